@@ -0,0 +1,319 @@
+/*
+Package mockengine provides scriptable MRCP engine and channel test doubles,
+so that applications and engine wrappers can be unit tested against the engine/channel contracts
+without the server stack: requests processed by the channel are answered by the behaviors scripted per method,
+the messages sent by the channel are recorded for assertions.
+*/
+package mockengine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/engine"
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+/** Behavior of the engine on request, returning the messages (responses, events) to send in order */
+type Behavior func(channel *Channel, request *message.MRCPMessage) ([]*message.MRCPMessage, error)
+
+/** Respond to the request with the status code (COMPLETE) */
+func Respond(status message.MRCPStatusCode) Behavior {
+	return func(channel *Channel, request *message.MRCPMessage) ([]*message.MRCPMessage, error) {
+		response := message.MRCPResponseCreate(request)
+		response.StartLine.StatusCode = status
+		return []*message.MRCPMessage{response}, nil
+	}
+}
+
+/**
+ * Respond to the request IN-PROGRESS, then complete it by the event,
+ * e.g. SPEAK answered by SPEAK-COMPLETE.
+ * @param eventId the resource specific id of the completion event
+ * @param prepare the function setting the headers (e.g. completion cause) and body of the event (optional)
+ */
+func InProgressThenComplete(eventId mrcp.MRCPMethodId, prepare func(event *message.MRCPMessage)) Behavior {
+	return func(channel *Channel, request *message.MRCPMessage) ([]*message.MRCPMessage, error) {
+		response := message.MRCPResponseCreate(request)
+		response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+		event := message.MRCPEventCreate(request, eventId)
+		event.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+		if prepare != nil {
+			prepare(event)
+		}
+		return []*message.MRCPMessage{response, event}, nil
+	}
+}
+
+/** Respond to the request IN-PROGRESS and leave it in progress (e.g. to be stopped) */
+func InProgress() Behavior {
+	return func(channel *Channel, request *message.MRCPMessage) ([]*message.MRCPMessage, error) {
+		response := message.MRCPResponseCreate(request)
+		response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+		return []*message.MRCPMessage{response}, nil
+	}
+}
+
+/** Fail processing of the request by the error */
+func Fail(err error) Behavior {
+	return func(channel *Channel, request *message.MRCPMessage) ([]*message.MRCPMessage, error) {
+		return nil, err
+	}
+}
+
+/** Mock engine */
+type Engine struct {
+	/** Engine the channels are created by */
+	Engine *engine.MRCPEngine
+
+	mutex     sync.Mutex
+	behaviors map[mrcp.MRCPMethodId]Behavior
+	fallback  Behavior
+	/** Error open of the engine and the channels fails with (if any) */
+	openErr error
+}
+
+/**
+ * Create mock engine, requests of methods not scripted are answered by 200 COMPLETE.
+ * @param id the engine identifier
+ * @param resourceId the resource of the engine
+ */
+func New(id string, resourceId mrcp.MRCPResourceId) *Engine {
+	e := &Engine{
+		behaviors: make(map[mrcp.MRCPMethodId]Behavior),
+		fallback:  Respond(message.MRCP_STATUS_CODE_SUCCESS),
+	}
+	e.Engine = engine.MRCPEngineCreate(resourceId, e, &engine.MRCPEngineMethodVTable{
+		Open: func(*engine.MRCPEngine) error { return e.openErrorGet() },
+	})
+	e.Engine.Id = id
+	e.Engine.Config = engine.MRCPEngineConfigAlloc()
+	return e
+}
+
+/**
+ * Script behavior of the engine on requests of the method.
+ * @param methodId the resource specific method id
+ * @param behavior the behavior
+ */
+func (e *Engine) On(methodId mrcp.MRCPMethodId, behavior Behavior) *Engine {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.behaviors[methodId] = behavior
+	return e
+}
+
+/** Script behavior of the engine on requests of methods not scripted */
+func (e *Engine) OnOther(behavior Behavior) *Engine {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.fallback = behavior
+	return e
+}
+
+/** Fail open of the engine and of its channels by the error */
+func (e *Engine) FailOpen(err error) *Engine {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.openErr = err
+	return e
+}
+
+func (e *Engine) openErrorGet() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.openErr
+}
+
+func (e *Engine) behaviorGet(methodId mrcp.MRCPMethodId) Behavior {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if behavior, ok := e.behaviors[methodId]; ok {
+		return behavior
+	}
+	return e.fallback
+}
+
+/** Mock engine channel recording requests processed and messages sent */
+type Channel struct {
+	/** Engine channel */
+	Channel *engine.MRCPEngineChannel
+
+	engine   *Engine
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	requests []*message.MRCPMessage
+	messages []*message.MRCPMessage
+	closed   bool
+}
+
+/**
+ * Create and open mock channel.
+ * @param id the channel identifier
+ * @param version the MRCP version
+ */
+func (e *Engine) NewChannel(id string, version mrcp.Version) (*Channel, error) {
+	c := &Channel{engine: e}
+	c.cond = sync.NewCond(&c.mutex)
+	c.Channel = e.Engine.MRCPEngineChannelCreate(&engine.MRCPEngineChannelMethodVTable{
+		Open: func(channel *engine.MRCPEngineChannel) error {
+			err := e.openErrorGet()
+			if respondErr := channel.MRCPEngineChannelOpenRespond(err == nil); err == nil {
+				err = respondErr
+			}
+			return err
+		},
+		Close: func(channel *engine.MRCPEngineChannel) error {
+			return channel.MRCPEngineChannelCloseRespond()
+		},
+		ProcessRequest: c.requestProcess,
+	}, c, nil)
+	c.Channel.Id = id
+	c.Channel.Version = version
+	c.Channel.EventVTable = &engine.MRCPEngineChannelEventVTable{
+		OnOpen: func(*engine.MRCPEngineChannel, bool) error { return nil },
+		OnClose: func(*engine.MRCPEngineChannel) error {
+			c.mutex.Lock()
+			c.closed = true
+			c.mutex.Unlock()
+			return nil
+		},
+		OnMessage: func(channel *engine.MRCPEngineChannel, msg *message.MRCPMessage) error {
+			c.mutex.Lock()
+			c.messages = append(c.messages, msg)
+			c.cond.Broadcast()
+			c.mutex.Unlock()
+			return nil
+		},
+	}
+	if err := engine.MRCPEngineChannelVirtualOpen(c.Channel); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Channel) requestProcess(channel *engine.MRCPEngineChannel, request *message.MRCPMessage) error {
+	c.mutex.Lock()
+	c.requests = append(c.requests, request)
+	c.mutex.Unlock()
+	msgs, err := c.engine.behaviorGet(request.StartLine.MethodId)(c, request)
+	if err != nil {
+		return err
+	}
+	for _, msg := range msgs {
+		if err := channel.MRCPEngineChannelMessageSend(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Process request by the channel the way the server does.
+ * @param request the request to process
+ */
+func (c *Channel) Process(request *message.MRCPMessage) error {
+	return engine.MRCPEngineChannelRequestProcess(c.Channel, request)
+}
+
+/** Send message (e.g. asynchronous event) on behalf of the engine */
+func (c *Channel) Send(msg *message.MRCPMessage) error {
+	return c.Channel.MRCPEngineChannelMessageSend(msg)
+}
+
+/** Close the channel */
+func (c *Channel) Close() error {
+	return engine.MRCPEngineChannelVirtualClose(c.Channel)
+}
+
+/** Check whether the close of the channel is responded */
+func (c *Channel) Closed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+/** Get requests processed by the channel */
+func (c *Channel) Requests() []*message.MRCPMessage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*message.MRCPMessage(nil), c.requests...)
+}
+
+/** Get messages sent by the channel */
+func (c *Channel) Messages() []*message.MRCPMessage {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return append([]*message.MRCPMessage(nil), c.messages...)
+}
+
+/** Forget requests and messages recorded */
+func (c *Channel) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests = nil
+	c.messages = nil
+}
+
+/**
+ * Wait for the number of messages sent by the channel (e.g. by behaviors sending asynchronously).
+ * @param count the number of messages to wait for
+ * @param timeout the time to wait
+ * @return the messages sent, error if they are fewer than the count in time
+ */
+func (c *Channel) WaitMessages(count int, timeout time.Duration) ([]*message.MRCPMessage, error) {
+	timer := time.AfterFunc(timeout, func() {
+		c.mutex.Lock()
+		c.cond.Broadcast()
+		c.mutex.Unlock()
+	})
+	defer timer.Stop()
+	deadline := time.Now().Add(timeout)
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for len(c.messages) < count && time.Now().Before(deadline) {
+		c.cond.Wait()
+	}
+	messages := append([]*message.MRCPMessage(nil), c.messages...)
+	if len(messages) < count {
+		return messages, fmt.Errorf("%d messages sent, want %d", len(messages), count)
+	}
+	return messages, nil
+}
+
+/**
+ * Assert the message sent by the channel.
+ * @param t the test
+ * @param index the index of the message sent
+ * @param messageType the message type expected
+ * @param methodId the method (or event) id expected
+ * @param state the request state expected
+ */
+func (c *Channel) AssertMessage(t testing.TB, index int, messageType message.MRCPMessageType, methodId mrcp.MRCPMethodId, state message.MRCPRequestState) *message.MRCPMessage {
+	t.Helper()
+	messages := c.Messages()
+	if index >= len(messages) {
+		t.Fatalf("%d messages sent, message %d is expected", len(messages), index)
+		return nil
+	}
+	msg := messages[index]
+	if msg.StartLine == nil || msg.StartLine.MessageType != messageType || msg.StartLine.MethodId != methodId || msg.StartLine.RequestState != state {
+		t.Fatalf("message %d is %+v, want type %d, method %d, state %d", index, msg.StartLine, messageType, methodId, state)
+	}
+	return msg
+}
+
+/**
+ * Assert the number of messages sent by the channel.
+ * @param t the test
+ * @param count the number of messages expected
+ */
+func (c *Channel) AssertMessageCount(t testing.TB, count int) {
+	t.Helper()
+	if n := len(c.Messages()); n != count {
+		t.Fatalf("%d messages sent, want %d", n, count)
+	}
+}
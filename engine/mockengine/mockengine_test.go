@@ -0,0 +1,51 @@
+package mockengine
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+func speakRequestCreate(id mrcp.MRCPRequestId, method resources.MRCPSynthesizerMethodId) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{
+		MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     mrcp.MRCP_VERSION_2,
+		RequestId:   id,
+		MethodId:    int64(method),
+	}
+	return request
+}
+
+func TestMockChannel(t *testing.T) {
+	e := New("mock-synth", mrcp.MRCP_SYNTHESIZER_RESOURCE).
+		On(int64(resources.SYNTHESIZER_SPEAK), InProgressThenComplete(int64(resources.SYNTHESIZER_SPEAK_COMPLETE), nil)).
+		On(int64(resources.SYNTHESIZER_PAUSE), Fail(errors.New("not supported")))
+	channel, err := e.NewChannel("channel-1", mrcp.MRCP_VERSION_2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.Process(speakRequestCreate(1, resources.SYNTHESIZER_SPEAK)); err != nil {
+		t.Fatal(err)
+	}
+	channel.AssertMessageCount(t, 2)
+	channel.AssertMessage(t, 0, message.MRCP_MESSAGE_TYPE_RESPONSE, int64(resources.SYNTHESIZER_SPEAK), message.MRCP_REQUEST_STATE_INPROGRESS)
+	channel.AssertMessage(t, 1, message.MRCP_MESSAGE_TYPE_EVENT, int64(resources.SYNTHESIZER_SPEAK_COMPLETE), message.MRCP_REQUEST_STATE_COMPLETE)
+
+	if err := channel.Process(speakRequestCreate(2, resources.SYNTHESIZER_PAUSE)); err == nil {
+		t.Fatalf("scripted failure is not returned")
+	}
+	if err := channel.Process(speakRequestCreate(3, resources.SYNTHESIZER_STOP)); err != nil {
+		t.Fatal(err)
+	}
+	channel.AssertMessage(t, 2, message.MRCP_MESSAGE_TYPE_RESPONSE, int64(resources.SYNTHESIZER_STOP), message.MRCP_REQUEST_STATE_COMPLETE)
+	if len(channel.Requests()) != 3 {
+		t.Fatalf("%d requests recorded, want 3", len(channel.Requests()))
+	}
+	if err := channel.Close(); err != nil || !channel.Closed() {
+		t.Fatalf("channel is not closed: %v", err)
+	}
+}
@@ -0,0 +1,306 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+/**
+ * Engine param (capability) listing the codecs the engine consumes/produces natively,
+ * e.g. "PCMU PCMA/8000 L16/8000/16000". If the codec negotiated with the peer is accepted,
+ * the decode/encode stages are skipped and encoded frames are passed through as is.
+ */
+const MRCP_ENGINE_PARAM_ACCEPTED_CODECS = "accepted-codecs"
+
+/** Codecs and sampling rates the engine handles natively, advertised on engine registration */
+type MRCPEngineCapabilities struct {
+	Input  mpf.CodecCapabilities // Codecs the engine consumes (recognizer, recorder, verifier)
+	Output mpf.CodecCapabilities // Codecs the engine produces (synthesizer)
+}
+
+/** Create engine capabilities */
+func MRCPEngineCapabilitiesCreate() *MRCPEngineCapabilities {
+	capabilities := &MRCPEngineCapabilities{}
+	capabilities.Input.CodecCapabilitiesInit(1)
+	capabilities.Output.CodecCapabilitiesInit(1)
+	return capabilities
+}
+
+/**
+ * Advertise codec the engine consumes natively.
+ * @param sampleRates the supported sampling rates (mpf_sample_rates_e)
+ * @param codecName the codec name (LPCM for linear PCM)
+ */
+func (c *MRCPEngineCapabilities) MRCPEngineInputCodecAdd(sampleRates int, codecName string) {
+	c.Input.CodecCapabilitiesAdd(sampleRates, codecName)
+}
+
+/**
+ * Advertise codec the engine produces natively.
+ * @param sampleRates the supported sampling rates (mpf_sample_rates_e)
+ * @param codecName the codec name (LPCM for linear PCM)
+ */
+func (c *MRCPEngineCapabilities) MRCPEngineOutputCodecAdd(sampleRates int, codecName string) {
+	c.Output.CodecCapabilitiesAdd(sampleRates, codecName)
+}
+
+/** Parse codec capabilities (<name>[/<sampling rate>]*) accepted by the engine */
+func mrcpEngineAcceptedCodecsParse(value string, codecs *mpf.CodecCapabilities) error {
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ' ' || r == ',' || r == ';'
+	}) {
+		fields := strings.Split(item, "/")
+		var sampleRates int
+		for _, field := range fields[1:] {
+			rate, err := strconv.ParseUint(field, 10, 16)
+			if err != nil {
+				return fmt.Errorf("invalid sampling rate %s of codec %s", field, fields[0])
+			}
+			/* RTP clock rate may be specified as well (G722/8000) */
+			mask := mpf.SampleRateMaskGet(mpf.CodecSamplingRateGet(fields[0], uint16(rate)))
+			if mask == mpf.MPF_SAMPLE_RATE_NONE {
+				return fmt.Errorf("unsupported sampling rate %s of codec %s", field, fields[0])
+			}
+			sampleRates |= mask
+		}
+		if sampleRates == mpf.MPF_SAMPLE_RATE_NONE {
+			sampleRates = mpf.MPF_SAMPLE_RATE_8000
+		}
+		codecs.CodecCapabilitiesAdd(sampleRates, fields[0])
+	}
+	return nil
+}
+
+/*
+ * Get codecs the engine handles natively in the direction of the engine termination stream:
+ * advertised capabilities merged with the "accepted-codecs" param.
+ * Linear PCM at 8 kHz is assumed if nothing is advertised.
+ */
+func (engine *MRCPEngine) mrcpEngineCodecsGet(direction mpf.StreamDirection) (*mpf.CodecCapabilities, error) {
+	codecs := &mpf.CodecCapabilities{}
+	codecs.CodecCapabilitiesInit(1)
+	if engine.Capabilities != nil {
+		if (direction&mpf.STREAM_DIRECTION_SEND) == mpf.STREAM_DIRECTION_SEND && engine.Capabilities.Input.AttribArr != nil {
+			codecs.CodecCapabilitiesMerge(&engine.Capabilities.Input)
+		}
+		if (direction&mpf.STREAM_DIRECTION_RECEIVE) == mpf.STREAM_DIRECTION_RECEIVE && engine.Capabilities.Output.AttribArr != nil {
+			codecs.CodecCapabilitiesMerge(&engine.Capabilities.Output)
+		}
+	}
+	if err := mrcpEngineAcceptedCodecsParse(engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_ACCEPTED_CODECS), codecs); err != nil {
+		return nil, err
+	}
+	codecs.CodecCapabilitiesValidate()
+	return codecs, nil
+}
+
+/**
+ * Create stream capabilities of the engine audio termination.
+ * @param direction the stream direction (send for engines consuming audio, receive for engines producing it)
+ */
+func (engine *MRCPEngine) MRCPEngineStreamCapabilitiesCreate(direction mpf.StreamDirection) (*mpf.StreamCapabilities, error) {
+	codecs, err := engine.mrcpEngineCodecsGet(direction)
+	if err != nil {
+		return nil, err
+	}
+	capabilities := mpf.StreamCapabilitiesCreate(direction)
+	capabilities.StreamCapabilitiesCodecsGet().CodecCapabilitiesMerge(codecs)
+	return capabilities, nil
+}
+
+/**
+ * Check whether the engine accepts the codec natively.
+ * @param descriptor the codec descriptor negotiated with the peer
+ */
+func (engine *MRCPEngine) MRCPEngineCodecAccept(descriptor *mpf.CodecDescriptor) bool {
+	if descriptor == nil {
+		return false
+	}
+	codecs, err := engine.mrcpEngineCodecsGet(mpf.STREAM_DIRECTION_DUPLEX)
+	if err != nil {
+		return false
+	}
+	return codecs.CodecCapabilitiesNativeFind(descriptor) != nil
+}
+
+/* Cost of the offered codec: 0 - passthrough, 1 - transcoding, 2 - transcoding and resampling, -1 - unsupported */
+func (engine *MRCPEngine) mrcpEngineCodecCost(codecs *mpf.CodecCapabilities, descriptor *mpf.CodecDescriptor) int {
+	if codecs.CodecCapabilitiesNativeFind(descriptor) != nil {
+		return 0
+	}
+	codecManager := engine.CodecManager
+	if codecManager == nil {
+		codecManager = mpf.CodecManagerDefaultGet()
+	}
+	if codec, _ := codecManager.CodecManagerCodecGet(descriptor); codec == nil {
+		return -1
+	}
+	lpcm := mpf.CodecLPcmDescriptorCreate(descriptor.SamplingRate, descriptor.ChannelCount)
+	if codecs.CodecCapabilitiesNativeFind(lpcm) != nil {
+		return 1
+	}
+	return 2
+}
+
+/**
+ * Negotiate codec of SDP answer with the offered codec list, preferring codecs the engine
+ * handles natively, then the ones requiring no resampling, in the order of the offer.
+ * The primary (and named event) descriptors of the list are set accordingly.
+ * @param direction the direction of the engine termination stream
+ * @param offer the offered codec list
+ */
+func (engine *MRCPEngine) MRCPEngineCodecListNegotiate(direction mpf.StreamDirection, offer *mpf.CodecList) (*mpf.CodecDescriptor, error) {
+	codecs, err := engine.mrcpEngineCodecsGet(direction)
+	if err != nil {
+		return nil, err
+	}
+	var (
+		best     *mpf.CodecDescriptor
+		bestCost = -1
+	)
+	offer.PrimaryDescriptor = nil
+	offer.EventDescriptor = nil
+	for i := 0; i < offer.DescriptorArr.Stack.Size(); i++ {
+		descriptor := offer.CodecListDescriptorGet(i)
+		if !descriptor.Enabled {
+			continue
+		}
+		if mpf.EventDescriptorCheck(descriptor) {
+			if offer.EventDescriptor == nil && codecs.AllowNamedEvents {
+				offer.EventDescriptor = descriptor
+			}
+			continue
+		}
+		cost := engine.mrcpEngineCodecCost(codecs, descriptor)
+		if cost >= 0 && (best == nil || cost < bestCost) {
+			best = descriptor
+			bestCost = cost
+		}
+	}
+	if best == nil {
+		return nil, message.MRCPFailureErrorCreate(message.MRCP_FAILURE_CODEC_MISMATCH,
+			fmt.Errorf("no offered codec is supported by engine %s", engine.Id))
+	}
+	offer.PrimaryDescriptor = best
+	return best, nil
+}
+
+/**
+ * Get codec descriptor of the engine termination stream for the codec negotiated with the peer,
+ * so that the media topology needs the fewest conversions: the peer codec itself if it is handled natively,
+ * otherwise linear PCM at the peer sampling rate if supported, otherwise linear PCM at the advertised rate.
+ * @param direction the direction of the engine termination stream
+ * @param peer the codec descriptor negotiated with the peer
+ */
+func (engine *MRCPEngine) MRCPEngineStreamDescriptorGet(direction mpf.StreamDirection, peer *mpf.CodecDescriptor) (*mpf.CodecDescriptor, error) {
+	codecs, err := engine.mrcpEngineCodecsGet(direction)
+	if err != nil {
+		return nil, err
+	}
+	if codecs.CodecCapabilitiesNativeFind(peer) != nil {
+		return mpf.CodecDescriptorClone(peer), nil
+	}
+	descriptor := mpf.CodecLPcmDescriptorCreate(peer.SamplingRate, peer.ChannelCount)
+	if codecs.CodecCapabilitiesNativeFind(descriptor) != nil {
+		return descriptor, nil
+	}
+	for _, rate := range []uint16{8000, 16000, 32000, 48000} {
+		descriptor.SamplingRate = rate
+		if codecs.CodecCapabilitiesNativeFind(descriptor) != nil {
+			return descriptor, nil
+		}
+	}
+	return nil, fmt.Errorf("engine %s handles no linear audio", engine.Id)
+}
+
+/**
+ * Set codec negotiated with the peer, the engine is notified by the UpdateCodec method (if any),
+ * so that engines accepting compressed audio natively may configure their codecs to match the wire format.
+ * @param descriptor the codec descriptor negotiated with the peer (including the fmtp format)
+ */
+func (channel *MRCPEngineChannel) MRCPEngineChannelPeerCodecSet(descriptor *mpf.CodecDescriptor) error {
+	if descriptor == nil {
+		return fmt.Errorf("no codec negotiated")
+	}
+	channel.peerCodec = mpf.CodecDescriptorClone(descriptor)
+	if channel.MethodVTable != nil && channel.MethodVTable.UpdateCodec != nil {
+		return channel.MethodVTable.UpdateCodec(channel, channel.peerCodec)
+	}
+	return nil
+}
+
+/** Get codec negotiated with the peer, nil if not negotiated yet */
+func (channel *MRCPEngineChannel) MRCPEngineChannelPeerCodecGet() *mpf.CodecDescriptor {
+	return channel.peerCodec
+}
+
+/**
+ * Get params of the codec negotiated with the peer (SDP fmtp), e.g. "maxaveragebitrate" of Opus.
+ * Names are lower cased, empty if the codec is not negotiated yet.
+ */
+func (channel *MRCPEngineChannel) MRCPEngineChannelCodecParamsGet() map[string]string {
+	if channel.peerCodec == nil {
+		return map[string]string{}
+	}
+	return channel.peerCodec.CodecFormatParamsGet()
+}
+
+/**
+ * Negotiate media of the channel with the SDP offer of the peer, to add/modify the RTP termination with.
+ * The codec of the answer is chosen by the cost for the engine (@see MRCPEngineCodecListNegotiate()),
+ * the local media (answer) of the descriptor is set to the chosen codec and named events,
+ * the audio stream of the engine termination (if any) is set to the codec requiring the fewest conversions
+ * and the engine is notified of the codec negotiated (@see MRCPEngineChannelPeerCodecSet()).
+ * @param direction the direction of the engine termination stream
+ * @param descriptor the RTP termination descriptor with the remote media (offer)
+ */
+func (channel *MRCPEngineChannel) MRCPEngineChannelMediaNegotiate(direction mpf.StreamDirection, descriptor *mpf.RtpTerminationDescriptor) (*mpf.CodecDescriptor, error) {
+	remote := descriptor.RtpTerminationDescriptorAudioRemoteGet()
+	if remote == nil || remote.RtpMediaDescriptorCodecListGet().DescriptorArr == nil {
+		return nil, fmt.Errorf("no remote media offered to channel %s", channel.Id)
+	}
+	offer := remote.RtpMediaDescriptorCodecListGet()
+	peer, err := channel.engine.MRCPEngineCodecListNegotiate(direction, offer)
+	if err != nil {
+		return nil, err
+	}
+	streamDescriptor, err := channel.engine.MRCPEngineStreamDescriptorGet(direction, peer)
+	if err != nil {
+		return nil, err
+	}
+
+	/* answer with the offered payload types */
+	var answer mpf.CodecList
+	mpf.CodecListInit(&answer, 2)
+	answer.PrimaryDescriptor = mpf.CodecListAdd(&answer)
+	*answer.PrimaryDescriptor = *peer
+	if offer.EventDescriptor != nil {
+		answer.EventDescriptor = mpf.CodecListAdd(&answer)
+		*answer.EventDescriptor = *offer.EventDescriptor
+	}
+	local := descriptor.RtpTerminationDescriptorAudioLocalGet()
+	if local == nil {
+		local = mpf.RtpMediaDescriptorAlloc()
+		descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	}
+	*local.RtpMediaDescriptorCodecListGet() = answer
+
+	if channel.Termination != nil {
+		if stream := channel.Termination.TerminationAudioStreamGet(); stream != nil {
+			if (direction & mpf.STREAM_DIRECTION_SEND) == mpf.STREAM_DIRECTION_SEND {
+				stream.TXDescriptor = streamDescriptor
+			}
+			if (direction & mpf.STREAM_DIRECTION_RECEIVE) == mpf.STREAM_DIRECTION_RECEIVE {
+				stream.RXDescriptor = mpf.CodecDescriptorClone(streamDescriptor)
+			}
+		}
+	}
+	if err := channel.MRCPEngineChannelPeerCodecSet(answer.PrimaryDescriptor); err != nil {
+		return nil, err
+	}
+	return answer.PrimaryDescriptor, nil
+}
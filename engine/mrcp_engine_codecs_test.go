@@ -0,0 +1,246 @@
+package engine
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+func engineCodecsTestDescriptor(pt uint8, name string, rate uint16) *mpf.CodecDescriptor {
+	return &mpf.CodecDescriptor{PayloadType: pt, Name: name, SamplingRate: rate, ChannelCount: 1, Enabled: true}
+}
+
+func engineCodecsTestOffer(descriptors ...*mpf.CodecDescriptor) *mpf.CodecList {
+	offer := &mpf.CodecList{}
+	mpf.CodecListInit(offer, len(descriptors))
+	for _, descriptor := range descriptors {
+		*mpf.CodecListAdd(offer) = *descriptor
+	}
+	return offer
+}
+
+func engineCodecsTestEngine(input map[string]int) *MRCPEngine {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	engine.Capabilities = MRCPEngineCapabilitiesCreate()
+	for name, rates := range input {
+		engine.Capabilities.MRCPEngineInputCodecAdd(rates, name)
+	}
+	return engine
+}
+
+func TestEngineCodecCost(t *testing.T) {
+	engine := engineCodecsTestEngine(map[string]int{
+		"PCMU": mpf.MPF_SAMPLE_RATE_8000,
+		"LPCM": mpf.MPF_SAMPLE_RATE_8000,
+	})
+	codecs, err := engine.mrcpEngineCodecsGet(mpf.STREAM_DIRECTION_SEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		descriptor *mpf.CodecDescriptor
+		cost       int
+	}{
+		{engineCodecsTestDescriptor(0, "PCMU", 8000), 0},
+		{engineCodecsTestDescriptor(8, "PCMA", 8000), 1},
+		{engineCodecsTestDescriptor(96, "L16", 8000), 1},
+		{engineCodecsTestDescriptor(9, "G722", 16000), 2},
+		{engineCodecsTestDescriptor(97, "L16", 16000), 2},
+		{engineCodecsTestDescriptor(98, "opus", 48000), -1},
+	}
+	for _, test := range tests {
+		if cost := engine.mrcpEngineCodecCost(codecs, test.descriptor); cost != test.cost {
+			t.Errorf("cost of %s/%d: %d, want %d", test.descriptor.Name, test.descriptor.SamplingRate, cost, test.cost)
+		}
+	}
+}
+
+func TestEngineCodecListNegotiate(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]int
+		offer []*mpf.CodecDescriptor
+		want  string
+		event bool
+	}{
+		{
+			name:  "native preferred to the offer order",
+			input: map[string]int{"PCMU": mpf.MPF_SAMPLE_RATE_8000, "LPCM": mpf.MPF_SAMPLE_RATE_8000},
+			offer: []*mpf.CodecDescriptor{engineCodecsTestDescriptor(8, "PCMA", 8000), engineCodecsTestDescriptor(0, "PCMU", 8000)},
+			want:  "PCMU",
+		},
+		{
+			name:  "transcoding preferred to resampling",
+			input: map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000},
+			offer: []*mpf.CodecDescriptor{engineCodecsTestDescriptor(9, "G722", 16000), engineCodecsTestDescriptor(8, "PCMA", 8000)},
+			want:  "PCMA",
+		},
+		{
+			name:  "offer order kept at equal cost",
+			input: map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000},
+			offer: []*mpf.CodecDescriptor{engineCodecsTestDescriptor(8, "PCMA", 8000), engineCodecsTestDescriptor(0, "PCMU", 8000)},
+			want:  "PCMA",
+		},
+		{
+			name:  "unsupported skipped, named events kept",
+			input: map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_16000},
+			offer: []*mpf.CodecDescriptor{
+				engineCodecsTestDescriptor(98, "opus", 48000),
+				engineCodecsTestDescriptor(101, "telephone-event", 8000),
+				engineCodecsTestDescriptor(9, "G722", 16000),
+			},
+			want:  "G722",
+			event: true,
+		},
+		{
+			name:  "nothing supported",
+			input: map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000},
+			offer: []*mpf.CodecDescriptor{engineCodecsTestDescriptor(98, "opus", 48000)},
+		},
+	}
+	for _, test := range tests {
+		engine := engineCodecsTestEngine(test.input)
+		offer := engineCodecsTestOffer(test.offer...)
+		descriptor, err := engine.MRCPEngineCodecListNegotiate(mpf.STREAM_DIRECTION_SEND, offer)
+		if test.want == "" {
+			if failure, ok := err.(*message.MRCPFailureError); !ok || failure.Failure != message.MRCP_FAILURE_CODEC_MISMATCH {
+				t.Errorf("%s: %v, want codec mismatch", test.name, err)
+			}
+			continue
+		}
+		if err != nil || descriptor == nil || descriptor.Name != test.want || offer.PrimaryDescriptor != descriptor {
+			t.Errorf("%s: %v %v, want %s", test.name, descriptor, err, test.want)
+			continue
+		}
+		if (offer.EventDescriptor != nil) != test.event {
+			t.Errorf("%s: event descriptor %v", test.name, offer.EventDescriptor)
+		}
+	}
+}
+
+func TestEngineStreamDescriptorGet(t *testing.T) {
+	tests := []struct {
+		name  string
+		input map[string]int
+		peer  *mpf.CodecDescriptor
+		want  string
+		rate  uint16
+	}{
+		{"native passthrough", map[string]int{"PCMU": mpf.MPF_SAMPLE_RATE_8000}, engineCodecsTestDescriptor(0, "PCMU", 8000), "PCMU", 8000},
+		{"linear at the peer rate", map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000 | mpf.MPF_SAMPLE_RATE_16000}, engineCodecsTestDescriptor(9, "G722", 16000), "LPCM", 16000},
+		{"linear at the advertised rate", map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000}, engineCodecsTestDescriptor(9, "G722", 16000), "LPCM", 8000},
+		{"no linear", map[string]int{"PCMU": mpf.MPF_SAMPLE_RATE_8000}, engineCodecsTestDescriptor(8, "PCMA", 8000), "", 0},
+	}
+	for _, test := range tests {
+		engine := engineCodecsTestEngine(test.input)
+		descriptor, err := engine.MRCPEngineStreamDescriptorGet(mpf.STREAM_DIRECTION_SEND, test.peer)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: %v, want error", test.name, descriptor)
+			}
+			continue
+		}
+		if err != nil || descriptor.Name != test.want || descriptor.SamplingRate != test.rate {
+			t.Errorf("%s: %v %v, want %s/%d", test.name, descriptor, err, test.want, test.rate)
+		}
+		if descriptor == test.peer {
+			t.Errorf("%s: peer descriptor is not cloned", test.name)
+		}
+	}
+}
+
+func TestEngineChannelMediaNegotiate(t *testing.T) {
+	engine := engineCodecsTestEngine(map[string]int{"LPCM": mpf.MPF_SAMPLE_RATE_8000 | mpf.MPF_SAMPLE_RATE_16000})
+	termination, err := mpf.NullTerminationCreate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{}, nil, termination)
+
+	remote := mpf.RtpMediaDescriptorAlloc()
+	*remote.RtpMediaDescriptorCodecListGet() = *engineCodecsTestOffer(
+		engineCodecsTestDescriptor(0, "PCMU", 8000),
+		engineCodecsTestDescriptor(9, "G722", 16000),
+		engineCodecsTestDescriptor(101, "telephone-event", 8000),
+	)
+	descriptor := mpf.RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(remote)
+	peer, err := channel.MRCPEngineChannelMediaNegotiate(mpf.STREAM_DIRECTION_SEND, descriptor)
+	if err != nil || peer.Name != "PCMU" {
+		t.Fatalf("negotiated %v: %v", peer, err)
+	}
+
+	/* answer carries the negotiated codec and named events with the offered payload types */
+	local := descriptor.RtpTerminationDescriptorAudioLocalGet()
+	if local == nil {
+		t.Fatal("no answer")
+	}
+	answer := local.RtpMediaDescriptorCodecListGet()
+	if answer.DescriptorArr.Stack.Size() != 2 || answer.PrimaryDescriptor != peer || peer.PayloadType != 0 ||
+		answer.EventDescriptor == nil || answer.EventDescriptor.PayloadType != 101 {
+		t.Fatalf("answer %v, primary %v, event %v", answer.DescriptorArr.Stack.Size(), answer.PrimaryDescriptor, answer.EventDescriptor)
+	}
+
+	/* engine termination consumes linear audio at the peer rate */
+	stream := termination.TerminationAudioStreamGet()
+	if stream.TXDescriptor == nil || !mpf.CodecLPcmDescriptorMatch(stream.TXDescriptor) || stream.TXDescriptor.SamplingRate != 8000 {
+		t.Fatalf("engine stream descriptor %v", stream.TXDescriptor)
+	}
+
+	/* no remote media */
+	if _, err := channel.MRCPEngineChannelMediaNegotiate(mpf.STREAM_DIRECTION_SEND, mpf.RtpTerminationDescriptorAlloc()); err == nil {
+		t.Fatal("negotiated without offer")
+	}
+}
+
+func TestEngineChannelPeerCodecSet(t *testing.T) {
+	engine := engineCodecsTestEngine(map[string]int{"AMR": mpf.MPF_SAMPLE_RATE_8000, "LPCM": mpf.MPF_SAMPLE_RATE_8000})
+	var updated []*mpf.CodecDescriptor
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		UpdateCodec: func(channel *MRCPEngineChannel, descriptor *mpf.CodecDescriptor) error {
+			updated = append(updated, descriptor)
+			return nil
+		},
+	}, nil, nil)
+	if params := channel.MRCPEngineChannelCodecParamsGet(); len(params) != 0 {
+		t.Fatalf("params before negotiation: %v", params)
+	}
+
+	/* codec negotiated with the media is passed to the engine along with the fmtp */
+	amr := engineCodecsTestDescriptor(96, "AMR", 8000)
+	amr.Format = "mode-set=0,2; octet-align=1"
+	remote := mpf.RtpMediaDescriptorAlloc()
+	*remote.RtpMediaDescriptorCodecListGet() = *engineCodecsTestOffer(amr)
+	descriptor := mpf.RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(remote)
+	peer, err := channel.MRCPEngineChannelMediaNegotiate(mpf.STREAM_DIRECTION_SEND, descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated) != 1 || updated[0] != channel.MRCPEngineChannelPeerCodecGet() || updated[0] == peer || updated[0].Name != "AMR" {
+		t.Fatalf("codec updates %v", updated)
+	}
+	params := channel.MRCPEngineChannelCodecParamsGet()
+	if len(params) != 2 || params["mode-set"] != "0,2" || params["octet-align"] != "1" {
+		t.Fatalf("params %v", params)
+	}
+
+	/* engine refusing the codec fails the negotiation */
+	channel.MethodVTable.UpdateCodec = func(channel *MRCPEngineChannel, descriptor *mpf.CodecDescriptor) error {
+		return fmt.Errorf("unsupported mode-set")
+	}
+	if _, err := channel.MRCPEngineChannelMediaNegotiate(mpf.STREAM_DIRECTION_SEND, descriptor); err == nil {
+		t.Fatal("codec refused by the engine is negotiated")
+	}
+
+	/* update is optional */
+	channel.MethodVTable.UpdateCodec = nil
+	if err := channel.MRCPEngineChannelPeerCodecSet(amr); err != nil {
+		t.Fatal(err)
+	}
+	if err := channel.MRCPEngineChannelPeerCodecSet(nil); err == nil {
+		t.Fatal("nil codec is set")
+	}
+}
@@ -0,0 +1,206 @@
+package engine
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+/**
+ * Engine param specifying the time in msec the engine must respond to a request within, 0 disables the deadline.
+ * The deadline of a particular method may be specified by "response-timeout.<method name>", e.g. "response-timeout.SPEAK".
+ */
+const MRCP_ENGINE_PARAM_RESPONSE_TIMEOUT = "response-timeout"
+
+/** Engine param enabling recycling (closing and reopening) of engine channels which missed the response deadline */
+const MRCP_ENGINE_PARAM_RECYCLE_SUSPECT_CHANNELS = "recycle-suspect-channels"
+
+/** Max number of requests answered on behalf of the engine, the late messages of which are dropped */
+const MRCP_ENGINE_EXPIRED_REQUEST_MAX_COUNT = 64
+
+/** Response deadlines of requests being processed by the engine channel */
+type mrcpEngineChannelDeadlines struct {
+	mutex        sync.Mutex
+	timers       map[mrcp.MRCPRequestId]*time.Timer // Deadlines of requests waiting for response
+	expired      map[mrcp.MRCPRequestId]bool        // Requests answered on behalf of the engine
+	expiredOrder []mrcp.MRCPRequestId               // Requests answered on behalf of the engine, oldest first
+	suspect      bool                               // Channel missed the response deadline
+}
+
+/** Get response deadline of the request method, 0 if there is no deadline */
+func (engine *MRCPEngine) MRCPEngineResponseTimeoutGet(methodName string) time.Duration {
+	value := ""
+	if methodName != "" {
+		value = engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_RESPONSE_TIMEOUT + "." + methodName)
+	}
+	if value == "" {
+		value = engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_RESPONSE_TIMEOUT)
+	}
+	timeout, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || timeout <= 0 {
+		return 0
+	}
+	return time.Duration(timeout) * time.Millisecond
+}
+
+/* Start response deadline of the request */
+func (channel *MRCPEngineChannel) deadlineStart(request *message.MRCPMessage) {
+	if channel.engine == nil || request == nil || request.StartLine == nil {
+		return
+	}
+	timeout := channel.engine.MRCPEngineResponseTimeoutGet(request.StartLine.MethodName)
+	if timeout <= 0 {
+		return
+	}
+	requestId := request.StartLine.RequestId
+	deadlines := &channel.deadlines
+	deadlines.mutex.Lock()
+	defer deadlines.mutex.Unlock()
+	if deadlines.timers == nil {
+		deadlines.timers = make(map[mrcp.MRCPRequestId]*time.Timer)
+	}
+	if timer := deadlines.timers[requestId]; timer != nil {
+		timer.Stop()
+	}
+	deadlines.timers[requestId] = time.AfterFunc(timeout, func() {
+		channel.deadlineExpire(request)
+	})
+}
+
+/*
+ * Check the message sent by the engine against the deadlines: the response stops the deadline of its request,
+ * messages of the requests already answered on behalf of the engine are to be dropped, until the message
+ * completing the request (of COMPLETE request-state) is dropped.
+ */
+func (channel *MRCPEngineChannel) deadlineCheck(msg *message.MRCPMessage) bool {
+	if msg == nil || msg.StartLine == nil {
+		return true
+	}
+	requestId := msg.StartLine.RequestId
+	deadlines := &channel.deadlines
+	deadlines.mutex.Lock()
+	defer deadlines.mutex.Unlock()
+	if deadlines.expired[requestId] {
+		if msg.StartLine.RequestState == message.MRCP_REQUEST_STATE_COMPLETE {
+			deadlines.expiredRemove(requestId)
+		}
+		return false
+	}
+	if msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE {
+		if timer := deadlines.timers[requestId]; timer != nil {
+			timer.Stop()
+			delete(deadlines.timers, requestId)
+		}
+	}
+	return true
+}
+
+/* Stop response deadline of the request, return true if the request is already answered on behalf of the engine */
+func (channel *MRCPEngineChannel) deadlineStop(request *message.MRCPMessage) bool {
+	if request == nil || request.StartLine == nil {
+		return false
+	}
+	deadlines := &channel.deadlines
+	deadlines.mutex.Lock()
+	defer deadlines.mutex.Unlock()
+	if timer := deadlines.timers[request.StartLine.RequestId]; timer != nil {
+		timer.Stop()
+		delete(deadlines.timers, request.StartLine.RequestId)
+	}
+	return deadlines.expired[request.StartLine.RequestId]
+}
+
+/* Mark the request answered on behalf of the engine, the oldest one is forgotten over the max count */
+func (deadlines *mrcpEngineChannelDeadlines) expiredAdd(requestId mrcp.MRCPRequestId) {
+	if deadlines.expired == nil {
+		deadlines.expired = make(map[mrcp.MRCPRequestId]bool)
+	}
+	if len(deadlines.expiredOrder) >= MRCP_ENGINE_EXPIRED_REQUEST_MAX_COUNT {
+		delete(deadlines.expired, deadlines.expiredOrder[0])
+		deadlines.expiredOrder = deadlines.expiredOrder[1:]
+	}
+	deadlines.expired[requestId] = true
+	deadlines.expiredOrder = append(deadlines.expiredOrder, requestId)
+}
+
+/* Forget the request answered on behalf of the engine */
+func (deadlines *mrcpEngineChannelDeadlines) expiredRemove(requestId mrcp.MRCPRequestId) {
+	delete(deadlines.expired, requestId)
+	for i, id := range deadlines.expiredOrder {
+		if id == requestId {
+			deadlines.expiredOrder = append(deadlines.expiredOrder[:i], deadlines.expiredOrder[i+1:]...)
+			break
+		}
+	}
+}
+
+/*
+ * Respond to the request on behalf of the engine which missed the deadline, even if the engine call processing
+ * the request is still stuck. The recycling is serialized with open/close of the channel.
+ */
+func (channel *MRCPEngineChannel) deadlineExpire(request *message.MRCPMessage) {
+	requestId := request.StartLine.RequestId
+	deadlines := &channel.deadlines
+	deadlines.mutex.Lock()
+	if _, ok := deadlines.timers[requestId]; !ok {
+		/* responded in the meantime */
+		deadlines.mutex.Unlock()
+		return
+	}
+	delete(deadlines.timers, requestId)
+	deadlines.expiredAdd(requestId)
+	deadlines.suspect = true
+	deadlines.mutex.Unlock()
+
+	response := message.MRCPResponseCreate(request)
+	message.MRCPStatusMapDefaultGet().MRCPResponseFailureSet(response, message.MRCP_FAILURE_TIMEOUT)
+	if channel.EventVTable != nil && channel.EventVTable.OnMessage != nil {
+		channel.messageDeliver(response)
+	}
+
+	if channel.engine != nil {
+		if recycle, _ := strconv.ParseBool(channel.engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_RECYCLE_SUSPECT_CHANNELS)); recycle {
+			channel.MRCPEngineChannelRecycle()
+		}
+	}
+}
+
+/* Stop all the deadlines of the channel (late messages of expired requests are still dropped, up to the max count) */
+func (channel *MRCPEngineChannel) deadlinesReset() {
+	deadlines := &channel.deadlines
+	deadlines.mutex.Lock()
+	defer deadlines.mutex.Unlock()
+	for _, timer := range deadlines.timers {
+		timer.Stop()
+	}
+	deadlines.timers = nil
+	deadlines.suspect = false
+}
+
+/** Check whether the channel missed the response deadline */
+func (channel *MRCPEngineChannel) MRCPEngineChannelSuspectGet() bool {
+	channel.deadlines.mutex.Lock()
+	defer channel.deadlines.mutex.Unlock()
+	return channel.deadlines.suspect
+}
+
+/**
+ * Recycle engine channel: close it and open it again, so that the stuck engine call is abandoned.
+ * Pending deadlines are cancelled and the suspect mark is cleared on close.
+ */
+func (channel *MRCPEngineChannel) MRCPEngineChannelRecycle() error {
+	channel.processMutex.Lock()
+	defer channel.processMutex.Unlock()
+	return channel.recycle()
+}
+
+/* Recycle engine channel, called under the process mutex */
+func (channel *MRCPEngineChannel) recycle() error {
+	if err := channel.close(); err != nil {
+		return err
+	}
+	return channel.open()
+}
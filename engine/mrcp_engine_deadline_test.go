@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+/* Channel of the engine which never responds, recording the messages delivered and the opens/closes */
+type deadlineTestChannel struct {
+	*MRCPEngineChannel
+	mutex     sync.Mutex
+	delivered []*message.MRCPMessage
+	opened    int
+	closed    int
+	messages  chan *message.MRCPMessage
+}
+
+func deadlineTestChannelCreate(params map[string]string, process func(channel *MRCPEngineChannel, request *message.MRCPMessage) error) *deadlineTestChannel {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	engine.Config = &MRCPEngineConfig{Params: params}
+	c := &deadlineTestChannel{messages: make(chan *message.MRCPMessage, 16)}
+	c.MRCPEngineChannel = engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		Open: func(channel *MRCPEngineChannel) error {
+			c.mutex.Lock()
+			c.opened++
+			c.mutex.Unlock()
+			return nil
+		},
+		Close: func(channel *MRCPEngineChannel) error {
+			c.mutex.Lock()
+			c.closed++
+			c.mutex.Unlock()
+			return nil
+		},
+		ProcessRequest: process,
+	}, nil, nil)
+	c.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *message.MRCPMessage) error {
+			c.mutex.Lock()
+			c.delivered = append(c.delivered, msg)
+			c.mutex.Unlock()
+			c.messages <- msg
+			return nil
+		},
+	}
+	return c
+}
+
+func deadlineTestRequestCreate(requestId mrcp.MRCPRequestId) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, Version: mrcp.MRCP_VERSION_2, MethodName: "SPEAK", RequestId: requestId}
+	return request
+}
+
+func (c *deadlineTestChannel) messageWait(t *testing.T) *message.MRCPMessage {
+	select {
+	case msg := <-c.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message delivered")
+	}
+	return nil
+}
+
+func TestDeadlineExpire(t *testing.T) {
+	c := deadlineTestChannelCreate(map[string]string{MRCP_ENGINE_PARAM_RESPONSE_TIMEOUT: "20"}, func(channel *MRCPEngineChannel, request *message.MRCPMessage) error {
+		return nil
+	})
+	if err := MRCPEngineChannelVirtualOpen(c.MRCPEngineChannel); err != nil {
+		t.Fatal(err)
+	}
+	request := deadlineTestRequestCreate(1)
+	if err := MRCPEngineChannelRequestProcess(c.MRCPEngineChannel, request); err != nil {
+		t.Fatal(err)
+	}
+
+	/* answered on behalf of the engine */
+	response := c.messageWait(t)
+	if response.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_RESPONSE || response.StartLine.RequestId != 1 ||
+		response.StartLine.StatusCode != message.MRCP_STATUS_CODE_METHOD_FAILED || response.StartLine.RequestState != message.MRCP_REQUEST_STATE_COMPLETE {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	if !c.MRCPEngineChannelSuspectGet() {
+		t.Fatal("channel is not suspect")
+	}
+
+	/* late messages are dropped until the request is completed */
+	late := message.MRCPResponseCreate(request)
+	late.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+	event := message.MRCPEventCreate(request, 0)
+	event.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	for _, msg := range []*message.MRCPMessage{late, event} {
+		if err := c.MRCPEngineChannelMessageSend(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(c.delivered) != 1 {
+		t.Fatalf("%d messages delivered", len(c.delivered))
+	}
+	if len(c.deadlines.expired) != 0 || len(c.deadlines.expiredOrder) != 0 {
+		t.Fatalf("expired requests %v are not pruned", c.deadlines.expiredOrder)
+	}
+
+	/* the response in time stops the deadline */
+	request = deadlineTestRequestCreate(2)
+	if err := MRCPEngineChannelRequestProcess(c.MRCPEngineChannel, request); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.MRCPEngineChannelMessageSend(message.MRCPResponseCreate(request)); err != nil {
+		t.Fatal(err)
+	}
+	if response := c.messageWait(t); response.StartLine.RequestId != 2 || response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(c.messages) != 0 {
+		t.Fatal("answered on behalf of the engine which responded")
+	}
+}
+
+func TestDeadlineExpiredBound(t *testing.T) {
+	var deadlines mrcpEngineChannelDeadlines
+	for i := 0; i < MRCP_ENGINE_EXPIRED_REQUEST_MAX_COUNT+10; i++ {
+		deadlines.expiredAdd(mrcp.MRCPRequestId(i))
+	}
+	if len(deadlines.expired) != MRCP_ENGINE_EXPIRED_REQUEST_MAX_COUNT || deadlines.expired[9] || !deadlines.expired[10] {
+		t.Fatalf("%d expired requests", len(deadlines.expired))
+	}
+	deadlines.expiredRemove(20)
+	if len(deadlines.expired) != len(deadlines.expiredOrder) || deadlines.expired[20] {
+		t.Fatalf("%d expired requests of %d", len(deadlines.expired), len(deadlines.expiredOrder))
+	}
+}
+
+func TestDeadlineRecycle(t *testing.T) {
+	stuck := make(chan struct{})
+	release := make(chan struct{})
+	c := deadlineTestChannelCreate(map[string]string{
+		MRCP_ENGINE_PARAM_RESPONSE_TIMEOUT:         "10",
+		MRCP_ENGINE_PARAM_RECYCLE_SUSPECT_CHANNELS: "true",
+	}, func(channel *MRCPEngineChannel, request *message.MRCPMessage) error {
+		/* the engine call is stuck past the deadline and fails at last */
+		close(stuck)
+		<-release
+		return fmt.Errorf("engine is stuck")
+	})
+	if err := MRCPEngineChannelVirtualOpen(c.MRCPEngineChannel); err != nil {
+		t.Fatal(err)
+	}
+	processed := make(chan error, 1)
+	go func() {
+		processed <- MRCPEngineChannelRequestProcess(c.MRCPEngineChannel, deadlineTestRequestCreate(1))
+	}()
+	<-stuck
+
+	/* answered on behalf of the engine and recycled while the call is still stuck */
+	if response := c.messageWait(t); response.StartLine.StatusCode != message.MRCP_STATUS_CODE_METHOD_FAILED {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		c.mutex.Lock()
+		opened := c.opened
+		c.mutex.Unlock()
+		if opened == 2 {
+			break
+		}
+	}
+	c.processMutex.Lock()
+	c.mutex.Lock()
+	if c.opened != 2 || c.closed != 1 || !c.IsOpen || c.MRCPEngineChannelSuspectGet() {
+		t.Errorf("opened %d, closed %d, open %t, suspect %t", c.opened, c.closed, c.IsOpen, c.MRCPEngineChannelSuspectGet())
+	}
+	c.mutex.Unlock()
+	c.processMutex.Unlock()
+	select {
+	case <-processed:
+		t.Fatal("request processing is not stuck")
+	default:
+	}
+
+	/* the failure of the call answered already is not reported again */
+	close(release)
+	select {
+	case err := <-processed:
+		if err != nil {
+			t.Fatalf("failure of the request answered on behalf of the engine is reported: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request processing is not returned")
+	}
+	if len(c.messages) != 0 {
+		t.Fatalf("%d messages delivered after the expiry", len(c.messages))
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"container/list"
 	"fmt"
 
+	"github.com/navi-tt/go-mrcp/mpf"
 	"github.com/navi-tt/go-mrcp/mrcp"
 )
 
@@ -48,7 +49,15 @@ func MRCPEngineFactoryDestroy(factory *MRCPEngineFactory) error {
 
 /** Open registered engines */
 func (f *MRCPEngineFactory) MRCPEngineFactoryOpen() error {
-	return nil
+	var firstErr error
+	e := f.MRCPEngineFactoryEngineFirst()
+	for ; e != nil; e = e.Next() {
+		engine := e.Value.(*MRCPEngine)
+		if err := MRCPEngineVirtualOpen(engine); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("failed to open engine %s: %v", engine.Id, err)
+		}
+	}
+	return firstErr
 }
 
 /** Close registered engines */
@@ -83,6 +92,13 @@ func (f *MRCPEngineFactory) MRCPEngineFactoryRegister(engine *MRCPEngine) error
 	if engine.CreateStateMachine == nil {
 		return fmt.Errorf("invalid engine, CreateStateMachine is nil")
 	}
+	if engine.CodecManager == nil {
+		engine.CodecManager = mpf.CodecManagerDefaultGet()
+	}
+	/* validate advertised codecs */
+	if _, err := engine.mrcpEngineCodecsGet(mpf.STREAM_DIRECTION_DUPLEX); err != nil {
+		return fmt.Errorf("invalid engine capabilities: %v", err)
+	}
 
 	e := f.enginesList.PushBack(engine)
 	f.enginesMap[engine.Id] = e
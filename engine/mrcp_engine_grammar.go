@@ -0,0 +1,130 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+/** Prefix of the well-known URIs preloaded grammars are exposed under */
+const MRCP_ENGINE_GRAMMAR_URI_PREFIX = "builtin:grammar/"
+
+/** Default content type of preloaded grammars */
+const MRCP_ENGINE_GRAMMAR_CONTENT_TYPE = "application/srgs+xml"
+
+/** Config of the grammar to preload */
+type MRCPEngineGrammarConfig struct {
+	Name        string // Name of the grammar (or complete URI if it has a scheme)
+	ContentType string // Content type of the grammar
+	Path        string // Path to the file to load the grammar from
+	Content     string // Inline content of the grammar, used if no path is specified
+}
+
+/** Grammar preloaded (and compiled) by the engine */
+type MRCPEngineGrammar struct {
+	Uri         string      // Well-known URI the grammar is referenced by
+	ContentType string      // Content type of the grammar
+	Content     string      // Content of the grammar
+	Obj         interface{} // Compiled grammar set by the engine
+}
+
+/**
+ * Get the well-known URI of the grammar.
+ * @param name the name of the grammar
+ */
+func MRCPEngineGrammarUriGet(name string) string {
+	if strings.Contains(name, ":") {
+		return name
+	}
+	return MRCP_ENGINE_GRAMMAR_URI_PREFIX + name
+}
+
+/** Load grammar content by its config */
+func mrcpEngineGrammarLoad(config *MRCPEngineGrammarConfig) (*MRCPEngineGrammar, error) {
+	if len(config.Name) == 0 {
+		return nil, fmt.Errorf("grammar name is empty")
+	}
+	grammar := &MRCPEngineGrammar{
+		Uri:         MRCPEngineGrammarUriGet(config.Name),
+		ContentType: config.ContentType,
+		Content:     config.Content,
+	}
+	if len(grammar.ContentType) == 0 {
+		grammar.ContentType = MRCP_ENGINE_GRAMMAR_CONTENT_TYPE
+	}
+	if len(config.Path) > 0 {
+		content, err := ioutil.ReadFile(config.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load grammar %s: %v", grammar.Uri, err)
+		}
+		grammar.Content = string(content)
+	}
+	if len(grammar.Content) == 0 {
+		return nil, fmt.Errorf("grammar %s is empty", grammar.Uri)
+	}
+	return grammar, nil
+}
+
+/**
+ * Register (compile) grammar to be available to all the channels of the engine.
+ * @param grammar the grammar to register
+ */
+func (engine *MRCPEngine) MRCPEngineGrammarRegister(grammar *MRCPEngineGrammar) error {
+	if grammar == nil || len(grammar.Uri) == 0 {
+		return fmt.Errorf("invalid grammar")
+	}
+	if engine.MethodVTable != nil && engine.MethodVTable.CompileGrammar != nil {
+		if err := engine.MethodVTable.CompileGrammar(engine, grammar); err != nil {
+			return fmt.Errorf("failed to compile grammar %s: %v", grammar.Uri, err)
+		}
+	}
+
+	engine.grammarsMutex.Lock()
+	defer engine.grammarsMutex.Unlock()
+	if engine.grammars == nil {
+		engine.grammars = make(map[string]*MRCPEngineGrammar)
+	}
+	engine.grammars[grammar.Uri] = grammar
+	return nil
+}
+
+/**
+ * Preload and compile grammars specified in the engine config.
+ * Invoked on engine open, so that the first request referencing the grammar is not penalized by compilation.
+ */
+func (engine *MRCPEngine) MRCPEngineGrammarsPreload() error {
+	if engine.Config == nil {
+		return nil
+	}
+	for i := range engine.Config.Grammars {
+		grammar, err := mrcpEngineGrammarLoad(&engine.Config.Grammars[i])
+		if err != nil {
+			return err
+		}
+		if err := engine.MRCPEngineGrammarRegister(grammar); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Get preloaded grammar by URI.
+ * @param uri the well-known URI of the grammar
+ */
+func (engine *MRCPEngine) MRCPEngineGrammarGet(uri string) *MRCPEngineGrammar {
+	engine.grammarsMutex.RLock()
+	defer engine.grammarsMutex.RUnlock()
+	return engine.grammars[uri]
+}
+
+/**
+ * Get grammar preloaded by the engine the channel belongs to.
+ * @param uri the well-known URI of the grammar
+ */
+func (channel *MRCPEngineChannel) MRCPEngineChannelGrammarGet(uri string) *MRCPEngineGrammar {
+	if channel.engine == nil {
+		return nil
+	}
+	return channel.engine.MRCPEngineGrammarGet(uri)
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const grammarTestContent = `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="yesno"><rule id="yesno"><one-of><item>yes</item><item>no</item></one-of></rule></grammar>`
+
+func TestEngineGrammarsPreload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "grammar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "digits.grxml")
+	if err := ioutil.WriteFile(path, []byte("<grammar root=\"digits\"/>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var compiled []string
+	opened := 0
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{
+		Open: func(engine *MRCPEngine) error {
+			opened++
+			return nil
+		},
+		CompileGrammar: func(engine *MRCPEngine, grammar *MRCPEngineGrammar) error {
+			compiled = append(compiled, grammar.Uri)
+			grammar.Obj = strings.ToUpper(grammar.Content)
+			return nil
+		},
+	})
+	engine.Config = &MRCPEngineConfig{Grammars: []MRCPEngineGrammarConfig{
+		{Name: "yesno", Content: grammarTestContent},
+		{Name: "digits", ContentType: "application/grammar+xml", Path: path},
+		{Name: "http://example.com/grammar.grxml", Content: grammarTestContent},
+	}}
+
+	/* the grammars are preloaded and compiled once, as the engine is open */
+	for i := 0; i < 2; i++ {
+		if err := MRCPEngineVirtualOpen(engine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !engine.IsOpen || opened != 1 || len(compiled) != 3 {
+		t.Fatalf("open %t, opened %d, compiled %v", engine.IsOpen, opened, compiled)
+	}
+
+	grammar := engine.MRCPEngineGrammarGet("builtin:grammar/yesno")
+	if grammar == nil || grammar.ContentType != MRCP_ENGINE_GRAMMAR_CONTENT_TYPE || grammar.Obj != strings.ToUpper(grammarTestContent) {
+		t.Fatalf("grammar %+v", grammar)
+	}
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{}, nil, nil)
+	if grammar := channel.MRCPEngineChannelGrammarGet("builtin:grammar/digits"); grammar == nil ||
+		grammar.ContentType != "application/grammar+xml" || grammar.Content != "<grammar root=\"digits\"/>" {
+		t.Fatalf("grammar of file %+v", grammar)
+	}
+	if engine.MRCPEngineGrammarGet("http://example.com/grammar.grxml") == nil || engine.MRCPEngineGrammarGet("builtin:grammar/unknown") != nil {
+		t.Fatal("grammar got by unexpected URI")
+	}
+
+	/* reopen after close */
+	if err := MRCPEngineVirtualClose(engine); err != nil || engine.IsOpen {
+		t.Fatalf("open %t: %v", engine.IsOpen, err)
+	}
+	if err := MRCPEngineVirtualOpen(engine); err != nil || opened != 2 {
+		t.Fatalf("opened %d: %v", opened, err)
+	}
+}
+
+func TestEngineGrammarsPreloadFailure(t *testing.T) {
+	for _, grammars := range [][]MRCPEngineGrammarConfig{
+		{{Content: grammarTestContent}},
+		{{Name: "empty"}},
+		{{Name: "missing", Path: filepath.Join(os.TempDir(), "missing.grxml")}},
+		{{Name: "invalid", Content: "<grammar"}},
+	} {
+		opened := false
+		engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{
+			Open: func(engine *MRCPEngine) error {
+				opened = true
+				return nil
+			},
+			CompileGrammar: func(engine *MRCPEngine, grammar *MRCPEngineGrammar) error {
+				if !strings.HasSuffix(grammar.Content, ">") {
+					return fmt.Errorf("unexpected end of grammar")
+				}
+				return nil
+			},
+		})
+		engine.Config = &MRCPEngineConfig{Grammars: grammars}
+		if err := MRCPEngineVirtualOpen(engine); err == nil || engine.IsOpen || opened {
+			t.Fatalf("engine of grammar %+v is open", grammars[0])
+		}
+		if engine.MRCPEngineGrammarGet(MRCPEngineGrammarUriGet(grammars[0].Name)) != nil {
+			t.Fatalf("grammar %+v is registered", grammars[0])
+		}
+	}
+}
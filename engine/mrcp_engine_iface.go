@@ -3,6 +3,7 @@ package engine
 import (
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
 )
 
 /** Destroy engine */
@@ -12,6 +13,18 @@ func MRCPEngineVirtualDestroy(e *MRCPEngine) error {
 
 /** Open engine */
 func MRCPEngineVirtualOpen(e *MRCPEngine) error {
+	if e.IsOpen {
+		return nil
+	}
+	if err := e.MRCPEngineGrammarsPreload(); err != nil {
+		return err
+	}
+	if e.MethodVTable != nil && e.MethodVTable.Open != nil {
+		if err := e.MethodVTable.Open(e); err != nil {
+			return err
+		}
+	}
+	e.IsOpen = true
 	return nil
 }
 
@@ -22,6 +35,13 @@ func MRCPEngineOnOpen(e *MRCPEngine, status bool) {
 
 /** Close engine */
 func MRCPEngineVirtualClose(e *MRCPEngine) error {
+	if !e.IsOpen {
+		return nil
+	}
+	e.IsOpen = false
+	if e.MethodVTable != nil && e.MethodVTable.Close != nil {
+		return e.MethodVTable.Close(e)
+	}
 	return nil
 }
 
@@ -37,6 +57,13 @@ func MRCPEngineChannelVirtualDestroy(channel *MRCPEngineChannel) error {
 
 /** Open engine channel */
 func MRCPEngineChannelVirtualOpen(channel *MRCPEngineChannel) error {
+	channel.processMutex.Lock()
+	defer channel.processMutex.Unlock()
+	return channel.open()
+}
+
+/* Open engine channel, called under the process mutex */
+func (channel *MRCPEngineChannel) open() error {
 	if !channel.IsOpen {
 		err := channel.MethodVTable.Open(channel)
 		if err != nil {
@@ -50,19 +77,42 @@ func MRCPEngineChannelVirtualOpen(channel *MRCPEngineChannel) error {
 
 /** Close engine channel */
 func MRCPEngineChannelVirtualClose(channel *MRCPEngineChannel) error {
+	channel.processMutex.Lock()
+	defer channel.processMutex.Unlock()
+	return channel.close()
+}
+
+/* Close engine channel, called under the process mutex */
+func (channel *MRCPEngineChannel) close() error {
 	if channel.IsOpen {
 		err := channel.MethodVTable.Close(channel)
 		if err != nil {
 			return err
 		}
 		channel.IsOpen = false
+		channel.deadlinesReset()
 	}
 	return nil
 }
 
-/** Process request */
+/**
+ * Process request. The request is recorded and its deadline is started serialized with open/close of the channel,
+ * the engine is called out of the lock, so that the engine call which is stuck past the deadline is answered
+ * on behalf of the engine and the channel is recycled in the meantime. The error of the call is not returned
+ * if the request is already answered on behalf of the engine.
+ */
 func MRCPEngineChannelRequestProcess(channel *MRCPEngineChannel, message *message.MRCPMessage) error {
-	return channel.MethodVTable.ProcessRequest(channel, message)
+	channel.processMutex.Lock()
+	channel.Audit.MRCPAuditMessageRecord(session.MRCP_AUDIT_DIRECTION_INBOUND, message)
+	channel.deadlineStart(message)
+	channel.processMutex.Unlock()
+	if err := channel.MethodVTable.ProcessRequest(channel, message); err != nil {
+		if channel.deadlineStop(message) {
+			return nil
+		}
+		return err
+	}
+	return nil
 }
 
 /** Allocate engine config */
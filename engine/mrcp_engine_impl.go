@@ -4,11 +4,16 @@ import (
 	"github.com/navi-tt/go-mrcp/mpf"
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
 )
 
 /** Create engine */
 func MRCPEngineCreate(rid mrcp.MRCPResourceId, obj interface{}, vtable *MRCPEngineMethodVTable) *MRCPEngine {
-	return &MRCPEngine{}
+	return &MRCPEngine{
+		ResourceId:   rid,
+		obj:          obj,
+		MethodVTable: vtable,
+	}
 }
 
 /** Send engine open response */
@@ -28,12 +33,20 @@ func (engine *MRCPEngine) MRCPEngineConfigGet() *MRCPEngineConfig {
 
 /** Get engine param by name */
 func (engine *MRCPEngine) MRCPEngineParamGet(name string) string {
-	return ""
+	if engine.Config == nil || engine.Config.Params == nil {
+		return ""
+	}
+	return engine.Config.Params[name]
 }
 
 /** Create engine channel */
-func (engine *MRCPEngine) MRCPEngineChannelCreate(methodVTable *MRCPEngineMethodVTable, methodObj interface{}, termination *mpf.Termination) *MRCPEngineChannel {
-	return nil
+func (engine *MRCPEngine) MRCPEngineChannelCreate(methodVTable *MRCPEngineChannelMethodVTable, methodObj interface{}, termination *mpf.Termination) *MRCPEngineChannel {
+	return &MRCPEngineChannel{
+		MethodVTable: methodVTable,
+		MethodObj:    methodObj,
+		Termination:  termination,
+		engine:       engine,
+	}
 }
 
 /** Create audio termination */
@@ -69,7 +82,17 @@ func (channel *MRCPEngineChannel) MRCPEngineChannelCloseRespond() error {
 
 /** Send response/event message */
 func (channel *MRCPEngineChannel) MRCPEngineChannelMessageSend(message *message.MRCPMessage) error {
-	return channel.EventVTable.OnMessage(channel, message)
+	if !channel.deadlineCheck(message) {
+		/* the request is already answered on behalf of the engine */
+		return nil
+	}
+	return channel.messageDeliver(message)
+}
+
+/* Deliver response/event message to the event handler, the message is recorded in the audit trail (if any) */
+func (channel *MRCPEngineChannel) messageDeliver(msg *message.MRCPMessage) error {
+	channel.Audit.MRCPAuditMessageRecord(session.MRCP_AUDIT_DIRECTION_OUTBOUND, msg)
+	return channel.EventVTable.OnMessage(channel, msg)
 }
 
 /** Get channel identifier */
@@ -84,10 +107,16 @@ func (channel *MRCPEngineChannel) MRCPEngineChannelVersionGet() mrcp.Version {
 
 /** Get codec descriptor of the audio source stream */
 func (channel *MRCPEngineChannel) MRCPEngineSourceStreamCodecGet() *mpf.CodecDescriptor {
-	return nil
+	if channel.Termination == nil || channel.Termination.TerminationAudioStreamGet() == nil {
+		return nil
+	}
+	return channel.Termination.TerminationAudioStreamGet().RXDescriptor
 }
 
 /** Get codec descriptor of the audio sink stream */
 func (channel *MRCPEngineChannel) MRCPEngineSinkStreamCodecGet() *mpf.CodecDescriptor {
-	return nil
+	if channel.Termination == nil || channel.Termination.TerminationAudioStreamGet() == nil {
+		return nil
+	}
+	return channel.Termination.TerminationAudioStreamGet().TXDescriptor
 }
@@ -0,0 +1,50 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
+)
+
+func TestEngineChannelAudit(t *testing.T) {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	var channel *MRCPEngineChannel
+	channel = engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		ProcessRequest: func(channel *MRCPEngineChannel, request *message.MRCPMessage) error {
+			response := message.MRCPResponseCreate(request)
+			return channel.MRCPEngineChannelMessageSend(response)
+		},
+	}, nil, nil)
+	var sent []*message.MRCPMessage
+	channel.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *message.MRCPMessage) error {
+			sent = append(sent, msg)
+			return nil
+		},
+	}
+
+	/* no audit trail is optional */
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, Version: mrcp.MRCP_VERSION_2, MethodName: "SPEAK", RequestId: 1}
+	if err := MRCPEngineChannelRequestProcess(channel, request); err != nil || len(sent) != 1 {
+		t.Fatalf("%d messages sent: %v", len(sent), err)
+	}
+
+	channel.Audit = session.MRCPSessionAuditTrailCreate("engine", 0)
+	request.StartLine.RequestId = 2
+	if err := MRCPEngineChannelRequestProcess(channel, request); err != nil || len(sent) != 2 {
+		t.Fatalf("%d messages sent: %v", len(sent), err)
+	}
+	records := channel.Audit.MRCPAuditRecordsGet()
+	if len(records) != 2 {
+		t.Fatalf("records %+v", records)
+	}
+	if r := records[0]; r.Direction != session.MRCP_AUDIT_DIRECTION_INBOUND || r.MessageType != "request" || r.RequestId != 2 {
+		t.Fatalf("record of request %+v", r)
+	}
+	if r := records[1]; r.Direction != session.MRCP_AUDIT_DIRECTION_OUTBOUND || r.MessageType != "response" || r.StatusCode != int(message.MRCP_STATUS_CODE_SUCCESS) {
+		t.Fatalf("record of response %+v", r)
+	}
+}
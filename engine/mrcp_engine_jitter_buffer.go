@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/**
+ * Prefix of vendor specific params tuning the jitter buffer of the session RTP termination,
+ * e.g. "Vendor-Specific-Parameters: jitter-buffer.playout-delay=60;jitter-buffer.adaptive=false".
+ * The rest of the name is one of the jitter buffer params (playout-delay, min-playout-delay,
+ * max-playout-delay, adaptive, time-skew-detection).
+ */
+const MRCP_VENDOR_PARAM_JITTER_BUFFER_PREFIX = "jitter-buffer."
+
+/** Name of the method jitter buffer params are accepted in */
+const MRCP_SET_PARAMS_METHOD_NAME = "SET-PARAMS"
+
+/**
+ * Apply jitter buffer params specified by SET-PARAMS request to the RTP termination of the session live.
+ * @param request the SET-PARAMS request
+ * @param termination the RTP termination of the session
+ * @return true if the jitter buffer is tuned
+ */
+func MRCPJitterBufferParamsApply(request *message.MRCPMessage, termination *mpf.Termination) (bool, error) {
+	if request == nil || request.StartLine == nil || !strings.EqualFold(request.StartLine.MethodName, MRCP_SET_PARAMS_METHOD_NAME) {
+		return false, nil
+	}
+	genericHeader, ok := request.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	if !ok || genericHeader == nil {
+		return false, nil
+	}
+
+	var params []*toolkit.AptPair
+	for i := 0; i < toolkit.AptPairArraySize(genericHeader.VendorSpecificParams); i++ {
+		pair := toolkit.AptPairArrayGet(genericHeader.VendorSpecificParams, i)
+		if pair != nil && strings.HasPrefix(strings.ToLower(pair.Name), MRCP_VENDOR_PARAM_JITTER_BUFFER_PREFIX) {
+			params = append(params, pair)
+		}
+	}
+	if len(params) == 0 {
+		return false, nil
+	}
+
+	if termination == nil || termination.TerminationAudioStreamGet() == nil {
+		return false, fmt.Errorf("no RTP termination to tune jitter buffer of")
+	}
+	jbConfig := mpf.RtpStreamJbConfigGet(termination.TerminationAudioStreamGet())
+	if jbConfig == nil {
+		return false, fmt.Errorf("termination %s is not RTP one", mpf.TerminationNameGet(termination))
+	}
+	for _, pair := range params {
+		if err := jbConfig.JbConfigParamSet(pair.Name[len(MRCP_VENDOR_PARAM_JITTER_BUFFER_PREFIX):], pair.Value); err != nil {
+			return false, err
+		}
+	}
+	if err := jbConfig.JbConfigValidate(); err != nil {
+		return false, err
+	}
+
+	descriptor := mpf.RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorJbConfigSet(jbConfig)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -1,9 +1,13 @@
 package engine
 
 import (
+	"sync"
+
 	"github.com/navi-tt/go-mrcp/mpf"
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
 	"github.com/navi-tt/go-mrcp/toolkit"
 )
 
@@ -17,6 +21,8 @@ type MRCPEngineChannelMethodVTable struct {
 	Close func(channel *MRCPEngineChannel) error
 	/** Virtual process_request */
 	ProcessRequest func(channel *MRCPEngineChannel, request *message.MRCPMessage) error
+	/** [OPTIONAL] Virtual codec update, invoked when the codec (wire format) is negotiated with the peer */
+	UpdateCodec func(channel *MRCPEngineChannel, descriptor *mpf.CodecDescriptor) error
 }
 
 /** Table of channel virtual event handlers */
@@ -40,6 +46,10 @@ type MRCPEngineChannel struct {
 	Id           string                         // Unique identifier to be used in traces
 	Version      mrcp.Version                   // MRCP version
 	IsOpen       bool                           // Is channel successfully opened
+	deadlines    mrcpEngineChannelDeadlines     // Response deadlines of requests in progress
+	processMutex sync.Mutex                     // Serializes open/close with recording and starting deadline of requests
+	peerCodec    *mpf.CodecDescriptor           // Codec negotiated with the peer (wire format)
+	Audit        *session.MRCPSessionAuditTrail // Audit trail of the session the channel belongs to [OPTIONAL]
 	//pool         *memory.AprPool                // Pool to allocate memory from
 }
 
@@ -56,6 +66,9 @@ type MRCPEngineMethodVTable struct {
 
 	/** Virtual channel create */
 	CreateChannel func(engine *MRCPEngine) MRCPEngineChannel
+
+	/** [OPTIONAL] Virtual grammar compile, invoked for grammars preloaded by the engine */
+	CompileGrammar func(engine *MRCPEngine, grammar *MRCPEngineGrammar) error
 }
 
 /** Table of MRCP engine virtual event handlers */
@@ -76,20 +89,28 @@ type MRCPEngine struct {
 	EventVTable  *MRCPEngineEventVTable  // Table of virtual event handlers
 	eventObj     interface{}             // External object used with event handlers
 
-	CodecManager    *mpf.CodecManager     // Codec manager
-	DirLayout       *toolkit.AptDirLayout // Dir layout structure
-	Config          *MRCPEngineConfig     // Config of engine
-	CurChannelCount int64                 // Number of simultaneous channels currently in use
-	IsOpen          bool                  // Is engine successfully opened
+	CodecManager    *mpf.CodecManager       // Codec manager
+	Capabilities    *MRCPEngineCapabilities // Codecs and sampling rates handled natively
+	DirLayout       *toolkit.AptDirLayout   // Dir layout structure
+	Config          *MRCPEngineConfig       // Config of engine
+	CurChannelCount int64                   // Number of simultaneous channels currently in use
+	IsOpen          bool                    // Is engine successfully opened
 	//pool            *memory.AprPool       // Pool to allocate memory from
 
+	grammars      map[string]*MRCPEngineGrammar // Grammars preloaded by the engine (key: well-known URI)
+	grammarsMutex sync.RWMutex
+
+	storage      storage.MRCPStorage // Storage of waveforms and recordings (created by config on demand)
+	storageMutex sync.Mutex
+
 	/** Create state machine */
 	CreateStateMachine func(obj interface{}, version mrcp.Version) *MRCPStateMachine
 }
 
 /** MRCP engine config */
 type MRCPEngineConfig struct {
-	MaxChannelCount int64         // Max number of simultaneous channels
+	MaxChannelCount int64 // Max number of simultaneous channels
 	//Params          *apr.AprTable // Table of name/value string params todo(map[string]string ???)
-	Params        map[string]string // Table of name/value string params
+	Params   map[string]string         // Table of name/value string params
+	Grammars []MRCPEngineGrammarConfig // Grammars to preload on engine open
 }
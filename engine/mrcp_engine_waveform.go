@@ -0,0 +1,125 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
+)
+
+/** Engine param specifying normalization of captured waveforms (none, peak or loudness) */
+const MRCP_ENGINE_PARAM_WAVEFORM_NORMALIZATION = "waveform-normalization"
+
+/** Engine param specifying target level of normalization (dBFS for peak, LUFS for loudness) */
+const MRCP_ENGINE_PARAM_WAVEFORM_TARGET_LEVEL = "waveform-target-level"
+
+/**
+ * Get engine param by name for the tenant.
+ * @param tenant the tenant name, the param named "<tenant>.<name>" overrides the default one
+ * @param name the param name
+ */
+func (engine *MRCPEngine) MRCPEngineTenantParamGet(tenant, name string) string {
+	if len(tenant) > 0 {
+		if value := engine.MRCPEngineParamGet(tenant + "." + name); len(value) > 0 {
+			return value
+		}
+	}
+	return engine.MRCPEngineParamGet(name)
+}
+
+/**
+ * Normalize captured waveform according to the config of the tenant
+ * before it is written to the file referenced by Waveform-URI/Record-URI.
+ * @param tenant the tenant name
+ * @param descriptor the codec descriptor of the (linear) waveform
+ * @param data the waveform, normalized in place
+ */
+func (engine *MRCPEngine) MRCPEngineWaveformNormalize(tenant string, descriptor *mpf.CodecDescriptor, data []byte) error {
+	var (
+		mode   mpf.NormalizationMode
+		target float64
+	)
+	switch strings.ToLower(engine.MRCPEngineTenantParamGet(tenant, MRCP_ENGINE_PARAM_WAVEFORM_NORMALIZATION)) {
+	case "", "none":
+		return nil
+	case "peak":
+		mode = mpf.MPF_NORMALIZATION_PEAK
+		target = mpf.MPF_NORMALIZATION_PEAK_TARGET
+	case "loudness":
+		mode = mpf.MPF_NORMALIZATION_LOUDNESS
+		target = mpf.MPF_NORMALIZATION_LOUDNESS_TARGET
+	default:
+		return fmt.Errorf("unknown waveform normalization %s", engine.MRCPEngineTenantParamGet(tenant, MRCP_ENGINE_PARAM_WAVEFORM_NORMALIZATION))
+	}
+
+	if level := engine.MRCPEngineTenantParamGet(tenant, MRCP_ENGINE_PARAM_WAVEFORM_TARGET_LEVEL); len(level) > 0 {
+		value, err := strconv.ParseFloat(level, 64)
+		if err != nil {
+			return fmt.Errorf("invalid waveform target level %s", level)
+		}
+		target = value
+	}
+	return mpf.AudioNormalize(data, descriptor, mode, target)
+}
+
+/**
+ * Get storage of waveforms and recordings, created by the engine params (see storage.MRCP_STORAGE_PARAM_*)
+ * on the first use. Local storage under os.TempDir() is used if no storage is configured.
+ */
+func (engine *MRCPEngine) MRCPEngineStorageGet() (storage.MRCPStorage, error) {
+	engine.storageMutex.Lock()
+	defer engine.storageMutex.Unlock()
+	if engine.storage == nil {
+		var params map[string]string
+		if engine.Config != nil {
+			params = engine.Config.Params
+		}
+		store, err := storage.MRCPStorageCreate(params, os.TempDir())
+		if err != nil {
+			return nil, err
+		}
+		engine.storage = store
+	}
+	return engine.storage, nil
+}
+
+/** Set storage of waveforms and recordings, overriding the configured one */
+func (engine *MRCPEngine) MRCPEngineStorageSet(store storage.MRCPStorage) {
+	engine.storageMutex.Lock()
+	defer engine.storageMutex.Unlock()
+	engine.storage = store
+}
+
+/**
+ * Normalize captured waveform and put it to the engine storage.
+ * @param tenant the tenant name
+ * @param key the storage key, e.g. "waveforms/<session id>/<channel id>.pcm"
+ * @param descriptor the codec descriptor of the (linear) waveform
+ * @param data the host order waveform, normalized in place and stored in network order
+ * @return the Waveform-URI of the stored waveform
+ */
+func (engine *MRCPEngine) MRCPEngineWaveformStore(tenant, key string, descriptor *mpf.CodecDescriptor, data []byte) (string, error) {
+	if err := engine.MRCPEngineWaveformNormalize(tenant, descriptor, data); err != nil {
+		return "", err
+	}
+	store, err := engine.MRCPEngineStorageGet()
+	if err != nil {
+		return "", err
+	}
+	/* audio/L16 is of network byte order */
+	wire, err := mpf.L16NetworkOrderConvert(data)
+	if err != nil {
+		return "", err
+	}
+	metadata := &storage.MRCPStorageMetadata{
+		ContentType: fmt.Sprintf("audio/L16;rate=%d;channels=%d", descriptor.SamplingRate, descriptor.ChannelCount),
+	}
+	if len(tenant) > 0 {
+		metadata.Attributes = map[string]string{"tenant": tenant}
+	}
+	return store.Put(key, bytes.NewReader(wire), metadata)
+}
@@ -0,0 +1,43 @@
+package engine
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+)
+
+func TestEngineWaveformStore(t *testing.T) {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	store := segmenterTestStorageCreate()
+	engine.MRCPEngineStorageSet(store)
+
+	var sample int16 = -1000
+	data := make([]byte, 320)
+	for i := 0; i < len(data); i += 2 {
+		binary.LittleEndian.PutUint16(data[i:], uint16(sample))
+	}
+	uri, err := engine.MRCPEngineWaveformStore("acme", "waveforms/session-1.pcm", mpf.CodecLPcmDescriptorCreate(16000, 1), data)
+	if err != nil || uri != "mem://waveforms/session-1.pcm" {
+		t.Fatalf("uri %s: %v", uri, err)
+	}
+	metadata := store.metadata["waveforms/session-1.pcm"]
+	if metadata.ContentType != "audio/L16;rate=16000;channels=1" || metadata.Attributes["tenant"] != "acme" {
+		t.Fatalf("metadata %+v", metadata)
+	}
+
+	/* samples are put in network byte order, as audio/L16 is, the waveform passed is kept in host order */
+	stored := store.objects["waveforms/session-1.pcm"]
+	if len(stored) != len(data) {
+		t.Fatalf("%d bytes put", len(stored))
+	}
+	for i := 0; i < len(stored); i += 2 {
+		if int16(binary.BigEndian.Uint16(stored[i:])) != sample || int16(binary.LittleEndian.Uint16(data[i:])) != sample {
+			t.Fatalf("sample %d: %d", i/2, int16(binary.BigEndian.Uint16(stored[i:])))
+		}
+	}
+
+	if _, err := engine.MRCPEngineWaveformStore("", "odd.pcm", mpf.CodecLPcmDescriptorCreate(8000, 1), make([]byte, 3)); err == nil {
+		t.Fatal("partial sample is stored")
+	}
+}
@@ -0,0 +1,335 @@
+package engine
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/** Engine param (policy flag) enabling long-form dictation mode of recognizer channels */
+const MRCP_ENGINE_PARAM_DICTATION = "dictation"
+
+/** Engine param specifying interval in msec intermediate results are flushed at, 0 flushes each segment finalized */
+const MRCP_ENGINE_PARAM_DICTATION_FLUSH_INTERVAL = "dictation-flush-interval"
+
+/** Names of vendor specific params of dictation */
+const (
+	/** RECOGNIZE param enabling dictation mode per request, e.g. "Vendor-Specific-Parameters: dictation=true" */
+	MRCP_VENDOR_PARAM_DICTATION = "dictation"
+	/** Sequence number of intermediate result (1, 2, ...) */
+	MRCP_VENDOR_PARAM_DICTATION_SEQUENCE = "dictation-sequence"
+	/** Number of segments of intermediate result */
+	MRCP_VENDOR_PARAM_DICTATION_SEGMENTS = "dictation-segments"
+)
+
+/** Content type of NLSML results */
+const MRCP_NLSML_CONTENT_TYPE = "application/nlsml+xml"
+
+/**
+ * Vendor specific recognizer event carrying intermediate result of dictation, the request stays IN-PROGRESS.
+ * Its identifier follows the recognizer events of MRCPv2.
+ */
+const (
+	MRCP_DICTATION_RESULT_EVENT_NAME = "DICTATION-RESULT"
+	MRCP_DICTATION_RESULT_EVENT_ID   = resources.RECOGNIZER_EVENT_COUNT
+)
+
+/**
+ * Long-form dictation of recognizer channel.
+ * RECOGNIZE request in dictation mode stays IN-PROGRESS, while the recognizer (recognizing one utterance
+ * per RECOGNIZE) is re-armed as soon as it completes. Finalized segments are flushed to the client
+ * by vendor specific DICTATION-RESULT events of IN-PROGRESS request state, carrying vendor specific params
+ * dictation-sequence and dictation-segments. The request is completed by STOP, or by recognizer failure
+ * reported by RECOGNITION-COMPLETE event.
+ */
+type MRCPRecogDictation struct {
+	channel    *MRCPEngineChannel // Channel facing the client
+	recognizer *MRCPEngineChannel // Channel of the recognizer
+	interval   time.Duration      // Interval intermediate results are flushed at
+
+	mutex        sync.Mutex
+	request      *message.MRCPMessage // In-progress RECOGNIZE request in dictation mode
+	segments     []string             // Interpretations finalized, not flushed yet
+	sequence     int                  // Number of intermediate results flushed
+	responded    bool                 // Is response to the request sent
+	inputStarted bool                 // Is START-OF-INPUT event sent
+	stopping     bool                 // Is STOP in progress, the recognizer is not re-armed
+	timer        *time.Timer          // Flush timer
+
+	rearmMutex sync.Mutex // Serializes re-arming of the recognizer with STOP
+}
+
+/**
+ * Create recognizer dictation.
+ * @param channel the channel facing the client
+ * @param recognizer the channel of the recognizer, opened virtually, its messages are processed by the dictation
+ */
+func MRCPRecogDictationCreate(channel, recognizer *MRCPEngineChannel) (*MRCPRecogDictation, error) {
+	d := &MRCPRecogDictation{channel: channel, recognizer: recognizer}
+	if channel.engine != nil {
+		if interval, err := strconv.ParseInt(channel.engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_DICTATION_FLUSH_INTERVAL), 10, 64); err == nil && interval > 0 {
+			d.interval = time.Duration(interval) * time.Millisecond
+		}
+	}
+	recognizer.EventVTable = &MRCPEngineChannelEventVTable{
+		OnOpen: func(channel *MRCPEngineChannel, status bool) error {
+			return nil
+		},
+		OnClose: func(channel *MRCPEngineChannel) error {
+			return nil
+		},
+		OnMessage: func(channel *MRCPEngineChannel, message *message.MRCPMessage) error {
+			return d.recognizerMessage(message)
+		},
+	}
+	recognizer.EventObj = d
+	if err := MRCPEngineChannelVirtualOpen(recognizer); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+/* Check whether RECOGNIZE request is to be processed in dictation mode */
+func (d *MRCPRecogDictation) enabled(request *message.MRCPMessage) bool {
+	if genericHeader := request.MRCPGenericHeaderGet(); genericHeader != nil {
+		if pair := toolkit.AptPairArrayFind(genericHeader.VendorSpecificParams, MRCP_VENDOR_PARAM_DICTATION); pair != nil {
+			enabled, _ := strconv.ParseBool(pair.Value)
+			return enabled
+		}
+	}
+	if d.channel.engine == nil {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(d.channel.engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_DICTATION))
+	return enabled
+}
+
+/**
+ * Process request received from the client.
+ * @param request the request to process
+ */
+func (d *MRCPRecogDictation) MRCPRecogDictationRequestProcess(request *message.MRCPMessage) error {
+	if request.StartLine != nil {
+		switch request.StartLine.MethodId {
+		case int64(resources.RECOGNIZER_RECOGNIZE):
+			d.mutex.Lock()
+			if d.enabled(request) {
+				d.request = request
+				d.segments = nil
+				d.sequence = 0
+				d.responded = false
+				d.inputStarted = false
+				d.stopping = false
+				d.timerStart()
+			} else {
+				d.request = nil
+			}
+			d.mutex.Unlock()
+		case int64(resources.RECOGNIZER_STOP):
+			/* the recognizer being re-armed is stopped after */
+			d.rearmMutex.Lock()
+			defer d.rearmMutex.Unlock()
+			d.mutex.Lock()
+			if d.request != nil {
+				d.stopping = true
+			}
+			d.mutex.Unlock()
+		}
+	}
+	return MRCPEngineChannelRequestProcess(d.recognizer, request)
+}
+
+/*
+ * Re-arm the recognizer by the request in dictation mode. Called apart from the handler of the recognizer
+ * messages, as the recognizer may complete from within its request processing. The recognizer is called
+ * directly, the request is neither recorded again nor given a new deadline.
+ */
+func (d *MRCPRecogDictation) rearm(request *message.MRCPMessage) {
+	d.rearmMutex.Lock()
+	defer d.rearmMutex.Unlock()
+	d.mutex.Lock()
+	if d.request != request || d.stopping {
+		d.mutex.Unlock()
+		return
+	}
+	d.mutex.Unlock()
+	if err := d.recognizer.MethodVTable.ProcessRequest(d.recognizer, request); err != nil {
+		d.mutex.Lock()
+		var event *message.MRCPMessage
+		if d.request == request {
+			/* failed to re-arm */
+			event = d.resultCreate(message.MRCP_REQUEST_STATE_COMPLETE, resources.RECOGNIZER_COMPLETION_CAUSE_ERROR)
+			d.complete()
+		}
+		d.mutex.Unlock()
+		if event != nil {
+			d.channel.MRCPEngineChannelMessageSend(event)
+		}
+	}
+}
+
+/* Start flush timer, must be called under the lock */
+func (d *MRCPRecogDictation) timerStart() {
+	if d.interval <= 0 {
+		return
+	}
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+	request := d.request
+	d.timer = time.AfterFunc(d.interval, func() {
+		d.mutex.Lock()
+		if d.request != request {
+			d.mutex.Unlock()
+			return
+		}
+		event := d.intermediateCreate()
+		d.timer.Reset(d.interval)
+		d.mutex.Unlock()
+		if event != nil {
+			d.channel.MRCPEngineChannelMessageSend(event)
+		}
+	})
+}
+
+/* Complete dictation, must be called under the lock */
+func (d *MRCPRecogDictation) complete() {
+	if d.timer != nil {
+		d.timer.Stop()
+		d.timer = nil
+	}
+	d.request = nil
+}
+
+/*
+ * Create event of the segments, must be called under the lock: RECOGNITION-COMPLETE of the cause
+ * completing the request, or DICTATION-RESULT of IN-PROGRESS request state.
+ */
+func (d *MRCPRecogDictation) resultCreate(state message.MRCPRequestState, cause resources.MRCPRecognizerCompletionCause) *message.MRCPMessage {
+	var event *message.MRCPMessage
+	if state == message.MRCP_REQUEST_STATE_COMPLETE {
+		event = message.MRCPEventCreate(d.request, int64(resources.RECOGNIZER_RECOGNITION_COMPLETE))
+		event.Header.ResourceHeaderAccessor.Data = &resources.MRCPRecognizerHeader{CompletionCause: cause}
+	} else {
+		event = message.MRCPEventCreate(d.request, int64(MRCP_DICTATION_RESULT_EVENT_ID))
+		event.StartLine.MethodName = MRCP_DICTATION_RESULT_EVENT_NAME
+	}
+	event.StartLine.RequestState = state
+	genericHeader := &header.MRCPGenericHeader{VendorSpecificParams: toolkit.AptPairArrayCreate(2)}
+	if len(d.segments) > 0 {
+		event.Body = nlsmlResultGenerate(d.segments)
+		genericHeader.ContentType = MRCP_NLSML_CONTENT_TYPE
+		genericHeader.ContentLength = int64(len(event.Body))
+	}
+	d.sequence++
+	toolkit.AptPairArrayAppend(genericHeader.VendorSpecificParams, MRCP_VENDOR_PARAM_DICTATION_SEQUENCE, strconv.Itoa(d.sequence))
+	toolkit.AptPairArrayAppend(genericHeader.VendorSpecificParams, MRCP_VENDOR_PARAM_DICTATION_SEGMENTS, strconv.Itoa(len(d.segments)))
+	event.Header.GenericHeaderAccessor.Data = genericHeader
+	d.segments = nil
+	return event
+}
+
+/* Create intermediate result of the segments finalized, nil if there are none, must be called under the lock */
+func (d *MRCPRecogDictation) intermediateCreate() *message.MRCPMessage {
+	if len(d.segments) == 0 {
+		return nil
+	}
+	return d.resultCreate(message.MRCP_REQUEST_STATE_INPROGRESS, resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS)
+}
+
+/* Process message sent by the recognizer */
+func (d *MRCPRecogDictation) recognizerMessage(msg *message.MRCPMessage) error {
+	d.mutex.Lock()
+	if d.request == nil || msg.StartLine == nil {
+		d.mutex.Unlock()
+		return d.channel.MRCPEngineChannelMessageSend(msg)
+	}
+	if msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE && d.stopping && msg.StartLine.RequestId != d.request.StartLine.RequestId {
+		/*
+		 * response to STOP, flushed segments are sent before; the request in dictation mode is stopped,
+		 * even if the recognizer is idle between re-arms and does not list it as stopped
+		 */
+		genericHeader := msg.MRCPGenericHeaderPrepare()
+		if genericHeader.ActiveRequestIdListFind(d.request.StartLine.RequestId) != nil &&
+			genericHeader.ActiveRequestIdListAppend(d.request.StartLine.RequestId) == nil {
+			msg.MRCPGenericHeaderPropertyAdd(int64(header.GENERIC_HEADER_ACTIVE_REQUEST_ID_LIST))
+		}
+		event := d.intermediateCreate()
+		d.complete()
+		d.mutex.Unlock()
+		if event != nil {
+			if err := d.channel.MRCPEngineChannelMessageSend(event); err != nil {
+				return err
+			}
+		}
+		return d.channel.MRCPEngineChannelMessageSend(msg)
+	}
+	if msg.StartLine.RequestId != d.request.StartLine.RequestId {
+		d.mutex.Unlock()
+		return d.channel.MRCPEngineChannelMessageSend(msg)
+	}
+
+	var (
+		forward []*message.MRCPMessage
+		rearm   bool
+	)
+	switch {
+	case msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE:
+		failed := msg.StartLine.StatusCode >= message.MRCP_STATUS_CODE_METHOD_NOT_ALLOWED ||
+			msg.StartLine.RequestState == message.MRCP_REQUEST_STATE_COMPLETE
+		if !d.responded {
+			d.responded = true
+			forward = append(forward, msg)
+			if failed {
+				d.complete()
+			}
+		} else if failed {
+			/* the recognizer failed to re-arm */
+			forward = append(forward, d.resultCreate(message.MRCP_REQUEST_STATE_COMPLETE, resources.RECOGNIZER_COMPLETION_CAUSE_ERROR))
+			d.complete()
+		}
+	case msg.StartLine.MethodId == int64(resources.RECOGNIZER_START_OF_INPUT):
+		if !d.inputStarted {
+			d.inputStarted = true
+			forward = append(forward, msg)
+		}
+	case msg.StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNITION_COMPLETE):
+		switch cause := mrcpRecogCompletionCauseGet(msg); cause {
+		case resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS:
+			interpretations, _ := nlsmlInterpretationsParse(msg.Body)
+			for i := range interpretations {
+				d.segments = append(d.segments, interpretations[i].generate())
+			}
+			if d.interval <= 0 {
+				if event := d.intermediateCreate(); event != nil {
+					forward = append(forward, event)
+				}
+			}
+			rearm = !d.stopping
+		case resources.RECOGNIZER_COMPLETION_CAUSE_NO_MATCH, resources.RECOGNIZER_COMPLETION_CAUSE_NO_INPUT_TIMEOUT,
+			resources.RECOGNIZER_COMPLETION_CAUSE_RECOGNITION_TIMEOUT, resources.RECOGNIZER_COMPLETION_CAUSE_PARTIAL_MATCH:
+			/* pauses and noise of long-form dictation */
+			rearm = !d.stopping
+		default:
+			forward = append(forward, d.resultCreate(message.MRCP_REQUEST_STATE_COMPLETE, cause))
+			d.complete()
+		}
+	}
+	request := d.request
+	d.mutex.Unlock()
+
+	for _, event := range forward {
+		if err := d.channel.MRCPEngineChannelMessageSend(event); err != nil {
+			return err
+		}
+	}
+	if rearm && request != nil {
+		go d.rearm(request)
+	}
+	return nil
+}
@@ -0,0 +1,196 @@
+package engine
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/* Recognizer completing each RECOGNIZE by the results queued, from within the request processing */
+type dictationTestRecognizer struct {
+	mutex      sync.Mutex
+	recognizes int
+	results    []*resources.MRCPRecognizerHeader
+	bodies     []string
+}
+
+func (r *dictationTestRecognizer) requestProcess(channel *MRCPEngineChannel, request *message.MRCPMessage) error {
+	response := message.MRCPResponseCreate(request)
+	if request.StartLine.MethodId != int64(resources.RECOGNIZER_RECOGNIZE) {
+		return channel.MRCPEngineChannelMessageSend(response)
+	}
+	response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+	if err := channel.MRCPEngineChannelMessageSend(response); err != nil {
+		return err
+	}
+	r.mutex.Lock()
+	r.recognizes++
+	if len(r.results) == 0 {
+		r.mutex.Unlock()
+		return nil
+	}
+	event := message.MRCPEventCreate(request, int64(resources.RECOGNIZER_RECOGNITION_COMPLETE))
+	event.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	event.Header.ResourceHeaderAccessor.Data, event.Body = r.results[0], r.bodies[0]
+	r.results, r.bodies = r.results[1:], r.bodies[1:]
+	r.mutex.Unlock()
+	return channel.MRCPEngineChannelMessageSend(event)
+}
+
+func (r *dictationTestRecognizer) resultQueue(cause resources.MRCPRecognizerCompletionCause, body string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.results = append(r.results, &resources.MRCPRecognizerHeader{CompletionCause: cause})
+	r.bodies = append(r.bodies, body)
+}
+
+func (r *dictationTestRecognizer) recognizesGet() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.recognizes
+}
+
+func dictationTestRequestCreate(methodId resources.MRCPRecognizerMethodId, requestId mrcp.MRCPRequestId) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, Version: mrcp.MRCP_VERSION_2, MethodId: int64(methodId), RequestId: requestId}
+	return request
+}
+
+func dictationTestMessageWait(t *testing.T, messages chan *message.MRCPMessage) *message.MRCPMessage {
+	select {
+	case msg := <-messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message sent to the client")
+	}
+	return nil
+}
+
+func TestRecogDictation(t *testing.T) {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	engine.Config = &MRCPEngineConfig{Params: map[string]string{MRCP_ENGINE_PARAM_DICTATION: "true"}}
+	messages := make(chan *message.MRCPMessage, 16)
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{}, nil, nil)
+	channel.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *message.MRCPMessage) error {
+			messages <- msg
+			return nil
+		},
+	}
+	r := &dictationTestRecognizer{}
+	recognizer := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		Open:           func(channel *MRCPEngineChannel) error { return nil },
+		ProcessRequest: r.requestProcess,
+	}, nil, nil)
+	recognizer.Audit = session.MRCPSessionAuditTrailCreate("dictation", 0)
+	d, err := MRCPRecogDictationCreate(channel, recognizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* the recognizer completing from within RECOGNIZE processing is re-armed (no match is a pause) */
+	r.resultQueue(resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS, `<result><interpretation confidence="0.9"><input>hello</input></interpretation></result>`)
+	r.resultQueue(resources.RECOGNIZER_COMPLETION_CAUSE_NO_MATCH, "")
+	r.resultQueue(resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS, `<result><interpretation confidence="0.8"><input>world</input></interpretation></result>`)
+	if err := d.MRCPRecogDictationRequestProcess(dictationTestRequestCreate(resources.RECOGNIZER_RECOGNIZE, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if response := dictationTestMessageWait(t, messages); response.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_RESPONSE ||
+		response.StartLine.RequestState != message.MRCP_REQUEST_STATE_INPROGRESS {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+
+	/* each segment finalized is flushed by the vendor specific event */
+	for i, input := range []string{"hello", "world"} {
+		event := dictationTestMessageWait(t, messages)
+		if event.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_EVENT || event.StartLine.MethodId != int64(MRCP_DICTATION_RESULT_EVENT_ID) ||
+			event.StartLine.MethodName != MRCP_DICTATION_RESULT_EVENT_NAME || event.StartLine.RequestState != message.MRCP_REQUEST_STATE_INPROGRESS {
+			t.Fatalf("event %+v", event.StartLine)
+		}
+		if !strings.Contains(event.Body, input) {
+			t.Fatalf("result %d %s", i, event.Body)
+		}
+		params := event.MRCPGenericHeaderGet().VendorSpecificParams
+		if pair := toolkit.AptPairArrayFind(params, MRCP_VENDOR_PARAM_DICTATION_SEQUENCE); pair == nil || pair.Value != string(rune('1'+i)) {
+			t.Fatalf("sequence %+v", pair)
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); r.recognizesGet() < 4 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+	}
+	if recognizes := r.recognizesGet(); recognizes != 4 {
+		t.Fatalf("recognizer armed %d times", recognizes)
+	}
+
+	/* STOP completes the request, the recognizer is not re-armed */
+	if err := d.MRCPRecogDictationRequestProcess(dictationTestRequestCreate(resources.RECOGNIZER_STOP, 2)); err != nil {
+		t.Fatal(err)
+	}
+	response := dictationTestMessageWait(t, messages)
+	if response.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_RESPONSE || response.StartLine.RequestId != 2 {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	/* the recognizer idle between re-arms does not list the request, the dictation does */
+	if genericHeader := response.MRCPGenericHeaderGet(); genericHeader == nil || genericHeader.ActiveRequestIdListFind(1) != nil {
+		t.Fatal("request in dictation mode is not listed as stopped")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(messages) != 0 || r.recognizesGet() != 4 {
+		t.Fatalf("%d messages sent, recognizer armed %d times after STOP", len(messages), r.recognizesGet())
+	}
+
+	/* the re-arms are not recorded as requests received, only RECOGNIZE and STOP of the client are */
+	inbound := 0
+	for _, record := range recognizer.Audit.MRCPAuditRecordsGet() {
+		if record.Direction == session.MRCP_AUDIT_DIRECTION_INBOUND {
+			inbound++
+		}
+	}
+	if inbound != 2 {
+		t.Fatalf("%d requests recorded", inbound)
+	}
+}
+
+func TestRecogDictationFailure(t *testing.T) {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	engine.Config = &MRCPEngineConfig{Params: map[string]string{MRCP_ENGINE_PARAM_DICTATION: "true"}}
+	messages := make(chan *message.MRCPMessage, 16)
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{}, nil, nil)
+	channel.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *message.MRCPMessage) error {
+			messages <- msg
+			return nil
+		},
+	}
+	r := &dictationTestRecognizer{}
+	recognizer := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		Open:           func(channel *MRCPEngineChannel) error { return nil },
+		ProcessRequest: r.requestProcess,
+	}, nil, nil)
+	d, err := MRCPRecogDictationCreate(channel, recognizer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* failure of the recognizer completes the request by RECOGNITION-COMPLETE */
+	r.resultQueue(resources.RECOGNIZER_COMPLETION_CAUSE_GRAM_LOAD_FAILURE, "")
+	if err := d.MRCPRecogDictationRequestProcess(dictationTestRequestCreate(resources.RECOGNIZER_RECOGNIZE, 1)); err != nil {
+		t.Fatal(err)
+	}
+	dictationTestMessageWait(t, messages)
+	event := dictationTestMessageWait(t, messages)
+	if event.StartLine.MethodId != int64(resources.RECOGNIZER_RECOGNITION_COMPLETE) || event.StartLine.RequestState != message.MRCP_REQUEST_STATE_COMPLETE ||
+		mrcpRecogCompletionCauseGet(event) != resources.RECOGNIZER_COMPLETION_CAUSE_GRAM_LOAD_FAILURE {
+		t.Fatalf("event %+v", event.StartLine)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if r.recognizesGet() != 1 {
+		t.Fatalf("recognizer armed %d times after failure", r.recognizesGet())
+	}
+}
@@ -0,0 +1,553 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/**
+ * Engine param (policy flag) enabling parallel recognition against multiple grammars
+ * by engines which accept only one grammar at a time.
+ */
+const MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS = "parallel-grammars"
+
+/**
+ * Engine param specifying how the results of the branches are combined:
+ * "merge" (default) - all the interpretations ordered by confidence, as soon as all the branches complete,
+ * "first-result" - the first successful result, the other branches are stopped then.
+ */
+const MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS_MODE = "parallel-grammars-mode"
+
+/** Modes of combining the results of the branches */
+const (
+	MRCP_RECOG_FANOUT_MODE_MERGE        = "merge"
+	MRCP_RECOG_FANOUT_MODE_FIRST_RESULT = "first-result"
+)
+
+/** Content type of the RECOGNIZE body referencing grammars by URIs */
+const MRCP_GRAMMAR_URI_LIST_CONTENT_TYPE = "text/uri-list"
+
+/*
+ * Request identifier the STOP requests sent to the branches by the fan-out itself start from,
+ * apart from the identifiers of the client requests.
+ */
+const mrcpRecogFanoutStopRequestIdBase mrcp.MRCPRequestId = 1 << 31
+
+/** Create branch channel (a single grammar recognizer) of the fan-out */
+type MRCPRecogFanoutBranchCreate func(fanout *MRCPRecogFanout, index int) (*MRCPEngineChannel, error)
+
+/**
+ * Fan-out of RECOGNIZE request referencing multiple grammars to the branch channels,
+ * each recognizing against one grammar on the forked audio. Results are merged by confidence,
+ * or the first result completes the request (see MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS_MODE).
+ */
+type MRCPRecogFanout struct {
+	channel      *MRCPEngineChannel          // Channel facing the client
+	createBranch MRCPRecogFanoutBranchCreate // Branch channel creator
+	branches     []*MRCPEngineChannel        // Branch channels
+
+	mutex        sync.Mutex
+	request      *message.MRCPMessage   // In-progress RECOGNIZE request, fanned out
+	active       int                    // Number of branches the request is fanned out to
+	pending      int                    // Number of branches not completed yet
+	completions  []*message.MRCPMessage // RECOGNITION-COMPLETE events of the branches
+	responded    bool                   // Is response to the request sent
+	inputStarted bool                   // Is START-OF-INPUT event sent
+	completed    bool                   // Is the request completed by the first result, the other branches being stopped
+	stops        int                    // Number of STOP requests sent to the branches, not responded yet
+	stopId       mrcp.MRCPRequestId     // Request identifier of the last STOP sent to the branches
+}
+
+/**
+ * Create recognizer fan-out.
+ * @param channel the channel facing the client
+ * @param createBranch the creator of the branch channels
+ */
+func MRCPRecogFanoutCreate(channel *MRCPEngineChannel, createBranch MRCPRecogFanoutBranchCreate) *MRCPRecogFanout {
+	return &MRCPRecogFanout{
+		channel:      channel,
+		createBranch: createBranch,
+	}
+}
+
+/** Check whether parallel recognition is enabled by the engine policy */
+func (f *MRCPRecogFanout) MRCPRecogFanoutEnabled() bool {
+	if f.channel == nil || f.channel.engine == nil {
+		return false
+	}
+	enabled, _ := strconv.ParseBool(f.channel.engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS))
+	return enabled
+}
+
+/* Check whether the first result completes the request, must be called under the lock */
+func (f *MRCPRecogFanout) firstResultMode() bool {
+	if f.channel == nil || f.channel.engine == nil {
+		return false
+	}
+	return f.channel.engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS_MODE) == MRCP_RECOG_FANOUT_MODE_FIRST_RESULT
+}
+
+/* Get (create if needed) branch channel */
+func (f *MRCPRecogFanout) branchGet(index int) (*MRCPEngineChannel, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for len(f.branches) <= index {
+		i := len(f.branches)
+		branch, err := f.createBranch(f, i)
+		if err != nil {
+			return nil, message.MRCPFailureErrorCreate(message.MRCP_FAILURE_ENGINE_UNAVAILABLE, err)
+		}
+		if branch == nil {
+			return nil, message.MRCPFailureErrorCreate(message.MRCP_FAILURE_ENGINE_UNAVAILABLE, fmt.Errorf("failed to create branch %d", i))
+		}
+		branch.EventVTable = &MRCPEngineChannelEventVTable{
+			OnOpen: func(channel *MRCPEngineChannel, status bool) error {
+				return nil
+			},
+			OnClose: func(channel *MRCPEngineChannel) error {
+				return nil
+			},
+			OnMessage: func(channel *MRCPEngineChannel, message *message.MRCPMessage) error {
+				return f.branchMessage(i, message)
+			},
+		}
+		branch.EventObj = f
+		if err := MRCPEngineChannelVirtualOpen(branch); err != nil {
+			return nil, message.MRCPFailureErrorCreate(message.MRCP_FAILURE_ENGINE_UNAVAILABLE, err)
+		}
+		f.branches = append(f.branches, branch)
+	}
+	return f.branches[index], nil
+}
+
+/* Get grammar URIs referenced by RECOGNIZE request */
+func mrcpRecogGrammarUrisGet(request *message.MRCPMessage) []string {
+	genericHeader, ok := request.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	if !ok || genericHeader == nil || !strings.EqualFold(genericHeader.ContentType, MRCP_GRAMMAR_URI_LIST_CONTENT_TYPE) {
+		return nil
+	}
+	var uris []string
+	for _, line := range strings.Split(request.Body, "\n") {
+		if uri := strings.TrimSpace(line); len(uri) > 0 {
+			uris = append(uris, uri)
+		}
+	}
+	return uris
+}
+
+/* Create branch request referencing single grammar */
+func mrcpRecogBranchRequestCreate(request *message.MRCPMessage, uri string) *message.MRCPMessage {
+	branchRequest := *request
+	branchRequest.Body = uri + "\r\n"
+	if genericHeader, ok := request.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader); ok && genericHeader != nil {
+		branchHeader := *genericHeader
+		branchHeader.ContentLength = int64(len(branchRequest.Body))
+		branchRequest.Header.GenericHeaderAccessor.Data = &branchHeader
+	}
+	return &branchRequest
+}
+
+/**
+ * Process request received from the client.
+ * @param request the request to process
+ */
+func (f *MRCPRecogFanout) MRCPRecogFanoutRequestProcess(request *message.MRCPMessage) error {
+	var uris []string
+	if request.StartLine != nil && request.StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNIZE) && f.MRCPRecogFanoutEnabled() {
+		uris = mrcpRecogGrammarUrisGet(request)
+	}
+
+	if len(uris) <= 1 {
+		/* nothing to fan out, forward to the primary branch or
+		to all the branches while fanned out request is in-progress */
+		f.mutex.Lock()
+		count := 1
+		if f.request != nil {
+			count = f.active
+			if request.StartLine != nil && request.StartLine.MethodId == int64(resources.RECOGNIZER_STOP) {
+				/* stopped requests are not completed by events */
+				f.request = nil
+			}
+		}
+		f.mutex.Unlock()
+		for i := 0; i < count; i++ {
+			branch, err := f.branchGet(i)
+			if err != nil {
+				return err
+			}
+			if err := MRCPEngineChannelRequestProcess(branch, request); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := range uris {
+		if _, err := f.branchGet(i); err != nil {
+			/* no branch for each grammar, nothing is started */
+			return f.channel.MRCPEngineChannelMessageSend(message.MRCPStatusMapDefaultGet().MRCPFailureResponseCreate(request, err))
+		}
+	}
+
+	f.mutex.Lock()
+	f.request = request
+	f.active = len(uris)
+	f.pending = len(uris)
+	f.completions = nil
+	f.responded = false
+	f.inputStarted = false
+	f.completed = false
+	f.mutex.Unlock()
+
+	for i, uri := range uris {
+		branch, err := f.branchGet(i)
+		if err != nil {
+			return err
+		}
+		branchRequest := mrcpRecogBranchRequestCreate(request, uri)
+		if err := MRCPEngineChannelRequestProcess(branch, branchRequest); err != nil {
+			/* the branch failed to start recognition as if responded by the failure */
+			failure := message.MRCPStatusMapDefaultGet().MRCPFailureResponseCreate(branchRequest, err)
+			if err := f.branchMessage(i, failure); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/**
+ * Fork audio frame to the branches.
+ * @param frame the frame written to the channel
+ */
+func (f *MRCPRecogFanout) MRCPRecogFanoutFrameWrite(frame *mpf.Frame) error {
+	f.mutex.Lock()
+	count := len(f.branches)
+	if f.request != nil {
+		count = f.active
+	}
+	if count > len(f.branches) {
+		count = len(f.branches)
+	}
+	branches := f.branches[:count]
+	f.mutex.Unlock()
+
+	for _, branch := range branches {
+		if branch.Termination == nil {
+			continue
+		}
+		stream := branch.Termination.TerminationAudioStreamGet()
+		if stream == nil || stream.VTable == nil || stream.VTable.WriteFrame == nil {
+			continue
+		}
+		fork := *frame
+		if frame.CodecFrame.Buffer != nil {
+			fork.CodecFrame.Buffer = bytes.NewBuffer(append([]byte(nil), frame.CodecFrame.Buffer.Bytes()...))
+		}
+		if err := stream.VTable.WriteFrame(stream, &fork); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Branch is stopped, the request is done as soon as all the branches are, must be called under the lock */
+func (f *MRCPRecogFanout) stopDone() {
+	f.stops--
+	if f.stops == 0 && f.completed {
+		f.request = nil
+		f.completed = false
+	}
+}
+
+/*
+ * Stop the branches still recognizing as the request is completed by the first result. Called apart from
+ * the handler of the branch messages, as the branches may send messages from within the request processing.
+ */
+func (f *MRCPRecogFanout) branchesStop(request *message.MRCPMessage, branches []*MRCPEngineChannel, stopId mrcp.MRCPRequestId) {
+	for _, branch := range branches {
+		stop := message.MRCPMessageCreate()
+		stop.StartLine = &message.MRCPStartLine{
+			MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+			Version:     request.StartLine.Version,
+			MethodId:    int64(resources.RECOGNIZER_STOP),
+			MethodName:  "STOP",
+			RequestId:   stopId,
+		}
+		stop.ChannelId = request.ChannelId
+		stop.Resource = request.Resource
+		if err := MRCPEngineChannelRequestProcess(branch, stop); err != nil {
+			f.mutex.Lock()
+			if f.stopId == stopId {
+				f.stopDone()
+			}
+			f.mutex.Unlock()
+		}
+	}
+}
+
+/* Process message sent by the branch */
+func (f *MRCPRecogFanout) branchMessage(index int, msg *message.MRCPMessage) error {
+	f.mutex.Lock()
+	if msg.StartLine != nil && f.stops > 0 && msg.StartLine.RequestId == f.stopId {
+		/* response to STOP sent by the fan-out */
+		f.stopDone()
+		f.mutex.Unlock()
+		return nil
+	}
+	if f.completed && f.request != nil && msg.StartLine != nil && msg.StartLine.RequestId == f.request.StartLine.RequestId {
+		/* the branches stopped are done as soon as they respond to STOP */
+		f.mutex.Unlock()
+		return nil
+	}
+	if f.request == nil || msg.StartLine == nil || msg.StartLine.RequestId != f.request.StartLine.RequestId {
+		f.mutex.Unlock()
+		/* not a fanned out request, relay messages of the primary branch only */
+		if index != 0 {
+			return nil
+		}
+		return f.channel.MRCPEngineChannelMessageSend(msg)
+	}
+
+	var (
+		forward *message.MRCPMessage
+		stopped []*MRCPEngineChannel
+	)
+	switch {
+	case msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE:
+		if msg.StartLine.StatusCode >= message.MRCP_STATUS_CODE_METHOD_NOT_ALLOWED ||
+			msg.StartLine.RequestState == message.MRCP_REQUEST_STATE_COMPLETE {
+			/* the branch failed to start recognition */
+			f.pending--
+			if f.pending == 0 && len(f.completions) == 0 && !f.responded {
+				f.responded = true
+				f.request = nil
+				forward = msg
+			} else if f.pending == 0 && f.responded {
+				forward = f.completionsMerge()
+			}
+		} else if !f.responded {
+			f.responded = true
+			forward = msg
+		}
+	case msg.StartLine.MethodId == int64(resources.RECOGNIZER_START_OF_INPUT):
+		if !f.inputStarted {
+			f.inputStarted = true
+			forward = msg
+		}
+	case msg.StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNITION_COMPLETE):
+		f.completions = append(f.completions, msg)
+		f.pending--
+		if f.pending == 0 {
+			forward = f.completionsMerge()
+		} else if f.firstResultMode() && mrcpRecogCompletionCauseGet(msg) == resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS {
+			/* the other branches are still recognizing */
+			forward = msg
+			f.completed = true
+			f.completions = nil
+			for i, branch := range f.branches[:f.active] {
+				if i != index {
+					stopped = append(stopped, branch)
+				}
+			}
+			if f.stopId < mrcpRecogFanoutStopRequestIdBase {
+				f.stopId = mrcpRecogFanoutStopRequestIdBase
+			}
+			f.stopId++
+			f.stops = len(stopped)
+		}
+	}
+	request, stopId := f.request, f.stopId
+	f.mutex.Unlock()
+
+	if len(stopped) > 0 {
+		go f.branchesStop(request, stopped, stopId)
+	}
+	if forward == nil {
+		return nil
+	}
+	return f.channel.MRCPEngineChannelMessageSend(forward)
+}
+
+/* Get completion cause of RECOGNITION-COMPLETE event */
+func mrcpRecogCompletionCauseGet(msg *message.MRCPMessage) resources.MRCPRecognizerCompletionCause {
+	recogHeader, ok := msg.Header.ResourceHeaderAccessor.Data.(*resources.MRCPRecognizerHeader)
+	if !ok || recogHeader == nil {
+		return resources.RECOGNIZER_COMPLETION_CAUSE_UNKNOWN
+	}
+	return recogHeader.CompletionCause
+}
+
+/** Namespace of NLSML results */
+const MRCP_NLSML_NAMESPACE = "urn:ietf:params:xml:ns:mrcpv2"
+
+/* NLSML result, the interpretations are kept as received */
+type nlsmlResult struct {
+	XMLName         xml.Name              `xml:"result"`
+	Attrs           []xml.Attr            `xml:",any,attr"`
+	Interpretations []nlsmlInterpretation `xml:"interpretation"`
+}
+
+/* NLSML interpretation */
+type nlsmlInterpretation struct {
+	Attrs    []xml.Attr `xml:",any,attr"`
+	InnerXML string     `xml:",innerxml"`
+}
+
+/*
+ * Parse NLSML result, the grammar of the result is set to the interpretations which do not specify one,
+ * so that they are kept apart from the result.
+ */
+func nlsmlResultParse(body string) (*nlsmlResult, error) {
+	result := &nlsmlResult{}
+	if err := xml.Unmarshal([]byte(body), result); err != nil {
+		return nil, fmt.Errorf("invalid NLSML result: %v", err)
+	}
+	for _, attr := range result.Attrs {
+		if attr.Name.Space != "" || attr.Name.Local != "grammar" {
+			continue
+		}
+		for i := range result.Interpretations {
+			if nlsmlAttrGet(result.Interpretations[i].Attrs, "grammar") == nil {
+				result.Interpretations[i].Attrs = append(result.Interpretations[i].Attrs, attr)
+			}
+		}
+	}
+	return result, nil
+}
+
+/* Parse interpretations of NLSML result */
+func nlsmlInterpretationsParse(body string) ([]nlsmlInterpretation, error) {
+	result, err := nlsmlResultParse(body)
+	if err != nil {
+		return nil, err
+	}
+	return result.Interpretations, nil
+}
+
+/* Get attribute of no namespace by name, nil if there is none */
+func nlsmlAttrGet(attrs []xml.Attr, name string) *xml.Attr {
+	for i := range attrs {
+		if attrs[i].Name.Space == "" && attrs[i].Name.Local == name {
+			return &attrs[i]
+		}
+	}
+	return nil
+}
+
+/* Generate attributes of NLSML element, the namespace declarations are kept, the attributes of other namespaces are not */
+func nlsmlAttrsGenerate(b *strings.Builder, attrs []xml.Attr) {
+	for _, attr := range attrs {
+		name := attr.Name.Local
+		if attr.Name.Space == "xmlns" {
+			name = "xmlns:" + name
+		} else if attr.Name.Space != "" {
+			continue
+		}
+		b.WriteString(" " + name + "=\"")
+		xml.EscapeText(b, []byte(attr.Value))
+		b.WriteString("\"")
+	}
+}
+
+/* Get confidence of NLSML interpretation */
+func (i *nlsmlInterpretation) confidenceGet() float64 {
+	for _, attr := range i.Attrs {
+		if attr.Name.Local == "confidence" {
+			confidence, _ := strconv.ParseFloat(strings.TrimSpace(attr.Value), 64)
+			return confidence
+		}
+	}
+	return 0
+}
+
+/* Generate NLSML interpretation element */
+func (i *nlsmlInterpretation) generate() string {
+	var b strings.Builder
+	b.WriteString("<interpretation")
+	nlsmlAttrsGenerate(&b, i.Attrs)
+	b.WriteString(">" + i.InnerXML + "</interpretation>")
+	return b.String()
+}
+
+/* Generate NLSML result of the interpretations, in the NLSML namespace, of the attributes of the result (e.g. grammar) */
+func nlsmlResultGenerate(interpretations []string, attrs ...xml.Attr) string {
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\"?>\n<result xmlns=\"" + MRCP_NLSML_NAMESPACE + "\"")
+	for _, attr := range attrs {
+		if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+			/* the default namespace is the NLSML one */
+			continue
+		}
+		nlsmlAttrsGenerate(&b, []xml.Attr{attr})
+	}
+	b.WriteString(">\n" + strings.Join(interpretations, "\n") + "\n</result>\n")
+	return b.String()
+}
+
+/* Merge RECOGNITION-COMPLETE events of the branches, must be called under the lock */
+func (f *MRCPRecogFanout) completionsMerge() *message.MRCPMessage {
+	var (
+		best            *message.MRCPMessage
+		bestAttrs       []xml.Attr
+		bestConfidence  = -1.0
+		interpretations []nlsmlInterpretation
+	)
+	for _, completion := range f.completions {
+		if mrcpRecogCompletionCauseGet(completion) != resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS {
+			if best == nil {
+				best = completion
+			}
+			continue
+		}
+		result, err := nlsmlResultParse(completion.Body)
+		if err != nil {
+			/* the result of the branch is not merged */
+			continue
+		}
+		for i := range result.Interpretations {
+			interpretations = append(interpretations, result.Interpretations[i])
+			if confidence := result.Interpretations[i].confidenceGet(); confidence > bestConfidence {
+				bestConfidence = confidence
+				best = completion
+				bestAttrs = result.Attrs
+			}
+		}
+	}
+	if best == nil && len(f.completions) > 0 {
+		/* none of the results is valid, relay as received */
+		best = f.completions[0]
+	}
+	f.request = nil
+	f.completions = nil
+	if best == nil || len(interpretations) == 0 {
+		return best
+	}
+
+	sort.SliceStable(interpretations, func(i, j int) bool {
+		return interpretations[i].confidenceGet() > interpretations[j].confidenceGet()
+	})
+	generated := make([]string, len(interpretations))
+	for i := range interpretations {
+		generated[i] = interpretations[i].generate()
+	}
+	merged := *best
+	merged.Body = nlsmlResultGenerate(generated, bestAttrs...)
+	if genericHeader, ok := best.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader); ok && genericHeader != nil {
+		mergedHeader := *genericHeader
+		mergedHeader.ContentLength = int64(len(merged.Body))
+		merged.Header.GenericHeaderAccessor.Data = &mergedHeader
+	}
+	return &merged
+}
@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/* Branch recognizing against one grammar, completed by the test */
+type fanoutTestBranch struct {
+	*MRCPEngineChannel
+	mutex    sync.Mutex
+	requests []*message.MRCPMessage
+	fail     bool          // Fail to start recognition
+	err      error         // [OPTIONAL] Error to process recognition by
+	hold     chan struct{} // [OPTIONAL] Hold response to STOP until closed
+}
+
+func (b *fanoutTestBranch) requestProcess(channel *MRCPEngineChannel, request *message.MRCPMessage) error {
+	b.mutex.Lock()
+	b.requests = append(b.requests, request)
+	b.mutex.Unlock()
+	if request.StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNIZE) && b.err != nil {
+		return b.err
+	}
+	if request.StartLine.MethodId == int64(resources.RECOGNIZER_STOP) && b.hold != nil {
+		<-b.hold
+	}
+	response := message.MRCPResponseCreate(request)
+	if request.StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNIZE) {
+		if b.fail {
+			response.StartLine.StatusCode = message.MRCP_STATUS_CODE_METHOD_FAILED
+		} else {
+			response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+		}
+	}
+	return channel.MRCPEngineChannelMessageSend(response)
+}
+
+func (b *fanoutTestBranch) methodsGet() []int64 {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var methods []int64
+	for _, request := range b.requests {
+		methods = append(methods, request.StartLine.MethodId)
+	}
+	return methods
+}
+
+/* Complete recognition of the branch by the interpretation of the confidence, no match if negative */
+func (b *fanoutTestBranch) complete(request *message.MRCPMessage, confidence float64) {
+	event := message.MRCPEventCreate(request, int64(resources.RECOGNIZER_RECOGNITION_COMPLETE))
+	event.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	cause := resources.RECOGNIZER_COMPLETION_CAUSE_NO_MATCH
+	if confidence >= 0 {
+		cause = resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS
+		grammar := strings.TrimSpace(b.recognizeGet().Body)
+		event.Body = fmt.Sprintf(`<?xml version="1.0"?><result xmlns="urn:ietf:params:xml:ns:mrcpv2" grammar="%s">`+
+			`<interpretation confidence="%.1f"><instance>%s</instance><input mode="speech">%s</input></interpretation></result>`,
+			grammar, confidence, grammar[:1], grammar[:1])
+		event.Header.GenericHeaderAccessor.Data = &header.MRCPGenericHeader{ContentType: "application/nlsml+xml", ContentLength: int64(len(event.Body))}
+	}
+	event.Header.ResourceHeaderAccessor.Data = &resources.MRCPRecognizerHeader{CompletionCause: cause}
+	b.MRCPEngineChannelMessageSend(event)
+}
+
+func (b *fanoutTestBranch) recognizeGet() *message.MRCPMessage {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for i := len(b.requests) - 1; i >= 0; i-- {
+		if b.requests[i].StartLine.MethodId == int64(resources.RECOGNIZER_RECOGNIZE) {
+			return b.requests[i]
+		}
+	}
+	return nil
+}
+
+func (b *fanoutTestBranch) lastRequest() *message.MRCPMessage {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.requests[len(b.requests)-1]
+}
+
+type fanoutTest struct {
+	fanout   *MRCPRecogFanout
+	branches []*fanoutTestBranch
+	messages chan *message.MRCPMessage
+}
+
+/* Create fan-out of the branches, failing to create the branches over the count */
+func fanoutTestCreate(mode string, count int) *fanoutTest {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	engine.Config = &MRCPEngineConfig{Params: map[string]string{
+		MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS:      "true",
+		MRCP_ENGINE_PARAM_PARALLEL_GRAMMARS_MODE: mode,
+	}}
+	test := &fanoutTest{messages: make(chan *message.MRCPMessage, 16)}
+	channel := engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{}, nil, nil)
+	channel.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *message.MRCPMessage) error {
+			test.messages <- msg
+			return nil
+		},
+	}
+	test.fanout = MRCPRecogFanoutCreate(channel, func(fanout *MRCPRecogFanout, index int) (*MRCPEngineChannel, error) {
+		if index >= count {
+			return nil, fmt.Errorf("no recognizer available")
+		}
+		branch := &fanoutTestBranch{}
+		branch.MRCPEngineChannel = engine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+			Open:           func(channel *MRCPEngineChannel) error { return nil },
+			ProcessRequest: branch.requestProcess,
+		}, nil, nil)
+		test.branches = append(test.branches, branch)
+		return branch.MRCPEngineChannel, nil
+	})
+	return test
+}
+
+func fanoutTestRecognizeCreate(requestId mrcp.MRCPRequestId, uris ...string) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, Version: mrcp.MRCP_VERSION_2, MethodId: int64(resources.RECOGNIZER_RECOGNIZE), RequestId: requestId}
+	request.Body = strings.Join(uris, "\n")
+	request.Header.GenericHeaderAccessor.Data = &header.MRCPGenericHeader{ContentType: MRCP_GRAMMAR_URI_LIST_CONTENT_TYPE, ContentLength: int64(len(request.Body))}
+	return request
+}
+
+func (test *fanoutTest) messageWait(t *testing.T) *message.MRCPMessage {
+	select {
+	case msg := <-test.messages:
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message sent to the client")
+	}
+	return nil
+}
+
+func (test *fanoutTest) noMessage(t *testing.T) {
+	time.Sleep(20 * time.Millisecond)
+	if len(test.messages) != 0 {
+		t.Fatalf("message sent to the client %+v", (<-test.messages).StartLine)
+	}
+}
+
+func TestRecogFanoutMerge(t *testing.T) {
+	test := fanoutTestCreate(MRCP_RECOG_FANOUT_MODE_MERGE, 3)
+	request := fanoutTestRecognizeCreate(1, "alpha", "bravo", "charlie")
+	if err := test.fanout.MRCPRecogFanoutRequestProcess(request); err != nil {
+		t.Fatal(err)
+	}
+	/* each branch recognizes against one grammar, the client is responded once */
+	for i, uri := range []string{"alpha", "bravo", "charlie"} {
+		if body := test.branches[i].lastRequest().Body; body != uri+"\r\n" {
+			t.Fatalf("branch %d grammar %q", i, body)
+		}
+	}
+	if response := test.messageWait(t); response.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_RESPONSE {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	test.noMessage(t)
+
+	/* the results are merged as soon as all the branches complete */
+	test.branches[0].complete(test.branches[0].lastRequest(), 0.4)
+	test.branches[2].complete(test.branches[2].lastRequest(), -1)
+	test.noMessage(t)
+	test.branches[1].complete(test.branches[1].lastRequest(), 0.9)
+	event := test.messageWait(t)
+	if event.StartLine.MethodId != int64(resources.RECOGNIZER_RECOGNITION_COMPLETE) || mrcpRecogCompletionCauseGet(event) != resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS {
+		t.Fatalf("event %+v", event.StartLine)
+	}
+	result, err := nlsmlResultParse(event.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	interpretations := result.Interpretations
+	if len(interpretations) != 2 || interpretations[0].confidenceGet() != 0.9 || interpretations[1].confidenceGet() != 0.4 ||
+		!strings.Contains(interpretations[0].InnerXML, "<input mode=\"speech\">b</input>") {
+		t.Fatalf("merged result %s", event.Body)
+	}
+	/* the result is of NLSML namespace and the grammar of the best one, the interpretations keep the grammars of their results */
+	if grammar := nlsmlAttrGet(result.Attrs, "grammar"); result.XMLName.Space != MRCP_NLSML_NAMESPACE || grammar == nil || grammar.Value != "bravo" {
+		t.Fatalf("merged result %s", event.Body)
+	}
+	if !strings.Contains(event.Body, `<interpretation confidence="0.4" grammar="alpha">`) ||
+		!strings.Contains(event.Body, `<interpretation confidence="0.9" grammar="bravo">`) {
+		t.Fatalf("grammars of the interpretations are not kept: %s", event.Body)
+	}
+	if event.MRCPGenericHeaderGet().ContentLength != int64(len(event.Body)) {
+		t.Fatalf("content length %d of %d", event.MRCPGenericHeaderGet().ContentLength, len(event.Body))
+	}
+}
+
+func TestRecogFanoutFirstResult(t *testing.T) {
+	test := fanoutTestCreate(MRCP_RECOG_FANOUT_MODE_FIRST_RESULT, 3)
+	request := fanoutTestRecognizeCreate(1, "alpha", "bravo", "charlie")
+	if err := test.fanout.MRCPRecogFanoutRequestProcess(request); err != nil {
+		t.Fatal(err)
+	}
+	test.messageWait(t)
+	test.branches[0].hold = make(chan struct{})
+
+	/* no match does not complete the request */
+	test.branches[2].complete(test.branches[2].lastRequest(), -1)
+	test.noMessage(t)
+	test.branches[1].complete(test.branches[1].lastRequest(), 0.6)
+	event := test.messageWait(t)
+	if event.StartLine.MethodId != int64(resources.RECOGNIZER_RECOGNITION_COMPLETE) || !strings.Contains(event.Body, `grammar="bravo"`) {
+		t.Fatalf("event %+v: %s", event.StartLine, event.Body)
+	}
+
+	/* the branch still recognizing is stopped, its late result and the response to STOP are not relayed */
+	for deadline := time.Now().Add(2 * time.Second); len(test.branches[0].methodsGet()) < 2 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+	}
+	if methods := test.branches[0].methodsGet(); len(methods) != 2 || methods[1] != int64(resources.RECOGNIZER_STOP) {
+		t.Fatalf("branch methods %v", methods)
+	}
+	if stop := test.branches[0].lastRequest(); stop.StartLine.RequestId < mrcpRecogFanoutStopRequestIdBase {
+		t.Fatalf("STOP request id %d", stop.StartLine.RequestId)
+	}
+	test.branches[0].complete(test.branches[0].recognizeGet(), 0.9)
+	test.noMessage(t)
+	close(test.branches[0].hold)
+	test.noMessage(t)
+
+	test.fanout.mutex.Lock()
+	done := test.fanout.request == nil && test.fanout.stops == 0
+	test.fanout.mutex.Unlock()
+	if !done {
+		t.Fatal("request is not done as the branches are stopped")
+	}
+}
+
+func TestRecogFanoutFailure(t *testing.T) {
+	/* no branch for each grammar, the engine is unavailable */
+	test := fanoutTestCreate(MRCP_RECOG_FANOUT_MODE_MERGE, 2)
+	if err := test.fanout.MRCPRecogFanoutRequestProcess(fanoutTestRecognizeCreate(1, "alpha", "bravo", "charlie")); err != nil {
+		t.Fatal(err)
+	}
+	if response := test.messageWait(t); response.StartLine.StatusCode != message.MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE ||
+		response.StartLine.RequestState != message.MRCP_REQUEST_STATE_COMPLETE {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	if len(test.branches) != 2 || len(test.branches[0].methodsGet()) != 0 || len(test.branches[1].methodsGet()) != 0 {
+		t.Fatal("request is fanned out to the branches created")
+	}
+
+	/* the failure of all the branches to start is responded once */
+	test = fanoutTestCreate(MRCP_RECOG_FANOUT_MODE_MERGE, 2)
+	if _, err := test.fanout.branchGet(1); err != nil {
+		t.Fatal(err)
+	}
+	test.branches[0].fail = true
+	test.branches[1].err = message.MRCPFailureErrorCreate(message.MRCP_FAILURE_MEDIA, fmt.Errorf("no audio"))
+	if err := test.fanout.MRCPRecogFanoutRequestProcess(fanoutTestRecognizeCreate(1, "alpha", "bravo")); err != nil {
+		t.Fatal(err)
+	}
+	if response := test.messageWait(t); response.StartLine.StatusCode != message.MRCP_STATUS_CODE_METHOD_FAILED ||
+		response.StartLine.RequestState != message.MRCP_REQUEST_STATE_COMPLETE {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	test.noMessage(t)
+
+	/* the branch failing to start is not waited for, the invalid result is not merged */
+	test.branches[0].fail = false
+	request := fanoutTestRecognizeCreate(2, "alpha", "bravo")
+	if err := test.fanout.MRCPRecogFanoutRequestProcess(request); err != nil {
+		t.Fatal(err)
+	}
+	if response := test.messageWait(t); response.StartLine.RequestState != message.MRCP_REQUEST_STATE_INPROGRESS {
+		t.Fatalf("response %+v", response.StartLine)
+	}
+	invalid := message.MRCPEventCreate(request, int64(resources.RECOGNIZER_RECOGNITION_COMPLETE))
+	invalid.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	invalid.Header.ResourceHeaderAccessor.Data = &resources.MRCPRecognizerHeader{CompletionCause: resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS}
+	invalid.Body = "<result><interpretation confidence=\"0.5\">"
+	test.branches[0].MRCPEngineChannelMessageSend(invalid)
+	if event := test.messageWait(t); event != invalid {
+		t.Fatalf("event %+v: %s", event.StartLine, event.Body)
+	}
+}
@@ -0,0 +1,48 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/** Names of vendor specific params START-OF-INPUT event reports spotted keyword by */
+const (
+	MRCP_VENDOR_PARAM_KEYWORD            = "keyword"
+	MRCP_VENDOR_PARAM_KEYWORD_CONFIDENCE = "keyword-confidence"
+)
+
+/**
+ * Create START-OF-INPUT event of the in-progress RECOGNIZE request reporting keyword spotted,
+ * e.g. "Vendor-Specific-Parameters: keyword=hello;keyword-confidence=0.87".
+ * Recognizer engines gating the recognition by keyword spotter stream send it instead of
+ * the START-OF-INPUT by the activity detector.
+ * @param request the RECOGNIZE request
+ * @param event the keyword spotted
+ */
+func MRCPRecogKeywordEventCreate(request *message.MRCPMessage, event *mpf.KeywordSpotterEvent) (*message.MRCPMessage, error) {
+	if request == nil || event == nil {
+		return nil, fmt.Errorf("no request or keyword to create event by")
+	}
+	msg := message.MRCPEventCreate(request, mrcp.MRCPMethodId(resources.RECOGNIZER_START_OF_INPUT))
+	genericHeader := msg.MRCPGenericHeaderPrepare()
+	if genericHeader == nil {
+		return nil, fmt.Errorf("failed to prepare generic header")
+	}
+	if genericHeader.VendorSpecificParams == nil {
+		genericHeader.VendorSpecificParams = toolkit.AptPairArrayCreate(2)
+	}
+	toolkit.AptPairArrayAppend(genericHeader.VendorSpecificParams, MRCP_VENDOR_PARAM_KEYWORD, event.Keyword)
+	toolkit.AptPairArrayAppend(genericHeader.VendorSpecificParams, MRCP_VENDOR_PARAM_KEYWORD_CONFIDENCE,
+		strconv.FormatFloat(event.Confidence, 'f', 2, 64))
+	if err := msg.MRCPGenericHeaderPropertyAdd(int64(header.GENERIC_HEADER_VENDOR_SPECIFIC_PARAMS)); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
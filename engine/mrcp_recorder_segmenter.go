@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
+)
+
+/** Engine param specifying the silence gap in msec to split recordings at, 0 disables segmentation */
+const MRCP_ENGINE_PARAM_RECORD_SEGMENT_GAP = "record-segment-gap"
+
+/** Recorded segment */
+type MRCPRecordSegment struct {
+	Key      string // Storage key of the segment
+	Uri      string // URI the stored segment is accessible by
+	Size     int64  // Size of the segment in bytes
+	Duration int64  // Duration of the segment in msec
+}
+
+/** Get Record-URI of the segment (<uri>;size=<size>;duration=<duration>) */
+func (s *MRCPRecordSegment) MRCPRecordSegmentUriGet() string {
+	return fmt.Sprintf("<%s>;size=%d;duration=%d", s.Uri, s.Size, s.Duration)
+}
+
+/** Recorder splitting captured audio into multiple objects at silence boundaries */
+type MRCPRecorderSegmenter struct {
+	storage      storage.MRCPStorage   // Storage to put segments to
+	prefix       string                // Prefix of storage keys
+	contentType  string                // MIME type of segments (audio/L16, network byte order)
+	detector     *mpf.ActivityDetector // Activity detector to find silence boundaries by
+	bytesPerMsec int64                 // Size of 1 msec of audio
+
+	buffer   *bytes.Buffer        // Data of the current segment
+	segment  *MRCPRecordSegment   // Current segment
+	activity bool                 // Is activity detected in the current segment
+	Segments []*MRCPRecordSegment // Completed segments
+}
+
+/**
+ * Create recorder segmenter.
+ * @param store the storage to put segments to
+ * @param prefix the prefix of storage keys, e.g. "recordings/<session id>/<channel id>"
+ * @param descriptor the codec descriptor of the captured (linear) audio
+ * @param gap the silence gap in msec to split recording at
+ */
+func MRCPRecorderSegmenterCreate(store storage.MRCPStorage, prefix string, descriptor *mpf.CodecDescriptor, gap int64) *MRCPRecorderSegmenter {
+	detector := mpf.ActivityDetectorCreate()
+	detector.ActivityDetectorSilenceTimeoutSet(gap)
+	return &MRCPRecorderSegmenter{
+		storage:      store,
+		prefix:       prefix,
+		contentType:  fmt.Sprintf("audio/L16;rate=%d;channels=%d", descriptor.SamplingRate, descriptor.ChannelCount),
+		detector:     detector,
+		bytesPerMsec: mpf.CodecLinearFrameSizeCalculate(descriptor.SamplingRate, descriptor.ChannelCount) / mpf.CODEC_FRAME_TIME_BASE,
+	}
+}
+
+/**
+ * Create recorder segmenter putting segments to the engine storage, if segmentation is enabled by the engine config.
+ * @param engine the recorder engine
+ * @param prefix the prefix of storage keys
+ * @param descriptor the codec descriptor of the captured (linear) audio
+ */
+func (engine *MRCPEngine) MRCPRecorderSegmenterCreate(prefix string, descriptor *mpf.CodecDescriptor) *MRCPRecorderSegmenter {
+	gap, err := strconv.ParseInt(engine.MRCPEngineParamGet(MRCP_ENGINE_PARAM_RECORD_SEGMENT_GAP), 10, 64)
+	if err != nil || gap <= 0 {
+		return nil
+	}
+	store, err := engine.MRCPEngineStorageGet()
+	if err != nil {
+		return nil
+	}
+	return MRCPRecorderSegmenterCreate(store, prefix, descriptor, gap)
+}
+
+func (s *MRCPRecorderSegmenter) segmentOpen() error {
+	s.buffer = bytes.NewBuffer(nil)
+	s.segment = &MRCPRecordSegment{Key: fmt.Sprintf("%s-%d.pcm", s.prefix, len(s.Segments)+1)}
+	s.activity = false
+	return nil
+}
+
+func (s *MRCPRecorderSegmenter) segmentClose() error {
+	if s.buffer == nil {
+		return nil
+	}
+	var err error
+	if s.activity {
+		metadata := &storage.MRCPStorageMetadata{ContentType: s.contentType}
+		s.segment.Uri, err = s.storage.Put(s.segment.Key, s.buffer, metadata)
+		if err == nil {
+			s.Segments = append(s.Segments, s.segment)
+		}
+	}
+	/* otherwise nothing but noise blip, drop the segment */
+	s.buffer = nil
+	s.segment = nil
+	return err
+}
+
+/**
+ * Write captured frame, switching to the next file at silence boundary.
+ * @param frame the captured frame of host order linear audio
+ */
+func (s *MRCPRecorderSegmenter) MRCPRecorderSegmenterFrameWrite(frame *mpf.Frame) error {
+	event, err := s.detector.ActivityDetectorProcess(frame)
+	if err != nil {
+		return err
+	}
+	if event == mpf.MPF_DETECTOR_EVENT_ACTIVITY {
+		s.activity = true
+	}
+	if s.buffer != nil && !s.activity && s.detector.State == mpf.DETECTOR_STATE_INACTIVITY {
+		/* nothing but noise blip, drop the segment rather than record the silence following it */
+		s.buffer = nil
+		s.segment = nil
+	}
+
+	if s.buffer == nil {
+		if s.detector.State == mpf.DETECTOR_STATE_INACTIVITY {
+			/* skip silence between segments */
+			return nil
+		}
+		if err := s.segmentOpen(); err != nil {
+			return err
+		}
+	}
+
+	if (frame.Type&mpf.MEDIA_FRAME_TYPE_AUDIO) == mpf.MEDIA_FRAME_TYPE_AUDIO && frame.CodecFrame.Buffer != nil {
+		data := frame.CodecFrame.Buffer.Bytes()
+		if frame.CodecFrame.Size > 0 && frame.CodecFrame.Size < int64(len(data)) {
+			data = data[:frame.CodecFrame.Size]
+		}
+		/* audio/L16 is of network byte order */
+		data, err := mpf.L16NetworkOrderConvert(data)
+		if err != nil {
+			return err
+		}
+		n, err := s.buffer.Write(data)
+		if err != nil {
+			return err
+		}
+		s.segment.Size += int64(n)
+		if s.bytesPerMsec > 0 {
+			s.segment.Duration = s.segment.Size / s.bytesPerMsec
+		}
+	}
+	if event == mpf.MPF_DETECTOR_EVENT_INACTIVITY {
+		/* the silence gap is over, complete the segment including the gap */
+		return s.segmentClose()
+	}
+	return nil
+}
+
+/**
+ * Complete recording and get the list of Record-URIs of the segments.
+ */
+func (s *MRCPRecorderSegmenter) MRCPRecorderSegmenterComplete() (string, error) {
+	err := s.segmentClose()
+	uris := make([]string, 0, len(s.Segments))
+	for _, segment := range s.Segments {
+		uris = append(uris, segment.MRCPRecordSegmentUriGet())
+	}
+	return strings.Join(uris, ","), err
+}
@@ -0,0 +1,226 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
+)
+
+/* Storage keeping the objects put in memory */
+type segmenterTestStorage struct {
+	objects  map[string][]byte
+	metadata map[string]*storage.MRCPStorageMetadata
+	err      error // [OPTIONAL] Error to fail put by
+}
+
+func segmenterTestStorageCreate() *segmenterTestStorage {
+	return &segmenterTestStorage{objects: make(map[string][]byte), metadata: make(map[string]*storage.MRCPStorageMetadata)}
+}
+
+func (s *segmenterTestStorage) Put(key string, r io.Reader, metadata *storage.MRCPStorageMetadata) (string, error) {
+	if s.err != nil {
+		return "", s.err
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.objects[key] = data
+	s.metadata[key] = metadata
+	return "mem://" + key, nil
+}
+
+func (s *segmenterTestStorage) Get(key string) (io.ReadCloser, *storage.MRCPStorageMetadata, error) {
+	data, ok := s.objects[key]
+	if !ok {
+		return nil, nil, storage.ErrMRCPStorageNotFound
+	}
+	return ioutil.NopCloser(bytes.NewReader(data)), s.metadata[key], nil
+}
+
+func (s *segmenterTestStorage) Delete(key string) error {
+	delete(s.objects, key)
+	return nil
+}
+
+/* Write frames of linear PCM of the amplitude (0 - silence) for the duration in msec */
+func segmenterTestWrite(t *testing.T, s *MRCPRecorderSegmenter, amplitude int16, duration int) {
+	for elapsed := 0; elapsed < duration; elapsed += mpf.CODEC_FRAME_TIME_BASE {
+		data := make([]byte, s.bytesPerMsec*mpf.CODEC_FRAME_TIME_BASE)
+		for i := 0; i < len(data); i += 2 {
+			binary.LittleEndian.PutUint16(data[i:], uint16(amplitude))
+		}
+		frame := &mpf.Frame{Type: mpf.MEDIA_FRAME_TYPE_AUDIO}
+		frame.CodecFrame.Buffer = bytes.NewBuffer(data)
+		frame.CodecFrame.Size = int64(len(data))
+		if err := s.MRCPRecorderSegmenterFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestRecorderSegmenterRollover(t *testing.T) {
+	store := segmenterTestStorageCreate()
+	s := MRCPRecorderSegmenterCreate(store, "recordings/session-1/channel-1", mpf.CodecLPcmDescriptorCreate(8000, 1), 200)
+
+	/* leading silence is not recorded */
+	segmenterTestWrite(t, s, 0, 500)
+	if len(store.objects) != 0 || s.buffer != nil {
+		t.Fatal("silence is recorded")
+	}
+
+	/* the segment is put as soon as the silence gap (following the first frame of silence) is over, including the gap */
+	segmenterTestWrite(t, s, 1000, 600)
+	segmenterTestWrite(t, s, 0, 100)
+	if len(s.Segments) != 0 {
+		t.Fatal("segment is put within the gap")
+	}
+	segmenterTestWrite(t, s, 0, 400)
+	if len(s.Segments) != 1 {
+		t.Fatalf("%d segments put", len(s.Segments))
+	}
+	segment := s.Segments[0]
+	if segment.Key != "recordings/session-1/channel-1-1.pcm" || segment.Uri != "mem://"+segment.Key ||
+		segment.Duration != 810 || segment.Size != 810*16 || int64(len(store.objects[segment.Key])) != segment.Size {
+		t.Fatalf("segment %+v", segment)
+	}
+	if metadata := store.metadata[segment.Key]; metadata.ContentType != "audio/L16;rate=8000;channels=1" {
+		t.Fatalf("metadata %+v", metadata)
+	}
+
+	/* the pause shorter than the gap does not split the segment */
+	segmenterTestWrite(t, s, -1000, 400)
+	segmenterTestWrite(t, s, 0, 150)
+	segmenterTestWrite(t, s, 1000, 400)
+	segmenterTestWrite(t, s, 0, 300)
+	if len(s.Segments) != 2 || s.Segments[1].Key != "recordings/session-1/channel-1-2.pcm" || s.Segments[1].Duration != 1160 {
+		t.Fatalf("segments %+v", s.Segments)
+	}
+}
+
+func TestRecorderSegmenterFrameData(t *testing.T) {
+	store := segmenterTestStorageCreate()
+	s := MRCPRecorderSegmenterCreate(store, "rec", mpf.CodecLPcmDescriptorCreate(8000, 1), 200)
+	segmenterTestWrite(t, s, 1000, 400)
+
+	frameWrite := func(length int, size int64) {
+		data := make([]byte, length)
+		for i := 0; i < len(data); i += 2 {
+			binary.LittleEndian.PutUint16(data[i:], 1000)
+		}
+		frame := &mpf.Frame{Type: mpf.MEDIA_FRAME_TYPE_AUDIO}
+		frame.CodecFrame.Buffer = bytes.NewBuffer(data)
+		frame.CodecFrame.Size = size
+		if err := s.MRCPRecorderSegmenterFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	/* the whole buffer is written if the size is not set */
+	frameWrite(160, 0)
+	/* the buffer is truncated to the size */
+	frameWrite(200, 160)
+	if _, err := s.MRCPRecorderSegmenterComplete(); err != nil {
+		t.Fatal(err)
+	}
+	data := store.objects["rec-1.pcm"]
+	if len(s.Segments) != 1 || len(data) != 420*16 || s.Segments[0].Duration != 420 {
+		t.Fatalf("segments %+v, %d bytes put", s.Segments, len(data))
+	}
+
+	/* samples are put in network byte order, as audio/L16 is */
+	for i := 0; i < len(data); i += 2 {
+		if sample := binary.BigEndian.Uint16(data[i:]); sample != 1000 {
+			t.Fatalf("sample %d: %d", i/2, sample)
+		}
+	}
+}
+
+func TestRecorderSegmenterNoiseBlip(t *testing.T) {
+	store := segmenterTestStorageCreate()
+	s := MRCPRecorderSegmenterCreate(store, "blip", mpf.CodecLPcmDescriptorCreate(8000, 1), 200)
+
+	/* the noise shorter than speech is dropped with the silence following it */
+	segmenterTestWrite(t, s, 1000, 100)
+	segmenterTestWrite(t, s, 0, 1000)
+	segmenterTestWrite(t, s, 1000, 400)
+	segmenterTestWrite(t, s, 0, 210)
+	if len(s.Segments) != 1 || s.Segments[0].Key != "blip-1.pcm" || s.Segments[0].Duration != 610 {
+		t.Fatalf("segments %+v", s.Segments)
+	}
+
+	/* the noise left at completion is not put */
+	segmenterTestWrite(t, s, 1000, 100)
+	uris, err := s.MRCPRecorderSegmenterComplete()
+	if err != nil || uris != "<mem://blip-1.pcm>;size=9760;duration=610" || len(store.objects) != 1 {
+		t.Fatalf("uris %s: %v", uris, err)
+	}
+}
+
+func TestRecorderSegmenterComplete(t *testing.T) {
+	store := segmenterTestStorageCreate()
+	s := MRCPRecorderSegmenterCreate(store, "rec", mpf.CodecLPcmDescriptorCreate(16000, 1), 200)
+	if uris, err := s.MRCPRecorderSegmenterComplete(); err != nil || uris != "" {
+		t.Fatalf("uris %s of nothing recorded: %v", uris, err)
+	}
+
+	/* the segment in progress is finalized on completion */
+	segmenterTestWrite(t, s, 1000, 500)
+	segmenterTestWrite(t, s, 0, 300)
+	segmenterTestWrite(t, s, 1000, 400)
+	uris, err := s.MRCPRecorderSegmenterComplete()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"<mem://rec-1.pcm>;size=22720;duration=710", "<mem://rec-2.pcm>;size=12800;duration=400"}
+	if uris != strings.Join(want, ",") {
+		t.Fatalf("uris %s", uris)
+	}
+	if uris, err := s.MRCPRecorderSegmenterComplete(); err != nil || uris != strings.Join(want, ",") {
+		t.Fatalf("uris %s completed again: %v", uris, err)
+	}
+}
+
+func TestRecorderSegmenterStorageFailure(t *testing.T) {
+	store := segmenterTestStorageCreate()
+	store.err = fmt.Errorf("storage is unavailable")
+	s := MRCPRecorderSegmenterCreate(store, "rec", mpf.CodecLPcmDescriptorCreate(8000, 1), 200)
+	segmenterTestWrite(t, s, 1000, 400)
+
+	/* the failure to put the segment at the gap is reported by the frame completing it */
+	var err error
+	for i := 0; i < 50 && err == nil; i++ {
+		frame := &mpf.Frame{Type: mpf.MEDIA_FRAME_TYPE_AUDIO}
+		frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 160))
+		frame.CodecFrame.Size = 160
+		err = s.MRCPRecorderSegmenterFrameWrite(frame)
+	}
+	if err != store.err || len(s.Segments) != 0 {
+		t.Fatalf("segments %+v: %v", s.Segments, err)
+	}
+
+	segmenterTestWrite(t, s, 1000, 400)
+	if _, err := s.MRCPRecorderSegmenterComplete(); err != store.err {
+		t.Fatalf("completed: %v", err)
+	}
+}
+
+func TestRecorderSegmenterEngine(t *testing.T) {
+	engine := MRCPEngineCreate(0, nil, &MRCPEngineMethodVTable{})
+	if engine.MRCPRecorderSegmenterCreate("rec", mpf.CodecLPcmDescriptorCreate(8000, 1)) != nil {
+		t.Fatal("segmenter created, segmentation is not enabled")
+	}
+	store := segmenterTestStorageCreate()
+	engine.Config = &MRCPEngineConfig{Params: map[string]string{MRCP_ENGINE_PARAM_RECORD_SEGMENT_GAP: "250"}}
+	engine.MRCPEngineStorageSet(store)
+	s := engine.MRCPRecorderSegmenterCreate("rec", mpf.CodecLPcmDescriptorCreate(8000, 1))
+	if s == nil || s.storage != store || s.detector.SilenceTimeout != 250 {
+		t.Fatalf("segmenter %+v", s)
+	}
+}
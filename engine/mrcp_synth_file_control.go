@@ -0,0 +1,66 @@
+package engine
+
+import (
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/**
+ * Process synthesizer CONTROL request against file-backed prompt:
+ * Jump-Size header skips the file stream forward or backward.
+ * @param stream the file stream the prompt is played from
+ * @param request the CONTROL request
+ * @return the response to send
+ */
+func MRCPSynthFileControlProcess(stream *mpf.AudioStream, request *message.MRCPMessage) *message.MRCPMessage {
+	response := message.MRCPResponseCreate(request)
+	if response.StartLine == nil {
+		return response
+	}
+
+	synthHeader, ok := request.MRCPResourceHeaderGet().(*resources.MRCPSynthHeader)
+	if !ok || synthHeader == nil || !request.MRCPResourceHeaderPropertyCheck(int64(resources.SYNTHESIZER_HEADER_JUMP_SIZE)) {
+		/* nothing to jump, other CONTROL headers (prosody, voice) are not applicable to files */
+		return response
+	}
+
+	offset, err := synthHeader.JumpSize.MRCPSpeechLengthMsecGet()
+	if err != nil {
+		message.MRCPStatusMapDefaultGet().MRCPResponseFailureSet(response, message.MRCP_FAILURE_PARAM_VALUE)
+		return response
+	}
+	if err := mpf.FileStreamSeek(stream, offset); err != nil {
+		message.MRCPStatusMapDefaultGet().MRCPResponseFailureSet(response, message.MRCP_FAILURE_MEDIA)
+	}
+	return response
+}
+
+/**
+ * Process synthesizer request controlling file-backed prompt playback:
+ * PAUSE and RESUME pause and resume the file stream, CONTROL is processed by MRCPSynthFileControlProcess.
+ * @param stream the file stream the prompt is played from
+ * @param request the PAUSE, RESUME or CONTROL request
+ * @return the response to send, nil if the request is not a playback control one
+ */
+func MRCPSynthFileRequestProcess(stream *mpf.AudioStream, request *message.MRCPMessage) *message.MRCPMessage {
+	if request == nil || request.StartLine == nil {
+		return nil
+	}
+	var err error
+	switch request.StartLine.MethodId {
+	case int64(resources.SYNTHESIZER_PAUSE):
+		err = mpf.FileStreamPause(stream)
+	case int64(resources.SYNTHESIZER_RESUME):
+		err = mpf.FileStreamResume(stream)
+	case int64(resources.SYNTHESIZER_CONTROL):
+		return MRCPSynthFileControlProcess(stream, request)
+	default:
+		return nil
+	}
+	response := message.MRCPResponseCreate(request)
+	if err != nil && response.StartLine != nil {
+		message.MRCPStatusMapDefaultGet().MRCPResponseFailureSet(response, message.MRCP_FAILURE_MEDIA)
+	}
+	return response
+}
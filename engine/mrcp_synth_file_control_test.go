@@ -0,0 +1,157 @@
+package engine
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/* Create stream playing 5 sec prompt of linear PCM 8 kHz, each frame filled with its index (modulo 256) */
+func fileControlTestStreamCreate(t *testing.T) *mpf.AudioStream {
+	var prompt []byte
+	for i := 0; i < 500; i++ {
+		for j := 0; j < 160; j++ {
+			prompt = append(prompt, byte(i))
+		}
+	}
+	dir, err := ioutil.TempDir("", "prompt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "prompt.pcm")
+	if err := ioutil.WriteFile(path, prompt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		file.Close()
+		os.RemoveAll(dir)
+	})
+	player := mpf.FileTerminationFactoryCreate().TerminationCreate(nil)
+	reader := mpf.AudioFileDescriptorCreate(mpf.FILE_READER)
+	reader.CodecDescriptor = mpf.CodecLPcmDescriptorCreate(8000, 1)
+	reader.ReadHandle = file
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	return player.TerminationAudioStreamGet()
+}
+
+/* Read frame of the stream, get its index (-1 if no audio is read) */
+func fileControlTestRead(t *testing.T, stream *mpf.AudioStream) int {
+	frame := &mpf.Frame{}
+	if err := stream.AudioStreamFrameRead(frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type&mpf.MEDIA_FRAME_TYPE_AUDIO == 0 {
+		return -1
+	}
+	return int(frame.CodecFrame.Buffer.Bytes()[0])
+}
+
+/* Create synthesizer request, CONTROL with Jump-Size of the length in the unit if the method is CONTROL */
+func fileControlTestRequestCreate(method resources.MRCPSynthesizerMethodId, length int64, unit resources.MRCPSpeechUnit) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, Version: mrcp.MRCP_VERSION_2, MethodId: int64(method), RequestId: 1}
+	if method != resources.SYNTHESIZER_CONTROL || length == 0 {
+		return request
+	}
+	synthHeader := &resources.MRCPSynthHeader{}
+	synthHeader.JumpSize.Type = resources.SPEECH_LENGTH_TYPE_NUMERIC_POSITIVE
+	if length < 0 {
+		synthHeader.JumpSize.Type = resources.SPEECH_LENGTH_TYPE_NUMERIC_NEGATIVE
+		length = -length
+	}
+	synthHeader.JumpSize.Value.Numeric = resources.MRCPNumericSpeechLength{Length: length, Unit: unit}
+	request.Header.ResourceHeaderAccessor.Data = synthHeader
+	value := fmt.Sprintf("%+d %d", length, unit)
+	request.MRCPMessageHeaderFieldAdd(toolkit.AptHeaderFieldCreate("Jump-Size", value, int64(resources.SYNTHESIZER_HEADER_JUMP_SIZE)+int64(header.GENERIC_HEADER_COUNT)))
+	return request
+}
+
+func TestSynthFileControlJump(t *testing.T) {
+	stream := fileControlTestStreamCreate(t)
+	fileControlTestRead(t, stream)
+
+	for _, test := range []struct {
+		length   int64 // Jump-Size in sec, 0 - not specified
+		position int64 // Position after the jump in msec
+		frame    int   // Frame played after the jump
+	}{
+		{0, 10, 1},
+		{2, 2020, 202},
+		{-1, 1030, 103},
+		/* rewind before the start and skip past the end are clamped */
+		{-5, 0, 0},
+		{10, 5000, -1},
+		{-1, 4000, 400 % 256},
+	} {
+		response := MRCPSynthFileControlProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_CONTROL, test.length, resources.SPEECH_UNIT_SECOND))
+		if response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS || response.StartLine.RequestState != message.MRCP_REQUEST_STATE_COMPLETE {
+			t.Fatalf("response to jump of %d sec %+v", test.length, response.StartLine)
+		}
+		if position, err := mpf.FileStreamPositionGet(stream); err != nil || position != test.position {
+			t.Fatalf("position %d after jump of %d sec, want %d: %v", position, test.length, test.position, err)
+		}
+		if frame := fileControlTestRead(t, stream); frame != test.frame {
+			t.Fatalf("frame %d played after jump of %d sec, want %d", frame, test.length, test.frame)
+		}
+	}
+}
+
+func TestSynthFileControlFailure(t *testing.T) {
+	stream := fileControlTestStreamCreate(t)
+
+	/* the jump by words is not applicable to files */
+	response := MRCPSynthFileControlProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_CONTROL, 2, resources.SPEECH_UNIT_WORD))
+	if response.StartLine.StatusCode != message.MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE {
+		t.Fatalf("response to jump by words %+v", response.StartLine)
+	}
+	if position, _ := mpf.FileStreamPositionGet(stream); position != 0 {
+		t.Fatalf("position %d after jump by words", position)
+	}
+
+	/* not a file stream */
+	other := mpf.AudioStreamCreate(nil, &mpf.AudioStreamVTable{}, mpf.SourceStreamCapabilitiesCreate())
+	response = MRCPSynthFileControlProcess(other, fileControlTestRequestCreate(resources.SYNTHESIZER_CONTROL, 2, resources.SPEECH_UNIT_SECOND))
+	if response.StartLine.StatusCode != message.MRCP_STATUS_CODE_METHOD_FAILED {
+		t.Fatalf("response to jump of other stream %+v", response.StartLine)
+	}
+}
+
+func TestSynthFileRequestProcess(t *testing.T) {
+	stream := fileControlTestStreamCreate(t)
+	if response := MRCPSynthFileRequestProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_PAUSE, 0, 0)); response == nil ||
+		response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS || !mpf.FileStreamPausedGet(stream) {
+		t.Fatalf("response to PAUSE %+v", response)
+	}
+	if frame := fileControlTestRead(t, stream); frame != -1 {
+		t.Fatalf("frame %d played while paused", frame)
+	}
+
+	/* seeking while paused */
+	MRCPSynthFileRequestProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_CONTROL, 3, resources.SPEECH_UNIT_SECOND))
+	if response := MRCPSynthFileRequestProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_RESUME, 0, 0)); response == nil ||
+		response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS || mpf.FileStreamPausedGet(stream) {
+		t.Fatalf("response to RESUME %+v", response)
+	}
+	if frame := fileControlTestRead(t, stream); frame != 300%256 {
+		t.Fatalf("frame %d played after resume", frame)
+	}
+
+	if response := MRCPSynthFileRequestProcess(stream, fileControlTestRequestCreate(resources.SYNTHESIZER_SPEAK, 0, 0)); response != nil {
+		t.Fatalf("response to SPEAK %+v", response)
+	}
+}
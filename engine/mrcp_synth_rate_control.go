@@ -0,0 +1,37 @@
+package engine
+
+import (
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/**
+ * Process synthesizer CONTROL request against already synthesized audio of engines,
+ * which can't re-render it: Prosody-Rate header changes the playback rate of the time-stretch
+ * filter the audio is read through, keeping its pitch.
+ * @param stretch the time-stretch filter the audio is read through
+ * @param request the CONTROL request
+ * @return the response to send
+ */
+func MRCPSynthRateControlProcess(stretch *mpf.TimeStretch, request *message.MRCPMessage) *message.MRCPMessage {
+	response := message.MRCPResponseCreate(request)
+	if response.StartLine == nil {
+		return response
+	}
+
+	synthHeader, ok := request.MRCPResourceHeaderGet().(*resources.MRCPSynthHeader)
+	if !ok || synthHeader == nil || !request.MRCPResourceHeaderPropertyCheck(int64(resources.SYNTHESIZER_HEADER_PROSODY_RATE)) {
+		/* rate is not changed */
+		return response
+	}
+
+	rate, err := synthHeader.MRCPProsodyRateGet().MRCPProsodyRateFactorGet()
+	if err == nil {
+		err = stretch.TimeStretchRateSet(rate)
+	}
+	if err != nil {
+		message.MRCPStatusMapDefaultGet().MRCPResponseFailureSet(response, message.MRCP_FAILURE_PARAM_VALUE)
+	}
+	return response
+}
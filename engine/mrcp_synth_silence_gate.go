@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+)
+
+/** Engine param enabling trimming of leading/trailing silence of synthesized audio (true/false) */
+const MRCP_ENGINE_PARAM_SYNTH_SILENCE_TRIM = "synth-silence-trim"
+
+/** Engine param specifying level threshold of silence (mean absolute sample value of linear audio) */
+const MRCP_ENGINE_PARAM_SYNTH_SILENCE_LEVEL = "synth-silence-level"
+
+/** Engine param specifying duration of silence kept before and after speech in msec */
+const MRCP_ENGINE_PARAM_SYNTH_SILENCE_KEEP = "synth-silence-keep"
+
+/** Engine param specifying max duration of silence trimmed at once in msec */
+const MRCP_ENGINE_PARAM_SYNTH_SILENCE_MAX = "synth-silence-max"
+
+/**
+ * Create silence gate of synthesized audio according to the config of the tenant,
+ * the audio produced by the engine is to be read through it before it is sent to RTP.
+ * @param tenant the tenant name
+ * @return the gate, nil if trimming is not enabled
+ */
+func (engine *MRCPEngine) MRCPEngineSynthSilenceGateCreate(tenant string) (*mpf.SilenceGate, error) {
+	value := engine.MRCPEngineTenantParamGet(tenant, MRCP_ENGINE_PARAM_SYNTH_SILENCE_TRIM)
+	if len(value) == 0 {
+		return nil, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid synth silence trim %s", value)
+	}
+	if !enabled {
+		return nil, nil
+	}
+
+	params := []struct {
+		name  string
+		value int64
+	}{
+		{MRCP_ENGINE_PARAM_SYNTH_SILENCE_LEVEL, mpf.SILENCE_GATE_LEVEL_THRESHOLD},
+		{MRCP_ENGINE_PARAM_SYNTH_SILENCE_KEEP, mpf.SILENCE_GATE_KEEP},
+		{MRCP_ENGINE_PARAM_SYNTH_SILENCE_MAX, mpf.SILENCE_GATE_MAX},
+	}
+	for i := range params {
+		if value := engine.MRCPEngineTenantParamGet(tenant, params[i].name); len(value) > 0 {
+			if params[i].value, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid %s %s", params[i].name, value)
+			}
+		}
+	}
+	return mpf.SilenceGateCreate(params[0].value, params[1].value, params[2].value)
+}
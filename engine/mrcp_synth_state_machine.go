@@ -1,10 +1,428 @@
 package engine
 
 import (
+	"fmt"
+	"sync"
+
 	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/** MRCP synthesizer states */
+type MRCPSynthState = int
+
+const (
+	SYNTHESIZER_STATE_IDLE MRCPSynthState = iota
+	SYNTHESIZER_STATE_SPEAKING
+	SYNTHESIZER_STATE_PAUSED
+
+	SYNTHESIZER_STATE_COUNT
 )
 
-/** Create MRCP synth state machine */
+/** MRCP synthesizer state machine */
+type mrcpSynthStateMachine struct {
+	base    MRCPStateMachine
+	version mrcp.Version
+	state   MRCPSynthState
+
+	speaker       *message.MRCPMessage   // In-progress SPEAK request
+	speakerQueued bool                   // Speaker was queued, so its response is already sent as PENDING
+	queue         []*message.MRCPMessage // Queued (PENDING) SPEAK requests
+
+	stop       *message.MRCPMessage // STOP (BARGE-IN-OCCURRED) request dispatched to the engine
+	stopQueued []mrcp.MRCPRequestId // Queued requests to stop along with the speaker
+	completion *message.MRCPMessage // SPEAK-COMPLETE event raced by the dispatched STOP request
+	deactivate bool                 // Stop is initiated by deactivation
+
+	mutex    sync.Mutex
+	outbox   []*message.MRCPMessage // Messages to dispatch in order
+	flushing bool                   // Outbox is being flushed
+}
+
+/**
+ * Create MRCP synth state machine.
+ * Requests are dispatched to the engine, responses and events to the client, both via OnDispatch.
+ * The state machine may be updated concurrently by the client and the engine: each update is processed
+ * atomically and the resulting messages are dispatched in order, so that STOP racing a natural completion
+ * yields exactly one terminal event/response pair for each SPEAK request.
+ * @param obj the external object associated with the state machine
+ * @param version the MRCP version
+ */
 func MRCPSynthStateMachineCreate(obj interface{}, version mrcp.Version) *MRCPStateMachine {
+	sm := &mrcpSynthStateMachine{version: version}
+	MRCPStateMachineInit(&sm.base, obj)
+	sm.base.Update = func(machine *MRCPStateMachine, msg *message.MRCPMessage) error {
+		return sm.update(msg)
+	}
+	sm.base.Deactivate = func(machine *MRCPStateMachine) error {
+		return sm.deactivateRequest()
+	}
+	return &sm.base
+}
+
+func (sm *mrcpSynthStateMachine) update(msg *message.MRCPMessage) error {
+	if msg == nil || msg.StartLine == nil {
+		return fmt.Errorf("invalid synthesizer message")
+	}
+	sm.mutex.Lock()
+	var err error
+	switch msg.StartLine.MessageType {
+	case message.MRCP_MESSAGE_TYPE_REQUEST:
+		err = sm.requestUpdate(msg)
+	case message.MRCP_MESSAGE_TYPE_RESPONSE:
+		err = sm.responseUpdate(msg)
+	case message.MRCP_MESSAGE_TYPE_EVENT:
+		err = sm.eventUpdate(msg)
+	default:
+		err = fmt.Errorf("invalid synthesizer message type %d", msg.StartLine.MessageType)
+	}
+	sm.mutex.Unlock()
+	sm.flush()
+	return err
+}
+
+/* Queue message to dispatch (called with mutex held) */
+func (sm *mrcpSynthStateMachine) dispatch(msg *message.MRCPMessage) {
+	sm.outbox = append(sm.outbox, msg)
+}
+
+/* Dispatch queued messages in order; messages queued by nested or concurrent updates are dispatched by the same loop */
+func (sm *mrcpSynthStateMachine) flush() {
+	sm.mutex.Lock()
+	if sm.flushing {
+		sm.mutex.Unlock()
+		return
+	}
+	sm.flushing = true
+	for len(sm.outbox) > 0 {
+		msg := sm.outbox[0]
+		sm.outbox = sm.outbox[1:]
+		sm.mutex.Unlock()
+		if sm.base.OnDispatch != nil {
+			sm.base.OnDispatch(&sm.base, msg)
+		}
+		sm.mutex.Lock()
+	}
+	sm.flushing = false
+	sm.mutex.Unlock()
+}
+
+func (sm *mrcpSynthStateMachine) requestUpdate(request *message.MRCPMessage) error {
+	switch request.StartLine.MethodId {
+	case int64(resources.SYNTHESIZER_SPEAK):
+		sm.requestSpeak(request)
+	case int64(resources.SYNTHESIZER_STOP):
+		sm.requestStop(request)
+	case int64(resources.SYNTHESIZER_PAUSE):
+		sm.requestInState(request, SYNTHESIZER_STATE_SPEAKING)
+	case int64(resources.SYNTHESIZER_RESUME):
+		sm.requestInState(request, SYNTHESIZER_STATE_PAUSED)
+	case int64(resources.SYNTHESIZER_CONTROL):
+		if sm.speaker != nil && sm.stop == nil {
+			sm.dispatch(request)
+		} else {
+			sm.dispatch(message.MRCPResponseCreate(request))
+		}
+	case int64(resources.SYNTHESIZER_BARGE_IN_OCCURRED):
+		if sm.speaker != nil && mrcpSynthKillOnBargeIn(sm.speaker) {
+			sm.requestStop(request)
+		} else {
+			sm.dispatch(message.MRCPResponseCreate(request))
+		}
+	default:
+		sm.dispatch(request)
+	}
 	return nil
 }
+
+/* Process request only in the specified state, otherwise respond immediately */
+func (sm *mrcpSynthStateMachine) requestInState(request *message.MRCPMessage, state MRCPSynthState) {
+	if sm.state == state && sm.stop == nil {
+		sm.dispatch(request)
+		return
+	}
+	sm.dispatch(message.MRCPResponseCreate(request))
+}
+
+func (sm *mrcpSynthStateMachine) requestSpeak(request *message.MRCPMessage) {
+	if sm.speaker != nil || len(sm.queue) > 0 {
+		/* queue the request, respond with PENDING */
+		sm.queue = append(sm.queue, request)
+		response := message.MRCPResponseCreate(request)
+		response.StartLine.RequestState = message.MRCP_REQUEST_STATE_PENDING
+		sm.dispatch(response)
+		return
+	}
+	sm.speakStart(request, false)
+}
+
+func (sm *mrcpSynthStateMachine) speakStart(request *message.MRCPMessage, queued bool) {
+	sm.speaker = request
+	sm.speakerQueued = queued
+	sm.dispatch(request)
+}
+
+/* Start the next queued SPEAK request if any */
+func (sm *mrcpSynthStateMachine) speakNext() {
+	sm.speaker = nil
+	sm.speakerQueued = false
+	sm.state = SYNTHESIZER_STATE_IDLE
+	if len(sm.queue) == 0 {
+		return
+	}
+	request := sm.queue[0]
+	sm.queue = sm.queue[1:]
+	sm.speakStart(request, true)
+}
+
+/* Get the list of request ids the STOP request applies to (nil - all of the requests) */
+func mrcpActiveRequestIdListGet(request *message.MRCPMessage) *header.MRCPGenericHeader {
+	genericHeader, ok := request.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	if !ok || genericHeader == nil || genericHeader.ActiveRequestIdList.MRCPRequestIdListCount() == 0 {
+		return nil
+	}
+	return genericHeader
+}
+
+func (sm *mrcpSynthStateMachine) requestStop(request *message.MRCPMessage) {
+	if sm.stop != nil {
+		/* STOP is already in progress, nothing is left to stop */
+		sm.dispatch(message.MRCPResponseCreate(request))
+		return
+	}
+
+	var (
+		selective   = mrcpActiveRequestIdListGet(request)
+		stopSpeaker = sm.speaker != nil
+		stopQueued  []mrcp.MRCPRequestId
+	)
+	for _, queued := range sm.queue {
+		if selective == nil || selective.ActiveRequestIdListFind(queued.StartLine.RequestId) == nil {
+			stopQueued = append(stopQueued, queued.StartLine.RequestId)
+		}
+	}
+	if stopSpeaker && selective != nil && selective.ActiveRequestIdListFind(sm.speaker.StartLine.RequestId) != nil {
+		stopSpeaker = false
+	}
+
+	if !stopSpeaker {
+		/* only queued requests (if any) are stopped, respond immediately */
+		response := message.MRCPResponseCreate(request)
+		sm.stoppedIdsSet(response, sm.queueRemove(stopQueued))
+		sm.dispatch(response)
+		return
+	}
+
+	/* stop the in-progress request by the engine, queued ones are reported with its response */
+	sm.stop = request
+	sm.stopQueued = stopQueued
+	sm.dispatch(request)
+}
+
+/* Remove requests from the queue, returning the ids actually removed */
+func (sm *mrcpSynthStateMachine) queueRemove(ids []mrcp.MRCPRequestId) []mrcp.MRCPRequestId {
+	var removed []mrcp.MRCPRequestId
+	queue := sm.queue[:0]
+	for _, queued := range sm.queue {
+		found := false
+		for _, id := range ids {
+			if queued.StartLine.RequestId == id {
+				found = true
+				break
+			}
+		}
+		if found {
+			removed = append(removed, queued.StartLine.RequestId)
+		} else {
+			queue = append(queue, queued)
+		}
+	}
+	sm.queue = queue
+	return removed
+}
+
+/* Set the ids of the stopped requests in the Active-Request-Id-List of the response */
+func (sm *mrcpSynthStateMachine) stoppedIdsSet(response *message.MRCPMessage, ids []mrcp.MRCPRequestId) {
+	if len(ids) == 0 {
+		return
+	}
+	genericHeader := response.MRCPGenericHeaderPrepare()
+	for _, id := range ids {
+		if err := genericHeader.ActiveRequestIdListAppend(id); err != nil {
+			break
+		}
+	}
+	response.MRCPGenericHeaderPropertyAdd(int64(header.GENERIC_HEADER_ACTIVE_REQUEST_ID_LIST))
+}
+
+func (sm *mrcpSynthStateMachine) responseUpdate(response *message.MRCPMessage) error {
+	if sm.stop != nil && response.StartLine.RequestId == sm.stop.StartLine.RequestId &&
+		response.StartLine.MethodId == sm.stop.StartLine.MethodId {
+		sm.responseStop(response)
+		return nil
+	}
+
+	success := response.StartLine.StatusCode == message.MRCP_STATUS_CODE_SUCCESS ||
+		response.StartLine.StatusCode == message.MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE
+	switch response.StartLine.MethodId {
+	case int64(resources.SYNTHESIZER_SPEAK):
+		if sm.speaker == nil || sm.speaker.StartLine.RequestId != response.StartLine.RequestId {
+			/* unexpected SPEAK response */
+			return nil
+		}
+		if !success || response.StartLine.RequestState == message.MRCP_REQUEST_STATE_COMPLETE {
+			if sm.speakerQueued {
+				/* the client is waiting for SPEAK-COMPLETE of the pending request */
+				sm.dispatch(mrcpSynthSpeakCompleteCreate(sm.speaker, resources.SYNTHESIZER_COMPLETION_CAUSE_ERROR))
+			} else {
+				sm.dispatch(response)
+			}
+			if sm.stop == nil {
+				sm.speakNext()
+			} else {
+				/* the next request is started on STOP response */
+				sm.speaker = nil
+			}
+			return nil
+		}
+		sm.state = SYNTHESIZER_STATE_SPEAKING
+		if sm.speakerQueued {
+			/* PENDING response is already sent */
+			return nil
+		}
+	case int64(resources.SYNTHESIZER_PAUSE):
+		if success && sm.state == SYNTHESIZER_STATE_SPEAKING {
+			sm.state = SYNTHESIZER_STATE_PAUSED
+		}
+	case int64(resources.SYNTHESIZER_RESUME):
+		if success && sm.state == SYNTHESIZER_STATE_PAUSED {
+			sm.state = SYNTHESIZER_STATE_SPEAKING
+		}
+	}
+	sm.dispatch(response)
+	return nil
+}
+
+func (sm *mrcpSynthStateMachine) responseStop(response *message.MRCPMessage) {
+	completion := sm.completion
+	sm.stop = nil
+	sm.completion = nil
+	deactivate := sm.deactivate
+	sm.deactivate = false
+
+	if response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS &&
+		response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE {
+		/* nothing is stopped */
+		if !deactivate {
+			sm.dispatch(response)
+		}
+		if completion != nil {
+			/* deliver the raced natural completion instead */
+			sm.dispatch(completion)
+			sm.speakNext()
+		}
+		if deactivate {
+			sm.deactivated()
+		}
+		return
+	}
+
+	stopped := []mrcp.MRCPRequestId{}
+	if sm.speaker != nil {
+		stopped = append(stopped, sm.speaker.StartLine.RequestId)
+	}
+	stopped = append(stopped, sm.queueRemove(sm.stopQueued)...)
+	sm.stopQueued = nil
+	if deactivate {
+		sm.queue = nil
+	}
+	sm.stoppedIdsSet(response, stopped)
+	if !deactivate {
+		sm.dispatch(response)
+	}
+	sm.speakNext()
+	if deactivate {
+		sm.deactivated()
+	}
+}
+
+func (sm *mrcpSynthStateMachine) eventUpdate(event *message.MRCPMessage) error {
+	if sm.speaker == nil || sm.speaker.StartLine.RequestId != event.StartLine.RequestId {
+		/* late event of stopped or completed request */
+		return nil
+	}
+	if event.StartLine.MethodId != int64(resources.SYNTHESIZER_SPEAK_COMPLETE) {
+		sm.dispatch(event)
+		return nil
+	}
+	if sm.stop != nil {
+		/* STOP is in progress, its response reports the request instead */
+		sm.completion = event
+		return nil
+	}
+	sm.dispatch(event)
+	sm.speakNext()
+	return nil
+}
+
+func (sm *mrcpSynthStateMachine) deactivateRequest() error {
+	sm.mutex.Lock()
+	if sm.stop != nil {
+		/* deactivate on STOP response */
+		sm.deactivate = true
+		sm.mutex.Unlock()
+		return nil
+	}
+	if sm.speaker == nil {
+		sm.queue = nil
+		sm.deactivated()
+		sm.mutex.Unlock()
+		return nil
+	}
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{
+		MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     sm.version,
+		RequestId:   sm.speaker.StartLine.RequestId,
+		MethodId:    int64(resources.SYNTHESIZER_STOP),
+	}
+	request.ChannelId = sm.speaker.ChannelId
+	request.Resource = sm.speaker.Resource
+	sm.deactivate = true
+	sm.stop = request
+	sm.stopQueued = nil
+	sm.dispatch(request)
+	sm.mutex.Unlock()
+	sm.flush()
+	return nil
+}
+
+/* Notify deactivation (called with mutex held, the handler is invoked without it) */
+func (sm *mrcpSynthStateMachine) deactivated() {
+	sm.base.Active = false
+	if sm.base.OnDeactivate != nil {
+		onDeactivate := sm.base.OnDeactivate
+		sm.mutex.Unlock()
+		onDeactivate(&sm.base)
+		sm.mutex.Lock()
+	}
+}
+
+/* Check whether the speaker is to be killed on barge-in (true by default) */
+func mrcpSynthKillOnBargeIn(speaker *message.MRCPMessage) bool {
+	synthHeader, ok := speaker.Header.ResourceHeaderAccessor.Data.(*resources.MRCPSynthHeader)
+	if ok && synthHeader != nil && speaker.MRCPResourceHeaderPropertyCheck(int64(resources.SYNTHESIZER_HEADER_KILL_ON_BARGE_IN)) {
+		return synthHeader.KillOnBargeIn
+	}
+	return true
+}
+
+/* Create SPEAK-COMPLETE event of the request */
+func mrcpSynthSpeakCompleteCreate(request *message.MRCPMessage, cause resources.MRCPSynthCompletionCause) *message.MRCPMessage {
+	event := message.MRCPEventCreate(request, int64(resources.SYNTHESIZER_SPEAK_COMPLETE))
+	event.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	event.Header.ResourceHeaderAccessor.Data = &resources.MRCPSynthHeader{CompletionCause: cause}
+	return event
+}
@@ -0,0 +1,333 @@
+package engine
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/* Synth state machine with messages dispatched to the engine and to the client recorded */
+type synthHarness struct {
+	machine *MRCPStateMachine
+	mutex   sync.Mutex
+	engine  []*message.MRCPMessage
+	client  []*message.MRCPMessage
+	/* optional engine simulation invoked for each request dispatched to the engine */
+	onRequest func(request *message.MRCPMessage)
+}
+
+func synthHarnessCreate() *synthHarness {
+	h := &synthHarness{machine: MRCPSynthStateMachineCreate(nil, mrcp.MRCP_VERSION_2)}
+	h.machine.OnDispatch = func(machine *MRCPStateMachine, msg *message.MRCPMessage) error {
+		h.mutex.Lock()
+		if msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_REQUEST {
+			h.engine = append(h.engine, msg)
+		} else {
+			h.client = append(h.client, msg)
+		}
+		onRequest := h.onRequest
+		h.mutex.Unlock()
+		if onRequest != nil && msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_REQUEST {
+			onRequest(msg)
+		}
+		return nil
+	}
+	return h
+}
+
+func synthRequestCreate(id mrcp.MRCPRequestId, method resources.MRCPSynthesizerMethodId, active ...mrcp.MRCPRequestId) *message.MRCPMessage {
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{
+		MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     mrcp.MRCP_VERSION_2,
+		RequestId:   id,
+		MethodId:    int64(method),
+	}
+	if len(active) > 0 {
+		genericHeader := request.MRCPGenericHeaderPrepare()
+		for _, activeId := range active {
+			genericHeader.ActiveRequestIdListAppend(activeId)
+		}
+	}
+	return request
+}
+
+func synthResponseCreate(request *message.MRCPMessage, state message.MRCPRequestState) *message.MRCPMessage {
+	response := message.MRCPResponseCreate(request)
+	response.StartLine.RequestState = state
+	return response
+}
+
+func (h *synthHarness) update(t *testing.T, msg *message.MRCPMessage) {
+	if err := h.machine.MRCPStateMachineUpdate(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+/* Speak and let the engine start speaking */
+func (h *synthHarness) speak(t *testing.T, id mrcp.MRCPRequestId) *message.MRCPMessage {
+	request := synthRequestCreate(id, resources.SYNTHESIZER_SPEAK)
+	h.update(t, request)
+	h.update(t, synthResponseCreate(request, message.MRCP_REQUEST_STATE_INPROGRESS))
+	return request
+}
+
+func (h *synthHarness) engineRequests(method resources.MRCPSynthesizerMethodId) []*message.MRCPMessage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	var requests []*message.MRCPMessage
+	for _, request := range h.engine {
+		if request.StartLine.MethodId == int64(method) {
+			requests = append(requests, request)
+		}
+	}
+	return requests
+}
+
+/* Get the ids of the requests stopped by the STOP response */
+func synthStoppedIds(response *message.MRCPMessage) []mrcp.MRCPRequestId {
+	genericHeader := response.MRCPGenericHeaderGet()
+	if genericHeader == nil {
+		return nil
+	}
+	ids := make([]mrcp.MRCPRequestId, 0, genericHeader.ActiveRequestIdList.MRCPRequestIdListCount())
+	for i := 0; i < genericHeader.ActiveRequestIdList.MRCPRequestIdListCount(); i++ {
+		ids = append(ids, genericHeader.ActiveRequestIdList.MRCPRequestIdListGet(i))
+	}
+	return ids
+}
+
+/* Count terminal messages sent to the client for each SPEAK request */
+func (h *synthHarness) terminals() map[mrcp.MRCPRequestId]int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	terminals := make(map[mrcp.MRCPRequestId]int)
+	for _, msg := range h.client {
+		switch {
+		case msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_EVENT &&
+			msg.StartLine.MethodId == int64(resources.SYNTHESIZER_SPEAK_COMPLETE):
+			terminals[msg.StartLine.RequestId]++
+		case msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE &&
+			msg.StartLine.MethodId == int64(resources.SYNTHESIZER_STOP):
+			for _, id := range synthStoppedIds(msg) {
+				terminals[id]++
+			}
+		}
+	}
+	return terminals
+}
+
+func (h *synthHarness) lastClientMessage() *message.MRCPMessage {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.client[len(h.client)-1]
+}
+
+func idsEqual(ids []mrcp.MRCPRequestId, want ...mrcp.MRCPRequestId) bool {
+	if len(ids) != len(want) {
+		return false
+	}
+	for i := range ids {
+		if ids[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSynthStopAfterCompletion(t *testing.T) {
+	h := synthHarnessCreate()
+	speak := h.speak(t, 1)
+	h.update(t, mrcpSynthSpeakCompleteCreate(speak, resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL))
+
+	h.update(t, synthRequestCreate(2, resources.SYNTHESIZER_STOP))
+	if stops := h.engineRequests(resources.SYNTHESIZER_STOP); len(stops) != 0 {
+		t.Fatalf("STOP of completed request is dispatched to the engine")
+	}
+	if ids := synthStoppedIds(h.lastClientMessage()); len(ids) != 0 {
+		t.Fatalf("STOP response reports stopped requests %v", ids)
+	}
+	if terminals := h.terminals(); terminals[1] != 1 {
+		t.Fatalf("%d terminal messages of SPEAK", terminals[1])
+	}
+}
+
+func TestSynthStopRacingCompletion(t *testing.T) {
+	h := synthHarnessCreate()
+	speak := h.speak(t, 1)
+	stop := synthRequestCreate(2, resources.SYNTHESIZER_STOP)
+	h.update(t, stop)
+	if stops := h.engineRequests(resources.SYNTHESIZER_STOP); len(stops) != 1 {
+		t.Fatalf("STOP is dispatched to the engine %d times", len(stops))
+	}
+
+	/* the engine completes naturally before processing STOP */
+	h.update(t, mrcpSynthSpeakCompleteCreate(speak, resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL))
+	h.update(t, synthResponseCreate(stop, message.MRCP_REQUEST_STATE_COMPLETE))
+	if ids := synthStoppedIds(h.lastClientMessage()); !idsEqual(ids, 1) {
+		t.Fatalf("STOP response reports stopped requests %v, want [1]", ids)
+	}
+
+	/* late events are dropped */
+	h.update(t, mrcpSynthSpeakCompleteCreate(speak, resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL))
+	if terminals := h.terminals(); terminals[1] != 1 {
+		t.Fatalf("%d terminal messages of SPEAK", terminals[1])
+	}
+}
+
+func TestSynthStopFailureDeliversCompletion(t *testing.T) {
+	h := synthHarnessCreate()
+	speak := h.speak(t, 1)
+	stop := synthRequestCreate(2, resources.SYNTHESIZER_STOP)
+	h.update(t, stop)
+	h.update(t, mrcpSynthSpeakCompleteCreate(speak, resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL))
+
+	response := synthResponseCreate(stop, message.MRCP_REQUEST_STATE_COMPLETE)
+	response.StartLine.StatusCode = message.MRCP_STATUS_CODE_METHOD_FAILED
+	h.update(t, response)
+	if terminals := h.terminals(); terminals[1] != 1 {
+		t.Fatalf("%d terminal messages of SPEAK", terminals[1])
+	}
+}
+
+func TestSynthStopIdempotent(t *testing.T) {
+	h := synthHarnessCreate()
+	h.speak(t, 1)
+	stop := synthRequestCreate(2, resources.SYNTHESIZER_STOP)
+	h.update(t, stop)
+	h.update(t, synthRequestCreate(3, resources.SYNTHESIZER_STOP))
+	if ids := synthStoppedIds(h.lastClientMessage()); len(ids) != 0 {
+		t.Fatalf("repeated STOP reports stopped requests %v", ids)
+	}
+	h.update(t, synthResponseCreate(stop, message.MRCP_REQUEST_STATE_COMPLETE))
+	h.update(t, synthRequestCreate(4, resources.SYNTHESIZER_STOP))
+
+	if stops := h.engineRequests(resources.SYNTHESIZER_STOP); len(stops) != 1 {
+		t.Fatalf("STOP is dispatched to the engine %d times", len(stops))
+	}
+	if terminals := h.terminals(); terminals[1] != 1 {
+		t.Fatalf("%d terminal messages of SPEAK", terminals[1])
+	}
+}
+
+func TestSynthStopSelective(t *testing.T) {
+	h := synthHarnessCreate()
+	h.speak(t, 1)
+	h.update(t, synthRequestCreate(2, resources.SYNTHESIZER_SPEAK))
+	h.update(t, synthRequestCreate(3, resources.SYNTHESIZER_SPEAK))
+	if state := h.lastClientMessage().StartLine.RequestState; state != message.MRCP_REQUEST_STATE_PENDING {
+		t.Fatalf("queued SPEAK response state %d, want PENDING", state)
+	}
+
+	/* only the queued request is listed, the speaker keeps speaking */
+	h.update(t, synthRequestCreate(4, resources.SYNTHESIZER_STOP, 3))
+	if stops := h.engineRequests(resources.SYNTHESIZER_STOP); len(stops) != 0 {
+		t.Fatalf("STOP of queued request is dispatched to the engine")
+	}
+	if ids := synthStoppedIds(h.lastClientMessage()); !idsEqual(ids, 3) {
+		t.Fatalf("STOP response reports stopped requests %v, want [3]", ids)
+	}
+
+	/* unknown ids stop nothing */
+	h.update(t, synthRequestCreate(5, resources.SYNTHESIZER_STOP, 3, 42))
+	if ids := synthStoppedIds(h.lastClientMessage()); len(ids) != 0 {
+		t.Fatalf("STOP response reports stopped requests %v", ids)
+	}
+
+	/* stop the speaker only, the queued request starts then */
+	stop := synthRequestCreate(6, resources.SYNTHESIZER_STOP, 1)
+	h.update(t, stop)
+	h.update(t, synthResponseCreate(stop, message.MRCP_REQUEST_STATE_COMPLETE))
+	if ids := synthStoppedIds(h.lastClientMessage()); !idsEqual(ids, 1) {
+		t.Fatalf("STOP response reports stopped requests %v, want [1]", ids)
+	}
+	speaks := h.engineRequests(resources.SYNTHESIZER_SPEAK)
+	if len(speaks) != 2 || speaks[1].StartLine.RequestId != 2 {
+		t.Fatalf("queued SPEAK is not started")
+	}
+
+	/* STOP without the list stops everything */
+	h.update(t, synthRequestCreate(7, resources.SYNTHESIZER_SPEAK))
+	stop = synthRequestCreate(8, resources.SYNTHESIZER_STOP)
+	h.update(t, stop)
+	h.update(t, synthResponseCreate(stop, message.MRCP_REQUEST_STATE_COMPLETE))
+	if ids := synthStoppedIds(h.lastClientMessage()); !idsEqual(ids, 2, 7) {
+		t.Fatalf("STOP response reports stopped requests %v, want [2 7]", ids)
+	}
+
+	terminals := h.terminals()
+	for _, id := range []mrcp.MRCPRequestId{1, 2, 3, 7} {
+		if terminals[id] != 1 {
+			t.Fatalf("%d terminal messages of SPEAK %d", terminals[id], id)
+		}
+	}
+}
+
+func TestSynthStopRaceConcurrent(t *testing.T) {
+	for i := 0; i < 200; i++ {
+		h := synthHarnessCreate()
+		/* the engine responds to STOP as soon as it gets it */
+		h.onRequest = func(request *message.MRCPMessage) {
+			if request.StartLine.MethodId == int64(resources.SYNTHESIZER_STOP) {
+				h.machine.MRCPStateMachineUpdate(synthResponseCreate(request, message.MRCP_REQUEST_STATE_COMPLETE))
+			}
+		}
+		speak := h.speak(t, 1)
+
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			h.machine.MRCPStateMachineUpdate(mrcpSynthSpeakCompleteCreate(speak, resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL))
+		}()
+		go func() {
+			defer wg.Done()
+			h.machine.MRCPStateMachineUpdate(synthRequestCreate(2, resources.SYNTHESIZER_STOP))
+		}()
+		wg.Wait()
+
+		if terminals := h.terminals(); terminals[1] != 1 {
+			t.Fatalf("iteration %d: %d terminal messages of SPEAK", i, terminals[1])
+		}
+		h.mutex.Lock()
+		stopResponses := 0
+		for _, msg := range h.client {
+			if msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE &&
+				msg.StartLine.MethodId == int64(resources.SYNTHESIZER_STOP) {
+				stopResponses++
+			}
+		}
+		h.mutex.Unlock()
+		if stopResponses != 1 {
+			t.Fatalf("iteration %d: %d STOP responses", i, stopResponses)
+		}
+	}
+}
+
+func TestSynthDeactivate(t *testing.T) {
+	h := synthHarnessCreate()
+	h.speak(t, 1)
+	h.update(t, synthRequestCreate(2, resources.SYNTHESIZER_SPEAK))
+	deactivated := false
+	h.machine.OnDeactivate = func(machine *MRCPStateMachine) error {
+		deactivated = true
+		return nil
+	}
+	if err := h.machine.MRCPStateMachineDeactivate(); err != nil {
+		t.Fatal(err)
+	}
+	stops := h.engineRequests(resources.SYNTHESIZER_STOP)
+	if len(stops) != 1 || deactivated {
+		t.Fatalf("speaker is not stopped on deactivation")
+	}
+	h.update(t, synthResponseCreate(stops[0], message.MRCP_REQUEST_STATE_COMPLETE))
+	if !deactivated || h.machine.Active {
+		t.Fatalf("state machine is not deactivated")
+	}
+	if speaks := h.engineRequests(resources.SYNTHESIZER_SPEAK); len(speaks) != 1 {
+		t.Fatalf("queued SPEAK is started after deactivation")
+	}
+}
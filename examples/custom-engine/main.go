@@ -0,0 +1,90 @@
+// Command custom-engine embeds a custom synthesizer engine the way the server loads it:
+// the engine is registered to the engine factory and opened, then a channel of it processes
+// SPEAK requests, answering IN-PROGRESS and completing them with SPEAK-COMPLETE events.
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/navi-tt/go-mrcp/gomrcp"
+)
+
+/* Echo synthesizer: "speaks" the body of SPEAK requests by printing it */
+type echoSynth struct {
+	channel *gomrcp.MRCPEngineChannel
+}
+
+func (s *echoSynth) processRequest(channel *gomrcp.MRCPEngineChannel, request *gomrcp.MRCPMessage) error {
+	response := gomrcp.MRCPResponseCreate(request)
+	if request.StartLine.MethodId != int64(gomrcp.SYNTHESIZER_SPEAK) {
+		return channel.MRCPEngineChannelMessageSend(response)
+	}
+	response.StartLine.RequestState = gomrcp.MRCP_REQUEST_STATE_INPROGRESS
+	if err := channel.MRCPEngineChannelMessageSend(response); err != nil {
+		return err
+	}
+
+	fmt.Printf("speaking [%s]\n", request.Body)
+	event := gomrcp.MRCPEventCreate(request, int64(gomrcp.SYNTHESIZER_SPEAK_COMPLETE))
+	event.StartLine.RequestState = gomrcp.MRCP_REQUEST_STATE_COMPLETE
+	event.Header.ResourceHeaderAccessor.Data = &gomrcp.MRCPSynthHeader{CompletionCause: gomrcp.SYNTHESIZER_COMPLETION_CAUSE_NORMAL}
+	return channel.MRCPEngineChannelMessageSend(event)
+}
+
+func main() {
+	synth := &echoSynth{}
+	synthEngine := gomrcp.MRCPEngineCreate(gomrcp.MRCP_SYNTHESIZER_RESOURCE, synth, &gomrcp.MRCPEngineMethodVTable{
+		Open: func(e *gomrcp.MRCPEngine) error {
+			fmt.Printf("engine %s is open\n", e.Id)
+			return nil
+		},
+	})
+	synthEngine.Id = "echo-synth"
+	synthEngine.Config = gomrcp.MRCPEngineConfigAlloc()
+	synthEngine.CreateStateMachine = gomrcp.MRCPSynthStateMachineCreate
+
+	factory := gomrcp.MRCPEngineFactoryCreate()
+	defer gomrcp.MRCPEngineFactoryDestroy(factory)
+	if err := factory.MRCPEngineFactoryRegister(synthEngine); err != nil {
+		log.Fatal(err)
+	}
+	if err := factory.MRCPEngineFactoryOpen(); err != nil {
+		log.Fatal(err)
+	}
+
+	channel := synthEngine.MRCPEngineChannelCreate(&gomrcp.MRCPEngineChannelMethodVTable{
+		Open:           func(channel *gomrcp.MRCPEngineChannel) error { return nil },
+		Close:          func(channel *gomrcp.MRCPEngineChannel) error { return nil },
+		ProcessRequest: synth.processRequest,
+	}, synth, nil)
+	channel.Id = "channel-1"
+	channel.Version = gomrcp.MRCP_VERSION_2
+	channel.EventVTable = &gomrcp.MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *gomrcp.MRCPEngineChannel, msg *gomrcp.MRCPMessage) error {
+			fmt.Printf("%s: message type %d, method %d, request %d, state %d\n", channel.MRCPEngineChannelIdGet(),
+				msg.StartLine.MessageType, msg.StartLine.MethodId, msg.StartLine.RequestId, msg.StartLine.RequestState)
+			return nil
+		},
+	}
+	synth.channel = channel
+	if err := gomrcp.MRCPEngineChannelVirtualOpen(channel); err != nil {
+		log.Fatal(err)
+	}
+	defer gomrcp.MRCPEngineChannelVirtualClose(channel)
+
+	for id, text := range []string{"Hello world", "Goodbye"} {
+		request := gomrcp.MRCPMessageCreate()
+		request.StartLine = &gomrcp.MRCPStartLine{
+			MessageType: gomrcp.MRCP_MESSAGE_TYPE_REQUEST,
+			Version:     gomrcp.MRCP_VERSION_2,
+			RequestId:   gomrcp.MRCPRequestId(id + 1),
+			MethodName:  "SPEAK",
+			MethodId:    int64(gomrcp.SYNTHESIZER_SPEAK),
+		}
+		request.Body = text
+		if err := gomrcp.MRCPEngineChannelRequestProcess(channel, request); err != nil {
+			log.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Command mpf-bridge bridges two media endpoints by the standalone MPF:
+// the PCMU audio received from one endpoint is decoded and written to the other one as linear PCM.
+// The endpoints are simulated by audio streams, as RTP transport is out of the scope of the example.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mpf/codecs/g711"
+)
+
+/* Number of 10 msec frames to bridge */
+const frameCount = 100
+
+func main() {
+	manager := mpf.CodecManagerCreate(1)
+	pcmu := mpf.CodecG711UCreate()
+	if err := manager.CodecManagerCodecRegister(pcmu); err != nil {
+		log.Fatal(err)
+	}
+
+	/* endpoint A sends 400 Hz tone encoded by PCMU */
+	var sent int
+	source := mpf.AudioStreamCreate(nil, &mpf.AudioStreamVTable{
+		ReadFrame: func(stream *mpf.AudioStream, frame *mpf.Frame) error {
+			payload := make([]byte, 80)
+			for i := range payload {
+				sample := 8000 * math.Sin(2*math.Pi*400*float64(sent*80+i)/8000)
+				payload[i] = g711.EncodeUlawFrame(int16(sample))
+			}
+			sent++
+			frame.Type = mpf.MEDIA_FRAME_TYPE_AUDIO
+			frame.CodecFrame.Buffer.Reset()
+			frame.CodecFrame.Buffer.Write(payload)
+			return nil
+		},
+	}, mpf.SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = mpf.CodecDescriptorClone(pcmu.StaticDescriptor)
+
+	/* endpoint B receives linear audio */
+	var received bytes.Buffer
+	sink := mpf.AudioStreamCreate(nil, &mpf.AudioStreamVTable{
+		WriteFrame: func(stream *mpf.AudioStream, frame *mpf.Frame) error {
+			received.Write(frame.CodecFrame.Buffer.Bytes())
+			return nil
+		},
+	}, mpf.SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = mpf.CodecLPcmDescriptorCreate(8000, 1)
+
+	bridge, err := mpf.BridgeCreate(source, sink, manager, "bridge")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer mpf.ObjectDestroy(bridge)
+
+	factory := mpf.ContextFactoryCreate()
+	context := factory.ContextCreate("bridge", nil, 2)
+	if err := context.ContextObjectAdd(bridge); err != nil {
+		log.Fatal(err)
+	}
+	for i := 0; i < frameCount; i++ {
+		if err := context.ContextProcess(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fmt.Printf("bridged %d frames: %d bytes of %s sent, %d bytes of %s received\n",
+		frameCount, sent*80, source.RXDescriptor.Name, received.Len(), sink.TXDescriptor.Name)
+}
@@ -0,0 +1,98 @@
+// Command speak-and-recognize plays a prompt and recognizes the answer the way a client embedding
+// the stack does: requests are built by the message API, redundant DEFINE-GRAMMAR round trips are
+// skipped by the client grammar cache and SPEAK is tracked by the synthesizer state machine.
+// The server side is simulated in-process, as signaling (SIP/RTSP) is out of the scope of the example.
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/navi-tt/go-mrcp/engine"
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/client"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+const grammar = `<grammar xmlns="http://www.w3.org/2001/06/grammar" root="answer">
+  <rule id="answer"><one-of><item>yes</item><item>no</item></one-of></rule>
+</grammar>`
+
+var requestId mrcp.MRCPRequestId
+
+func requestCreate(methodName string, methodId int64, body string) *message.MRCPMessage {
+	requestId++
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{
+		MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     mrcp.MRCP_VERSION_2,
+		RequestId:   requestId,
+		MethodName:  methodName,
+		MethodId:    methodId,
+	}
+	request.Body = body
+	return request
+}
+
+/* Simulated recognizer: DEFINE-GRAMMAR succeeds, RECOGNIZE completes with "yes" */
+func recognizerSend(request *message.MRCPMessage) []*message.MRCPMessage {
+	response := message.MRCPResponseCreate(request)
+	if request.StartLine.MethodId != int64(resources.RECOGNIZER_RECOGNIZE) {
+		return []*message.MRCPMessage{response}
+	}
+	response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+	complete := message.MRCPEventCreate(request, int64(resources.RECOGNIZER_RECOGNITION_COMPLETE))
+	complete.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+	complete.Body = `<result><interpretation confidence="0.92"><instance>yes</instance><input mode="speech">yes</input></interpretation></result>`
+	return []*message.MRCPMessage{response, complete}
+}
+
+func main() {
+	/* speak the prompt, the synthesizer state machine tracks the request to its completion */
+	synth := engine.MRCPSynthStateMachineCreate(nil, mrcp.MRCP_VERSION_2)
+	synth.OnDispatch = func(machine *engine.MRCPStateMachine, msg *message.MRCPMessage) error {
+		if msg.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_REQUEST {
+			fmt.Printf("synthesizer: message type %d of request %d, state %d\n", msg.StartLine.MessageType, msg.StartLine.RequestId, msg.StartLine.RequestState)
+			return nil
+		}
+		/* the simulated engine speaks the prompt at once */
+		response := message.MRCPResponseCreate(msg)
+		response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+		complete := message.MRCPEventCreate(msg, int64(resources.SYNTHESIZER_SPEAK_COMPLETE))
+		complete.StartLine.RequestState = message.MRCP_REQUEST_STATE_COMPLETE
+		complete.Header.ResourceHeaderAccessor.Data = &resources.MRCPSynthHeader{CompletionCause: resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL}
+		for _, reply := range []*message.MRCPMessage{response, complete} {
+			if err := machine.MRCPStateMachineUpdate(reply); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := synth.MRCPStateMachineUpdate(requestCreate("SPEAK", int64(resources.SYNTHESIZER_SPEAK), "Do you want to continue?")); err != nil {
+		log.Fatal(err)
+	}
+
+	/* define the grammar twice (e.g. per dialog turn), the second definition is answered from the cache */
+	cache := client.MRCPClientGrammarCacheCreate()
+	for turn := 0; turn < 2; turn++ {
+		define := requestCreate(client.MRCP_DEFINE_GRAMMAR_METHOD_NAME, int64(resources.RECOGNIZER_DEFINE_GRAMMAR), grammar)
+		genericHeader := define.MRCPGenericHeaderPrepare()
+		genericHeader.ContentType = "application/srgs+xml"
+		genericHeader.ContentId = "answer@form-level"
+		if response := cache.GrammarCacheCheck(define); response != nil {
+			fmt.Printf("recognizer: grammar of request %d is defined already, round trip skipped\n", define.StartLine.RequestId)
+			continue
+		}
+		response := recognizerSend(define)[0]
+		cache.GrammarCacheUpdate(define, response)
+		fmt.Printf("recognizer: grammar of request %d is defined, status %d\n", define.StartLine.RequestId, response.StartLine.StatusCode)
+	}
+
+	recognize := requestCreate("RECOGNIZE", int64(resources.RECOGNIZER_RECOGNIZE), "session:answer@form-level")
+	for _, msg := range recognizerSend(recognize) {
+		if msg.StartLine.MessageType == message.MRCP_MESSAGE_TYPE_EVENT {
+			fmt.Printf("recognizer: recognition of request %d is complete: %s\n", msg.StartLine.RequestId, msg.Body)
+		}
+	}
+}
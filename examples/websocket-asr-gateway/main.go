@@ -0,0 +1,139 @@
+// Command websocket-asr-gateway accepts audio streamed over WebSocket and feeds it to a recognizer:
+// each connection is attached by a WebSocket termination, the frames read from its stream are passed
+// to the recognizer until the peer closes the connection. The recognizer is simulated by the voice
+// activity detector of MPF and the peer (e.g. a browser) is simulated in-process.
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/utils/websocketx"
+)
+
+/* Duration of a frame of linear PCM 8 kHz mono, in msec */
+const frameDuration = 10
+
+/* Simulated recognizer reporting the start and the end of input by voice activity */
+type recognizer struct {
+	detector *mpf.ActivityDetector
+	elapsed  int
+	started  int
+}
+
+func (r *recognizer) frameProcess(frame *mpf.Frame) error {
+	event, err := r.detector.ActivityDetectorProcess(frame)
+	if err != nil {
+		return err
+	}
+	r.elapsed += frameDuration
+	switch event {
+	case mpf.MPF_DETECTOR_EVENT_ACTIVITY:
+		r.started = r.elapsed
+		fmt.Printf("%5d ms: START-OF-INPUT\n", r.elapsed)
+	case mpf.MPF_DETECTOR_EVENT_INACTIVITY:
+		fmt.Printf("%5d ms: RECOGNITION-COMPLETE, input of %d ms\n", r.elapsed, r.elapsed-r.started)
+	}
+	return nil
+}
+
+/* Gateway handler attaching WebSocket connection to recognizer */
+func gatewayServe(w http.ResponseWriter, req *http.Request, done chan<- error) {
+	conn, err := websocketx.Upgrade(w, req)
+	if err != nil {
+		done <- err
+		return
+	}
+	termination, err := mpf.WebSocketTerminationCreate(conn, mpf.CodecLPcmDescriptorCreate(8000, 1))
+	if err != nil {
+		conn.Close()
+		done <- err
+		return
+	}
+	completed := false
+	termination.EventHandler = func(termination *mpf.Termination, eventId int, descriptor interface{}) error {
+		if eventId == mpf.AUDIO_FILE_COMPLETE_EVENT {
+			completed = true
+		}
+		return nil
+	}
+	stream := termination.TerminationAudioStreamGet()
+	defer mpf.AudioStreamDestroy(stream)
+
+	r := &recognizer{detector: mpf.ActivityDetectorCreate()}
+	ticker := time.NewTicker(frameDuration * time.Millisecond)
+	defer ticker.Stop()
+	for !completed {
+		<-ticker.C
+		frame := &mpf.Frame{}
+		if err := stream.AudioStreamFrameRead(frame); err != nil {
+			done <- err
+			return
+		}
+		if (frame.Type & mpf.MEDIA_FRAME_TYPE_AUDIO) != mpf.MEDIA_FRAME_TYPE_AUDIO {
+			continue
+		}
+		if err := r.frameProcess(frame); err != nil {
+			done <- err
+			return
+		}
+	}
+	fmt.Printf("%5d ms: audio completed by the peer\n", r.elapsed)
+	done <- mpf.IOStreamErrorGet(stream)
+}
+
+/* Create linear PCM of tone (or silence if amplitude is 0) of the duration in msec */
+func audioCreate(amplitude float64, duration int) []byte {
+	samples := 8 * duration
+	audio := make([]byte, 2*samples)
+	for i := 0; i < samples; i++ {
+		sample := amplitude * math.Sin(2*math.Pi*400*float64(i)/8000)
+		binary.LittleEndian.PutUint16(audio[2*i:], uint16(int16(sample)))
+	}
+	return audio
+}
+
+func main() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatal(err)
+	}
+	done := make(chan error, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		gatewayServe(w, req, done)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	/* the peer sends silence, speech and silence in messages of 100 msec, then closes */
+	client, err := websocketx.Dial("ws://"+listener.Addr().String()+"/asr", nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var audio []byte
+	audio = append(audio, audioCreate(0, 500)...)
+	audio = append(audio, audioCreate(8000, 1000)...)
+	audio = append(audio, audioCreate(0, 800)...)
+	for len(audio) > 0 {
+		n := 1600
+		if n > len(audio) {
+			n = len(audio)
+		}
+		if err := client.WriteMessage(websocketx.OP_BINARY, audio[:n]); err != nil {
+			log.Fatal(err)
+		}
+		audio = audio[n:]
+	}
+	if err := client.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,29 @@
+package gomrcp
+
+import (
+	"github.com/navi-tt/go-mrcp/mrcp/client"
+	"github.com/navi-tt/go-mrcp/mrcp/session"
+)
+
+/** Client side: sessions and grammar cache */
+type (
+	MRCPSession            = session.MRCPSession
+	MRCPClientGrammarCache = client.MRCPClientGrammarCache
+)
+
+/** Name of DEFINE-GRAMMAR method */
+const MRCP_DEFINE_GRAMMAR_METHOD_NAME = client.MRCP_DEFINE_GRAMMAR_METHOD_NAME
+
+/**
+ * Create MRCP session.
+ * @param id the session identifier
+ * @param obj the external object of the session
+ */
+func MRCPSessionCreate(id string, obj interface{}) *MRCPSession {
+	return session.MRCPSessionCreate(id, obj)
+}
+
+/** Create cache of grammars defined by the client, skipping redundant DEFINE-GRAMMAR requests */
+func MRCPClientGrammarCacheCreate() *MRCPClientGrammarCache {
+	return client.MRCPClientGrammarCacheCreate()
+}
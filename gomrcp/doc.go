@@ -0,0 +1,24 @@
+/*
+Package gomrcp is the stable public API of go-mrcp.
+
+It re-exports the supported entry points of the client, server (engine) and media (MPF) layers,
+so that applications depend on this package only and are not coupled to the internal packages
+(apr, toolkit, utils and the internals of mrcp, engine and mpf), which may be refactored or removed.
+
+# Compatibility
+
+The API is versioned by semantic versioning (see VERSION):
+
+  - Within major version 1 the identifiers exported by this package are neither removed
+    nor changed incompatibly (signatures, meaning of constants, behavior documented).
+  - Minor versions add identifiers, patch versions fix bugs only.
+  - Types are aliases of the types of the underlying packages, so values may be passed
+    to the underlying packages, while code using the aliases keeps compiling as long as
+    the major version is the same.
+  - Fields and methods of the aliased types are covered to the extent they are used by the
+    examples, other fields and methods are not guaranteed to be stable.
+
+Identifiers of the underlying packages not re-exported here are internal
+and may change in any release.
+*/
+package gomrcp
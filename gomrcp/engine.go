@@ -0,0 +1,81 @@
+package gomrcp
+
+import (
+	"github.com/navi-tt/go-mrcp/engine"
+)
+
+/** Server side: resource engines and their channels */
+type (
+	MRCPEngine                    = engine.MRCPEngine
+	MRCPEngineConfig              = engine.MRCPEngineConfig
+	MRCPEngineFactory             = engine.MRCPEngineFactory
+	MRCPEngineMethodVTable        = engine.MRCPEngineMethodVTable
+	MRCPEngineChannel             = engine.MRCPEngineChannel
+	MRCPEngineChannelMethodVTable = engine.MRCPEngineChannelMethodVTable
+	MRCPEngineChannelEventVTable  = engine.MRCPEngineChannelEventVTable
+	MRCPStateMachine              = engine.MRCPStateMachine
+)
+
+/**
+ * Create resource engine.
+ * @param resourceId the resource identifier
+ * @param obj the external object of the engine
+ * @param vtable the methods of the engine
+ */
+func MRCPEngineCreate(resourceId MRCPResourceId, obj interface{}, vtable *MRCPEngineMethodVTable) *MRCPEngine {
+	return engine.MRCPEngineCreate(resourceId, obj, vtable)
+}
+
+/** Allocate engine config */
+func MRCPEngineConfigAlloc() *MRCPEngineConfig {
+	return engine.MRCPEngineConfigAlloc()
+}
+
+/** Create factory of engines */
+func MRCPEngineFactoryCreate() *MRCPEngineFactory {
+	return engine.MRCPEngineFactoryCreate()
+}
+
+/** Destroy factory of engines */
+func MRCPEngineFactoryDestroy(factory *MRCPEngineFactory) error {
+	return engine.MRCPEngineFactoryDestroy(factory)
+}
+
+/** Open engine channel not backed by a session (e.g. embedded or test use) */
+func MRCPEngineChannelVirtualOpen(channel *MRCPEngineChannel) error {
+	return engine.MRCPEngineChannelVirtualOpen(channel)
+}
+
+/** Close engine channel opened virtually */
+func MRCPEngineChannelVirtualClose(channel *MRCPEngineChannel) error {
+	return engine.MRCPEngineChannelVirtualClose(channel)
+}
+
+/**
+ * Process request by engine channel.
+ * @param channel the channel
+ * @param request the request
+ */
+func MRCPEngineChannelRequestProcess(channel *MRCPEngineChannel, request *MRCPMessage) error {
+	return engine.MRCPEngineChannelRequestProcess(channel, request)
+}
+
+/** Create state machine of synthesizer */
+func MRCPSynthStateMachineCreate(obj interface{}, version Version) *MRCPStateMachine {
+	return engine.MRCPSynthStateMachineCreate(obj, version)
+}
+
+/** Create state machine of recognizer */
+func MRCPRecognizerStateMachineCreate(obj interface{}, version Version) *MRCPStateMachine {
+	return engine.MRCPRecognizerStateMachineCreate(obj, version)
+}
+
+/** Create state machine of recorder */
+func MRCPRecorderStateMachineCreate(obj interface{}, version Version) *MRCPStateMachine {
+	return engine.MRCPRecorderStateMachineCreate(obj, version)
+}
+
+/** Create state machine of verifier */
+func MRCPVerifierStateMachineCreate(obj interface{}, version Version) *MRCPStateMachine {
+	return engine.MRCPVerifierStateMachineCreate(obj, version)
+}
@@ -0,0 +1,166 @@
+package gomrcp
+
+import (
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+)
+
+/** Version of the public API (semantic versioning) */
+const (
+	VERSION_MAJOR = 1
+	VERSION_MINOR = 0
+	VERSION_PATCH = 0
+
+	VERSION = "1.0.0"
+)
+
+/** MRCP protocol version */
+type Version = mrcp.Version
+
+const (
+	MRCP_VERSION_1 = mrcp.MRCP_VERSION_1
+	MRCP_VERSION_2 = mrcp.MRCP_VERSION_2
+)
+
+/** MRCP resource types */
+type MRCPResourceId = mrcp.MRCPResourceId
+
+const (
+	MRCP_SYNTHESIZER_RESOURCE = mrcp.MRCP_SYNTHESIZER_RESOURCE
+	MRCP_RECOGNIZER_RESOURCE  = mrcp.MRCP_RECOGNIZER_RESOURCE
+	MRCP_RECORDER_RESOURCE    = mrcp.MRCP_RECORDER_RESOURCE
+	MRCP_VERIFIER_RESOURCE    = mrcp.MRCP_VERIFIER_RESOURCE
+)
+
+/** MRCP request and method identifiers */
+type (
+	MRCPRequestId = mrcp.MRCPRequestId
+	MRCPMethodId  = mrcp.MRCPMethodId
+)
+
+/** MRCP message */
+type (
+	MRCPMessage      = message.MRCPMessage
+	MRCPStartLine    = message.MRCPStartLine
+	MRCPMessageType  = message.MRCPMessageType
+	MRCPRequestState = message.MRCPRequestState
+	MRCPStatusCode   = message.MRCPStatusCode
+)
+
+const (
+	MRCP_MESSAGE_TYPE_REQUEST  = message.MRCP_MESSAGE_TYPE_REQUEST
+	MRCP_MESSAGE_TYPE_RESPONSE = message.MRCP_MESSAGE_TYPE_RESPONSE
+	MRCP_MESSAGE_TYPE_EVENT    = message.MRCP_MESSAGE_TYPE_EVENT
+
+	MRCP_REQUEST_STATE_COMPLETE   = message.MRCP_REQUEST_STATE_COMPLETE
+	MRCP_REQUEST_STATE_INPROGRESS = message.MRCP_REQUEST_STATE_INPROGRESS
+	MRCP_REQUEST_STATE_PENDING    = message.MRCP_REQUEST_STATE_PENDING
+
+	MRCP_STATUS_CODE_SUCCESS                   = message.MRCP_STATUS_CODE_SUCCESS
+	MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE       = message.MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE
+	MRCP_STATUS_CODE_METHOD_NOT_ALLOWED        = message.MRCP_STATUS_CODE_METHOD_NOT_ALLOWED
+	MRCP_STATUS_CODE_METHOD_NOT_VALID          = message.MRCP_STATUS_CODE_METHOD_NOT_VALID
+	MRCP_STATUS_CODE_UNSUPPORTED_PARAM         = message.MRCP_STATUS_CODE_UNSUPPORTED_PARAM
+	MRCP_STATUS_CODE_ILLEGAL_PARAM_VALUE       = message.MRCP_STATUS_CODE_ILLEGAL_PARAM_VALUE
+	MRCP_STATUS_CODE_NOT_FOUND                 = message.MRCP_STATUS_CODE_NOT_FOUND
+	MRCP_STATUS_CODE_MISSING_PARAM             = message.MRCP_STATUS_CODE_MISSING_PARAM
+	MRCP_STATUS_CODE_METHOD_FAILED             = message.MRCP_STATUS_CODE_METHOD_FAILED
+	MRCP_STATUS_CODE_UNRECOGNIZED_MESSAGE      = message.MRCP_STATUS_CODE_UNRECOGNIZED_MESSAGE
+	MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE   = message.MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE
+	MRCP_STATUS_CODE_OUT_OF_ORDER              = message.MRCP_STATUS_CODE_OUT_OF_ORDER
+	MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE = message.MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE
+)
+
+/** Create MRCP message */
+func MRCPMessageCreate() *MRCPMessage {
+	return message.MRCPMessageCreate()
+}
+
+/**
+ * Create MRCP response to the request.
+ * @param request the request to create response to
+ */
+func MRCPResponseCreate(request *MRCPMessage) *MRCPMessage {
+	return message.MRCPResponseCreate(request)
+}
+
+/**
+ * Create MRCP event of the request.
+ * @param request the request to create event of
+ * @param eventId the event identifier of the resource
+ */
+func MRCPEventCreate(request *MRCPMessage, eventId MRCPMethodId) *MRCPMessage {
+	return message.MRCPEventCreate(request, eventId)
+}
+
+/** Resource headers */
+type (
+	MRCPSynthHeader      = resources.MRCPSynthHeader
+	MRCPRecognizerHeader = resources.MRCPRecognizerHeader
+)
+
+/** Synthesizer methods and events */
+const (
+	SYNTHESIZER_SET_PARAMS        = resources.SYNTHESIZER_SET_PARAMS
+	SYNTHESIZER_GET_PARAMS        = resources.SYNTHESIZER_GET_PARAMS
+	SYNTHESIZER_SPEAK             = resources.SYNTHESIZER_SPEAK
+	SYNTHESIZER_STOP              = resources.SYNTHESIZER_STOP
+	SYNTHESIZER_PAUSE             = resources.SYNTHESIZER_PAUSE
+	SYNTHESIZER_RESUME            = resources.SYNTHESIZER_RESUME
+	SYNTHESIZER_BARGE_IN_OCCURRED = resources.SYNTHESIZER_BARGE_IN_OCCURRED
+	SYNTHESIZER_CONTROL           = resources.SYNTHESIZER_CONTROL
+	SYNTHESIZER_DEFINE_LEXICON    = resources.SYNTHESIZER_DEFINE_LEXICON
+
+	SYNTHESIZER_SPEECH_MARKER  = resources.SYNTHESIZER_SPEECH_MARKER
+	SYNTHESIZER_SPEAK_COMPLETE = resources.SYNTHESIZER_SPEAK_COMPLETE
+)
+
+/** Recognizer methods and events */
+const (
+	RECOGNIZER_SET_PARAMS         = resources.RECOGNIZER_SET_PARAMS
+	RECOGNIZER_GET_PARAMS         = resources.RECOGNIZER_GET_PARAMS
+	RECOGNIZER_DEFINE_GRAMMAR     = resources.RECOGNIZER_DEFINE_GRAMMAR
+	RECOGNIZER_RECOGNIZE          = resources.RECOGNIZER_RECOGNIZE
+	RECOGNIZER_INTERPRET          = resources.RECOGNIZER_INTERPRET
+	RECOGNIZER_GET_RESULT         = resources.RECOGNIZER_GET_RESULT
+	RECOGNIZER_START_INPUT_TIMERS = resources.RECOGNIZER_START_INPUT_TIMERS
+	RECOGNIZER_STOP               = resources.RECOGNIZER_STOP
+
+	RECOGNIZER_START_OF_INPUT          = resources.RECOGNIZER_START_OF_INPUT
+	RECOGNIZER_RECOGNITION_COMPLETE    = resources.RECOGNIZER_RECOGNITION_COMPLETE
+	RECOGNIZER_INTERPRETATION_COMPLETE = resources.RECOGNIZER_INTERPRETATION_COMPLETE
+)
+
+/** Synthesizer completion causes (SPEAK-COMPLETE) */
+const (
+	SYNTHESIZER_COMPLETION_CAUSE_NORMAL               = resources.SYNTHESIZER_COMPLETION_CAUSE_NORMAL
+	SYNTHESIZER_COMPLETION_CAUSE_BARGE_IN             = resources.SYNTHESIZER_COMPLETION_CAUSE_BARGE_IN
+	SYNTHESIZER_COMPLETION_CAUSE_PARSE_FAILURE        = resources.SYNTHESIZER_COMPLETION_CAUSE_PARSE_FAILURE
+	SYNTHESIZER_COMPLETION_CAUSE_URI_FAILURE          = resources.SYNTHESIZER_COMPLETION_CAUSE_URI_FAILURE
+	SYNTHESIZER_COMPLETION_CAUSE_ERROR                = resources.SYNTHESIZER_COMPLETION_CAUSE_ERROR
+	SYNTHESIZER_COMPLETION_CAUSE_LANGUAGE_UNSUPPORTED = resources.SYNTHESIZER_COMPLETION_CAUSE_LANGUAGE_UNSUPPORTED
+	SYNTHESIZER_COMPLETION_CAUSE_LEXICON_LOAD_FAILURE = resources.SYNTHESIZER_COMPLETION_CAUSE_LEXICON_LOAD_FAILURE
+	SYNTHESIZER_COMPLETION_CAUSE_CANCELLED            = resources.SYNTHESIZER_COMPLETION_CAUSE_CANCELLED
+)
+
+/** Recognizer completion causes (RECOGNITION-COMPLETE) */
+const (
+	RECOGNIZER_COMPLETION_CAUSE_SUCCESS                 = resources.RECOGNIZER_COMPLETION_CAUSE_SUCCESS
+	RECOGNIZER_COMPLETION_CAUSE_NO_MATCH                = resources.RECOGNIZER_COMPLETION_CAUSE_NO_MATCH
+	RECOGNIZER_COMPLETION_CAUSE_NO_INPUT_TIMEOUT        = resources.RECOGNIZER_COMPLETION_CAUSE_NO_INPUT_TIMEOUT
+	RECOGNIZER_COMPLETION_CAUSE_RECOGNITION_TIMEOUT     = resources.RECOGNIZER_COMPLETION_CAUSE_RECOGNITION_TIMEOUT
+	RECOGNIZER_COMPLETION_CAUSE_GRAM_LOAD_FAILURE       = resources.RECOGNIZER_COMPLETION_CAUSE_GRAM_LOAD_FAILURE
+	RECOGNIZER_COMPLETION_CAUSE_GRAM_COMP_FAILURE       = resources.RECOGNIZER_COMPLETION_CAUSE_GRAM_COMP_FAILURE
+	RECOGNIZER_COMPLETION_CAUSE_ERROR                   = resources.RECOGNIZER_COMPLETION_CAUSE_ERROR
+	RECOGNIZER_COMPLETION_CAUSE_SPEECH_TOO_EARLY        = resources.RECOGNIZER_COMPLETION_CAUSE_SPEECH_TOO_EARLY
+	RECOGNIZER_COMPLETION_CAUSE_TOO_MUCH_SPEECH_TIMEOUT = resources.RECOGNIZER_COMPLETION_CAUSE_TOO_MUCH_SPEECH_TIMEOUT
+	RECOGNIZER_COMPLETION_CAUSE_URI_FAILURE             = resources.RECOGNIZER_COMPLETION_CAUSE_URI_FAILURE
+	RECOGNIZER_COMPLETION_CAUSE_LANGUAGE_UNSUPPORTED    = resources.RECOGNIZER_COMPLETION_CAUSE_LANGUAGE_UNSUPPORTED
+	RECOGNIZER_COMPLETION_CAUSE_CANCELLED               = resources.RECOGNIZER_COMPLETION_CAUSE_CANCELLED
+	RECOGNIZER_COMPLETION_CAUSE_SEMANTICS_FAILURE       = resources.RECOGNIZER_COMPLETION_CAUSE_SEMANTICS_FAILURE
+	RECOGNIZER_COMPLETION_CAUSE_PARTIAL_MATCH           = resources.RECOGNIZER_COMPLETION_CAUSE_PARTIAL_MATCH
+	RECOGNIZER_COMPLETION_CAUSE_PARTIAL_MATCH_MAXTIME   = resources.RECOGNIZER_COMPLETION_CAUSE_PARTIAL_MATCH_MAXTIME
+	RECOGNIZER_COMPLETION_CAUSE_NO_MATCH_MAXTIME        = resources.RECOGNIZER_COMPLETION_CAUSE_NO_MATCH_MAXTIME
+	RECOGNIZER_COMPLETION_CAUSE_GRAM_DEFINITION_FAILURE = resources.RECOGNIZER_COMPLETION_CAUSE_GRAM_DEFINITION_FAILURE
+)
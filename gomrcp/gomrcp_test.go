@@ -0,0 +1,164 @@
+package gomrcp
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestVersion(t *testing.T) {
+	if version := fmt.Sprintf("%d.%d.%d", VERSION_MAJOR, VERSION_MINOR, VERSION_PATCH); version != VERSION {
+		t.Fatalf("version %s of %s", VERSION, version)
+	}
+}
+
+/* Synthesizer answering SPEAK IN-PROGRESS and completing it, the way the custom-engine example does */
+func gomrcpTestProcessRequest(channel *MRCPEngineChannel, request *MRCPMessage) error {
+	response := MRCPResponseCreate(request)
+	if request.StartLine.MethodId != int64(SYNTHESIZER_SPEAK) {
+		return channel.MRCPEngineChannelMessageSend(response)
+	}
+	response.StartLine.RequestState = MRCP_REQUEST_STATE_INPROGRESS
+	if err := channel.MRCPEngineChannelMessageSend(response); err != nil {
+		return err
+	}
+	event := MRCPEventCreate(request, int64(SYNTHESIZER_SPEAK_COMPLETE))
+	event.StartLine.RequestState = MRCP_REQUEST_STATE_COMPLETE
+	event.Header.ResourceHeaderAccessor.Data = &MRCPSynthHeader{CompletionCause: SYNTHESIZER_COMPLETION_CAUSE_NORMAL}
+	return channel.MRCPEngineChannelMessageSend(event)
+}
+
+func TestEngineChannel(t *testing.T) {
+	opened := 0
+	synthEngine := MRCPEngineCreate(MRCP_SYNTHESIZER_RESOURCE, nil, &MRCPEngineMethodVTable{
+		Open: func(e *MRCPEngine) error {
+			opened++
+			return nil
+		},
+	})
+	synthEngine.Id = "synth"
+	synthEngine.Config = MRCPEngineConfigAlloc()
+	synthEngine.CreateStateMachine = MRCPSynthStateMachineCreate
+
+	factory := MRCPEngineFactoryCreate()
+	defer MRCPEngineFactoryDestroy(factory)
+	if err := factory.MRCPEngineFactoryRegister(synthEngine); err != nil {
+		t.Fatal(err)
+	}
+	if err := factory.MRCPEngineFactoryOpen(); err != nil || opened != 1 || !synthEngine.IsOpen {
+		t.Fatalf("opened %d: %v", opened, err)
+	}
+
+	var messages []*MRCPMessage
+	closed := false
+	channel := synthEngine.MRCPEngineChannelCreate(&MRCPEngineChannelMethodVTable{
+		Open: func(channel *MRCPEngineChannel) error { return nil },
+		Close: func(channel *MRCPEngineChannel) error {
+			closed = true
+			return nil
+		},
+		ProcessRequest: gomrcpTestProcessRequest,
+	}, nil, nil)
+	channel.Id = "channel-1"
+	channel.Version = MRCP_VERSION_2
+	channel.EventVTable = &MRCPEngineChannelEventVTable{
+		OnMessage: func(channel *MRCPEngineChannel, msg *MRCPMessage) error {
+			messages = append(messages, msg)
+			return nil
+		},
+	}
+	if err := MRCPEngineChannelVirtualOpen(channel); err != nil {
+		t.Fatal(err)
+	}
+
+	request := MRCPMessageCreate()
+	request.StartLine = &MRCPStartLine{
+		MessageType: MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     MRCP_VERSION_2,
+		RequestId:   MRCPRequestId(1),
+		MethodName:  "SPEAK",
+		MethodId:    int64(SYNTHESIZER_SPEAK),
+	}
+	request.Body = "Hello world"
+	if err := MRCPEngineChannelRequestProcess(channel, request); err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("%d messages sent", len(messages))
+	}
+	if start := messages[0].StartLine; start.MessageType != MRCP_MESSAGE_TYPE_RESPONSE || start.RequestId != 1 ||
+		start.StatusCode != MRCP_STATUS_CODE_SUCCESS || start.RequestState != MRCP_REQUEST_STATE_INPROGRESS {
+		t.Fatalf("response %+v", start)
+	}
+	event := messages[1]
+	if start := event.StartLine; start.MessageType != MRCP_MESSAGE_TYPE_EVENT || start.MethodId != int64(SYNTHESIZER_SPEAK_COMPLETE) ||
+		start.RequestState != MRCP_REQUEST_STATE_COMPLETE {
+		t.Fatalf("event %+v", start)
+	}
+	if synthHeader, ok := event.Header.ResourceHeaderAccessor.Data.(*MRCPSynthHeader); !ok || synthHeader.CompletionCause != SYNTHESIZER_COMPLETION_CAUSE_NORMAL {
+		t.Fatalf("event header %+v", event.Header.ResourceHeaderAccessor.Data)
+	}
+
+	if err := MRCPEngineChannelVirtualClose(channel); err != nil || !closed {
+		t.Fatalf("channel is not closed: %v", err)
+	}
+}
+
+func TestClientGrammarCache(t *testing.T) {
+	cache := MRCPClientGrammarCacheCreate()
+	session := MRCPSessionCreate("session-1", nil)
+	request := MRCPMessageCreate()
+	request.StartLine = &MRCPStartLine{MessageType: MRCP_MESSAGE_TYPE_REQUEST, Version: MRCP_VERSION_2, RequestId: 1, MethodName: MRCP_DEFINE_GRAMMAR_METHOD_NAME}
+	request.ChannelId.SessionId = session.Id
+	request.MRCPGenericHeaderPrepare().ContentId = "yesno"
+	request.MRCPGenericHeaderGet().ContentType = "application/srgs+xml"
+	request.Body = `<grammar root="yesno"/>`
+	if cache.GrammarCacheCheck(request) != nil {
+		t.Fatal("grammar not defined is hit")
+	}
+	cache.GrammarCacheUpdate(request, MRCPResponseCreate(request))
+	if response := cache.GrammarCacheCheck(request); response == nil || response.StartLine.StatusCode != MRCP_STATUS_CODE_SUCCESS {
+		t.Fatalf("response to redundant definition %+v", response)
+	}
+	cache.GrammarCacheSessionRemove(session.Id)
+	if cache.GrammarCacheCheck(request) != nil {
+		t.Fatal("grammar of terminated session is hit")
+	}
+}
+
+func TestToneTermination(t *testing.T) {
+	if _, err := ToneParse("440/", 1); err == nil {
+		t.Fatal("invalid tone parsed")
+	}
+	tone, err := ToneParse("440+480/100", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	termination, err := ToneTerminationCreate(tone, CodecLPcmDescriptorCreate(8000, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed := false
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		completed = eventId == AUDIO_FILE_COMPLETE_EVENT
+		return nil
+	}
+
+	/* the tone is played by frames of the time base until complete */
+	stream := termination.TerminationAudioStreamGet()
+	frames := 0
+	for i := 0; i < 100 && !completed; i++ {
+		frame := &Frame{}
+		if err := stream.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == MEDIA_FRAME_TYPE_AUDIO {
+			frames++
+			if size := frame.CodecFrame.Buffer.Len(); size != 2*8*CODEC_FRAME_TIME_BASE {
+				t.Fatalf("frame of %d bytes", size)
+			}
+		}
+	}
+	if !completed || frames != 100/CODEC_FRAME_TIME_BASE {
+		t.Fatalf("completed %t by %d frames", completed, frames)
+	}
+}
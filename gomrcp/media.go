@@ -0,0 +1,317 @@
+package gomrcp
+
+import (
+	"log"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+)
+
+/** Media processing framework (MPF) */
+type (
+	MediaEngine         = mpf.Engine
+	Context             = mpf.Context
+	ContextFactory      = mpf.ContextFactory
+	Termination         = mpf.Termination
+	Object              = mpf.Object
+	AudioStream         = mpf.AudioStream
+	AudioStreamVTable   = mpf.AudioStreamVTable
+	VideoStream         = mpf.VideoStream
+	VideoStreamVTable   = mpf.VideoStreamVTable
+	StreamCapabilities  = mpf.StreamCapabilities
+	Frame               = mpf.Frame
+	Codec               = mpf.Codec
+	CodecDescriptor     = mpf.CodecDescriptor
+	CodecManager        = mpf.CodecManager
+	Tone                = mpf.Tone
+	TonePattern         = mpf.TonePattern
+	FrameBuffer         = mpf.FrameBuffer
+	Agc                 = mpf.Agc
+	AgcConfig           = mpf.AgcConfig
+	FrameFilter         = mpf.FrameFilter
+	FrameFilterFunc     = mpf.FrameFilterFunc
+	FrameFilterChain    = mpf.FrameFilterChain
+	NoiseSuppressor     = mpf.NoiseSuppressor
+	Gain                = mpf.Gain
+	Mixer               = mpf.Mixer
+	Multiplier          = mpf.Multiplier
+	Conference          = mpf.Conference
+	ConferenceMember    = mpf.ConferenceMember
+	StreamMeterStat     = mpf.StreamMeterStat
+	StreamTraceRecord   = mpf.StreamTraceRecord
+	StreamTraceProc     = mpf.StreamTraceProc
+	WavStreamTracer     = mpf.WavStreamTracer
+	JitterBufferReader  = mpf.JitterBufferReader
+	PushSource          = mpf.PushSource
+	PullSink            = mpf.PullSink
+	PulledFrame         = mpf.PulledFrame
+	RecordingConfig     = mpf.RecordingConfig
+	RecordingFile       = mpf.RecordingFile
+	AudioFileCompletion = mpf.AudioFileCompletion
+)
+
+const (
+	MEDIA_FRAME_TYPE_NONE  = mpf.MEDIA_FRAME_TYPE_NONE
+	MEDIA_FRAME_TYPE_AUDIO = mpf.MEDIA_FRAME_TYPE_AUDIO
+	MEDIA_FRAME_TYPE_EVENT = mpf.MEDIA_FRAME_TYPE_EVENT
+
+	/** Codec frame time base in msec */
+	CODEC_FRAME_TIME_BASE = mpf.CODEC_FRAME_TIME_BASE
+
+	/** Event raised by file, tone and I/O terminations as soon as the audio is complete */
+	AUDIO_FILE_COMPLETE_EVENT = mpf.AUDIO_FILE_COMPLETE_EVENT
+
+	TONE_PATTERN_BEEP       = mpf.TONE_PATTERN_BEEP
+	TONE_PATTERN_DIAL       = mpf.TONE_PATTERN_DIAL
+	TONE_PATTERN_RINGBACK   = mpf.TONE_PATTERN_RINGBACK
+	TONE_PATTERN_BUSY       = mpf.TONE_PATTERN_BUSY
+	TONE_PATTERN_CONGESTION = mpf.TONE_PATTERN_CONGESTION
+	TONE_REPEAT_INFINITE    = mpf.TONE_REPEAT_INFINITE
+
+	RECORDING_FINALIZE_CLOSE    = mpf.RECORDING_FINALIZE_CLOSE
+	RECORDING_FINALIZE_DURATION = mpf.RECORDING_FINALIZE_DURATION
+	RECORDING_FINALIZE_SIZE     = mpf.RECORDING_FINALIZE_SIZE
+
+	/** Default max suppression of noise in dB */
+	NS_SUPPRESSION = mpf.NS_SUPPRESSION
+
+	/** Gain in dB the audio is muted at */
+	GAIN_MUTE = mpf.GAIN_MUTE
+
+	/** Level of silence reported by stream meters in dBFS */
+	METER_LEVEL_SILENCE = mpf.METER_LEVEL_SILENCE
+)
+
+/**
+ * Create media engine.
+ * @param id the identifier of the engine
+ */
+func MediaEngineCreate(id string) *MediaEngine {
+	return mpf.EngineCreate(id)
+}
+
+/** Create factory of media contexts (standalone use, without media engine) */
+func ContextFactoryCreate() *ContextFactory {
+	return mpf.ContextFactoryCreate()
+}
+
+/** Process the contexts of factory once (a tick of media clock) */
+func ContextFactoryProcess(factory *ContextFactory) error {
+	return mpf.ContextFactoryProcess(factory)
+}
+
+/**
+ * Create audio stream.
+ * @param obj the external object of the stream
+ * @param vtable the methods of the stream
+ * @param capabilities the capabilities of the stream
+ */
+func AudioStreamCreate(obj interface{}, vtable *AudioStreamVTable, capabilities *StreamCapabilities) *AudioStream {
+	return mpf.AudioStreamCreate(obj, vtable, capabilities)
+}
+
+/** Create capabilities of source (receive) stream */
+func SourceStreamCapabilitiesCreate() *StreamCapabilities {
+	return mpf.SourceStreamCapabilitiesCreate()
+}
+
+/** Create capabilities of sink (send) stream */
+func SinkStreamCapabilitiesCreate() *StreamCapabilities {
+	return mpf.SinkStreamCapabilitiesCreate()
+}
+
+/**
+ * Create bridge of audio streams, converting the audio if needed.
+ * @param source the source stream
+ * @param sink the sink stream
+ * @param manager the codec manager
+ * @param name the informative name of the bridge
+ */
+func BridgeCreate(source, sink *AudioStream, manager *CodecManager, name string) (*Object, error) {
+	return mpf.BridgeCreate(source, sink, manager, name)
+}
+
+/**
+ * Create mixer of audio streams summing the sources by weights (nil - unity).
+ * @param sources the source streams
+ * @param weights the weights of the sources
+ * @param sink the sink stream
+ * @param manager the codec manager
+ * @param name the informative name of the mixer
+ */
+func MixerCreate(sources []*AudioStream, weights []float64, sink *AudioStream, manager *CodecManager, name string) (*Mixer, error) {
+	return mpf.MixerWeightedCreate(sources, weights, sink, manager, name)
+}
+
+/**
+ * Create multiplier of audio stream fanning out the source to the sinks, the frames are shared by the sinks.
+ * @param source the source stream
+ * @param sinks the sink streams
+ * @param manager the codec manager
+ * @param name the informative name of the multiplier
+ */
+func MultiplierCreate(source *AudioStream, sinks []*AudioStream, manager *CodecManager, name string) (*Multiplier, error) {
+	return mpf.MultiplierSharedCreate(source, sinks, manager, name)
+}
+
+/**
+ * Create N-way conference mixing the linear audio of the descriptor, each member hears the others.
+ * @param descriptor the descriptor of the audio mixed
+ * @param manager the codec manager
+ * @param name the informative name of the conference
+ */
+func ConferenceCreate(descriptor *CodecDescriptor, manager *CodecManager, name string) (*Conference, error) {
+	return mpf.ConferenceCreate(descriptor, manager, name)
+}
+
+/** Destroy media processing object (e.g. bridge) */
+func ObjectDestroy(object *Object) error {
+	return mpf.ObjectDestroy(object)
+}
+
+/** Create codec manager of the default codecs */
+func CodecManagerDefaultCreate() *CodecManager {
+	return mpf.CodecManagerDefaultCreate()
+}
+
+/**
+ * Create descriptor of linear PCM audio.
+ * @param samplingRate the sampling rate
+ * @param channelCount the channel count
+ */
+func CodecLPcmDescriptorCreate(samplingRate uint16, channelCount uint8) *CodecDescriptor {
+	return mpf.CodecLPcmDescriptorCreate(samplingRate, channelCount)
+}
+
+/** Clone codec descriptor */
+func CodecDescriptorClone(descriptor *CodecDescriptor) *CodecDescriptor {
+	return mpf.CodecDescriptorClone(descriptor)
+}
+
+/** Create standard tone */
+func ToneCreate(pattern TonePattern) (*Tone, error) {
+	return mpf.ToneCreate(pattern)
+}
+
+/** Parse custom tone, e.g. "440+480/2000,0/4000" */
+func ToneParse(spec string, repeat int) (*Tone, error) {
+	return mpf.ToneParse(spec, repeat)
+}
+
+/** Create termination playing tone */
+func ToneTerminationCreate(tone *Tone, descriptor *CodecDescriptor) (*Termination, error) {
+	return mpf.ToneTerminationCreate(tone, descriptor)
+}
+
+/** Create termination producing silence */
+func SilenceTerminationCreate(descriptor *CodecDescriptor) (*Termination, error) {
+	return mpf.SilenceTerminationCreate(descriptor)
+}
+
+/** Create termination discarding the audio written */
+func NullTerminationCreate(descriptor *CodecDescriptor) (*Termination, error) {
+	return mpf.NullTerminationCreate(descriptor)
+}
+
+/**
+ * Create frame buffer.
+ * @param frameSize the size of frame
+ * @param frameCount the max number of frames buffered
+ */
+func FrameBufferCreate(frameSize, frameCount int64) *FrameBuffer {
+	return mpf.FrameBufferCreate(frameSize, frameCount)
+}
+
+/** Create termination the audio of which is buffered */
+func FrameBufferTerminationCreate(rxBuffer, txBuffer *FrameBuffer, descriptor *CodecDescriptor) (*Termination, error) {
+	return mpf.FrameBufferTerminationCreate(rxBuffer, txBuffer, descriptor)
+}
+
+/** Create AGC (default config if nil) */
+func AgcCreate(descriptor *CodecDescriptor, config *AgcConfig) (*Agc, error) {
+	return mpf.AgcCreate(descriptor, config)
+}
+
+/** Create stream applying AGC to the audio written to the sink */
+func AgcStreamCreate(sink *AudioStream, agc *Agc) *AudioStream {
+	return mpf.AgcStreamCreate(sink, agc)
+}
+
+/** Create chain of frame filters applied in order */
+func FrameFilterChainCreate(filters ...FrameFilter) *FrameFilterChain {
+	return mpf.FrameFilterChainCreate(filters...)
+}
+
+/** Create stream applying frame filter (or chain) to the audio written to the sink */
+func FrameFilterStreamCreate(sink *AudioStream, filter FrameFilter) *AudioStream {
+	return mpf.FrameFilterStreamCreate(sink, filter)
+}
+
+/** Create noise suppressor by spectral subtraction */
+func NoiseSuppressorCreate(descriptor *CodecDescriptor, suppression float64) (*NoiseSuppressor, error) {
+	return mpf.NoiseSuppressorCreate(descriptor, suppression)
+}
+
+/** Create gain stage attenuating or amplifying audio by dB */
+func GainCreate(gain float64) (*Gain, error) {
+	return mpf.GainCreate(gain)
+}
+
+/** Allocate recording config of default values */
+func RecordingConfigAlloc() *RecordingConfig {
+	return mpf.RecordingConfigAlloc()
+}
+
+/** Create termination recording the audio to files rotated by duration and size */
+func RecordingTerminationCreate(config *RecordingConfig, descriptor *CodecDescriptor) (*Termination, error) {
+	return mpf.RecordingTerminationCreate(config, descriptor)
+}
+
+/** Close recording of termination, finalizing the file being recorded */
+func RecordingTerminationClose(termination *Termination) error {
+	return mpf.RecordingTerminationClose(termination)
+}
+
+/** Create tracer logging a line per frame of audio stream */
+func LogStreamTracerCreate(logger *log.Logger, name string) StreamTraceProc {
+	return mpf.LogStreamTracerCreate(logger, name)
+}
+
+/** Create tracer capturing the audio of one direction of audio stream to WAV file */
+func WavStreamTracerCreate(path string, direction mpf.StreamDirection) (*WavStreamTracer, error) {
+	return mpf.WavStreamTracerCreate(path, direction)
+}
+
+/** Create source stream pulling frames from jitter buffer at the rate of the local media clock */
+func JitterBufferSourceCreate(jb JitterBufferReader, descriptor, eventDescriptor *CodecDescriptor, codec *Codec) (*AudioStream, error) {
+	return mpf.JitterBufferSourceCreate(jb, descriptor, eventDescriptor, codec)
+}
+
+/** Create source the audio of which is pushed asynchronously, e.g. by streaming TTS engine */
+func PushSourceCreate(descriptor *CodecDescriptor) (*PushSource, error) {
+	return mpf.PushSourceCreate(descriptor)
+}
+
+/** Create termination playing the audio pushed to the source at real-time */
+func PushTerminationCreate(source *PushSource) (*Termination, error) {
+	return mpf.PushTerminationCreate(source)
+}
+
+/** Create sink queueing the frames received on bounded channel */
+func PullSinkCreate(descriptor *CodecDescriptor, queueSize int) (*PullSink, error) {
+	return mpf.PullSinkCreate(descriptor, queueSize)
+}
+
+/** Create sink passing the frames received to callback */
+func PullSinkCallbackCreate(descriptor *CodecDescriptor, callback func(frame *PulledFrame)) (*PullSink, error) {
+	return mpf.PullSinkCallbackCreate(descriptor, callback)
+}
+
+/** Create termination exposing the audio received to the pull sink */
+func PullTerminationCreate(sink *PullSink) (*Termination, error) {
+	return mpf.PullTerminationCreate(sink)
+}
+
+/** Create video stream, the video of which is passed through as encoded */
+func VideoStreamCreate(obj interface{}, vtable *VideoStreamVTable, capabilities *StreamCapabilities) *VideoStream {
+	return mpf.VideoStreamCreate(obj, vtable, capabilities)
+}
@@ -0,0 +1,320 @@
+/*
+Package g722 implements the ITU-T G.722 wideband (16 kHz) codec in its 64 kbit/s mode.
+
+The sub-band ADPCM algorithm follows the ITU-T reference implementation: a QMF splits
+the input into low and high bands, quantized with 6 and 2 bits respectively, so every
+pair of 16-bit input samples is coded into a single byte.
+
+Encoder and Decoder keep the adaptive state of the codec, hence each stream needs its own.
+*/
+package g722
+
+const (
+	// SampleRate is the actual sampling rate of the audio
+	SampleRate = 16000
+	// ClockRate is the RTP clock rate advertised for G.722 (RFC3551 section 4.5.2)
+	ClockRate = 8000
+)
+
+var (
+	qmfCoeffs = [12]int{3, -11, 12, 32, -210, 951, 3876, -805, 362, -156, 53, -11}
+
+	q6  = [32]int{0, 35, 72, 110, 150, 190, 233, 276, 323, 370, 422, 473, 530, 587, 650, 714, 786, 858, 940, 1023, 1121, 1219, 1339, 1458, 1612, 1765, 1980, 2195, 2557, 2919, 0, 0}
+	iln = [32]int{0, 63, 62, 31, 30, 29, 28, 27, 26, 25, 24, 23, 22, 21, 20, 19, 18, 17, 16, 15, 14, 13, 12, 11, 10, 9, 8, 7, 6, 5, 4, 0}
+	ilp = [32]int{0, 61, 60, 59, 58, 57, 56, 55, 54, 53, 52, 51, 50, 49, 48, 47, 46, 45, 44, 43, 42, 41, 40, 39, 38, 37, 36, 35, 34, 33, 32, 0}
+	wl  = [8]int{-60, -30, 58, 172, 334, 538, 1198, 3042}
+	ilb = [32]int{2048, 2093, 2139, 2186, 2233, 2282, 2332, 2383, 2435, 2489, 2543, 2599, 2656, 2714, 2774, 2834, 2896, 2960, 3025, 3091, 3158, 3228, 3298, 3371, 3444, 3520, 3597, 3676, 3756, 3838, 3922, 4008}
+
+	rl42 = [16]int{0, 7, 6, 5, 4, 3, 2, 1, 7, 6, 5, 4, 3, 2, 1, 0}
+	qm4  = [16]int{0, -20456, -12896, -8968, -6288, -4240, -2584, -1200, 20456, 12896, 8968, 6288, 4240, 2584, 1200, 0}
+	qm6  = [64]int{
+		-136, -136, -136, -136, -24808, -21904, -19008, -16704,
+		-14984, -13512, -12280, -11192, -10232, -9360, -8576, -7856,
+		-7192, -6576, -6000, -5456, -4944, -4464, -4008, -3576,
+		-3168, -2776, -2400, -2032, -1688, -1360, -1040, -728,
+		24808, 21904, 19008, 16704, 14984, 13512, 12280, 11192,
+		10232, 9360, 8576, 7856, 7192, 6576, 6000, 5456,
+		4944, 4464, 4008, 3576, 3168, 2776, 2400, 2032,
+		1688, 1360, 1040, 728, 432, 136, -432, -136,
+	}
+
+	ihn = [3]int{0, 1, 0}
+	ihp = [3]int{0, 3, 2}
+	wh  = [3]int{0, -214, 798}
+	rh2 = [4]int{2, 1, 2, 1}
+	qm2 = [4]int{-7408, -1616, 7408, 1616}
+)
+
+func saturate(amp int) int {
+	if amp > 32767 {
+		return 32767
+	}
+	if amp < -32768 {
+		return -32768
+	}
+	return amp
+}
+
+// band is the adaptive predictor state of a sub-band
+type band struct {
+	s, sp, sz int
+	r, a, ap  [3]int
+	p         [3]int
+	d, b, bp  [7]int
+	sg        [7]int
+	nb, det   int
+}
+
+// update adapts the predictor to the quantized difference signal (block 4)
+func (b *band) update(dx int) {
+	/* RECONS */
+	b.d[0] = dx
+	b.r[0] = saturate(b.s + dx)
+	/* PARREC */
+	b.p[0] = saturate(b.sz + dx)
+
+	/* UPPOL2 */
+	for i := 0; i < 3; i++ {
+		b.sg[i] = b.p[i] >> 15
+	}
+	wd1 := saturate(b.a[1] << 2)
+	wd2 := wd1
+	if b.sg[0] == b.sg[1] {
+		wd2 = -wd1
+	}
+	if wd2 > 32767 {
+		wd2 = 32767
+	}
+	wd3 := wd2 >> 7
+	if b.sg[0] == b.sg[2] {
+		wd3 += 128
+	} else {
+		wd3 -= 128
+	}
+	wd3 += (b.a[2] * 32512) >> 15
+	if wd3 > 12288 {
+		wd3 = 12288
+	} else if wd3 < -12288 {
+		wd3 = -12288
+	}
+	b.ap[2] = wd3
+
+	/* UPPOL1 */
+	b.sg[0] = b.p[0] >> 15
+	b.sg[1] = b.p[1] >> 15
+	wd1 = -192
+	if b.sg[0] == b.sg[1] {
+		wd1 = 192
+	}
+	wd2 = (b.a[1] * 32640) >> 15
+	b.ap[1] = saturate(wd1 + wd2)
+	wd3 = saturate(15360 - b.ap[2])
+	if b.ap[1] > wd3 {
+		b.ap[1] = wd3
+	} else if b.ap[1] < -wd3 {
+		b.ap[1] = -wd3
+	}
+
+	/* UPZERO */
+	wd1 = 128
+	if dx == 0 {
+		wd1 = 0
+	}
+	b.sg[0] = dx >> 15
+	for i := 1; i < 7; i++ {
+		b.sg[i] = b.d[i] >> 15
+		wd2 = -wd1
+		if b.sg[i] == b.sg[0] {
+			wd2 = wd1
+		}
+		wd3 = (b.b[i] * 32640) >> 15
+		b.bp[i] = saturate(wd2 + wd3)
+	}
+
+	/* DELAYA */
+	for i := 6; i > 0; i-- {
+		b.d[i] = b.d[i-1]
+		b.b[i] = b.bp[i]
+	}
+	for i := 2; i > 0; i-- {
+		b.r[i] = b.r[i-1]
+		b.p[i] = b.p[i-1]
+		b.a[i] = b.ap[i]
+	}
+
+	/* FILTEP */
+	wd1 = (b.a[1] * saturate(b.r[1]+b.r[1])) >> 15
+	wd2 = (b.a[2] * saturate(b.r[2]+b.r[2])) >> 15
+	b.sp = saturate(wd1 + wd2)
+
+	/* FILTEZ */
+	b.sz = 0
+	for i := 6; i > 0; i-- {
+		b.sz += (b.b[i] * saturate(b.d[i]+b.d[i])) >> 15
+	}
+	b.sz = saturate(b.sz)
+
+	/* PREDIC */
+	b.s = saturate(b.sp + b.sz)
+}
+
+// scale adapts the quantizer scale factor of the band (blocks 3L/3H)
+func (b *band) scale(weight, limit, shift int) {
+	nb := ((b.nb * 127) >> 7) + weight
+	if nb < 0 {
+		nb = 0
+	} else if nb > limit {
+		nb = limit
+	}
+	b.nb = nb
+
+	wd1 := (b.nb >> 6) & 31
+	wd2 := shift - (b.nb >> 11)
+	var wd3 int
+	if wd2 < 0 {
+		wd3 = ilb[wd1] << uint(-wd2)
+	} else {
+		wd3 = ilb[wd1] >> uint(wd2)
+	}
+	b.det = wd3 << 2
+}
+
+// Encoder codes 16 kHz linear samples to G.722 at 64 kbit/s
+type Encoder struct {
+	x    [24]int
+	band [2]band
+}
+
+// NewEncoder creates encoder in its initial state
+func NewEncoder() *Encoder {
+	e := &Encoder{}
+	e.band[0].det = 32
+	e.band[1].det = 8
+	return e
+}
+
+// Encode codes pairs of linear samples to bytes; a trailing odd sample is ignored
+func (e *Encoder) Encode(samples []int16) []byte {
+	out := make([]byte, 0, len(samples)/2)
+	for j := 0; j+1 < len(samples); j += 2 {
+		/* transmit QMF */
+		copy(e.x[:22], e.x[2:])
+		e.x[22] = int(samples[j])
+		e.x[23] = int(samples[j+1])
+		var sumEven, sumOdd int
+		for i := 0; i < 12; i++ {
+			sumOdd += e.x[2*i] * qmfCoeffs[i]
+			sumEven += e.x[2*i+1] * qmfCoeffs[11-i]
+		}
+		xLow := (sumEven + sumOdd) >> 14
+		xHigh := (sumEven - sumOdd) >> 14
+
+		/* low band: SUBTRA, QUANTL */
+		low := &e.band[0]
+		el := saturate(xLow - low.s)
+		wd := el
+		if el < 0 {
+			wd = -(el + 1)
+		}
+		i := 1
+		for ; i < 30; i++ {
+			if wd < (q6[i]*low.det)>>12 {
+				break
+			}
+		}
+		ilow := ilp[i]
+		if el < 0 {
+			ilow = iln[i]
+		}
+		/* INVQAL, LOGSCL, SCALEL */
+		ril := ilow >> 2
+		dLow := (low.det * qm4[ril]) >> 15
+		low.scale(wl[rl42[ril]], 18432, 8)
+		low.update(dLow)
+
+		/* high band: SUBTRA, QUANTH */
+		high := &e.band[1]
+		eh := saturate(xHigh - high.s)
+		wd = eh
+		if eh < 0 {
+			wd = -(eh + 1)
+		}
+		mih := 1
+		if wd >= (564*high.det)>>12 {
+			mih = 2
+		}
+		ihigh := ihp[mih]
+		if eh < 0 {
+			ihigh = ihn[mih]
+		}
+		/* INVQAH, LOGSCH, SCALEH */
+		dHigh := (high.det * qm2[ihigh]) >> 15
+		high.scale(wh[rh2[ihigh]], 22528, 10)
+		high.update(dHigh)
+
+		out = append(out, byte(ihigh<<6|ilow))
+	}
+	return out
+}
+
+// Decoder decodes G.722 at 64 kbit/s to 16 kHz linear samples
+type Decoder struct {
+	x    [24]int
+	band [2]band
+}
+
+// NewDecoder creates decoder in its initial state
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+	d.band[0].det = 32
+	d.band[1].det = 8
+	return d
+}
+
+// Decode decodes bytes to pairs of linear samples
+func (d *Decoder) Decode(data []byte) []int16 {
+	out := make([]int16, 0, len(data)*2)
+	for _, code := range data {
+		ilow := int(code) & 0x3F
+		ihigh := int(code>>6) & 0x03
+
+		/* low band: INVQBL, RECONS, LIMIT */
+		low := &d.band[0]
+		rLow := low.s + (low.det*qm6[ilow])>>15
+		if rLow > 16383 {
+			rLow = 16383
+		} else if rLow < -16384 {
+			rLow = -16384
+		}
+		/* INVQAL, LOGSCL, SCALEL */
+		ril := ilow >> 2
+		dLow := (low.det * qm4[ril]) >> 15
+		low.scale(wl[rl42[ril]], 18432, 8)
+		low.update(dLow)
+
+		/* high band: INVQAH, RECONS, LIMIT */
+		high := &d.band[1]
+		dHigh := (high.det * qm2[ihigh]) >> 15
+		rHigh := dHigh + high.s
+		if rHigh > 16383 {
+			rHigh = 16383
+		} else if rHigh < -16384 {
+			rHigh = -16384
+		}
+		/* LOGSCH, SCALEH */
+		high.scale(wh[rh2[ihigh]], 22528, 10)
+		high.update(dHigh)
+
+		/* receive QMF */
+		copy(d.x[:22], d.x[2:])
+		d.x[22] = rLow + rHigh
+		d.x[23] = rLow - rHigh
+		var xOut1, xOut2 int
+		for i := 0; i < 12; i++ {
+			xOut2 += d.x[2*i] * qmfCoeffs[i]
+			xOut1 += d.x[2*i+1] * qmfCoeffs[11-i]
+		}
+		out = append(out, int16(saturate(xOut1>>11)), int16(saturate(xOut2>>11)))
+	}
+	return out
+}
@@ -0,0 +1,51 @@
+package g722
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	const count = SampleRate / 4
+	in := make([]int16, count)
+	for i := range in {
+		in[i] = int16(8000*math.Sin(2*math.Pi*1000*float64(i)/SampleRate) +
+			4000*math.Sin(2*math.Pi*5000*float64(i)/SampleRate))
+	}
+
+	encoded := NewEncoder().Encode(in)
+	if len(encoded) != count/2 {
+		t.Fatalf("encoded %d bytes, want %d", len(encoded), count/2)
+	}
+	out := NewDecoder().Decode(encoded)
+	if len(out) != count {
+		t.Fatalf("decoded %d samples, want %d", len(out), count)
+	}
+
+	/* find the delay of the QMF pair, then measure SNR once the predictors converged */
+	best := math.Inf(-1)
+	for delay := 0; delay < 64; delay++ {
+		var signal, noise float64
+		for i := count / 2; i < count; i++ {
+			s := float64(in[i-delay])
+			n := float64(out[i]) - s
+			signal += s * s
+			noise += n * n
+		}
+		if snr := 10 * math.Log10(signal/noise); snr > best {
+			best = snr
+		}
+	}
+	if best < 20 {
+		t.Fatalf("SNR %.1f dB is too low", best)
+	}
+}
+
+func TestSilence(t *testing.T) {
+	out := NewDecoder().Decode(NewEncoder().Encode(make([]int16, SampleRate/10)))
+	for i, sample := range out {
+		if sample > 32 || sample < -32 {
+			t.Fatalf("sample %d of silence decoded as %d", i, sample)
+		}
+	}
+}
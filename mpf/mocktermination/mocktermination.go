@@ -0,0 +1,254 @@
+/*
+Package mocktermination provides scriptable MPF termination test doubles:
+the source plays the frames scripted (or generated), the sink records the frames written,
+and the events raised into the termination are recorded, so that contexts, bridges and engines
+may be unit tested without RTP or files.
+*/
+package mocktermination
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+)
+
+/** Event raised into the termination */
+type Event struct {
+	Id         int
+	Descriptor interface{}
+}
+
+/** Mock termination */
+type Termination struct {
+	/** MPF termination, to be added to the context */
+	Termination *mpf.Termination
+	/** Audio stream of the termination */
+	Stream *mpf.AudioStream
+
+	mutex     sync.Mutex
+	frames    [][]byte
+	generator func(index int) []byte
+	reads     int
+	readErr   error
+	written   [][]byte
+	writeErr  error
+	events    []Event
+	opened    map[string]int
+}
+
+/**
+ * Create mock termination.
+ * @param direction the direction of the stream (mpf.STREAM_DIRECTION_RECEIVE for source, SEND for sink, DUPLEX)
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ */
+func New(direction mpf.StreamDirection, descriptor *mpf.CodecDescriptor) *Termination {
+	if descriptor == nil {
+		descriptor = mpf.CodecLPcmDescriptorCreate(8000, 1)
+	}
+	m := &Termination{opened: make(map[string]int)}
+	vtable := &mpf.AudioStreamVTable{
+		Destroy:    func(*mpf.AudioStream) error { return nil },
+		OpenRX:     func(*mpf.AudioStream, *mpf.Codec) error { return m.openCount("rx", 1) },
+		CloseRX:    func(*mpf.AudioStream) error { return m.openCount("rx", -1) },
+		ReadFrame:  m.frameRead,
+		OpenTX:     func(*mpf.AudioStream, *mpf.Codec) error { return m.openCount("tx", 1) },
+		CloseTX:    func(*mpf.AudioStream) error { return m.openCount("tx", -1) },
+		WriteFrame: m.frameWrite,
+	}
+	m.Stream = mpf.AudioStreamCreate(m, vtable, mpf.StreamCapabilitiesCreate(direction))
+	if (direction & mpf.STREAM_DIRECTION_RECEIVE) == mpf.STREAM_DIRECTION_RECEIVE {
+		m.Stream.RXDescriptor = descriptor
+	}
+	if (direction & mpf.STREAM_DIRECTION_SEND) == mpf.STREAM_DIRECTION_SEND {
+		m.Stream.TXDescriptor = descriptor
+	}
+	m.Termination = mpf.TerminationBaseCreate(nil, m, nil, m.Stream, nil)
+	m.Termination.EventHandler = func(termination *mpf.Termination, eventId int, descriptor interface{}) error {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		m.events = append(m.events, Event{Id: eventId, Descriptor: descriptor})
+		return nil
+	}
+	return m
+}
+
+/** Create mock source termination playing the frames */
+func NewSource(descriptor *mpf.CodecDescriptor, frames ...[]byte) *Termination {
+	m := New(mpf.STREAM_DIRECTION_RECEIVE, descriptor)
+	m.Push(frames...)
+	return m
+}
+
+/** Create mock sink termination recording the frames written */
+func NewSink(descriptor *mpf.CodecDescriptor) *Termination {
+	return New(mpf.STREAM_DIRECTION_SEND, descriptor)
+}
+
+func (m *Termination) openCount(direction string, delta int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.opened[direction] += delta
+	return nil
+}
+
+/** Queue frames to play */
+func (m *Termination) Push(frames ...[]byte) *Termination {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, frame := range frames {
+		m.frames = append(m.frames, append([]byte(nil), frame...))
+	}
+	return m
+}
+
+/** Generate frames to play when the queued ones are played, nil generated means no audio */
+func (m *Termination) Generate(generator func(index int) []byte) *Termination {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.generator = generator
+	return m
+}
+
+/** Fail reads of frames by the error (nil to stop failing) */
+func (m *Termination) FailRead(err error) *Termination {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.readErr = err
+	return m
+}
+
+/** Fail writes of frames by the error (nil to stop failing) */
+func (m *Termination) FailWrite(err error) *Termination {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.writeErr = err
+	return m
+}
+
+/** Raise event into the termination on behalf of the stream */
+func (m *Termination) Raise(eventId int, descriptor interface{}) error {
+	return m.Termination.EventHandler(m.Termination, eventId, descriptor)
+}
+
+func (m *Termination) frameRead(stream *mpf.AudioStream, frame *mpf.Frame) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.readErr != nil {
+		return m.readErr
+	}
+	var data []byte
+	if len(m.frames) > 0 {
+		data = m.frames[0]
+		m.frames = m.frames[1:]
+	} else if m.generator != nil {
+		data = m.generator(m.reads)
+	}
+	m.reads++
+	if data == nil {
+		return nil
+	}
+	if frame.CodecFrame.Buffer == nil {
+		frame.CodecFrame.Buffer = &bytes.Buffer{}
+	}
+	frame.CodecFrame.Buffer.Reset()
+	frame.CodecFrame.Buffer.Write(data)
+	frame.CodecFrame.Size = int64(len(data))
+	frame.Type |= mpf.MEDIA_FRAME_TYPE_AUDIO
+	return nil
+}
+
+func (m *Termination) frameWrite(stream *mpf.AudioStream, frame *mpf.Frame) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.writeErr != nil {
+		return m.writeErr
+	}
+	var data []byte
+	if (frame.Type&mpf.MEDIA_FRAME_TYPE_AUDIO) == mpf.MEDIA_FRAME_TYPE_AUDIO && frame.CodecFrame.Buffer != nil {
+		data = frame.CodecFrame.Buffer.Bytes()
+		if frame.CodecFrame.Size > 0 && frame.CodecFrame.Size < int64(len(data)) {
+			data = data[:frame.CodecFrame.Size]
+		}
+		data = append([]byte{}, data...)
+	}
+	/* frames of no audio are recorded as nil */
+	m.written = append(m.written, data)
+	return nil
+}
+
+/** Get frames written (nil for frames of no audio) */
+func (m *Termination) Written() [][]byte {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([][]byte(nil), m.written...)
+}
+
+/** Get audio written (frames concatenated) */
+func (m *Termination) Audio() []byte {
+	var audio []byte
+	for _, data := range m.Written() {
+		audio = append(audio, data...)
+	}
+	return audio
+}
+
+/** Get number of frames read */
+func (m *Termination) Reads() int {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.reads
+}
+
+/** Get events raised into the termination */
+func (m *Termination) Events() []Event {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return append([]Event(nil), m.events...)
+}
+
+/** Check whether the stream is open in the direction ("rx" or "tx") */
+func (m *Termination) Opened(direction string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.opened[direction] > 0
+}
+
+/**
+ * Assert audio written.
+ * @param t the test
+ * @param audio the audio expected
+ */
+func (m *Termination) AssertAudio(t testing.TB, audio []byte) {
+	t.Helper()
+	if written := m.Audio(); !bytes.Equal(written, audio) {
+		t.Fatalf("%d bytes written %s, want %d bytes", len(written), preview(written), len(audio))
+	}
+}
+
+/**
+ * Assert events raised.
+ * @param t the test
+ * @param ids the ids of the events expected in order
+ */
+func (m *Termination) AssertEvents(t testing.TB, ids ...int) {
+	t.Helper()
+	events := m.Events()
+	if len(events) != len(ids) {
+		t.Fatalf("%d events raised %+v, want %v", len(events), events, ids)
+	}
+	for i, event := range events {
+		if event.Id != ids[i] {
+			t.Fatalf("event %d is %d, want %d", i, event.Id, ids[i])
+		}
+	}
+}
+
+func preview(data []byte) string {
+	if len(data) > 8 {
+		return fmt.Sprintf("% x...", data[:8])
+	}
+	return fmt.Sprintf("% x", data)
+}
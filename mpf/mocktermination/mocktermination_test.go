@@ -0,0 +1,40 @@
+package mocktermination
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+)
+
+func TestMockTerminationBridge(t *testing.T) {
+	descriptor := &mpf.CodecDescriptor{PayloadType: 0, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1, Enabled: true}
+	frames := [][]byte{bytes.Repeat([]byte{1}, 80), bytes.Repeat([]byte{2}, 80)}
+	source := NewSource(descriptor, frames...)
+	sink := NewSink(descriptor)
+
+	manager := mpf.CodecManagerCreate(1)
+	if err := manager.CodecManagerCodecRegister(mpf.CodecG711UCreate()); err != nil {
+		t.Fatal(err)
+	}
+	bridge, err := mpf.BridgeCreate(source.Stream, sink.Stream, manager, "mock")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !source.Opened("rx") || !sink.Opened("tx") {
+		t.Fatalf("streams are not opened by bridge")
+	}
+	for i := 0; i < 2; i++ {
+		if err := bridge.ObjectProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sink.AssertAudio(t, append(append([]byte(nil), frames[0]...), frames[1]...))
+	mpf.ObjectDestroy(bridge)
+	if source.Opened("rx") || sink.Opened("tx") {
+		t.Fatalf("streams are not closed by bridge")
+	}
+
+	source.Raise(7, nil)
+	source.AssertEvents(t, 7)
+}
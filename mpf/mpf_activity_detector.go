@@ -1,8 +1,7 @@
 package mpf
 
 import (
-	"bytes"
-	"encoding/binary"
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
 )
 
 /** Detector states */
@@ -90,31 +89,32 @@ func (ad *ActivityDetector) ActivityDetectorStateChange(state DetectorState) {
 
 func (ad *ActivityDetector) ActivityDetectorLevelCalculate(frame *Frame) (int64, error) {
 	var (
-		sum   int64 = 0
-		count       = int64(frame.CodecFrame.Size) / 2
-		cur   int64 = 0
-		end         = cur + count
+		sum int64 = 0
 	)
-	// todo( 从[2]byte读一个int16 )
-	for ; cur < end; cur++ {
-		tmp := make([]byte, 2)
-		_, err := frame.CodecFrame.Buffer.Read(tmp)
-		if err != nil {
-			return 0, err
-		}
-		//frame.CodecFrame.Size = frame.CodecFrame.Buffer.Len()
-		br := bytes.NewReader(tmp)
-		var utt16 int16
-		_ = binary.Read(br, binary.LittleEndian, &utt16)
-
-		if utt16 < 0 {
-			sum -= int64(utt16)
+	if frame.CodecFrame.Buffer == nil {
+		return 0, nil
+	}
+	/* the frame is inspected only, its buffer is not consumed */
+	data := frame.CodecFrame.Buffer.Bytes()
+	if frame.CodecFrame.Size < int64(len(data)) {
+		data = data[:frame.CodecFrame.Size]
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(data)
+	if err != nil {
+		return 0, err
+	}
+	if len(samples) == 0 {
+		return 0, nil
+	}
+	for _, sample := range samples {
+		if sample < 0 {
+			sum -= int64(sample)
 		} else {
-			sum += int64(utt16)
+			sum += int64(sample)
 		}
 	}
 
-	return sum / count, nil
+	return sum / int64(len(samples)), nil
 }
 
 /** Process current frame return detected event if any */
@@ -0,0 +1,182 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+/** Default target level (RMS) of the audio in dBFS */
+const AGC_TARGET_LEVEL = -20.0
+
+/** Default max gain applied in dB (attenuation is limited by the same value) */
+const AGC_MAX_GAIN = 30.0
+
+/** Default attack time (gain decrease as the level rises) in msec */
+const AGC_ATTACK_TIME = 20
+
+/** Default release time (gain increase as the level falls) in msec */
+const AGC_RELEASE_TIME = 500
+
+/** Default noise floor in dBFS, the gain is held (not raised) for the audio below it */
+const AGC_NOISE_FLOOR = -55.0
+
+/** AGC config */
+type AgcConfig struct {
+	/** Target level (RMS) in dBFS */
+	TargetLevel float64
+	/** Max gain (and attenuation) in dB */
+	MaxGain float64
+	/** Attack time in msec */
+	AttackTime int64
+	/** Release time in msec */
+	ReleaseTime int64
+	/** Noise floor in dBFS */
+	NoiseFloor float64
+}
+
+/** Allocate AGC config initialized with default values, suitable for telephony speech recognition */
+func AgcConfigAlloc() *AgcConfig {
+	return &AgcConfig{
+		TargetLevel: AGC_TARGET_LEVEL,
+		MaxGain:     AGC_MAX_GAIN,
+		AttackTime:  AGC_ATTACK_TIME,
+		ReleaseTime: AGC_RELEASE_TIME,
+		NoiseFloor:  AGC_NOISE_FLOOR,
+	}
+}
+
+/**
+ * Automatic gain control normalizing the level of (linear) input toward the target,
+ * e.g. of quiet callers before the audio reaches the recognizer.
+ * The gain follows the level of frames with attack/release smoothing, is ramped within the frame
+ * and is limited so that the peaks are not clipped. Silence and noise below the floor are not amplified.
+ */
+type Agc struct {
+	config       AgcConfig
+	samplingRate int64
+	channelCount int64
+
+	/** Current gain in dB */
+	gain float64
+
+	mutex sync.Mutex
+}
+
+/**
+ * Create AGC.
+ * @param descriptor the descriptor of the (linear) audio
+ * @param config the config (default if nil)
+ */
+func AgcCreate(descriptor *CodecDescriptor, config *AgcConfig) (*Agc, error) {
+	if descriptor == nil || descriptor.SamplingRate == 0 || descriptor.ChannelCount == 0 {
+		return nil, fmt.Errorf("gain control of the audio is not supported")
+	}
+	if config == nil {
+		config = AgcConfigAlloc()
+	}
+	if config.TargetLevel >= 0 || config.MaxGain < 0 || config.AttackTime <= 0 || config.ReleaseTime <= 0 {
+		return nil, fmt.Errorf("invalid AGC target %.1f dBFS, max gain %.1f dB, attack %d or release %d msec",
+			config.TargetLevel, config.MaxGain, config.AttackTime, config.ReleaseTime)
+	}
+	return &Agc{
+		config:       *config,
+		samplingRate: int64(descriptor.SamplingRate),
+		channelCount: int64(descriptor.ChannelCount),
+	}, nil
+}
+
+/** Reset AGC for the next audio (e.g. the next RECOGNIZE request) */
+func (agc *Agc) AgcReset() {
+	agc.mutex.Lock()
+	defer agc.mutex.Unlock()
+	agc.gain = 0
+}
+
+/** Get current gain in dB */
+func (agc *Agc) AgcGainGet() float64 {
+	agc.mutex.Lock()
+	defer agc.mutex.Unlock()
+	return agc.gain
+}
+
+/* Measure RMS and peak level of samples in dBFS */
+func agcLevelMeasure(samples []int16) (rms, peak float64) {
+	var energy, max float64
+	for _, sample := range samples {
+		v := float64(sample)
+		energy += v * v
+		if v = math.Abs(v); v > max {
+			max = v
+		}
+	}
+	return 10 * math.Log10(energy/float64(len(samples))/(32768*32768)), 20 * math.Log10(max/32768)
+}
+
+/**
+ * Process (interleaved) samples of the frame in place.
+ * @param samples the samples
+ */
+func (agc *Agc) AgcProcess(samples []int16) {
+	if len(samples) == 0 {
+		return
+	}
+	agc.mutex.Lock()
+	defer agc.mutex.Unlock()
+
+	start := agc.gain
+	rms, peak := agcLevelMeasure(samples)
+	if rms >= agc.config.NoiseFloor {
+		desired := math.Max(-agc.config.MaxGain, math.Min(agc.config.MaxGain, agc.config.TargetLevel-rms))
+		duration := float64(len(samples)) * 1000 / float64(agc.samplingRate*agc.channelCount)
+		constant := agc.config.ReleaseTime
+		if desired < agc.gain {
+			constant = agc.config.AttackTime
+		}
+		agc.gain += (desired - agc.gain) * (1 - math.Exp(-duration/float64(constant)))
+	}
+	/* the peaks are not clipped */
+	if limit := -0.1 - peak; agc.gain > limit {
+		agc.gain = limit
+	}
+	if start > agc.gain {
+		/* the gain is decreased immediately not to clip the frame */
+		start = agc.gain
+	}
+
+	from, to := math.Pow(10, start/20), math.Pow(10, agc.gain/20)
+	frames := len(samples) / int(agc.channelCount)
+	for i, sample := range samples {
+		factor := from + (to-from)*float64(i/int(agc.channelCount)+1)/float64(frames)
+		v := math.Round(float64(sample) * factor)
+		if v > math.MaxInt16 {
+			v = math.MaxInt16
+		} else if v < math.MinInt16 {
+			v = math.MinInt16
+		}
+		samples[i] = int16(v)
+	}
+}
+
+/**
+ * Create stream applying AGC to the audio written to the (linear) sink, e.g. of the recognizer.
+ * @param sink the sink
+ * @param agc the AGC
+ */
+func AgcStreamCreate(sink *AudioStream, agc *Agc) *AudioStream {
+	if agc == nil {
+		return nil
+	}
+	return FrameFilterStreamCreate(sink, agc)
+}
+
+/** Process samples as frame filter */
+func (agc *Agc) FrameFilterProcess(samples []int16) error {
+	agc.AgcProcess(samples)
+	return nil
+}
+
+/** Reset as frame filter */
+func (agc *Agc) FrameFilterReset() {
+	agc.AgcReset()
+}
@@ -0,0 +1,67 @@
+package mpf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestAgcStream(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	agc, err := AgcCreate(descriptor, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var written []int16
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			written = samples
+			return err
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = descriptor
+	stream := AgcStreamCreate(sink, agc)
+
+	write := func(amplitude float64, frames int) (rms, peak float64) {
+		for i := 0; i < frames; i++ {
+			samples := make([]int16, 80)
+			for j := range samples {
+				samples[j] = int16(amplitude * math.Sin(2*math.Pi*440*float64(i*80+j)/8000))
+			}
+			frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+			codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+			if err := stream.AudioStreamFrameWrite(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return agcLevelMeasure(written)
+	}
+
+	/* quiet caller (-40 dBFS) is raised toward the target */
+	if rms, _ := write(328, 300); math.Abs(rms-AGC_TARGET_LEVEL) > 2 {
+		t.Fatalf("quiet audio is normalized to %.1f dBFS", rms)
+	}
+	gain := agc.AgcGainGet()
+	/* silence does not raise the gain */
+	write(0, 100)
+	if agc.AgcGainGet() != gain {
+		t.Fatalf("gain %.1f dB is changed by silence", agc.AgcGainGet())
+	}
+	/* loud burst is not clipped and is attenuated quickly */
+	if _, peak := write(16000, 1); peak > -0.05 {
+		t.Fatalf("loud audio is clipped: %.1f dBFS", peak)
+	}
+	if rms, _ := write(16000, 20); math.Abs(rms-AGC_TARGET_LEVEL) > 2 {
+		t.Fatalf("loud audio is normalized to %.1f dBFS", rms)
+	}
+
+	agc.AgcReset()
+	if agc.AgcGainGet() != 0 {
+		t.Fatalf("gain is not reset")
+	}
+	if _, err := AgcCreate(descriptor, &AgcConfig{TargetLevel: 3}); err == nil {
+		t.Fatalf("AGC of invalid config is created")
+	}
+}
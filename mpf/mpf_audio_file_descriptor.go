@@ -9,6 +9,14 @@ const (
 	FILE_WRITER = STREAM_DIRECTION_SEND
 )
 
+/** Audio file formats */
+type AudioFileFormat = int
+
+const (
+	AUDIO_FILE_FORMAT_RAW AudioFileFormat = iota /**< raw audio data of the codec descriptor */
+	AUDIO_FILE_FORMAT_WAV                        /**< WAV (PCM 16-bit mono 8/16 kHz) */
+)
+
 /** Audio file descriptor */
 type AudioFileDescriptor struct {
 	/** Indicate descriptor type (reader and/or writer) */
@@ -21,4 +29,57 @@ type AudioFileDescriptor struct {
 	WriteHandle *os.File
 	/** Max size of file  */
 	MaxWriteSize int64
+	/** Format of the file written, the format of the file read is detected by its header */
+	Format AudioFileFormat
+	/** Format of headerless PCM file read (optional), the audio is converted to linear 16-bit one */
+	PcmFormat *AudioFilePcmFormat
+	/** Number of times the file read is replayed after the end of it, AUDIO_FILE_LOOP_INFINITE to loop until stopped */
+	LoopCount int
+	/** Max time of playback (reader) or recording (writer) in msec, 0 if unlimited */
+	MaxTime int64
+	/** Handler of file stream completion (optional), the completion is also raised as termination event */
+	OnComplete func(completion *AudioFileCompletion)
+}
+
+/** Loop file read until stopped */
+const AUDIO_FILE_LOOP_INFINITE = -1
+
+/** Id of termination event raised on file stream completion, the descriptor of which is *AudioFileCompletion */
+const AUDIO_FILE_COMPLETE_EVENT = 0
+
+/** Causes of file stream completion */
+type AudioFileCompletionCause = int
+
+const (
+	AUDIO_FILE_COMPLETION_EOF      AudioFileCompletionCause = iota /**< end of file read is reached (all the loops are played) */
+	AUDIO_FILE_COMPLETION_MAX_TIME                                 /**< max time of playback or recording is reached */
+	AUDIO_FILE_COMPLETION_MAX_SIZE                                 /**< max size of file written is reached */
+)
+
+/** File stream completion */
+type AudioFileCompletion struct {
+	/** Direction completed (FILE_READER or FILE_WRITER) */
+	Direction StreamDirection
+	/** Cause of completion */
+	Cause AudioFileCompletionCause
+	/** Time played or recorded in msec */
+	Duration int64
+}
+
+/** Format of raw (headerless) PCM file */
+type AudioFilePcmFormat struct {
+	/** Sampling rate */
+	SamplingRate uint16
+	/** Channel count (samples of the channels are interleaved) */
+	ChannelCount uint8
+	/** Sample width in bytes (1 - unsigned 8-bit, 2, 3, 4 - signed little-endian) */
+	SampleWidth uint8
+}
+
+/**
+ * Create audio file descriptor.
+ * @param mask the descriptor type (FILE_READER and/or FILE_WRITER)
+ */
+func AudioFileDescriptorCreate(mask StreamDirection) *AudioFileDescriptor {
+	return &AudioFileDescriptor{mask: mask}
 }
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"sync"
 )
 
 /** Audio file stream */
@@ -16,6 +17,35 @@ type AudioFileStream struct {
 	eof          bool
 	maxWriteSize int64
 	curWriteSize int64
+
+	bitsPerSample uint8
+
+	/** Offset of the audio data read (past the WAV header) */
+	readStart int64
+	/** End of the audio data read, -1 if it lasts till the end of the file */
+	readEnd int64
+	/** Is the file written of WAV format, the header is completed on close */
+	wavWriter bool
+	/** Max write size is reached and reported */
+	writeLimitReached bool
+	/** Format of headerless PCM file read, nil if the data is of the codec descriptor */
+	pcm *AudioFilePcmFormat
+
+	/** Number of loops left to play, negative to loop until stopped */
+	loopCount int
+	/** Max time of playback and recording in msec */
+	maxReadTime  int64
+	maxWriteTime int64
+	/** Time played and recorded in msec */
+	readTime  int64
+	writeTime int64
+	/** Completion handlers of reader and writer */
+	onReadComplete  func(completion *AudioFileCompletion)
+	onWriteComplete func(completion *AudioFileCompletion)
+	/** Is playback paused */
+	paused bool
+	/** Guard of the reader, controlled (paused, seeked) apart from the media processing */
+	mutex sync.Mutex
 }
 
 func AudioFileDestroy(stream *AudioStream) error {
@@ -23,14 +53,20 @@ func AudioFileDestroy(stream *AudioStream) error {
 	if !ok {
 		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
 	}
+	fileStream.mutex.Lock()
 	if fileStream.readHandle != nil {
 		err := fileStream.readHandle.Close()
 		if err != nil {
+			fileStream.mutex.Unlock()
 			return err
 		}
 		fileStream.readHandle = nil
 	}
+	fileStream.mutex.Unlock()
 	if fileStream.writeHandle != nil {
+		if err := fileStream.writerFinalize(stream); err != nil {
+			return err
+		}
 		err := fileStream.writeHandle.Close()
 		if err != nil {
 			return err
@@ -40,7 +76,30 @@ func AudioFileDestroy(stream *AudioStream) error {
 	return nil
 }
 
+/* Complete the header of WAV file written by the size of the audio data */
+func (fileStream *AudioFileStream) writerFinalize(as *AudioStream) error {
+	if !fileStream.wavWriter || fileStream.writeHandle == nil {
+		return nil
+	}
+	if _, err := fileStream.writeHandle.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	descriptor := as.TXDescriptor
+	if err := WavHeaderWrite(fileStream.writeHandle, descriptor.SamplingRate, descriptor.ChannelCount, fileStream.curWriteSize); err != nil {
+		return err
+	}
+	_, err := fileStream.writeHandle.Seek(0, io.SeekEnd)
+	return err
+}
+
 func AudioFileReaderOpen(stream *AudioStream, codec *Codec) error {
+	fileStream, ok := stream.Obj.(*AudioFileStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
+	}
+	if codec != nil && codec.Attribs != nil && fileStream.pcm == nil {
+		fileStream.bitsPerSample = codec.Attribs.BitsPerSample
+	}
 	return nil
 }
 
@@ -53,23 +112,120 @@ func AudioFileFrameRead(as *AudioStream, frame *Frame) error {
 	if !ok {
 		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
 	}
+	/* the completion is reported out of the lock, the handlers may control the stream */
+	fileStream.mutex.Lock()
+	completed, cause, err := fileStream.readerFrameRead(as, frame)
+	fileStream.mutex.Unlock()
+	if err != nil || !completed {
+		return err
+	}
+	return fileStream.complete(as, FILE_READER, cause)
+}
+
+/* Read the frame under the lock, report whether the reader is completed and by which cause */
+func (fileStream *AudioFileStream) readerFrameRead(as *AudioStream, frame *Frame) (bool, AudioFileCompletionCause, error) {
+	if fileStream.readHandle == nil || fileStream.eof || fileStream.paused {
+		return false, 0, nil
+	}
+	if fileStream.maxReadTime > 0 && fileStream.readTime >= fileStream.maxReadTime {
+		fileStream.eof = true
+		return true, AUDIO_FILE_COMPLETION_MAX_TIME, nil
+	}
+
+	data, err := fileStream.frameRead(as, frame)
+	if err != nil {
+		return false, 0, err
+	}
+	if data == nil && fileStream.loopCount != 0 {
+		/* replay the file from the start of the audio data */
+		if fileStream.loopCount > 0 {
+			fileStream.loopCount--
+		}
+		if _, err := fileStream.readHandle.Seek(fileStream.readStart, io.SeekStart); err != nil {
+			return false, 0, err
+		}
+		if data, err = fileStream.frameRead(as, frame); err != nil {
+			return false, 0, err
+		}
+	}
+	if data == nil {
+		/* the rest shorter than a frame is not played */
+		fileStream.eof = true
+		return true, AUDIO_FILE_COMPLETION_EOF, nil
+	}
+	fileStream.readTime += as.RXDescriptor.CodecFrameDurationGet()
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	return false, 0, codecFrameDataSet(&frame.CodecFrame, data)
+}
 
-	// todo(应该是按frame一帧一帧来读吧, 这里先一次性读完处理, 等确认frame一帧是多长再来改)
-	if fileStream.readHandle != nil && !fileStream.eof {
-		n, err := io.CopyN(frame.CodecFrame.Buffer, fileStream.readHandle, frame.CodecFrame.Size)
-		//n, err := frame.CodecFrame.Buffer.ReadFrom(fileStream.readHandle)
+/* Read audio data of the frame, nil if the rest of the file is shorter than a frame */
+func (fileStream *AudioFileStream) frameRead(as *AudioStream, frame *Frame) ([]byte, error) {
+	size := frame.CodecFrame.Size
+	if size <= 0 && as.RXDescriptor != nil {
+		size = as.RXDescriptor.CodecLinearFrameSizeGet()
+	}
+	frameSize := size
+	if fileStream.pcm != nil {
+		/* the samples are converted to 16-bit ones */
+		size = size / BYTES_PER_SAMPLE * int64(fileStream.pcm.SampleWidth)
+	}
+	if fileStream.readEnd >= 0 {
+		/* the data chunk may be followed by other chunks of WAV file */
+		pos, err := fileStream.readHandle.Seek(0, io.SeekCurrent)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		// 刚好读了1个frame帧
-		if n == frame.CodecFrame.Size {
-			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		if pos+size > fileStream.readEnd {
+			size = 0
+		}
+	}
+	data := make([]byte, size)
+	n, err := io.ReadFull(fileStream.readHandle, data)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	if size == 0 || int64(n) < size {
+		return nil, nil
+	}
+	if fileStream.pcm != nil {
+		data = pcmSamplesConvert(data, int(fileStream.pcm.SampleWidth), frameSize)
+	}
+	return data, nil
+}
+
+/* Report completion of the reader or writer to the handler and the termination */
+func (fileStream *AudioFileStream) complete(as *AudioStream, direction StreamDirection, cause AudioFileCompletionCause) error {
+	completion := &AudioFileCompletion{Direction: direction, Cause: cause}
+	fileStream.mutex.Lock()
+	handler := fileStream.onReadComplete
+	completion.Duration = fileStream.readTime
+	fileStream.mutex.Unlock()
+	if direction == FILE_WRITER {
+		handler = fileStream.onWriteComplete
+		completion.Duration = fileStream.writeTime
+	}
+	if handler != nil {
+		handler(completion)
+	}
+	return AudioFileEventRaise(as, AUDIO_FILE_COMPLETE_EVENT, completion)
+}
+
+/* Convert PCM samples of the width to linear 16-bit (little-endian) ones */
+func pcmSamplesConvert(data []byte, width int, size int64) []byte {
+	if width == BYTES_PER_SAMPLE {
+		return data
+	}
+	linear := make([]byte, size)
+	for i, j := 0, 0; i+width <= len(data) && j+1 < len(linear); i, j = i+width, j+BYTES_PER_SAMPLE {
+		if width == 1 {
+			/* 8-bit samples are unsigned */
+			linear[j], linear[j+1] = 0, data[i]-128
 		} else {
-			fileStream.eof = true
-			return AudioFileEventRaise(as, 0, nil)
+			/* the most significant bytes */
+			linear[j], linear[j+1] = data[i+width-2], data[i+width-1]
 		}
 	}
-	return nil
+	return linear
 }
 
 func AudioFileWriterOpen(as *AudioStream, codec *Codec) error {
@@ -77,7 +233,11 @@ func AudioFileWriterOpen(as *AudioStream, codec *Codec) error {
 }
 
 func AudioFileWriterClose(as *AudioStream) error {
-	return nil
+	fileStream, ok := as.Obj.(*AudioFileStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
+	}
+	return fileStream.writerFinalize(as)
 }
 
 func AudioFileFrameWrite(as *AudioStream, frame *Frame) error {
@@ -85,17 +245,26 @@ func AudioFileFrameWrite(as *AudioStream, frame *Frame) error {
 	if !ok {
 		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
 	}
-	if fileStream.writeHandle != nil &&
-		(fileStream.maxWriteSize > 0 || fileStream.curWriteSize < fileStream.maxWriteSize) {
-		//n, err := frame.CodecFrame.Buffer.WriteTo(fileStream.writeHandle)
-		n, err := io.CopyN(fileStream.writeHandle, frame.CodecFrame.Buffer, frame.CodecFrame.Size)
-		if err != nil {
-			return err
-		}
-		fileStream.curWriteSize += n
-		if fileStream.curWriteSize >= fileStream.maxWriteSize {
-			return AudioFileEventRaise(as, 0, nil)
-		}
+	if fileStream.writeHandle == nil || fileStream.writeLimitReached || (frame.Type&MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		return nil
+	}
+	data := codecFrameDataGet(&frame.CodecFrame)
+	if fileStream.maxWriteSize > 0 && fileStream.curWriteSize+int64(len(data)) > fileStream.maxWriteSize {
+		data = data[:fileStream.maxWriteSize-fileStream.curWriteSize]
+	}
+	n, err := fileStream.writeHandle.Write(data)
+	fileStream.curWriteSize += int64(n)
+	if err != nil {
+		return err
+	}
+	fileStream.writeTime += as.TXDescriptor.CodecFrameDurationGet()
+	if fileStream.maxWriteSize > 0 && fileStream.curWriteSize >= fileStream.maxWriteSize {
+		fileStream.writeLimitReached = true
+		return fileStream.complete(as, FILE_WRITER, AUDIO_FILE_COMPLETION_MAX_SIZE)
+	}
+	if fileStream.maxWriteTime > 0 && fileStream.writeTime >= fileStream.maxWriteTime {
+		fileStream.writeLimitReached = true
+		return fileStream.complete(as, FILE_WRITER, AUDIO_FILE_COMPLETION_MAX_TIME)
 	}
 	return nil
 }
@@ -118,7 +287,7 @@ var vtable AudioStreamVTable = AudioStreamVTable{
  */
 func FileStreamCreate(termination *Termination) *AudioStream {
 	var (
-		fileStream   = &AudioFileStream{}
+		fileStream   = &AudioFileStream{readEnd: -1}
 		capabilities = StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX)
 		audioStream  = AudioStreamCreate(fileStream, &vtable, capabilities)
 	)
@@ -141,26 +310,257 @@ func FileStreamModify(as *AudioStream, descriptor *AudioFileDescriptor) error {
 		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
 	}
 	if (descriptor.mask & FILE_READER) > 0 {
+		fileStream.mutex.Lock()
+		defer fileStream.mutex.Unlock()
 		if fileStream.readHandle != nil {
 			fileStream.readHandle.Close()
 		}
 		fileStream.readHandle = descriptor.ReadHandle
 		fileStream.eof = false
+		fileStream.readStart = 0
+		fileStream.readEnd = -1
+		fileStream.pcm = descriptor.PcmFormat
+		fileStream.loopCount = descriptor.LoopCount
+		fileStream.maxReadTime = descriptor.MaxTime
+		fileStream.readTime = 0
+		fileStream.onReadComplete = descriptor.OnComplete
+		fileStream.paused = false
 		as.direction |= FILE_READER
+		as.RXDescriptor = descriptor.CodecDescriptor
+		if fileStream.pcm != nil {
+			if err := fileStream.readerPcmFormatSet(as); err != nil {
+				return err
+			}
+		} else if err := fileStream.readerHeaderRead(as); err != nil {
+			return err
+		}
 	}
 	if (descriptor.mask & FILE_WRITER) > 0 {
 		if fileStream.writeHandle != nil {
+			if err := fileStream.writerFinalize(as); err != nil {
+				return err
+			}
 			fileStream.writeHandle.Close()
 		}
 		fileStream.writeHandle = descriptor.WriteHandle
 		fileStream.maxWriteSize = descriptor.MaxWriteSize
 		fileStream.curWriteSize = 0
+		fileStream.writeLimitReached = false
+		fileStream.maxWriteTime = descriptor.MaxTime
+		fileStream.writeTime = 0
+		fileStream.onWriteComplete = descriptor.OnComplete
+		fileStream.wavWriter = descriptor.Format == AUDIO_FILE_FORMAT_WAV
 		as.direction |= FILE_WRITER
 		as.TXDescriptor = descriptor.CodecDescriptor
+		if fileStream.wavWriter && fileStream.writeHandle != nil {
+			if as.TXDescriptor == nil {
+				as.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+			}
+			if !CodecLPcmDescriptorMatch(as.TXDescriptor) || as.TXDescriptor.ChannelCount != 1 {
+				return fmt.Errorf("WAV file of %s is not supported, linear mono audio is expected", as.TXDescriptor.Name)
+			}
+			/* the sizes are completed on close */
+			if err := WavHeaderWrite(fileStream.writeHandle, as.TXDescriptor.SamplingRate, as.TXDescriptor.ChannelCount, 0); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+/* Set format of headerless PCM file read, the audio of which is converted to linear 16-bit one */
+func (fileStream *AudioFileStream) readerPcmFormatSet(as *AudioStream) error {
+	pcm := fileStream.pcm
+	if pcm.SamplingRate == 0 || pcm.ChannelCount == 0 || pcm.SampleWidth == 0 || pcm.SampleWidth > 4 {
+		return fmt.Errorf("invalid PCM format %d Hz, %d channels, %d bytes per sample", pcm.SamplingRate, pcm.ChannelCount, pcm.SampleWidth)
 	}
+	if as.RXDescriptor == nil {
+		as.RXDescriptor = CodecLPcmDescriptorCreate(pcm.SamplingRate, pcm.ChannelCount)
+	} else if !CodecLPcmDescriptorMatch(as.RXDescriptor) || as.RXDescriptor.SamplingRate != pcm.SamplingRate ||
+		as.RXDescriptor.ChannelCount != pcm.ChannelCount {
+		return fmt.Errorf("PCM file of %d Hz does not match %s/%d", pcm.SamplingRate, as.RXDescriptor.Name, as.RXDescriptor.SamplingRate)
+	}
+	fileStream.bitsPerSample = pcm.SampleWidth * 8
 	return nil
 }
 
+/* Detect WAV file read by its header, the audio data of which is read then */
+func (fileStream *AudioFileStream) readerHeaderRead(as *AudioStream) error {
+	if fileStream.readHandle == nil {
+		return nil
+	}
+	magic := make([]byte, 12)
+	n, _ := io.ReadFull(fileStream.readHandle, magic)
+	if _, err := fileStream.readHandle.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if !WavHeaderCheck(magic[:n]) {
+		return nil
+	}
+	header, err := WavHeaderRead(fileStream.readHandle)
+	if err != nil {
+		return err
+	}
+	if as.RXDescriptor == nil {
+		as.RXDescriptor = CodecLPcmDescriptorCreate(header.SamplingRate, header.ChannelCount)
+	} else if !CodecLPcmDescriptorMatch(as.RXDescriptor) || as.RXDescriptor.SamplingRate != header.SamplingRate {
+		return fmt.Errorf("WAV file of %d Hz does not match %s/%d", header.SamplingRate, as.RXDescriptor.Name, as.RXDescriptor.SamplingRate)
+	}
+	fileStream.bitsPerSample = uint8(header.BitsPerSample)
+	fileStream.readStart = header.DataOffset
+	fileStream.readEnd = header.DataOffset + header.DataSize
+	return nil
+}
+
+/**
+ * Seek file stream reader (skip forward or backward).
+ * @param stream file stream to seek
+ * @param offset the offset in msec relative to the current position, negative to rewind
+ */
+func FileStreamSeek(as *AudioStream, offset int64) error {
+	return fileStreamSeek(as, offset, io.SeekCurrent)
+}
+
+/**
+ * Seek file stream reader to the position.
+ * @param stream file stream to seek
+ * @param position the position in msec from the start of the audio
+ */
+func FileStreamSeekMs(as *AudioStream, position int64) error {
+	return fileStreamSeek(as, position, io.SeekStart)
+}
+
+/* Get sampling rate and sample size of the audio read */
+func (fileStream *AudioFileStream) readerSampleSizeGet(as *AudioStream) (int64, int64, error) {
+	if fileStream.readHandle == nil {
+		return 0, 0, fmt.Errorf("file stream has no reader")
+	}
+	if as.RXDescriptor == nil || as.RXDescriptor.SamplingRate == 0 {
+		return 0, 0, fmt.Errorf("file stream has no codec descriptor")
+	}
+
+	bitsPerSample := int64(fileStream.bitsPerSample)
+	if bitsPerSample == 0 {
+		bitsPerSample = BITS_PER_SAMPLE
+	}
+	channelCount := int64(as.RXDescriptor.ChannelCount)
+	if channelCount == 0 {
+		channelCount = 1
+	}
+	sampleSize := channelCount * bitsPerSample / 8
+	if sampleSize == 0 {
+		sampleSize = 1
+	}
+	return int64(as.RXDescriptor.SamplingRate), sampleSize, nil
+}
+
+func fileStreamSeek(as *AudioStream, offset int64, whence int) error {
+	fileStream, ok := as.Obj.(*AudioFileStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
+	}
+	fileStream.mutex.Lock()
+	defer fileStream.mutex.Unlock()
+	samplingRate, sampleSize, err := fileStream.readerSampleSizeGet(as)
+	if err != nil {
+		return err
+	}
+	/* seek to the boundary of the sample */
+	delta := offset * samplingRate / 1000 * sampleSize
+
+	cur := fileStream.readStart
+	if whence == io.SeekCurrent {
+		if cur, err = fileStream.readHandle.Seek(0, io.SeekCurrent); err != nil {
+			return err
+		}
+	}
+	end := fileStream.readEnd
+	if end < 0 {
+		info, err := fileStream.readHandle.Stat()
+		if err != nil {
+			return err
+		}
+		end = info.Size()
+	}
+	pos := cur + delta
+	if pos < fileStream.readStart {
+		pos = fileStream.readStart
+	} else if pos > end {
+		pos = end
+	}
+	if _, err = fileStream.readHandle.Seek(pos, io.SeekStart); err != nil {
+		return err
+	}
+	if pos < end {
+		fileStream.eof = false
+	}
+	return nil
+}
+
+/**
+ * Get position of file stream reader.
+ * @param stream file stream
+ * @return the position in msec from the start of the audio
+ */
+func FileStreamPositionGet(as *AudioStream) (int64, error) {
+	fileStream, ok := as.Obj.(*AudioFileStream)
+	if !ok {
+		return 0, fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
+	}
+	fileStream.mutex.Lock()
+	defer fileStream.mutex.Unlock()
+	samplingRate, sampleSize, err := fileStream.readerSampleSizeGet(as)
+	if err != nil {
+		return 0, err
+	}
+	cur, err := fileStream.readHandle.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	return (cur - fileStream.readStart) / sampleSize * 1000 / samplingRate, nil
+}
+
+/**
+ * Pause playback of file stream, no audio is read until it is resumed.
+ * @param stream file stream to pause
+ */
+func FileStreamPause(as *AudioStream) error {
+	return fileStreamPausedSet(as, true)
+}
+
+/**
+ * Resume paused playback of file stream.
+ * @param stream file stream to resume
+ */
+func FileStreamResume(as *AudioStream) error {
+	return fileStreamPausedSet(as, false)
+}
+
+func fileStreamPausedSet(as *AudioStream, paused bool) error {
+	fileStream, ok := as.Obj.(*AudioFileStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *AudioFileStream")
+	}
+	fileStream.mutex.Lock()
+	defer fileStream.mutex.Unlock()
+	if fileStream.readHandle == nil {
+		return fmt.Errorf("file stream has no reader")
+	}
+	fileStream.paused = paused
+	return nil
+}
+
+/** Check whether playback of file stream is paused */
+func FileStreamPausedGet(as *AudioStream) bool {
+	fileStream, ok := as.Obj.(*AudioFileStream)
+	if !ok {
+		return false
+	}
+	fileStream.mutex.Lock()
+	defer fileStream.mutex.Unlock()
+	return fileStream.paused
+}
+
 func AudioFileEventRaise(as *AudioStream, eventId int, descriptor interface{}) error {
 	if as.termination != nil && as.termination.EventHandler != nil {
 		return as.termination.EventHandler(as.termination, eventId, descriptor)
@@ -0,0 +1,462 @@
+package mpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestFileStreamWav(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "utterance.wav")
+	factory := FileTerminationFactoryCreate()
+
+	/* record 100 msec of a tone */
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := factory.TerminationCreate(nil)
+	writer := AudioFileDescriptorCreate(FILE_WRITER)
+	writer.CodecDescriptor = CodecLPcmDescriptorCreate(16000, 1)
+	writer.WriteHandle = file
+	writer.Format = AUDIO_FILE_FORMAT_WAV
+	if err := recorder.TerminationAdd(writer); err != nil {
+		t.Fatal(err)
+	}
+	sink := recorder.TerminationAudioStreamGet()
+	tone := binaryx.Int16SliceToByteSlice(loudnessTestSine(500, 16000, 100, 10000))
+	for i := 0; i < 10; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, tone[i*320:(i+1)*320])
+		if err := sink.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AudioStreamDestroy(sink); err != nil {
+		t.Fatal(err)
+	}
+
+	/* play it back */
+	if file, err = os.Open(path); err != nil {
+		t.Fatal(err)
+	}
+	player := factory.TerminationCreate(nil)
+	events := 0
+	player.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		events++
+		return nil
+	}
+	reader := AudioFileDescriptorCreate(FILE_READER)
+	reader.ReadHandle = file
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	source := player.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(source)
+	if source.RXDescriptor == nil || source.RXDescriptor.SamplingRate != 16000 || !CodecLPcmDescriptorMatch(source.RXDescriptor) {
+		t.Fatalf("WAV format is not detected: %+v", source.RXDescriptor)
+	}
+	var played []byte
+	for i := 0; i < 12; i++ {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO != 0 {
+			played = append(played, codecFrameDataGet(&frame.CodecFrame)...)
+		}
+	}
+	if !bytes.Equal(played, tone) || events != 1 {
+		t.Fatalf("%d bytes played (want %d), %d events", len(played), len(tone), events)
+	}
+
+	/* rewind by 30 msec from the end */
+	if err := FileStreamSeek(source, -30); err != nil {
+		t.Fatal(err)
+	}
+	frames := 0
+	for {
+		frame := &Frame{}
+		source.AudioStreamFrameRead(frame)
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			break
+		}
+		frames++
+	}
+	if frames != 3 {
+		t.Fatalf("%d frames played after rewind, want 3", frames)
+	}
+}
+
+func TestWavHeaderRead(t *testing.T) {
+	var buf bytes.Buffer
+	WavHeaderWrite(&buf, 8000, 1, 4)
+	data := buf.Bytes()
+
+	/* LIST chunk between fmt and data chunks is skipped */
+	list := append([]byte("LIST"), 3, 0, 0, 0, 'a', 'b', 'c', 0)
+	wav := append(append(append([]byte(nil), data[:36]...), list...), data[36:]...)
+	wav = append(wav, 1, 2, 3, 4)
+	binary.LittleEndian.PutUint32(wav[4:8], uint32(len(wav)-8))
+	header, err := WavHeaderRead(bytes.NewReader(wav))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.SamplingRate != 8000 || header.DataOffset != int64(len(wav)-4) || header.DataSize != 4 {
+		t.Fatalf("unexpected header %+v", header)
+	}
+
+	/* 8-bit audio is not supported */
+	binary.LittleEndian.PutUint16(data[34:36], 8)
+	if _, err := WavHeaderRead(bytes.NewReader(data)); err == nil {
+		t.Fatalf("8-bit WAV is accepted")
+	}
+}
+
+func TestFileStreamPcm(t *testing.T) {
+	/* 20 msec of 24-bit stereo audio at 8 kHz */
+	samples := loudnessTestSine(500, 8000, 20, 10000)
+	var raw []byte
+	for _, sample := range samples {
+		for channel := 0; channel < 2; channel++ {
+			raw = append(raw, 0x5a, byte(sample), byte(sample>>8))
+		}
+	}
+	path := filepath.Join(t.TempDir(), "fixture.pcm")
+	if err := ioutil.WriteFile(path, raw, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := FileTerminationFactoryCreate().TerminationCreate(nil)
+	reader := AudioFileDescriptorCreate(FILE_READER)
+	reader.ReadHandle = file
+	reader.PcmFormat = &AudioFilePcmFormat{SamplingRate: 8000, ChannelCount: 2, SampleWidth: 3}
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	source := player.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(source)
+	if source.RXDescriptor.SamplingRate != 8000 || source.RXDescriptor.ChannelCount != 2 {
+		t.Fatalf("unexpected descriptor %+v", source.RXDescriptor)
+	}
+
+	var played []int16
+	for i := 0; i < 3; i++ {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO != 0 {
+			data, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			played = append(played, data...)
+		}
+	}
+	if len(played) != 2*len(samples) {
+		t.Fatalf("%d samples played, want %d", len(played), 2*len(samples))
+	}
+	for i, sample := range samples {
+		if played[2*i] != sample || played[2*i+1] != sample {
+			t.Fatalf("sample %d is %d/%d, want %d", i, played[2*i], played[2*i+1], sample)
+		}
+	}
+
+	/* rewind by 10 msec: 80 frames of 6 bytes */
+	if err := FileStreamSeek(source, -10); err != nil {
+		t.Fatal(err)
+	}
+	if pos, _ := file.Seek(0, io.SeekCurrent); pos != int64(len(raw))-480 {
+		t.Fatalf("position %d after rewind, want %d", pos, len(raw)-480)
+	}
+
+	/* invalid sample width */
+	reader.PcmFormat = &AudioFilePcmFormat{SamplingRate: 8000, ChannelCount: 1, SampleWidth: 5}
+	if err := FileStreamModify(source, reader); err == nil {
+		t.Fatalf("invalid PCM format is accepted")
+	}
+}
+
+func TestFileStreamLoop(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "prompt.pcm")
+	if err := ioutil.WriteFile(path, make([]byte, 3*160), 0644); err != nil {
+		t.Fatal(err)
+	}
+	factory := FileTerminationFactoryCreate()
+	play := func(loopCount int, maxTime int64) (int, []*AudioFileCompletion) {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		player := factory.TerminationCreate(nil)
+		var completions []*AudioFileCompletion
+		player.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+			if eventId == AUDIO_FILE_COMPLETE_EVENT {
+				completions = append(completions, descriptor.(*AudioFileCompletion))
+			}
+			return nil
+		}
+		reader := AudioFileDescriptorCreate(FILE_READER)
+		reader.CodecDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+		reader.ReadHandle = file
+		reader.LoopCount = loopCount
+		reader.MaxTime = maxTime
+		if err := player.TerminationAdd(reader); err != nil {
+			t.Fatal(err)
+		}
+		source := player.TerminationAudioStreamGet()
+		defer AudioStreamDestroy(source)
+		frames := 0
+		for i := 0; i < 20; i++ {
+			frame := &Frame{}
+			if err := source.AudioStreamFrameRead(frame); err != nil {
+				t.Fatal(err)
+			}
+			if frame.Type&MEDIA_FRAME_TYPE_AUDIO != 0 {
+				frames++
+			}
+		}
+		return frames, completions
+	}
+
+	/* played twice */
+	frames, completions := play(1, 0)
+	if frames != 6 || len(completions) != 1 || completions[0].Cause != AUDIO_FILE_COMPLETION_EOF || completions[0].Duration != 60 {
+		t.Fatalf("%d frames played, completions %+v", frames, completions)
+	}
+	/* looped until max time */
+	frames, completions = play(AUDIO_FILE_LOOP_INFINITE, 80)
+	if frames != 8 || len(completions) != 1 || completions[0].Cause != AUDIO_FILE_COMPLETION_MAX_TIME {
+		t.Fatalf("%d frames played, completions %+v", frames, completions)
+	}
+}
+
+func TestFileStreamRecordMaxTime(t *testing.T) {
+	file, err := os.Create(filepath.Join(t.TempDir(), "record.pcm"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := FileTerminationFactoryCreate().TerminationCreate(nil)
+	var completion *AudioFileCompletion
+	writer := AudioFileDescriptorCreate(FILE_WRITER)
+	writer.CodecDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	writer.WriteHandle = file
+	writer.MaxTime = 50
+	writer.OnComplete = func(c *AudioFileCompletion) { completion = c }
+	if err := recorder.TerminationAdd(writer); err != nil {
+		t.Fatal(err)
+	}
+	sink := recorder.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(sink)
+	for i := 0; i < 10; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, make([]byte, 160))
+		if err := sink.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	info, _ := file.Stat()
+	if completion == nil || completion.Direction != FILE_WRITER || completion.Cause != AUDIO_FILE_COMPLETION_MAX_TIME || info.Size() != 5*160 {
+		t.Fatalf("completion %+v, %d bytes recorded", completion, info.Size())
+	}
+}
+
+func TestFileStreamPauseSeek(t *testing.T) {
+	/* 100 msec prompt, each frame filled with its index */
+	var prompt []byte
+	for i := 0; i < 10; i++ {
+		prompt = append(prompt, bytes.Repeat([]byte{byte(i)}, 160)...)
+	}
+	path := filepath.Join(t.TempDir(), "prompt.pcm")
+	if err := ioutil.WriteFile(path, prompt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := FileTerminationFactoryCreate().TerminationCreate(nil)
+	reader := AudioFileDescriptorCreate(FILE_READER)
+	reader.CodecDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	reader.ReadHandle = file
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	source := player.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(source)
+	read := func() int {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			return -1
+		}
+		return int(codecFrameDataGet(&frame.CodecFrame)[0])
+	}
+
+	read()
+	read()
+	if err := FileStreamPause(source); err != nil {
+		t.Fatal(err)
+	}
+	if index := read(); index != -1 || !FileStreamPausedGet(source) {
+		t.Fatalf("frame %d is played while paused", index)
+	}
+	FileStreamResume(source)
+	if index := read(); index != 2 {
+		t.Fatalf("frame %d is played after resume, want 2", index)
+	}
+	if err := FileStreamSeekMs(source, 70); err != nil {
+		t.Fatal(err)
+	}
+	if position, _ := FileStreamPositionGet(source); position != 70 {
+		t.Fatalf("position %d, want 70", position)
+	}
+	if index := read(); index != 7 {
+		t.Fatalf("frame %d is played after seek, want 7", index)
+	}
+}
+
+func TestFileStreamSeekClamp(t *testing.T) {
+	/* 100 msec WAV prompt, each frame filled with its index, followed by another chunk */
+	var wav bytes.Buffer
+	if err := WavHeaderWrite(&wav, 8000, 1, 1600); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		wav.Write(bytes.Repeat([]byte{byte(i)}, 160))
+	}
+	wav.WriteString("LIST")
+	binary.Write(&wav, binary.LittleEndian, uint32(160))
+	wav.Write(bytes.Repeat([]byte{0xff}, 160))
+	path := filepath.Join(t.TempDir(), "prompt.wav")
+	if err := ioutil.WriteFile(path, wav.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := FileTerminationFactoryCreate().TerminationCreate(nil)
+	events := 0
+	player.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		events++
+		return nil
+	}
+	reader := AudioFileDescriptorCreate(FILE_READER)
+	reader.ReadHandle = file
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	source := player.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(source)
+	read := func() int {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			return -1
+		}
+		return int(codecFrameDataGet(&frame.CodecFrame)[0])
+	}
+	seek := func(offset int64, position int64) {
+		if err := FileStreamSeek(source, offset); err != nil {
+			t.Fatal(err)
+		}
+		if current, err := FileStreamPositionGet(source); err != nil || current != position {
+			t.Fatalf("position %d after seek by %d, want %d: %v", current, offset, position, err)
+		}
+	}
+
+	read()
+	read()
+	/* skipping past the end is clamped to the end of the data chunk, the other chunk is not played */
+	seek(1000, 100)
+	if index := read(); index != -1 || events != 1 {
+		t.Fatalf("frame %d played past the end, %d events", index, events)
+	}
+	seek(-10, 90)
+	if index := read(); index != 9 {
+		t.Fatalf("frame %d played after rewind from the end, want 9", index)
+	}
+	if index := read(); index != -1 || events != 2 {
+		t.Fatalf("frame %d played past the end, %d events", index, events)
+	}
+
+	/* rewinding before the start is clamped to the start of the data chunk */
+	seek(-1000, 0)
+	if index := read(); index != 0 {
+		t.Fatalf("frame %d played after rewind, want 0", index)
+	}
+	for position, want := range map[int64]int64{-50: 0, 40: 40, 500: 100} {
+		if err := FileStreamSeekMs(source, position); err != nil {
+			t.Fatal(err)
+		}
+		if current, _ := FileStreamPositionGet(source); current != want {
+			t.Fatalf("position %d after seek to %d, want %d", current, position, want)
+		}
+	}
+}
+
+func TestFileStreamControlConcurrent(t *testing.T) {
+	/* 100 msec looped prompt, each frame filled with its index */
+	var prompt []byte
+	for i := 0; i < 10; i++ {
+		prompt = append(prompt, bytes.Repeat([]byte{byte(i)}, 160)...)
+	}
+	path := filepath.Join(t.TempDir(), "prompt.pcm")
+	if err := ioutil.WriteFile(path, prompt, 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	player := FileTerminationFactoryCreate().TerminationCreate(nil)
+	reader := AudioFileDescriptorCreate(FILE_READER)
+	reader.CodecDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	reader.ReadHandle = file
+	reader.LoopCount = -1
+	if err := player.TerminationAdd(reader); err != nil {
+		t.Fatal(err)
+	}
+	source := player.TerminationAudioStreamGet()
+	defer AudioStreamDestroy(source)
+
+	/* the request processing pauses and seeks the prompt while the media processing reads it */
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			FileStreamPause(source)
+			FileStreamSeek(source, 30)
+			FileStreamSeekMs(source, int64(i%10)*10)
+			FileStreamPositionGet(source)
+			FileStreamResume(source)
+		}
+	}()
+	for i := 0; i < 1000; i++ {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			continue
+		}
+		/* the frame is read from the boundary of the frame written */
+		data := codecFrameDataGet(&frame.CodecFrame)
+		if !bytes.Equal(data, bytes.Repeat(data[:1], 160)) {
+			t.Fatalf("frame read across the boundary: %v", data)
+		}
+	}
+	<-done
+}
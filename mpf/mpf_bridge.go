@@ -15,18 +15,33 @@ type Bridge struct {
 	sink *AudioStream
 	/** Codec used in case of null bridge */
 	codec *Codec
+	/** Codec manager to get codecs by (renegotiated) descriptors */
+	codecManager *CodecManager
 	/** Media frame used to read data from source and write it to sink */
 	frame Frame
+	/** Pacer of the frames of the sink, if the audio is re-framed */
+	pacer *framePacer
 }
 
-func BridgeProcess(object *Object) error {
-	bridge := &Bridge{
-		base: object,
+/** Process bridge: read frame from source and write it to sink */
+func (bridge *Bridge) BridgeProcess() error {
+	if bridge.pacer != nil {
+		/* frames of the sink are processed as they are due */
+		for count := bridge.pacer.tick(bridge.base.TickDuration); count > 0; count-- {
+			if err := bridge.frameProcess(); err != nil {
+				return err
+			}
+		}
+		return nil
 	}
+	return bridge.frameProcess()
+}
+
+/* Read frame from source and write it to sink */
+func (bridge *Bridge) frameProcess() error {
 	bridge.frame.Type = MEDIA_FRAME_TYPE_NONE
 	bridge.frame.Marker = MPF_MARKER_NONE
-	// todo(source当前是空指针, panic)
-	err := bridge.source.VTable.ReadFrame(bridge.source, &bridge.frame)
+	err := bridge.source.AudioStreamFrameRead(&bridge.frame)
 	if err != nil {
 		return err
 	}
@@ -35,16 +50,14 @@ func BridgeProcess(object *Object) error {
 		bridge.frame.CodecFrame.Buffer.Reset()
 	}
 
-	return bridge.sink.VTable.WriteFrame(bridge.sink, &bridge.frame)
+	return bridge.sink.AudioStreamFrameWrite(&bridge.frame)
 }
-func NullBridgeProcess(object *Object) error {
-	bridge := &Bridge{
-		base: object,
-	}
+
+/** Process null (passthrough) bridge: relay encoded frame from source to sink as is */
+func (bridge *Bridge) NullBridgeProcess() error {
 	bridge.frame.Type = MEDIA_FRAME_TYPE_NONE
 	bridge.frame.Marker = MPF_MARKER_NONE
-	// todo(source当前是空指针, panic)
-	err := bridge.source.VTable.ReadFrame(bridge.source, &bridge.frame)
+	err := bridge.source.AudioStreamFrameRead(&bridge.frame)
 	if err != nil {
 		return err
 	}
@@ -57,14 +70,25 @@ func NullBridgeProcess(object *Object) error {
 		}
 	}
 
-	return bridge.sink.VTable.WriteFrame(bridge.sink, &bridge.frame)
+	return bridge.sink.AudioStreamFrameWrite(&bridge.frame)
 }
 
-func BridgeDestroy(object *Object) error {
-	bridge := &Bridge{
-		base: object,
+/** Process event bridge: relay named events of source to sink, the audio (if any) is not relayed */
+func (bridge *Bridge) EventBridgeProcess() error {
+	bridge.frame.Type = MEDIA_FRAME_TYPE_NONE
+	bridge.frame.Marker = MPF_MARKER_NONE
+	err := bridge.source.AudioStreamFrameRead(&bridge.frame)
+	if err != nil {
+		return err
 	}
-	// todo(source, sink 当前是空指针, panic)
+
+	bridge.frame.Type &= MEDIA_FRAME_TYPE_EVENT
+	bridge.frame.CodecFrame.Buffer.Reset()
+	return bridge.sink.AudioStreamFrameWrite(&bridge.frame)
+}
+
+/** Destroy bridge: close source and sink */
+func (bridge *Bridge) BridgeDestroy() error {
 	err := bridge.source.AudioStreamRXClose()
 	if err != nil {
 		return err
@@ -72,6 +96,53 @@ func BridgeDestroy(object *Object) error {
 	return bridge.sink.AudioStreamTXClose()
 }
 
+/**
+ * Update bridge to the codecs renegotiated (e.g. by re-INVITE) for the source and sink:
+ * the decoder/encoder stages and the streams behind them are re-opened with the new codecs,
+ * keeping the topology. The bridge must be re-created if the topology does not fit anymore
+ * (e.g. passthrough is not possible or sampling rates differ).
+ */
+func (bridge *Bridge) BridgeCodecUpdate() error {
+	source, sink := bridge.source, bridge.sink
+	if bridge.codec != nil {
+		/* null bridge */
+		if !CodecDescriptorsMatch(source.RXDescriptor, sink.TXDescriptor) && !BridgePassthroughNegotiate(source, sink) {
+			return fmt.Errorf("passthrough of %s to %s is not possible, bridge must be re-created", source.RXDescriptor.Name, sink.TXDescriptor.Name)
+		}
+		codec, err := bridge.codecManager.CodecManagerCodecGet(source.RXDescriptor)
+		if err != nil {
+			return err
+		}
+		bridge.codec = codec
+		bridge.frame.CodecFrame.Size = codec.CodecFrameSizeGet(source.RXDescriptor)
+		return nil
+	}
+
+	if decoder, ok := source.Obj.(*Decoder); ok {
+		codec, err := bridge.codecManager.CodecManagerCodecGet(decoder.Source.RXDescriptor)
+		if err != nil {
+			return err
+		}
+		if err := source.AudioStreamCodecUpdate(codec); err != nil {
+			return err
+		}
+	}
+	if encoder, ok := sink.Obj.(*Encoder); ok {
+		codec, err := bridge.codecManager.CodecManagerCodecGet(encoder.Sink.TXDescriptor)
+		if err != nil {
+			return err
+		}
+		if err := sink.AudioStreamCodecUpdate(codec); err != nil {
+			return err
+		}
+	}
+	if source.RXDescriptor.SamplingRate != sink.TXDescriptor.SamplingRate {
+		return fmt.Errorf("sampling rates %d and %d differ, bridge must be re-created", source.RXDescriptor.SamplingRate, sink.TXDescriptor.SamplingRate)
+	}
+	bridge.frame.CodecFrame.Size = source.RXDescriptor.CodecLinearFrameSizeGet()
+	return nil
+}
+
 func BridgeBaseCreate(source *AudioStream, sink *AudioStream, name string) (*Bridge, error) {
 	if source == nil || sink == nil {
 		return nil, fmt.Errorf("source or sink is nil")
@@ -85,13 +156,29 @@ func BridgeBaseCreate(source *AudioStream, sink *AudioStream, name string) (*Bri
 		frame:  Frame{},
 	}
 
-	bridge.base.Destroy = BridgeDestroy
-	bridge.base.Process = BridgeProcess
+	bridge.base.Sheddable = AudioStreamSheddable(source)
+	bridge.base.Destroy = func(object *Object) error {
+		return bridge.BridgeDestroy()
+	}
+	bridge.base.Process = func(object *Object) error {
+		return bridge.BridgeProcess()
+	}
+	bridge.base.UpdateCodec = func(object *Object) error {
+		return bridge.BridgeCodecUpdate()
+	}
 
 	return bridge, nil
 }
 
 func LinearBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, name string) (*Object, error) {
+	bridge, err := linearBridgeCreate(source, sink, codecManager, name)
+	if err != nil {
+		return nil, err
+	}
+	return bridge.base, nil
+}
+
+func linearBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, name string) (*Bridge, error) {
 	var (
 		descriptor *CodecDescriptor
 		frameSize  int64
@@ -104,8 +191,9 @@ func LinearBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, n
 		return nil, err
 	}
 
+	bridge.codecManager = codecManager
 	descriptor = source.RXDescriptor
-	frameSize = CodecLinearFrameSizeCalculate(descriptor.SamplingRate, descriptor.ChannelCount)
+	frameSize = descriptor.CodecLinearFrameSizeGet()
 	bridge.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
 	bridge.frame.CodecFrame.Size = frameSize
 
@@ -117,7 +205,7 @@ func LinearBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, n
 		return nil, err
 	}
 
-	return bridge.base, nil
+	return bridge, nil
 }
 
 func NullBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, name string) (*Object, error) {
@@ -132,15 +220,21 @@ func NullBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, nam
 	if err != nil {
 		return nil, err
 	}
-	bridge.base.Process = NullBridgeProcess
+	bridge.base.Process = func(object *Object) error {
+		return bridge.NullBridgeProcess()
+	}
 
 	codec, err = codecManager.CodecManagerCodecGet(source.RXDescriptor)
 	if err != nil {
 		return nil, err
 	}
+	if codec == nil {
+		return nil, fmt.Errorf("no codec %s registered", source.RXDescriptor.Name)
+	}
 
-	frameSize = source.RXDescriptor.CodecFrameSizeCalculate(codec.Attribs)
+	frameSize = codec.CodecFrameSizeGet(source.RXDescriptor)
 	bridge.codec = codec
+	bridge.codecManager = codecManager
 	bridge.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
 	bridge.frame.CodecFrame.Size = frameSize
 
@@ -155,6 +249,40 @@ func NullBridgeCreate(source, sink *AudioStream, codecManager *CodecManager, nam
 	return bridge.base, nil
 }
 
+/**
+ * Create bridge relaying named events only (e.g. out-of-band DTMF of event-only stream), no audio is relayed.
+ * @param source the source audio stream
+ * @param sink the sink audio stream
+ * @param name the informative name used for debugging
+ */
+func EventBridgeCreate(source, sink *AudioStream, name string) (*Object, error) {
+	if source == nil || sink == nil {
+		return nil, fmt.Errorf("source or sink is nil")
+	}
+	if !EventDescriptorsCompatible(source.RXEventDescriptor, sink.TXEventDescriptor) {
+		return nil, fmt.Errorf("named events are not compatible")
+	}
+
+	bridge, err := BridgeBaseCreate(source, sink, name)
+	if err != nil {
+		return nil, err
+	}
+	bridge.base.Process = func(object *Object) error {
+		return bridge.EventBridgeProcess()
+	}
+	bridge.base.UpdateCodec = nil
+	bridge.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+
+	if err = source.AudioStreamRXOpen(nil); err != nil {
+		return nil, err
+	}
+	if err = sink.AudioStreamTXOpen(nil); err != nil {
+		_ = source.AudioStreamRXClose()
+		return nil, err
+	}
+	return bridge.base, nil
+}
+
 /**
  * Create bridge of audio streams.
  * @param source the source audio stream
@@ -167,6 +295,10 @@ func BridgeCreate(source, sink *AudioStream, manager *CodecManager, name string)
 	if source == nil || sink == nil {
 		return nil, fmt.Errorf("source or sink is nil")
 	}
+	if eventOnlySourceCheck(source) || eventOnlySinkCheck(sink) {
+		/* no audio flows, relay named events only */
+		return EventBridgeCreate(source, sink, name)
+	}
 
 	err := source.AudioStreamRXValidate(sink.TXDescriptor, sink.TXEventDescriptor)
 	if err != nil {
@@ -177,27 +309,104 @@ func BridgeCreate(source, sink *AudioStream, manager *CodecManager, name string)
 		return nil, err
 	}
 
-	if CodecDescriptorsMatch(source.RXDescriptor, sink.TXDescriptor) {
+	sourceDuration, sinkDuration := source.RXDescriptor.CodecFrameDurationGet(), sink.TXDescriptor.CodecFrameDurationGet()
+	if !CodecLPcmDescriptorMatch(source.RXDescriptor) && sourceDuration == sinkDuration &&
+		(CodecDescriptorsMatch(source.RXDescriptor, sink.TXDescriptor) || BridgePassthroughNegotiate(source, sink)) {
+		/* no decode/encode stages needed, relay frames as is (linear audio is relayed by linear bridge) */
 		return NullBridgeCreate(source, sink, manager, name)
 	}
 
+	if sink, err = linearSinkCreate(sink, manager); err != nil {
+		return nil, err
+	}
+	if source, err = linearSourceCreate(source, sink.TXDescriptor, manager); err != nil {
+		return nil, err
+	}
+	if sourceDuration == sinkDuration {
+		return LinearBridgeCreate(source, sink, manager, name)
+	}
+
+	/* set re-framer before bridge, the frames of the sink are paced */
+	if source, err = ReFramerCreate(source, sinkDuration); err != nil {
+		return nil, err
+	}
+	bridge, err := linearBridgeCreate(source, sink, manager, name)
+	if err != nil {
+		return nil, err
+	}
+	bridge.pacer = &framePacer{duration: sinkDuration}
+	return bridge.base, nil
+}
+
+/* Set encoder before sink of encoded audio, so that linear audio is written to the sink */
+func linearSinkCreate(sink *AudioStream, manager *CodecManager) (*AudioStream, error) {
+	if CodecLPcmDescriptorMatch(sink.TXDescriptor) {
+		return sink, nil
+	}
+	codec, err := manager.CodecManagerCodecGet(sink.TXDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	/* set encoder after bridge */
+	if sink = EncoderCreate(sink, codec); sink == nil {
+		return nil, fmt.Errorf("failed to create encoder")
+	}
+	return sink, nil
+}
+
+/* Set decoder, resampler and channel converter after source as needed to read linear audio of the descriptor */
+func linearSourceCreate(source *AudioStream, descriptor *CodecDescriptor, manager *CodecManager) (*AudioStream, error) {
 	if !CodecLPcmDescriptorMatch(source.RXDescriptor) {
-		codec, err := manager.CodecManagerCodecGet(sink.TXDescriptor)
+		codec, err := manager.CodecManagerCodecGet(source.RXDescriptor)
 		if err != nil {
 			return nil, err
 		}
-		encoder := EncoderCreate(sink, codec)
-		sink = encoder
+		/* set decoder before bridge */
+		source = DecoderCreate(source, codec)
+		if source == nil {
+			return nil, fmt.Errorf("failed to create decoder")
+		}
 	}
 
-	if source.RXDescriptor.SamplingRate != sink.TXDescriptor.SamplingRate {
+	if source.RXDescriptor.SamplingRate != descriptor.SamplingRate {
 		/* set resampler before bridge */
-		resampler, err := ReSamplerCreate(source, sink)
+		resampler, err := ReSamplerRateCreate(source, descriptor.SamplingRate, ReSamplerQualityGet())
 		if err != nil {
 			return nil, err
 		}
 		source = resampler
 	}
 
-	return LinearBridgeCreate(source, sink, manager, name)
+	if source.RXDescriptor.ChannelCount != descriptor.ChannelCount {
+		/* set channel converter (mixdown) before bridge */
+		converter, err := ChannelConverterCreate(source, descriptor.ChannelCount, CHANNEL_MODE_MIXDOWN)
+		if err != nil {
+			return nil, err
+		}
+		source = converter
+	}
+	return source, nil
+}
+
+/**
+ * Negotiate passthrough of encoded frames between audio streams.
+ * If either stream accepts the codec of the other one natively (e.g. engine consumes PCMU directly),
+ * the descriptor of the accepting stream is switched to that codec, so that no transcoding is needed.
+ * @param source the source audio stream
+ * @param sink the sink audio stream
+ * @return true if passthrough is negotiated
+ */
+func BridgePassthroughNegotiate(source, sink *AudioStream) bool {
+	if source.RXDescriptor == nil || sink.TXDescriptor == nil {
+		return false
+	}
+	if !CodecLPcmDescriptorMatch(source.RXDescriptor) && sink.AudioStreamCodecAccept(source.RXDescriptor) {
+		sink.TXDescriptor = CodecDescriptorClone(source.RXDescriptor)
+		return true
+	}
+	if !CodecLPcmDescriptorMatch(sink.TXDescriptor) && source.AudioStreamCodecAccept(sink.TXDescriptor) {
+		source.RXDescriptor = CodecDescriptorClone(sink.TXDescriptor)
+		return true
+	}
+	return false
 }
@@ -0,0 +1,236 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBridgePassthrough(t *testing.T) {
+	var (
+		payload = bytes.Repeat([]byte{0x7f, 0xff}, 40)
+		written []byte
+	)
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			frame.CodecFrame.Buffer.Reset()
+			frame.CodecFrame.Buffer.Write(payload)
+			return nil
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecDescriptorClone(&g711UDescriptor)
+
+	capabilities := SinkStreamCapabilitiesCreate()
+	capabilities.StreamCapabilitiesCodecsGet().CodecDefaultCapabilitiesAdd()
+	capabilities.StreamCapabilitiesCodecsGet().CodecCapabilitiesAdd(MPF_SAMPLE_RATE_8000, "PCMU")
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			written = append([]byte(nil), frame.CodecFrame.Buffer.Bytes()...)
+			return nil
+		},
+	}, capabilities)
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+
+	manager := CodecManagerCreate(1)
+	if err := manager.CodecManagerCodecRegister(CodecG711UCreate()); err != nil {
+		t.Fatal(err)
+	}
+	bridge, err := BridgeCreate(source, sink, manager, "passthrough")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.TXDescriptor.Name != "PCMU" {
+		t.Fatalf("sink codec %s, want PCMU", sink.TXDescriptor.Name)
+	}
+	if err := bridge.ObjectProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(written, payload) {
+		t.Fatalf("frame is not passed through as is")
+	}
+	if err := ObjectDestroy(bridge); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBridgeTranscode(t *testing.T) {
+	manager := CodecManagerCreate(2)
+	manager.CodecManagerCodecRegister(CodecG711UCreate())
+	manager.CodecManagerCodecRegister(CodecG711ACreate())
+	for _, test := range []struct {
+		name    string
+		source  *CodecDescriptor
+		sink    *CodecDescriptor
+		payload []byte
+		want    []byte
+	}{
+		/* decoder of the source codec, encoder of the sink one */
+		{"PCMU to PCMA", CodecDescriptorClone(&g711UDescriptor), CodecDescriptorClone(&g711ADescriptor), bytes.Repeat([]byte{0xFF}, 80), bytes.Repeat([]byte{0xD5}, 80)},
+		/* no decoder of linear source */
+		{"L16 to PCMU", CodecLPcmDescriptorCreate(8000, 1), CodecDescriptorClone(&g711UDescriptor), make([]byte, 160), bytes.Repeat([]byte{0xFF}, 80)},
+		/* no encoder of linear sink, A-law silence is decoded to the lowest positive level */
+		{"PCMA to L16", CodecDescriptorClone(&g711ADescriptor), CodecLPcmDescriptorCreate(8000, 1), bytes.Repeat([]byte{0xD5}, 80), bytes.Repeat([]byte{0x08, 0x00}, 80)},
+	} {
+		var written []byte
+		payload := test.payload
+		source := AudioStreamCreate(nil, &AudioStreamVTable{
+			ReadFrame: func(stream *AudioStream, frame *Frame) error {
+				frame.Type = MEDIA_FRAME_TYPE_AUDIO
+				return codecFrameDataSet(&frame.CodecFrame, payload)
+			},
+		}, SourceStreamCapabilitiesCreate())
+		source.RXDescriptor = test.source
+		sink := AudioStreamCreate(nil, &AudioStreamVTable{
+			WriteFrame: func(stream *AudioStream, frame *Frame) error {
+				written = append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...)
+				return nil
+			},
+		}, SinkStreamCapabilitiesCreate())
+		sink.TXDescriptor = test.sink
+
+		bridge, err := BridgeCreate(source, sink, manager, test.name)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if err := bridge.ObjectProcess(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if !bytes.Equal(written, test.want) {
+			t.Fatalf("%s: %d bytes written % x", test.name, len(written), written)
+		}
+		if err := ObjectDestroy(bridge); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestBridgeCodecUpdate(t *testing.T) {
+	var (
+		written []byte
+		opened  []string
+	)
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		OpenRX: func(stream *AudioStream, codec *Codec) error {
+			opened = append(opened, codec.Attribs.Name)
+			return nil
+		},
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, make([]byte, 80))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecDescriptorClone(&g711UDescriptor)
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			written = append([]byte(nil), frame.CodecFrame.Buffer.Bytes()...)
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+
+	manager := CodecManagerCreate(2)
+	manager.CodecManagerCodecRegister(CodecG711UCreate())
+	manager.CodecManagerCodecRegister(CodecG711ACreate())
+	bridge, err := BridgeCreate(source, sink, manager, "update")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bridge.ObjectProcess()
+	ulaw := written
+
+	/* re-INVITE switches PCMU to PCMA */
+	source.RXDescriptor = CodecDescriptorClone(&g711ADescriptor)
+	if err := bridge.ObjectCodecUpdate(); err != nil {
+		t.Fatal(err)
+	}
+	bridge.ObjectProcess()
+	if len(written) != 160 || bytes.Equal(written, ulaw) {
+		t.Fatal("frame is not decoded by PCMA")
+	}
+	if len(opened) != 2 || opened[1] != "PCMA" {
+		t.Fatalf("source is opened with %v", opened)
+	}
+
+	/* sampling rate change requires the bridge to be re-created */
+	source.RXDescriptor = &CodecDescriptor{PayloadType: 96, Name: "L16", SamplingRate: 16000, ChannelCount: 1}
+	manager.CodecManagerCodecRegister(CodecL16Create())
+	if err := bridge.ObjectCodecUpdate(); err == nil {
+		t.Fatal("sampling rate change is accepted")
+	}
+}
+
+func TestEventPropagation(t *testing.T) {
+	/* event-only source sending DTMF 5 */
+	dtmf := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_EVENT
+			frame.EventFrame = NamedEventFrame{EventId: DtmfCharToEventId('5'), Duration: 160}
+			return nil
+		},
+	}, SourceStreamCapabilitiesCreate())
+	dtmf.RXEventDescriptor = EventDescriptorCreate(8000)
+
+	var events, audio int
+	sinkCreate := func(rate uint16) *AudioStream {
+		sink := AudioStreamCreate(nil, &AudioStreamVTable{
+			WriteFrame: func(stream *AudioStream, frame *Frame) error {
+				if (frame.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT && frame.EventFrame.EventId == 5 {
+					events++
+				}
+				if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+					audio++
+				}
+				return nil
+			},
+		}, SinkStreamCapabilitiesCreate())
+		sink.TXDescriptor = CodecLPcmDescriptorCreate(rate, 1)
+		sink.TXEventDescriptor = EventDescriptorCreate(rate)
+		return sink
+	}
+
+	context := ContextFactoryCreate().ContextCreate("events", nil, 3)
+	var terminations []*Termination
+	for _, stream := range []*AudioStream{dtmf, sinkCreate(8000), sinkCreate(16000)} {
+		termination := TerminationBaseCreate(nil, nil, nil, stream, nil)
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+		terminations = append(terminations, termination)
+	}
+
+	/* events of the rate of the sink only flow, no audio */
+	if !StreamDirectionCompatibilityCheck(terminations[0], terminations[1]) || StreamDirectionCompatibilityCheck(terminations[0], terminations[2]) {
+		t.Fatalf("event descriptors are not checked")
+	}
+	context.ContextAssociationAdd(terminations[0], terminations[1])
+	context.ContextAssociationAdd(terminations[0], terminations[2])
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if err := context.ContextProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events != 5 || audio != 0 {
+		t.Fatalf("bridge relayed %d events and %d audio frames", events, audio)
+	}
+
+	/* events are passed through along with the mix of audio, and fanned out to the sinks */
+	full := -1
+	events, audio = 0, 0
+	mixer, err := MixerWeightedCreate([]*AudioStream{mixerTestSource(100, &full), dtmf}, nil, sinkCreate(8000), CodecManagerDefaultCreate(), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mixer.MixerProcess(); err != nil || events != 1 || audio != 1 {
+		t.Fatalf("mixer relayed %d events and %d audio frames: %v", events, audio, err)
+	}
+	events, audio = 0, 0
+	multiplier, err := MultiplierSharedCreate(dtmf, []*AudioStream{sinkCreate(8000), sinkCreate(8000)}, CodecManagerDefaultCreate(), "events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := multiplier.MultiplierProcess(); err != nil || events != 2 || audio != 0 {
+		t.Fatalf("multiplier relayed %d events and %d audio frames: %v", events, audio, err)
+	}
+}
@@ -0,0 +1,146 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Channel selection of up/downmix */
+type ChannelMode = int
+
+const (
+	CHANNEL_MODE_MIXDOWN ChannelMode = iota // stereo is mixed down (averaged) to mono, mono is copied to both channels
+	CHANNEL_MODE_LEFT                       // left channel only is taken (or fed, right one is silent)
+	CHANNEL_MODE_RIGHT                      // right channel only is taken (or fed, left one is silent)
+)
+
+/** Channel converter (up/downmix) derived from audio stream */
+type ChannelConverter struct {
+	/** Audio stream base */
+	base *AudioStream
+	/** Audio stream source */
+	source *AudioStream
+	/** Channel selection */
+	mode ChannelMode
+	/** Frame read from source */
+	frameIn Frame
+}
+
+/**
+ * Check whether channel conversion of the audio is supported.
+ * @param sourceCount the channel count of the source
+ * @param sinkCount the channel count of the sink
+ */
+func ChannelConversionSupported(sourceCount, sinkCount uint8) bool {
+	return sourceCount == sinkCount || (sourceCount == 1 && sinkCount == 2) || (sourceCount == 2 && sinkCount == 1)
+}
+
+/**
+ * Create stage converting (linear) audio of source between stereo and mono, e.g. so that a dual-channel
+ * call recording feeds mono recognizer or mono prompt feeds stereo sink.
+ * @param source the source stream
+ * @param channelCount the channel count to convert to (1 or 2)
+ * @param mode the channel selection
+ */
+func ChannelConverterCreate(source *AudioStream, channelCount uint8, mode ChannelMode) (*AudioStream, error) {
+	if source == nil || source.RXDescriptor == nil {
+		return nil, fmt.Errorf("source is nil")
+	}
+	if !CodecLPcmDescriptorMatch(source.RXDescriptor) {
+		return nil, fmt.Errorf("channel conversion of codec %s is not supported", source.RXDescriptor.Name)
+	}
+	if !ChannelConversionSupported(source.RXDescriptor.ChannelCount, channelCount) {
+		return nil, fmt.Errorf("channel conversion %d to %d is not supported", source.RXDescriptor.ChannelCount, channelCount)
+	}
+	if mode != CHANNEL_MODE_MIXDOWN && mode != CHANNEL_MODE_LEFT && mode != CHANNEL_MODE_RIGHT {
+		return nil, fmt.Errorf("invalid channel mode %d", mode)
+	}
+
+	converter := &ChannelConverter{source: source, mode: mode}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			return converter.frameRead(frame)
+		},
+	}
+	converter.base = AudioStreamCreate(converter, vtable, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	if converter.base == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	converter.base.RXDescriptor = CodecDescriptorClone(source.RXDescriptor)
+	converter.base.RXDescriptor.ChannelCount = channelCount
+	converter.base.RXEventDescriptor = source.RXEventDescriptor
+	converter.frameIn.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	return converter.base, nil
+}
+
+/* Read frame of source and convert its channels */
+func (converter *ChannelConverter) frameRead(frame *Frame) error {
+	in := &converter.frameIn
+	in.Type = MEDIA_FRAME_TYPE_NONE
+	in.Marker = MPF_MARKER_NONE
+	in.AudioLevel = FrameAudioLevel{}
+	in.CodecFrame.Size = converter.source.RXDescriptor.CodecLinearFrameSizeGet()
+	if err := converter.source.AudioStreamFrameRead(in); err != nil {
+		return err
+	}
+
+	frame.Type = in.Type
+	frame.Marker = in.Marker
+	frame.PayloadType = in.PayloadType
+	frame.AudioLevel = in.AudioLevel
+	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		frame.EventFrame = in.EventFrame
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		return nil
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&in.CodecFrame))
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(
+		ChannelsConvert(samples, converter.source.RXDescriptor.ChannelCount, converter.base.RXDescriptor.ChannelCount, converter.mode)))
+}
+
+/**
+ * Convert interleaved samples between stereo and mono.
+ * @param samples the samples
+ * @param sourceCount the channel count of the samples
+ * @param sinkCount the channel count to convert to
+ * @param mode the channel selection
+ */
+func ChannelsConvert(samples []int16, sourceCount, sinkCount uint8, mode ChannelMode) []int16 {
+	switch {
+	case sourceCount == 2 && sinkCount == 1:
+		out := make([]int16, len(samples)/2)
+		for i := range out {
+			left, right := samples[2*i], samples[2*i+1]
+			switch mode {
+			case CHANNEL_MODE_LEFT:
+				out[i] = left
+			case CHANNEL_MODE_RIGHT:
+				out[i] = right
+			default:
+				out[i] = int16((int32(left) + int32(right)) / 2)
+			}
+		}
+		return out
+	case sourceCount == 1 && sinkCount == 2:
+		out := make([]int16, len(samples)*2)
+		for i, sample := range samples {
+			if mode != CHANNEL_MODE_RIGHT {
+				out[2*i] = sample
+			}
+			if mode != CHANNEL_MODE_LEFT {
+				out[2*i+1] = sample
+			}
+		}
+		return out
+	}
+	return samples
+}
@@ -0,0 +1,82 @@
+package mpf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestChannelConverter(t *testing.T) {
+	/* dual-channel recording: caller on the left, agent on the right */
+	stereo := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			if frame.CodecFrame.Size != 320 {
+				return fmt.Errorf("stereo frame of %d bytes is read", frame.CodecFrame.Size)
+			}
+			samples := make([]int16, 160)
+			for i := 0; i < len(samples); i += 2 {
+				samples[i], samples[i+1] = 100, 300
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	stereo.RXDescriptor = CodecLPcmDescriptorCreate(8000, 2)
+
+	read := func(source *AudioStream) []int16 {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		return samples
+	}
+	for mode, want := range map[ChannelMode]int16{CHANNEL_MODE_LEFT: 100, CHANNEL_MODE_RIGHT: 300, CHANNEL_MODE_MIXDOWN: 200} {
+		mono, err := ChannelConverterCreate(stereo, 1, mode)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mono.RXDescriptor.ChannelCount != 1 || stereo.RXDescriptor.ChannelCount != 2 {
+			t.Fatalf("descriptor of source is changed")
+		}
+		if samples := read(mono); len(samples) != 80 || samples[0] != want || samples[79] != want {
+			t.Fatalf("mode %d: %d samples of %v converted", mode, len(samples), samples[:2])
+		}
+	}
+
+	/* mono fed to the left channel of stereo sink */
+	if samples := ChannelsConvert([]int16{1, 2}, 1, 2, CHANNEL_MODE_LEFT); fmt.Sprint(samples) != "[1 0 2 0]" {
+		t.Fatalf("upmix to the left %v", samples)
+	}
+	if samples := ChannelsConvert([]int16{1, 2}, 1, 2, CHANNEL_MODE_MIXDOWN); fmt.Sprint(samples) != "[1 1 2 2]" {
+		t.Fatalf("upmix to both %v", samples)
+	}
+
+	/* stereo source bridged to mono sink is mixed down */
+	var written []int16
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			written, _ = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	if reasons := StreamDescriptorsValidate(stereo, sink); len(reasons) != 0 {
+		t.Fatalf("stereo to mono is not valid: %v", reasons)
+	}
+	bridge, err := BridgeCreate(stereo, sink, CodecManagerDefaultCreate(), "downmix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.Process(bridge); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 80 || written[0] != 200 {
+		t.Fatalf("%d samples of %v written", len(written), written[:1])
+	}
+
+	if _, err := ChannelConverterCreate(stereo, 3, CHANNEL_MODE_MIXDOWN); err == nil {
+		t.Fatalf("conversion to 3 channels is created")
+	}
+}
@@ -14,8 +14,16 @@ type Codec struct {
 	Attribs *CodecAttribs
 	/** Optional static codec descriptor (pt < 96) */
 	StaticDescriptor *CodecDescriptor
+
+	/** Optional factory creating codec instances with their own state */
+	factory CodecFactory
+	/** Descriptor the codec instance is got for (negotiated attributes) */
+	descriptor *CodecDescriptor
 }
 
+/** Factory creating new codec instance */
+type CodecFactory func() *Codec
+
 /** Table of codec virtual methods */
 type CodecVTable struct {
 
@@ -34,6 +42,12 @@ type CodecVTable struct {
 
 	/** Virtual initialize method */
 	Initialize func(codec *Codec, frameOut *CodecFrame) error
+
+	/** [OPTIONAL] Virtual packet loss concealment method, the (linear) frame missing is concealed by the previous one */
+	Conceal func(codec *Codec, prev, frameOut *CodecFrame) error
+
+	/** [OPTIONAL] Virtual method getting encoded frame size (the upper bound of variable one), calculated by the attributes if not set */
+	FrameSize func(codec *Codec, descriptor *CodecDescriptor) int64
 }
 
 /**
@@ -57,6 +71,14 @@ func CodecCreate(vtable *CodecVTable, attribs *CodecAttribs, descriptor *CodecDe
  * @param pool the pool to allocate memory from
  */
 func (c *Codec) CodecClone() *Codec {
+	if c.factory != nil {
+		codec := c.factory()
+		if codec != nil {
+			codec.Attribs = c.Attribs
+			codec.factory = c.factory
+			return codec
+		}
+	}
 	codec := &Codec{
 		VTable:           c.VTable,
 		Attribs:          c.Attribs,
@@ -65,6 +87,14 @@ func (c *Codec) CodecClone() *Codec {
 	return codec
 }
 
+/** Get descriptor the codec instance is got for, the static one if not set */
+func (c *Codec) CodecDescriptorGet() *CodecDescriptor {
+	if c.descriptor != nil {
+		return c.descriptor
+	}
+	return c.StaticDescriptor
+}
+
 /** Open codec */
 func (c *Codec) CodecOpen() error {
 	if c.VTable != nil && c.VTable.Open != nil {
@@ -101,18 +131,43 @@ func (c *Codec) CodecDecode(frameIn, frameOut *CodecFrame) error {
 func (c *Codec) CodecDissect(buffer *bytes.Buffer, frame *CodecFrame) error {
 	if c.VTable != nil && c.VTable.Dissect != nil {
 		return c.VTable.Dissect(c, buffer, frame)
-	} else {
-		/* default dissector */
-		if frame.Buffer != nil && frame.Size > 0 && int64(buffer.Len()) >= frame.Size {
-			//frame.Buffer.Reset()
-			//_, err := frame.Buffer.Write(buffer.Bytes())
-			_, err := io.CopyN(frame.Buffer, buffer, int64(frame.Size))
-			return err
-		}
+	}
+	return codecDefaultDissect(buffer, frame)
+}
+
+/* Default dissector of fixed size frames */
+func codecDefaultDissect(buffer *bytes.Buffer, frame *CodecFrame) error {
+	if frame.Buffer != nil && frame.Size > 0 && int64(buffer.Len()) >= frame.Size {
+		//frame.Buffer.Reset()
+		//_, err := frame.Buffer.Write(buffer.Bytes())
+		_, err := io.CopyN(frame.Buffer, buffer, int64(frame.Size))
+		return err
 	}
 	return nil
 }
 
+/**
+ * Get encoded frame size of codec in bytes.
+ * @param descriptor the descriptor (sampling rate, channels) of the stream
+ */
+func (c *Codec) CodecFrameSizeGet(descriptor *CodecDescriptor) int64 {
+	if c.VTable != nil && c.VTable.FrameSize != nil {
+		return c.VTable.FrameSize(c, descriptor)
+	}
+	return descriptor.CodecFrameSizeCalculate(c.Attribs)
+}
+
+/** Dissect buffer of variable size codec packet (e.g. Speex, AMR): the whole buffer is the frame */
+func CodecPacketDissect(codec *Codec, buffer *bytes.Buffer, frame *CodecFrame) error {
+	if frame.Buffer == nil {
+		frame.Buffer = bytes.NewBuffer(nil)
+	}
+	frame.Buffer.Reset()
+	n, err := buffer.WriteTo(frame.Buffer)
+	frame.Size = n
+	return err
+}
+
 /** Initialize (fill) codec frame with silence */
 func (c *Codec) CodecInitialize(frameOut *CodecFrame) error {
 	if c.VTable != nil && c.VTable.Initialize != nil {
@@ -122,3 +177,26 @@ func (c *Codec) CodecInitialize(frameOut *CodecFrame) error {
 	}
 	return nil
 }
+
+/* Get data of codec frame (limited to the frame size if it is set) */
+func codecFrameDataGet(frame *CodecFrame) []byte {
+	if frame.Buffer == nil {
+		return nil
+	}
+	data := frame.Buffer.Bytes()
+	if frame.Size > 0 && frame.Size < int64(len(data)) {
+		data = data[:frame.Size]
+	}
+	return data
+}
+
+/* Set data of codec frame, replacing the previous one */
+func codecFrameDataSet(frame *CodecFrame, data []byte) error {
+	if frame.Buffer == nil {
+		frame.Buffer = bytes.NewBuffer(make([]byte, 0, len(data)))
+	}
+	frame.Buffer.Reset()
+	n, err := frame.Buffer.Write(data)
+	frame.Size = int64(n)
+	return err
+}
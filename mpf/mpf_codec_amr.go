@@ -0,0 +1,512 @@
+package mpf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* AMR and AMR-WB (RFC4867) */
+const (
+	AMR_CODEC_NAME    = "AMR"
+	AMR_WB_CODEC_NAME = "AMR-WB"
+)
+
+/** Duration of AMR frame in msec (2 frames of the media processing time base) */
+const AMR_FRAME_TIME = 20
+
+/** Frame types (FT field of the table of contents) */
+const (
+	AMR_FT_SID         = 8  /**< AMR comfort noise frame */
+	AMR_WB_FT_SID      = 9  /**< AMR-WB comfort noise frame */
+	AMR_WB_FT_LOST     = 14 /**< AMR-WB speech lost */
+	AMR_FT_NO_DATA     = 15 /**< no data (transmitted or received) */
+	AMR_CMR_NO_REQUEST = 15 /**< no codec mode request */
+)
+
+/** Highest speech modes */
+const (
+	AMR_MODE_MAX    = 7 /**< AMR 12.2 kbit/s */
+	AMR_WB_MODE_MAX = 8 /**< AMR-WB 23.85 kbit/s */
+)
+
+/** Speech data size in bytes by frame type (octet-aligned) */
+var (
+	amrFrameSizes   = [16]int{12, 13, 15, 17, 19, 20, 26, 31, 5, -1, -1, -1, -1, -1, -1, 0}
+	amrWbFrameSizes = [16]int{17, 23, 32, 36, 40, 46, 50, 58, 60, 5, -1, -1, -1, -1, 0, 0}
+)
+
+/** Get speech data size of the frame type, -1 if the frame type is not supported */
+func AMRFrameSizeGet(wideband bool, frameType int) int {
+	if frameType < 0 || frameType > AMR_FT_NO_DATA {
+		return -1
+	}
+	if wideband {
+		return amrWbFrameSizes[frameType]
+	}
+	return amrFrameSizes[frameType]
+}
+
+/* Highest speech mode */
+func amrModeMax(wideband bool) int {
+	if wideband {
+		return AMR_WB_MODE_MAX
+	}
+	return AMR_MODE_MAX
+}
+
+/** AMR attributes negotiated by SDP fmtp */
+type AMRAttribs struct {
+	Wideband           bool   // AMR-WB
+	ModeSet            uint16 // Mask of allowed speech modes (0 - all)
+	ModeChangePeriod   int    // Number of frames the mode may be changed at (0 - any)
+	ModeChangeNeighbor bool   // Mode may be changed to the neighboring one of the mode set only
+	MaxRed             int    // Max redundancy in msec
+}
+
+/** Check whether the speech mode is allowed by the mode set */
+func (a *AMRAttribs) AMRModeAllowed(mode int) bool {
+	if mode < 0 || mode > amrModeMax(a.Wideband) {
+		return false
+	}
+	return a.ModeSet == 0 || a.ModeSet&(1<<uint(mode)) != 0
+}
+
+/** Get the highest allowed speech mode not exceeding the requested one (-1 if none) */
+func (a *AMRAttribs) AMRModeSelect(requested int) int {
+	if requested > amrModeMax(a.Wideband) {
+		requested = amrModeMax(a.Wideband)
+	}
+	for mode := requested; mode >= 0; mode-- {
+		if a.AMRModeAllowed(mode) {
+			return mode
+		}
+	}
+	/* requested mode is below the mode set, use the lowest one */
+	for mode := requested + 1; mode <= amrModeMax(a.Wideband); mode++ {
+		if a.AMRModeAllowed(mode) {
+			return mode
+		}
+	}
+	return -1
+}
+
+/* Get the neighboring mode of the mode set, moving from the current mode to the target one */
+func (a *AMRAttribs) amrModeNeighborGet(current, target int) int {
+	step := 1
+	if target < current {
+		step = -1
+	}
+	for mode := current + step; mode >= 0 && mode <= amrModeMax(a.Wideband); mode += step {
+		if a.AMRModeAllowed(mode) {
+			return mode
+		}
+		if mode == target {
+			break
+		}
+	}
+	return current
+}
+
+/**
+ * Get AMR attributes of the descriptor, parsed from the fmtp (format) params:
+ * octet-align, mode-set, mode-change-period, mode-change-neighbor and max-red.
+ * Only octet-aligned single channel payload without CRC, robust sorting and interleaving is supported.
+ */
+func (d *CodecDescriptor) CodecAMRAttribsGet() (*AMRAttribs, error) {
+	attribs := &AMRAttribs{}
+	switch {
+	case strings.EqualFold(d.Name, AMR_CODEC_NAME) && d.SamplingRate == 8000:
+	case strings.EqualFold(d.Name, AMR_WB_CODEC_NAME) && d.SamplingRate == 16000:
+		attribs.Wideband = true
+	default:
+		return nil, fmt.Errorf("unsupported amr codec %s/%d", d.Name, d.SamplingRate)
+	}
+	if d.ChannelCount > 1 {
+		return nil, fmt.Errorf("unsupported amr channel count %d", d.ChannelCount)
+	}
+	params := d.CodecFormatParamsGet()
+	if params["octet-align"] != "1" {
+		return nil, fmt.Errorf("bandwidth-efficient amr payload is not supported")
+	}
+	for _, name := range []string{"crc", "robust-sorting"} {
+		if value, ok := params[name]; ok && value != "0" {
+			return nil, fmt.Errorf("amr %s is not supported", name)
+		}
+	}
+	if _, ok := params["interleaving"]; ok {
+		return nil, fmt.Errorf("amr interleaving is not supported")
+	}
+	if value, ok := params["mode-set"]; ok && value != "" {
+		for _, field := range strings.Split(value, ",") {
+			mode, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil || mode < 0 || mode > amrModeMax(attribs.Wideband) {
+				return nil, fmt.Errorf("invalid amr mode-set [%s]", value)
+			}
+			attribs.ModeSet |= 1 << uint(mode)
+		}
+	}
+	if value, ok := params["mode-change-period"]; ok {
+		period, err := strconv.Atoi(value)
+		if err != nil || (period != 1 && period != 2) {
+			return nil, fmt.Errorf("invalid amr mode-change-period [%s]", value)
+		}
+		attribs.ModeChangePeriod = period
+	}
+	attribs.ModeChangeNeighbor = params["mode-change-neighbor"] == "1"
+	if value, ok := params["max-red"]; ok {
+		maxRed, err := strconv.Atoi(value)
+		if err != nil || maxRed < 0 {
+			return nil, fmt.Errorf("invalid amr max-red [%s]", value)
+		}
+		attribs.MaxRed = maxRed
+	}
+	return attribs, nil
+}
+
+/** Generate fmtp (format) params of AMR attributes */
+func (a *AMRAttribs) AMRFormatGenerate() string {
+	params := []string{"octet-align=1"}
+	if a.ModeSet != 0 {
+		var modes []string
+		for mode := 0; mode <= amrModeMax(a.Wideband); mode++ {
+			if a.ModeSet&(1<<uint(mode)) != 0 {
+				modes = append(modes, strconv.Itoa(mode))
+			}
+		}
+		params = append(params, "mode-set="+strings.Join(modes, ","))
+	}
+	if a.ModeChangePeriod != 0 {
+		params = append(params, "mode-change-period="+strconv.Itoa(a.ModeChangePeriod))
+	}
+	if a.ModeChangeNeighbor {
+		params = append(params, "mode-change-neighbor=1")
+	}
+	if a.MaxRed != 0 {
+		params = append(params, "max-red="+strconv.Itoa(a.MaxRed))
+	}
+	return strings.Join(params, ";")
+}
+
+/**
+ * Create AMR (AMR-WB) descriptor.
+ * @param payloadType the dynamic payload type
+ * @param attribs the AMR attributes
+ */
+func CodecAMRDescriptorCreate(payloadType RtpPayloadType, attribs *AMRAttribs) (*CodecDescriptor, error) {
+	if payloadType < RTP_PT_DYNAMIC || payloadType > RTP_PT_DYNAMIC_MAX {
+		return nil, fmt.Errorf("payload type %d of amr is not dynamic", payloadType)
+	}
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = payloadType
+	descriptor.Name = AMR_CODEC_NAME
+	descriptor.SamplingRate = 8000
+	if attribs.Wideband {
+		descriptor.Name = AMR_WB_CODEC_NAME
+		descriptor.SamplingRate = 16000
+	}
+	descriptor.ChannelCount = 1
+	descriptor.Format = attribs.AMRFormatGenerate()
+	return descriptor, nil
+}
+
+/** AMR speech frame of the payload */
+type AMRFrame struct {
+	FrameType int    // Frame type (speech mode, SID or no data)
+	Quality   bool   // Frame quality indicator (false - damaged frame)
+	Speech    []byte // Speech data (octet-aligned)
+}
+
+/**
+ * Pack speech frames to octet-aligned payload.
+ * @param wideband whether the frames are of AMR-WB
+ * @param cmr the codec mode request to the remote encoder
+ * @param frames the frames to pack
+ */
+func AMRPayloadPack(wideband bool, cmr int, frames []AMRFrame) ([]byte, error) {
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no amr frames to pack")
+	}
+	payload := make([]byte, 0, 1+len(frames))
+	payload = append(payload, byte(cmr&0x0F)<<4)
+	for i, frame := range frames {
+		size := AMRFrameSizeGet(wideband, frame.FrameType)
+		if size < 0 || len(frame.Speech) != size {
+			return nil, fmt.Errorf("invalid amr frame type %d of %d bytes", frame.FrameType, len(frame.Speech))
+		}
+		toc := byte(frame.FrameType&0x0F) << 3
+		if i < len(frames)-1 {
+			toc |= 0x80
+		}
+		if frame.Quality {
+			toc |= 0x04
+		}
+		payload = append(payload, toc)
+	}
+	for _, frame := range frames {
+		payload = append(payload, frame.Speech...)
+	}
+	return payload, nil
+}
+
+/**
+ * Unpack octet-aligned payload to speech frames.
+ * @param wideband whether the payload is of AMR-WB
+ * @param payload the payload to unpack
+ * @return the codec mode request and the frames
+ */
+func AMRPayloadUnpack(wideband bool, payload []byte) (int, []AMRFrame, error) {
+	if len(payload) < 2 {
+		return AMR_CMR_NO_REQUEST, nil, fmt.Errorf("amr payload of %d bytes is too short", len(payload))
+	}
+	cmr := int(payload[0] >> 4)
+	var frames []AMRFrame
+	offset := 1
+	for {
+		if offset >= len(payload) {
+			return cmr, nil, fmt.Errorf("truncated amr table of contents")
+		}
+		toc := payload[offset]
+		offset++
+		frame := AMRFrame{
+			FrameType: int(toc>>3) & 0x0F,
+			Quality:   toc&0x04 != 0,
+		}
+		if AMRFrameSizeGet(wideband, frame.FrameType) < 0 {
+			return cmr, nil, fmt.Errorf("unsupported amr frame type %d", frame.FrameType)
+		}
+		frames = append(frames, frame)
+		if toc&0x80 == 0 {
+			break
+		}
+	}
+	for i := range frames {
+		size := AMRFrameSizeGet(wideband, frames[i].FrameType)
+		if offset+size > len(payload) {
+			return cmr, nil, fmt.Errorf("truncated amr frame of type %d", frames[i].FrameType)
+		}
+		frames[i].Speech = payload[offset : offset+size]
+		offset += size
+	}
+	return cmr, frames, nil
+}
+
+/** AMR encoder of the DSP backend */
+type AMREncoder interface {
+	/** Encode 20 msec of linear samples in the speech mode, the frame may be SID or no data (DTX) */
+	Encode(samples []int16, mode int) (AMRFrame, error)
+}
+
+/** AMR decoder of the DSP backend */
+type AMRDecoder interface {
+	/** Decode speech frame to 20 msec of linear samples (no data frame - conceal the lost one) */
+	Decode(frame AMRFrame) ([]int16, error)
+}
+
+/** AMR (AMR-WB) DSP backend (e.g. binding of opencore-amr) plugged into the codec */
+type AMRBackend interface {
+	EncoderCreate(attribs *AMRAttribs) (AMREncoder, error)
+	DecoderCreate(attribs *AMRAttribs) (AMRDecoder, error)
+}
+
+var (
+	amrAttribs = CodecAttribs{
+		Name:          AMR_CODEC_NAME,
+		BitsPerSample: 8, /* upper bound of 20 msec payload per 10 msec frame */
+		SampleRates:   MPF_SAMPLE_RATE_8000,
+	}
+	amrWbAttribs = CodecAttribs{
+		Name:          AMR_WB_CODEC_NAME,
+		BitsPerSample: 8,
+		SampleRates:   MPF_SAMPLE_RATE_16000,
+	}
+)
+
+/** AMR codec instance repacketizing 10 msec media frames to 20 msec AMR frames */
+type amrCodec struct {
+	backend AMRBackend
+	encoder AMREncoder
+	decoder AMRDecoder
+	attribs *AMRAttribs
+
+	mode        int // Current speech mode of the encoder
+	targetMode  int // Speech mode requested by the remote CMR
+	modeFrames  int // Number of frames encoded since the mode change period start
+	requestMode int // Codec mode request sent to the remote encoder
+
+	encodeSamples []int16 // Samples waiting for the rest of the AMR frame
+	decodeSamples []int16 // Decoded samples of the next media frames
+}
+
+func (c *amrCodec) open(codec *Codec) error {
+	descriptor := codec.CodecDescriptorGet()
+	if descriptor == nil {
+		return fmt.Errorf("no amr descriptor")
+	}
+	attribs, err := descriptor.CodecAMRAttribsGet()
+	if err != nil {
+		return err
+	}
+	if c.encoder, err = c.backend.EncoderCreate(attribs); err != nil {
+		return err
+	}
+	if c.decoder, err = c.backend.DecoderCreate(attribs); err != nil {
+		return err
+	}
+	c.attribs = attribs
+	c.mode = attribs.AMRModeSelect(amrModeMax(attribs.Wideband))
+	c.targetMode = c.mode
+	c.modeFrames = 0
+	c.requestMode = AMR_CMR_NO_REQUEST
+	c.encodeSamples = nil
+	c.decodeSamples = nil
+	return nil
+}
+
+func (c *amrCodec) close(codec *Codec) error {
+	c.encoder = nil
+	c.decoder = nil
+	return nil
+}
+
+/* Apply codec mode request of the remote decoder */
+func (c *amrCodec) cmrApply(cmr int) {
+	if cmr == AMR_CMR_NO_REQUEST {
+		return
+	}
+	if mode := c.attribs.AMRModeSelect(cmr); mode >= 0 {
+		c.targetMode = mode
+	}
+}
+
+/* Step the encoder mode towards the requested one, at the mode change period boundary */
+func (c *amrCodec) modeUpdate() {
+	if c.mode != c.targetMode && (c.attribs.ModeChangePeriod <= 1 || c.modeFrames%c.attribs.ModeChangePeriod == 0) {
+		if c.attribs.ModeChangeNeighbor {
+			c.mode = c.attribs.amrModeNeighborGet(c.mode, c.targetMode)
+		} else {
+			c.mode = c.targetMode
+		}
+	}
+	c.modeFrames++
+}
+
+/* Number of samples of media frame */
+func (c *amrCodec) frameSamples(codec *Codec) int {
+	return int(codec.CodecDescriptorGet().CodecFrameSamplesCalculate())
+}
+
+/** Encode linear frame, every other frame carries 20 msec AMR payload, the rest are empty */
+func (c *amrCodec) encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.encoder == nil {
+		return fmt.Errorf("amr codec is not open")
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(frameIn))
+	if err != nil {
+		return err
+	}
+	c.encodeSamples = append(c.encodeSamples, samples...)
+	frameSamples := c.frameSamples(codec) * AMR_FRAME_TIME / int(codec.CodecDescriptorGet().CodecFrameDurationGet())
+	if len(c.encodeSamples) < frameSamples {
+		return codecFrameDataSet(frameOut, nil)
+	}
+	/* media frames of 30 msec carry one or two AMR frames */
+	var frames []AMRFrame
+	for len(c.encodeSamples) >= frameSamples {
+		c.modeUpdate()
+		frame, err := c.encoder.Encode(c.encodeSamples[:frameSamples], c.mode)
+		c.encodeSamples = append(c.encodeSamples[:0], c.encodeSamples[frameSamples:]...)
+		if err != nil {
+			return err
+		}
+		frames = append(frames, frame)
+	}
+	payload, err := AMRPayloadPack(c.attribs.Wideband, c.requestMode, frames)
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, payload)
+}
+
+/** Decode AMR payload to linear frames, the rest of the payload is output on the next (empty) frames */
+func (c *amrCodec) decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.decoder == nil {
+		return fmt.Errorf("amr codec is not open")
+	}
+	if payload := codecFrameDataGet(frameIn); len(payload) > 0 {
+		cmr, frames, err := AMRPayloadUnpack(c.attribs.Wideband, payload)
+		if err != nil {
+			return err
+		}
+		c.cmrApply(cmr)
+		for _, frame := range frames {
+			samples, err := c.decoder.Decode(frame)
+			if err != nil {
+				return err
+			}
+			c.decodeSamples = append(c.decodeSamples, samples...)
+		}
+	} else if len(c.decodeSamples) == 0 {
+		/* lost payload */
+		samples, err := c.decoder.Decode(AMRFrame{FrameType: AMR_FT_NO_DATA})
+		if err != nil {
+			return err
+		}
+		c.decodeSamples = append(c.decodeSamples, samples...)
+	}
+	out := make([]int16, c.frameSamples(codec))
+	n := copy(out, c.decodeSamples)
+	c.decodeSamples = append(c.decodeSamples[:0], c.decodeSamples[n:]...)
+	return codecFrameDataSet(frameOut, binaryx.Int16SliceToByteSlice(out))
+}
+
+/** Initialize AMR frame as empty one (no payload, the decoder conceals it) */
+func AMRInit(codec *Codec, frameOut *CodecFrame) error {
+	return codecFrameDataSet(frameOut, nil)
+}
+
+/* Create AMR (AMR-WB) codec using the DSP backend */
+func codecAMRCreate(backend AMRBackend, attribs *CodecAttribs) *Codec {
+	c := &amrCodec{backend: backend}
+	vtable := &CodecVTable{
+		Open:       c.open,
+		Close:      c.close,
+		Encode:     c.encode,
+		Decode:     c.decode,
+		Dissect:    CodecPacketDissect,
+		Initialize: AMRInit,
+	}
+	return CodecCreate(vtable, attribs, nil)
+}
+
+/** Create AMR codec using the DSP backend */
+func CodecAMRCreate(backend AMRBackend) *Codec {
+	return codecAMRCreate(backend, &amrAttribs)
+}
+
+/** Create AMR-WB codec using the DSP backend */
+func CodecAMRWBCreate(backend AMRBackend) *Codec {
+	return codecAMRCreate(backend, &amrWbAttribs)
+}
+
+/**
+ * Register AMR and AMR-WB codecs in codec manager, using the DSP backend.
+ * @param codecManager the codec manager to register codecs in
+ * @param backend the AMR DSP backend, serving both AMR and AMR-WB (see AMRAttribs.Wideband)
+ */
+func CodecAMRRegister(codecManager *CodecManager, backend AMRBackend) error {
+	if backend == nil {
+		return fmt.Errorf("amr backend is nil")
+	}
+	err := codecManager.CodecManagerCodecFactoryRegister(&amrAttribs, func() *Codec {
+		return CodecAMRCreate(backend)
+	})
+	if err != nil {
+		return err
+	}
+	return codecManager.CodecManagerCodecFactoryRegister(&amrWbAttribs, func() *Codec {
+		return CodecAMRWBCreate(backend)
+	})
+}
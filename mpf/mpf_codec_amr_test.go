@@ -0,0 +1,182 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Fake backend: speech data is filled by the first sample, decoded to the samples of that value */
+type amrTestBackend struct {
+	modes []int
+}
+
+type amrTestEncoder struct {
+	backend  *amrTestBackend
+	wideband bool
+}
+
+type amrTestDecoder struct {
+	wideband bool
+}
+
+func (e *amrTestEncoder) Encode(samples []int16, mode int) (AMRFrame, error) {
+	e.backend.modes = append(e.backend.modes, mode)
+	speech := bytes.Repeat([]byte{byte(samples[0])}, AMRFrameSizeGet(e.wideband, mode))
+	return AMRFrame{FrameType: mode, Quality: true, Speech: speech}, nil
+}
+
+func (d *amrTestDecoder) Decode(frame AMRFrame) ([]int16, error) {
+	samples := make([]int16, 160)
+	if d.wideband {
+		samples = make([]int16, 320)
+	}
+	if len(frame.Speech) > 0 {
+		for i := range samples {
+			samples[i] = int16(frame.Speech[0])
+		}
+	}
+	return samples, nil
+}
+
+func (b *amrTestBackend) EncoderCreate(attribs *AMRAttribs) (AMREncoder, error) {
+	return &amrTestEncoder{backend: b, wideband: attribs.Wideband}, nil
+}
+
+func (b *amrTestBackend) DecoderCreate(attribs *AMRAttribs) (AMRDecoder, error) {
+	return &amrTestDecoder{wideband: attribs.Wideband}, nil
+}
+
+func TestCodecAMRAttribs(t *testing.T) {
+	descriptor := &CodecDescriptor{Name: AMR_WB_CODEC_NAME, SamplingRate: 16000, ChannelCount: 1,
+		Format: "octet-align=1; mode-set=0,2,8; mode-change-period=2; mode-change-neighbor=1"}
+	attribs, err := descriptor.CodecAMRAttribsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attribs.Wideband || attribs.ModeSet != 0x105 || attribs.ModeChangePeriod != 2 || !attribs.ModeChangeNeighbor {
+		t.Fatalf("unexpected attribs %+v", attribs)
+	}
+	if mode := attribs.AMRModeSelect(6); mode != 2 {
+		t.Fatalf("selected mode %d, want 2", mode)
+	}
+
+	for _, format := range []string{"", "octet-align=0", "octet-align=1;crc=1", "octet-align=1;interleaving=2", "octet-align=1;mode-set=9"} {
+		descriptor.Format = format
+		if _, err := descriptor.CodecAMRAttribsGet(); err == nil {
+			t.Fatalf("format [%s] is accepted", format)
+		}
+	}
+
+	/* generated fmtp is parsed back */
+	descriptor, err = CodecAMRDescriptorCreate(98, &AMRAttribs{ModeSet: 0x84, MaxRed: 220})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if descriptor.Name != AMR_CODEC_NAME || descriptor.SamplingRate != 8000 {
+		t.Fatalf("descriptor %s/%d", descriptor.Name, descriptor.SamplingRate)
+	}
+	if attribs, err = descriptor.CodecAMRAttribsGet(); err != nil || attribs.ModeSet != 0x84 || attribs.MaxRed != 220 {
+		t.Fatalf("attribs %+v of format [%s], err %v", attribs, descriptor.Format, err)
+	}
+}
+
+func TestCodecAMRPayload(t *testing.T) {
+	frames := []AMRFrame{
+		{FrameType: 7, Quality: true, Speech: make([]byte, 31)},
+		{FrameType: AMR_FT_SID, Quality: true, Speech: make([]byte, 5)},
+		{FrameType: AMR_FT_NO_DATA},
+	}
+	payload, err := AMRPayloadPack(false, 5, frames)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 1+3+31+5 || payload[0] != 0x50 || payload[1] != 0xBC || payload[3] != 0x78 {
+		t.Fatalf("unexpected payload % x", payload[:4])
+	}
+	cmr, unpacked, err := AMRPayloadUnpack(false, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmr != 5 || len(unpacked) != 3 || unpacked[0].FrameType != 7 || len(unpacked[1].Speech) != 5 || unpacked[2].Quality {
+		t.Fatalf("unexpected cmr %d, frames %+v", cmr, unpacked)
+	}
+	if _, _, err := AMRPayloadUnpack(false, payload[:10]); err == nil {
+		t.Fatal("truncated payload is accepted")
+	}
+	if _, err := AMRPayloadPack(true, AMR_CMR_NO_REQUEST, []AMRFrame{{FrameType: 2, Speech: make([]byte, 31)}}); err == nil {
+		t.Fatal("frame of invalid size is packed")
+	}
+}
+
+func TestCodecAMR(t *testing.T) {
+	backend := &amrTestBackend{}
+	codecManager := CodecManagerDefaultCreate()
+	if err := CodecAMRRegister(codecManager, backend); err != nil {
+		t.Fatal(err)
+	}
+	descriptor := &CodecDescriptor{PayloadType: 98, Name: AMR_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1,
+		Format: "octet-align=1;mode-set=2,5,7;mode-change-neighbor=1"}
+	codec, err := codecManager.CodecManagerCodecGet(descriptor)
+	if err != nil || codec == nil {
+		t.Fatalf("amr codec is not found: %v", err)
+	}
+	if err := codec.CodecOpen(); err != nil {
+		t.Fatal(err)
+	}
+
+	frameSamples := int(descriptor.CodecFrameSamplesCalculate())
+	encoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	decoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	encode := func(value int16) {
+		samples := make([]int16, frameSamples)
+		samples[0] = value
+		in := CodecFrame{Buffer: bytes.NewBuffer(binaryx.Int16SliceToByteSlice(samples))}
+		in.Size = int64(in.Buffer.Len())
+		if err := codec.CodecEncode(&in, &encoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	encode(1)
+	if encoded.Size != 0 {
+		t.Fatalf("first frame carries payload of %d bytes", encoded.Size)
+	}
+	encode(2)
+	/* CMR, ToC and the speech of the highest mode of the mode set */
+	if encoded.Size != 2+31 {
+		t.Fatalf("payload size %d, want %d", encoded.Size, 2+31)
+	}
+	payload := append([]byte(nil), encoded.Buffer.Bytes()...)
+	if payload[0]>>4 != AMR_CMR_NO_REQUEST {
+		t.Fatalf("unexpected cmr %d", payload[0]>>4)
+	}
+
+	/* the payload is decoded to two frames, the second one is output on the empty frame */
+	for j, in := range []CodecFrame{{Buffer: bytes.NewBuffer(payload), Size: int64(len(payload))}, {Buffer: bytes.NewBuffer(nil)}} {
+		if err := codec.CodecDecode(&in, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(decoded.Buffer.Bytes())
+		if len(samples) != frameSamples || samples[0] != 1 {
+			t.Fatalf("frame %d: %d samples of %v", j, len(samples), samples[:1])
+		}
+	}
+
+	/* remote requests mode 0, the encoder steps to the neighboring modes of the mode set */
+	request, _ := AMRPayloadPack(false, 0, []AMRFrame{{FrameType: AMR_FT_NO_DATA}})
+	if err := codec.CodecDecode(&CodecFrame{Buffer: bytes.NewBuffer(request), Size: int64(len(request))}, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		encode(0)
+		encode(0)
+	}
+	want := []int{7, 5, 2, 2}
+	for i, mode := range want {
+		if backend.modes[i] != mode {
+			t.Fatalf("encoder modes %v, want %v", backend.modes, want)
+		}
+	}
+}
@@ -2,6 +2,7 @@ package mpf
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/navi-tt/go-mrcp/apr"
 	"strings"
 )
@@ -9,6 +10,9 @@ import (
 /** Codec frame time base in msec */
 const CODEC_FRAME_TIME_BASE = 10
 
+/** Max frame duration in msec */
+const CODEC_FRAME_TIME_MAX = 30
+
 const LPCM_CODEC_NAME = "LPCM"
 
 const LPCM_CODEC_NAME_LENGTH = len(LPCM_CODEC_NAME) - 1
@@ -41,12 +45,13 @@ const (
 
 /** Codec descriptor */
 type CodecDescriptor struct {
-	PayloadType  uint8  // Payload type used in RTP packet
-	Name         string // Codec name
-	SamplingRate uint16 // Sampling rate
-	ChannelCount uint8  // Channel count
-	Format       string // Codec dependent additional format
-	Enabled      bool   // Enabled/disabled state
+	PayloadType   uint8  // Payload type used in RTP packet
+	Name          string // Codec name
+	SamplingRate  uint16 // Sampling rate
+	ChannelCount  uint8  // Channel count
+	Format        string // Codec dependent additional format
+	Enabled       bool   // Enabled/disabled state
+	FrameDuration int64  // Frame duration in msec (CODEC_FRAME_TIME_BASE if 0)
 }
 
 /** List of codec descriptors */
@@ -112,7 +117,7 @@ func CodecDescriptorInit(descriptor *CodecDescriptor) {
 	descriptor.ChannelCount = 0
 	descriptor.Format = ""
 	descriptor.Enabled = true
-
+	descriptor.FrameDuration = 0
 }
 
 /** Initialize codec descriptor */
@@ -122,15 +127,93 @@ func CodecDescriptorCreate() *CodecDescriptor {
 	return d
 }
 
+/** Clone codec descriptor */
+func CodecDescriptorClone(src *CodecDescriptor) *CodecDescriptor {
+	descriptor := *src
+	return &descriptor
+}
+
+/**
+ * Get params of codec dependent format (SDP fmtp), e.g. "mode-set=0,2;octet-align=1".
+ * Names are lower cased, quotes of values are trimmed.
+ */
+func (d *CodecDescriptor) CodecFormatParamsGet() map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(d.Format, ";") {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+		name, value := param, ""
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			name, value = strings.TrimSpace(param[:i]), strings.Trim(strings.TrimSpace(param[i+1:]), `"`)
+		}
+		params[strings.ToLower(name)] = value
+	}
+	return params
+}
+
+/**
+ * Validate frame duration, which is to be a multiple of CODEC_FRAME_TIME_BASE up to CODEC_FRAME_TIME_MAX (10, 20 or 30 msec).
+ * @param duration the frame duration in msec
+ */
+func FrameDurationValidate(duration int64) error {
+	if duration <= 0 || duration > CODEC_FRAME_TIME_MAX || duration%CODEC_FRAME_TIME_BASE != 0 {
+		return fmt.Errorf("unsupported frame duration %d msec", duration)
+	}
+	return nil
+}
+
+/** Get frame duration in msec (CODEC_FRAME_TIME_BASE if the descriptor is nil) */
+func (d *CodecDescriptor) CodecFrameDurationGet() int64 {
+	if d != nil && d.FrameDuration > 0 {
+		return d.FrameDuration
+	}
+	return CODEC_FRAME_TIME_BASE
+}
+
 /** Calculate encoded frame size in bytes */
 func (d *CodecDescriptor) CodecFrameSizeCalculate(attribs *CodecAttribs) int64 {
-	return int64(d.ChannelCount) * int64(attribs.BitsPerSample) * CODEC_FRAME_TIME_BASE * int64(d.SamplingRate) / 1000 / 8
+	return int64(d.ChannelCount) * int64(attribs.BitsPerSample) * d.CodecFrameDurationGet() * int64(d.SamplingRate) / 1000 / 8
 	/* 1000 - msec per sec, 8 - bits per byte */
 }
 
 /** Calculate samples of the frame (ts) */
 func (d *CodecDescriptor) CodecFrameSamplesCalculate() int64 {
-	return int64(d.ChannelCount) * CODEC_FRAME_TIME_BASE * int64(d.SamplingRate) / 1000
+	return int64(d.ChannelCount) * d.CodecFrameDurationGet() * int64(d.SamplingRate) / 1000
+}
+
+/** Calculate linear frame size in bytes of the frame duration */
+func (d *CodecDescriptor) CodecLinearFrameSizeGet() int64 {
+	return int64(d.ChannelCount) * BYTES_PER_SAMPLE * d.CodecFrameDurationGet() * int64(d.SamplingRate) / 1000
+}
+
+/**
+ * Get RTP clock rate of the codec, which differs from the sampling rate for G.722
+ * (RFC3551 advertises 8000 for historical reasons, while the audio is sampled at 16000).
+ */
+func (d *CodecDescriptor) CodecRtpClockRateGet() uint16 {
+	if strings.EqualFold(d.Name, G722_CODEC_NAME) || (d.Name == "" && d.PayloadType == RTP_PT_G722) {
+		return d.SamplingRate / 2
+	}
+	return d.SamplingRate
+}
+
+/** Calculate RTP timestamp increment of the frame */
+func (d *CodecDescriptor) CodecFrameTimestampCalculate() int64 {
+	return int64(d.ChannelCount) * d.CodecFrameDurationGet() * int64(d.CodecRtpClockRateGet()) / 1000
+}
+
+/**
+ * Get sampling rate of the codec by RTP clock rate (as advertised in SDP rtpmap).
+ * @param name the codec name
+ * @param clockRate the RTP clock rate
+ */
+func CodecSamplingRateGet(name string, clockRate uint16) uint16 {
+	if strings.EqualFold(name, G722_CODEC_NAME) && clockRate == G722_CLOCK_RATE {
+		return clockRate * 2
+	}
+	return clockRate
 }
 
 /** Calculate linear frame size in bytes */
@@ -173,7 +256,7 @@ func CodecListsCompare(codecList1, codecList2 *CodecList) bool {
 			i++
 		}
 		for j < codecList2.DescriptorArr.Stack.Size() {
-			descriptor2 = codecList1.DescriptorArr.Stack.Index(j).(*CodecDescriptor)
+			descriptor2 = codecList2.DescriptorArr.Stack.Index(j).(*CodecDescriptor)
 			if descriptor2.Enabled {
 				break
 			}
@@ -231,7 +314,7 @@ func CodecLPcmDescriptorCreate(samplingRate uint16, channelCount uint8) *CodecDe
 	descriptor.Name = lpcmAttribs.Name
 	descriptor.SamplingRate = samplingRate
 	descriptor.ChannelCount = channelCount
-	return nil
+	return descriptor
 }
 
 /** Create codec descriptor by capabilities */
@@ -250,12 +333,13 @@ func CodecDescriptorCreateByCapabilities(capabilities *CodecCapabilities, peer *
 	}
 
 	descriptor = &CodecDescriptor{
-		PayloadType:  peer.PayloadType,
-		Name:         peer.Name,
-		SamplingRate: peer.SamplingRate,
-		ChannelCount: peer.ChannelCount,
-		Format:       peer.Format,
-		Enabled:      peer.Enabled,
+		PayloadType:   peer.PayloadType,
+		Name:          peer.Name,
+		SamplingRate:  peer.SamplingRate,
+		ChannelCount:  peer.ChannelCount,
+		Format:        peer.Format,
+		Enabled:       peer.Enabled,
+		FrameDuration: peer.FrameDuration,
 	}
 
 	if !strings.EqualFold(peer.Name, attribs.Name) {
@@ -304,6 +388,7 @@ func CodecDescriptorMatchByAttribs(descriptor, staticDescriptor *CodecDescriptor
 		}
 	} else {
 		if strings.EqualFold(attribs.Name, descriptor.Name) {
+			descriptor.SamplingRate = CodecSamplingRateGet(descriptor.Name, descriptor.SamplingRate)
 			if SamplingRateCheck(descriptor.SamplingRate, attribs.SampleRates) {
 				match = true
 			}
@@ -339,6 +424,20 @@ func CodecCapabilitiesAttribsFind(capabilities *CodecCapabilities, descriptor *C
 	return nil
 }
 
+/** Find attribs in codec capabilities matching both the name and sampling rate of the descriptor */
+func (c *CodecCapabilities) CodecCapabilitiesNativeFind(descriptor *CodecDescriptor) *CodecAttribs {
+	if c.AttribArr == nil {
+		return nil
+	}
+	for i := 0; i < c.AttribArr.Stack.Size(); i++ {
+		attribs := c.AttribArr.ArrayHeaderIndex(i).(*CodecAttribs)
+		if strings.EqualFold(attribs.Name, descriptor.Name) && SamplingRateCheck(descriptor.SamplingRate, attribs.SampleRates) {
+			return attribs
+		}
+	}
+	return nil
+}
+
 /** Initialize codec capabilities */
 func (c *CodecCapabilities) CodecCapabilitiesInit(initialCount int) {
 	c.AttribArr = apr.NewArrayHeader(initialCount)
@@ -371,6 +470,7 @@ func (c *CodecCapabilities) CodecCapabilitiesAdd(sampleRates int, codecName stri
 
 /** Add default (linear PCM) capabilities */
 func (c *CodecCapabilities) CodecDefaultCapabilitiesAdd() {
+	CodecDefaultCapabilitiesAdd(c)
 }
 
 /** Validate codec capabilities */
@@ -382,7 +482,7 @@ func (c *CodecCapabilities) CodecCapabilitiesValidate() {
 
 /** Find matched descriptor in codec list */
 func (c *CodecList) CodecListDescriptorFind(descriptor *CodecDescriptor) *CodecDescriptor {
-	return nil
+	return CodecListDescriptorFind(c, descriptor)
 }
 
 /** Match codec list with specified capabilities */
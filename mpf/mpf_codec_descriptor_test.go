@@ -0,0 +1,26 @@
+package mpf
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCodecFormatParamsGet(t *testing.T) {
+	tests := []struct {
+		format string
+		params map[string]string
+	}{
+		{"", map[string]string{}},
+		{"0-15", map[string]string{"0-15": ""}},
+		{"mode-set=0,2;octet-align=1", map[string]string{"mode-set": "0,2", "octet-align": "1"}},
+		{" Mode-Set = 0,2 ; ; octet-align=1; ", map[string]string{"mode-set": "0,2", "octet-align": "1"}},
+		{`maxaveragebitrate="20000";useinbandfec=1;stereo`, map[string]string{"maxaveragebitrate": "20000", "useinbandfec": "1", "stereo": ""}},
+		{"config=a=b", map[string]string{"config": "a=b"}},
+	}
+	for _, test := range tests {
+		descriptor := &CodecDescriptor{Name: "AMR", Format: test.format}
+		if params := descriptor.CodecFormatParamsGet(); !reflect.DeepEqual(params, test.params) {
+			t.Errorf("params of %q: %v, want %v", test.format, params, test.params)
+		}
+	}
+}
@@ -10,6 +10,12 @@ const (
 	G711A_CODEC_NAME = "PCMA"
 )
 
+/** Encoded silence of G.711 u-law */
+const G711U_SILENCE = 0xFF
+
+/** Encoded silence of G.711 A-law */
+const G711A_SILENCE = 0xD5
+
 func G711Open(codec *Codec) error {
 	return nil
 }
@@ -18,48 +24,34 @@ func G711Close(codec *Codec) error {
 	return nil
 }
 
+/** Encode linear frame (2 bytes per sample) to u-law one (1 byte per sample) */
 func G711UEncode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	data := g711.EncodeUlaw(frameIn.Buffer.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, g711.EncodeUlaw(codecFrameDataGet(frameIn)))
 }
 
+/** Decode u-law frame (1 byte per sample) to linear one (2 bytes per sample) */
 func G711UDecode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	data := g711.DecodeUlaw(frameIn.Buffer.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, g711.DecodeUlaw(codecFrameDataGet(frameIn)))
 }
 
+/** Fill u-law frame of the (encoded) frame size with silence */
 func G711UInit(codec *Codec, frameOut *CodecFrame) error {
-	buf := bytes.NewBuffer(make([]byte, frameOut.Size))
-	data := g711.EncodeUlaw(buf.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, bytes.Repeat([]byte{G711U_SILENCE}, int(frameOut.Size)))
 }
 
+/** Encode linear frame (2 bytes per sample) to A-law one (1 byte per sample) */
 func G711AEncode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	data := g711.EncodeAlaw(frameIn.Buffer.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, g711.EncodeAlaw(codecFrameDataGet(frameIn)))
 }
 
+/** Decode A-law frame (1 byte per sample) to linear one (2 bytes per sample) */
 func G711ADecode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	data := g711.DecodeAlaw(frameIn.Buffer.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, g711.DecodeAlaw(codecFrameDataGet(frameIn)))
 }
 
+/** Fill A-law frame of the (encoded) frame size with silence */
 func G711AInit(codec *Codec, frameOut *CodecFrame) error {
-	buf := bytes.NewBuffer(make([]byte, frameOut.Size))
-	data := g711.EncodeAlaw(buf.Bytes())
-	n, err := frameOut.Buffer.Write(data)
-	frameOut.Size = int64(n)
-	return err
+	return codecFrameDataSet(frameOut, bytes.Repeat([]byte{G711A_SILENCE}, int(frameOut.Size)))
 }
 
 var g711UVTable = CodecVTable{
@@ -69,6 +61,7 @@ var g711UVTable = CodecVTable{
 	Decode:     G711UDecode,
 	Dissect:    nil,
 	Initialize: G711UInit,
+	Conceal:    CodecLinearConceal,
 }
 
 var g711AVTable = CodecVTable{
@@ -78,6 +71,7 @@ var g711AVTable = CodecVTable{
 	Decode:     G711ADecode,
 	Dissect:    nil,
 	Initialize: G711AInit,
+	Conceal:    CodecLinearConceal,
 }
 
 var g711UDescriptor = CodecDescriptor{
@@ -0,0 +1,111 @@
+package mpf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Round trip linear frame through the codec */
+func g711RoundTrip(t *testing.T, descriptor *CodecDescriptor) {
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if err != nil || codec == nil {
+		t.Fatalf("codec %s is not found: %v", descriptor.Name, err)
+	}
+	if err := codec.CodecOpen(); err != nil {
+		t.Fatal(err)
+	}
+
+	samples := make([]int16, descriptor.CodecFrameSamplesCalculate())
+	for i := range samples {
+		samples[i] = int16(8000 * math.Sin(2*math.Pi*1000*float64(i)/8000))
+	}
+	in := CodecFrame{Buffer: bytes.NewBuffer(binaryx.Int16SliceToByteSlice(samples))}
+	in.Size = int64(in.Buffer.Len())
+
+	encoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	if err := codec.CodecEncode(&in, &encoded); err != nil {
+		t.Fatal(err)
+	}
+	if want := descriptor.CodecFrameSizeCalculate(codec.Attribs); encoded.Size != want {
+		t.Fatalf("encoded frame size %d, want %d", encoded.Size, want)
+	}
+
+	decoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	/* the output frame is replaced, not appended to */
+	for i := 0; i < 2; i++ {
+		if err := codec.CodecDecode(&encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if decoded.Size != in.Size {
+		t.Fatalf("decoded frame size %d, want %d", decoded.Size, in.Size)
+	}
+	out, _ := binaryx.ByteSliceToInt16Slice(decoded.Buffer.Bytes())
+	for i := range samples {
+		/* companding error is proportional to the amplitude */
+		if diff := math.Abs(float64(out[i]) - float64(samples[i])); diff > math.Abs(float64(samples[i]))/16+16 {
+			t.Fatalf("sample %d decoded as %d, want %d", i, out[i], samples[i])
+		}
+	}
+
+	silence := CodecFrame{Buffer: bytes.NewBuffer(nil), Size: encoded.Size}
+	if err := codec.CodecInitialize(&silence); err != nil {
+		t.Fatal(err)
+	}
+	if err := codec.CodecDecode(&silence, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	out, _ = binaryx.ByteSliceToInt16Slice(decoded.Buffer.Bytes())
+	for i := range out {
+		/* A-law has no exact zero, the smallest magnitude is 8 */
+		if out[i] > 8 || out[i] < -8 {
+			t.Fatalf("silence decoded as %d", out[i])
+		}
+	}
+}
+
+func TestCodecG711U(t *testing.T) {
+	g711RoundTrip(t, CodecDescriptorClone(&g711UDescriptor))
+}
+
+func TestCodecG711A(t *testing.T) {
+	g711RoundTrip(t, CodecDescriptorClone(&g711ADescriptor))
+}
+
+func TestCodecG711ADecoder(t *testing.T) {
+	var (
+		payload = bytes.Repeat([]byte{G711A_SILENCE}, 80)
+		codec   = CodecManagerDefaultGet().CodecManagerCodecPayloadTypeFind(RTP_PT_PCMA)
+	)
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, payload)
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecDescriptorClone(&g711ADescriptor)
+
+	decoder := DecoderCreate(source, codec.CodecClone())
+	if decoder == nil {
+		t.Fatalf("decoder is not created")
+	}
+	if !CodecLPcmDescriptorMatch(decoder.RXDescriptor) || decoder.RXDescriptor.SamplingRate != 8000 {
+		t.Fatalf("decoder produces %s/%d", decoder.RXDescriptor.Name, decoder.RXDescriptor.SamplingRate)
+	}
+	if err := decoder.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	frame := Frame{CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(nil)}}
+	if err := decoder.AudioStreamFrameRead(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.CodecFrame.Size != CodecLinearFrameSizeCalculate(8000, 1) {
+		t.Fatalf("decoded frame size %d", frame.CodecFrame.Size)
+	}
+	if err := decoder.AudioStreamRXClose(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,85 @@
+package mpf
+
+import (
+	"github.com/navi-tt/go-mrcp/mpf/codecs/g722"
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+const G722_CODEC_NAME = "G722"
+
+/** RTP clock rate of G.722 advertised in SDP (the actual sampling rate is 16000) */
+const G722_CLOCK_RATE = g722.ClockRate
+
+/** G.722 codec instance keeping the adaptive state of encoder and decoder */
+type g722Codec struct {
+	encoder *g722.Encoder
+	decoder *g722.Decoder
+}
+
+func (c *g722Codec) open(codec *Codec) error {
+	c.encoder = g722.NewEncoder()
+	c.decoder = g722.NewDecoder()
+	return nil
+}
+
+func (c *g722Codec) close(codec *Codec) error {
+	c.encoder = nil
+	c.decoder = nil
+	return nil
+}
+
+/** Encode linear frame (2 bytes per sample) to G.722 one (1 byte per 2 samples) */
+func (c *g722Codec) encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.encoder == nil {
+		c.encoder = g722.NewEncoder()
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(frameIn))
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, c.encoder.Encode(samples))
+}
+
+/** Decode G.722 frame (1 byte per 2 samples) to linear one (2 bytes per sample) */
+func (c *g722Codec) decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.decoder == nil {
+		c.decoder = g722.NewDecoder()
+	}
+	samples := c.decoder.Decode(codecFrameDataGet(frameIn))
+	return codecFrameDataSet(frameOut, binaryx.Int16SliceToByteSlice(samples))
+}
+
+/** Fill G.722 frame of the (encoded) frame size with silence */
+func G722Init(codec *Codec, frameOut *CodecFrame) error {
+	/* encoded silence depends on the codec state, take the one of the initial state */
+	return codecFrameDataSet(frameOut, g722.NewEncoder().Encode(make([]int16, frameOut.Size*2)))
+}
+
+var g722Descriptor = CodecDescriptor{
+	PayloadType:  RTP_PT_G722,
+	Name:         G722_CODEC_NAME,
+	SamplingRate: g722.SampleRate,
+	ChannelCount: 1,
+	Format:       "",
+	Enabled:      true,
+}
+
+var g722Attribs = CodecAttribs{
+	Name:          G722_CODEC_NAME,
+	BitsPerSample: 4, /* 64 kbit/s at 16 kHz */
+	SampleRates:   MPF_SAMPLE_RATE_16000,
+}
+
+/** Create G.722 codec (each instance has its own encoder and decoder state) */
+func CodecG722Create() *Codec {
+	c := &g722Codec{}
+	vtable := &CodecVTable{
+		Open:       c.open,
+		Close:      c.close,
+		Encode:     c.encode,
+		Decode:     c.decode,
+		Dissect:    nil,
+		Initialize: G722Init,
+	}
+	return CodecCreate(vtable, &g722Attribs, &g722Descriptor)
+}
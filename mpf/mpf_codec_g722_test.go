@@ -0,0 +1,89 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecG722Descriptor(t *testing.T) {
+	codecManager := CodecManagerDefaultGet()
+	codecList := &CodecList{}
+	CodecListInit(codecList, 2)
+	if err := codecManager.CodecManagerCodecListLoad(codecList, "G722/9/8000 G722/97/16000"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if descriptor.SamplingRate != 16000 {
+			t.Fatalf("sampling rate %d of %s/%d, want 16000", descriptor.SamplingRate, descriptor.Name, descriptor.PayloadType)
+		}
+		if rate := descriptor.CodecRtpClockRateGet(); rate != G722_CLOCK_RATE {
+			t.Fatalf("RTP clock rate %d, want %d", rate, G722_CLOCK_RATE)
+		}
+	}
+
+	/* static payload type resolves to the actual sampling rate */
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_G722, SamplingRate: G722_CLOCK_RATE, ChannelCount: 1}
+	codec, err := codecManager.CodecManagerCodecGet(descriptor)
+	if err != nil || codec == nil {
+		t.Fatalf("G722 codec is not found: %v", err)
+	}
+	if descriptor.SamplingRate != 16000 {
+		t.Fatalf("sampling rate %d, want 16000", descriptor.SamplingRate)
+	}
+	if size := descriptor.CodecFrameSizeCalculate(codec.Attribs); size != 80 {
+		t.Fatalf("frame size %d, want 80", size)
+	}
+	if samples := descriptor.CodecFrameSamplesCalculate(); samples != 160 {
+		t.Fatalf("frame samples %d, want 160", samples)
+	}
+	if ts := descriptor.CodecFrameTimestampCalculate(); ts != 80 {
+		t.Fatalf("frame timestamp increment %d, want 80", ts)
+	}
+}
+
+func TestCodecG722(t *testing.T) {
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_G722, ChannelCount: 1}
+	codec, _ := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if codec == nil {
+		t.Fatal("G722 codec is not found")
+	}
+	if err := codec.CodecOpen(); err != nil {
+		t.Fatal(err)
+	}
+	frameSize := descriptor.CodecFrameSizeCalculate(codec.Attribs)
+
+	in := CodecFrame{Buffer: bytes.NewBuffer(make([]byte, CodecLinearFrameSizeCalculate(descriptor.SamplingRate, 1)))}
+	in.Size = int64(in.Buffer.Len())
+	encoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	decoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	/* the output frames are replaced, not appended to */
+	for i := 0; i < 2; i++ {
+		if err := codec.CodecEncode(&in, &encoded); err != nil {
+			t.Fatal(err)
+		}
+		if encoded.Size != frameSize {
+			t.Fatalf("encoded frame size %d, want %d", encoded.Size, frameSize)
+		}
+		if err := codec.CodecDecode(&encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Size != in.Size {
+			t.Fatalf("decoded frame size %d, want %d", decoded.Size, in.Size)
+		}
+	}
+
+	silence := CodecFrame{Buffer: bytes.NewBuffer(nil), Size: frameSize}
+	if err := codec.CodecInitialize(&silence); err != nil {
+		t.Fatal(err)
+	}
+	if silence.Size != frameSize {
+		t.Fatalf("silence frame size %d, want %d", silence.Size, frameSize)
+	}
+
+	/* instances do not share the codec state */
+	other, _ := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if other.VTable == codec.VTable {
+		t.Fatal("G722 codec instances share the state")
+	}
+}
@@ -1,7 +1,8 @@
 package mpf
 
 import (
-	"github.com/navi-tt/go-mrcp/utils/binaryx"
+	"encoding/binary"
+	"fmt"
 )
 
 /* linear 16-bit PCM (RFC3551) */
@@ -15,38 +16,48 @@ func L16Close(codec *Codec) error {
 	return nil
 }
 
-func L16Encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	ins, err := binaryx.ByteSliceToInt16Slice(frameIn.Buffer.Bytes())
-	if err != nil {
-		return err
+/* Swap byte order of 16-bit samples between host (little-endian) and network (big-endian) ones */
+func l16ByteOrderSwap(in []byte, toNetwork bool) ([]byte, error) {
+	if len(in)%2 != 0 {
+		return nil, fmt.Errorf("invalid L16 frame size %d", len(in))
 	}
-
-	var outs = make([]int16, 0, len(ins))
-	for i := 0; i < len(ins); i++ {
-		out := binaryx.HToNS(uint16(ins[i]))
-		outs = append(outs, int16(out))
+	out := make([]byte, len(in))
+	for i := 0; i < len(in); i += 2 {
+		if toNetwork {
+			binary.BigEndian.PutUint16(out[i:], binary.LittleEndian.Uint16(in[i:]))
+		} else {
+			binary.LittleEndian.PutUint16(out[i:], binary.BigEndian.Uint16(in[i:]))
+		}
 	}
+	return out, nil
+}
 
-	data := binaryx.Int16SliceToByteSlice(outs)
-	frameOut.Buffer.Write(data)
-	return err
+/** Convert host order linear data to network order one, e.g. to store it as audio/L16 (RFC 3551) */
+func L16NetworkOrderConvert(data []byte) ([]byte, error) {
+	return l16ByteOrderSwap(data, true)
 }
 
-func L16Decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
-	ins, err := binaryx.ByteSliceToInt16Slice(frameIn.Buffer.Bytes())
+/** Encode host order linear frame to network order one */
+func L16Encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	data, err := l16ByteOrderSwap(codecFrameDataGet(frameIn), true)
 	if err != nil {
 		return err
 	}
+	return codecFrameDataSet(frameOut, data)
+}
 
-	var outs = make([]int16, 0, len(ins))
-	for i := 0; i < len(ins); i++ {
-		out := binaryx.NToHS(uint16(ins[i]))
-		outs = append(outs, int16(out))
+/** Decode network order linear frame to host order one */
+func L16Decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	data, err := l16ByteOrderSwap(codecFrameDataGet(frameIn), false)
+	if err != nil {
+		return err
 	}
+	return codecFrameDataSet(frameOut, data)
+}
 
-	data := binaryx.Int16SliceToByteSlice(outs)
-	frameOut.Buffer.Write(data)
-	return err
+/** Fill L16 frame of the frame size with silence */
+func L16Init(codec *Codec, frameOut *CodecFrame) error {
+	return codecFrameDataSet(frameOut, make([]byte, frameOut.Size))
 }
 
 var l16VTable = CodecVTable{
@@ -55,7 +66,8 @@ var l16VTable = CodecVTable{
 	Encode:     L16Encode,
 	Decode:     L16Decode,
 	Dissect:    nil,
-	Initialize: nil,
+	Initialize: L16Init,
+	Conceal:    CodecLinearConceal,
 }
 
 var l16Attribs = CodecAttribs{
@@ -67,3 +79,23 @@ var l16Attribs = CodecAttribs{
 func CodecL16Create() *Codec {
 	return CodecCreate(&l16VTable, &l16Attribs, nil)
 }
+
+/**
+ * Create descriptor of L16 variant mapped to dynamic payload type (L16 has no static one at 8/16 kHz).
+ * @param samplingRate the sampling rate (8000, 16000, ...)
+ * @param payloadType the dynamic payload type (96-127) negotiated by SDP rtpmap
+ */
+func CodecL16DescriptorCreate(samplingRate uint16, payloadType RtpPayloadType) (*CodecDescriptor, error) {
+	if payloadType < RTP_PT_DYNAMIC || payloadType > RTP_PT_DYNAMIC_MAX {
+		return nil, fmt.Errorf("payload type %d of L16 is not dynamic", payloadType)
+	}
+	if !SamplingRateCheck(samplingRate, l16Attribs.SampleRates) {
+		return nil, fmt.Errorf("unsupported L16 sampling rate %d", samplingRate)
+	}
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = payloadType
+	descriptor.Name = L16_CODEC_NAME
+	descriptor.SamplingRate = samplingRate
+	descriptor.ChannelCount = 1
+	return descriptor, nil
+}
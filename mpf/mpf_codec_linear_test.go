@@ -0,0 +1,44 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCodecL16(t *testing.T) {
+	for _, rate := range []uint16{8000, 16000} {
+		descriptor, err := CodecL16DescriptorCreate(rate, 97)
+		if err != nil {
+			t.Fatal(err)
+		}
+		codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+		if err != nil || codec == nil {
+			t.Fatalf("L16/%d is not mapped to codec: %v", rate, err)
+		}
+		if size, want := descriptor.CodecFrameSizeCalculate(codec.Attribs), CodecLinearFrameSizeCalculate(rate, 1); size != want {
+			t.Fatalf("L16/%d frame size %d, want %d", rate, size, want)
+		}
+
+		/* host (little-endian) 0x1234 goes to network as 0x12 0x34 */
+		in := CodecFrame{Buffer: bytes.NewBuffer([]byte{0x34, 0x12, 0xff, 0x00})}
+		in.Size = int64(in.Buffer.Len())
+		encoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+		if err := codec.CodecEncode(&in, &encoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded.Buffer.Bytes(), []byte{0x12, 0x34, 0x00, 0xff}) {
+			t.Fatalf("encoded % x", encoded.Buffer.Bytes())
+		}
+		decoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+		if err := codec.CodecDecode(&encoded, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(decoded.Buffer.Bytes(), []byte{0x34, 0x12, 0xff, 0x00}) {
+			t.Fatalf("decoded % x", decoded.Buffer.Bytes())
+		}
+	}
+
+	if _, err := CodecL16DescriptorCreate(16000, RTP_PT_PCMU); err == nil {
+		t.Fatalf("static payload type is accepted")
+	}
+}
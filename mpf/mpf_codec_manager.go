@@ -5,6 +5,7 @@ import (
 	"github.com/navi-tt/go-mrcp/apr"
 	"strconv"
 	"strings"
+	"sync"
 )
 
 /** Opaque codec manager declaration */
@@ -12,8 +13,15 @@ type CodecManager struct {
 	CodecArr *apr.ArrayHeader // Dynamic (resizable) array of codecs (mpf_codec_t*
 
 	EventDescriptor *CodecDescriptor // Default named event descriptor
+
+	mutex sync.RWMutex // Guard of codecs registered at runtime
 }
 
+var (
+	defaultCodecManager     *CodecManager
+	defaultCodecManagerOnce sync.Once
+)
+
 /** Create codec manager */
 func CodecManagerCreate(codecCount int) *CodecManager {
 	codecManager := CodecManager{
@@ -31,19 +39,67 @@ func (cm *CodecManager) CodecManagerCodecRegister(codec *Codec) error {
 	if codec == nil || codec.Attribs == nil || codec.Attribs.Name == "" {
 		return fmt.Errorf(`codec is nil, or codec.Attribs is nil, or codec.Attribs.Name is ""`)
 	}
+	cm.mutex.Lock()
+	defer cm.mutex.Unlock()
 	cm.CodecArr.Stack.Push(codec)
 	return nil
 }
 
+/**
+ * Register codec factory in codec manager.
+ * Each codec got from the manager is created by the factory, so codecs may keep their own state.
+ * @param attribs the codec attributes (capabilities)
+ * @param factory the factory creating codec instances
+ */
+func (cm *CodecManager) CodecManagerCodecFactoryRegister(attribs *CodecAttribs, factory CodecFactory) error {
+	if attribs == nil || attribs.Name == "" || factory == nil {
+		return fmt.Errorf(`attribs is nil, or attribs.Name is "", or factory is nil`)
+	}
+	codec := factory()
+	if codec == nil {
+		return fmt.Errorf("factory of codec %s failed", attribs.Name)
+	}
+	codec.Attribs = attribs
+	codec.factory = factory
+	return cm.CodecManagerCodecRegister(codec)
+}
+
+/**
+ * Create codec manager with built-in codecs (L16, PCMU, PCMA, G722) registered.
+ */
+func CodecManagerDefaultCreate() *CodecManager {
+	codecManager := CodecManagerCreate(4)
+	_ = codecManager.CodecManagerCodecRegister(CodecL16Create())
+	_ = codecManager.CodecManagerCodecRegister(CodecG711UCreate())
+	_ = codecManager.CodecManagerCodecRegister(CodecG711ACreate())
+	_ = codecManager.CodecManagerCodecFactoryRegister(&g722Attribs, CodecG722Create)
+	return codecManager
+}
+
+/**
+ * Get default codec manager shared by engines and RTP streams.
+ * Codecs registered in the default manager become available to all of them.
+ */
+func CodecManagerDefaultGet() *CodecManager {
+	defaultCodecManagerOnce.Do(func() {
+		defaultCodecManager = CodecManagerDefaultCreate()
+	})
+	return defaultCodecManager
+}
+
 /** Get (allocate) codec by codec descriptor */
 func (cm *CodecManager) CodecManagerCodecGet(descriptor *CodecDescriptor) (*Codec, error) {
 	if descriptor == nil {
 		return nil, fmt.Errorf("descriptor is nil")
 	}
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
 	for i := 0; i < cm.CodecArr.Stack.Size(); i++ {
 		codec := cm.CodecArr.ArrayHeaderIndex(i).(*Codec)
 		if CodecDescriptorMatchByAttribs(descriptor, codec.StaticDescriptor, codec.Attribs) {
-			return codec.CodecClone(), nil
+			clone := codec.CodecClone()
+			clone.descriptor = descriptor
+			return clone, nil
 		}
 	}
 	return nil, nil
@@ -51,6 +107,8 @@ func (cm *CodecManager) CodecManagerCodecGet(descriptor *CodecDescriptor) (*Code
 
 /** Get (allocate) list of available codecs */
 func (cm *CodecManager) CodecManagerCodecListGet(codecList *CodecList) error {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
 	CodecListInit(codecList, cm.CodecArr.Stack.Size())
 	for i := 0; i < cm.CodecArr.Stack.Size(); i++ {
 		codec := cm.CodecArr.ArrayHeaderIndex(i).(*Codec)
@@ -107,6 +165,7 @@ func (cm *CodecManager) CodecManagerCodecParse(codecList *CodecList, codecDescSt
 		}
 
 		/* parse optional payload type */
+		str = ""
 		if len(codecDescs) > 1 {
 			str = codecDescs[1]
 		}
@@ -115,14 +174,16 @@ func (cm *CodecManager) CodecManagerCodecParse(codecList *CodecList, codecDescSt
 			descriptor.PayloadType = uint8(payloadType)
 
 			/* parse optional sampling rate */
+			str = ""
 			if len(codecDescs) > 2 {
 				str = codecDescs[2]
 			}
 			if len(str) > 0 {
 				samplingRate, _ := strconv.Atoi(str)
-				descriptor.SamplingRate = uint16(samplingRate)
+				descriptor.SamplingRate = CodecSamplingRateGet(descriptor.Name, uint16(samplingRate))
 
 				/* parse optional channel count */
+				str = ""
 				if len(codecDescs) > 3 {
 					str = codecDescs[3]
 				}
@@ -150,6 +211,8 @@ func (cm *CodecManager) CodecManagerCodecListLoad(codecList *CodecList, str stri
 
 /** Find codec by name  */
 func (cm *CodecManager) CodecManagerCodecFind(codecName string) *Codec {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
 	for i := 0; i < cm.CodecArr.Stack.Size(); i++ {
 		codec := cm.CodecArr.ArrayHeaderIndex(i).(*Codec)
 		if strings.EqualFold(codec.Attribs.Name, codecName) {
@@ -158,3 +221,29 @@ func (cm *CodecManager) CodecManagerCodecFind(codecName string) *Codec {
 	}
 	return nil
 }
+
+/** Find codec by static payload type */
+func (cm *CodecManager) CodecManagerCodecPayloadTypeFind(payloadType uint8) *Codec {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	for i := 0; i < cm.CodecArr.Stack.Size(); i++ {
+		codec := cm.CodecArr.ArrayHeaderIndex(i).(*Codec)
+		if codec.StaticDescriptor != nil && codec.StaticDescriptor.PayloadType == payloadType {
+			return codec
+		}
+	}
+	return nil
+}
+
+/** Find codec by name supporting the sampling rate */
+func (cm *CodecManager) CodecManagerCodecLookup(codecName string, samplingRate uint16) *Codec {
+	cm.mutex.RLock()
+	defer cm.mutex.RUnlock()
+	for i := 0; i < cm.CodecArr.Stack.Size(); i++ {
+		codec := cm.CodecArr.ArrayHeaderIndex(i).(*Codec)
+		if strings.EqualFold(codec.Attribs.Name, codecName) && SamplingRateCheck(samplingRate, codec.Attribs.SampleRates) {
+			return codec
+		}
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package mpf
+
+import "testing"
+
+func TestCodecManagerDefault(t *testing.T) {
+	manager := CodecManagerDefaultGet()
+	if codec := manager.CodecManagerCodecPayloadTypeFind(RTP_PT_PCMA); codec == nil || codec.Attribs.Name != G711A_CODEC_NAME {
+		t.Fatalf("PCMA is not found by payload type")
+	}
+	if codec := manager.CodecManagerCodecLookup("l16", 16000); codec == nil {
+		t.Fatalf("L16/16000 is not found")
+	}
+	if codec := manager.CodecManagerCodecLookup(G711U_CODEC_NAME, 11025); codec != nil {
+		t.Fatalf("PCMU/11025 is found")
+	}
+}
+
+func TestCodecManagerFactoryRegister(t *testing.T) {
+	var (
+		manager = CodecManagerCreate(1)
+		attribs = &CodecAttribs{Name: "X-TEST", BitsPerSample: 16, SampleRates: MPF_SAMPLE_RATE_16000}
+		created int
+	)
+	err := manager.CodecManagerCodecFactoryRegister(attribs, func() *Codec {
+		created++
+		return CodecCreate(&CodecVTable{}, nil, nil)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_DYNAMIC, Name: "x-test", SamplingRate: 16000, ChannelCount: 1}
+	codec1, err := manager.CodecManagerCodecGet(descriptor)
+	if err != nil || codec1 == nil {
+		t.Fatalf("codec is not got: %v", err)
+	}
+	codec2, _ := manager.CodecManagerCodecGet(descriptor)
+	if codec1 == codec2 || created != 3 {
+		t.Fatalf("codecs are not created by the factory, created %d", created)
+	}
+	if codec1.Attribs != attribs {
+		t.Fatalf("codec attribs are not set")
+	}
+}
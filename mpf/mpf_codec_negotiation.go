@@ -0,0 +1,272 @@
+package mpf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* Parse named event list (e.g. "0-15,66"), nil if the format is invalid */
+func eventListParse(format string) []bool {
+	events := make([]bool, 256)
+	for _, item := range strings.Split(format, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		first, last := item, item
+		if i := strings.IndexByte(item, '-'); i >= 0 {
+			first, last = item[:i], item[i+1:]
+		}
+		from, err1 := strconv.Atoi(strings.TrimSpace(first))
+		to, err2 := strconv.Atoi(strings.TrimSpace(last))
+		if err1 != nil || err2 != nil || from < 0 || to > 255 || from > to {
+			return nil
+		}
+		for event := from; event <= to; event++ {
+			events[event] = true
+		}
+	}
+	return events
+}
+
+/* Generate named event list of ranges */
+func eventListGenerate(events []bool) string {
+	var items []string
+	for event := 0; event < len(events); event++ {
+		if !events[event] {
+			continue
+		}
+		last := event
+		for last+1 < len(events) && events[last+1] {
+			last++
+		}
+		if last == event {
+			items = append(items, strconv.Itoa(event))
+		} else {
+			items = append(items, fmt.Sprintf("%d-%d", event, last))
+		}
+		event = last
+	}
+	return strings.Join(items, ",")
+}
+
+/**
+ * Negotiate codec dependent format (fmtp) of matching descriptors.
+ * Named events are intersected, AMR mode sets are intersected and the payload format must agree,
+ * the remote format is taken for other codecs (the local one if the remote is empty).
+ * @param local the local descriptor
+ * @param remote the remote descriptor
+ * @return the negotiated format and whether the formats are compatible
+ */
+func CodecFormatsNegotiate(local, remote *CodecDescriptor) (string, bool) {
+	switch {
+	case EventDescriptorCheck(local):
+		if local.Format == "" || remote.Format == "" {
+			return local.Format + remote.Format, true
+		}
+		localEvents, remoteEvents := eventListParse(local.Format), eventListParse(remote.Format)
+		if localEvents == nil || remoteEvents == nil {
+			return "", false
+		}
+		for event := range localEvents {
+			localEvents[event] = localEvents[event] && remoteEvents[event]
+		}
+		format := eventListGenerate(localEvents)
+		return format, format != ""
+	case strings.EqualFold(local.Name, AMR_CODEC_NAME) || strings.EqualFold(local.Name, AMR_WB_CODEC_NAME):
+		localAttribs, err := local.CodecAMRAttribsGet()
+		if err != nil {
+			return "", false
+		}
+		remoteAttribs, err := remote.CodecAMRAttribsGet()
+		if err != nil {
+			return "", false
+		}
+		if localAttribs.ModeSet != 0 {
+			if remoteAttribs.ModeSet != 0 {
+				remoteAttribs.ModeSet &= localAttribs.ModeSet
+				if remoteAttribs.ModeSet == 0 {
+					return "", false
+				}
+			} else {
+				remoteAttribs.ModeSet = localAttribs.ModeSet
+			}
+		}
+		return remoteAttribs.AMRFormatGenerate(), true
+	}
+	if remote.Format != "" {
+		return remote.Format, true
+	}
+	return local.Format, true
+}
+
+/**
+ * Negotiate descriptor matching by name, sampling rate, channel count and format.
+ * The payload type of the remote descriptor is kept, as the offerer's payload types are used in the answer.
+ * @param local the local descriptor
+ * @param remote the remote descriptor
+ * @return the negotiated descriptor, nil if the descriptors do not match
+ */
+func CodecDescriptorsNegotiate(local, remote *CodecDescriptor) *CodecDescriptor {
+	if !CodecDescriptorsMatch(local, remote) {
+		return nil
+	}
+	if local.PayloadType < RTP_PT_DYNAMIC && remote.PayloadType < RTP_PT_DYNAMIC {
+		/* static payload types are matched regardless of names */
+		return CodecDescriptorClone(remote)
+	}
+	format, ok := CodecFormatsNegotiate(local, remote)
+	if !ok {
+		return nil
+	}
+	descriptor := CodecDescriptorClone(remote)
+	descriptor.Format = format
+	descriptor.Enabled = true
+	return descriptor
+}
+
+/**
+ * Merge local and remote codec lists to the list of their intersection.
+ * The preferred list is walked in order, each of its enabled descriptors is negotiated
+ * with the first matching enabled descriptor of the other list, so the result is deterministic.
+ * The primary descriptor is the first negotiated non event one, the event descriptor is the first
+ * negotiated named event one of the primary sampling rate (or the first one if none is).
+ * @param local the local codec list
+ * @param remote the remote codec list
+ * @param ownPreference whether the local (own) preference is followed, the remote one otherwise
+ */
+func CodecListsMerge(local, remote *CodecList, ownPreference bool) (*CodecList, error) {
+	if local == nil || remote == nil || local.DescriptorArr == nil || remote.DescriptorArr == nil {
+		return nil, fmt.Errorf("codec list is nil")
+	}
+	preferred, other := remote, local
+	if ownPreference {
+		preferred, other = local, remote
+	}
+
+	merged := &CodecList{}
+	CodecListInit(merged, preferred.DescriptorArr.Stack.Size())
+	for i := 0; i < preferred.DescriptorArr.Stack.Size(); i++ {
+		descriptor1 := preferred.CodecListDescriptorGet(i)
+		if !descriptor1.Enabled {
+			continue
+		}
+		for j := 0; j < other.DescriptorArr.Stack.Size(); j++ {
+			descriptor2 := other.CodecListDescriptorGet(j)
+			if !descriptor2.Enabled {
+				continue
+			}
+			localDescriptor, remoteDescriptor := descriptor2, descriptor1
+			if ownPreference {
+				localDescriptor, remoteDescriptor = descriptor1, descriptor2
+			}
+			if descriptor := CodecDescriptorsNegotiate(localDescriptor, remoteDescriptor); descriptor != nil {
+				merged.DescriptorArr.Stack.Push(descriptor)
+				break
+			}
+		}
+	}
+
+	for i := 0; i < merged.DescriptorArr.Stack.Size(); i++ {
+		descriptor := merged.CodecListDescriptorGet(i)
+		if !EventDescriptorCheck(descriptor) && merged.PrimaryDescriptor == nil {
+			merged.PrimaryDescriptor = descriptor
+		}
+	}
+	if merged.PrimaryDescriptor == nil {
+		return merged, fmt.Errorf("no common codec")
+	}
+	for i := 0; i < merged.DescriptorArr.Stack.Size(); i++ {
+		descriptor := merged.CodecListDescriptorGet(i)
+		if !EventDescriptorCheck(descriptor) {
+			continue
+		}
+		if merged.EventDescriptor == nil {
+			merged.EventDescriptor = descriptor
+		}
+		if descriptor.SamplingRate == merged.PrimaryDescriptor.SamplingRate {
+			merged.EventDescriptor = descriptor
+			break
+		}
+	}
+	return merged, nil
+}
+
+/**
+ * Filter codec list by codec capabilities, keeping descriptors of the supported codecs and sampling rates
+ * (and named events, if allowed) in order.
+ * @param capabilities the codec capabilities
+ * @param codecList the codec list to filter
+ */
+func CodecCapabilitiesCodecListFilter(capabilities *CodecCapabilities, codecList *CodecList) *CodecList {
+	filtered := &CodecList{}
+	CodecListInit(filtered, codecList.DescriptorArr.Stack.Size())
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if !descriptor.Enabled {
+			continue
+		}
+		if EventDescriptorCheck(descriptor) {
+			if !capabilities.AllowNamedEvents {
+				continue
+			}
+		} else if capabilities.CodecCapabilitiesNativeFind(descriptor) == nil {
+			continue
+		}
+		filtered.DescriptorArr.Stack.Push(CodecDescriptorClone(descriptor))
+	}
+	return filtered
+}
+
+/**
+ * Intersect stream capabilities: common directions and codecs (by name) of the common sampling rates.
+ * @param capabilities1 the first capabilities
+ * @param capabilities2 the second capabilities
+ */
+func StreamCapabilitiesIntersect(capabilities1, capabilities2 *StreamCapabilities) *StreamCapabilities {
+	if capabilities1 == nil || capabilities2 == nil {
+		return nil
+	}
+	capabilities := StreamCapabilitiesCreate(capabilities1.direction & capabilities2.direction)
+	codecs1, codecs2 := &capabilities1.codecs, &capabilities2.codecs
+	capabilities.codecs.AllowNamedEvents = codecs1.AllowNamedEvents && codecs2.AllowNamedEvents
+	for i := 0; i < codecs1.AttribArr.Stack.Size(); i++ {
+		attribs1 := codecs1.AttribArr.ArrayHeaderIndex(i).(*CodecAttribs)
+		for j := 0; j < codecs2.AttribArr.Stack.Size(); j++ {
+			attribs2 := codecs2.AttribArr.ArrayHeaderIndex(j).(*CodecAttribs)
+			if !strings.EqualFold(attribs1.Name, attribs2.Name) {
+				continue
+			}
+			if sampleRates := attribs1.SampleRates & attribs2.SampleRates; sampleRates != MPF_SAMPLE_RATE_NONE {
+				capabilities.codecs.AttribArr.Stack.Push(&CodecAttribs{
+					Name:          attribs1.Name,
+					BitsPerSample: attribs1.BitsPerSample,
+					SampleRates:   sampleRates,
+				})
+			}
+		}
+	}
+	return capabilities
+}
+
+/**
+ * Negotiate codecs of RTP stream: the configured codecs supported by the stream capabilities
+ * are merged with the remote ones according to the preference of the settings.
+ * The negotiated list is set to the local media descriptor.
+ */
+func (d *RtpStreamDescriptor) RtpStreamDescriptorCodecsNegotiate() error {
+	if d.local == nil || d.remote == nil || d.settings == nil {
+		return fmt.Errorf("local, remote media or settings of rtp stream is nil")
+	}
+	localList := &d.settings.codecList
+	if d.capabilities != nil {
+		localList = CodecCapabilitiesCodecListFilter(&d.capabilities.codecs, localList)
+	}
+	merged, err := CodecListsMerge(localList, &d.remote.codecList, d.settings.ownPreference)
+	if err != nil {
+		return err
+	}
+	d.local.codecList = *merged
+	return nil
+}
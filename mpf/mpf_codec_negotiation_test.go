@@ -0,0 +1,107 @@
+package mpf
+
+import "testing"
+
+func codecListCreate(t *testing.T, codecs string) *CodecList {
+	codecList := &CodecList{}
+	CodecListInit(codecList, 4)
+	if err := CodecManagerDefaultGet().CodecManagerCodecListLoad(codecList, codecs); err != nil {
+		t.Fatal(err)
+	}
+	return codecList
+}
+
+func TestCodecListsMerge(t *testing.T) {
+	local := codecListCreate(t, "PCMA PCMU L16/96/16000 telephone-event/101/8000")
+	remote := codecListCreate(t, "L16/98/16000 PCMU telephone-event/100/16000 telephone-event/99/8000")
+	local.CodecListDescriptorGet(3).Format = "0-15"
+	remote.CodecListDescriptorGet(3).Format = "0-11,16"
+
+	/* own preference: local order, remote payload types */
+	merged, err := CodecListsMerge(local, remote, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := merged.DescriptorArr.Stack.Size(); size != 3 {
+		t.Fatalf("merged %d descriptors, want 3", size)
+	}
+	if merged.PrimaryDescriptor.PayloadType != RTP_PT_PCMU {
+		t.Fatalf("primary payload type %d, want PCMU", merged.PrimaryDescriptor.PayloadType)
+	}
+	if merged.CodecListDescriptorGet(1).PayloadType != 98 {
+		t.Fatalf("payload type %d of L16, want the remote 98", merged.CodecListDescriptorGet(1).PayloadType)
+	}
+	if merged.EventDescriptor == nil || merged.EventDescriptor.PayloadType != 99 || merged.EventDescriptor.Format != "0-11" {
+		t.Fatalf("unexpected event descriptor %+v", merged.EventDescriptor)
+	}
+
+	/* remote preference */
+	merged, err = CodecListsMerge(local, remote, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if merged.PrimaryDescriptor.Name != "L16" || merged.PrimaryDescriptor.SamplingRate != 16000 {
+		t.Fatalf("primary %s/%d, want L16/16000", merged.PrimaryDescriptor.Name, merged.PrimaryDescriptor.SamplingRate)
+	}
+	/* no event of 16 kHz at local side, the 8 kHz one is the only match */
+	if merged.EventDescriptor == nil || merged.EventDescriptor.PayloadType != 99 {
+		t.Fatalf("unexpected event descriptor %+v", merged.EventDescriptor)
+	}
+
+	/* deterministic */
+	again, _ := CodecListsMerge(local, remote, false)
+	if !CodecListsCompare(merged, again) {
+		t.Fatal("merge results differ")
+	}
+
+	if _, err := CodecListsMerge(codecListCreate(t, "PCMA"), codecListCreate(t, "PCMU"), true); err == nil {
+		t.Fatal("lists without common codec are merged")
+	}
+}
+
+func TestCodecFormatsNegotiate(t *testing.T) {
+	local := &CodecDescriptor{PayloadType: 97, Name: AMR_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1, Format: "octet-align=1;mode-set=0,2,7"}
+	remote := &CodecDescriptor{PayloadType: 96, Name: AMR_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1, Format: "octet-align=1;mode-set=2,5,7"}
+	descriptor := CodecDescriptorsNegotiate(local, remote)
+	if descriptor == nil || descriptor.PayloadType != 96 {
+		t.Fatalf("unexpected descriptor %+v", descriptor)
+	}
+	if attribs, _ := descriptor.CodecAMRAttribsGet(); attribs == nil || attribs.ModeSet != 0x84 {
+		t.Fatalf("mode set of format [%s], want 2,7", descriptor.Format)
+	}
+
+	remote.Format = "octet-align=1;mode-set=5"
+	if CodecDescriptorsNegotiate(local, remote) != nil {
+		t.Fatal("disjoint mode sets are negotiated")
+	}
+	remote.Format = "mode-set=2"
+	if CodecDescriptorsNegotiate(local, remote) != nil {
+		t.Fatal("bandwidth-efficient and octet-aligned formats are negotiated")
+	}
+}
+
+func TestStreamCapabilitiesIntersect(t *testing.T) {
+	capabilities1 := StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX)
+	capabilities1.StreamCapabilitiesCodecsGet().CodecCapabilitiesAdd(MPF_SAMPLE_RATE_8000|MPF_SAMPLE_RATE_16000, "L16")
+	capabilities1.StreamCapabilitiesCodecsGet().CodecCapabilitiesAdd(MPF_SAMPLE_RATE_8000, "PCMU")
+	capabilities2 := StreamCapabilitiesCreate(STREAM_DIRECTION_SEND)
+	capabilities2.StreamCapabilitiesCodecsGet().CodecCapabilitiesAdd(MPF_SAMPLE_RATE_16000, "l16")
+	capabilities2.StreamCapabilitiesCodecsGet().AllowNamedEvents = false
+
+	capabilities := StreamCapabilitiesIntersect(capabilities1, capabilities2)
+	if capabilities.StreamCapabilitiesDirectionGet() != STREAM_DIRECTION_SEND {
+		t.Fatalf("direction %d, want send", capabilities.StreamCapabilitiesDirectionGet())
+	}
+	codecs := capabilities.StreamCapabilitiesCodecsGet()
+	if codecs.AllowNamedEvents || codecs.AttribArr.Stack.Size() != 1 {
+		t.Fatalf("unexpected codecs %d, named events %v", codecs.AttribArr.Stack.Size(), codecs.AllowNamedEvents)
+	}
+	if attribs := codecs.AttribArr.ArrayHeaderIndex(0).(*CodecAttribs); attribs.SampleRates != MPF_SAMPLE_RATE_16000 {
+		t.Fatalf("sample rates %x, want 16000", attribs.SampleRates)
+	}
+
+	filtered := CodecCapabilitiesCodecListFilter(codecs, codecListCreate(t, "PCMU L16/96/16000 L16/97/8000 telephone-event/101/8000"))
+	if filtered.DescriptorArr.Stack.Size() != 1 || filtered.CodecListDescriptorGet(0).PayloadType != 96 {
+		t.Fatalf("filtered %d descriptors", filtered.DescriptorArr.Stack.Size())
+	}
+}
@@ -0,0 +1,131 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/**
+ * Codec implemented outside of the mpf package (e.g. proprietary EVS) registered by plugin.
+ * Each codec got from the codec manager has its own plugin instance, so the implementation
+ * may keep the state of encoder and decoder between frames.
+ */
+type CodecPlugin interface {
+	/** Open codec for the descriptor negotiated */
+	Open(descriptor *CodecDescriptor) error
+	/** Close codec */
+	Close() error
+	/** Encode 10 msec frame of linear samples (little-endian 16-bit), the output size may vary */
+	Encode(in []byte) ([]byte, error)
+	/** Decode encoded frame to linear samples (little-endian 16-bit) */
+	Decode(in []byte) ([]byte, error)
+	/** Get size of 10 msec encoded frame in bytes for the descriptor, 0 if the size is variable */
+	FrameSize(descriptor *CodecDescriptor) int64
+}
+
+/** Factory creating codec plugin instance */
+type CodecPluginFactory func() CodecPlugin
+
+/** [OPTIONAL] Interface of codec plugin concealing missing frames by itself */
+type CodecPluginConcealer interface {
+	/** Conceal missing frame, prev is the previous decoded (or concealed) linear frame */
+	Conceal(prev []byte) ([]byte, error)
+}
+
+/* Codec adapting plugin to the codec virtual methods */
+type codecPluginAdapter struct {
+	plugin CodecPlugin
+}
+
+func (a *codecPluginAdapter) open(codec *Codec) error {
+	return a.plugin.Open(codec.CodecDescriptorGet())
+}
+
+func (a *codecPluginAdapter) close(codec *Codec) error {
+	return a.plugin.Close()
+}
+
+func (a *codecPluginAdapter) encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	data, err := a.plugin.Encode(codecFrameDataGet(frameIn))
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, data)
+}
+
+func (a *codecPluginAdapter) decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	data, err := a.plugin.Decode(codecFrameDataGet(frameIn))
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, data)
+}
+
+/* Dissect frame of fixed size by the default dissector, variable one is the whole packet */
+func (a *codecPluginAdapter) dissect(codec *Codec, buffer *bytes.Buffer, frame *CodecFrame) error {
+	if descriptor := codec.CodecDescriptorGet(); descriptor != nil && a.plugin.FrameSize(descriptor) > 0 {
+		return codecDefaultDissect(buffer, frame)
+	}
+	return CodecPacketDissect(codec, buffer, frame)
+}
+
+func (a *codecPluginAdapter) frameSize(codec *Codec, descriptor *CodecDescriptor) int64 {
+	if size := a.plugin.FrameSize(descriptor); size > 0 {
+		return size
+	}
+	/* variable size, the upper bound is specified by the attributes */
+	return descriptor.CodecFrameSizeCalculate(codec.Attribs)
+}
+
+func (a *codecPluginAdapter) conceal(codec *Codec, prev, frameOut *CodecFrame) error {
+	concealer, ok := a.plugin.(CodecPluginConcealer)
+	if !ok {
+		return CodecLinearConceal(codec, prev, frameOut)
+	}
+	data, err := concealer.Conceal(codecFrameDataGet(prev))
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, data)
+}
+
+/**
+ * Create codec by plugin.
+ * @param plugin the plugin implementing the codec
+ * @param attribs the codec attributes (capabilities)
+ * @param descriptor the static descriptor (pt < 96), nil if the codec has dynamic payload type only
+ */
+func CodecPluginCreate(plugin CodecPlugin, attribs *CodecAttribs, descriptor *CodecDescriptor) *Codec {
+	a := &codecPluginAdapter{plugin: plugin}
+	vtable := &CodecVTable{
+		Open:       a.open,
+		Close:      a.close,
+		Encode:     a.encode,
+		Decode:     a.decode,
+		Dissect:    a.dissect,
+		Initialize: nil,
+		Conceal:    a.conceal,
+		FrameSize:  a.frameSize,
+	}
+	return CodecCreate(vtable, attribs, descriptor)
+}
+
+/**
+ * Register codec plugin in codec manager, so that the codec is negotiated and used
+ * by decoders, encoders and bridges like the built-in ones.
+ * @param attribs the codec attributes (capabilities), BitsPerSample specifies the upper bound of variable frame size
+ * @param descriptor the static descriptor (pt < 96), nil if the codec has dynamic payload type only
+ * @param factory the factory creating plugin instance per codec
+ */
+func (cm *CodecManager) CodecManagerCodecPluginRegister(attribs *CodecAttribs, descriptor *CodecDescriptor, factory CodecPluginFactory) error {
+	if factory == nil {
+		return fmt.Errorf("codec plugin factory is nil")
+	}
+	return cm.CodecManagerCodecFactoryRegister(attribs, func() *Codec {
+		plugin := factory()
+		if plugin == nil {
+			return nil
+		}
+		return CodecPluginCreate(plugin, attribs, descriptor)
+	})
+}
@@ -0,0 +1,116 @@
+package mpf
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Test codec of 8-bit samples, silent frames are encoded by a single byte (variable size) */
+type testPluginCodec struct {
+	opened  bool
+	encoded int
+	decoded int
+}
+
+func (c *testPluginCodec) Open(descriptor *CodecDescriptor) error {
+	if descriptor == nil || descriptor.SamplingRate != 8000 {
+		return fmt.Errorf("unexpected descriptor %+v", descriptor)
+	}
+	c.opened = true
+	return nil
+}
+
+func (c *testPluginCodec) Close() error {
+	c.opened = false
+	return nil
+}
+
+func (c *testPluginCodec) Encode(in []byte) ([]byte, error) {
+	samples, err := binaryx.ByteSliceToInt16Slice(in)
+	if err != nil {
+		return nil, err
+	}
+	c.encoded++
+	out := make([]byte, len(samples))
+	silent := true
+	for i, sample := range samples {
+		out[i] = byte(sample >> 8)
+		silent = silent && out[i] == 0
+	}
+	if silent {
+		return []byte{0}, nil
+	}
+	return out, nil
+}
+
+func (c *testPluginCodec) Decode(in []byte) ([]byte, error) {
+	c.decoded++
+	samples := make([]int16, 80)
+	if len(in) > 1 {
+		for i := range samples {
+			samples[i] = int16(int8(in[i])) << 8
+		}
+	}
+	return binaryx.Int16SliceToByteSlice(samples), nil
+}
+
+func (c *testPluginCodec) FrameSize(descriptor *CodecDescriptor) int64 {
+	return 0
+}
+
+func TestCodecPlugin(t *testing.T) {
+	attribs := &CodecAttribs{Name: "X8", BitsPerSample: 8, SampleRates: MPF_SAMPLE_RATE_8000}
+	manager := CodecManagerCreate(1)
+	var plugins []*testPluginCodec
+	if err := manager.CodecManagerCodecPluginRegister(attribs, nil, func() CodecPlugin {
+		plugin := &testPluginCodec{}
+		plugins = append(plugins, plugin)
+		return plugin
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	descriptor := &CodecDescriptor{PayloadType: 110, Name: "X8", SamplingRate: 8000, ChannelCount: 1}
+	encoder, err := manager.CodecManagerCodecGet(descriptor)
+	if err != nil || encoder == nil {
+		t.Fatalf("plugin codec is not got: %v", err)
+	}
+	decoder, _ := manager.CodecManagerCodecGet(descriptor)
+	if err := encoder.CodecOpen(); err != nil {
+		t.Fatal(err)
+	}
+	decoder.CodecOpen()
+	if size := encoder.CodecFrameSizeGet(descriptor); size != 80 {
+		t.Fatalf("frame size %d, want the upper bound of 80", size)
+	}
+
+	tone := binaryx.Int16SliceToByteSlice(loudnessTestSine(400, 8000, 10, 10000))
+	silence := make([]byte, 160)
+	for _, data := range [][]byte{tone, silence} {
+		in, encoded, decoded := &CodecFrame{}, &CodecFrame{}, &CodecFrame{}
+		codecFrameDataSet(in, data)
+		if err := encoder.CodecEncode(in, encoded); err != nil {
+			t.Fatal(err)
+		}
+		if err := decoder.CodecDecode(encoded, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Size != 160 {
+			t.Fatalf("%d bytes decoded from %d bytes", decoded.Size, encoded.Size)
+		}
+	}
+
+	/* codecs got from the manager keep their own state */
+	if len(plugins) != 3 || !plugins[1].opened || plugins[1].encoded != 2 || plugins[1].decoded != 0 || plugins[2].decoded != 2 {
+		t.Fatalf("plugin instances do not keep own state")
+	}
+
+	/* missing frame is concealed by the default PCM concealment */
+	prev, concealed := &CodecFrame{}, &CodecFrame{}
+	codecFrameDataSet(prev, tone)
+	if err := decoder.CodecConcealFrame(prev, concealed); err != nil || concealed.Size != 160 {
+		t.Fatalf("frame is not concealed: %v", err)
+	}
+}
@@ -0,0 +1,278 @@
+package mpf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Speex (RFC5574) */
+const SPEEX_CODEC_NAME = "speex"
+
+/** Speex modes (bands) */
+type SpeexMode = int
+
+const (
+	SPEEX_MODE_NB  SpeexMode = iota /**< narrowband, 8 kHz */
+	SPEEX_MODE_WB                   /**< wideband, 16 kHz */
+	SPEEX_MODE_UWB                  /**< ultra-wideband, 32 kHz */
+)
+
+/** Duration of Speex frame in msec (2 frames of the media processing time base) */
+const SPEEX_FRAME_TIME = 20
+
+/** Default Speex encoding quality */
+const SPEEX_QUALITY_DEFAULT = 8
+
+/** Narrowband sub-mode (RFC5574 "mode" fmtp param) by quality */
+var speexNbSubModes = [11]int{1, 8, 2, 3, 3, 4, 4, 5, 5, 6, 7}
+
+/** Speex attributes negotiated by SDP fmtp */
+type SpeexAttribs struct {
+	Mode    SpeexMode // Mode (band) derived from the sampling rate
+	Quality int       // Encoding quality (0-10)
+	Vbr     bool      // Variable bit-rate
+	Cng     bool      // Comfort noise generation
+}
+
+/** Get Speex mode by sampling rate */
+func SpeexModeGet(samplingRate uint16) (SpeexMode, error) {
+	switch samplingRate {
+	case 8000:
+		return SPEEX_MODE_NB, nil
+	case 16000:
+		return SPEEX_MODE_WB, nil
+	case 32000:
+		return SPEEX_MODE_UWB, nil
+	}
+	return SPEEX_MODE_NB, fmt.Errorf("unsupported speex sampling rate %d", samplingRate)
+}
+
+/* Parse on/off fmtp value */
+func speexFlagParse(value string) bool {
+	return strings.EqualFold(value, "on") || strings.EqualFold(value, "vad") || value == "1"
+}
+
+/**
+ * Get Speex attributes of the descriptor, parsed from the fmtp (format) params:
+ * mode=<narrowband sub-mode>|any, vbr=on|off|vad, cng=on|off and vendor specific quality=<0-10>.
+ */
+func (d *CodecDescriptor) CodecSpeexAttribsGet() (*SpeexAttribs, error) {
+	mode, err := SpeexModeGet(d.SamplingRate)
+	if err != nil {
+		return nil, err
+	}
+	attribs := &SpeexAttribs{Mode: mode, Quality: SPEEX_QUALITY_DEFAULT}
+	params := d.CodecFormatParamsGet()
+	if value, ok := params["quality"]; ok {
+		quality, err := strconv.Atoi(value)
+		if err != nil || quality < 0 || quality > 10 {
+			return nil, fmt.Errorf("invalid speex quality [%s]", value)
+		}
+		attribs.Quality = quality
+	} else if value, ok := params["mode"]; ok && !strings.EqualFold(value, "any") {
+		subMode, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid speex mode [%s]", value)
+		}
+		/* the highest quality of the narrowband sub-mode */
+		for quality := len(speexNbSubModes) - 1; quality >= 0; quality-- {
+			if speexNbSubModes[quality] == subMode {
+				attribs.Quality = quality
+				break
+			}
+		}
+	}
+	attribs.Vbr = speexFlagParse(params["vbr"])
+	attribs.Cng = speexFlagParse(params["cng"])
+	return attribs, nil
+}
+
+/** Generate fmtp (format) params of Speex attributes */
+func (a *SpeexAttribs) SpeexFormatGenerate() string {
+	params := []string{
+		"mode=" + strconv.Itoa(speexNbSubModes[a.Quality]),
+		"quality=" + strconv.Itoa(a.Quality),
+	}
+	if a.Vbr {
+		params = append(params, "vbr=on")
+	}
+	if a.Cng {
+		params = append(params, "cng=on")
+	}
+	return strings.Join(params, ";")
+}
+
+/**
+ * Create Speex descriptor.
+ * @param samplingRate the sampling rate (8000, 16000 or 32000)
+ * @param payloadType the dynamic payload type
+ * @param attribs the Speex attributes
+ */
+func CodecSpeexDescriptorCreate(samplingRate uint16, payloadType RtpPayloadType, attribs *SpeexAttribs) (*CodecDescriptor, error) {
+	if payloadType < RTP_PT_DYNAMIC || payloadType > RTP_PT_DYNAMIC_MAX {
+		return nil, fmt.Errorf("payload type %d of speex is not dynamic", payloadType)
+	}
+	mode, err := SpeexModeGet(samplingRate)
+	if err != nil {
+		return nil, err
+	}
+	if attribs.Quality < 0 || attribs.Quality > 10 {
+		return nil, fmt.Errorf("invalid speex quality %d", attribs.Quality)
+	}
+	attribs.Mode = mode
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = payloadType
+	descriptor.Name = SPEEX_CODEC_NAME
+	descriptor.SamplingRate = samplingRate
+	descriptor.ChannelCount = 1
+	descriptor.Format = attribs.SpeexFormatGenerate()
+	return descriptor, nil
+}
+
+/** Speex encoder of the DSP backend */
+type SpeexEncoder interface {
+	/** Encode 20 msec of linear samples to Speex packet */
+	Encode(samples []int16) ([]byte, error)
+}
+
+/** Speex decoder of the DSP backend */
+type SpeexDecoder interface {
+	/** Decode Speex packet to linear samples (nil packet - conceal the lost one) */
+	Decode(packet []byte) ([]int16, error)
+}
+
+/** Speex DSP backend (e.g. binding of libspeex) plugged into the codec */
+type SpeexBackend interface {
+	EncoderCreate(attribs *SpeexAttribs) (SpeexEncoder, error)
+	DecoderCreate(attribs *SpeexAttribs) (SpeexDecoder, error)
+}
+
+var speexAttribs = CodecAttribs{
+	Name:          SPEEX_CODEC_NAME,
+	BitsPerSample: 8, /* upper bound of 20 msec packet per 10 msec frame */
+	SampleRates:   MPF_SAMPLE_RATE_8000 | MPF_SAMPLE_RATE_16000 | MPF_SAMPLE_RATE_32000,
+}
+
+/** Speex codec instance repacketizing 10 msec media frames to 20 msec Speex frames */
+type speexCodec struct {
+	backend SpeexBackend
+	encoder SpeexEncoder
+	decoder SpeexDecoder
+	attribs *SpeexAttribs
+
+	encodeSamples []int16 // Samples waiting for the rest of the Speex frame
+	decodeSamples []int16 // Decoded samples of the next media frames
+}
+
+func (c *speexCodec) open(codec *Codec) error {
+	descriptor := codec.CodecDescriptorGet()
+	if descriptor == nil {
+		return fmt.Errorf("no speex descriptor")
+	}
+	attribs, err := descriptor.CodecSpeexAttribsGet()
+	if err != nil {
+		return err
+	}
+	if c.encoder, err = c.backend.EncoderCreate(attribs); err != nil {
+		return err
+	}
+	if c.decoder, err = c.backend.DecoderCreate(attribs); err != nil {
+		return err
+	}
+	c.attribs = attribs
+	c.encodeSamples = nil
+	c.decodeSamples = nil
+	return nil
+}
+
+func (c *speexCodec) close(codec *Codec) error {
+	c.encoder = nil
+	c.decoder = nil
+	return nil
+}
+
+/* Number of samples of media frame */
+func (c *speexCodec) frameSamples(codec *Codec) int {
+	return int(codec.CodecDescriptorGet().CodecFrameSamplesCalculate())
+}
+
+/** Encode linear frame, every other frame carries 20 msec Speex packet, the rest are empty */
+func (c *speexCodec) encode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.encoder == nil {
+		return fmt.Errorf("speex codec is not open")
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(frameIn))
+	if err != nil {
+		return err
+	}
+	c.encodeSamples = append(c.encodeSamples, samples...)
+	packetSamples := c.frameSamples(codec) * SPEEX_FRAME_TIME / int(codec.CodecDescriptorGet().CodecFrameDurationGet())
+	if len(c.encodeSamples) < packetSamples {
+		return codecFrameDataSet(frameOut, nil)
+	}
+	packet, err := c.encoder.Encode(c.encodeSamples[:packetSamples])
+	c.encodeSamples = append(c.encodeSamples[:0], c.encodeSamples[packetSamples:]...)
+	if err != nil {
+		return err
+	}
+	return codecFrameDataSet(frameOut, packet)
+}
+
+/** Decode Speex packet to linear frames, the rest of 20 msec is output on the next (empty) frame */
+func (c *speexCodec) decode(codec *Codec, frameIn, frameOut *CodecFrame) error {
+	if c.decoder == nil {
+		return fmt.Errorf("speex codec is not open")
+	}
+	if packet := codecFrameDataGet(frameIn); len(packet) > 0 || len(c.decodeSamples) == 0 {
+		if len(packet) == 0 {
+			/* lost packet */
+			packet = nil
+		}
+		samples, err := c.decoder.Decode(packet)
+		if err != nil {
+			return err
+		}
+		c.decodeSamples = append(c.decodeSamples, samples...)
+	}
+	frameSamples := c.frameSamples(codec)
+	out := make([]int16, frameSamples)
+	n := copy(out, c.decodeSamples)
+	c.decodeSamples = append(c.decodeSamples[:0], c.decodeSamples[n:]...)
+	return codecFrameDataSet(frameOut, binaryx.Int16SliceToByteSlice(out))
+}
+
+/** Initialize Speex frame as empty one (no packet, the decoder conceals it) */
+func SpeexInit(codec *Codec, frameOut *CodecFrame) error {
+	return codecFrameDataSet(frameOut, nil)
+}
+
+/** Create Speex codec using the DSP backend */
+func CodecSpeexCreate(backend SpeexBackend) *Codec {
+	c := &speexCodec{backend: backend}
+	vtable := &CodecVTable{
+		Open:       c.open,
+		Close:      c.close,
+		Encode:     c.encode,
+		Decode:     c.decode,
+		Dissect:    CodecPacketDissect,
+		Initialize: SpeexInit,
+	}
+	return CodecCreate(vtable, &speexAttribs, nil)
+}
+
+/**
+ * Register Speex codec in codec manager, using the DSP backend.
+ * @param codecManager the codec manager to register codec in
+ * @param backend the Speex DSP backend
+ */
+func CodecSpeexRegister(codecManager *CodecManager, backend SpeexBackend) error {
+	if backend == nil {
+		return fmt.Errorf("speex backend is nil")
+	}
+	return codecManager.CodecManagerCodecFactoryRegister(&speexAttribs, func() *Codec {
+		return CodecSpeexCreate(backend)
+	})
+}
@@ -0,0 +1,145 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Passthrough backend: packet is the little endian samples, the lost one is concealed by silence */
+type speexTestBackend struct {
+	attribs *SpeexAttribs
+}
+
+type speexTestCoder struct{}
+
+func (speexTestCoder) Encode(samples []int16) ([]byte, error) {
+	return binaryx.Int16SliceToByteSlice(samples), nil
+}
+
+func (speexTestCoder) Decode(packet []byte) ([]int16, error) {
+	if packet == nil {
+		return make([]int16, 320), nil
+	}
+	return binaryx.ByteSliceToInt16Slice(packet)
+}
+
+func (b *speexTestBackend) EncoderCreate(attribs *SpeexAttribs) (SpeexEncoder, error) {
+	b.attribs = attribs
+	return speexTestCoder{}, nil
+}
+
+func (b *speexTestBackend) DecoderCreate(attribs *SpeexAttribs) (SpeexDecoder, error) {
+	return speexTestCoder{}, nil
+}
+
+func TestCodecSpeexAttribs(t *testing.T) {
+	descriptor := &CodecDescriptor{Name: SPEEX_CODEC_NAME, SamplingRate: 16000, Format: "mode=6; vbr=on; cng=off"}
+	attribs, err := descriptor.CodecSpeexAttribsGet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attribs.Mode != SPEEX_MODE_WB || attribs.Quality != 9 || !attribs.Vbr || attribs.Cng {
+		t.Fatalf("unexpected attribs %+v", attribs)
+	}
+
+	descriptor.Format = `mode="any";quality=3`
+	if attribs, err = descriptor.CodecSpeexAttribsGet(); err != nil || attribs.Quality != 3 {
+		t.Fatalf("quality %v, err %v", attribs, err)
+	}
+
+	descriptor.Format = "quality=11"
+	if _, err = descriptor.CodecSpeexAttribsGet(); err == nil {
+		t.Fatal("invalid quality is accepted")
+	}
+	descriptor.SamplingRate = 48000
+	descriptor.Format = ""
+	if _, err = descriptor.CodecSpeexAttribsGet(); err == nil {
+		t.Fatal("unsupported sampling rate is accepted")
+	}
+
+	/* generated fmtp is parsed back */
+	if _, err = CodecSpeexDescriptorCreate(8000, RTP_PT_PCMU, &SpeexAttribs{}); err == nil {
+		t.Fatal("static payload type is accepted")
+	}
+	descriptor, err = CodecSpeexDescriptorCreate(8000, 97, &SpeexAttribs{Quality: 5, Cng: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attribs, err = descriptor.CodecSpeexAttribsGet(); err != nil || attribs.Mode != SPEEX_MODE_NB || attribs.Quality != 5 || !attribs.Cng {
+		t.Fatalf("attribs %+v of format [%s], err %v", attribs, descriptor.Format, err)
+	}
+}
+
+func TestCodecSpeex(t *testing.T) {
+	backend := &speexTestBackend{}
+	codecManager := CodecManagerDefaultCreate()
+	if err := CodecSpeexRegister(codecManager, backend); err != nil {
+		t.Fatal(err)
+	}
+	descriptor := &CodecDescriptor{PayloadType: 97, Name: SPEEX_CODEC_NAME, SamplingRate: 16000, ChannelCount: 1, Format: "quality=4"}
+	codec, err := codecManager.CodecManagerCodecGet(descriptor)
+	if err != nil || codec == nil {
+		t.Fatalf("speex codec is not found: %v", err)
+	}
+	if err := codec.CodecOpen(); err != nil {
+		t.Fatal(err)
+	}
+	if backend.attribs == nil || backend.attribs.Mode != SPEEX_MODE_WB || backend.attribs.Quality != 4 {
+		t.Fatalf("backend is created with attribs %+v", backend.attribs)
+	}
+
+	frameSamples := int(descriptor.CodecFrameSamplesCalculate())
+	encoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	decoded := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	var packets [][]byte
+	for i := 0; i < 4; i++ {
+		samples := make([]int16, frameSamples)
+		for j := range samples {
+			samples[j] = int16(i + 1)
+		}
+		in := CodecFrame{Buffer: bytes.NewBuffer(binaryx.Int16SliceToByteSlice(samples))}
+		in.Size = int64(in.Buffer.Len())
+		if err := codec.CodecEncode(&in, &encoded); err != nil {
+			t.Fatal(err)
+		}
+		/* every other frame carries 20 msec packet */
+		if i%2 == 0 && encoded.Size != 0 {
+			t.Fatalf("frame %d carries packet of %d bytes", i, encoded.Size)
+		}
+		if i%2 == 1 {
+			if encoded.Size != int64(frameSamples*4) {
+				t.Fatalf("packet size %d, want %d", encoded.Size, frameSamples*4)
+			}
+			packets = append(packets, append([]byte(nil), encoded.Buffer.Bytes()...))
+		}
+	}
+
+	/* the packet is decoded to two frames, the second one is output on the empty frame */
+	for i, packet := range packets {
+		for j := 0; j < 2; j++ {
+			in := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+			if j == 0 {
+				in.Buffer.Write(packet)
+				in.Size = int64(len(packet))
+			}
+			if err := codec.CodecDecode(&in, &decoded); err != nil {
+				t.Fatal(err)
+			}
+			samples, _ := binaryx.ByteSliceToInt16Slice(decoded.Buffer.Bytes())
+			if len(samples) != frameSamples || samples[0] != int16(i*2+j+1) {
+				t.Fatalf("packet %d frame %d: %d samples of %v", i, j, len(samples), samples[:1])
+			}
+		}
+	}
+
+	/* lost packet is concealed */
+	lost := CodecFrame{Buffer: bytes.NewBuffer(nil)}
+	if err := codec.CodecDecode(&lost, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Size != int64(frameSamples*2) {
+		t.Fatalf("concealed frame size %d, want %d", decoded.Size, frameSamples*2)
+	}
+}
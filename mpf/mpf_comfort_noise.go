@@ -0,0 +1,99 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+)
+
+/** Comfort noise (RFC 3389) codec name */
+const CN_CODEC_NAME = "CN"
+
+/** Min noise level in -dBov, the lowest level encoded in CN payload */
+const CN_LEVEL_MIN = 127
+
+/** Change of noise level in dB the CN update is sent on */
+const CN_LEVEL_HYSTERESIS = 3
+
+/** Check whether the descriptor is of comfort noise */
+func CnDescriptorCheck(descriptor *CodecDescriptor) bool {
+	if descriptor == nil {
+		return false
+	}
+	return strings.EqualFold(descriptor.Name, CN_CODEC_NAME)
+}
+
+/**
+ * Calculate noise level of linear samples in -dBov (0 - full scale .. 127 - the lowest level).
+ * @param samples the samples to calculate level of
+ */
+func CnLevelCalculate(samples []int16) uint8 {
+	var energy float64
+	for _, sample := range samples {
+		energy += float64(sample) * float64(sample)
+	}
+	if len(samples) == 0 || energy == 0 {
+		return CN_LEVEL_MIN
+	}
+	rms := math.Sqrt(energy / float64(len(samples)))
+	level := -20 * math.Log10(rms/32768)
+	if level < 0 {
+		return 0
+	}
+	if level > CN_LEVEL_MIN {
+		return CN_LEVEL_MIN
+	}
+	return uint8(math.Round(level))
+}
+
+/**
+ * Parse noise level of CN payload, the spectral information (if any) is ignored.
+ * @param data the payload of CN packet
+ */
+func CnPayloadParse(data []byte) (uint8, error) {
+	if len(data) == 0 {
+		return 0, fmt.Errorf("empty CN payload")
+	}
+	return data[0] & 0x7f, nil
+}
+
+/** Comfort noise generator of white noise at the level received by CN */
+type ComfortNoiseGenerator struct {
+	rand *rand.Rand
+	/** Noise level in -dBov */
+	level uint8
+}
+
+/**
+ * Create comfort noise generator.
+ * @param seed the seed of the noise
+ */
+func ComfortNoiseGeneratorCreate(seed int64) *ComfortNoiseGenerator {
+	return &ComfortNoiseGenerator{
+		rand:  rand.New(rand.NewSource(seed)),
+		level: CN_LEVEL_MIN,
+	}
+}
+
+/** Set noise level in -dBov */
+func (g *ComfortNoiseGenerator) ComfortNoiseLevelSet(level uint8) {
+	g.level = level & 0x7f
+}
+
+/** Get noise level in -dBov */
+func (g *ComfortNoiseGenerator) ComfortNoiseLevelGet() uint8 {
+	return g.level
+}
+
+/** Generate noise samples */
+func (g *ComfortNoiseGenerator) ComfortNoiseGenerate(samples []int16) {
+	/* uniform noise of amplitude a has rms of a/sqrt(3) */
+	amplitude := 32768 * math.Pow(10, -float64(g.level)/20) * math.Sqrt(3)
+	if amplitude > math.MaxInt16 {
+		amplitude = math.MaxInt16
+	}
+	for i := range samples {
+		samples[i] = int16((2*g.rand.Float64() - 1) * amplitude)
+	}
+}
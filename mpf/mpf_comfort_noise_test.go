@@ -0,0 +1,124 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestComfortNoiseLevel(t *testing.T) {
+	generator := ComfortNoiseGeneratorCreate(1)
+	for _, level := range []uint8{30, 50, 70} {
+		generator.ComfortNoiseLevelSet(level)
+		samples := make([]int16, 8000)
+		generator.ComfortNoiseGenerate(samples)
+		if measured := CnLevelCalculate(samples); measured < level-1 || measured > level+1 {
+			t.Errorf("noise of -%d dBov is measured as -%d dBov", level, measured)
+		}
+	}
+	if level := CnLevelCalculate(make([]int16, 80)); level != CN_LEVEL_MIN {
+		t.Errorf("level of digital silence is -%d dBov", level)
+	}
+	if _, err := CnPayloadParse(nil); err == nil {
+		t.Errorf("empty CN payload is parsed")
+	}
+}
+
+func TestEncoderComfortNoise(t *testing.T) {
+	noise := ComfortNoiseGeneratorCreate(1)
+	noise.ComfortNoiseLevelSet(60)
+	levels := []uint8{60, 60, 40, 0, 60}
+	var written []Frame
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			out := *frame
+			out.CodecFrame.Buffer = bytes.NewBuffer(append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...))
+			written = append(written, out)
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}
+
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(sink.TXDescriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoder := EncoderCreate(sink, codec)
+	if err := EncoderComfortNoiseSet(encoder, 400); err != nil {
+		t.Fatal(err)
+	}
+	for _, level := range levels {
+		samples := make([]int16, 80)
+		if level == 0 {
+			samples = loudnessTestSine(400, 8000, 10, 10000)
+		} else {
+			noise.ComfortNoiseLevelSet(level)
+			noise.ComfortNoiseGenerate(samples)
+		}
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		if err := encoder.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* CN at the start of silence, nothing while the level is the same, CN update, speech, CN again */
+	expected := []struct {
+		frameType   FrameType
+		payloadType RtpPayloadType
+		size        int
+	}{
+		{MEDIA_FRAME_TYPE_AUDIO, RTP_PT_CN, 1},
+		{MEDIA_FRAME_TYPE_NONE, RTP_PT_CN, 1},
+		{MEDIA_FRAME_TYPE_AUDIO, RTP_PT_CN, 1},
+		{MEDIA_FRAME_TYPE_AUDIO, RTP_PT_PCMU, 80},
+		{MEDIA_FRAME_TYPE_AUDIO, RTP_PT_CN, 1},
+	}
+	if len(written) != len(expected) {
+		t.Fatalf("%d frames written, want %d", len(written), len(expected))
+	}
+	for i, frame := range written {
+		if frame.Type != expected[i].frameType || frame.Type == MEDIA_FRAME_TYPE_AUDIO && (frame.PayloadType != expected[i].payloadType || frame.CodecFrame.Buffer.Len() != expected[i].size) {
+			t.Fatalf("frame %d: type %d, payload type %d, %d bytes", i, frame.Type, frame.PayloadType, frame.CodecFrame.Buffer.Len())
+		}
+	}
+	if level, _ := CnPayloadParse(written[2].CodecFrame.Buffer.Bytes()); level < 39 || level > 41 {
+		t.Fatalf("CN level -%d dBov, want -40", level)
+	}
+}
+
+func TestDecoderComfortNoise(t *testing.T) {
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(&CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reads := 0
+	source := AudioStreamCreate(nil, &AudioStreamVTable{ReadFrame: func(stream *AudioStream, frame *Frame) error {
+		reads++
+		if reads > 1 {
+			/* no packets are sent during silence */
+			return nil
+		}
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		frame.PayloadType = RTP_PT_CN
+		return codecFrameDataSet(&frame.CodecFrame, []byte{50})
+	}}, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	source.RXDescriptor = &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}
+	decoder := DecoderCreate(source, codec)
+
+	frame := &Frame{CodecFrame: CodecFrame{Buffer: new(bytes.Buffer), Size: 160}}
+	for i := 0; i < 10; i++ {
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		if err := decoder.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			t.Fatalf("no comfort noise in frame %d", i)
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if level := CnLevelCalculate(samples); len(samples) != 80 || level < 48 || level > 52 {
+			t.Fatalf("frame %d: %d samples of -%d dBov", i, len(samples), level)
+		}
+	}
+}
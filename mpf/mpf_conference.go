@@ -0,0 +1,384 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Level in -dBov (smoothed) the member speaks above, to be the active speaker */
+const CONFERENCE_SPEECH_LEVEL = 50.0
+
+/** Margin in dB the member speaks louder than the active speaker by, to take over */
+const CONFERENCE_SPEAKER_MARGIN = 6.0
+
+/** Smoothing of the level of member per frame (the weight of the level of the frame) */
+const CONFERENCE_LEVEL_SMOOTHING = 0.2
+
+/** Member (participant) of conference */
+type ConferenceMember struct {
+	/** Informative name of the member */
+	Name string
+	/** Audio stream of the member */
+	stream *AudioStream
+	/** Stream the audio of the member is read from (linear audio of the conference), nil if none */
+	source *AudioStream
+	/** Stream the mix is written to (linear audio of the sink), nil if none */
+	sink *AudioStream
+	/** Stages converting the mix to the audio of the sink, nil if no conversion needed */
+	converter *AudioStream
+
+	/** The member is not heard, yet hears the others */
+	muted bool
+	/** The member neither is heard nor hears the others */
+	held bool
+	/** Level in -dBov of the audio heard (smoothed) */
+	level float64
+
+	/** Frame read from source and its samples mixed, nil if none */
+	frame   Frame
+	samples []int16
+	/** Frames of the mix-minus of the member, converted to the audio of the sink */
+	mixFrame, sinkFrame Frame
+	limiter             mixLimiter
+}
+
+/** N-way conference mixing the members, each member hears the mix of the others (mix-minus) */
+type Conference struct {
+	/** MPF conference base */
+	base *Object
+	/** Descriptor of the (linear) audio mixed */
+	descriptor *CodecDescriptor
+	/** Codec manager to decode/encode the audio of members */
+	codecManager *CodecManager
+
+	/** Guards members, added and removed while processed */
+	mutex   sync.Mutex
+	members []*ConferenceMember
+	/** Mix of the members heard */
+	mix []float64
+	/** Mix-minus of a member */
+	mixMinus []float64
+	/** Member speaking the loudest (active speaker), nil if none */
+	speaker *ConferenceMember
+}
+
+/**
+ * Create conference, processed as MPF object (@see ContextConferenceAdd()).
+ * @param descriptor the descriptor of the linear audio mixed, the audio of members is converted as needed
+ * @param codecManager the codec manager
+ * @param name the informative name used for debugging
+ */
+func ConferenceCreate(descriptor *CodecDescriptor, codecManager *CodecManager, name string) (*Conference, error) {
+	if descriptor == nil || !CodecLPcmDescriptorMatch(descriptor) {
+		return nil, fmt.Errorf("conference of linear audio only")
+	}
+	conference := &Conference{
+		base:         ObjectInit(name),
+		descriptor:   CodecDescriptorClone(descriptor),
+		codecManager: codecManager,
+	}
+	conference.base.Destroy = func(object *Object) error {
+		return conference.ConferenceDestroy()
+	}
+	conference.base.Process = func(object *Object) error {
+		return conference.ConferenceProcess()
+	}
+	return conference, nil
+}
+
+/** Get MPF object of conference */
+func (conference *Conference) ConferenceObjectGet() *Object {
+	return conference.base
+}
+
+/**
+ * Add member to conference at runtime, the audio of the stream is mixed (if the stream receives)
+ * and the mix of the others is written to the stream (if the stream sends).
+ * @param name the informative name of the member
+ * @param stream the audio stream of the member
+ */
+func (conference *Conference) ConferenceMemberAdd(name string, stream *AudioStream) (*ConferenceMember, error) {
+	if stream == nil || stream.Capabilities == nil {
+		return nil, fmt.Errorf("stream is nil")
+	}
+	member := &ConferenceMember{Name: name, stream: stream, level: RTP_AUDIO_LEVEL_SILENCE, limiter: mixLimiter{gain: 1}}
+	direction := stream.Capabilities.StreamCapabilitiesDirectionGet()
+	var err error
+	if (direction&STREAM_DIRECTION_RECEIVE) == STREAM_DIRECTION_RECEIVE && stream.RXDescriptor != nil {
+		if member.source, err = linearSourceCreate(stream, conference.descriptor, conference.codecManager); err != nil {
+			return nil, err
+		}
+		member.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	}
+	if (direction&STREAM_DIRECTION_SEND) == STREAM_DIRECTION_SEND && stream.TXDescriptor != nil {
+		if member.sink, err = linearSinkCreate(stream, conference.codecManager); err != nil {
+			return nil, err
+		}
+		member.mixFrame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+		member.sinkFrame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+		if !CodecDescriptorsMatch(member.sink.TXDescriptor, conference.descriptor) {
+			/* stages read the mix-minus of the member */
+			feed := AudioStreamCreate(member, &AudioStreamVTable{
+				ReadFrame: func(_ *AudioStream, frame *Frame) error {
+					return FrameCopy(frame, &member.mixFrame)
+				},
+			}, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+			feed.RXDescriptor = conference.descriptor
+			if member.converter, err = linearSourceCreate(feed, member.sink.TXDescriptor, nil); err != nil {
+				return nil, err
+			}
+			member.sinkFrame.CodecFrame.Size = member.sink.TXDescriptor.CodecLinearFrameSizeGet()
+		}
+	}
+	if member.source == nil && member.sink == nil {
+		return nil, fmt.Errorf("stream neither receives nor sends")
+	}
+
+	if member.source != nil {
+		if err = member.source.AudioStreamRXOpen(nil); err != nil {
+			return nil, err
+		}
+	}
+	if member.sink != nil {
+		if err = member.sink.AudioStreamTXOpen(nil); err != nil {
+			if member.source != nil {
+				_ = member.source.AudioStreamRXClose()
+			}
+			return nil, err
+		}
+	}
+
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	conference.members = append(conference.members, member)
+	return member, nil
+}
+
+/* Close streams of member */
+func (member *ConferenceMember) close() error {
+	var err error
+	if member.source != nil {
+		err = member.source.AudioStreamRXClose()
+	}
+	if member.sink != nil {
+		if e := member.sink.AudioStreamTXClose(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
+
+/**
+ * Remove member from conference at runtime.
+ * @param member the member to remove
+ */
+func (conference *Conference) ConferenceMemberRemove(member *ConferenceMember) error {
+	conference.mutex.Lock()
+	for i, m := range conference.members {
+		if m == member {
+			conference.members = append(conference.members[:i], conference.members[i+1:]...)
+			if conference.speaker == member {
+				conference.speaker = nil
+			}
+			conference.mutex.Unlock()
+			return member.close()
+		}
+	}
+	conference.mutex.Unlock()
+	return fmt.Errorf("no member %s", member.Name)
+}
+
+/** Get number of members */
+func (conference *Conference) ConferenceMemberCount() int {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	return len(conference.members)
+}
+
+/**
+ * Mute (or unmute) member: the member is not heard, yet hears the others.
+ * @param muted whether to mute
+ */
+func (conference *Conference) ConferenceMemberMuteSet(member *ConferenceMember, muted bool) {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	member.muted = muted
+}
+
+/**
+ * Hold (or resume) member: the member neither is heard nor hears the others (no audio is written to it).
+ * @param held whether to hold
+ */
+func (conference *Conference) ConferenceMemberHoldSet(member *ConferenceMember, held bool) {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	member.held = held
+}
+
+/** Get whether member is muted and held */
+func (conference *Conference) ConferenceMemberFlagsGet(member *ConferenceMember) (muted, held bool) {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	return member.muted, member.held
+}
+
+/**
+ * Get member speaking the loudest (active speaker), e.g. to be shown or recorded.
+ * The level signalled along with the audio of the member (RFC 6464) is used, the one of the audio otherwise.
+ * @return the member, nil if no one speaks
+ */
+func (conference *Conference) ConferenceActiveSpeakerGet() *ConferenceMember {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	return conference.speaker
+}
+
+/** Get level in -dBov of the audio of member heard (smoothed), RTP_AUDIO_LEVEL_SILENCE if not heard */
+func (conference *Conference) ConferenceMemberLevelGet(member *ConferenceMember) byte {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+	return byte(math.Round(member.level))
+}
+
+/** Process conference: read frames from members, mix them and write mix-minus to members */
+func (conference *Conference) ConferenceProcess() error {
+	conference.mutex.Lock()
+	defer conference.mutex.Unlock()
+
+	size := int(conference.descriptor.CodecLinearFrameSizeGet() / BYTES_PER_SAMPLE)
+	if len(conference.mix) != size {
+		conference.mix = make([]float64, size)
+		conference.mixMinus = make([]float64, size)
+	}
+	for i := range conference.mix {
+		conference.mix[i] = 0
+	}
+
+	for _, member := range conference.members {
+		member.samples = nil
+		if member.source == nil {
+			continue
+		}
+		/* the source is read even if not heard, so that its audio does not pile up */
+		frame := &member.frame
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		frame.Marker = MPF_MARKER_NONE
+		frame.CodecFrame.Size = int64(size * BYTES_PER_SAMPLE)
+		if err := member.source.AudioStreamFrameRead(frame); err != nil {
+			return err
+		}
+		if member.muted || member.held || (frame.Type&MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+			member.levelUpdate(RTP_AUDIO_LEVEL_SILENCE)
+			continue
+		}
+		samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if err != nil {
+			return err
+		}
+		if len(samples) > size {
+			samples = samples[:size]
+		}
+		member.samples = samples
+		for n, sample := range samples {
+			conference.mix[n] += float64(sample)
+		}
+		if frame.AudioLevel.Present {
+			member.levelUpdate(frame.AudioLevel.Level)
+		} else {
+			member.levelUpdate(RtpAudioLevelCalculate(samples))
+		}
+	}
+	conference.speakerUpdate()
+
+	for _, member := range conference.members {
+		if member.sink == nil {
+			continue
+		}
+		if err := conference.mixMinusWrite(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Update smoothed level of member by the level of the frame read */
+func (member *ConferenceMember) levelUpdate(level byte) {
+	member.level += (float64(level) - member.level) * CONFERENCE_LEVEL_SMOOTHING
+}
+
+/*
+ * Update active speaker: the member heard speaking the loudest takes over, if no one speaks
+ * or it is louder than the active speaker by the margin (the speaker does not flap among similar levels).
+ */
+func (conference *Conference) speakerUpdate() {
+	var loudest *ConferenceMember
+	for _, member := range conference.members {
+		if member.source == nil || member.muted || member.held {
+			continue
+		}
+		if loudest == nil || member.level < loudest.level {
+			loudest = member
+		}
+	}
+	speaker := conference.speaker
+	if speaker != nil && (speaker.muted || speaker.held || speaker.level >= CONFERENCE_SPEECH_LEVEL) {
+		speaker = nil
+	}
+	if loudest != nil && loudest.level < CONFERENCE_SPEECH_LEVEL &&
+		(speaker == nil || loudest.level+CONFERENCE_SPEAKER_MARGIN < speaker.level) {
+		speaker = loudest
+	}
+	conference.speaker = speaker
+}
+
+/* Write the mix of the other members to the member */
+func (conference *Conference) mixMinusWrite(member *ConferenceMember) error {
+	frame := &member.mixFrame
+	frame.Type = MEDIA_FRAME_TYPE_NONE
+	frame.Marker = MPF_MARKER_NONE
+	frame.CodecFrame.Buffer.Reset()
+	if !member.held {
+		copy(conference.mixMinus, conference.mix)
+		for n, sample := range member.samples {
+			conference.mixMinus[n] -= float64(sample)
+		}
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		samples := member.limiter.process(conference.mixMinus, conference.descriptor)
+		if err := codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples)); err != nil {
+			return err
+		}
+	}
+	if member.converter == nil {
+		return member.sink.AudioStreamFrameWrite(frame)
+	}
+	out := &member.sinkFrame
+	out.Type = MEDIA_FRAME_TYPE_NONE
+	out.Marker = MPF_MARKER_NONE
+	if err := member.converter.AudioStreamFrameRead(out); err != nil {
+		return err
+	}
+	if (out.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+		out.CodecFrame.Buffer.Reset()
+	}
+	return member.sink.AudioStreamFrameWrite(out)
+}
+
+/** Destroy conference: remove all members */
+func (conference *Conference) ConferenceDestroy() error {
+	conference.mutex.Lock()
+	members := conference.members
+	conference.members = nil
+	conference.speaker = nil
+	conference.mutex.Unlock()
+	var err error
+	for _, member := range members {
+		if e := member.close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
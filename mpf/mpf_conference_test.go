@@ -0,0 +1,144 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Create duplex stream talking constant samples and keeping the samples heard */
+func conferenceTestStream(rate uint16, value int16, heard *[]int16) *AudioStream {
+	stream := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			samples := make([]int16, frame.CodecFrame.Size/BYTES_PER_SAMPLE)
+			for i := range samples {
+				samples[i] = value
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			var err error
+			*heard, err = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			return err
+		},
+	}, StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX))
+	stream.RXDescriptor = CodecLPcmDescriptorCreate(rate, 1)
+	stream.TXDescriptor = stream.RXDescriptor
+	return stream
+}
+
+func TestConference(t *testing.T) {
+	conference, err := ConferenceCreate(CodecLPcmDescriptorCreate(8000, 1), CodecManagerDefaultCreate(), "conference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	context := ContextFactoryCreate().ContextCreate("conference", nil, 1)
+	if err := context.ContextConferenceAdd(conference); err != nil {
+		t.Fatal(err)
+	}
+	var heardA, heardB, heardC, heardD []int16
+	a, _ := conference.ConferenceMemberAdd("a", conferenceTestStream(8000, 100, &heardA))
+	b, _ := conference.ConferenceMemberAdd("b", conferenceTestStream(8000, 200, &heardB))
+	c, err := conference.ConferenceMemberAdd("c", conferenceTestStream(16000, 400, &heardC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		for i := 0; i < 10; i++ {
+			if err := context.ContextProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	/* each member hears the others only (mix-minus) */
+	process()
+	if heardA[0] != 600 || heardB[0] != 500 || len(heardC) != 160 || heardC[100] != 300 {
+		t.Fatalf("heard %v, %v and %d samples of %v", heardA[:1], heardB[:1], len(heardC), heardC[100])
+	}
+
+	/* muted member hears, held one neither hears nor is heard */
+	conference.ConferenceMemberMuteSet(b, true)
+	conference.ConferenceMemberHoldSet(c, true)
+	process()
+	if heardA[0] != 0 || heardB[0] != 100 || len(heardC) != 0 {
+		t.Fatalf("heard %v, %v and %d samples", heardA[:1], heardB[:1], len(heardC))
+	}
+	if muted, held := conference.ConferenceMemberFlagsGet(c); muted || !held {
+		t.Fatalf("flags of held member %v %v", muted, held)
+	}
+
+	/* members come and go without the topology re-applied */
+	conference.ConferenceMemberMuteSet(b, false)
+	if err := conference.ConferenceMemberRemove(a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conference.ConferenceMemberAdd("d", conferenceTestStream(8000, 1000, &heardD)); err != nil {
+		t.Fatal(err)
+	}
+	process()
+	if conference.ConferenceMemberCount() != 3 || heardB[0] != 1000 || heardD[0] != 200 {
+		t.Fatalf("%d members heard %v and %v", conference.ConferenceMemberCount(), heardB[:1], heardD[:1])
+	}
+	if err := conference.ConferenceMemberRemove(a); err == nil {
+		t.Fatalf("member is removed twice")
+	}
+	if err := context.ContextConferenceRemove(conference); err != nil || conference.ConferenceMemberCount() != 0 {
+		t.Fatalf("conference is not removed: %v", err)
+	}
+}
+
+func TestConferenceActiveSpeaker(t *testing.T) {
+	conference, err := ConferenceCreate(CodecLPcmDescriptorCreate(8000, 1), CodecManagerDefaultCreate(), "conference")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var heardA, heardB, heardC []int16
+	/* b talks quietly, yet the level signalled along with its audio is the loudest */
+	a, _ := conference.ConferenceMemberAdd("a", conferenceTestStream(8000, 1000, &heardA))
+	streamB := conferenceTestStream(8000, 10, &heardB)
+	readFrame := streamB.VTable.ReadFrame
+	streamB.VTable.ReadFrame = func(stream *AudioStream, frame *Frame) error {
+		frame.AudioLevel = FrameAudioLevel{Present: true, Level: 10, Voice: true}
+		return readFrame(stream, frame)
+	}
+	b, _ := conference.ConferenceMemberAdd("b", streamB)
+	c, err := conference.ConferenceMemberAdd("c", conferenceTestStream(8000, 1, &heardC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		for i := 0; i < 50; i++ {
+			if err := conference.ConferenceProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	process()
+	if speaker := conference.ConferenceActiveSpeakerGet(); speaker != b {
+		t.Fatalf("active speaker %v", speaker)
+	}
+	if levelA, levelB, levelC := conference.ConferenceMemberLevelGet(a), conference.ConferenceMemberLevelGet(b),
+		conference.ConferenceMemberLevelGet(c); levelA != 30 || levelB != 10 || levelC < CONFERENCE_SPEECH_LEVEL {
+		t.Fatalf("levels %d, %d, %d", levelA, levelB, levelC)
+	}
+
+	/* muted member is not the speaker, the one silent neither */
+	conference.ConferenceMemberMuteSet(b, true)
+	process()
+	if speaker := conference.ConferenceActiveSpeakerGet(); speaker != a || conference.ConferenceMemberLevelGet(b) != RTP_AUDIO_LEVEL_SILENCE {
+		t.Fatalf("active speaker %v, level of muted member %d", speaker, conference.ConferenceMemberLevelGet(b))
+	}
+	if err := conference.ConferenceMemberRemove(a); err != nil {
+		t.Fatal(err)
+	}
+	process()
+	if speaker := conference.ConferenceActiveSpeakerGet(); speaker != nil {
+		t.Fatalf("active speaker %v", speaker.Name)
+	}
+	if err := conference.ConferenceDestroy(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -3,19 +3,110 @@ package mpf
 import (
 	"container/list"
 	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/navi-tt/go-mrcp/apr"
 )
 
+/** Number of consecutive scheduler overruns to start shedding non-live frames after */
+const MPF_OVERLOAD_OVERRUN_THRESHOLD = 3
+
+/** Number of consecutive in-time ticks to stop shedding after */
+const MPF_OVERLOAD_RECOVERY_THRESHOLD = 50
+
+/** Overload state of context factory */
+type ContextOverload struct {
+	/** Processing time budget of a tick (scheduler resolution) */
+	Resolution time.Duration
+	/** Number of consecutive overruns to start shedding after */
+	OverrunThreshold int
+	/** Number of consecutive in-time ticks to stop shedding after */
+	RecoveryThreshold int
+
+	/** Current number of consecutive overruns */
+	overruns int
+	/** Current number of consecutive in-time ticks */
+	inTime int
+	/** Are non-live frames being shed */
+	shedding bool
+	/** Total number of frames shed */
+	ShedCount uint64
+}
+
+/** Report of media processing over a number of ticks */
+type ContextFactoryReport struct {
+	/** Number of ticks reported */
+	Ticks int
+	/** Number of contexts processed (summed over the ticks) */
+	Contexts int
+	/** Number of frames moved by media processing objects */
+	Frames uint64
+	/** Number of frames dropped (shed under overload or failed to be processed) */
+	Dropped uint64
+	/** Total processing duration of the ticks */
+	Duration time.Duration
+	/** Max processing duration of a tick */
+	MaxDuration time.Duration
+	/** Number of ticks overran the scheduler resolution */
+	Overruns int
+}
+
+/** Prototype of the callback of media processing report */
+type ContextFactoryReportProc func(factory *ContextFactory, report *ContextFactoryReport)
+
+/**
+ * Prototype of the callback of topology applied, e.g. to audit media topology changes of session.
+ * @param description the objects of the topology applied, or the failure to apply it
+ * @param err the error the topology failed to be applied with, nil if applied
+ */
+type ContextTopologyProc func(context *Context, description string, err error)
+
 /** Factory of media contexts */
 type ContextFactory struct {
 	Link *list.List
 	/** Ring head */
 	Head *list.Element // List of header fields (name-value pairs), Ring 的 Value 就是 *AptHeaderField head;
+	/** Overload state */
+	Overload ContextOverload
+	/** Frame duration in msec applied to the audio of terminations added (CODEC_FRAME_TIME_BASE if 0) */
+	FrameDuration int64
+
+	/** Guard of the list modified by sessions while being processed */
+	mutex sync.Mutex
+	/** Is the list being processed, modifications are deferred till the end of processing */
+	processing bool
+	/** Modifications of the list deferred */
+	pending []contextFactoryOp
+
+	/** Report callback (nil - disabled) */
+	reportProc ContextFactoryReportProc
+	/** Number of ticks to invoke report callback every */
+	reportInterval int
+	/** Report being collected */
+	report ContextFactoryReport
+
+	/** Taps of contexts by name */
+	taps map[string]FrameTapProc
+}
+
+/* Deferred modification of the list of context factory */
+type contextFactoryOp struct {
+	context *Context
+	add     bool
 }
 
 /** Item of the association matrix */
 type MatrixItem struct {
 	On uint8
+	/** Association is requested, the audio flows (On) as long as the directions of the streams are compatible */
+	associated bool
+	/** Gain of the audio of the association in dB (0 - unchanged) */
+	Gain float64
+	/** Gain stage applied along with the topology, nil if none */
+	gain *Gain
 }
 
 /** Item of the association matrix header */
@@ -28,8 +119,10 @@ type HeaderItem struct {
 /** Media processing context */
 type Context struct {
 
-	/** Ring entry */
+	/** Ring entry, nil if the context is not processed (has no terminations) */
 	Element *list.Element
+	/** Is the context processed, cleared as soon as its removal is requested */
+	linked bool
 	/** Back pointer to the context factory */
 	Factory *ContextFactory
 
@@ -50,6 +143,31 @@ type Context struct {
 	/** Array of media processing objects constructed while
 	  applying topology based on association matrix */
 	mpfObjects *apr.ArrayHeader
+
+	/** Number of frames shed under overload */
+	ShedCount uint64
+
+	/** Echo cancellers applied along with the topology */
+	echoCancellers []contextEchoCanceller
+	/** Source streams substituted for the ones of terminations (by slot) while the topology is applied */
+	sources map[int64]*AudioStream
+	/** Tap of the audio written to terminations (nil - tap of factory by name, if any) */
+	tap FrameTapProc
+	/** Sink streams substituted for the ones of terminations (by slot) while the topology is applied */
+	sinks map[int64]*AudioStream
+	/** Conferences processed along with the topology, kept as the topology is re-applied */
+	conferences []*Conference
+	/** Topology is applied (re-applied as soon as the directions of terminations change) */
+	applied bool
+	/** Callback of topology applied (nil - disabled) */
+	topologyProc ContextTopologyProc
+}
+
+/* Echo canceller applied between terminations of the context */
+type contextEchoCanceller struct {
+	reference *Termination
+	capture   *Termination
+	aec       *EchoCanceller
 }
 
 /**
@@ -59,6 +177,11 @@ func ContextFactoryCreate() *ContextFactory {
 	return &ContextFactory{
 		Link: list.New(),
 		Head: nil,
+		Overload: ContextOverload{
+			Resolution:        CODEC_FRAME_TIME_BASE * time.Millisecond,
+			OverrunThreshold:  MPF_OVERLOAD_OVERRUN_THRESHOLD,
+			RecoveryThreshold: MPF_OVERLOAD_RECOVERY_THRESHOLD,
+		},
 	}
 }
 
@@ -66,33 +189,202 @@ func ContextFactoryCreate() *ContextFactory {
  * Destroy factory of media contexts.
  */
 func ContextFactoryDestroy(factory *ContextFactory) error {
+	factory.mutex.Lock()
+	contexts := make([]*Context, 0, factory.Link.Len())
 	for factory.Link.Len() > 0 {
 		head := factory.Link.Front()
-		ctx := head.Value.(*Context)
+		ctx := factory.Link.Remove(head).(*Context)
+		ctx.Element = nil
+		ctx.linked = false
+		contexts = append(contexts, ctx)
+	}
+	factory.pending = nil
+	factory.mutex.Unlock()
+
+	for _, ctx := range contexts {
 		_ = ContextDestroy(ctx)
-		factory.Link.Remove(head)
 	}
 	return nil
 }
 
 /**
  * Process factory of media contexts.
+ * The contexts are processed by the snapshot of the list, contexts added or removed meanwhile
+ * take effect at the end of processing, contexts removed are not processed anymore.
  */
 func ContextFactoryProcess(factory *ContextFactory) error {
-	head := factory.Link.Front()
-	if head == nil {
+	factory.mutex.Lock()
+	if factory.Link.Len() == 0 {
+		factory.mutex.Unlock()
 		return nil
 	}
-	ctx := head.Value.(*Context)
-	for ctx != nil {
-		_ = ctx.ContextProcess()
-		head = head.Next()
-		if head != nil {
-			ctx = head.Value.(*Context)
-		} else {
-			ctx = nil
+	contexts := make([]*Context, 0, factory.Link.Len())
+	for e := factory.Link.Front(); e != nil; e = e.Next() {
+		contexts = append(contexts, e.Value.(*Context))
+	}
+	factory.processing = true
+	factory.mutex.Unlock()
+
+	var (
+		start           = time.Now()
+		processed       int
+		frames, dropped uint64
+	)
+	for _, ctx := range contexts {
+		if !factory.contextLinkedCheck(ctx) {
+			continue
 		}
+		ctxFrames, ctxDropped, _ := ctx.contextProcess()
+		frames += ctxFrames
+		dropped += ctxDropped
+		processed++
+	}
+	duration := time.Since(start)
+	overrun := duration > factory.Overload.Resolution
+	factory.ContextFactoryOverrunReport(overrun)
+	factory.reportUpdate(processed, frames, dropped, duration, overrun)
+
+	factory.mutex.Lock()
+	factory.processing = false
+	for _, op := range factory.pending {
+		factory.contextListUpdate(op.context, op.add)
 	}
+	factory.pending = nil
+	factory.mutex.Unlock()
+	return nil
+}
+
+/* Account the tick in the report, invoking the callback every report interval */
+func (factory *ContextFactory) reportUpdate(contexts int, frames, dropped uint64, duration time.Duration, overrun bool) {
+	if factory.reportProc == nil {
+		return
+	}
+	report := &factory.report
+	report.Ticks++
+	report.Contexts += contexts
+	report.Frames += frames
+	report.Dropped += dropped
+	report.Duration += duration
+	if duration > report.MaxDuration {
+		report.MaxDuration = duration
+	}
+	if overrun {
+		report.Overruns++
+	}
+	if report.Ticks >= factory.reportInterval {
+		collected := *report
+		*report = ContextFactoryReport{}
+		factory.reportProc(factory, &collected)
+	}
+}
+
+/**
+ * Set callback reporting media processing every number of ticks, so that embedders
+ * can feed their own monitoring.
+ * The callback is invoked by the media processing goroutine and must not block.
+ * @param interval the number of ticks to report every
+ * @param proc the callback, nil to disable reporting
+ */
+func (factory *ContextFactory) ContextFactoryReportSet(interval int, proc ContextFactoryReportProc) error {
+	if proc != nil && interval <= 0 {
+		return fmt.Errorf("invalid report interval %d", interval)
+	}
+	factory.reportProc = proc
+	factory.reportInterval = interval
+	factory.report = ContextFactoryReport{}
+	return nil
+}
+
+/* Check whether context is still to be processed */
+func (factory *ContextFactory) contextLinkedCheck(ctx *Context) bool {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	return ctx.linked
+}
+
+/* Add context to (or remove it from) the list, deferred while the list is being processed */
+func (factory *ContextFactory) contextLink(ctx *Context, add bool) {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	ctx.linked = add
+	if factory.processing {
+		factory.pending = append(factory.pending, contextFactoryOp{context: ctx, add: add})
+		return
+	}
+	factory.contextListUpdate(ctx, add)
+}
+
+/* Update the list by context, the mutex must be locked */
+func (factory *ContextFactory) contextListUpdate(ctx *Context, add bool) {
+	if add && ctx.Element == nil {
+		ctx.Element = factory.Link.PushBack(ctx)
+	} else if !add && ctx.Element != nil {
+		factory.Link.Remove(ctx.Element)
+		ctx.Element = nil
+	}
+}
+
+/** Get number of contexts processed */
+func (factory *ContextFactory) ContextFactoryCountGet() int {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	contexts := make(map[*Context]bool)
+	for e := factory.Link.Front(); e != nil; e = e.Next() {
+		contexts[e.Value.(*Context)] = true
+	}
+	for _, op := range factory.pending {
+		contexts[op.context] = true
+	}
+	count := 0
+	for ctx := range contexts {
+		if ctx.linked {
+			count++
+		}
+	}
+	return count
+}
+
+/**
+ * Report whether the last tick of media processing overran the scheduler resolution.
+ * Repeated overruns make the factory shed frames of non-live sources (file, tone)
+ * before live RTP/engine bridges are affected.
+ * @param overrun the tick overran
+ */
+func (factory *ContextFactory) ContextFactoryOverrunReport(overrun bool) {
+	overload := &factory.Overload
+	if overrun {
+		overload.inTime = 0
+		overload.overruns++
+		if overload.overruns >= overload.OverrunThreshold {
+			overload.shedding = true
+		}
+		return
+	}
+	overload.overruns = 0
+	if overload.shedding {
+		overload.inTime++
+		if overload.inTime >= overload.RecoveryThreshold {
+			overload.shedding = false
+			overload.inTime = 0
+		}
+	}
+}
+
+/** Are frames of non-live sources being shed */
+func (factory *ContextFactory) ContextFactorySheddingGet() bool {
+	return factory.Overload.shedding
+}
+
+/**
+ * Set frame duration of the media processed by factory, should be set before contexts are created.
+ * @param duration the frame duration in msec (10, 20 or 30)
+ */
+func (factory *ContextFactory) ContextFactoryFrameDurationSet(duration int64) error {
+	if err := FrameDurationValidate(duration); err != nil {
+		return err
+	}
+	factory.FrameDuration = duration
+	factory.Overload.Resolution = time.Duration(duration) * time.Millisecond
 	return nil
 }
 
@@ -130,8 +422,6 @@ func (f *ContextFactory) ContextCreate(name string, obj interface{}, maxTerminat
 			matrixItem.On = 0
 		}
 	}
-	ele := f.Link.PushBack(context)
-	context.Element = ele
 	return context
 }
 
@@ -140,6 +430,9 @@ func (f *ContextFactory) ContextCreate(name string, obj interface{}, maxTerminat
  * @param context the context to destroy
  */
 func ContextDestroy(context *Context) error {
+	for len(context.conferences) > 0 {
+		_ = context.ContextConferenceRemove(context.conferences[0])
+	}
 	for i := int64(0); i < context.Capacity; i++ {
 		termination := context.header[i].termination
 		if termination != nil {
@@ -174,13 +467,18 @@ func (context *Context) ContextTerminationAdd(termination *Termination) bool {
 			continue
 		}
 		if context.Count == 0 {
-			context.Factory.Link.PushBack(context)
+			/* the context is processed as soon as it has terminations */
+			context.Factory.contextLink(context, true)
 		}
 
 		headerItem.termination = termination
 		headerItem.TXCount = 0
 		headerItem.RXCount = 0
 
+		if stream := termination.TerminationAudioStreamGet(); stream != nil && context.Factory.FrameDuration > 0 {
+			audioStreamFrameDurationApply(stream, context.Factory.FrameDuration)
+		}
+
 		termination.slot = i
 		context.Count++
 		return true
@@ -188,6 +486,15 @@ func (context *Context) ContextTerminationAdd(termination *Termination) bool {
 	return false
 }
 
+/* Apply frame duration to the descriptors of audio stream the frame duration of which is not set */
+func audioStreamFrameDurationApply(stream *AudioStream, duration int64) {
+	for _, descriptor := range []*CodecDescriptor{stream.RXDescriptor, stream.TXDescriptor, stream.RXEventDescriptor, stream.TXEventDescriptor} {
+		if descriptor != nil && descriptor.FrameDuration == 0 {
+			descriptor.FrameDuration = duration
+		}
+	}
+}
+
 /**
  * Subtract termination from context.
  * @param context the context to subtract termination from
@@ -227,12 +534,17 @@ func (context *Context) ContextTerminationSubtract(termination *Termination) boo
 			headerItem1.RXCount--
 		}
 	}
+	for j = 0; j < context.Capacity; j++ {
+		context.matrix[i][j].associated = false
+		context.matrix[j][i].associated = false
+	}
 	headerItem1.termination = nil
 	termination.slot = -1
 	context.Count--
+	context.echoCancellersRemove(termination)
 
 	if context.Count <= 0 {
-		context.Factory.Link.Remove(context.Element)
+		context.Factory.contextLink(context, false)
 	}
 
 	return true
@@ -263,6 +575,8 @@ func (context *Context) ContextAssociationAdd(termination1, termination2 *Termin
 	matrixItem1 := &context.matrix[i][j]
 	matrixItem2 := &context.matrix[j][i]
 
+	matrixItem1.associated, matrixItem2.associated = true, true
+
 	/* 1 . 2 */
 	if matrixItem1.On <= 0 {
 		if StreamDirectionCompatibilityCheck(headerItem1.termination, headerItem2.termination) {
@@ -321,9 +635,91 @@ func (context *Context) ContextAssociationRemove(termination1, termination2 *Ter
 		headerItem2.TXCount--
 		headerItem1.RXCount--
 	}
+	matrixItem1.Gain, matrixItem2.Gain = 0, 0
+	matrixItem1.associated, matrixItem2.associated = false, false
 	return nil
 }
 
+/**
+ * Set gain of the audio of association from the first termination to the second one, e.g. to duck
+ * the prompt in the mix during barge-in. The gain is changed at once if a gain stage is applied
+ * for the association, otherwise it is applied as the topology is applied (@see ContextTopologyApply()).
+ * @param termination1 the termination (source) of the audio
+ * @param termination2 the termination (sink) of the audio
+ * @param gain the gain in dB (0 - unchanged, GAIN_MUTE - mute)
+ */
+func (context *Context) ContextAssociationGainSet(termination1, termination2 *Termination, gain float64) error {
+	if !context.terminationCheck(termination1) || !context.terminationCheck(termination2) {
+		return fmt.Errorf("no match Termination")
+	}
+	item := &context.matrix[termination1.slot][termination2.slot]
+	if item.On <= 0 {
+		return fmt.Errorf("no association")
+	}
+	if item.gain != nil {
+		if err := item.gain.GainSet(gain); err != nil {
+			return err
+		}
+	} else if gain > GAIN_MAX || math.IsNaN(gain) {
+		return fmt.Errorf("invalid gain %.1f dB", gain)
+	}
+	item.Gain = gain
+	return nil
+}
+
+/**
+ * Change direction of the audio stream of termination at runtime (e.g. on SIP hold/unhold or
+ * MRCPv2 a=sendonly update): the compatibility of the associations of the termination is recomputed,
+ * and the topology applied is re-applied if the audio flow of any association changes.
+ * @param termination the termination of the context
+ * @param direction the direction (STREAM_DIRECTION_NONE - inactive)
+ */
+func (context *Context) ContextTerminationDirectionSet(termination *Termination, direction StreamDirection) error {
+	if !context.terminationCheck(termination) {
+		return fmt.Errorf("no match Termination")
+	}
+	if err := termination.TerminationDirectionSet(direction); err != nil {
+		return err
+	}
+	if !context.associationsUpdate(termination.slot) || !context.applied {
+		return nil
+	}
+	return context.ContextTopologyApply()
+}
+
+/* Recompute the audio flow of the associations of the slot by the directions, returns whether any changes */
+func (context *Context) associationsUpdate(i int64) bool {
+	var changed bool
+	update := func(source, sink int64) {
+		item := &context.matrix[source][sink]
+		if !item.associated {
+			return
+		}
+		on := StreamDirectionCompatibilityCheck(context.header[source].termination, context.header[sink].termination)
+		if on == (item.On > 0) {
+			return
+		}
+		changed = true
+		if on {
+			item.On = 1
+			context.header[source].TXCount++
+			context.header[sink].RXCount++
+		} else {
+			item.On = 0
+			context.header[source].TXCount--
+			context.header[sink].RXCount--
+		}
+	}
+	for j := int64(0); j < context.Capacity; j++ {
+		if j == i || context.header[j].termination == nil {
+			continue
+		}
+		update(i, j)
+		update(j, i)
+	}
+	return changed
+}
+
 /**
  * Reset assigned associations and destroy applied topology.
  * @param context the context to reset associations for
@@ -336,6 +732,11 @@ func (context *Context) ContextAssociationsReset() error {
 	_ = ContextDestroy(context)
 
 	/* reset assigned associations */
+	for _, row := range context.matrix {
+		for j := range row {
+			row[j].associated = false
+		}
+	}
 	for ; i < context.Capacity && k < context.Count; i++ {
 		headerItem1 := &context.header[i]
 		if headerItem1.termination == nil {
@@ -374,29 +775,65 @@ func (context *Context) ContextObjectAdd(object *Object) error {
 	if object == nil {
 		return fmt.Errorf("object is nil")
 	}
+	if context.Factory != nil && object.TickDuration == 0 {
+		object.TickDuration = context.Factory.FrameDuration
+	}
 	context.mpfObjects.Stack.Push(object)
 	return nil
 }
 
+/**
+ * Set callback of topology applied, invoked each time the topology is (re-)applied or fails to be applied.
+ * @param proc the callback, nil to disable
+ */
+func (context *Context) ContextTopologyProcSet(proc ContextTopologyProc) {
+	context.topologyProc = proc
+}
+
 /**
  * Apply topology.
  * @param context the context to apply topology for
  */
 func (context *Context) ContextTopologyApply() error {
+	err := context.contextTopologyApply()
+	if context.topologyProc == nil {
+		return err
+	}
+	if err != nil {
+		context.topologyProc(context, "topology of "+context.Name+" failed to be applied: "+err.Error(), err)
+		return err
+	}
+	names := make([]string, 0)
+	if context.mpfObjects != nil {
+		for i := 0; i < context.mpfObjects.Stack.Size(); i++ {
+			names = append(names, context.mpfObjects.ArrayHeaderIndex(i).(*Object).Name)
+		}
+	}
+	context.topologyProc(context, fmt.Sprintf("topology of %s applied with %d objects [%s]", context.Name, len(names), strings.Join(names, ", ")), nil)
+	return nil
+}
+
+func (context *Context) contextTopologyApply() error {
 	/* first destroy existing topology / if any */
 	_ = context.ContextTopologyDestroy()
 
+	/* do not apply topology corrupting the audio silently */
+	if err := context.ContextTopologyValidate(); err != nil {
+		return err
+	}
+
+	if err := context.echoCancellersApply(); err != nil {
+		return err
+	}
+	context.tapApply()
+	context.applied = true
+
 	var (
-		i, k   int64
 		object *Object
 		err    error
 	)
-	for ; i < context.Capacity && k < context.Count; i++ {
+	for _, i := range context.topologyOrderGet() {
 		headerItem := &context.header[i]
-		if headerItem.termination == nil {
-			continue
-		}
-		k++
 
 		if headerItem.TXCount > 0 {
 			if headerItem.TXCount == 1 {
@@ -411,9 +848,12 @@ func (context *Context) ContextTopologyApply() error {
 				}
 			}
 
-			err = context.ContextObjectAdd(object)
-			if err != nil {
-				return err
+			/* no bridge is created to the sink of mixer */
+			if object != nil {
+				err = context.ContextObjectAdd(object)
+				if err != nil {
+					return err
+				}
 			}
 		}
 
@@ -429,7 +869,7 @@ func (context *Context) ContextTopologyApply() error {
 		}
 	}
 
-	return nil
+	return context.videoBridgesApply()
 }
 
 /**
@@ -445,6 +885,9 @@ func (context *Context) ContextTopologyDestroy() error {
 		}
 		context.mpfObjects.Stack.Clear()
 	}
+	context.sources = nil
+	context.sinks = nil
+	context.applied = false
 	return nil
 }
 
@@ -453,20 +896,74 @@ func (context *Context) ContextTopologyDestroy() error {
  * @param context the context to process
  */
 func (context *Context) ContextProcess() error {
+	_, _, err := context.contextProcess()
+	return err
+}
+
+/* Process context, returning the number of frames moved and dropped */
+func (context *Context) contextProcess() (frames, dropped uint64, err error) {
+	shedding := context.Factory != nil && context.Factory.ContextFactorySheddingGet()
 	if context.mpfObjects != nil && !context.mpfObjects.Stack.IsEmpty() {
 		for i := 0; i < context.mpfObjects.Stack.Size(); i++ {
 			object := context.mpfObjects.ArrayHeaderIndex(i).(*Object)
+			if object != nil && shedding && object.Sheddable {
+				/* drop the frame of non-live source */
+				context.ShedCount++
+				context.Factory.Overload.ShedCount++
+				dropped++
+				continue
+			}
 			if object != nil && object.Process != nil {
-				err := object.Process(object)
-				if err != nil {
-					return err
+				if err := object.Process(object); err != nil {
+					dropped++
+					return frames, dropped, err
 				}
+				frames++
 			}
 		}
 	}
+	for _, conference := range context.conferences {
+		if err := conference.ConferenceProcess(); err != nil {
+			dropped++
+			return frames, dropped, err
+		}
+		frames++
+	}
+	return frames, dropped, nil
+}
+
+/**
+ * Add conference processed by context, members of which are added and removed without re-applying the topology.
+ * The streams of the members must not be associated in the context otherwise.
+ * @param conference the conference to add
+ */
+func (context *Context) ContextConferenceAdd(conference *Conference) error {
+	if conference == nil {
+		return fmt.Errorf("conference is nil")
+	}
+	for _, c := range context.conferences {
+		if c == conference {
+			return fmt.Errorf("conference is already added")
+		}
+	}
+	context.conferences = append(context.conferences, conference)
 	return nil
 }
 
+/**
+ * Remove conference from context, the conference is destroyed.
+ * @param conference the conference to remove
+ */
+func (context *Context) ContextConferenceRemove(conference *Conference) error {
+	for i, c := range context.conferences {
+		if c == conference {
+			context.conferences = append(context.conferences[:i], context.conferences[i+1:]...)
+			return conference.ConferenceDestroy()
+		}
+	}
+	return fmt.Errorf("no conference")
+}
+
 func (context *Context) ContextBridgeCreate(i int64) (*Object, error) {
 	var (
 		headerItem1 = &context.header[i]
@@ -493,8 +990,12 @@ func (context *Context) ContextBridgeCreate(i int64) (*Object, error) {
 		/* create bridge i -> j */
 
 		if headerItem1.termination != nil && headerItem2.termination != nil {
-			return BridgeCreate(headerItem1.termination.audioStream,
-				headerItem2.termination.audioStream,
+			source, err := context.associationSourceGet(i, j)
+			if err != nil {
+				return nil, err
+			}
+			return BridgeCreate(source,
+				context.sinkStreamGet(j),
 				headerItem1.termination.codecManager,
 				context.Name)
 		}
@@ -520,11 +1021,18 @@ func (context *Context) ContextMultiplierCreate(i int64) (*Object, error) {
 		if item.On <= 0 {
 			continue
 		}
-		sinkArr[k] = headerItem2.termination.audioStream
+		sink, err := context.associationSinkGet(i, j)
+		if err != nil {
+			return nil, err
+		}
+		sinkArr[k] = sink
 		k++
 	}
-	return MultiplierCreate(headerItem1.termination.audioStream,
-		sinkArr, int64(len(sinkArr)), headerItem1.termination.codecManager, context.Name), nil
+	multiplier, err := MultiplierSharedCreate(context.sourceStreamGet(i), sinkArr, headerItem1.termination.codecManager, context.Name)
+	if err != nil {
+		return nil, err
+	}
+	return multiplier.MultiplierObjectGet(), nil
 }
 
 func (context *Context) ContextMixerCreate(j int64) (*Object, error) {
@@ -545,20 +1053,313 @@ func (context *Context) ContextMixerCreate(j int64) (*Object, error) {
 		if item.On <= 0 {
 			continue
 		}
-		sourceArr[k] = headerItem2.termination.audioStream
+		source, err := context.associationSourceGet(i, j)
+		if err != nil {
+			return nil, err
+		}
+		sourceArr[k] = source
+		k++
+	}
+	mixer, err := MixerWeightedCreate(sourceArr, nil, context.sinkStreamGet(j), headerItem1.termination.codecManager, context.Name)
+	if err != nil {
+		return nil, err
+	}
+	return mixer.MixerObjectGet(), nil
+}
+
+/* Get source stream of termination in the slot, as substituted while the topology is applied */
+/*
+ * Create bridges passing the video of associated terminations through. Video is not transcoded nor mixed:
+ * the video of mismatched codecs is not bridged, and a sink gets the video of the first source only,
+ * so that the audio topology is set up regardless of video.
+ */
+func (context *Context) videoBridgesApply() error {
+	taken := make(map[int64]bool)
+	for i := int64(0); i < context.Capacity; i++ {
+		termination := context.header[i].termination
+		if termination == nil || termination.videoStream == nil {
+			continue
+		}
+		var sinks []*VideoStream
+		for j := int64(0); j < context.Capacity; j++ {
+			sink := context.header[j].termination
+			if i == j || sink == nil || taken[j] || !context.matrix[i][j].associated {
+				continue
+			}
+			if VideoStreamPassthroughCheck(termination.videoStream, sink.videoStream) {
+				sinks = append(sinks, sink.videoStream)
+				taken[j] = true
+			}
+		}
+		if len(sinks) == 0 {
+			continue
+		}
+		object, err := VideoBridgeCreate(termination.videoStream, sinks, context.Name)
+		if err != nil {
+			return err
+		}
+		if err = context.ContextObjectAdd(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (context *Context) sourceStreamGet(i int64) *AudioStream {
+	if stream, ok := context.sources[i]; ok {
+		return stream
+	}
+	return context.header[i].termination.audioStream
+}
+
+/* Get sink stream of termination in the slot, as substituted while the topology is applied */
+func (context *Context) sinkStreamGet(j int64) *AudioStream {
+	if stream, ok := context.sinks[j]; ok {
+		return stream
+	}
+	return context.header[j].termination.audioStream
+}
+
+/* Get source stream of association, the audio of which is decoded and gained if gain is set */
+func (context *Context) associationSourceGet(i, j int64) (*AudioStream, error) {
+	item := &context.matrix[i][j]
+	item.gain = nil
+	if item.Gain == 0 {
+		return context.sourceStreamGet(i), nil
+	}
+	source, err := context.linearSourceStreamGet(i)
+	if err != nil {
+		return nil, err
+	}
+	if item.gain, err = GainCreate(item.Gain); err != nil {
+		return nil, err
+	}
+	return FrameFilterSourceCreate(source, item.gain), nil
+}
+
+/* Get sink stream of association (of multiplier), the audio written to which is gained if gain is set */
+func (context *Context) associationSinkGet(i, j int64) (*AudioStream, error) {
+	item := &context.matrix[i][j]
+	item.gain = nil
+	sink := context.sinkStreamGet(j)
+	if item.Gain == 0 {
+		return sink, nil
+	}
+	if sink.TXDescriptor == nil || !CodecLPcmDescriptorMatch(sink.TXDescriptor) {
+		return nil, fmt.Errorf("gain of the audio of codec written is not supported")
+	}
+	var err error
+	if item.gain, err = GainCreate(item.Gain); err != nil {
+		return nil, err
+	}
+	return FrameFilterStreamCreate(sink, item.gain), nil
+}
+
+/**
+ * Set tap of the audio written to the terminations of context, applied along with the topology
+ * (@see ContextTopologyApply()), e.g. to capture exactly what the engine receives.
+ * @param proc the procedure frames are forked to, nil to disable
+ */
+func (context *Context) ContextTapSet(proc FrameTapProc) {
+	context.tap = proc
+}
+
+/**
+ * Set tap of the contexts of name, applied as their topology is applied, unless the context has its own tap.
+ * @param name the name of contexts
+ * @param proc the procedure frames are forked to, nil to disable
+ */
+func (factory *ContextFactory) ContextFactoryTapSet(name string, proc FrameTapProc) {
+	factory.mutex.Lock()
+	defer factory.mutex.Unlock()
+	if proc == nil {
+		delete(factory.taps, name)
+		return
+	}
+	if factory.taps == nil {
+		factory.taps = make(map[string]FrameTapProc)
+	}
+	factory.taps[name] = proc
+}
+
+/* Get tap of context, if any */
+func (context *Context) tapGet() FrameTapProc {
+	if context.tap != nil || context.Factory == nil {
+		return context.tap
+	}
+	context.Factory.mutex.Lock()
+	defer context.Factory.mutex.Unlock()
+	return context.Factory.taps[context.Name]
+}
+
+/* Substitute the sink streams of terminations by the streams forking frames to the tap */
+func (context *Context) tapApply() {
+	context.sinks = make(map[int64]*AudioStream)
+	proc := context.tapGet()
+	if proc == nil {
+		return
+	}
+	for i, k := int64(0), int64(0); i < context.Capacity && k < context.Count; i++ {
+		termination := context.header[i].termination
+		if termination == nil {
+			continue
+		}
+		k++
+		sink := termination.audioStream
+		if sink == nil || (sink.direction&STREAM_DIRECTION_SEND) != STREAM_DIRECTION_SEND {
+			continue
+		}
+		context.sinks[i] = TapStreamCreate(sink, func(frame *Frame) {
+			proc(context, termination, sink.TXDescriptor, frame)
+		})
+	}
+}
+
+/* Get slots of terminations in the order objects are created (and processed) in, references of echo cancellers first */
+func (context *Context) topologyOrderGet() []int64 {
+	var (
+		order = make([]int64, 0, context.Count)
+		first = make(map[int64]bool)
+	)
+	for _, ec := range context.echoCancellers {
+		if !first[ec.reference.slot] {
+			first[ec.reference.slot] = true
+			order = append(order, ec.reference.slot)
+		}
+	}
+	for i, k := int64(0), int64(0); i < context.Capacity && k < context.Count; i++ {
+		if context.header[i].termination == nil {
+			continue
+		}
 		k++
+		/* the far-end audio is tapped before the near-end is processed */
+		if !first[i] {
+			order = append(order, i)
+		}
+	}
+	return order
+}
+
+/* Check whether termination is in the context */
+func (context *Context) terminationCheck(termination *Termination) bool {
+	return termination != nil && termination.slot >= 0 && termination.slot < context.Capacity &&
+		context.header[termination.slot].termination == termination
+}
+
+/**
+ * Add echo canceller to context, applied along with the topology (@see ContextTopologyApply()).
+ * The audio read from the reference termination (e.g. synthesizer) is the far-end reference played out,
+ * the echo of which is cancelled in the audio read from the capture termination (e.g. RTP)
+ * before the audio reaches any sink associated (e.g. recognizer).
+ * @param reference the termination of far-end audio
+ * @param capture the termination of near-end audio
+ * @param aec the echo canceller
+ */
+func (context *Context) ContextEchoCancellerAdd(reference, capture *Termination, aec *EchoCanceller) error {
+	if aec == nil {
+		return fmt.Errorf("echo canceller is nil")
+	}
+	if !context.terminationCheck(reference) || !context.terminationCheck(capture) || reference == capture {
+		return fmt.Errorf("no match Termination")
+	}
+	for _, ec := range context.echoCancellers {
+		if ec.capture == capture {
+			return fmt.Errorf("echo of termination is already cancelled")
+		}
+	}
+	context.echoCancellers = append(context.echoCancellers, contextEchoCanceller{
+		reference: reference,
+		capture:   capture,
+		aec:       aec,
+	})
+	return nil
+}
+
+/**
+ * Remove echo canceller of the capture termination from context, effective as the topology is applied.
+ * @param capture the termination of near-end audio
+ */
+func (context *Context) ContextEchoCancellerRemove(capture *Termination) error {
+	for i, ec := range context.echoCancellers {
+		if ec.capture == capture {
+			context.echoCancellers = append(context.echoCancellers[:i], context.echoCancellers[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("echo of termination is not cancelled")
+}
+
+/* Remove echo cancellers referring to termination subtracted */
+func (context *Context) echoCancellersRemove(termination *Termination) {
+	echoCancellers := context.echoCancellers[:0]
+	for _, ec := range context.echoCancellers {
+		if ec.reference != termination && ec.capture != termination {
+			echoCancellers = append(echoCancellers, ec)
+		}
+	}
+	context.echoCancellers = echoCancellers
+}
+
+/* Substitute the source streams of the terminations echo cancellers are applied to */
+func (context *Context) echoCancellersApply() error {
+	context.sources = make(map[int64]*AudioStream)
+	for _, ec := range context.echoCancellers {
+		reference, err := context.linearSourceStreamGet(ec.reference.slot)
+		if err != nil {
+			return err
+		}
+		capture, err := context.linearSourceStreamGet(ec.capture.slot)
+		if err != nil {
+			return err
+		}
+		context.sources[ec.reference.slot] = EchoCancellerReferenceStreamCreate(reference, ec.aec)
+		context.sources[ec.capture.slot] = EchoCancellerCaptureStreamCreate(capture, ec.aec)
 	}
-	return MixerCreate(sourceArr, int64(len(sourceArr)), headerItem1.termination.audioStream, headerItem1.termination.codecManager, context.Name), nil
+	return nil
 }
 
+/* Get source stream of termination in the slot decoding the audio to linear, if needed */
+func (context *Context) linearSourceStreamGet(i int64) (*AudioStream, error) {
+	var (
+		termination = context.header[i].termination
+		stream      = context.sourceStreamGet(i)
+	)
+	if stream == nil || (stream.direction&STREAM_DIRECTION_RECEIVE) != STREAM_DIRECTION_RECEIVE || stream.RXDescriptor == nil {
+		return nil, fmt.Errorf("termination has no audio to receive")
+	}
+	if CodecLPcmDescriptorMatch(stream.RXDescriptor) {
+		return stream, nil
+	}
+	if termination.codecManager == nil {
+		return nil, fmt.Errorf("no codec manager to decode %s", stream.RXDescriptor.Name)
+	}
+	codec, err := termination.codecManager.CodecManagerCodecGet(stream.RXDescriptor)
+	if err != nil {
+		return nil, err
+	}
+	decoder := DecoderCreate(stream, codec)
+	if decoder == nil {
+		return nil, fmt.Errorf("failed to create decoder")
+	}
+	return decoder, nil
+}
+
+/**
+ * Check whether media flows from the stream of termination1 to the one of termination2:
+ * the directions must be compatible and, if either stream carries named events only (no audio),
+ * the event descriptors must be compatible, so that e.g. out-of-band DTMF reaches the sink.
+ */
 func StreamDirectionCompatibilityCheck(termination1, termination2 *Termination) bool {
 	var (
 		source = termination1.audioStream
 		sink   = termination2.audioStream
 	)
-	if source != nil && (source.direction&STREAM_DIRECTION_RECEIVE) == STREAM_DIRECTION_RECEIVE &&
-		sink != nil && (sink.direction&STREAM_DIRECTION_SEND) == STREAM_DIRECTION_SEND {
-		return true
+	if source == nil || (source.direction&STREAM_DIRECTION_RECEIVE) != STREAM_DIRECTION_RECEIVE ||
+		sink == nil || (sink.direction&STREAM_DIRECTION_SEND) != STREAM_DIRECTION_SEND {
+		return false
 	}
-	return false
+	if eventOnlySourceCheck(source) || eventOnlySinkCheck(sink) {
+		return EventDescriptorsCompatible(source.RXEventDescriptor, sink.TXEventDescriptor)
+	}
+	return true
 }
@@ -0,0 +1,264 @@
+package mpf
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestContextOverloadShedding(t *testing.T) {
+	var (
+		factory        = ContextFactoryCreate()
+		context        = factory.ContextCreate("overload", nil, 2)
+		live, nonLive  int
+		liveObject     = ObjectInit("live")
+		nonLiveObject  = ObjectInit("file")
+		processObjects = func() {
+			if err := context.ContextProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	)
+	liveObject.Process = func(object *Object) error {
+		live++
+		return nil
+	}
+	nonLiveObject.Process = func(object *Object) error {
+		nonLive++
+		return nil
+	}
+	nonLiveObject.Sheddable = true
+	context.ContextObjectAdd(liveObject)
+	context.ContextObjectAdd(nonLiveObject)
+
+	for i := 0; i < MPF_OVERLOAD_OVERRUN_THRESHOLD-1; i++ {
+		factory.ContextFactoryOverrunReport(true)
+	}
+	processObjects()
+	if nonLive != 1 || context.ShedCount != 0 {
+		t.Fatalf("frames are shed before overrun threshold")
+	}
+
+	factory.ContextFactoryOverrunReport(true)
+	processObjects()
+	processObjects()
+	if live != 3 || nonLive != 1 || context.ShedCount != 2 || factory.Overload.ShedCount != 2 {
+		t.Fatalf("live %d non-live %d shed %d", live, nonLive, context.ShedCount)
+	}
+
+	for i := 0; i < MPF_OVERLOAD_RECOVERY_THRESHOLD; i++ {
+		factory.ContextFactoryOverrunReport(false)
+	}
+	processObjects()
+	if nonLive != 2 {
+		t.Fatalf("shedding is not stopped after recovery")
+	}
+}
+
+func TestContextFactoryConcurrentModify(t *testing.T) {
+	const (
+		contextCount = 8
+		cycles       = 500
+	)
+	var (
+		factory   = ContextFactoryCreate()
+		processed = make([]int32, contextCount)
+		done      = make(chan struct{})
+		wg        sync.WaitGroup
+	)
+	for i := 0; i < contextCount; i++ {
+		i := i
+		context := factory.ContextCreate("stress", nil, 2)
+		object := ObjectInit("counter")
+		object.Process = func(object *Object) error {
+			atomic.AddInt32(&processed[i], 1)
+			return nil
+		}
+		context.ContextObjectAdd(object)
+		termination := TerminationBaseCreate(nil, nil, nil, nil, nil)
+
+		/* sessions add and remove their terminations while the factory is processed */
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for c := 0; c < cycles; c++ {
+				if !context.ContextTerminationAdd(termination) {
+					t.Errorf("termination is not added")
+					return
+				}
+				runtime.Gosched()
+				if !context.ContextTerminationSubtract(termination) {
+					t.Errorf("termination is not subtracted")
+					return
+				}
+			}
+			/* the last context stays in the factory */
+			if i == 0 {
+				context.ContextTerminationAdd(termination)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			if err := ContextFactoryProcess(factory); err != nil {
+				t.Fatal(err)
+			}
+			runtime.Gosched()
+		}
+	}
+	if count := factory.ContextFactoryCountGet(); count != 1 || factory.Link.Len() != 1 {
+		t.Fatalf("%d contexts (%d listed) in factory, want 1", count, factory.Link.Len())
+	}
+	before := make([]int32, contextCount)
+	for i := range processed {
+		before[i] = atomic.LoadInt32(&processed[i])
+	}
+	ContextFactoryProcess(factory)
+	for i := range processed {
+		if diff := atomic.LoadInt32(&processed[i]) - before[i]; i == 0 && diff != 1 || i != 0 && diff != 0 {
+			t.Fatalf("context %d is processed %d times after modifications", i, diff)
+		}
+	}
+}
+
+func TestContextFactoryReport(t *testing.T) {
+	var (
+		factory = ContextFactoryCreate()
+		reports []ContextFactoryReport
+	)
+	if err := factory.ContextFactoryReportSet(0, func(*ContextFactory, *ContextFactoryReport) {}); err == nil {
+		t.Fatalf("report interval of 0 ticks is accepted")
+	}
+	factory.ContextFactoryReportSet(3, func(f *ContextFactory, report *ContextFactoryReport) {
+		reports = append(reports, *report)
+	})
+	for i := 0; i < 2; i++ {
+		context := factory.ContextCreate("report", nil, 1)
+		object := ObjectInit("bridge")
+		fail := i == 1
+		object.Process = func(object *Object) error {
+			if fail {
+				return fmt.Errorf("write failed")
+			}
+			return nil
+		}
+		context.ContextObjectAdd(object)
+		context.ContextTerminationAdd(TerminationBaseCreate(nil, nil, nil, nil, nil))
+	}
+	for i := 0; i < 7; i++ {
+		ContextFactoryProcess(factory)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("%d reports, want 2", len(reports))
+	}
+	for _, report := range reports {
+		if report.Ticks != 3 || report.Contexts != 6 || report.Frames != 3 || report.Dropped != 3 || report.MaxDuration > report.Duration {
+			t.Fatalf("unexpected report %+v", report)
+		}
+	}
+}
+
+func TestContextTopologyMismatch(t *testing.T) {
+	context := ContextFactoryCreate().ContextCreate("mismatch", nil, 2)
+	source := AudioStreamCreate(nil, &AudioStreamVTable{}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecLPcmDescriptorCreate(8000, 3)
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(11025, 1)
+	sink.TXDescriptor.Format = "ptime=25"
+	termination1 := TerminationBaseCreate(nil, nil, nil, source, nil)
+	termination1.Name = "rtp"
+	termination2 := TerminationBaseCreate(nil, nil, nil, sink, nil)
+	termination2.Name = "recognizer"
+	context.ContextTerminationAdd(termination1)
+	context.ContextTerminationAdd(termination2)
+	if err := context.ContextAssociationAdd(termination1, termination2); err != nil {
+		t.Fatal(err)
+	}
+
+	err := context.ContextTopologyApply()
+	mismatch, ok := err.(*TopologyMismatchError)
+	if !ok || len(mismatch.Mismatches) != 1 {
+		t.Fatalf("topology mismatch is not detected: %v", err)
+	}
+	if m := mismatch.Mismatches[0]; m.Source != "rtp" || m.Sink != "recognizer" || len(m.Reasons) != 3 {
+		t.Fatalf("unexpected diagnostic: %v", err)
+	}
+	if context.mpfObjects.Stack.Size() != 0 {
+		t.Fatalf("topology is applied")
+	}
+
+	/* matching descriptors are valid */
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 3)
+	if err := context.ContextTopologyValidate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextTerminationDirection(t *testing.T) {
+	full := -1
+	var played, recognized []int16
+	rtpStream := conferenceTestStream(8000, 100, &played)
+	recognizerStream := multiplierTestSink(8000, &recognized)
+	context := ContextFactoryCreate().ContextCreate("direction", nil, 3)
+	var terminations []*Termination
+	for _, stream := range []*AudioStream{mixerTestSource(300, &full), rtpStream, recognizerStream} {
+		termination := TerminationBaseCreate(nil, nil, nil, stream, nil)
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+		terminations = append(terminations, termination)
+	}
+	prompt, rtp, recognizer := terminations[0], terminations[1], terminations[2]
+	context.ContextAssociationAdd(prompt, rtp)
+	context.ContextAssociationAdd(rtp, recognizer)
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		played, recognized = nil, nil
+		if err := context.ContextProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	process()
+	if len(played) == 0 || len(recognized) == 0 {
+		t.Fatalf("%d samples played, %d samples recognized", len(played), len(recognized))
+	}
+
+	/* on hold the audio flows neither way */
+	if err := context.ContextTerminationDirectionSet(rtp, STREAM_DIRECTION_NONE); err != nil {
+		t.Fatal(err)
+	}
+	if process(); played != nil || recognized != nil || context.mpfObjects.Stack.Size() != 0 {
+		t.Fatalf("audio flows on hold")
+	}
+
+	/* sendonly: the prompt is played, nothing is received */
+	if err := context.ContextTerminationDirectionSet(rtp, STREAM_DIRECTION_SEND); err != nil {
+		t.Fatal(err)
+	}
+	if process(); played == nil || recognized != nil {
+		t.Fatalf("%d samples played, %d samples recognized when sendonly", len(played), len(recognized))
+	}
+
+	/* unhold */
+	if err := context.ContextTerminationDirectionSet(rtp, STREAM_DIRECTION_DUPLEX); err != nil {
+		t.Fatal(err)
+	}
+	if process(); played == nil || recognized == nil || recognized[0] != 100 {
+		t.Fatalf("%d samples played, %d samples recognized after unhold", len(played), len(recognized))
+	}
+
+	/* the prompt can not be made to send */
+	if err := context.ContextTerminationDirectionSet(prompt, STREAM_DIRECTION_DUPLEX); err == nil {
+		t.Fatalf("direction not supported is set")
+	}
+}
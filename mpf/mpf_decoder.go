@@ -1,12 +1,29 @@
 package mpf
 
-import "bytes"
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
 
 type Decoder struct {
 	Base    *AudioStream
 	Source  *AudioStream
 	Codec   *Codec
 	FrameIn Frame
+	/** Payload type registry of the session (optional) */
+	Registry *RtpPayloadTypeRegistry
+
+	/** Last decoded (or concealed) frame, nil if there is nothing to conceal by */
+	prev *CodecFrame
+	/** Number of consecutive frames concealed */
+	concealed int
+	/** Comfort noise generator, created on the first CN frame */
+	cn *ComfortNoiseGenerator
+	/** Silence is signaled by CN, missing frames are filled with comfort noise (not concealed) */
+	cnActive bool
 }
 
 func DecoderDestroy(stream *AudioStream) error {
@@ -16,7 +33,7 @@ func DecoderDestroy(stream *AudioStream) error {
 
 func DecoderOpen(stream *AudioStream, codec *Codec) error {
 	decoder := stream.Obj.(*Decoder)
-	err := DecoderOpen(stream, decoder.Codec)
+	err := decoder.Codec.CodecOpen()
 	if err != nil {
 		return err
 	}
@@ -32,10 +49,32 @@ func DecoderClose(stream *AudioStream) error {
 	return decoder.Source.AudioStreamRXClose()
 }
 
+/* Switch decoder to the codec of the source descriptor renegotiated, the source is re-opened with it */
+func DecoderCodecUpdate(stream *AudioStream, codec *Codec) error {
+	decoder := stream.Obj.(*Decoder)
+	if codec == nil {
+		return fmt.Errorf("codec is nil")
+	}
+	if err := decoder.Codec.CodecClose(); err != nil {
+		return err
+	}
+	decoder.Codec = codec
+	decoder.prev = nil
+	decoder.cnActive = false
+	if err := decoder.Codec.CodecOpen(); err != nil {
+		return err
+	}
+	decoder.FrameIn.CodecFrame.Size = codec.CodecFrameSizeGet(decoder.Source.RXDescriptor)
+	decoder.Base.RXDescriptor = CodecLPcmDescriptorCreate(decoder.Source.RXDescriptor.SamplingRate, decoder.Source.RXDescriptor.ChannelCount)
+	decoder.Base.RXEventDescriptor = decoder.Source.RXEventDescriptor
+	return decoder.Source.AudioStreamCodecUpdate(codec)
+}
+
 func DecoderProcess(stream *AudioStream, frame *Frame) error {
 	decoder := stream.Obj.(*Decoder)
 	decoder.FrameIn.Type = MEDIA_FRAME_TYPE_NONE
 	decoder.FrameIn.Marker = MPF_MARKER_NONE
+	decoder.FrameIn.AudioLevel = FrameAudioLevel{}
 
 	if err := decoder.Source.AudioStreamFrameRead(&decoder.FrameIn); err != nil {
 		return err
@@ -43,12 +82,109 @@ func DecoderProcess(stream *AudioStream, frame *Frame) error {
 
 	frame.Type = decoder.FrameIn.Type
 	frame.Marker = decoder.FrameIn.Marker
+	/* the level signalled is of the audio decoded only, not of the one concealed or generated */
+	frame.AudioLevel = FrameAudioLevel{}
 	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
 		frame.EventFrame = decoder.FrameIn.EventFrame
 	}
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && decoder.cnCheck() {
+		level, err := CnPayloadParse(codecFrameDataGet(&decoder.FrameIn.CodecFrame))
+		if err != nil {
+			return err
+		}
+		if decoder.cn == nil {
+			decoder.cn = ComfortNoiseGeneratorCreate(int64(level))
+		}
+		decoder.cn.ComfortNoiseLevelSet(level)
+		decoder.cnActive = true
+		decoder.prev = nil
+		return decoder.comfortNoiseGenerate(frame)
+	}
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && !decoder.payloadTypeCheck() {
+		/* payload is not of the codec, it must not be decoded */
+		frame.Type &^= MEDIA_FRAME_TYPE_AUDIO
+	}
 	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
-		return decoder.Codec.CodecDecode(&decoder.FrameIn.CodecFrame, &frame.CodecFrame)
+		if err := decoder.Codec.CodecDecode(&decoder.FrameIn.CodecFrame, &frame.CodecFrame); err != nil {
+			return err
+		}
+		frame.AudioLevel = decoder.FrameIn.AudioLevel
+		decoder.framePrevSet(&frame.CodecFrame)
+		decoder.concealed = 0
+		decoder.cnActive = false
+		return nil
+	}
+	if decoder.FrameIn.Type == MEDIA_FRAME_TYPE_NONE && decoder.cnActive {
+		/* no packets are sent during silence signaled by CN (DTX) */
+		return decoder.comfortNoiseGenerate(frame)
+	}
+	if decoder.FrameIn.Type == MEDIA_FRAME_TYPE_NONE && decoder.prev != nil {
+		/* frame is missing (lost or late), conceal it for a while */
+		if decoder.concealed >= PLC_MAX_FRAMES || decoder.Codec.CodecConcealFrame(decoder.prev, &frame.CodecFrame) != nil {
+			decoder.prev = nil
+			return nil
+		}
+		frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+		decoder.framePrevSet(&frame.CodecFrame)
+		decoder.concealed++
+	}
+	return nil
+}
+
+/* Keep copy of the frame to conceal the next missing one by */
+func (decoder *Decoder) framePrevSet(frame *CodecFrame) {
+	if decoder.prev == nil {
+		decoder.prev = &CodecFrame{}
+	}
+	codecFrameDataSet(decoder.prev, codecFrameDataGet(frame))
+}
+
+/* Check whether the input frame is of comfort noise, by the static or by the registered dynamic payload type */
+func (decoder *Decoder) cnCheck() bool {
+	if decoder.FrameIn.PayloadType == RTP_PT_CN {
+		return true
+	}
+	return decoder.Registry != nil && CnDescriptorCheck(decoder.Registry.RtpPayloadTypeResolve(decoder.FrameIn.PayloadType))
+}
+
+/* Fill the output frame with comfort noise */
+func (decoder *Decoder) comfortNoiseGenerate(frame *Frame) error {
+	descriptor := decoder.Base.RXDescriptor
+	samples := make([]int16, descriptor.CodecLinearFrameSizeGet()/BYTES_PER_SAMPLE)
+	decoder.cn.ComfortNoiseGenerate(samples)
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+}
+
+/* Check payload type of the input frame resolves to the codec of the decoder */
+func (decoder *Decoder) payloadTypeCheck() bool {
+	if decoder.Registry == nil {
+		return true
+	}
+	descriptor := decoder.Registry.RtpPayloadTypeResolve(decoder.FrameIn.PayloadType)
+	if descriptor == nil || EventDescriptorCheck(descriptor) {
+		return false
+	}
+	codecDescriptor := decoder.Codec.CodecDescriptorGet()
+	if codecDescriptor == nil {
+		return strings.EqualFold(descriptor.Name, decoder.Codec.Attribs.Name)
 	}
+	return strings.EqualFold(descriptor.Name, codecDescriptor.Name) && descriptor.SamplingRate == codecDescriptor.SamplingRate
+}
+
+/**
+ * Set payload type registry of the session to decoder.
+ * Payload types of the input frames are resolved by it, frames of unknown payload types,
+ * of named events or of other codecs are not decoded.
+ * @param stream the decoder stream
+ * @param registry the registry to set
+ */
+func DecoderPayloadTypeRegistrySet(stream *AudioStream, registry *RtpPayloadTypeRegistry) error {
+	decoder, ok := stream.Obj.(*Decoder)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *Decoder")
+	}
+	decoder.Registry = registry
 	return nil
 }
 
@@ -72,6 +208,8 @@ func DecoderCreate(source *AudioStream, codec *Codec) *AudioStream {
 		CloseTX:    nil,
 		WriteFrame: nil,
 		Trace:      nil,
+
+		UpdateCodec: DecoderCodecUpdate,
 	}
 
 	decoder := new(Decoder)
@@ -81,12 +219,13 @@ func DecoderCreate(source *AudioStream, codec *Codec) *AudioStream {
 		return nil
 	}
 	decoder.Base.RXDescriptor = CodecLPcmDescriptorCreate(source.RXDescriptor.SamplingRate, source.RXDescriptor.ChannelCount)
+	decoder.Base.RXDescriptor.FrameDuration = source.RXDescriptor.FrameDuration
 	decoder.Base.RXEventDescriptor = source.RXEventDescriptor
 
 	decoder.Source = source
 	decoder.Codec = codec
 
-	frameSize := source.RXDescriptor.CodecFrameSizeCalculate(codec.Attribs)
+	frameSize := codec.CodecFrameSizeGet(source.RXDescriptor)
 	decoder.FrameIn.CodecFrame.Size = frameSize
 	decoder.FrameIn.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
 
@@ -3,6 +3,9 @@ package mpf
 import (
 	"math"
 	"sync"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
 )
 
 /** DTMF detector band */
@@ -15,9 +18,11 @@ const (
 	MPF_DTMF_DETECTOR_OUTBAND DtmfDetectorBand = 0x2
 	/** Detect both in-band and out-of-band digits */
 	MPF_DTMF_DETECTOR_BOTH = MPF_DTMF_DETECTOR_INBAND | MPF_DTMF_DETECTOR_OUTBAND
+	/** Flag to suppress (zero) in-band tones in the frames once detected */
+	MPF_DTMF_DETECTOR_SUPPRESS DtmfDetectorBand = 0x4
 )
 
-/** Max detected DTMF digits buffer length */
+/** Default max detected DTMF digits buffer length */
 const MPF_DTMFDET_BUFFER_LEN = 32
 
 /** Number of DTMF frequencies */
@@ -29,6 +34,76 @@ const GOERTZEL_SAMPLES_8K = 102
 /** See RFC4733 */
 const DTMF_EVENT_ID_MAX = 15 /* 0123456789*#ABCD */
 
+/** Min energy (squared amplitude) of each of the dual tones */
+const DTMF_MIN_ENERGY = 1.0e4 /* amplitude ~100, about -50 dBFS */
+
+/** Max ratio of column to row energy (normal twist, 8 dB) */
+const DTMF_FORWARD_TWIST = 6.3
+
+/** Max ratio of row to column energy (reverse twist, 4 dB) */
+const DTMF_REVERSE_TWIST = 2.5
+
+/** Min part of the total signal energy the dual tone must hold */
+const DTMF_RELATIVE_ENERGY = 0.5
+
+/** Min duration of the tone in msec to be reported as a digit */
+const DTMF_MIN_TONE_DURATION = 40
+
+/** Min duration of the pause in msec to separate repeated digits */
+const DTMF_MIN_INTERDIGIT_GAP = 40
+
+/** DTMF detector config */
+type DtmfDetectorConfig struct {
+	/** Min energy (squared amplitude) of each of the dual tones */
+	MinEnergy float64
+	/** Max ratio of column to row energy (normal twist) */
+	ForwardTwist float64
+	/** Max ratio of row to column energy (reverse twist) */
+	ReverseTwist float64
+	/** Min part of the total signal energy the dual tone must hold */
+	RelativeEnergy float64
+	/** Min duration of the tone in msec */
+	MinToneDuration uint32
+	/** Min duration of the pause between digits in msec */
+	InterDigitGap uint32
+	/** Max number of detected digits kept in the buffer */
+	BufferLen int
+	/** Overwrite the oldest digit when the buffer is full instead of dropping the new one */
+	BufferOverwrite bool
+}
+
+/** Detected DTMF digit */
+type DtmfDigitEvent struct {
+	/** DTMF character [0-9*#A-D] */
+	Digit byte
+	/** Start of the digit relative to the start (or reset) of the detector on the media timeline */
+	Timestamp time.Duration
+	/** Duration of the digit */
+	Duration time.Duration
+}
+
+/** Max number of digit statistics kept by the detector */
+const MPF_DTMFDET_STATS_LEN = MPF_DTMFDET_BUFFER_LEN
+
+/** Statistics of detected DTMF digit */
+type DtmfDigitStats struct {
+	/** DTMF character [0-9*#A-D] */
+	Digit byte
+	/** Detected in-band (by tones) or out-of-band (by named events) */
+	InBand bool
+	/** Start of the digit relative to the start (or reset) of the detector on the media timeline */
+	Timestamp time.Duration
+	/** Duration of the digit in frames */
+	Frames int64
+	/** Average energies (squared amplitudes) of the row and column tones, in-band only */
+	RowEnergy, ColEnergy float64
+	/** Gap from the end of the previous digit, 0 for the first one */
+	Gap time.Duration
+}
+
+/** Handler of detected DTMF digits, invoked from the media processing thread */
+type DtmfDetectorHandler func(detector *DtmfDetector, event *DtmfDigitEvent)
+
 /** Media Processing Framework's Dual Tone Multiple Frequency detector */
 type DtmfDetector struct {
 
@@ -36,11 +111,13 @@ type DtmfDetector struct {
 	mutex sync.Mutex
 	/** Recognizer band */
 	Band DtmfDetectorBand
-	/** Detected digits buffer */
-	buf [MPF_DTMFDET_BUFFER_LEN + 1]byte
+	/** Detected digits (ring) buffer */
+	buf []byte
+	/** Position of the oldest digit in the buffer */
+	head int64
 	/** Number of digits in the buffer */
 	Digits int64
-	/** Number of lost digits due to full buffer */
+	/** Number of lost (dropped or overwritten) digits due to full buffer */
 	LostDigits int64
 	/** Frequency analyzators */
 	energies [DTMF_FREQUENCIES]GoertzelState
@@ -52,6 +129,41 @@ type DtmfDetector struct {
 	NSamples int64
 	/** Previously detected and last reported digits */
 	last1, last2, curr byte
+	/** Number of consecutive windows with the same decision as last1 */
+	hits int64
+	/** Decision thresholds */
+	config DtmfDetectorConfig
+	/** Min number of consecutive windows holding a tone */
+	toneWindows int64
+	/** Min number of consecutive windows holding a pause */
+	gapWindows int64
+
+	/** Sampling rate of the media timeline and of the named events */
+	samplingRate, eventSamplingRate int64
+	/** Media timeline position (in samples) of the frame being processed */
+	clock int64
+	/** Number of samples of the frame (timeline advance per frame) */
+	frameSamples int64
+	/** Timeline positions of the start and of the last detected window of the current in-band digit */
+	toneStart, toneEnd int64
+	/** Current out-of-band digit and its start on the timeline */
+	eventDigit byte
+	eventStart int64
+	/** Handler of detected digits */
+	handler DtmfDetectorHandler
+	/** Subscribed channels of detected digits */
+	subscribers []chan DtmfDigitEvent
+	/** Number of digit events dropped due to full subscriber channels */
+	LostEvents int64
+
+	/** Energies summed over the windows of the current decision run and of the current digit */
+	runRowEnergy, runColEnergy   float64
+	toneRowEnergy, toneColEnergy float64
+	toneWindowCount              int64
+	/** Timeline position of the end of the previous digit, -1 if none */
+	lastDigitEnd int64
+	/** Statistics of the digits detected so far */
+	stats []DtmfDigitStats
 }
 
 /**
@@ -85,6 +197,33 @@ var freq2Digits = [DTMF_FREQUENCIES / 2][DTMF_FREQUENCIES / 2]byte{
 	{'*', '0', '#', 'D'},
 }
 
+/**
+ * Allocate DTMF detector config initialized with default thresholds,
+ * suitable for clean VoIP audio.
+ */
+func DtmfDetectorConfigAlloc() *DtmfDetectorConfig {
+	return &DtmfDetectorConfig{
+		MinEnergy:       DTMF_MIN_ENERGY,
+		ForwardTwist:    DTMF_FORWARD_TWIST,
+		ReverseTwist:    DTMF_REVERSE_TWIST,
+		RelativeEnergy:  DTMF_RELATIVE_ENERGY,
+		MinToneDuration: DTMF_MIN_TONE_DURATION,
+		InterDigitGap:   DTMF_MIN_INTERDIGIT_GAP,
+		BufferLen:       MPF_DTMFDET_BUFFER_LEN,
+		BufferOverwrite: false,
+	}
+}
+
+/* Number of whole windows surely covered by the signal of the given duration */
+func dtmfDetectorWindowsCalc(durationMs uint32, samplingRate uint16, wSamples int64) int64 {
+	samples := int64(durationMs) * int64(samplingRate) / 1000
+	windows := (samples - wSamples + 1) / wSamples
+	if windows < 1 {
+		windows = 1
+	}
+	return windows
+}
+
 /**
  * Create MPF DTMF detector (advanced).
  * @param stream      A stream to get digits from.
@@ -93,11 +232,14 @@ var freq2Digits = [DTMF_FREQUENCIES / 2][DTMF_FREQUENCIES / 2]byte{
  *   - MPF_DTMF_DETECTOR_OUTBAND: detect out-of-band named-events only
  *   - MPF_DTMF_DETECTOR_BOTH: detect digits in both bands if supported by
  *     stream. When out-of-band digit arrives, in-band detection is turned off.
+ *   optionally combined with MPF_DTMF_DETECTOR_SUPPRESS to zero the audio
+ *     holding detected in-band tones, so they are not forwarded further.
+ * @param config      Decision thresholds or NULL to use the defaults.
  * @param pool        Memory pool to allocate DTMF detector from.
  * @return The object or NULL on error.
  * @see mpf_dtmf_detector_create
  */
-func DtmfDetectorCreateEx(stream *AudioStream, band DtmfDetectorBand) *DtmfDetector {
+func DtmfDetectorCreateEx(stream *AudioStream, band DtmfDetectorBand, config *DtmfDetectorConfig) *DtmfDetector {
 	var (
 		flgBand = band
 	)
@@ -108,11 +250,35 @@ func DtmfDetectorCreateEx(stream *AudioStream, band DtmfDetectorBand) *DtmfDetec
 		Event descriptor is not important actually
 		if (!stream->tx_event_descriptor) flg_band &= ~MPF_DTMF_DETECTOR_OUTBAND;
 	*/
-	if flgBand <= 0 {
+	if flgBand&MPF_DTMF_DETECTOR_BOTH <= 0 {
 		return nil
 	}
+	if config == nil {
+		config = DtmfDetectorConfigAlloc()
+	}
 	det := new(DtmfDetector)
 	det.Band = flgBand
+	det.config = *config
+	if det.config.BufferLen <= 0 {
+		det.config.BufferLen = MPF_DTMFDET_BUFFER_LEN
+	}
+	det.buf = make([]byte, det.config.BufferLen)
+	det.samplingRate = 8000
+	if stream.TXDescriptor != nil {
+		det.samplingRate = int64(stream.TXDescriptor.SamplingRate)
+	} else if stream.TXEventDescriptor != nil {
+		det.samplingRate = int64(stream.TXEventDescriptor.SamplingRate)
+	}
+	det.eventSamplingRate = det.samplingRate
+	if stream.TXDescriptor != nil {
+		det.frameSamples = det.samplingRate / 1000 * stream.TXDescriptor.CodecFrameDurationGet()
+	} else {
+		det.frameSamples = det.samplingRate / 1000 * stream.TXEventDescriptor.CodecFrameDurationGet()
+	}
+	det.lastDigitEnd = -1
+	if stream.TXEventDescriptor != nil && stream.TXEventDescriptor.SamplingRate > 0 {
+		det.eventSamplingRate = int64(stream.TXEventDescriptor.SamplingRate)
+	}
 
 	if det.Band&MPF_DTMF_DETECTOR_INBAND > 0 {
 		for i := 0; i < DTMF_FREQUENCIES; i++ {
@@ -121,10 +287,17 @@ func DtmfDetectorCreateEx(stream *AudioStream, band DtmfDetectorBand) *DtmfDetec
 			det.energies[i].S2 = 0
 		}
 		det.NSamples = 0
-		det.WSamples = GOERTZEL_SAMPLES_8K * int64(stream.TXDescriptor.SamplingRate/8000)
+		/* keep the window duration of the narrowband one for any sampling rate */
+		det.WSamples = (GOERTZEL_SAMPLES_8K*int64(stream.TXDescriptor.SamplingRate) + 4000) / 8000
+		if det.WSamples <= 0 {
+			det.WSamples = GOERTZEL_SAMPLES_8K
+		}
 		det.last1 = 0
 		det.last2 = 0
 		det.curr = 0
+		det.hits = 0
+		det.toneWindows = dtmfDetectorWindowsCalc(config.MinToneDuration, stream.TXDescriptor.SamplingRate, det.WSamples)
+		det.gapWindows = dtmfDetectorWindowsCalc(config.InterDigitGap, stream.TXDescriptor.SamplingRate, det.WSamples)
 	}
 
 	return det
@@ -144,7 +317,7 @@ func DtmfDetectorCreate(stream *AudioStream) *DtmfDetector {
 	if stream.TXEventDescriptor != nil {
 		band = MPF_DTMF_DETECTOR_BOTH
 	}
-	return DtmfDetectorCreateEx(stream, band)
+	return DtmfDetectorCreateEx(stream, band, nil)
 }
 
 /**
@@ -156,9 +329,9 @@ func (detector *DtmfDetector) DtmfDetectorDigitGet() byte {
 	var digit byte
 	detector.mutex.Lock()
 	defer detector.mutex.Unlock()
-	digit = detector.buf[0]
-	if digit > 0 {
-		copy(detector.buf[:], detector.buf[1:])
+	if detector.Digits > 0 {
+		digit = detector.buf[detector.head]
+		detector.head = (detector.head + 1) % int64(len(detector.buf))
 		detector.Digits--
 	}
 	return digit
@@ -180,14 +353,122 @@ func (detector *DtmfDetector) DtmfDetectorDigitsLost() int64 {
 func (detector *DtmfDetector) DtmfDetectorReset() {
 	detector.mutex.Lock()
 	defer detector.mutex.Unlock()
-	detector.buf[0] = 0
+	detector.head = 0
 	detector.LostDigits = 0
 	detector.Digits = 0
 	detector.curr = 0
 	detector.last1 = 0
 	detector.last2 = 0
+	detector.hits = 0
 	detector.NSamples = 0
 	detector.TotalEnergy = 0
+	detector.clock = 0
+	detector.toneStart = 0
+	detector.toneEnd = 0
+	detector.eventDigit = 0
+	detector.eventStart = 0
+	detector.lastDigitEnd = -1
+	detector.stats = nil
+}
+
+/**
+ * Get statistics of the digits completed so far (up to MPF_DTMFDET_STATS_LEN last ones).
+ * @param detector  The detector.
+ */
+func (detector *DtmfDetector) DtmfDetectorStats() []DtmfDigitStats {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	stats := make([]DtmfDigitStats, len(detector.stats))
+	copy(stats, detector.stats)
+	return stats
+}
+
+/**
+ * Set handler of detected digits. The handler is invoked from the media
+ * processing thread once the digit is over, so it must not block.
+ * @param detector  The detector.
+ * @param handler   The handler or NULL to remove it.
+ */
+func (detector *DtmfDetector) DtmfDetectorHandlerSet(handler DtmfDetectorHandler) {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	detector.handler = handler
+}
+
+/**
+ * Subscribe to detected digits. The digit is sent to the channel once it is over,
+ * the digit is dropped if the channel is full.
+ * @param detector  The detector.
+ * @param size      Capacity of the channel.
+ * @return The channel to receive detected digits from.
+ */
+func (detector *DtmfDetector) DtmfDetectorSubscribe(size int) <-chan DtmfDigitEvent {
+	ch := make(chan DtmfDigitEvent, size)
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	detector.subscribers = append(detector.subscribers, ch)
+	return ch
+}
+
+/**
+ * Unsubscribe from detected digits and close the channel.
+ * @param detector  The detector.
+ * @param ch        The channel returned by DtmfDetectorSubscribe.
+ */
+func (detector *DtmfDetector) DtmfDetectorUnsubscribe(ch <-chan DtmfDigitEvent) {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	for i, subscriber := range detector.subscribers {
+		if subscriber == ch {
+			detector.subscribers = append(detector.subscribers[:i], detector.subscribers[i+1:]...)
+			close(subscriber)
+			return
+		}
+	}
+}
+
+/* Notify handler and subscribers of the digit which is over */
+func (detector *DtmfDetector) dtmfDetectorDigitNotify(digit byte, start, end int64, inBand bool) {
+	event := DtmfDigitEvent{
+		Digit:     digit,
+		Timestamp: time.Duration(start * int64(time.Second) / detector.samplingRate),
+		Duration:  time.Duration((end - start) * int64(time.Second) / detector.samplingRate),
+	}
+	stats := DtmfDigitStats{
+		Digit:     digit,
+		InBand:    inBand,
+		Timestamp: event.Timestamp,
+	}
+	if frameSamples := detector.frameSamples; frameSamples > 0 {
+		stats.Frames = (end - start + frameSamples - 1) / frameSamples
+	}
+	if inBand && detector.toneWindowCount > 0 {
+		stats.RowEnergy = detector.toneRowEnergy / float64(detector.toneWindowCount)
+		stats.ColEnergy = detector.toneColEnergy / float64(detector.toneWindowCount)
+	}
+	if detector.lastDigitEnd >= 0 && start > detector.lastDigitEnd {
+		stats.Gap = time.Duration((start - detector.lastDigitEnd) * int64(time.Second) / detector.samplingRate)
+	}
+	detector.lastDigitEnd = end
+
+	detector.mutex.Lock()
+	if len(detector.stats) >= MPF_DTMFDET_STATS_LEN {
+		detector.stats = detector.stats[1:]
+	}
+	detector.stats = append(detector.stats, stats)
+	handler := detector.handler
+	for _, subscriber := range detector.subscribers {
+		select {
+		case subscriber <- event:
+		default:
+			detector.LostEvents++
+		}
+	}
+	detector.mutex.Unlock()
+
+	if handler != nil {
+		handler(detector, &event)
+	}
 }
 
 func (detector *DtmfDetector) DtmfDetectorAddDigit(digit byte) {
@@ -196,10 +477,15 @@ func (detector *DtmfDetector) DtmfDetectorAddDigit(digit byte) {
 	}
 	detector.mutex.Lock()
 	defer detector.mutex.Unlock()
-	if detector.Digits < MPF_DTMFDET_BUFFER_LEN {
-		detector.buf[detector.Digits] = digit
+	size := int64(len(detector.buf))
+	if detector.Digits < size {
+		detector.buf[(detector.head+detector.Digits)%size] = digit
 		detector.Digits++
-		detector.buf[detector.Digits] = 0
+	} else if detector.config.BufferOverwrite {
+		/* overwrite the oldest digit */
+		detector.buf[detector.head] = digit
+		detector.head = (detector.head + 1) % size
+		detector.LostDigits++
 	} else {
 		detector.LostDigits++
 	}
@@ -212,11 +498,86 @@ func (detector *DtmfDetector) GoertzelSample(sample int16) {
 		detector.energies[i].S2 = float64(sample) + detector.energies[i].Coef*detector.energies[i].S1 - s
 	}
 
-	detector.TotalEnergy += float64(sample * sample)
+	detector.TotalEnergy += float64(sample) * float64(sample)
 }
 
-func (detector *DtmfDetector) GoertzelEnergiesDigit() {
+/**
+ * Evaluate energies collected over the window, decide on a digit
+ * and reset the analyzators for the next window.
+ * @param position  Media timeline position (in samples) of the end of the window.
+ */
+func (detector *DtmfDetector) GoertzelEnergiesDigit(position int64) {
+	var (
+		rmax, cmax int
+		reng, ceng float64
+		digit      byte
+		/* squared amplitude of a pure tone is 4*E/N^2 */
+		norm = 4 / (float64(detector.WSamples) * float64(detector.WSamples))
+	)
+
+	/* Calculate energies and maxims */
+	for i := 0; i < DTMF_FREQUENCIES; i++ {
+		e := detector.energies[i].S1*detector.energies[i].S1 +
+			detector.energies[i].S2*detector.energies[i].S2 -
+			detector.energies[i].Coef*detector.energies[i].S1*detector.energies[i].S2
+		e *= norm
+		if i < DTMF_FREQUENCIES/2 {
+			if e > reng {
+				rmax = i
+				reng = e
+			}
+		} else {
+			if e > ceng {
+				cmax = i
+				ceng = e
+			}
+		}
+		detector.energies[i].S1 = 0
+		detector.energies[i].S2 = 0
+	}
 
+	/* Dual tone must be strong enough, within twist limits and dominate the signal;
+	total energy per sample equals to half of squared amplitude for each tone */
+	if reng >= detector.config.MinEnergy && ceng >= detector.config.MinEnergy &&
+		ceng <= reng*detector.config.ForwardTwist && reng <= ceng*detector.config.ReverseTwist &&
+		(reng+ceng)/2 >= detector.config.RelativeEnergy*detector.TotalEnergy/float64(detector.WSamples) {
+		digit = freq2Digits[rmax][cmax-DTMF_FREQUENCIES/2]
+	}
+	detector.TotalEnergy = 0
+
+	/* Debouncing: a decision (either digit or pause) is taken once it is made
+	in enough consecutive windows, a digit is reported on its leading edge only */
+	if digit == detector.last1 {
+		detector.hits++
+	} else {
+		detector.hits = 1
+		detector.runRowEnergy = 0
+		detector.runColEnergy = 0
+	}
+	detector.runRowEnergy += reng
+	detector.runColEnergy += ceng
+	detector.last2 = detector.last1
+	detector.last1 = digit
+	if digit != 0 && digit == detector.curr {
+		detector.toneEnd = position
+		detector.toneRowEnergy += reng
+		detector.toneColEnergy += ceng
+		detector.toneWindowCount++
+	} else if digit != 0 && detector.hits >= detector.toneWindows {
+		if detector.curr != 0 {
+			detector.dtmfDetectorDigitNotify(detector.curr, detector.toneStart, detector.toneEnd, true)
+		}
+		detector.curr = digit
+		detector.toneStart = position - detector.hits*detector.WSamples
+		detector.toneEnd = position
+		detector.toneRowEnergy = detector.runRowEnergy
+		detector.toneColEnergy = detector.runColEnergy
+		detector.toneWindowCount = detector.hits
+		detector.DtmfDetectorAddDigit(digit)
+	} else if digit == 0 && detector.curr != 0 && detector.hits >= detector.gapWindows {
+		detector.dtmfDetectorDigitNotify(detector.curr, detector.toneStart, detector.toneEnd, true)
+		detector.curr = 0
+	}
 }
 
 /**
@@ -225,7 +586,57 @@ func (detector *DtmfDetector) GoertzelEnergiesDigit() {
  * @param frame     Frame object passed in stream_write().
  */
 func (detector *DtmfDetector) DtmfDetectorGetFrame(frame *Frame) {
+	/* media timeline advances by frame duration regardless of the frame type */
+	defer func() {
+		detector.clock += detector.frameSamples
+	}()
 
+	if (detector.Band&MPF_DTMF_DETECTOR_OUTBAND) > 0 &&
+		(frame.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		if frame.Marker == MPF_MARKER_START_OF_EVENT {
+			digit := EventIdToDtmfChar(frame.EventFrame.EventId)
+			detector.DtmfDetectorAddDigit(digit)
+			detector.eventDigit = digit
+			detector.eventStart = detector.clock
+			/* out-of-band digit arrived, turn in-band detection off */
+			detector.Band &= ^MPF_DTMF_DETECTOR_INBAND
+		} else if frame.Marker == MPF_MARKER_END_OF_EVENT && detector.eventDigit != 0 {
+			/* end of event is retransmitted, notify on the first one only */
+			detector.dtmfDetectorDigitNotify(detector.eventDigit, detector.eventStart,
+				detector.eventStart+int64(frame.EventFrame.Duration)*detector.samplingRate/detector.eventSamplingRate, false)
+			detector.eventDigit = 0
+		}
+		return
+	}
+
+	if (detector.Band&MPF_DTMF_DETECTOR_INBAND) > 0 &&
+		(frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO &&
+		frame.CodecFrame.Buffer != nil {
+		data := frame.CodecFrame.Buffer.Bytes()
+		if frame.CodecFrame.Size > 0 && frame.CodecFrame.Size < int64(len(data)) {
+			data = data[:frame.CodecFrame.Size]
+		}
+		samples, err := binaryx.ByteSliceToInt16Slice(data)
+		if err != nil {
+			return
+		}
+		for i, sample := range samples {
+			detector.GoertzelSample(sample)
+			detector.NSamples++
+			if detector.NSamples >= detector.WSamples {
+				detector.GoertzelEnergiesDigit(detector.clock + int64(i) + 1)
+				detector.NSamples = 0
+			}
+		}
+
+		/* zero the frame while the tone lasts; the onset of the tone preceding
+		its detection (up to two windows) can not be suppressed */
+		if (detector.Band&MPF_DTMF_DETECTOR_SUPPRESS) > 0 && (detector.last1 != 0 || detector.curr != 0) {
+			for i := range data {
+				data[i] = 0
+			}
+		}
+	}
 }
 
 /**
@@ -233,5 +644,12 @@ func (detector *DtmfDetector) DtmfDetectorGetFrame(frame *Frame) {
  * @param detector  The detector.
  */
 func DtmfDetectorDestroy(detector *DtmfDetector) error {
+	detector.mutex.Lock()
+	defer detector.mutex.Unlock()
+	for _, subscriber := range detector.subscribers {
+		close(subscriber)
+	}
+	detector.subscribers = nil
+	detector.handler = nil
 	return nil
 }
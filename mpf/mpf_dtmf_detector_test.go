@@ -0,0 +1,261 @@
+package mpf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* synthesize dual tone of the digit followed by silence, split into 10 ms frames */
+func dtmfFrames(digit byte, samplingRate int, toneMs, silenceMs int, amp float64) []*Frame {
+	var (
+		row, col  int
+		samples   []int16
+		frameSize = samplingRate / 100
+	)
+	for r := 0; r < DTMF_FREQUENCIES/2; r++ {
+		for c := 0; c < DTMF_FREQUENCIES/2; c++ {
+			if freq2Digits[r][c] == digit {
+				row, col = r, c+DTMF_FREQUENCIES/2
+			}
+		}
+	}
+	for i := 0; i < samplingRate*toneMs/1000; i++ {
+		t := float64(i) / float64(samplingRate)
+		v := amp*math.Sin(2*math.Pi*DtmfFreqs[row]*t) + amp*math.Sin(2*math.Pi*DtmfFreqs[col]*t)
+		samples = append(samples, int16(v))
+	}
+	for i := 0; i < samplingRate*silenceMs/1000; i++ {
+		samples = append(samples, 0)
+	}
+
+	var frames []*Frame
+	for i := 0; i+frameSize <= len(samples); i += frameSize {
+		data := binaryx.Int16SliceToByteSlice(samples[i : i+frameSize])
+		frames = append(frames, &Frame{
+			Type:       MEDIA_FRAME_TYPE_AUDIO,
+			CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(data), Size: int64(len(data))},
+		})
+	}
+	return frames
+}
+
+func dtmfDetectorTestCreate(samplingRate uint16) *DtmfDetector {
+	stream := &AudioStream{
+		TXDescriptor: &CodecDescriptor{SamplingRate: samplingRate, ChannelCount: 1},
+	}
+	return DtmfDetectorCreateEx(stream, MPF_DTMF_DETECTOR_BOTH, nil)
+}
+
+func TestDtmfDetectorInBand(t *testing.T) {
+	const digits = "0123456789*#ABCD"
+	detector := dtmfDetectorTestCreate(8000)
+	for i := 0; i < len(digits); i++ {
+		for _, frame := range dtmfFrames(digits[i], 8000, 60, 60, 6000) {
+			detector.DtmfDetectorGetFrame(frame)
+		}
+	}
+
+	var detected []byte
+	for digit := detector.DtmfDetectorDigitGet(); digit != 0; digit = detector.DtmfDetectorDigitGet() {
+		detected = append(detected, digit)
+	}
+	if string(detected) != digits {
+		t.Errorf("detected %q, expected %q", detected, digits)
+	}
+}
+
+func TestDtmfDetectorWideband(t *testing.T) {
+	const digits = "0123456789*#ABCD"
+	for _, samplingRate := range []int{16000, 32000, 44100, 48000} {
+		detector := dtmfDetectorTestCreate(uint16(samplingRate))
+		for i := 0; i < len(digits); i++ {
+			for _, frame := range dtmfFrames(digits[i], samplingRate, 45, 45, 6000) {
+				detector.DtmfDetectorGetFrame(frame)
+			}
+		}
+
+		var detected []byte
+		for digit := detector.DtmfDetectorDigitGet(); digit != 0; digit = detector.DtmfDetectorDigitGet() {
+			detected = append(detected, digit)
+		}
+		if string(detected) != digits {
+			t.Errorf("%d Hz: detected %q, expected %q", samplingRate, detected, digits)
+		}
+	}
+}
+
+func TestDtmfDetectorSilence(t *testing.T) {
+	detector := dtmfDetectorTestCreate(8000)
+	for _, frame := range dtmfFrames('5', 8000, 100, 0, 30) {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+	if digit := detector.DtmfDetectorDigitGet(); digit != 0 {
+		t.Errorf("detected %q in a tone below energy threshold", digit)
+	}
+}
+
+func TestDtmfDetectorOutOfBand(t *testing.T) {
+	detector := dtmfDetectorTestCreate(8000)
+	frame := &Frame{
+		Type:       MEDIA_FRAME_TYPE_EVENT,
+		Marker:     MPF_MARKER_START_OF_EVENT,
+		EventFrame: NamedEventFrame{EventId: DtmfCharToEventId('#')},
+	}
+	detector.DtmfDetectorGetFrame(frame)
+	if digit := detector.DtmfDetectorDigitGet(); digit != '#' {
+		t.Errorf("detected %q, expected '#'", digit)
+	}
+	if detector.Band&MPF_DTMF_DETECTOR_INBAND != 0 {
+		t.Errorf("in-band detection must be turned off after out-of-band digit")
+	}
+}
+
+func TestDtmfDetectorConfig(t *testing.T) {
+	stream := &AudioStream{TXDescriptor: &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}}
+
+	config := DtmfDetectorConfigAlloc()
+	config.MinToneDuration = 100
+	detector := DtmfDetectorCreateEx(stream, MPF_DTMF_DETECTOR_INBAND, config)
+	for _, frame := range dtmfFrames('3', 8000, 60, 60, 6000) {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+	if digit := detector.DtmfDetectorDigitGet(); digit != 0 {
+		t.Errorf("detected %q in a tone shorter than min tone duration", digit)
+	}
+
+	config = DtmfDetectorConfigAlloc()
+	config.MinEnergy = 1.0e2
+	detector = DtmfDetectorCreateEx(stream, MPF_DTMF_DETECTOR_INBAND, config)
+	for _, frame := range dtmfFrames('3', 8000, 60, 60, 30) {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+	if digit := detector.DtmfDetectorDigitGet(); digit != '3' {
+		t.Errorf("detected %q, expected '3' with lowered energy threshold", digit)
+	}
+}
+
+func TestDtmfDetectorSubscribe(t *testing.T) {
+	detector := dtmfDetectorTestCreate(8000)
+	events := detector.DtmfDetectorSubscribe(4)
+	var handled []byte
+	detector.DtmfDetectorHandlerSet(func(detector *DtmfDetector, event *DtmfDigitEvent) {
+		handled = append(handled, event.Digit)
+	})
+
+	for _, frame := range dtmfFrames('1', 8000, 100, 100, 6000) {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+	for _, frame := range dtmfFrames('2', 8000, 100, 100, 6000) {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+
+	for _, expected := range []struct {
+		digit     byte
+		timestamp time.Duration
+	}{{'1', 0}, {'2', 200 * time.Millisecond}} {
+		select {
+		case event := <-events:
+			if event.Digit != expected.digit {
+				t.Errorf("received %q, expected %q", event.Digit, expected.digit)
+			}
+			if d := event.Timestamp - expected.timestamp; d < -20*time.Millisecond || d > 20*time.Millisecond {
+				t.Errorf("digit %q timestamp %v, expected %v", event.Digit, event.Timestamp, expected.timestamp)
+			}
+			if event.Duration < 70*time.Millisecond || event.Duration > 110*time.Millisecond {
+				t.Errorf("digit %q duration %v, expected about 100ms", event.Digit, event.Duration)
+			}
+		default:
+			t.Fatalf("digit %q is not received", expected.digit)
+		}
+	}
+	if string(handled) != "12" {
+		t.Errorf("handled %q, expected \"12\"", handled)
+	}
+
+	detector.DtmfDetectorUnsubscribe(events)
+	if _, ok := <-events; ok {
+		t.Errorf("channel must be closed on unsubscribe")
+	}
+}
+
+func TestDtmfDetectorSuppress(t *testing.T) {
+	stream := &AudioStream{TXDescriptor: &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}}
+	detector := DtmfDetectorCreateEx(stream, MPF_DTMF_DETECTOR_INBAND|MPF_DTMF_DETECTOR_SUPPRESS, nil)
+
+	frames := dtmfFrames('9', 8000, 100, 100, 6000)
+	for _, frame := range frames {
+		detector.DtmfDetectorGetFrame(frame)
+	}
+	if digit := detector.DtmfDetectorDigitGet(); digit != '9' {
+		t.Fatalf("detected %q, expected '9'", digit)
+	}
+
+	/* the tone is suppressed once detected, up to its end */
+	for i := 3; i < 10; i++ {
+		for _, b := range frames[i].CodecFrame.Buffer.Bytes() {
+			if b != 0 {
+				t.Fatalf("frame %d is not suppressed", i)
+			}
+		}
+	}
+}
+
+func TestDtmfDetectorStats(t *testing.T) {
+	detector := dtmfDetectorTestCreate(8000)
+	for _, digit := range []byte("47") {
+		for _, frame := range dtmfFrames(digit, 8000, 100, 150, 6000) {
+			detector.DtmfDetectorGetFrame(frame)
+		}
+	}
+
+	stats := detector.DtmfDetectorStats()
+	if len(stats) != 2 || stats[0].Digit != '4' || stats[1].Digit != '7' {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	for _, s := range stats {
+		if !s.InBand || s.Frames < 8 || s.Frames > 11 {
+			t.Errorf("digit %q: unexpected in-band %v, frames %d", s.Digit, s.InBand, s.Frames)
+		}
+		/* squared amplitude of each tone */
+		if math.Abs(s.RowEnergy-36e6) > 6e6 || math.Abs(s.ColEnergy-36e6) > 6e6 {
+			t.Errorf("digit %q: unexpected energies %.0f/%.0f", s.Digit, s.RowEnergy, s.ColEnergy)
+		}
+	}
+	if stats[0].Gap != 0 || stats[1].Gap < 120*time.Millisecond || stats[1].Gap > 180*time.Millisecond {
+		t.Errorf("unexpected gaps %v/%v", stats[0].Gap, stats[1].Gap)
+	}
+}
+
+func TestDtmfDetectorBuffer(t *testing.T) {
+	stream := &AudioStream{TXEventDescriptor: EventDescriptorCreate(8000)}
+	for _, overwrite := range []bool{false, true} {
+		config := DtmfDetectorConfigAlloc()
+		config.BufferLen = 4
+		config.BufferOverwrite = overwrite
+		detector := DtmfDetectorCreateEx(stream, MPF_DTMF_DETECTOR_OUTBAND, config)
+		for _, digit := range []byte("123456") {
+			detector.DtmfDetectorGetFrame(&Frame{
+				Type:       MEDIA_FRAME_TYPE_EVENT,
+				Marker:     MPF_MARKER_START_OF_EVENT,
+				EventFrame: NamedEventFrame{EventId: DtmfCharToEventId(digit)},
+			})
+		}
+
+		var detected []byte
+		for digit := detector.DtmfDetectorDigitGet(); digit != 0; digit = detector.DtmfDetectorDigitGet() {
+			detected = append(detected, digit)
+		}
+		expected := "1234"
+		if overwrite {
+			expected = "3456"
+		}
+		if string(detected) != expected || detector.DtmfDetectorDigitsLost() != 2 {
+			t.Errorf("overwrite %v: detected %q, lost %d, expected %q, lost 2",
+				overwrite, detected, detector.DtmfDetectorDigitsLost(), expected)
+		}
+	}
+}
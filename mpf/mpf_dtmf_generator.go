@@ -1,6 +1,13 @@
 package mpf
 
-import "sync"
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
 
 /** Max DTMF digits waiting to be sent */
 const MPF_DTMFGEN_QUEUE_LEN = 32
@@ -11,6 +18,9 @@ const DTMF_EVENT_VOLUME = 10
 /** Amplitude of single sine wave from tone generator */
 const DTMF_SINE_AMPLITUDE = 12288
 
+/** Default interval between named event packets in msec */
+const DTMF_EVENTS_PTIME = 20
+
 /** DTMF generator band */
 type DtmfGeneratorBand = int
 
@@ -63,7 +73,7 @@ type DtmfGenerator struct {
 	/** Mutex to guard the queue */
 	mutex sync.Mutex
 	/** Queue of digits to generate */
-	queue [MPF_DTMFGEN_QUEUE_LEN + 1]byte
+	queue []byte
 	/** DTMF event_id according to RFC4733 */
 	EventId uint8
 	/** Duration in RTP units: (sample_rate / 1000) * milliseconds */
@@ -74,6 +84,8 @@ type DtmfGenerator struct {
 	Counter uint32
 	/** Frame duration in RTP units */
 	FrameDuration uint32
+	/** Frame duration in msec */
+	frameTime uint32
 	/** RTP named event duration (0..0xFFFF) */
 	EventDuration uint32
 	/** Set MPF_MARKER_NEW_SEGMENT in the next event frame */
@@ -92,6 +104,26 @@ type DtmfGenerator struct {
 	SinceLastEvent uint32
 }
 
+/** [row, col] frequencies of DTMF digits indexed by event identifiers */
+var dtmfEventFreqs = [DTMF_EVENT_ID_MAX + 1][2]float64{
+	{941, 1336}, /* 0 */
+	{697, 1209}, /* 1 */
+	{697, 1336}, /* 2 */
+	{697, 1477}, /* 3 */
+	{770, 1209}, /* 4 */
+	{770, 1336}, /* 5 */
+	{770, 1477}, /* 6 */
+	{852, 1209}, /* 7 */
+	{852, 1336}, /* 8 */
+	{852, 1477}, /* 9 */
+	{941, 1209}, /* * */
+	{941, 1477}, /* # */
+	{697, 1633}, /* A */
+	{770, 1633}, /* B */
+	{852, 1633}, /* C */
+	{941, 1633}, /* D */
+}
+
 /**
  * Create MPF DTMF generator (advanced).
  * @param stream      A stream to transport digits via.
@@ -103,7 +135,40 @@ type DtmfGenerator struct {
  * @see mpf_dtmf_generator_create
  */
 func DtmfGeneratorCreateEx(stream *AudioStream, band DtmfGeneratorBand, toneMs uint32, silenceMs uint32) *DtmfGenerator {
-	return nil
+	var (
+		flgBand = band
+	)
+	if stream.RXDescriptor == nil {
+		flgBand &= ^MPF_DTMF_GENERATOR_INBAND
+	}
+	if stream.RXEventDescriptor == nil {
+		flgBand &= ^MPF_DTMF_GENERATOR_OUTBAND
+	}
+	if flgBand <= 0 {
+		return nil
+	}
+
+	gen := new(DtmfGenerator)
+	gen.band = flgBand
+	gen.queue = make([]byte, 0, MPF_DTMFGEN_QUEUE_LEN)
+	gen.state = DTMF_GEN_STATE_IDLE
+	if stream.RXDescriptor != nil {
+		gen.SampleRateAudio = uint32(stream.RXDescriptor.SamplingRate)
+	}
+	gen.SampleRateEvents = gen.SampleRateAudio
+	if stream.RXEventDescriptor != nil {
+		gen.SampleRateEvents = uint32(stream.RXEventDescriptor.SamplingRate)
+	}
+	if stream.RXDescriptor != nil {
+		gen.frameTime = uint32(stream.RXDescriptor.CodecFrameDurationGet())
+	} else {
+		gen.frameTime = uint32(stream.RXEventDescriptor.CodecFrameDurationGet())
+	}
+	gen.FrameDuration = gen.SampleRateEvents / 1000 * gen.frameTime
+	gen.ToneDuration = gen.SampleRateEvents / 1000 * toneMs
+	gen.SilenceDuration = gen.SampleRateEvents / 1000 * silenceMs
+	gen.EventsPtime = DTMF_EVENTS_PTIME
+	return gen
 }
 
 /**
@@ -129,7 +194,14 @@ func DtmfGeneratorCreate(stream *AudioStream) *DtmfGenerator {
  * @param digits    DTMF character sequence [0-9*#A-D].
  * @return TRUE if ok, FALSE if there are too many digits.
  */
-func (g *DtmfGenerator) DtmfGeneratorEnqueue(digits byte) error {
+func (g *DtmfGenerator) DtmfGeneratorEnqueue(digits string) error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if len(g.queue)+len(digits) > MPF_DTMFGEN_QUEUE_LEN {
+		return fmt.Errorf("DTMF queue too short (%d), cannot add %d digit(s) to already present %d",
+			MPF_DTMFGEN_QUEUE_LEN, len(digits), len(g.queue))
+	}
+	g.queue = append(g.queue, digits...)
 	return nil
 }
 
@@ -137,7 +209,12 @@ func (g *DtmfGenerator) DtmfGeneratorEnqueue(digits byte) error {
  * Empty the queue and immediately stop generating.
  * @param generator The generator.
  */
-func (g *DtmfGenerator) DtmfGeneratorReset() {}
+func (g *DtmfGenerator) DtmfGeneratorReset() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.state = DTMF_GEN_STATE_IDLE
+	g.queue = g.queue[:0]
+}
 
 /**
  * Check state of the generator.
@@ -146,7 +223,79 @@ func (g *DtmfGenerator) DtmfGeneratorReset() {}
  * FALSE if the queue is empty or generating silence after the last digit.
  */
 func (g *DtmfGenerator) DtmfGeneratorSending() bool {
-	return false
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return len(g.queue) > 0 || g.state != DTMF_GEN_STATE_IDLE
+}
+
+func (g *DtmfGenerator) dtmfGeneratorAdvance() {
+	g.Counter += g.FrameDuration
+	if (g.band & MPF_DTMF_GENERATOR_OUTBAND) > 0 {
+		g.SinceLastEvent += g.frameTime
+		g.EventDuration += g.FrameDuration
+	}
+}
+
+/* Start generating the next valid digit from the queue, must be called under the lock */
+func (g *DtmfGenerator) dtmfGeneratorNextDigit() {
+	if g.state != DTMF_GEN_STATE_IDLE || len(g.queue) == 0 {
+		return
+	}
+
+	eventId := uint32(DTMF_EVENT_ID_MAX + 1)
+	for len(g.queue) > 0 && eventId > DTMF_EVENT_ID_MAX {
+		eventId = DtmfCharToEventId(g.queue[0])
+		g.queue = g.queue[1:]
+	}
+	if eventId > DTMF_EVENT_ID_MAX {
+		return
+	}
+
+	g.EventId = uint8(eventId)
+	g.state = DTMF_GEN_STATE_TONE
+	g.Counter = 0
+	g.EventDuration = 0
+	if (g.band & MPF_DTMF_GENERATOR_INBAND) > 0 {
+		omega := 2 * math.Pi * dtmfEventFreqs[eventId][0] / float64(g.SampleRateAudio)
+		g.sine1 = SineState{Coef: 2 * math.Cos(omega), S1: 0, S2: DTMF_SINE_AMPLITUDE * math.Sin(omega)}
+		omega = 2 * math.Pi * dtmfEventFreqs[eventId][1] / float64(g.SampleRateAudio)
+		g.sine2 = SineState{Coef: 2 * math.Cos(omega), S1: 0, S2: DTMF_SINE_AMPLITUDE * math.Sin(omega)}
+	}
+	if (g.band & MPF_DTMF_GENERATOR_OUTBAND) > 0 {
+		g.SinceLastEvent = 0
+		g.NewSegment = true
+	}
+}
+
+func (g *DtmfGenerator) dtmfGeneratorTone(frame *Frame) {
+	var (
+		samples = make([]int16, frame.CodecFrame.Size/BYTES_PER_SAMPLE)
+		s       float64
+	)
+	for i := range samples {
+		samples[i] = int16(g.sine1.S2 + g.sine2.S2)
+		s = g.sine1.S1
+		g.sine1.S1 = g.sine1.S2
+		g.sine1.S2 = g.sine1.Coef*g.sine1.S1 - s
+		s = g.sine2.S1
+		g.sine2.S1 = g.sine2.S2
+		g.sine2.S2 = g.sine2.Coef*g.sine2.S1 - s
+	}
+	if frame.CodecFrame.Buffer == nil {
+		frame.CodecFrame.Buffer = bytes.NewBuffer(nil)
+	}
+	frame.CodecFrame.Buffer.Reset()
+	frame.CodecFrame.Buffer.Write(binaryx.Int16SliceToByteSlice(samples))
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+}
+
+func (g *DtmfGenerator) dtmfGeneratorEvent(frame *Frame, marker FrameMarker, edge uint32) {
+	frame.Type |= MEDIA_FRAME_TYPE_EVENT
+	frame.Marker = marker
+	frame.EventFrame.EventId = uint32(g.EventId)
+	frame.EventFrame.Volume = DTMF_EVENT_VOLUME
+	frame.EventFrame.Edge = edge
+	frame.EventFrame.Duration = g.EventDuration
 }
 
 /**
@@ -159,8 +308,55 @@ func (g *DtmfGenerator) DtmfGeneratorSending() bool {
  * object was filled with data. This method MUST be called for each frame for
  * proper timing.
  */
-func (g *DtmfGenerator) DtmfGeneratorPutFrame(frame *Frame) error {
-	return nil
+func (g *DtmfGenerator) DtmfGeneratorPutFrame(frame *Frame) bool {
+	/* the state is shared with enqueue and reset, called apart from the media processing */
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.dtmfGeneratorNextDigit()
+
+	switch g.state {
+	case DTMF_GEN_STATE_TONE:
+		g.dtmfGeneratorAdvance()
+		if (g.band & MPF_DTMF_GENERATOR_INBAND) > 0 {
+			g.dtmfGeneratorTone(frame)
+		}
+		if (g.band & MPF_DTMF_GENERATOR_OUTBAND) > 0 {
+			if g.Counter >= g.ToneDuration {
+				/* the last packet of the event, retransmitted while ending */
+				g.dtmfGeneratorEvent(frame, MPF_MARKER_END_OF_EVENT, 1)
+				g.state = DTMF_GEN_STATE_ENDING
+				g.Counter = 0
+				g.SinceLastEvent = 0
+			} else if g.NewSegment || g.SinceLastEvent >= g.EventsPtime {
+				marker := MPF_MARKER_NONE
+				if g.NewSegment {
+					marker = MPF_MARKER_START_OF_EVENT
+				}
+				g.NewSegment = false
+				g.dtmfGeneratorEvent(frame, marker, 0)
+				g.SinceLastEvent = 0
+			}
+		} else if g.Counter >= g.ToneDuration {
+			g.state = DTMF_GEN_STATE_SILENCE
+			g.Counter = 0
+		}
+		return true
+	case DTMF_GEN_STATE_ENDING:
+		/* duration of the final packet is not advanced (RFC4733 2.5.1.4) */
+		g.Counter += g.FrameDuration
+		g.dtmfGeneratorEvent(frame, MPF_MARKER_END_OF_EVENT, 1)
+		if g.Counter >= 2*g.FrameDuration {
+			g.state = DTMF_GEN_STATE_SILENCE
+			g.Counter = 0
+		}
+		return true
+	case DTMF_GEN_STATE_SILENCE:
+		g.Counter += g.FrameDuration
+		if g.Counter >= g.SilenceDuration {
+			g.state = DTMF_GEN_STATE_IDLE
+		}
+	}
+	return false
 }
 
 /**
@@ -168,5 +364,5 @@ func (g *DtmfGenerator) DtmfGeneratorPutFrame(frame *Frame) error {
  * @param generator The generator.
  */
 func DtmfGeneratorDestroy(g *DtmfGenerator) {
-
+	g.DtmfGeneratorReset()
 }
@@ -0,0 +1,90 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDtmfGeneratorInBand(t *testing.T) {
+	const digits = "159#D"
+	descriptor := &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}
+	generator := DtmfGeneratorCreateEx(&AudioStream{RXDescriptor: descriptor}, MPF_DTMF_GENERATOR_INBAND, 70, 50)
+	detector := DtmfDetectorCreateEx(&AudioStream{TXDescriptor: descriptor}, MPF_DTMF_DETECTOR_INBAND, nil)
+	if err := generator.DtmfGeneratorEnqueue(digits); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 200 && generator.DtmfGeneratorSending(); i++ {
+		size := CodecLinearFrameSizeCalculate(8000, 1)
+		frame := &Frame{
+			Type:       MEDIA_FRAME_TYPE_AUDIO,
+			CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(make([]byte, size)), Size: size},
+		}
+		generator.DtmfGeneratorPutFrame(frame)
+		detector.DtmfDetectorGetFrame(frame)
+	}
+
+	var detected []byte
+	for digit := detector.DtmfDetectorDigitGet(); digit != 0; digit = detector.DtmfDetectorDigitGet() {
+		detected = append(detected, digit)
+	}
+	if string(detected) != digits {
+		t.Errorf("detected %q, expected %q", detected, digits)
+	}
+}
+
+func TestDtmfGeneratorOutOfBand(t *testing.T) {
+	stream := &AudioStream{RXEventDescriptor: EventDescriptorCreate(8000)}
+	generator := DtmfGeneratorCreate(stream)
+	if err := generator.DtmfGeneratorEnqueue("7"); err != nil {
+		t.Fatal(err)
+	}
+
+	var markers []FrameMarker
+	for i := 0; i < 50 && generator.DtmfGeneratorSending(); i++ {
+		frame := &Frame{}
+		if generator.DtmfGeneratorPutFrame(frame) && frame.Type&MEDIA_FRAME_TYPE_EVENT != 0 {
+			if frame.EventFrame.EventId != DtmfCharToEventId('7') {
+				t.Fatalf("unexpected event id %d", frame.EventFrame.EventId)
+			}
+			markers = append(markers, frame.Marker)
+		}
+	}
+	if len(markers) < 2 || markers[0] != MPF_MARKER_START_OF_EVENT || markers[len(markers)-1] != MPF_MARKER_END_OF_EVENT {
+		t.Errorf("unexpected event markers %v", markers)
+	}
+	if generator.DtmfGeneratorSending() {
+		t.Errorf("generator must be idle after the digit is sent")
+	}
+}
+
+func TestDtmfGeneratorQueueOverflow(t *testing.T) {
+	generator := DtmfGeneratorCreate(&AudioStream{RXDescriptor: &CodecDescriptor{SamplingRate: 8000}})
+	if err := generator.DtmfGeneratorEnqueue(string(make([]byte, MPF_DTMFGEN_QUEUE_LEN+1))); err == nil {
+		t.Errorf("expected error on queue overflow")
+	}
+}
+
+func TestDtmfGeneratorConcurrentReset(t *testing.T) {
+	descriptor := &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}
+	generator := DtmfGeneratorCreateEx(&AudioStream{RXDescriptor: descriptor}, MPF_DTMF_GENERATOR_INBAND, 70, 50)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			generator.DtmfGeneratorEnqueue("12")
+			generator.DtmfGeneratorSending()
+			generator.DtmfGeneratorReset()
+		}
+	}()
+
+	size := CodecLinearFrameSizeCalculate(8000, 1)
+	for i := 0; i < 500; i++ {
+		frame := &Frame{
+			Type:       MEDIA_FRAME_TYPE_AUDIO,
+			CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(make([]byte, size)), Size: size},
+		}
+		generator.DtmfGeneratorPutFrame(frame)
+	}
+	<-done
+}
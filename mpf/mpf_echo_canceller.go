@@ -0,0 +1,303 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Default echo tail length covered by the canceller in msec */
+const AEC_TAIL_LENGTH = 64
+
+/** Step size of NLMS adaptation (0 .. 2) */
+const AEC_STEP_SIZE = 0.5
+
+/** Threshold of double-talk detection (Geigel): near-end is talking if it exceeds the max far-end level by the ratio */
+const AEC_DOUBLE_TALK_RATIO = 0.5
+
+/** Number of samples adaptation is held after double-talk is detected */
+const AEC_DOUBLE_TALK_HANGOVER = 240
+
+/** Max number of reference samples buffered ahead of the near-end (e.g. 200 msec at 8 kHz) */
+const AEC_REFERENCE_MAX = 1600
+
+/**
+ * Acoustic echo canceller by NLMS adaptive filter.
+ * The far-end (played) audio is the reference, its echo leaking into the near-end (captured) audio
+ * is estimated and subtracted. Adaptation is frozen while the near-end talks (double-talk),
+ * so that barge-in speech does not disturb the echo path estimated.
+ */
+type EchoCanceller struct {
+	/** Filter taps (echo path estimated) */
+	weights []float64
+	/** Far-end history, the latest sample first (circular) */
+	history []float64
+	/** Position of the latest sample in the history */
+	pos int
+	/** Energy of the history */
+	energy float64
+	/** Hangover of double-talk */
+	hangover int
+
+	/** Far-end samples not consumed by the near-end yet */
+	reference []int16
+
+	mutex sync.Mutex
+}
+
+/**
+ * Create echo canceller.
+ * @param descriptor the descriptor of the (linear, mono) audio
+ * @param tailLength the echo tail length in msec
+ */
+func EchoCancellerCreate(descriptor *CodecDescriptor, tailLength int64) (*EchoCanceller, error) {
+	if descriptor == nil || descriptor.ChannelCount != 1 || descriptor.SamplingRate == 0 {
+		return nil, fmt.Errorf("echo cancellation of the audio is not supported")
+	}
+	if tailLength <= 0 {
+		return nil, fmt.Errorf("invalid echo tail length %d", tailLength)
+	}
+	taps := int(tailLength) * int(descriptor.SamplingRate) / 1000
+	return &EchoCanceller{
+		weights: make([]float64, taps),
+		history: make([]float64, taps),
+	}, nil
+}
+
+/** Reset echo canceller, the echo path estimated is dropped */
+func (ec *EchoCanceller) EchoCancellerReset() {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	for i := range ec.weights {
+		ec.weights[i] = 0
+		ec.history[i] = 0
+	}
+	ec.energy = 0
+	ec.hangover = 0
+	ec.reference = ec.reference[:0]
+}
+
+/**
+ * Write far-end (reference) samples played out.
+ * @param samples the samples played
+ */
+func (ec *EchoCanceller) EchoCancellerReferenceWrite(samples []int16) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	ec.reference = append(ec.reference, samples...)
+	if drop := len(ec.reference) - AEC_REFERENCE_MAX; drop > 0 {
+		/* the near-end is not processed, keep the latest reference only */
+		ec.reference = append(ec.reference[:0], ec.reference[drop:]...)
+	}
+}
+
+/* Push far-end sample to the history */
+func (ec *EchoCanceller) historyPush(x float64) {
+	ec.pos--
+	if ec.pos < 0 {
+		ec.pos = len(ec.history) - 1
+	}
+	old := ec.history[ec.pos]
+	ec.energy += x*x - old*old
+	if ec.energy < 0 {
+		ec.energy = 0
+	}
+	ec.history[ec.pos] = x
+}
+
+/* Check whether the near-end sample is of double-talk (Geigel detector) */
+func (ec *EchoCanceller) doubleTalkCheck(near float64) bool {
+	var peak float64
+	for _, x := range ec.history {
+		if x = math.Abs(x); x > peak {
+			peak = x
+		}
+	}
+	if math.Abs(near) > AEC_DOUBLE_TALK_RATIO*peak {
+		ec.hangover = AEC_DOUBLE_TALK_HANGOVER
+	} else if ec.hangover > 0 {
+		ec.hangover--
+	}
+	return ec.hangover > 0
+}
+
+/**
+ * Cancel echo of the far-end in near-end samples (in place).
+ * The reference written so far is consumed sample by sample, silence is assumed if it is short.
+ * @param samples the near-end samples
+ */
+func (ec *EchoCanceller) EchoCancellerProcess(samples []int16) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+	taps := len(ec.weights)
+	for n, sample := range samples {
+		var x float64
+		if n < len(ec.reference) {
+			x = float64(ec.reference[n])
+		}
+		ec.historyPush(x)
+
+		/* estimate echo by the far-end history */
+		var echo float64
+		for i := 0; i < taps; i++ {
+			echo += ec.weights[i] * ec.history[(ec.pos+i)%taps]
+		}
+		near := float64(sample)
+		e := near - echo
+		samples[n] = timeStretchSaturate(e)
+
+		if ec.energy > 0 && !ec.doubleTalkCheck(near) {
+			step := AEC_STEP_SIZE * e / (ec.energy + 1)
+			for i := 0; i < taps; i++ {
+				ec.weights[i] += step * ec.history[(ec.pos+i)%taps]
+			}
+		}
+	}
+	if len(samples) < len(ec.reference) {
+		ec.reference = append(ec.reference[:0], ec.reference[len(samples):]...)
+	} else {
+		ec.reference = ec.reference[:0]
+	}
+}
+
+/* Stream tapping the far-end audio as reference of echo canceller */
+type echoReferenceStream struct {
+	base   *AudioStream
+	source *AudioStream
+	aec    *EchoCanceller
+}
+
+/* Stream cancelling echo of the near-end audio read */
+type echoCaptureStream struct {
+	base   *AudioStream
+	source *AudioStream
+	aec    *EchoCanceller
+}
+
+/* Stream cancelling echo of the near-end audio written */
+type echoCancelStream struct {
+	base *AudioStream
+	sink *AudioStream
+	aec  *EchoCanceller
+}
+
+/* Get linear samples of audio frame, nil if the frame has no audio */
+func echoFrameSamplesGet(frame *Frame) ([]int16, error) {
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		return nil, nil
+	}
+	return binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+}
+
+/**
+ * Create stream tapping the audio read from the (linear) source, e.g. of the synthesizer prompt,
+ * as the far-end reference of echo canceller.
+ * @param source the source played out to the far-end device
+ * @param aec the echo canceller
+ */
+func EchoCancellerReferenceStreamCreate(source *AudioStream, aec *EchoCanceller) *AudioStream {
+	if source == nil || aec == nil {
+		return nil
+	}
+	stream := &echoReferenceStream{source: source, aec: aec}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			if err := source.AudioStreamFrameRead(frame); err != nil {
+				return err
+			}
+			samples, err := echoFrameSamplesGet(frame)
+			if err != nil {
+				return err
+			}
+			aec.EchoCancellerReferenceWrite(samples)
+			return nil
+		},
+	}
+	stream.base = AudioStreamCreate(stream, vtable, StreamCapabilitiesClone(source.Capabilities))
+	if stream.base == nil {
+		return nil
+	}
+	stream.base.RXDescriptor = source.RXDescriptor
+	stream.base.RXEventDescriptor = source.RXEventDescriptor
+	return stream.base
+}
+
+/**
+ * Create stream cancelling echo in the audio written to the (linear) sink, e.g. of the recognizer,
+ * by the reference tapped in the same context.
+ * @param sink the sink of the audio captured by the far-end device
+ * @param aec the echo canceller
+ */
+func EchoCancellerStreamCreate(sink *AudioStream, aec *EchoCanceller) *AudioStream {
+	if sink == nil || aec == nil {
+		return nil
+	}
+	stream := &echoCancelStream{sink: sink, aec: aec}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(sink) },
+		OpenTX:  func(_ *AudioStream, codec *Codec) error { return sink.AudioStreamTXOpen(codec) },
+		CloseTX: func(*AudioStream) error { return sink.AudioStreamTXClose() },
+		WriteFrame: func(_ *AudioStream, frame *Frame) error {
+			samples, err := echoFrameSamplesGet(frame)
+			if err != nil {
+				return err
+			}
+			if len(samples) > 0 {
+				aec.EchoCancellerProcess(samples)
+				if err := codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples)); err != nil {
+					return err
+				}
+			}
+			return sink.AudioStreamFrameWrite(frame)
+		},
+	}
+	stream.base = AudioStreamCreate(stream, vtable, StreamCapabilitiesClone(sink.Capabilities))
+	if stream.base == nil {
+		return nil
+	}
+	stream.base.TXDescriptor = sink.TXDescriptor
+	stream.base.TXEventDescriptor = sink.TXEventDescriptor
+	stream.base.FrameWriteInPlace = true
+	return stream.base
+}
+
+/**
+ * Create stream cancelling echo in the audio read from the (linear) source capturing the near-end,
+ * e.g. of RTP termination, so that the echo-cancelled audio is output to any sink (or sinks) of the source.
+ * @param source the source of the audio captured by the far-end device
+ * @param aec the echo canceller
+ */
+func EchoCancellerCaptureStreamCreate(source *AudioStream, aec *EchoCanceller) *AudioStream {
+	if source == nil || aec == nil {
+		return nil
+	}
+	stream := &echoCaptureStream{source: source, aec: aec}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			if err := source.AudioStreamFrameRead(frame); err != nil {
+				return err
+			}
+			samples, err := echoFrameSamplesGet(frame)
+			if err != nil || len(samples) == 0 {
+				return err
+			}
+			aec.EchoCancellerProcess(samples)
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}
+	stream.base = AudioStreamCreate(stream, vtable, StreamCapabilitiesClone(source.Capabilities))
+	if stream.base == nil {
+		return nil
+	}
+	stream.base.RXDescriptor = source.RXDescriptor
+	stream.base.RXEventDescriptor = source.RXEventDescriptor
+	return stream.base
+}
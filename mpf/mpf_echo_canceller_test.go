@@ -0,0 +1,206 @@
+package mpf
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestEchoCanceller(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	aec, err := EchoCancellerCreate(descriptor, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(1))
+	var played []int16
+
+	/* the prompt (noise-like) is played out to the far-end device */
+	prompt := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			samples := make([]int16, 80)
+			for i := range samples {
+				samples[i] = int16(random.NormFloat64() * 4000)
+			}
+			played = append(played, samples...)
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	prompt.RXDescriptor = descriptor
+
+	var residual, echoEnergy float64
+	var received int
+	recognizer := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			received++
+			if received > 250 {
+				for _, sample := range samples {
+					residual += float64(sample) * float64(sample)
+				}
+			}
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	recognizer.TXDescriptor = descriptor
+
+	reference := EchoCancellerReferenceStreamCreate(prompt, aec)
+	cancel := EchoCancellerStreamCreate(recognizer, aec)
+	frame := &Frame{}
+	for tick := 0; tick < 300; tick++ {
+		if err := reference.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		/* the echo of the prompt leaks into the microphone path delayed and attenuated */
+		echo := make([]int16, 80)
+		for i := range echo {
+			n := tick*80 + i
+			var y float64
+			if n >= 20 {
+				y += 0.3 * float64(played[n-20])
+			}
+			if n >= 21 {
+				y -= 0.1 * float64(played[n-21])
+			}
+			echo[i] = int16(y)
+			if tick >= 250 {
+				echoEnergy += y * y
+			}
+		}
+		near := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&near.CodecFrame, binaryx.Int16SliceToByteSlice(echo))
+		if err := cancel.AudioStreamFrameWrite(near); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if erle := 10 * math.Log10(echoEnergy/(residual+1)); erle < 20 {
+		t.Fatalf("echo return loss enhancement %.1f dB, want at least 20 dB", erle)
+	}
+}
+
+func TestEchoCancellerDoubleTalk(t *testing.T) {
+	aec, _ := EchoCancellerCreate(CodecLPcmDescriptorCreate(8000, 1), 16)
+	/* near-end speech without far-end audio passes through unchanged */
+	speech := loudnessTestSine(300, 8000, 10, 8000)
+	near := append([]int16(nil), speech...)
+	aec.EchoCancellerProcess(near)
+	for i := range near {
+		if near[i] != speech[i] {
+			t.Fatalf("near-end speech is altered at %d: %d, want %d", i, near[i], speech[i])
+		}
+	}
+	/* loud near-end speech over the far-end audio freezes adaptation */
+	aec.EchoCancellerReferenceWrite(loudnessTestSine(1000, 8000, 10, 1000))
+	near = append([]int16(nil), speech...)
+	aec.EchoCancellerProcess(near)
+	for _, w := range aec.weights {
+		if w != 0 {
+			t.Fatalf("echo path is adapted during double-talk")
+		}
+	}
+}
+
+func TestContextEchoCanceller(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	aec, _ := EchoCancellerCreate(descriptor, 16)
+	random := rand.New(rand.NewSource(1))
+	var played []int16
+
+	/* the far-end device echoes the prompt played (sent) into the audio captured (received) */
+	rtpStream := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			echo := make([]int16, 80)
+			for i := range echo {
+				if n := len(played) - 80 + i - 20; n >= 0 {
+					echo[i] = int16(0.3 * float64(played[n]))
+				}
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(echo))
+		},
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			played = append(played, samples...)
+			return err
+		},
+	}, StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX))
+	rtpStream.RXDescriptor, rtpStream.TXDescriptor = descriptor, descriptor
+
+	promptStream := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			samples := make([]int16, 80)
+			for i := range samples {
+				samples[i] = int16(random.NormFloat64() * 4000)
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	promptStream.RXDescriptor = descriptor
+
+	var residual, echo float64
+	var received int
+	recognizerStream := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			if received++; received > 250 {
+				for i, sample := range samples {
+					residual += float64(sample) * float64(sample)
+					y := float64(int16(0.3 * float64(played[len(played)-80+i-20])))
+					echo += y * y
+				}
+			}
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	recognizerStream.TXDescriptor = descriptor
+
+	/* the capture termination precedes the reference one, yet the reference is tapped first */
+	context := ContextFactoryCreate().ContextCreate("aec", nil, 3)
+	rtp := TerminationBaseCreate(nil, nil, nil, rtpStream, nil)
+	prompt := TerminationBaseCreate(nil, nil, nil, promptStream, nil)
+	recognizer := TerminationBaseCreate(nil, nil, nil, recognizerStream, nil)
+	for _, termination := range []*Termination{rtp, prompt, recognizer} {
+		termination.codecManager = CodecManagerDefaultCreate()
+	}
+	context.ContextTerminationAdd(rtp)
+	context.ContextTerminationAdd(prompt)
+	context.ContextTerminationAdd(recognizer)
+	context.ContextAssociationAdd(prompt, rtp)
+	context.ContextAssociationAdd(rtp, recognizer)
+	if err := context.ContextEchoCancellerAdd(prompt, rtp, aec); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextEchoCancellerAdd(recognizer, rtp, aec); err == nil {
+		t.Fatalf("echo of termination is cancelled twice")
+	}
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	for tick := 0; tick < 300; tick++ {
+		if err := context.ContextProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if erle := 10 * math.Log10(echo/(residual+1)); erle < 20 {
+		t.Fatalf("echo return loss enhancement %.1f dB, want at least 20 dB", erle)
+	}
+
+	/* the echo is passed through once the canceller is removed */
+	if err := context.ContextEchoCancellerRemove(rtp); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	residual, echo = 0, 0
+	for tick := 0; tick < 10; tick++ {
+		context.ContextProcess()
+	}
+	if residual != echo {
+		t.Fatalf("echo %.0f is altered to %.0f without canceller", echo, residual)
+	}
+}
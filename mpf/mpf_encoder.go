@@ -1,5 +1,206 @@
 package mpf
 
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+type Encoder struct {
+	Base     *AudioStream
+	Sink     *AudioStream
+	Codec    *Codec
+	FrameOut Frame
+	/** Payload type registry of the session (optional) */
+	Registry *RtpPayloadTypeRegistry
+
+	/** Detector of silence sent as comfort noise (nil - CN is disabled) */
+	cnDetector *ActivityDetector
+	/** Noise level last sent by CN, -1 if speech is sent */
+	cnLevel int
+}
+
+func EncoderDestroy(stream *AudioStream) error {
+	encoder := stream.Obj.(*Encoder)
+	return AudioStreamDestroy(encoder.Sink)
+}
+
+func EncoderOpen(stream *AudioStream, codec *Codec) error {
+	encoder := stream.Obj.(*Encoder)
+	err := encoder.Codec.CodecOpen()
+	if err != nil {
+		return err
+	}
+	return encoder.Sink.AudioStreamTXOpen(encoder.Codec)
+}
+
+func EncoderClose(stream *AudioStream) error {
+	encoder := stream.Obj.(*Encoder)
+	err := encoder.Codec.CodecClose()
+	if err != nil {
+		return err
+	}
+	return encoder.Sink.AudioStreamTXClose()
+}
+
+/* Switch encoder to the codec of the sink descriptor renegotiated, the sink is re-opened with it */
+func EncoderCodecUpdate(stream *AudioStream, codec *Codec) error {
+	encoder := stream.Obj.(*Encoder)
+	if codec == nil {
+		return fmt.Errorf("codec is nil")
+	}
+	if err := encoder.Codec.CodecClose(); err != nil {
+		return err
+	}
+	encoder.Codec = codec
+	if err := encoder.Codec.CodecOpen(); err != nil {
+		return err
+	}
+	encoder.FrameOut.CodecFrame.Size = codec.CodecFrameSizeGet(encoder.Sink.TXDescriptor)
+	encoder.Base.TXDescriptor = CodecLPcmDescriptorCreate(encoder.Sink.TXDescriptor.SamplingRate, encoder.Sink.TXDescriptor.ChannelCount)
+	encoder.Base.TXDescriptor.FrameDuration = encoder.Sink.TXDescriptor.FrameDuration
+	encoder.Base.TXEventDescriptor = encoder.Sink.TXEventDescriptor
+	return encoder.Sink.AudioStreamCodecUpdate(codec)
+}
+
+func EncoderProcess(stream *AudioStream, frame *Frame) error {
+	encoder := stream.Obj.(*Encoder)
+	encoder.FrameOut.Type = frame.Type
+	encoder.FrameOut.Marker = frame.Marker
+	encoder.FrameOut.AudioLevel = frame.AudioLevel
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && (frame.Type&MEDIA_FRAME_TYPE_EVENT) != MEDIA_FRAME_TYPE_EVENT {
+		sent, err := encoder.comfortNoiseProcess(frame)
+		if err != nil || sent {
+			return err
+		}
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		encoder.FrameOut.PayloadType = encoder.payloadTypeGet(encoder.Sink.TXDescriptor)
+		if !frame.AudioLevel.Present {
+			/* the level is measured while the audio is linear, to be signalled along with the encoded one (RFC 6464) */
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			if err != nil {
+				return err
+			}
+			encoder.FrameOut.AudioLevel = FrameAudioLevel{Present: true, Level: RtpAudioLevelCalculate(samples)}
+		}
+		/* the size is updated by the codec to the encoded one, restore the expected one */
+		encoder.FrameOut.CodecFrame.Size = encoder.Codec.CodecFrameSizeGet(encoder.Sink.TXDescriptor)
+		if err := encoder.Codec.CodecEncode(&frame.CodecFrame, &encoder.FrameOut.CodecFrame); err != nil {
+			return err
+		}
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		/* named events take precedence over audio in RTP */
+		encoder.FrameOut.EventFrame = frame.EventFrame
+		encoder.FrameOut.PayloadType = encoder.payloadTypeGet(encoder.Sink.TXEventDescriptor)
+	}
+	return encoder.Sink.AudioStreamFrameWrite(&encoder.FrameOut)
+}
+
+/*
+ * Send silent frame as comfort noise: CN frame is sent at the start of silence and whenever
+ * the noise level changes, no frames are sent in between (DTX).
+ * Return true if the frame is processed as silence.
+ */
+func (encoder *Encoder) comfortNoiseProcess(frame *Frame) (bool, error) {
+	if encoder.cnDetector == nil {
+		return false, nil
+	}
+	level, err := encoder.cnDetector.ActivityDetectorLevelCalculate(frame)
+	if err != nil {
+		return false, err
+	}
+	if level >= encoder.cnDetector.LevelThreshold {
+		encoder.cnLevel = -1
+		return false, nil
+	}
+	payloadType := encoder.cnPayloadTypeGet()
+	if payloadType == RTP_PT_UNKNOWN {
+		/* CN is not negotiated, silence is sent as audio */
+		return false, nil
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+	if err != nil {
+		return false, err
+	}
+	cnLevel := int(CnLevelCalculate(samples))
+	if encoder.cnLevel >= 0 && cnLevel-encoder.cnLevel < CN_LEVEL_HYSTERESIS && encoder.cnLevel-cnLevel < CN_LEVEL_HYSTERESIS {
+		encoder.FrameOut.Type = MEDIA_FRAME_TYPE_NONE
+		return true, encoder.Sink.AudioStreamFrameWrite(&encoder.FrameOut)
+	}
+	encoder.cnLevel = cnLevel
+	encoder.FrameOut.PayloadType = payloadType
+	if err := codecFrameDataSet(&encoder.FrameOut.CodecFrame, []byte{byte(cnLevel)}); err != nil {
+		return false, err
+	}
+	return true, encoder.Sink.AudioStreamFrameWrite(&encoder.FrameOut)
+}
+
+/* Get payload type of comfort noise at the sampling rate of the sink, the static one is of 8 kHz only */
+func (encoder *Encoder) cnPayloadTypeGet() RtpPayloadType {
+	samplingRate := encoder.Sink.TXDescriptor.SamplingRate
+	if encoder.Registry != nil {
+		descriptor := &CodecDescriptor{PayloadType: RTP_PT_UNKNOWN, Name: CN_CODEC_NAME, SamplingRate: samplingRate, ChannelCount: 1}
+		if payloadType := encoder.Registry.RtpPayloadTypeFind(descriptor); payloadType != RTP_PT_UNKNOWN {
+			return payloadType
+		}
+	}
+	if samplingRate == 8000 {
+		return RTP_PT_CN
+	}
+	return RTP_PT_UNKNOWN
+}
+
+/**
+ * Enable sending of silence as comfort noise (RFC 3389) by encoder.
+ * @param stream the encoder stream
+ * @param levelThreshold the level threshold of silence (mean absolute sample value), negative to disable CN
+ */
+func EncoderComfortNoiseSet(stream *AudioStream, levelThreshold int64) error {
+	encoder, ok := stream.Obj.(*Encoder)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *Encoder")
+	}
+	encoder.cnLevel = -1
+	if levelThreshold < 0 {
+		encoder.cnDetector = nil
+		return nil
+	}
+	encoder.cnDetector = ActivityDetectorCreate()
+	encoder.cnDetector.ActivityDetectorLevelSet(levelThreshold)
+	return nil
+}
+
+/* Get payload type to send frames of the descriptor with, resolved by the registry if set */
+func (encoder *Encoder) payloadTypeGet(descriptor *CodecDescriptor) RtpPayloadType {
+	if descriptor == nil {
+		return RTP_PT_UNKNOWN
+	}
+	if encoder.Registry != nil {
+		if payloadType := encoder.Registry.RtpPayloadTypeFind(descriptor); payloadType != RTP_PT_UNKNOWN {
+			return payloadType
+		}
+	}
+	return descriptor.PayloadType
+}
+
+/**
+ * Set payload type registry of the session to encoder.
+ * Payload types of the output frames are resolved by it.
+ * @param stream the encoder stream
+ * @param registry the registry to set
+ */
+func EncoderPayloadTypeRegistrySet(stream *AudioStream, registry *RtpPayloadTypeRegistry) error {
+	encoder, ok := stream.Obj.(*Encoder)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *Encoder")
+	}
+	encoder.Registry = registry
+	return nil
+}
+
 /**
  * Create audio stream encoder.
  * @param sink the sink to write encoded stream to
@@ -7,5 +208,39 @@ package mpf
  * @param pool the pool to allocate memory from
  */
 func EncoderCreate(sink *AudioStream, codec *Codec) *AudioStream {
-	return nil
+	if sink == nil || codec == nil {
+		return nil
+	}
+
+	var vtable = AudioStreamVTable{
+		Destroy:    EncoderDestroy,
+		OpenRX:     nil,
+		CloseRX:    nil,
+		ReadFrame:  nil,
+		OpenTX:     EncoderOpen,
+		CloseTX:    EncoderClose,
+		WriteFrame: EncoderProcess,
+		Trace:      nil,
+
+		UpdateCodec: EncoderCodecUpdate,
+	}
+
+	encoder := new(Encoder)
+	capabilities := StreamCapabilitiesCreate(STREAM_DIRECTION_SEND)
+	encoder.Base = AudioStreamCreate(encoder, &vtable, capabilities)
+	if encoder.Base == nil {
+		return nil
+	}
+	encoder.Base.TXDescriptor = CodecLPcmDescriptorCreate(sink.TXDescriptor.SamplingRate, sink.TXDescriptor.ChannelCount)
+	encoder.Base.TXDescriptor.FrameDuration = sink.TXDescriptor.FrameDuration
+	encoder.Base.TXEventDescriptor = sink.TXEventDescriptor
+
+	encoder.Sink = sink
+	encoder.Codec = codec
+
+	frameSize := codec.CodecFrameSizeGet(sink.TXDescriptor)
+	encoder.FrameOut.CodecFrame.Size = frameSize
+	encoder.FrameOut.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0, frameSize))
+
+	return encoder.Base
 }
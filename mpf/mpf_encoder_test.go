@@ -0,0 +1,60 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncoderBridge(t *testing.T) {
+	var written []Frame
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			if len(written) == 1 {
+				frame.Type |= MEDIA_FRAME_TYPE_EVENT
+				frame.Marker = MPF_MARKER_START_OF_EVENT
+				frame.EventFrame.EventId = 5
+			}
+			return codecFrameDataSet(&frame.CodecFrame, make([]byte, 160))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			out := *frame
+			out.CodecFrame.Buffer = bytes.NewBuffer(append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...))
+			written = append(written, out)
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}
+	sink.TXEventDescriptor = &CodecDescriptor{PayloadType: 101, Name: MPF_EVENT_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1}
+
+	manager := CodecManagerCreate(1)
+	if err := manager.CodecManagerCodecRegister(CodecG711UCreate()); err != nil {
+		t.Fatal(err)
+	}
+	bridge, err := BridgeCreate(source, sink, manager, "encoder")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := bridge.ObjectProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(written) != 2 {
+		t.Fatalf("%d frames written, want 2", len(written))
+	}
+	if data := written[0].CodecFrame.Buffer.Bytes(); len(data) != 80 || data[0] != G711U_SILENCE {
+		t.Fatalf("frame is not encoded by PCMU: %d bytes", len(data))
+	}
+	if written[0].PayloadType != RTP_PT_PCMU {
+		t.Fatalf("payload type %d, want PCMU", written[0].PayloadType)
+	}
+	event := written[1]
+	if event.Type&MEDIA_FRAME_TYPE_EVENT == 0 || event.EventFrame.EventId != 5 || event.Marker != MPF_MARKER_START_OF_EVENT || event.PayloadType != 101 {
+		t.Fatalf("event frame is not passed through: %+v", event)
+	}
+}
@@ -1,12 +1,16 @@
 package mpf
 
 import (
+	"fmt"
+
 	"github.com/eapache/queue"
 	"github.com/navi-tt/go-mrcp/toolkit"
 	"sync"
 )
 
 type Engine struct {
+	id                string
+	frameDuration     int64
 	Task              *toolkit.TaskMsg
 	TaskMsgType       toolkit.TaskMsgType
 	requestQueueGuard sync.Mutex
@@ -23,15 +27,61 @@ type Engine struct {
 * @param pool the pool to allocate memory from
  */
 func EngineCreate(id string) *Engine {
+	engine := &Engine{
+		id:             id,
+		frameDuration:  CODEC_FRAME_TIME_BASE,
+		RequestQueue:   queue.New(),
+		contextFactory: ContextFactoryCreate(),
+		scheduler:      SchedulerCreate(),
+	}
+	if err := engine.scheduler.SchedulerMediaClockSet(CODEC_FRAME_TIME_BASE, engineMediaProcess, engine); err != nil {
+		return nil
+	}
+	return engine
+}
+
+/* Process contexts of engine every media clock tick */
+func engineMediaProcess(scheduler *Scheduler, obj interface{}) {
+	engine := obj.(*Engine)
+	_ = ContextFactoryProcess(engine.contextFactory)
+}
+
+/**
+* Set frame duration of the media processed by engine, propagated to codec frame sizes, DTMF timing,
+* RTP timestamps of terminations added afterwards and to the media clock of scheduler.
+* Should be set before contexts are created (e.g. as soon as the engine is created), some codecs are
+* more efficient at 20 msec.
+* @param engine the engine to set frame duration for
+* @param duration the frame duration in msec (10, 20 or 30)
+ */
+func (engine *Engine) EngineFrameDurationSet(duration int64) error {
+	if err := FrameDurationValidate(duration); err != nil {
+		return err
+	}
+	if err := engine.scheduler.SchedulerMediaClockSet(uint64(duration), engineMediaProcess, engine); err != nil {
+		return err
+	}
+	if err := engine.contextFactory.ContextFactoryFrameDurationSet(duration); err != nil {
+		return err
+	}
+	engine.frameDuration = duration
 	return nil
 }
 
+/**
+* Get frame duration of the media processed by engine in msec.
+* @param engine the engine to get frame duration of
+ */
+func (engine *Engine) EngineFrameDurationGet() int64 {
+	return engine.frameDuration
+}
+
 /**
 * Create MPF codec manager.
 * @param pool the pool to allocate memory from
  */
 func EngineCodecManagerCreate() *CodecManager {
-	return nil
+	return CodecManagerDefaultCreate()
 }
 
 /**
@@ -40,6 +90,10 @@ func EngineCodecManagerCreate() *CodecManager {
 * @param codec_manager the codec manager to register
  */
 func (engine *Engine) EngineCodecManagerRegister(codecManager *CodecManager) error {
+	if codecManager == nil {
+		return fmt.Errorf("codec manager is nil")
+	}
+	engine.CodecManager = codecManager
 	return nil
 }
 
@@ -52,7 +106,7 @@ func (engine *Engine) EngineCodecManagerRegister(codecManager *CodecManager) err
 * @param pool the pool to allocate memory from
  */
 func (engine *Engine) EngineContextCreate(name string, obj interface{}, maxTerminationCount uint32) *Context {
-	return nil
+	return engine.contextFactory.ContextCreate(name, obj, int64(maxTerminationCount))
 }
 
 /**
@@ -139,7 +193,7 @@ func (engine *Engine) EngineMessageSend(taskMsg *toolkit.TaskMsg) error {
 * @param rate the rate (n times faster than real-time)
  */
 func (engine *Engine) EngineSchedulerRateSet(rate uint64) {
-
+	_ = engine.scheduler.SchedulerRateSet(rate)
 }
 
 /**
@@ -147,5 +201,5 @@ func (engine *Engine) EngineSchedulerRateSet(rate uint64) {
 * @param engine the engine to get name of
  */
 func (engine *Engine) EngineIdGet() string {
-	return ""
+	return engine.id
 }
@@ -0,0 +1,85 @@
+package mpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEngineFrameDuration(t *testing.T) {
+	engine := EngineCreate("frame-duration")
+	if err := engine.EngineFrameDurationSet(25); err == nil {
+		t.Fatalf("frame duration of 25 msec is set")
+	}
+	if err := engine.EngineFrameDurationSet(20); err != nil {
+		t.Fatal(err)
+	}
+	context := engine.EngineContextCreate("ctx", nil, 1)
+	tone, _ := ToneCreate(TONE_PATTERN_DIAL)
+	termination, err := ToneTerminationCreate(tone, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !context.ContextTerminationAdd(termination) {
+		t.Fatalf("termination is not added")
+	}
+	source := termination.TerminationAudioStreamGet()
+	if duration := source.RXDescriptor.CodecFrameDurationGet(); duration != 20 {
+		t.Fatalf("frame duration %d msec", duration)
+	}
+	frame := &Frame{}
+	if err := source.AudioStreamFrameRead(frame); err != nil {
+		t.Fatal(err)
+	}
+	if size := len(codecFrameDataGet(&frame.CodecFrame)); int64(size) != source.RXDescriptor.CodecLinearFrameSizeGet() || size != 320 {
+		t.Fatalf("frame of %d bytes", size)
+	}
+
+	for _, test := range []struct {
+		descriptor *CodecDescriptor
+		duration   int64
+		samples    int64
+		timestamp  int64
+	}{
+		{&CodecDescriptor{Name: G711U_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1}, 0, 80, 80},
+		{&CodecDescriptor{Name: G711U_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1}, 30, 240, 240},
+		{&CodecDescriptor{Name: G722_CODEC_NAME, SamplingRate: 16000, ChannelCount: 1}, 20, 320, 160},
+	} {
+		test.descriptor.FrameDuration = test.duration
+		if samples := test.descriptor.CodecFrameSamplesCalculate(); samples != test.samples {
+			t.Fatalf("%s/%d: %d samples", test.descriptor.Name, test.duration, samples)
+		}
+		if timestamp := test.descriptor.CodecFrameTimestampCalculate(); timestamp != test.timestamp {
+			t.Fatalf("%s/%d: timestamp increment %d", test.descriptor.Name, test.duration, timestamp)
+		}
+	}
+}
+
+func TestSchedulerMediaClock(t *testing.T) {
+	scheduler := SchedulerCreate()
+	if err := scheduler.SchedulerMediaClockSet(15, nil, nil); err == nil {
+		t.Fatalf("media clock of 15 msec is set")
+	}
+	ticks := make(chan struct{}, 16)
+	if err := scheduler.SchedulerMediaClockSet(20, func(scheduler *Scheduler, obj interface{}) {
+		select {
+		case ticks <- struct{}{}:
+		default:
+		}
+	}, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.SchedulerRateSet(10); err != nil {
+		t.Fatal(err)
+	}
+	if err := scheduler.SchedulerStart(); err != nil {
+		t.Fatal(err)
+	}
+	defer SchedulerDestroy(scheduler)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ticks:
+		case <-time.After(time.Second):
+			t.Fatalf("media clock does not tick")
+		}
+	}
+}
@@ -1,8 +1,39 @@
 package mpf
 
+import "fmt"
+
+var fileTerminationVTable = TerminationVTable{
+	Destroy:  nil,
+	Add:      FileTerminationModify,
+	Modify:   FileTerminationModify,
+	Subtract: nil,
+}
+
+/** Add or modify file termination by audio file descriptor */
+func FileTerminationModify(termination *Termination, descriptor interface{}) error {
+	if descriptor == nil {
+		return nil
+	}
+	fileDescriptor, ok := descriptor.(*AudioFileDescriptor)
+	if !ok || fileDescriptor == nil {
+		return fmt.Errorf("descriptor is not *AudioFileDescriptor")
+	}
+	if termination.audioStream == nil {
+		return nil
+	}
+	return FileStreamModify(termination.audioStream, fileDescriptor)
+}
+
 /**
  * Create file termination factory.
  */
 func FileTerminationFactoryCreate() *TerminationFactory {
-	return nil
+	return &TerminationFactory{
+		CreateTermination: func(factory *TerminationFactory, obj interface{}) *Termination {
+			termination := TerminationBaseCreate(factory, obj, &fileTerminationVTable, nil, nil)
+			termination.audioStream = FileStreamCreate(termination)
+			return termination
+		},
+		AssignEngine: nil,
+	}
 }
@@ -20,6 +20,16 @@ const (
 	MPF_MARKER_NEW_SEGMENT                       /**< start of new segment (long-lasting events) */
 )
 
+/** Audio level of media frame, signalled by RTP header extension (RFC 6464) */
+type FrameAudioLevel struct {
+	/** the level is known (signalled by the source or measured) */
+	Present bool
+	/** level in -dBov (0 - the loudest, 127 - silence) */
+	Level byte
+	/** the frame contains voice (as told by the source) */
+	Voice bool
+}
+
 /** Media frame */
 type Frame struct {
 	/** frame type (audio/video/named-event) mpf_frame_type_e */
@@ -30,4 +40,29 @@ type Frame struct {
 	CodecFrame CodecFrame
 	/** named-event frame */
 	EventFrame NamedEventFrame
+	/** payload type of RTP packet the frame is received in (set by RTP streams only) */
+	PayloadType RtpPayloadType
+	/** audio level of the frame, not present if unknown */
+	AudioLevel FrameAudioLevel
+}
+
+/**
+ * Copy frame, the data is copied to the buffer of the destination frame (reused if any).
+ * @param dst the frame to copy to
+ * @param src the frame to copy
+ */
+func FrameCopy(dst, src *Frame) error {
+	dst.Type = src.Type
+	dst.Marker = src.Marker
+	dst.EventFrame = src.EventFrame
+	dst.PayloadType = src.PayloadType
+	dst.AudioLevel = src.AudioLevel
+	if src.CodecFrame.Buffer == nil {
+		dst.CodecFrame.Size = src.CodecFrame.Size
+		if dst.CodecFrame.Buffer != nil {
+			dst.CodecFrame.Buffer.Reset()
+		}
+		return nil
+	}
+	return codecFrameDataSet(&dst.CodecFrame, src.CodecFrame.Buffer.Bytes())
 }
@@ -1,9 +1,35 @@
 package mpf
 
-import "sync"
+import (
+	"fmt"
+	"sync"
+)
 
+/** Policy of write to frame buffer full */
+type FrameBufferPolicy = int
+
+const (
+	FRAME_BUFFER_POLICY_DROP_NEWEST FrameBufferPolicy = iota /**< the frame written is dropped */
+	FRAME_BUFFER_POLICY_DROP_OLDEST                          /**< the oldest frame buffered is dropped */
+	FRAME_BUFFER_POLICY_BLOCK                                /**< the writer is blocked till a frame is read */
+)
+
+/** Statistics of frame buffer */
+type FrameBufferStat struct {
+	/** Number of frames written */
+	Written uint64
+	/** Number of frames read */
+	Read uint64
+	/** Number of frames dropped since the buffer is full (overruns) */
+	Dropped uint64
+	/** Number of reads of the buffer empty, since the first frame is written */
+	Underruns uint64
+	/** Max level (number of frames buffered) reached */
+	MaxLevel int64
+}
+
+/** Bounded queue of frames decoupling clock domains of the producer and the consumer */
 type FrameBuffer struct {
-	RawData    []byte
 	Frames     []*Frame
 	FrameCount int64
 	FrameSize  int64
@@ -11,30 +37,236 @@ type FrameBuffer struct {
 	WritePos int64
 	ReadPos  int64
 
-	guard sync.Mutex
+	/** Policy of write to buffer full */
+	Policy FrameBufferPolicy
+	/** Level the handler is called at as soon as it falls to */
+	LowWatermark int64
+	/** Level the handler is called at as soon as it rises to */
+	HighWatermark int64
+	/** Called as soon as level rises to high (high = true) or falls to low watermark, e.g. to pause/resume producer [OPTIONAL] */
+	WatermarkHandler func(buffer *FrameBuffer, level int64, high bool)
+
+	stat FrameBufferStat
+	/** Level is above low watermark since high watermark is reached */
+	high bool
+	/** Buffer is destroyed, the writers blocked are released */
+	closed bool
+	cond   *sync.Cond
+	guard  sync.Mutex
 }
 
-/** Create frame buffer */
+/**
+ * Create frame buffer.
+ * @param frameSize the size of frame (informational, frames of any size are buffered)
+ * @param frameCount the max number of frames buffered
+ */
 func FrameBufferCreate(frameSize, frameCount int64) *FrameBuffer {
-	return nil
+	if frameCount <= 0 {
+		return nil
+	}
+	buffer := &FrameBuffer{
+		Frames:        make([]*Frame, frameCount),
+		FrameCount:    frameCount,
+		FrameSize:     frameSize,
+		Policy:        FRAME_BUFFER_POLICY_DROP_NEWEST,
+		HighWatermark: frameCount,
+	}
+	buffer.cond = sync.NewCond(&buffer.guard)
+	return buffer
 }
 
-/** Destroy frame buffer */
+/** Destroy frame buffer, the writers blocked are released */
 func FrameBufferDestroy(buffer *FrameBuffer) error {
+	buffer.guard.Lock()
+	defer buffer.guard.Unlock()
+	buffer.closed = true
+	buffer.cond.Broadcast()
+	return nil
+}
+
+/**
+ * Set watermarks of frame buffer.
+ * @param low the level the handler is called at as soon as it falls to
+ * @param high the level the handler is called at as soon as it rises to
+ * @param handler the handler
+ */
+func (buffer *FrameBuffer) FrameBufferWatermarksSet(low, high int64, handler func(buffer *FrameBuffer, level int64, high bool)) error {
+	if low < 0 || low >= high || high > buffer.FrameCount {
+		return fmt.Errorf("invalid watermarks %d/%d of frame buffer of %d frames", low, high, buffer.FrameCount)
+	}
+	buffer.guard.Lock()
+	defer buffer.guard.Unlock()
+	buffer.LowWatermark = low
+	buffer.HighWatermark = high
+	buffer.WatermarkHandler = handler
 	return nil
 }
 
-/** Restart frame buffer */
+/** Restart frame buffer, the frames buffered are discarded */
 func (buffer *FrameBuffer) FrameBufferRestart() error {
+	buffer.guard.Lock()
+	defer buffer.guard.Unlock()
+	for i := range buffer.Frames {
+		buffer.Frames[i] = nil
+	}
+	buffer.WritePos = 0
+	buffer.ReadPos = 0
+	buffer.high = false
+	buffer.cond.Broadcast()
 	return nil
 }
 
-/** Write frame to buffer */
+/* Check watermarks as soon as level is changed, the handler is returned to be called unlocked */
+func (buffer *FrameBuffer) watermarkCheck() func() {
+	level := buffer.WritePos - buffer.ReadPos
+	if level > buffer.stat.MaxLevel {
+		buffer.stat.MaxLevel = level
+	}
+	crossed := false
+	if !buffer.high && level >= buffer.HighWatermark {
+		buffer.high, crossed = true, true
+	} else if buffer.high && level <= buffer.LowWatermark {
+		buffer.high, crossed = false, true
+	}
+	handler, high := buffer.WatermarkHandler, buffer.high
+	if !crossed || handler == nil {
+		return nil
+	}
+	return func() {
+		handler(buffer, level, high)
+	}
+}
+
+/**
+ * Write (copy) frame to buffer, the frame is dropped or the writer is blocked if the buffer is full depending on policy.
+ * @remark The context must not write to buffer of FRAME_BUFFER_POLICY_BLOCK.
+ */
 func (buffer *FrameBuffer) FrameBufferWrite(frame *Frame) error {
+	buffer.guard.Lock()
+	for buffer.Policy == FRAME_BUFFER_POLICY_BLOCK && buffer.WritePos-buffer.ReadPos >= buffer.FrameCount && !buffer.closed {
+		buffer.cond.Wait()
+	}
+	if buffer.closed {
+		buffer.guard.Unlock()
+		return fmt.Errorf("frame buffer is destroyed")
+	}
+	if buffer.WritePos-buffer.ReadPos >= buffer.FrameCount {
+		buffer.stat.Dropped++
+		if buffer.Policy != FRAME_BUFFER_POLICY_DROP_OLDEST {
+			buffer.guard.Unlock()
+			return nil
+		}
+		buffer.Frames[buffer.ReadPos%buffer.FrameCount] = nil
+		buffer.ReadPos++
+	}
+	copied := &Frame{
+		Type:        frame.Type,
+		Marker:      frame.Marker,
+		EventFrame:  frame.EventFrame,
+		PayloadType: frame.PayloadType,
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		codecFrameDataSet(&copied.CodecFrame, codecFrameDataGet(&frame.CodecFrame))
+	}
+	buffer.Frames[buffer.WritePos%buffer.FrameCount] = copied
+	buffer.WritePos++
+	buffer.stat.Written++
+	notify := buffer.watermarkCheck()
+	buffer.guard.Unlock()
+	if notify != nil {
+		notify()
+	}
 	return nil
 }
 
-/** Read frame from buffer */
+/** Read frame from buffer, no frame (MEDIA_FRAME_TYPE_NONE) is read if the buffer is empty */
 func (buffer *FrameBuffer) FrameBufferRead(frame *Frame) error {
+	buffer.guard.Lock()
+	if buffer.WritePos == buffer.ReadPos {
+		if buffer.stat.Written > 0 {
+			buffer.stat.Underruns++
+		}
+		buffer.guard.Unlock()
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		return nil
+	}
+	index := buffer.ReadPos % buffer.FrameCount
+	buffered := buffer.Frames[index]
+	buffer.Frames[index] = nil
+	buffer.ReadPos++
+	buffer.stat.Read++
+	buffer.cond.Broadcast()
+	notify := buffer.watermarkCheck()
+	buffer.guard.Unlock()
+
+	frame.Type = buffered.Type
+	frame.Marker = buffered.Marker
+	frame.EventFrame = buffered.EventFrame
+	frame.PayloadType = buffered.PayloadType
+	if (buffered.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		codecFrameDataSet(&frame.CodecFrame, codecFrameDataGet(&buffered.CodecFrame))
+	}
+	if notify != nil {
+		notify()
+	}
 	return nil
 }
+
+/** Get level (number of frames buffered) of buffer */
+func (buffer *FrameBuffer) FrameBufferLevelGet() int64 {
+	buffer.guard.Lock()
+	defer buffer.guard.Unlock()
+	return buffer.WritePos - buffer.ReadPos
+}
+
+/** Get statistics of buffer */
+func (buffer *FrameBuffer) FrameBufferStatGet() FrameBufferStat {
+	buffer.guard.Lock()
+	defer buffer.guard.Unlock()
+	return buffer.stat
+}
+
+/**
+ * Create termination the audio of which is buffered, e.g. produced by TTS engine faster than real-time.
+ * The context reads frames of the receive buffer and writes frames to the transmit buffer,
+ * the frames are written to the receive buffer and read of the transmit buffer in other clock domain.
+ * @param rxBuffer the buffer the context reads frames of (may be nil)
+ * @param txBuffer the buffer the context writes frames to (may be nil)
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ */
+func FrameBufferTerminationCreate(rxBuffer, txBuffer *FrameBuffer, descriptor *CodecDescriptor) (*Termination, error) {
+	if rxBuffer == nil && txBuffer == nil {
+		return nil, fmt.Errorf("no frame buffer")
+	}
+	if txBuffer != nil && txBuffer.Policy == FRAME_BUFFER_POLICY_BLOCK {
+		return nil, fmt.Errorf("frame buffer written by context must not block")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	vtable := &AudioStreamVTable{}
+	direction := STREAM_DIRECTION_NONE
+	if rxBuffer != nil {
+		direction |= STREAM_DIRECTION_RECEIVE
+		vtable.ReadFrame = func(stream *AudioStream, frame *Frame) error {
+			return rxBuffer.FrameBufferRead(frame)
+		}
+	}
+	if txBuffer != nil {
+		direction |= STREAM_DIRECTION_SEND
+		vtable.WriteFrame = func(stream *AudioStream, frame *Frame) error {
+			return txBuffer.FrameBufferWrite(frame)
+		}
+	}
+	audioStream := AudioStreamCreate(nil, vtable, StreamCapabilitiesCreate(direction))
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	if rxBuffer != nil {
+		audioStream.RXDescriptor = descriptor
+	}
+	if txBuffer != nil {
+		audioStream.TXDescriptor = descriptor
+	}
+	return TerminationBaseCreate(nil, nil, nil, audioStream, nil), nil
+}
@@ -0,0 +1,91 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func frameBufferTestFrame(value byte) *Frame {
+	frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+	codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{value}, 160))
+	return frame
+}
+
+func TestFrameBufferPolicies(t *testing.T) {
+	for _, test := range []struct {
+		policy FrameBufferPolicy
+		first  byte
+	}{
+		{FRAME_BUFFER_POLICY_DROP_NEWEST, 0},
+		{FRAME_BUFFER_POLICY_DROP_OLDEST, 2},
+	} {
+		buffer := FrameBufferCreate(160, 3)
+		buffer.Policy = test.policy
+		for i := 0; i < 5; i++ {
+			if err := buffer.FrameBufferWrite(frameBufferTestFrame(byte(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+		frame := &Frame{}
+		buffer.FrameBufferRead(frame)
+		if data := codecFrameDataGet(&frame.CodecFrame); data[0] != test.first {
+			t.Fatalf("policy %d: frame %d read first, want %d", test.policy, data[0], test.first)
+		}
+		if stat := buffer.FrameBufferStatGet(); stat.Dropped != 2 || stat.MaxLevel != 3 || buffer.FrameBufferLevelGet() != 2 {
+			t.Fatalf("policy %d: %+v", test.policy, stat)
+		}
+	}
+}
+
+func TestFrameBufferTermination(t *testing.T) {
+	buffer := FrameBufferCreate(160, 4)
+	buffer.Policy = FRAME_BUFFER_POLICY_BLOCK
+	var marks []bool
+	if err := buffer.FrameBufferWatermarksSet(1, 4, func(buffer *FrameBuffer, level int64, high bool) {
+		marks = append(marks, high)
+	}); err != nil {
+		t.Fatal(err)
+	}
+	termination, err := FrameBufferTerminationCreate(buffer, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := termination.TerminationAudioStreamGet()
+
+	/* the producer faster than real-time is blocked as soon as the buffer is full */
+	produced := make(chan struct{})
+	go func() {
+		defer close(produced)
+		for i := 0; i < 6; i++ {
+			buffer.FrameBufferWrite(frameBufferTestFrame(byte(i)))
+		}
+	}()
+	for buffer.FrameBufferLevelGet() < 4 {
+		time.Sleep(time.Millisecond)
+	}
+	for i := 0; i < 7; i++ {
+		frame := &Frame{}
+		if err := stream.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if i == 6 {
+			if frame.Type != MEDIA_FRAME_TYPE_NONE {
+				t.Fatalf("frame is read of empty buffer")
+			}
+			break
+		}
+		if data := codecFrameDataGet(&frame.CodecFrame); frame.Type != MEDIA_FRAME_TYPE_AUDIO || data[0] != byte(i) {
+			t.Fatalf("frame %d: type %d, %v", i, frame.Type, data)
+		}
+		if i == 1 {
+			<-produced
+		}
+	}
+	if stat := buffer.FrameBufferStatGet(); stat.Dropped != 0 || stat.Underruns != 1 || stat.Read != 6 {
+		t.Fatalf("%+v", stat)
+	}
+	if len(marks) < 2 || !marks[0] || marks[len(marks)-1] {
+		t.Fatalf("watermarks %v", marks)
+	}
+}
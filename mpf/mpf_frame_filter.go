@@ -0,0 +1,176 @@
+package mpf
+
+import (
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/**
+ * Filter of linear audio frames, e.g. noise suppression, echo cancellation or AGC,
+ * inserted between the source (e.g. RTP) and the sink (e.g. recognizer) of the channel.
+ * Filters are implemented either built-in or externally (e.g. by a binding of a DSP library).
+ */
+type FrameFilter interface {
+	/**
+	 * Process (interleaved) samples of the frame in place.
+	 * @param samples the samples
+	 */
+	FrameFilterProcess(samples []int16) error
+
+	/** Reset filter for the next audio (e.g. the next RECOGNIZE request) */
+	FrameFilterReset()
+}
+
+/** Stateless frame filter implemented by function */
+type FrameFilterFunc func(samples []int16) error
+
+/** Process samples by the function */
+func (f FrameFilterFunc) FrameFilterProcess(samples []int16) error {
+	return f(samples)
+}
+
+/** Nothing to reset */
+func (f FrameFilterFunc) FrameFilterReset() {}
+
+/** Chain of frame filters applied in order, itself a frame filter */
+type FrameFilterChain struct {
+	filters []FrameFilter
+	mutex   sync.Mutex
+}
+
+/**
+ * Create chain of frame filters.
+ * @param filters the filters in the order applied
+ */
+func FrameFilterChainCreate(filters ...FrameFilter) *FrameFilterChain {
+	chain := &FrameFilterChain{}
+	for _, filter := range filters {
+		chain.FrameFilterChainAdd(filter)
+	}
+	return chain
+}
+
+/**
+ * Append filter to the chain, possibly while the audio is streamed.
+ * @param filter the filter
+ */
+func (chain *FrameFilterChain) FrameFilterChainAdd(filter FrameFilter) {
+	if filter == nil {
+		return
+	}
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	chain.filters = append(chain.filters, filter)
+}
+
+/** Get number of filters in the chain */
+func (chain *FrameFilterChain) FrameFilterChainCount() int {
+	chain.mutex.Lock()
+	defer chain.mutex.Unlock()
+	return len(chain.filters)
+}
+
+/** Process samples by the filters in order, stopping at the first failed */
+func (chain *FrameFilterChain) FrameFilterProcess(samples []int16) error {
+	chain.mutex.Lock()
+	filters := chain.filters
+	chain.mutex.Unlock()
+	for _, filter := range filters {
+		if err := filter.FrameFilterProcess(samples); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/** Reset the filters of the chain */
+func (chain *FrameFilterChain) FrameFilterReset() {
+	chain.mutex.Lock()
+	filters := chain.filters
+	chain.mutex.Unlock()
+	for _, filter := range filters {
+		filter.FrameFilterReset()
+	}
+}
+
+/**
+ * Create stream applying frame filter (or chain of filters) to the audio written to the (linear) sink,
+ * e.g. of the recognizer. Frames without audio (e.g. events) are passed through.
+ * @param sink the sink
+ * @param filter the filter
+ */
+func FrameFilterStreamCreate(sink *AudioStream, filter FrameFilter) *AudioStream {
+	if sink == nil || filter == nil {
+		return nil
+	}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(sink) },
+		OpenTX:  func(_ *AudioStream, codec *Codec) error { return sink.AudioStreamTXOpen(codec) },
+		CloseTX: func(*AudioStream) error { return sink.AudioStreamTXClose() },
+		WriteFrame: func(_ *AudioStream, frame *Frame) error {
+			if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+				samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+				if err != nil {
+					return err
+				}
+				if len(samples) > 0 {
+					if err := filter.FrameFilterProcess(samples); err != nil {
+						return err
+					}
+					if err := codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples)); err != nil {
+						return err
+					}
+				}
+			}
+			return sink.AudioStreamFrameWrite(frame)
+		},
+	}
+	stream := AudioStreamCreate(filter, vtable, StreamCapabilitiesClone(sink.Capabilities))
+	if stream == nil {
+		return nil
+	}
+	stream.TXDescriptor = sink.TXDescriptor
+	stream.TXEventDescriptor = sink.TXEventDescriptor
+	stream.FrameWriteInPlace = true
+	return stream
+}
+
+/**
+ * Create stream applying frame filter (or chain of filters) to the audio read from the (linear) source.
+ * @param source the source
+ * @param filter the filter
+ */
+func FrameFilterSourceCreate(source *AudioStream, filter FrameFilter) *AudioStream {
+	if source == nil || filter == nil {
+		return nil
+	}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			if err := source.AudioStreamFrameRead(frame); err != nil {
+				return err
+			}
+			if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+				return nil
+			}
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			if err != nil || len(samples) == 0 {
+				return err
+			}
+			if err := filter.FrameFilterProcess(samples); err != nil {
+				return err
+			}
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}
+	stream := AudioStreamCreate(filter, vtable, StreamCapabilitiesClone(source.Capabilities))
+	if stream == nil {
+		return nil
+	}
+	stream.RXDescriptor = source.RXDescriptor
+	stream.RXEventDescriptor = source.RXEventDescriptor
+	return stream
+}
@@ -0,0 +1,95 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+/** Gain in dB the audio is muted at (or below) */
+const GAIN_MUTE = -96.0
+
+/** Max gain (amplification) in dB */
+const GAIN_MAX = 40.0
+
+/**
+ * Gain (volume) stage attenuating or amplifying (linear) audio by dB, e.g. to duck a mixer participant
+ * during barge-in. Changes of gain are ramped within the next frame, so that no clicks are heard.
+ */
+type Gain struct {
+	/** Gain in dB */
+	gain float64
+	/** Factor applied to the last sample processed */
+	factor float64
+	mutex  sync.Mutex
+}
+
+/* Get factor of gain in dB */
+func gainFactorGet(gain float64) float64 {
+	if gain <= GAIN_MUTE {
+		return 0
+	}
+	return math.Pow(10, gain/20)
+}
+
+/**
+ * Create gain stage.
+ * @param gain the gain in dB (negative - attenuation, GAIN_MUTE - mute)
+ */
+func GainCreate(gain float64) (*Gain, error) {
+	if gain > GAIN_MAX || math.IsNaN(gain) {
+		return nil, fmt.Errorf("invalid gain %.1f dB", gain)
+	}
+	return &Gain{gain: gain, factor: gainFactorGet(gain)}, nil
+}
+
+/**
+ * Set gain, ramped within the next frame.
+ * @param gain the gain in dB
+ */
+func (g *Gain) GainSet(gain float64) error {
+	if gain > GAIN_MAX || math.IsNaN(gain) {
+		return fmt.Errorf("invalid gain %.1f dB", gain)
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.gain = gain
+	return nil
+}
+
+/** Get gain in dB */
+func (g *Gain) GainGet() float64 {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.gain
+}
+
+/**
+ * Process (interleaved) samples of the frame in place.
+ * @param samples the samples
+ */
+func (g *Gain) GainProcess(samples []int16) {
+	g.mutex.Lock()
+	from, to := g.factor, gainFactorGet(g.gain)
+	g.factor = to
+	g.mutex.Unlock()
+	if from == 1 && to == 1 {
+		return
+	}
+	for i, sample := range samples {
+		factor := to
+		if from != to {
+			factor = from + (to-from)*float64(i+1)/float64(len(samples))
+		}
+		samples[i] = timeStretchSaturate(float64(sample) * factor)
+	}
+}
+
+/** Process samples as frame filter */
+func (g *Gain) FrameFilterProcess(samples []int16) error {
+	g.GainProcess(samples)
+	return nil
+}
+
+/** Nothing to reset, the gain is kept */
+func (g *Gain) FrameFilterReset() {}
@@ -0,0 +1,102 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestGain(t *testing.T) {
+	gain, err := GainCreate(-6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := []int16{1000, -1000, 1000, -1000}
+	gain.GainProcess(samples)
+	if samples[0] != 501 || samples[3] != -501 {
+		t.Fatalf("attenuated by 6 dB to %v", samples)
+	}
+
+	/* change of gain is ramped within the frame */
+	_ = gain.GainSet(GAIN_MUTE)
+	samples = []int16{1000, 1000, 1000, 1000}
+	gain.GainProcess(samples)
+	if samples[0] <= samples[1] || samples[1] <= samples[2] || samples[3] != 0 {
+		t.Fatalf("muted to %v", samples)
+	}
+	samples = []int16{1000}
+	if gain.GainProcess(samples); samples[0] != 0 {
+		t.Fatalf("muted to %v", samples)
+	}
+
+	_ = gain.GainSet(20)
+	samples = []int16{0, 30000}
+	if gain.GainProcess(samples); samples[1] != 32767 {
+		t.Fatalf("amplified to %v", samples)
+	}
+	if err := gain.GainSet(GAIN_MAX + 1); err == nil || gain.GainGet() != 20 {
+		t.Fatalf("gain above max is set")
+	}
+}
+
+func TestContextAssociationGain(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	promptStream := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			samples := make([]int16, 80)
+			for i := range samples {
+				samples[i] = 10000
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	promptStream.RXDescriptor = descriptor
+
+	var played []int16
+	rtpStream := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			var err error
+			played, err = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			return err
+		},
+	}, SinkStreamCapabilitiesCreate())
+	rtpStream.TXDescriptor = descriptor
+
+	context := ContextFactoryCreate().ContextCreate("gain", nil, 2)
+	prompt := TerminationBaseCreate(nil, nil, nil, promptStream, nil)
+	rtp := TerminationBaseCreate(nil, nil, nil, rtpStream, nil)
+	for _, termination := range []*Termination{prompt, rtp} {
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+	}
+	if err := context.ContextAssociationGainSet(prompt, rtp, -6); err == nil {
+		t.Fatalf("gain of no association is set")
+	}
+	context.ContextAssociationAdd(prompt, rtp)
+	if err := context.ContextAssociationGainSet(prompt, rtp, -6); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	process := func() int16 {
+		for tick := 0; tick < 2; tick++ {
+			if err := context.ContextProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return played[len(played)-1]
+	}
+	if sample := process(); sample != 5012 {
+		t.Fatalf("prompt played at %d", sample)
+	}
+
+	/* the prompt is ducked at once on barge-in */
+	if err := context.ContextAssociationGainSet(prompt, rtp, GAIN_MUTE); err != nil {
+		t.Fatal(err)
+	}
+	if sample := process(); sample != 0 {
+		t.Fatalf("prompt ducked at %d", sample)
+	}
+}
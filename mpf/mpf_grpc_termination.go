@@ -0,0 +1,198 @@
+package mpf
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+)
+
+/** Number of chunks of audio granted to the peer at once, as soon as they are received */
+const GRPC_STREAM_CREDIT_BATCH = IO_STREAM_QUEUE_SIZE / 2
+
+/** Audio chunk exchanged over gRPC stream (message AudioChunk of mpf_grpc_audio.proto) */
+type GrpcAudioChunk struct {
+	/** Audio: linear PCM of any size, one frame per chunk for other codecs */
+	Audio []byte
+	/** Metadata, e.g. session and channel identifiers, codec, results of the worker */
+	Metadata map[string]string
+	/** Number of chunks of audio the sender is ready to receive more */
+	Credit uint32
+	/** End of the audio sent */
+	End bool
+}
+
+/**
+ * gRPC bidirectional stream of audio chunks (rpc Stream of service AudioStreamer of mpf_grpc_audio.proto),
+ * either the client or the server stream generated, adapted to GrpcAudioChunk.
+ * The stream may implement CloseSend() error (client stream), called as soon as the end of the audio is sent.
+ */
+type GrpcAudioStream interface {
+	Send(chunk *GrpcAudioChunk) error
+	Recv() (*GrpcAudioChunk, error)
+}
+
+/* gRPC stream shared by the reader and the writer of the termination */
+type grpcStream struct {
+	stream    GrpcAudioStream
+	sendMutex sync.Mutex
+
+	mutex sync.Mutex
+	cond  *sync.Cond
+	/** Credit granted by the peer, chunks of audio are not sent without credit */
+	credit uint32
+	/** No chunks of audio wait for credit anymore (destroyed or the stream is broken) */
+	closing bool
+	/** Metadata received from the peer */
+	metadata map[string]string
+	/** Chunks of audio received since credit is granted last */
+	received uint32
+
+	writer *ioWriterStream
+	ended  sync.Once
+}
+
+/**
+ * Create termination exchanging audio with external worker (e.g. ASR or TTS in other process) over gRPC
+ * bidirectional stream, see mpf_grpc_audio.proto.
+ * The first chunk sent carries the metadata (plus codec, sampling-rate and channel-count of the descriptor)
+ * and grants IO_STREAM_QUEUE_SIZE chunks of credit to the peer, credit is granted more as the audio is received.
+ * The frames written are sent only within credit granted by the peer, they are dropped if the peer is late.
+ * The end of the audio received is raised as AUDIO_FILE_COMPLETE_EVENT, the end is sent on destroy.
+ * @param stream the gRPC stream
+ * @param descriptor the codec descriptor of the audio in both directions (linear PCM 8 kHz mono if nil)
+ * @param metadata the metadata to send to the peer (may be nil)
+ */
+func GrpcTerminationCreate(stream GrpcAudioStream, descriptor *CodecDescriptor, metadata map[string]string) (*Termination, error) {
+	if stream == nil {
+		return nil, fmt.Errorf("no gRPC stream")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	s := &grpcStream{stream: stream, metadata: make(map[string]string)}
+	s.cond = sync.NewCond(&s.mutex)
+
+	initial := map[string]string{
+		"codec":         descriptor.Name,
+		"sampling-rate": strconv.Itoa(int(descriptor.SamplingRate)),
+		"channel-count": strconv.Itoa(int(descriptor.ChannelCount)),
+	}
+	for name, value := range metadata {
+		initial[name] = value
+	}
+	if err := s.send(&GrpcAudioChunk{Metadata: initial, Credit: IO_STREAM_QUEUE_SIZE}); err != nil {
+		return nil, err
+	}
+
+	reader := ioReaderStreamCreate(ioMessageFramesRead(descriptor, s.recv), s)
+	s.writer = ioWriterStreamCreate(s.write)
+	audioStream := ioDuplexAudioStreamCreate(reader, s.writer, s.close)
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = descriptor
+	audioStream.TXDescriptor = descriptor
+	go reader.run()
+	go s.writer.run()
+	return TerminationBaseCreate(nil, s, nil, audioStream, nil), nil
+}
+
+func (s *grpcStream) send(chunk *GrpcAudioChunk) error {
+	s.sendMutex.Lock()
+	defer s.sendMutex.Unlock()
+	return s.stream.Send(chunk)
+}
+
+/* Receive the next chunk of audio, metadata and credit are taken on the way */
+func (s *grpcStream) recv() ([]byte, error) {
+	for {
+		chunk, err := s.stream.Recv()
+		if err != nil {
+			/* the peer is gone, the writer does not wait for credit anymore */
+			s.close()
+			return nil, err
+		}
+		s.mutex.Lock()
+		for name, value := range chunk.Metadata {
+			s.metadata[name] = value
+		}
+		if chunk.Credit > 0 {
+			s.credit += chunk.Credit
+			s.cond.Broadcast()
+		}
+		s.mutex.Unlock()
+		if chunk.End {
+			return nil, io.EOF
+		}
+		if len(chunk.Audio) == 0 {
+			continue
+		}
+		/* the reader is blocked while the queue is full, so that no credit is granted then */
+		s.received++
+		if s.received >= GRPC_STREAM_CREDIT_BATCH {
+			if err := s.send(&GrpcAudioChunk{Credit: s.received}); err != nil {
+				return nil, err
+			}
+			s.received = 0
+		}
+		return chunk.Audio, nil
+	}
+}
+
+/* Send chunk of audio as soon as credit is granted */
+func (s *grpcStream) write(data []byte) error {
+	s.mutex.Lock()
+	for s.credit == 0 && !s.closing {
+		s.cond.Wait()
+	}
+	if s.credit == 0 {
+		s.mutex.Unlock()
+		s.writer.mutex.Lock()
+		s.writer.dropped++
+		s.writer.mutex.Unlock()
+		return nil
+	}
+	s.credit--
+	s.mutex.Unlock()
+	return s.send(&GrpcAudioChunk{Audio: data})
+}
+
+func (s *grpcStream) close() {
+	s.mutex.Lock()
+	s.closing = true
+	s.cond.Broadcast()
+	s.mutex.Unlock()
+}
+
+/* Send the end of the audio, called on destroy as soon as the writer is flushed */
+func (s *grpcStream) Close() error {
+	var err error
+	s.ended.Do(func() {
+		if err = s.send(&GrpcAudioChunk{End: true}); err != nil {
+			return
+		}
+		if closer, ok := s.stream.(interface{ CloseSend() error }); ok {
+			err = closer.CloseSend()
+		}
+	})
+	return err
+}
+
+/**
+ * Get metadata received from the peer of gRPC termination.
+ * @param termination the termination created by GrpcTerminationCreate
+ */
+func GrpcTerminationMetadataGet(termination *Termination) map[string]string {
+	s, ok := termination.Obj.(*grpcStream)
+	if !ok {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	metadata := make(map[string]string, len(s.metadata))
+	for name, value := range s.metadata {
+		metadata[name] = value
+	}
+	return metadata
+}
@@ -0,0 +1,89 @@
+package mpf
+
+import (
+	"bytes"
+	"io"
+	"runtime"
+	"testing"
+)
+
+/* In-memory gRPC stream, the peer reads what is sent and writes what is received */
+type grpcTestStream struct {
+	sent     chan *GrpcAudioChunk
+	received chan *GrpcAudioChunk
+}
+
+func (s *grpcTestStream) Send(chunk *GrpcAudioChunk) error {
+	s.sent <- chunk
+	return nil
+}
+
+func (s *grpcTestStream) Recv() (*GrpcAudioChunk, error) {
+	chunk, ok := <-s.received
+	if !ok {
+		return nil, io.EOF
+	}
+	return chunk, nil
+}
+
+func TestGrpcTermination(t *testing.T) {
+	stream := &grpcTestStream{sent: make(chan *GrpcAudioChunk, 100), received: make(chan *GrpcAudioChunk, 100)}
+	termination, err := GrpcTerminationCreate(stream, nil, map[string]string{"channel-id": "1@speechrecog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := <-stream.sent
+	if first.Credit != IO_STREAM_QUEUE_SIZE || first.Metadata["codec"] != "LPCM" || first.Metadata["channel-id"] != "1@speechrecog" {
+		t.Fatalf("first chunk %+v", first)
+	}
+	completed := false
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == AUDIO_FILE_COMPLETE_EVENT {
+			completed = true
+		}
+		return nil
+	}
+	as := termination.TerminationAudioStreamGet()
+
+	/* the audio received is re-chunked to frames, the metadata is taken */
+	audio := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 96)
+	stream.received <- &GrpcAudioChunk{Metadata: map[string]string{"result": "hello"}, Credit: 2}
+	stream.received <- &GrpcAudioChunk{Audio: audio[:200]}
+	stream.received <- &GrpcAudioChunk{Audio: audio[200:]}
+	stream.received <- &GrpcAudioChunk{End: true}
+	var received []byte
+	for !completed {
+		frame := &Frame{}
+		if err := as.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+			received = append(received, codecFrameDataGet(&frame.CodecFrame)...)
+		}
+		runtime.Gosched()
+	}
+	if !bytes.Equal(received, audio) || GrpcTerminationMetadataGet(termination)["result"] != "hello" {
+		t.Fatalf("%d bytes received, want %d, metadata %v", len(received), len(audio), GrpcTerminationMetadataGet(termination))
+	}
+
+	/* the frames are sent within credit granted, the rest is dropped on destroy */
+	for i := 0; i < 3; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{byte(i)}, 160))
+		if err := as.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if chunk := <-stream.sent; !bytes.Equal(chunk.Audio, bytes.Repeat([]byte{byte(i)}, 160)) {
+			t.Fatalf("chunk %d: %+v", i, chunk)
+		}
+	}
+	if err := AudioStreamDestroy(as); err != nil {
+		t.Fatal(err)
+	}
+	close(stream.received)
+	if chunk := <-stream.sent; !chunk.End || IOStreamDroppedGet(as) != 1 {
+		t.Fatalf("last chunk %+v, %d frames dropped", chunk, IOStreamDroppedGet(as))
+	}
+}
@@ -0,0 +1,296 @@
+package mpf
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+/** ICE candidate types */
+const (
+	ICE_CANDIDATE_HOST  = "host"
+	ICE_CANDIDATE_SRFLX = "srflx"
+)
+
+/** ICE components */
+const (
+	ICE_COMPONENT_RTP  = 1
+	ICE_COMPONENT_RTCP = 2
+)
+
+/** Type preferences of ICE candidates (RFC 8445 5.1.2.2) */
+const (
+	ICE_TYPE_PREF_HOST  = 126
+	ICE_TYPE_PREF_SRFLX = 100
+)
+
+/** Lengths of ICE credentials generated (at least 24 and 128 bits of randomness, RFC 8445 5.3) */
+const (
+	ICE_UFRAG_LENGTH = 8
+	ICE_PWD_LENGTH   = 24
+)
+
+/** Characters of ICE credentials (ice-char) */
+const iceChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+/** ICE candidate of SDP media (a=candidate, RFC 8839) */
+type IceCandidate struct {
+	/** Foundation, the same for the candidates of the same type and base */
+	Foundation string
+	/** Component (1 - RTP, 2 - RTCP) */
+	Component int
+	/** Transport, UDP only */
+	Transport string
+	/** Priority */
+	Priority uint32
+	/** IP address */
+	Ip string
+	/** Port */
+	Port uint16
+	/** Candidate type (host, srflx) */
+	Type string
+	/** Related address of server reflexive candidate, empty if none */
+	RelatedIp string
+	/** Related port of server reflexive candidate */
+	RelatedPort uint16
+}
+
+/**
+ * Calculate priority of ICE candidate (RFC 8445 5.1.2.1).
+ * @param typePref the type preference
+ * @param localPref the local preference (the same for single interface)
+ * @param component the component
+ */
+func IcePriorityCalculate(typePref int, localPref int, component int) uint32 {
+	return uint32(typePref)<<24 | uint32(localPref)<<8 | uint32(256-component)
+}
+
+/**
+ * Parse value of candidate attribute, e.g. "1 1 UDP 2130706431 10.0.1.1 8998 typ host".
+ * @param value the value of the attribute
+ */
+func IceCandidateParse(value string) (*IceCandidate, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 8 || !strings.EqualFold(fields[6], "typ") {
+		return nil, fmt.Errorf("invalid candidate attribute [%s]", value)
+	}
+	component, err := strconv.Atoi(fields[1])
+	if err != nil || component < 1 || component > 256 {
+		return nil, fmt.Errorf("invalid component of candidate attribute [%s]", value)
+	}
+	priority, err := strconv.ParseUint(fields[3], 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid priority of candidate attribute [%s]", value)
+	}
+	port, err := strconv.ParseUint(fields[5], 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port of candidate attribute [%s]", value)
+	}
+	candidate := &IceCandidate{
+		Foundation: fields[0],
+		Component:  component,
+		Transport:  strings.ToUpper(fields[2]),
+		Priority:   uint32(priority),
+		Ip:         fields[4],
+		Port:       uint16(port),
+		Type:       strings.ToLower(fields[7]),
+	}
+	/* the extensions other than the related address are ignored */
+	for i := 8; i+1 < len(fields); i += 2 {
+		switch strings.ToLower(fields[i]) {
+		case "raddr":
+			candidate.RelatedIp = fields[i+1]
+		case "rport":
+			if rport, err := strconv.ParseUint(fields[i+1], 10, 16); err == nil {
+				candidate.RelatedPort = uint16(rport)
+			}
+		}
+	}
+	return candidate, nil
+}
+
+/** Get value of candidate attribute */
+func (c *IceCandidate) IceCandidateStrGet() string {
+	value := fmt.Sprintf("%s %d %s %d %s %d typ %s", c.Foundation, c.Component, c.Transport, c.Priority, c.Ip, c.Port, c.Type)
+	if c.RelatedIp != "" {
+		value += fmt.Sprintf(" raddr %s rport %d", c.RelatedIp, c.RelatedPort)
+	}
+	return value
+}
+
+/** Generate ICE credentials (ice-ufrag, ice-pwd) of random characters */
+func IceCredentialsGenerate() (string, string, error) {
+	random := make([]byte, ICE_UFRAG_LENGTH+ICE_PWD_LENGTH)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+	for i := range random {
+		random[i] = iceChars[int(random[i])%len(iceChars)]
+	}
+	return string(random[:ICE_UFRAG_LENGTH]), string(random[ICE_UFRAG_LENGTH:]), nil
+}
+
+/** Set ICE credentials of RTP media descriptor (a=ice-ufrag, a=ice-pwd), ICE is not used if empty */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorIceSet(ufrag string, pwd string) {
+	media.iceUfrag = ufrag
+	media.icePwd = pwd
+}
+
+/** Get ICE credentials of RTP media descriptor */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorIceGet() (string, string) {
+	return media.iceUfrag, media.icePwd
+}
+
+/** Add ICE candidate to RTP media descriptor (a=candidate) */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorCandidateAdd(candidate *IceCandidate) {
+	media.candidates = append(media.candidates, candidate)
+}
+
+/** Get ICE candidates of RTP media descriptor, the ones of local media are gathered as the socket is bound */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorCandidatesGet() []*IceCandidate {
+	return media.candidates
+}
+
+/**
+ * Negotiate ICE: if accepted by the settings and the remote media offers ICE credentials,
+ * the credentials of the local media are generated (ICE-lite, the server is the controlled agent).
+ * The candidates of the local media are gathered as it is applied. ICE is not used otherwise.
+ */
+func (d *RtpStreamDescriptor) RtpStreamDescriptorIceNegotiate() error {
+	if d.local == nil || d.remote == nil || d.settings == nil {
+		return fmt.Errorf("local, remote media or settings of rtp stream is nil")
+	}
+	if !d.settings.ice || d.remote.iceUfrag == "" || d.remote.icePwd == "" {
+		d.local.RtpMediaDescriptorIceSet("", "")
+		return nil
+	}
+	if d.local.iceUfrag != "" && d.local.icePwd != "" {
+		/* the credentials are kept within the session */
+		return nil
+	}
+	ufrag, pwd, err := IceCredentialsGenerate()
+	if err != nil {
+		return err
+	}
+	d.local.RtpMediaDescriptorIceSet(ufrag, pwd)
+	return nil
+}
+
+/*
+ * Gather ICE candidates of local media bound to the port (the stream must be locked):
+ * the host candidate of the address bound and the server reflexive one of the external address (1:1 NAT).
+ * The candidates of RTCP are gathered unless it is multiplexed with RTP.
+ */
+func (rtpStream *RtpStream) iceCandidatesGather(local *RtpMediaDescriptor, ip string, port uint16) {
+	local.candidates = nil
+	if local.iceUfrag == "" {
+		return
+	}
+	components := []int{ICE_COMPONENT_RTP}
+	if rtpStream.settings.rtcp && !local.rtcpMux {
+		components = append(components, ICE_COMPONENT_RTCP)
+	}
+	for _, component := range components {
+		componentPort := port + uint16(component-ICE_COMPONENT_RTP)
+		if ip != "" && !net.ParseIP(ip).IsUnspecified() {
+			local.RtpMediaDescriptorCandidateAdd(&IceCandidate{
+				Foundation: "1",
+				Component:  component,
+				Transport:  "UDP",
+				Priority:   IcePriorityCalculate(ICE_TYPE_PREF_HOST, 65535, component),
+				Ip:         ip,
+				Port:       componentPort,
+				Type:       ICE_CANDIDATE_HOST,
+			})
+		}
+		if local.extIp != "" {
+			/* the port is kept by 1:1 NAT */
+			local.RtpMediaDescriptorCandidateAdd(&IceCandidate{
+				Foundation:  "2",
+				Component:   component,
+				Transport:   "UDP",
+				Priority:    IcePriorityCalculate(ICE_TYPE_PREF_SRFLX, 65535, component),
+				Ip:          local.extIp,
+				Port:        componentPort,
+				Type:        ICE_CANDIDATE_SRFLX,
+				RelatedIp:   ip,
+				RelatedPort: componentPort,
+			})
+		}
+	}
+}
+
+/*
+ * Respond to ICE connectivity check (STUN Binding request) received on socket of the component.
+ * The check is authenticated by the credentials of the local media, the address of the check
+ * nominated by the controlling agent (USE-CANDIDATE) is the one the media is sent to since then.
+ */
+func (rtpStream *RtpStream) iceCheckHandle(socket *net.UDPConn, data []byte, addr *net.UDPAddr, component int) {
+	request, err := StunMessageParse(data)
+	if err != nil || request.Type != STUN_BINDING_REQUEST {
+		return
+	}
+	rtpStream.mutex.Lock()
+	var localUfrag, localPwd, remoteUfrag string
+	if rtpStream.local != nil {
+		localUfrag, localPwd = rtpStream.local.iceUfrag, rtpStream.local.icePwd
+	}
+	if rtpStream.remote != nil {
+		remoteUfrag = rtpStream.remote.iceUfrag
+	}
+	rtpStream.mutex.Unlock()
+	if localUfrag == "" {
+		/* ICE is not used */
+		return
+	}
+
+	code := 0
+	/* USERNAME is of the form local:remote, the local fragment is of the receiver of the check */
+	username := string(request.StunMessageAttribGet(STUN_ATTR_USERNAME))
+	switch {
+	case !request.StunMessageFingerprintCheck():
+		return
+	case username == "" || !request.StunMessageAttribCheck(STUN_ATTR_MESSAGE_INTEGRITY):
+		code = STUN_ERROR_BAD_REQUEST
+	case !strings.HasPrefix(username, localUfrag+":") ||
+		(remoteUfrag != "" && username != localUfrag+":"+remoteUfrag) ||
+		!request.StunMessageIntegrityCheck([]byte(localPwd)):
+		code = STUN_ERROR_UNAUTHORIZED
+	case request.StunMessageAttribCheck(STUN_ATTR_ICE_CONTROLLED):
+		/* the lite agent is always controlled, the remote is to switch its role (RFC 8445 7.3.1.1) */
+		code = STUN_ERROR_ROLE_CONFLICT
+	}
+
+	response := &StunMessage{TransactionId: request.TransactionId}
+	if code != 0 {
+		response.Type = STUN_BINDING_ERROR
+		response.StunMessageAttribAdd(STUN_ATTR_ERROR_CODE, StunErrorCodeMake(code, ""))
+	} else {
+		response.Type = STUN_BINDING_SUCCESS
+		response.StunMessageAttribAdd(STUN_ATTR_XOR_MAPPED_ADDRESS, StunXorAddressMake(addr, request.TransactionId))
+	}
+	var key []byte
+	if code != STUN_ERROR_BAD_REQUEST && code != STUN_ERROR_UNAUTHORIZED {
+		key = []byte(localPwd)
+	}
+	_, _ = socket.WriteToUDP(response.StunMessageMarshal(key, true), addr)
+
+	if code == 0 && request.StunMessageAttribCheck(STUN_ATTR_USE_CANDIDATE) {
+		rtpStream.iceNominate(addr, component)
+	}
+}
+
+/* Nominate the address of the check of the component, the media of the component is sent to it since then */
+func (rtpStream *RtpStream) iceNominate(addr *net.UDPAddr, component int) {
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if component == ICE_COMPONENT_RTCP {
+		rtpStream.rtcp.remoteAddr = addr
+		return
+	}
+	rtpStream.remoteAddr = addr
+	/* the address nominated is not latched onto by symmetric RTP anymore */
+	rtpStream.latched = true
+}
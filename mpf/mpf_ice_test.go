@@ -0,0 +1,163 @@
+package mpf
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestIceCandidate(t *testing.T) {
+	candidate, err := IceCandidateParse("2 1 udp 1694498815 203.0.113.7 40000 typ srflx raddr 10.0.0.5 rport 40000 generation 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := IceCandidate{Foundation: "2", Component: ICE_COMPONENT_RTP, Transport: "UDP", Priority: 1694498815,
+		Ip: "203.0.113.7", Port: 40000, Type: ICE_CANDIDATE_SRFLX, RelatedIp: "10.0.0.5", RelatedPort: 40000}
+	if *candidate != want {
+		t.Fatalf("candidate %+v", candidate)
+	}
+	if value := candidate.IceCandidateStrGet(); value != "2 1 UDP 1694498815 203.0.113.7 40000 typ srflx raddr 10.0.0.5 rport 40000" {
+		t.Fatalf("candidate is formatted as [%s]", value)
+	}
+	if IcePriorityCalculate(ICE_TYPE_PREF_HOST, 65535, ICE_COMPONENT_RTP) != 2130706431 {
+		t.Fatal("priority of host candidate")
+	}
+	for _, value := range []string{"1 1 UDP 1 10.0.0.1 9 host", "1 x UDP 1 10.0.0.1 9 typ host", "1 1 UDP 1 10.0.0.1 port typ host"} {
+		if _, err := IceCandidateParse(value); err == nil {
+			t.Errorf("invalid candidate [%s] is parsed", value)
+		}
+	}
+
+	descriptor := RtpStreamDescriptor{local: RtpMediaDescriptorAlloc(), remote: RtpMediaDescriptorAlloc(), settings: RtpSettingsAlloc()}
+	descriptor.remote.RtpMediaDescriptorIceSet("rfrag", "remotepasswordremotepassword")
+	if err := descriptor.RtpStreamDescriptorIceNegotiate(); err != nil || descriptor.local.iceUfrag != "" {
+		t.Fatalf("ice is negotiated while not accepted: %v", err)
+	}
+	descriptor.settings.RtpSettingsIceSet(true)
+	if err := descriptor.RtpStreamDescriptorIceNegotiate(); err != nil {
+		t.Fatal(err)
+	}
+	if ufrag, pwd := descriptor.local.RtpMediaDescriptorIceGet(); len(ufrag) != ICE_UFRAG_LENGTH || len(pwd) != ICE_PWD_LENGTH {
+		t.Fatalf("ice credentials [%s] [%s]", ufrag, pwd)
+	}
+}
+
+func TestIceConnectivityCheck(t *testing.T) {
+	peer, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	/* the server is behind 1:1 NAT of the external address */
+	config := RtpConfigAlloc()
+	if err := config.RtpConfigIpSet("127.0.0.1", "203.0.113.7"); err != nil {
+		t.Fatal(err)
+	}
+	termination := RtpTerminationFactoryCreate(config).TerminationCreate(nil)
+	defer TerminationDestroy(termination)
+	descriptor := RtpTerminationDescriptorAlloc()
+	settings := RtpSettingsAlloc()
+	settings.RtpSettingsRtcpSet(true, 60000)
+	settings.RtpSettingsIceSet(true)
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorDirectionSet(STREAM_DIRECTION_SEND)
+	*local.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, "PCMU")
+	remote := rtpTestRemoteCreate(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}, 10)
+	remote.RtpMediaDescriptorIceSet("rfrag", "remotepasswordremotepassword")
+	descriptor.RtpTerminationDescriptorAudioSettingsSet(settings)
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(remote)
+	if err := descriptor.audio.RtpStreamDescriptorIceNegotiate(); err != nil {
+		t.Fatal(err)
+	}
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	/* host and server reflexive candidates of RTP and RTCP */
+	ip, port := local.RtpMediaDescriptorAddressGet()
+	candidates := local.RtpMediaDescriptorCandidatesGet()
+	if len(candidates) != 4 || candidates[0].Ip != ip || candidates[0].Port != port || candidates[0].Type != ICE_CANDIDATE_HOST ||
+		candidates[1].Ip != "203.0.113.7" || candidates[1].Type != ICE_CANDIDATE_SRFLX || candidates[3].Component != ICE_COMPONENT_RTCP || candidates[3].Port != port+1 {
+		for _, candidate := range candidates {
+			t.Log(candidate.IceCandidateStrGet())
+		}
+		t.Fatal("candidates gathered")
+	}
+	stream := termination.TerminationAudioStreamGet()
+	if err := stream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	ufrag, pwd := local.RtpMediaDescriptorIceGet()
+	localAddr := &net.UDPAddr{IP: net.ParseIP(ip), Port: int(port)}
+	check := func(username string, key string, nominate bool) *StunMessage {
+		request, err := StunMessageCreate(STUN_BINDING_REQUEST)
+		if err != nil {
+			t.Fatal(err)
+		}
+		request.StunMessageAttribAdd(STUN_ATTR_USERNAME, []byte(username))
+		request.StunMessageAttribAdd(STUN_ATTR_ICE_CONTROLLING, make([]byte, 8))
+		if nominate {
+			request.StunMessageAttribAdd(STUN_ATTR_USE_CANDIDATE, nil)
+		}
+		var integrity []byte
+		if key != "" {
+			integrity = []byte(key)
+		}
+		if _, err := peer.WriteToUDP(request.StunMessageMarshal(integrity, true), localAddr); err != nil {
+			t.Fatal(err)
+		}
+		buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+		peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+		n, _, err := peer.ReadFromUDP(buffer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		response, err := StunMessageParse(buffer[:n])
+		if err != nil || response.TransactionId != request.TransactionId {
+			t.Fatalf("response %+v: %v", response, err)
+		}
+		return response
+	}
+	for _, test := range []struct {
+		username string
+		key      string
+		code     byte
+	}{
+		{ufrag + ":rfrag", "", 0},
+		{ufrag + ":rfrag", "wrong", 1},
+		{"other:rfrag", pwd, 1},
+	} {
+		if response := check(test.username, test.key, true); response.Type != STUN_BINDING_ERROR ||
+			response.StunMessageAttribGet(STUN_ATTR_ERROR_CODE)[3] != test.code {
+			t.Fatalf("check of [%s] is responded by %+v", test.username, response)
+		}
+	}
+	if addr, _ := RtpStreamRemoteAddrGet(stream); addr.Port != 9 {
+		t.Fatalf("remote address %v is nominated by invalid check", addr)
+	}
+
+	/* the valid check is responded by the address it comes from, nominated */
+	response := check(ufrag+":rfrag", pwd, true)
+	if response.Type != STUN_BINDING_SUCCESS || !response.StunMessageIntegrityCheck([]byte(pwd)) || !response.StunMessageFingerprintCheck() {
+		t.Fatalf("response %+v", response)
+	}
+	mapped, err := StunAddressParse(response.StunMessageAttribGet(STUN_ATTR_XOR_MAPPED_ADDRESS), true, response.TransactionId)
+	if err != nil || mapped.String() != peer.LocalAddr().String() {
+		t.Fatalf("address %v is mapped: %v", mapped, err)
+	}
+	if addr, _ := RtpStreamRemoteAddrGet(stream); addr.String() != peer.LocalAddr().String() {
+		t.Fatalf("remote address %v is nominated", addr)
+	}
+	frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU}
+	codecFrameDataSet(&frame.CodecFrame, make([]byte, 80))
+	if err := stream.AudioStreamFrameWrite(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if header, _ := rtpTestPacketReceive(t, peer); RtpPayloadType(header.Type) != RTP_PT_PCMU {
+		t.Fatalf("payload type %d", header.Type)
+	}
+}
@@ -0,0 +1,371 @@
+package mpf
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+/** Number of frames queued between io.Reader/io.Writer and the context (500 msec) */
+const IO_STREAM_QUEUE_SIZE = 50
+
+/* Stream reading audio by frames from io.Reader or other source (e.g. WebSocket) */
+type ioReaderStream struct {
+	/** Read next frame, io.EOF at the end of the audio */
+	next func() ([]byte, error)
+	/** Unblock next on destroy (may be nil) */
+	closer io.Closer
+	frames chan []byte
+	stop   chan struct{}
+	closed sync.Once
+	/** Error the reader failed with */
+	err error
+	/** End of the audio is reported */
+	eof bool
+}
+
+/* Stream writing audio by frames to io.Writer or other sink (e.g. WebSocket) */
+type ioWriterStream struct {
+	/** Write frame */
+	write  func(data []byte) error
+	frames chan []byte
+	done   chan struct{}
+	/** The stream is destroyed, no frames are queued anymore */
+	closing bool
+	/** Error the writer failed with */
+	err error
+	/** Number of frames dropped since the writer is late */
+	dropped uint64
+	mutex   sync.Mutex
+}
+
+/* Get frame size of the audio of the codec descriptor */
+func ioStreamFrameSizeGet(descriptor *CodecDescriptor) (int64, error) {
+	if CodecLPcmDescriptorMatch(descriptor) {
+		return descriptor.CodecLinearFrameSizeGet(), nil
+	}
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if err != nil {
+		return 0, err
+	}
+	if codec == nil {
+		return 0, fmt.Errorf("no codec %s registered", descriptor.Name)
+	}
+	size := codec.CodecFrameSizeGet(descriptor)
+	if size <= 0 {
+		return 0, fmt.Errorf("codec %s of variable frame size is not supported", descriptor.Name)
+	}
+	return size, nil
+}
+
+/**
+ * Create termination the audio source of which is io.Reader, e.g. HTTP body, buffer or pipe.
+ * The audio is read by frames in the background, so that the context is not blocked by the reader,
+ * the end of the audio is raised as AUDIO_FILE_COMPLETE_EVENT.
+ * @param r the reader of the audio
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ */
+func TerminationFromReader(r io.Reader, descriptor *CodecDescriptor) (*Termination, error) {
+	if r == nil {
+		return nil, fmt.Errorf("no reader")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	frameSize, err := ioStreamFrameSizeGet(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	next := func() ([]byte, error) {
+		data := make([]byte, frameSize)
+		if _, err := io.ReadFull(r, data); err != nil {
+			if err == io.ErrUnexpectedEOF {
+				/* the rest shorter than a frame is not played */
+				err = io.EOF
+			}
+			return nil, err
+		}
+		return data, nil
+	}
+	closer, _ := r.(io.Closer)
+	stream := ioReaderStreamCreate(next, closer)
+	vtable := &AudioStreamVTable{
+		Destroy:   stream.destroy,
+		ReadFrame: stream.frameRead,
+	}
+	audioStream := AudioStreamCreate(stream, vtable, SourceStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = descriptor
+	go stream.run()
+	return TerminationBaseCreate(nil, r, nil, audioStream, nil), nil
+}
+
+/*
+ * Read frames of audio received in messages, e.g. over WebSocket or gRPC: linear PCM may be received
+ * in messages of any size and is re-chunked to frames, audio of other codecs is received as one frame per message.
+ */
+func ioMessageFramesRead(descriptor *CodecDescriptor, recv func() ([]byte, error)) func() ([]byte, error) {
+	linear := CodecLPcmDescriptorMatch(descriptor)
+	var (
+		frameSize int64
+		pending   []byte
+	)
+	if linear {
+		frameSize = descriptor.CodecLinearFrameSizeGet()
+	}
+	return func() ([]byte, error) {
+		for {
+			if linear && int64(len(pending)) >= frameSize {
+				data := append([]byte(nil), pending[:frameSize]...)
+				pending = pending[frameSize:]
+				return data, nil
+			}
+			payload, err := recv()
+			if err != nil {
+				return nil, err
+			}
+			if len(payload) == 0 {
+				continue
+			}
+			if !linear {
+				return payload, nil
+			}
+			pending = append(pending, payload...)
+		}
+	}
+}
+
+func ioReaderStreamCreate(next func() ([]byte, error), closer io.Closer) *ioReaderStream {
+	return &ioReaderStream{
+		next:   next,
+		closer: closer,
+		frames: make(chan []byte, IO_STREAM_QUEUE_SIZE),
+		stop:   make(chan struct{}),
+	}
+}
+
+/* Read frames till the end of the audio or the stream is destroyed */
+func (stream *ioReaderStream) run() {
+	defer close(stream.frames)
+	for {
+		data, err := stream.next()
+		if err != nil {
+			select {
+			case <-stream.stop:
+				/* unblocked on destroy */
+				return
+			default:
+			}
+			if err != io.EOF {
+				stream.err = err
+			}
+			return
+		}
+		select {
+		case stream.frames <- data:
+		case <-stream.stop:
+			return
+		}
+	}
+}
+
+func (stream *ioReaderStream) frameRead(as *AudioStream, frame *Frame) error {
+	if stream.eof {
+		return nil
+	}
+	select {
+	case data, ok := <-stream.frames:
+		if !ok {
+			stream.eof = true
+			completion := &AudioFileCompletion{Direction: FILE_READER, Cause: AUDIO_FILE_COMPLETION_EOF}
+			return AudioFileEventRaise(as, AUDIO_FILE_COMPLETE_EVENT, completion)
+		}
+		frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+		return codecFrameDataSet(&frame.CodecFrame, data)
+	default:
+		/* the reader is late, no audio this time */
+		return nil
+	}
+}
+
+func (stream *ioReaderStream) destroy(as *AudioStream) error {
+	stream.closed.Do(func() {
+		close(stream.stop)
+	})
+	if stream.closer != nil {
+		/* unblock the reader */
+		return stream.closer.Close()
+	}
+	return nil
+}
+
+/**
+ * Create termination the audio sink of which is io.Writer.
+ * The audio is written by frames in the background, so that the context is not blocked by the writer,
+ * frames are dropped if the writer is late for IO_STREAM_QUEUE_SIZE frames. The writer is flushed on destroy.
+ * @param w the writer of the audio
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ */
+func TerminationFromWriter(w io.Writer, descriptor *CodecDescriptor) (*Termination, error) {
+	if w == nil {
+		return nil, fmt.Errorf("no writer")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	stream := ioWriterStreamCreate(func(data []byte) error {
+		_, err := w.Write(data)
+		return err
+	})
+	vtable := &AudioStreamVTable{
+		Destroy:    stream.destroy,
+		WriteFrame: stream.frameWrite,
+	}
+	audioStream := AudioStreamCreate(stream, vtable, SinkStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.TXDescriptor = descriptor
+	go stream.run()
+	return TerminationBaseCreate(nil, w, nil, audioStream, nil), nil
+}
+
+func ioWriterStreamCreate(write func(data []byte) error) *ioWriterStream {
+	return &ioWriterStream{
+		write:  write,
+		frames: make(chan []byte, IO_STREAM_QUEUE_SIZE),
+		done:   make(chan struct{}),
+	}
+}
+
+/* Write frames queued till the stream is destroyed */
+func (stream *ioWriterStream) run() {
+	defer close(stream.done)
+	for data := range stream.frames {
+		if stream.errorGet() != nil {
+			continue
+		}
+		if err := stream.write(data); err != nil {
+			stream.mutex.Lock()
+			stream.err = err
+			stream.mutex.Unlock()
+		}
+	}
+}
+
+func (stream *ioWriterStream) errorGet() error {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.err
+}
+
+func (stream *ioWriterStream) frameWrite(as *AudioStream, frame *Frame) error {
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		return nil
+	}
+	if err := stream.errorGet(); err != nil {
+		return err
+	}
+	data := append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...)
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	if stream.closing {
+		return nil
+	}
+	select {
+	case stream.frames <- data:
+	default:
+		stream.dropped++
+	}
+	return nil
+}
+
+func (stream *ioWriterStream) destroy(as *AudioStream) error {
+	stream.mutex.Lock()
+	if !stream.closing {
+		stream.closing = true
+		close(stream.frames)
+	}
+	stream.mutex.Unlock()
+	<-stream.done
+	return stream.errorGet()
+}
+
+func (stream *ioWriterStream) droppedGet() uint64 {
+	stream.mutex.Lock()
+	defer stream.mutex.Unlock()
+	return stream.dropped
+}
+
+/* Stream reading and writing audio by frames, e.g. over WebSocket or gRPC */
+type ioDuplexStream struct {
+	reader *ioReaderStream
+	writer *ioWriterStream
+}
+
+/*
+ * Create duplex audio stream, the writer is flushed before the reader is closed on destroy.
+ * The closing function (may be nil) is called first on destroy, e.g. to unblock the writer.
+ */
+func ioDuplexAudioStreamCreate(reader *ioReaderStream, writer *ioWriterStream, closing func()) *AudioStream {
+	stream := &ioDuplexStream{reader: reader, writer: writer}
+	vtable := &AudioStreamVTable{
+		Destroy: func(as *AudioStream) error {
+			if closing != nil {
+				closing()
+			}
+			err := writer.destroy(as)
+			if closeErr := reader.destroy(as); err == nil {
+				err = closeErr
+			}
+			return err
+		},
+		ReadFrame:  reader.frameRead,
+		WriteFrame: writer.frameWrite,
+	}
+	return AudioStreamCreate(stream, vtable, StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX))
+}
+
+/* Get the error, safe to read as soon as the end of the audio is reported */
+func (stream *ioReaderStream) errorGet() error {
+	if !stream.eof {
+		return nil
+	}
+	return stream.err
+}
+
+/**
+ * Get error the reader or writer of io termination stream failed with.
+ * @param stream the stream of the termination created by TerminationFromReader, TerminationFromWriter
+ * or WebSocketTerminationCreate
+ */
+func IOStreamErrorGet(as *AudioStream) error {
+	switch stream := as.Obj.(type) {
+	case *ioReaderStream:
+		return stream.errorGet()
+	case *ioWriterStream:
+		return stream.errorGet()
+	case *ioDuplexStream:
+		if err := stream.writer.errorGet(); err != nil {
+			return err
+		}
+		return stream.reader.errorGet()
+	}
+	return fmt.Errorf("AudioStream.Obj is not io stream")
+}
+
+/**
+ * Get number of frames dropped since the writer of io termination stream is late.
+ * @param stream the stream of the termination created by TerminationFromWriter or WebSocketTerminationCreate
+ */
+func IOStreamDroppedGet(as *AudioStream) uint64 {
+	switch stream := as.Obj.(type) {
+	case *ioWriterStream:
+		return stream.droppedGet()
+	case *ioDuplexStream:
+		return stream.writer.droppedGet()
+	}
+	return 0
+}
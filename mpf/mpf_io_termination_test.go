@@ -0,0 +1,46 @@
+package mpf
+
+import (
+	"bytes"
+	"runtime"
+	"testing"
+)
+
+func TestIOTermination(t *testing.T) {
+	audio := bytes.Repeat([]byte{1, 2, 3, 4}, 400)
+	player, err := TerminationFromReader(bytes.NewReader(audio), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events := 0
+	player.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == AUDIO_FILE_COMPLETE_EVENT {
+			events++
+		}
+		return nil
+	}
+	var sink bytes.Buffer
+	recorder, err := TerminationFromWriter(&sink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	source, writer := player.TerminationAudioStreamGet(), recorder.TerminationAudioStreamGet()
+	for events == 0 {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+		runtime.Gosched()
+	}
+	if err := AudioStreamDestroy(writer); err != nil {
+		t.Fatal(err)
+	}
+	AudioStreamDestroy(source)
+	if !bytes.Equal(sink.Bytes(), audio) || IOStreamDroppedGet(writer) != 0 || IOStreamErrorGet(source) != nil {
+		t.Fatalf("%d bytes piped, want %d", sink.Len(), len(audio))
+	}
+}
@@ -1,26 +1,73 @@
 package mpf
 
+import "fmt"
+
 /** Jitter buffer write result */
 type JbResult = int
 
 const (
 	JB_OK                   JbResult = iota /**< successful write */
-	JB_DISCARD_NOT_ALLIGNED                 /**< discarded write (frame isn't alligned to CODEC_FRAME_TIME_BASE) */
+	JB_DISCARD_NOT_ALLIGNED                 /**< discarded write (frame isn't alligned to the frame duration) */
 	JB_DISCARD_TOO_LATE                     /**< discarded write (frame is arrived too late) */
 	JB_DISCARD_TOO_EARLY                    /**< discarded write (frame is arrived too early, buffer is full) */
+	JB_DISCARD_DUPLICATE                    /**< discarded write (frame is already buffered) */
 )
 
+/** Length of the buffer in msec if max playout delay is not configured */
+const JB_LENGTH_DEFAULT = 500
+
+/** Playout delay of adaptive jitter buffer in the interarrival jitters measured */
+const JB_JITTER_FACTOR = 3
+
+/** Statistics of jitter buffer */
+type JitterBufferStat struct {
+	/** Number of frames written */
+	WrittenFrames uint32
+	/** Number of frames of audio read */
+	ReadFrames uint32
+	/** Number of frames missing as read (lost or not arrived in time), the frames of the playout delay excluded */
+	LostFrames uint32
+	/** Number of frames discarded as arrived too late (already played out) */
+	DiscardedLate uint32
+	/** Number of frames discarded as arrived too early (buffer is full) */
+	DiscardedEarly uint32
+	/** Number of frames discarded as not aligned to frame */
+	DiscardedNotAligned uint32
+	/** Number of frames discarded as duplicate */
+	DiscardedDuplicate uint32
+	/** Number of times the buffer is drained (the write is synchronized again) */
+	Underflows uint32
+	/** Number of frames of silence inserted to grow the playout delay */
+	InsertedFrames uint32
+	/** Number of frames of silence deleted to shrink the playout delay */
+	DeletedFrames uint32
+	/** Number of frames buffered, up to the last one written (the gaps included) */
+	Occupancy uint32
+	/** Max number of frames buffered */
+	MaxOccupancy uint32
+}
+
+/**
+ * Jitter buffer of the frames received, played out after the delay.
+ * The write is synchronized by the first frame of talkspurt (or after the buffer is drained),
+ * which is put the playout delay ahead of the read. The delay converges to the target one
+ * by insertion/deletion of frames of silence (comfort noise or the delay before talkspurt),
+ * the target is the configured delay, or follows the interarrival jitter measured if adaptive.
+ * The buffer is not guarded, the owner is to lock it.
+ */
 type JitterBuffer struct {
 
 	/* jitter buffer config */
 	config *JbConfig
 	/* codec to be used to dissect payload */
 	codec *Codec
+	/* descriptor of the audio buffered */
+	descriptor *CodecDescriptor
 
-	/* cyclic raw data */
-	RawData []byte
-	/* frames (out of raw data) */
-	frames []*Frame
+	/* frames (cyclic), slot of the frame at read pointer first */
+	frames []Frame
+	/* slot of the frame at read pointer */
+	readPos int64
 	/* number of frames */
 	frameCount int64
 	/* frame timestamp units (samples) */
@@ -30,6 +77,10 @@ type JitterBuffer struct {
 
 	/* playout delay in timetsamp units */
 	playoutDelayTs uint32
+	/* playout delay the read converges to in timetsamp units */
+	targetDelayTs uint32
+	/* min playout delay in timetsamp units */
+	minPlayOutDelayTs uint32
 	/* max playout delay in timetsamp units */
 	maxPlayOutDelayTs uint32
 
@@ -37,11 +88,22 @@ type JitterBuffer struct {
 	writeSync byte
 	/* write timestamp offset */
 	writeTsOffset int32
+	/* read pointer the first frame since the write synchronized is played out at, the frames before are of the delay (not lost) */
+	syncTs uint32
 
 	/* write pointer in timestamp units */
 	writeTs uint32
 	/* read pointer in timestamp units */
 	readTs uint32
+	/* clock of the read (advanced by every read) in timestamp units, the arrivals are measured by */
+	clockTs uint32
+
+	/* transit time (arrival minus timestamp) of the last packet written */
+	transit int32
+	/* transit time is measured */
+	transitSet bool
+	/* interarrival jitter in timestamp units, scaled by 16 (RFC 3550 A.8) */
+	jitter uint32
 
 	/* min length of the buffer in timestamp units */
 	minLengthTs int32
@@ -56,39 +118,458 @@ type JitterBuffer struct {
 	eventWriteBase NamedEventFrame
 	/* the last received update for the event */
 	eventWriteUpdate *NamedEventFrame
+
+	/* statistics */
+	stat JitterBufferStat
 }
 
-/** Create jitter buffer */
+/**
+ * Create jitter buffer.
+ * @param jbConfig the config (playout delays in msec)
+ * @param descriptor the descriptor of the audio buffered
+ * @param codec the codec to dissect payload to frames by, nil for linear audio (or variable size frames)
+ */
 func JitterBufferCreate(jbConfig *JbConfig, descriptor *CodecDescriptor, codec *Codec) *JitterBuffer {
-	return nil
+	if descriptor == nil {
+		return nil
+	}
+	if jbConfig == nil {
+		jbConfig = JbConfigAlloc()
+	}
+	jb := &JitterBuffer{
+		codec:      codec,
+		descriptor: descriptor,
+		frameTs:    descriptor.CodecFrameTimestampCalculate(),
+		writeSync:  1,
+	}
+	if codec != nil {
+		jb.frameSize = codec.CodecFrameSizeGet(descriptor)
+	} else if CodecLPcmDescriptorMatch(descriptor) {
+		jb.frameSize = descriptor.CodecLinearFrameSizeGet()
+	}
+	if jb.frameTs <= 0 {
+		return nil
+	}
+	if err := jb.JitterBufferConfigUpdate(jbConfig); err != nil {
+		return nil
+	}
+	return jb
 }
 
 /** Destroy jitter buffer */
 func JitterBufferDestroy(jb *JitterBuffer) error {
+	if jb == nil {
+		return nil
+	}
+	jb.frames = nil
+	jb.frameCount = 0
 	return nil
 }
 
-/** Restart jitter buffer */
+/** Restart jitter buffer, the frames buffered are discarded and the next write is synchronized */
 func JitterBufferRestart(jb *JitterBuffer) error {
+	if jb == nil {
+		return nil
+	}
+	for i := range jb.frames {
+		jitterBufferFrameClear(&jb.frames[i])
+	}
+	jb.readPos = 0
+	jb.readTs = 0
+	jb.writeTs = 0
+	jb.writeTsOffset = 0
+	jb.writeSync = 1
+	jb.eventWriteUpdate = nil
+	/* the jitter of the new source is measured anew */
+	jb.transitSet = false
+	jb.jitter = 0
+	if jb.config != nil {
+		jb.targetDelayTs = jb.msecToTs(jb.config.initialPlayOutDelay)
+	}
 	return nil
 }
 
-/** Write audio data to jitter buffer */
-func (jb *JitterBuffer) JitterBufferWrite(buffer []byte, ts uint32, marker byte) JbResult {
-	return 0
+/* Convert msec to timestamp units by the frame duration and the clock rate of the audio buffered */
+func (jb *JitterBuffer) msecToTs(msec uint32) uint32 {
+	return uint32(int64(msec) * jb.frameTs / jb.descriptor.CodecFrameDurationGet())
+}
+
+/* Convert timestamp units to msec by the frame duration and the clock rate of the audio buffered */
+func (jb *JitterBuffer) tsToMsec(ts uint32) uint32 {
+	return uint32(int64(ts) * jb.descriptor.CodecFrameDurationGet() / jb.frameTs)
+}
+
+/* Clear slot of frame, the buffer of the data is kept to be reused */
+func jitterBufferFrameClear(frame *Frame) {
+	frame.Type = MEDIA_FRAME_TYPE_NONE
+	frame.Marker = MPF_MARKER_NONE
+	frame.PayloadType = 0
+	frame.EventFrame = NamedEventFrame{}
+	frame.AudioLevel = FrameAudioLevel{}
+	frame.CodecFrame.Size = 0
+	if frame.CodecFrame.Buffer != nil {
+		frame.CodecFrame.Buffer.Reset()
+	}
+}
+
+/* Get slot of frame by the offset from the read pointer in timestamp units */
+func (jb *JitterBuffer) frameGet(offsetTs uint32) *Frame {
+	return &jb.frames[(jb.readPos+int64(offsetTs)/jb.frameTs)%jb.frameCount]
 }
 
-/** Write named event to jitter buffer */
+/* Get number of frames buffered */
+func (jb *JitterBuffer) occupancyGet() uint32 {
+	if jb.writeSync == 1 || int32(jb.writeTs-jb.readTs) <= 0 {
+		return 0
+	}
+	return uint32(int64(jb.writeTs-jb.readTs) / jb.frameTs)
+}
+
+/**
+ * Write payload of RTP packet to jitter buffer, the payload is dissected to frames.
+ * @param buffer the payload
+ * @param payloadType the payload type of the packet, the payload of the type other than the one of the audio (e.g. CN) is a single frame
+ * @param ts the timestamp of the packet
+ * @param marker the packet starts talkspurt, the write is synchronized if nothing is buffered
+ */
+func (jb *JitterBuffer) JitterBufferWrite(buffer []byte, payloadType RtpPayloadType, ts uint32, marker byte) JbResult {
+	return jb.JitterBufferLevelWrite(buffer, payloadType, ts, marker, FrameAudioLevel{})
+}
+
+/**
+ * Write payload of RTP packet along with the audio level signalled (RFC 6464) to jitter buffer,
+ * the level is set to each frame of the payload (@see JitterBufferWrite()).
+ * @param level the audio level of the packet, not present if not signalled
+ */
+func (jb *JitterBuffer) JitterBufferLevelWrite(buffer []byte, payloadType RtpPayloadType, ts uint32, marker byte, level FrameAudioLevel) JbResult {
+	frameSize := jb.frameSize
+	if frameSize == 0 || payloadType != jb.descriptor.PayloadType {
+		frameSize = int64(len(buffer))
+	}
+	if frameSize == 0 {
+		return JB_OK
+	}
+	frameCount := int64(len(buffer)) / frameSize
+	jb.arrivalMeasure(ts)
+	jb.writeSyncCheck(ts, marker)
+
+	offsetTs := ts - uint32(jb.writeTsOffset) - jb.readTs
+	if int32(offsetTs) < 0 {
+		jb.stat.DiscardedLate += uint32(frameCount)
+		return JB_DISCARD_TOO_LATE
+	}
+	if int64(offsetTs)%jb.frameTs != 0 {
+		jb.stat.DiscardedNotAligned += uint32(frameCount)
+		return JB_DISCARD_NOT_ALLIGNED
+	}
+	if int64(offsetTs)/jb.frameTs+frameCount > jb.frameCount {
+		jb.stat.DiscardedEarly += uint32(frameCount)
+		return JB_DISCARD_TOO_EARLY
+	}
+
+	result := JB_OK
+	for i := int64(0); i < frameCount; i++ {
+		frame := jb.frameGet(offsetTs)
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+			jb.stat.DiscardedDuplicate++
+			result = JB_DISCARD_DUPLICATE
+		} else {
+			/* the audio is played along with the event written, if any */
+			frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+			frame.PayloadType = payloadType
+			frame.AudioLevel = level
+			if err := codecFrameDataSet(&frame.CodecFrame, buffer[i*frameSize:(i+1)*frameSize]); err != nil {
+				return JB_DISCARD_NOT_ALLIGNED
+			}
+			jb.stat.WrittenFrames++
+		}
+		offsetTs += uint32(jb.frameTs)
+	}
+	jb.writeTsUpdate(offsetTs)
+	return result
+}
+
+/* Synchronize write by the frame of timestamp, if nothing is buffered or the frame starts talkspurt while the buffer is drained */
+func (jb *JitterBuffer) writeSyncCheck(ts uint32, marker byte) {
+	if jb.writeSync == 1 || (marker == 1 && jb.occupancyGet() == 0) {
+		/* the first frame is played out after the delay, the target one is reached at once */
+		jb.playoutDelayTs = jb.targetDelayTs
+		jb.writeTsOffset = int32(ts - (jb.readTs + jb.playoutDelayTs))
+		jb.writeTs = jb.readTs
+		jb.syncTs = jb.readTs + jb.playoutDelayTs
+		jb.writeSync = 0
+	}
+}
+
+/* Advance write pointer up to the offset from the read pointer written up to */
+func (jb *JitterBuffer) writeTsUpdate(offsetTs uint32) {
+	if writeTs := jb.readTs + offsetTs; int32(writeTs-jb.writeTs) > 0 {
+		jb.writeTs = writeTs
+	}
+	if occupancy := jb.occupancyGet(); occupancy > jb.stat.MaxOccupancy {
+		jb.stat.MaxOccupancy = occupancy
+	}
+}
+
+/* Measure interarrival jitter by the packet of timestamp arrived, the target delay follows it if adaptive */
+func (jb *JitterBuffer) arrivalMeasure(ts uint32) {
+	transit := int32(jb.clockTs - ts)
+	if jb.transitSet {
+		deviation := int64(transit - jb.transit)
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		if deviation > jb.frameCount*jb.frameTs {
+			/* discontinuity of timestamps, not a jitter */
+			deviation = 0
+		}
+		jb.jitter += uint32(deviation) - ((jb.jitter + 8) >> 4)
+	}
+	jb.transit = transit
+	jb.transitSet = true
+	if jb.config.adaptive == 1 {
+		jb.targetDelayTs = jb.adaptiveDelayGet()
+	}
+}
+
+/* Get playout delay covering the interarrival jitter, in whole frames within min/max playout delay */
+func (jb *JitterBuffer) adaptiveDelayGet() uint32 {
+	delay := int64(JB_JITTER_FACTOR*jb.jitter) >> 4
+	delay = (delay + jb.frameTs - 1) / jb.frameTs * jb.frameTs
+	maxDelay := int64(jb.maxPlayOutDelayTs)
+	if maxDelay == 0 || maxDelay > (jb.frameCount-1)*jb.frameTs {
+		maxDelay = (jb.frameCount - 1) * jb.frameTs
+	}
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+	if delay < int64(jb.minPlayOutDelayTs) {
+		delay = int64(jb.minPlayOutDelayTs)
+	}
+	return uint32(delay)
+}
+
+/* Is frame at read pointer of silence, so that it may be inserted/deleted */
+func (jb *JitterBuffer) frameSilent(frame *Frame) bool {
+	if frame.Type == MEDIA_FRAME_TYPE_NONE {
+		/* the delay before talkspurt */
+		return int32(jb.readTs-jb.syncTs) < 0
+	}
+	return frame.PayloadType == RTP_PT_CN
+}
+
+/* Advance read pointer by a frame, the frame is cleared */
+func (jb *JitterBuffer) frameAdvance() {
+	jitterBufferFrameClear(&jb.frames[jb.readPos])
+	jb.readPos = (jb.readPos + 1) % jb.frameCount
+	jb.readTs += uint32(jb.frameTs)
+}
+
+/**
+ * Write named event to jitter buffer (RFC4733): the start of the event is played out at the timestamp of the event,
+ * the updates (the end) at the timestamp the duration reported is up to. The updates not advancing the event
+ * (e.g. retransmitted end of the event) are ignored, the event late is played out at once not to be missed.
+ * @param namedEvent the named event reported
+ * @param ts the timestamp of the event (the start of it)
+ * @param marker the packet starts the event
+ */
 func (jb *JitterBuffer) JitterBufferEventWrite(namedEvent *NamedEventFrame, ts uint32, marker byte) JbResult {
-	return 0
+	jb.writeSyncCheck(ts, marker)
+
+	update := jb.eventWriteUpdate
+	if update == nil || ts != jb.eventWriteBaseTs {
+		eventMarker := MPF_MARKER_START_OF_EVENT
+		if update != nil && update.Edge == 0 && update.EventId == namedEvent.EventId {
+			/* the next segment of long-lasting event (RFC4733 2.5.1.3) */
+			eventMarker = MPF_MARKER_NEW_SEGMENT
+		}
+		jb.eventWriteBaseTs = ts
+		jb.eventWriteBase = *namedEvent
+		event := *namedEvent
+		jb.eventWriteUpdate = &event
+		result := jb.eventFrameWrite(namedEvent, ts, eventMarker)
+		if result == JB_OK && namedEvent.Edge == 1 {
+			/* the start of short event is lost, it is ended as well */
+			result = jb.eventFrameWrite(namedEvent, ts+jb.eventDurationTsGet(namedEvent), MPF_MARKER_END_OF_EVENT)
+		}
+		return result
+	}
+
+	if update.Edge == 1 || namedEvent.Duration < update.Duration ||
+		(namedEvent.Duration == update.Duration && namedEvent.Edge == 0) {
+		/* retransmitted or reordered */
+		return JB_OK
+	}
+	*update = *namedEvent
+	eventMarker := MPF_MARKER_NONE
+	if namedEvent.Edge == 1 {
+		eventMarker = MPF_MARKER_END_OF_EVENT
+	}
+	return jb.eventFrameWrite(namedEvent, ts+jb.eventDurationTsGet(namedEvent), eventMarker)
+}
+
+/* Get duration of event up to its last frame in timestamp units */
+func (jb *JitterBuffer) eventDurationTsGet(namedEvent *NamedEventFrame) uint32 {
+	if int64(namedEvent.Duration) <= jb.frameTs {
+		return 0
+	}
+	return namedEvent.Duration - uint32(jb.frameTs)
+}
+
+/* Write frame of event at the timestamp, the frames starting/ending events are not overwritten by the others */
+func (jb *JitterBuffer) eventFrameWrite(namedEvent *NamedEventFrame, ts uint32, marker FrameMarker) JbResult {
+	offsetTs := ts - uint32(jb.writeTsOffset) - jb.readTs
+	if int32(offsetTs) < 0 {
+		offsetTs = 0
+	}
+	offsetTs -= uint32(int64(offsetTs) % jb.frameTs)
+	for ; int64(offsetTs)/jb.frameTs < jb.frameCount; offsetTs += uint32(jb.frameTs) {
+		frame := jb.frameGet(offsetTs)
+		if (frame.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT && frame.Marker != MPF_MARKER_NONE {
+			if marker == MPF_MARKER_NONE {
+				/* the update is superseded by the start/end of the event */
+				return JB_OK
+			}
+			continue
+		}
+		frame.Type |= MEDIA_FRAME_TYPE_EVENT
+		frame.Marker = marker
+		frame.EventFrame = *namedEvent
+		jb.stat.WrittenFrames++
+		jb.writeTsUpdate(offsetTs + uint32(jb.frameTs))
+		return JB_OK
+	}
+	jb.stat.DiscardedEarly++
+	return JB_DISCARD_TOO_EARLY
 }
 
-/** Read media frame from jitter buffer */
+/**
+ * Read media frame due from jitter buffer, the frame has no audio (type MEDIA_FRAME_TYPE_NONE)
+ * if it is lost or nothing is buffered (the frame is left intact then).
+ * @param mediaFrame the frame to read
+ */
 func (jb *JitterBuffer) JitterBufferRead(mediaFrame *Frame) error {
+	jb.clockTs += uint32(jb.frameTs)
+	if jb.occupancyGet() == 0 {
+		if jb.writeSync == 0 {
+			/* drained, the next write is synchronized by the playout delay again */
+			jb.writeSync = 1
+			jb.stat.Underflows++
+		}
+		return nil
+	}
+	frame := &jb.frames[jb.readPos]
+	if jb.frameSilent(frame) {
+		switch {
+		case jb.playoutDelayTs < jb.targetDelayTs:
+			/* the silence is stretched by a frame (the read pointer is held), the delay grows */
+			jb.playoutDelayTs += uint32(jb.frameTs)
+			jb.stat.InsertedFrames++
+			if frame.Type == MEDIA_FRAME_TYPE_NONE {
+				return nil
+			}
+			return FrameCopy(mediaFrame, frame)
+		case jb.playoutDelayTs > jb.targetDelayTs && jb.occupancyGet() > 1:
+			/* the silence is shortened by a frame (the frame is skipped), the delay shrinks */
+			jb.playoutDelayTs -= uint32(jb.frameTs)
+			jb.stat.DeletedFrames++
+			jb.frameAdvance()
+			frame = &jb.frames[jb.readPos]
+		}
+	}
+	var err error
+	if frame.Type != MEDIA_FRAME_TYPE_NONE {
+		err = FrameCopy(mediaFrame, frame)
+		jb.stat.ReadFrames++
+	} else if int32(jb.readTs-jb.syncTs) >= 0 {
+		jb.stat.LostFrames++
+	}
+	jb.frameAdvance()
+	return err
+}
+
+/* Resize cyclic frames to the number of frames, the frames buffered are kept (as many as fit) */
+func (jb *JitterBuffer) framesResize(frameCount int64) {
+	if frameCount == jb.frameCount {
+		return
+	}
+	frames := make([]Frame, frameCount)
+	for i := int64(0); i < jb.frameCount && i < frameCount; i++ {
+		frames[i] = jb.frames[(jb.readPos+i)%jb.frameCount]
+	}
+	jb.frames = frames
+	jb.frameCount = frameCount
+	jb.readPos = 0
+	if maxWriteTs := jb.readTs + uint32(frameCount*jb.frameTs); jb.writeSync == 0 && int32(jb.writeTs-maxWriteTs) > 0 {
+		jb.writeTs = maxWriteTs
+	}
+}
+
+/** Get statistics of jitter buffer */
+func (jb *JitterBuffer) JitterBufferStatGet() JitterBufferStat {
+	stat := jb.stat
+	stat.Occupancy = jb.occupancyGet()
+	return stat
+}
+
+/**
+ * Update config of jitter buffer live.
+ * The playout delay is changed gradually by the reader, the buffered frames are not discarded.
+ * @param jbConfig the new config
+ */
+func (jb *JitterBuffer) JitterBufferConfigUpdate(jbConfig *JbConfig) error {
+	if jbConfig == nil {
+		return fmt.Errorf("jitter buffer config is nil")
+	}
+	config := *jbConfig
+	if err := config.JbConfigValidate(); err != nil {
+		return err
+	}
+	jb.config = &config
+	if jb.frameTs > 0 {
+		jb.targetDelayTs = jb.msecToTs(config.initialPlayOutDelay)
+		jb.minPlayOutDelayTs = jb.msecToTs(config.minPlayOutDelay)
+		jb.maxPlayOutDelayTs = jb.msecToTs(config.maxPlayOutDelay)
+
+		/* the buffer holds up to max playout delay, at least a frame beyond the initial one */
+		frameDuration := jb.descriptor.CodecFrameDurationGet()
+		length := int64(config.maxPlayOutDelay)
+		if length == 0 {
+			length = JB_LENGTH_DEFAULT
+		}
+		if length < int64(config.initialPlayOutDelay)+frameDuration {
+			length = int64(config.initialPlayOutDelay) + frameDuration
+		}
+		jb.framesResize(length / frameDuration)
+		if jb.writeSync == 1 {
+			jb.playoutDelayTs = jb.targetDelayTs
+		}
+	}
 	return nil
 }
 
-/** Get current playout delay */
+/** Get config of jitter buffer */
+func (jb *JitterBuffer) JitterBufferConfigGet() *JbConfig {
+	return jb.config
+}
+
+/** Get current playout delay in msec */
 func (jb *JitterBuffer) JitterBufferPlayOutDelayGet() uint32 {
-	return 0
+	if jb.frameTs <= 0 {
+		return 0
+	}
+	return jb.tsToMsec(jb.playoutDelayTs)
+}
+
+/** Get target playout delay in msec, the current one converges to it in silence */
+func (jb *JitterBuffer) JitterBufferTargetDelayGet() uint32 {
+	if jb.frameTs <= 0 {
+		return 0
+	}
+	return jb.tsToMsec(jb.targetDelayTs)
+}
+
+/** Get length of jitter buffer (the frames it holds at most) in msec */
+func (jb *JitterBuffer) JitterBufferLengthGet() uint32 {
+	return uint32(jb.frameCount * jb.descriptor.CodecFrameDurationGet())
 }
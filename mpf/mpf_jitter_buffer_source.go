@@ -0,0 +1,144 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Reader of the frames buffered by jitter buffer (@see JitterBuffer) */
+type JitterBufferReader interface {
+	/** Read frame due, the frame has no audio (type MEDIA_FRAME_TYPE_NONE) on underrun */
+	JitterBufferRead(frame *Frame) error
+}
+
+/** Stat of jitter buffer source */
+type JitterBufferSourceStat struct {
+	/** Number of frames read */
+	Frames uint64
+	/** Number of frames of no audio in the buffer (underrun) */
+	Underruns uint64
+	/** Number of frames of underrun concealed by PLC */
+	Concealed uint64
+	/** Number of frames of underrun filled by silence */
+	Silence uint64
+}
+
+/**
+ * Source derived from audio stream, pulling frames from jitter buffer at the rate of the local
+ * media clock (the rate the stream is read at) instead of directly from the network.
+ * Underrun is concealed by PLC (linear audio, up to PLC_MAX_FRAMES) and filled by silence then.
+ * It sits between the RTP receiver writing packets to the jitter buffer and the context.
+ */
+type JitterBufferSource struct {
+	/** Audio stream base */
+	base *AudioStream
+	/** Jitter buffer the frames are pulled from */
+	jb JitterBufferReader
+	/** Codec of the audio buffered to generate silence by, nil for linear audio */
+	codec *Codec
+	/** Previous (read or concealed) samples of linear audio, nil if none */
+	prev      []int16
+	concealed int
+	stat      JitterBufferSourceStat
+}
+
+/**
+ * Create source stream pulling frames from jitter buffer.
+ * @param jb the jitter buffer
+ * @param descriptor the descriptor of the audio buffered
+ * @param eventDescriptor the descriptor of named events buffered, nil if none
+ * @param codec the codec of the audio buffered, used to generate silence (may be nil for linear audio)
+ */
+func JitterBufferSourceCreate(jb JitterBufferReader, descriptor, eventDescriptor *CodecDescriptor, codec *Codec) (*AudioStream, error) {
+	if jb == nil || descriptor == nil {
+		return nil, fmt.Errorf("jitter buffer or descriptor is nil")
+	}
+	if codec == nil && !CodecLPcmDescriptorMatch(descriptor) {
+		return nil, fmt.Errorf("no codec of %s to generate silence by", descriptor.Name)
+	}
+	source := &JitterBufferSource{jb: jb}
+	if !CodecLPcmDescriptorMatch(descriptor) {
+		source.codec = codec
+	}
+	vtable := &AudioStreamVTable{
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			return source.frameRead(frame)
+		},
+	}
+	source.base = AudioStreamCreate(source, vtable, SourceStreamCapabilitiesCreate())
+	if source.base == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	source.base.RXDescriptor = descriptor
+	source.base.RXEventDescriptor = eventDescriptor
+	return source.base, nil
+}
+
+/* Read frame from jitter buffer, underrun is concealed or filled by silence */
+func (source *JitterBufferSource) frameRead(frame *Frame) error {
+	if frame.CodecFrame.Buffer == nil {
+		frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	}
+	size := source.frameSizeGet()
+	frame.CodecFrame.Size = size
+	if err := source.jb.JitterBufferRead(frame); err != nil {
+		return err
+	}
+	source.stat.Frames++
+
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		source.concealed = 0
+		if source.codec == nil {
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			if err != nil {
+				return err
+			}
+			source.prev = append(source.prev[:0], samples...)
+		}
+		return nil
+	}
+
+	source.stat.Underruns++
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	frame.CodecFrame.Size = size
+	if source.codec != nil {
+		/* encoded silence, the decoder conceals nothing */
+		source.stat.Silence++
+		return source.codec.CodecInitialize(&frame.CodecFrame)
+	}
+
+	out := make([]int16, size/BYTES_PER_SAMPLE)
+	if source.prev != nil && source.concealed < PLC_MAX_FRAMES {
+		PLCLinearConceal(source.prev, out, int(source.base.RXDescriptor.SamplingRate))
+		source.prev = append(source.prev[:0], out...)
+		source.concealed++
+		source.stat.Concealed++
+	} else {
+		/* concealed for long enough, silence follows */
+		source.prev = nil
+		source.stat.Silence++
+	}
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(out))
+}
+
+/* Get size of the frame of the audio buffered */
+func (source *JitterBufferSource) frameSizeGet() int64 {
+	if source.codec != nil {
+		return source.codec.CodecFrameSizeGet(source.base.RXDescriptor)
+	}
+	return source.base.RXDescriptor.CodecLinearFrameSizeGet()
+}
+
+/**
+ * Get stat of jitter buffer source.
+ * @param stream the jitter buffer source stream
+ */
+func JitterBufferSourceStatGet(stream *AudioStream) (JitterBufferSourceStat, error) {
+	source, ok := stream.Obj.(*JitterBufferSource)
+	if !ok {
+		return JitterBufferSourceStat{}, fmt.Errorf("AudioStream.Obj is not *JitterBufferSource")
+	}
+	return source.stat, nil
+}
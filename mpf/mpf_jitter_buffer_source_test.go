@@ -0,0 +1,82 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Jitter buffer of the frames queued, underrun if none */
+type jitterBufferTestReader struct {
+	frames [][]int16
+}
+
+func (jb *jitterBufferTestReader) JitterBufferRead(frame *Frame) error {
+	if len(jb.frames) == 0 || jb.frames[0] == nil {
+		if len(jb.frames) > 0 {
+			jb.frames = jb.frames[1:]
+		}
+		return nil
+	}
+	frame.Type = MEDIA_FRAME_TYPE_AUDIO
+	samples := jb.frames[0]
+	jb.frames = jb.frames[1:]
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+}
+
+func TestJitterBufferSource(t *testing.T) {
+	voice := make([]int16, 80)
+	for i := range voice {
+		voice[i] = int16(1000 * (i%20 - 10))
+	}
+	jb := &jitterBufferTestReader{frames: [][]int16{voice, nil, voice}}
+	source, err := JitterBufferSourceCreate(jb, CodecLPcmDescriptorCreate(8000, 1), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	/* the loss is concealed, the underrun after PLC_MAX_FRAMES is filled by silence */
+	var frame Frame
+	for i := 0; i < 3+PLC_MAX_FRAMES+2; i++ {
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		if err := source.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == 0 || len(samples) != 80 {
+			t.Fatalf("frame %d of type %d and %d samples", i, frame.Type, len(samples))
+		}
+		if i == 1 && samples[0] == 0 {
+			t.Fatalf("loss is not concealed")
+		}
+		if i == 3+PLC_MAX_FRAMES+1 && samples[0] != 0 {
+			t.Fatalf("underrun is not filled by silence")
+		}
+	}
+	stat, _ := JitterBufferSourceStatGet(source)
+	if stat.Frames != 3+PLC_MAX_FRAMES+2 || stat.Underruns != 1+PLC_MAX_FRAMES+2 || stat.Concealed != 1+PLC_MAX_FRAMES || stat.Silence != 2 {
+		t.Fatalf("stat %+v", stat)
+	}
+
+	/* encoded silence is generated by the codec */
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = RTP_PT_PCMU
+	descriptor.Name = "PCMU"
+	descriptor.SamplingRate = 8000
+	descriptor.ChannelCount = 1
+	codec, err := CodecManagerDefaultCreate().CodecManagerCodecGet(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := JitterBufferSourceCreate(&jitterBufferTestReader{}, descriptor, nil, nil); err == nil {
+		t.Fatalf("source of encoded audio is created without codec")
+	}
+	source, err = JitterBufferSourceCreate(&jitterBufferTestReader{}, descriptor, nil, codec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame = Frame{}
+	if err := source.AudioStreamFrameRead(&frame); err != nil || (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == 0 || frame.CodecFrame.Size != 80 {
+		t.Fatalf("silence of type %d and size %d: %v", frame.Type, frame.CodecFrame.Size, err)
+	}
+}
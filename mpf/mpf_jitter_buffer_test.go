@@ -0,0 +1,260 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+/* Create jitter buffer config of the params given by name/value pairs */
+func jitterBufferTestConfig(params ...string) *JbConfig {
+	jbConfig := JbConfigAlloc()
+	for i := 0; i+1 < len(params); i += 2 {
+		jbConfig.JbConfigParamSet(params[i], params[i+1])
+	}
+	return jbConfig
+}
+
+/* Create jitter buffer of PCMU (frames of 80 bytes) */
+func jitterBufferTestCreate(t *testing.T, jbConfig *JbConfig) *JitterBuffer {
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jb := JitterBufferCreate(jbConfig, descriptor, codec)
+	if jb == nil {
+		t.Fatal("failed to create jitter buffer")
+	}
+	return jb
+}
+
+/* Read frame of jitter buffer, return the first byte of the audio (0 if none) */
+func jitterBufferTestRead(t *testing.T, jb *JitterBuffer) byte {
+	frame := Frame{}
+	if err := jb.JitterBufferRead(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type == MEDIA_FRAME_TYPE_NONE {
+		return 0
+	}
+	data := codecFrameDataGet(&frame.CodecFrame)
+	if len(data) != 80 || frame.PayloadType != RTP_PT_PCMU {
+		t.Fatalf("frame of %d bytes, payload type %d", len(data), frame.PayloadType)
+	}
+	return data[0]
+}
+
+func TestJitterBuffer(t *testing.T) {
+	jb := jitterBufferTestCreate(t, jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "20", JB_PARAM_MAX_PLAYOUT_DELAY, "100"))
+	if jb.JitterBufferPlayOutDelayGet() != 20 || jb.JitterBufferLengthGet() != 100 {
+		t.Fatalf("playout delay %d, length %d", jb.JitterBufferPlayOutDelayGet(), jb.JitterBufferLengthGet())
+	}
+	for _, write := range []struct {
+		ts     uint32
+		data   byte
+		marker byte
+		result JbResult
+	}{
+		{1000, 1, 1, JB_OK},
+		/* reordered */
+		{1160, 3, 0, JB_OK},
+		{1080, 2, 0, JB_OK},
+		{1080, 2, 0, JB_DISCARD_DUPLICATE},
+		{1040, 9, 0, JB_DISCARD_NOT_ALLIGNED},
+		/* beyond the length of the buffer */
+		{1000 + 80*20, 9, 0, JB_DISCARD_TOO_EARLY},
+	} {
+		if result := jb.JitterBufferWrite(bytes.Repeat([]byte{write.data}, 80), RTP_PT_PCMU, write.ts, write.marker); result != write.result {
+			t.Fatalf("write of ts %d results in %d, want %d", write.ts, result, write.result)
+		}
+	}
+	if stat := jb.JitterBufferStatGet(); stat.Occupancy != 5 {
+		t.Fatalf("occupancy %d", stat.Occupancy)
+	}
+
+	/* the frames are played out in order after the delay of 2 frames */
+	for i, want := range []byte{0, 0, 1, 2, 3} {
+		if data := jitterBufferTestRead(t, jb); data != want {
+			t.Fatalf("frame %d of %d, want %d", i, data, want)
+		}
+	}
+	if result := jb.JitterBufferWrite(bytes.Repeat([]byte{9}, 80), RTP_PT_PCMU, 1000, 0); result != JB_DISCARD_TOO_LATE {
+		t.Fatalf("late write results in %d", result)
+	}
+	/* the frame of ts 1240 is lost */
+	if result := jb.JitterBufferWrite(bytes.Repeat([]byte{5}, 80), RTP_PT_PCMU, 1320, 0); result != JB_OK {
+		t.Fatalf("write results in %d", result)
+	}
+	for i, want := range []byte{0, 5, 0} {
+		if data := jitterBufferTestRead(t, jb); data != want {
+			t.Fatalf("frame %d of %d, want %d", i, data, want)
+		}
+	}
+	stat := jb.JitterBufferStatGet()
+	want := JitterBufferStat{WrittenFrames: 4, ReadFrames: 4, LostFrames: 1, DiscardedLate: 1, DiscardedEarly: 1,
+		DiscardedNotAligned: 1, DiscardedDuplicate: 1, Underflows: 1, MaxOccupancy: 5}
+	if stat != want {
+		t.Fatalf("stat %+v, want %+v", stat, want)
+	}
+
+	/* the write is synchronized again after the buffer is drained, the packet of 2 frames is split */
+	if result := jb.JitterBufferWrite(append(bytes.Repeat([]byte{6}, 80), bytes.Repeat([]byte{7}, 80)...), RTP_PT_PCMU, 50000, 0); result != JB_OK {
+		t.Fatalf("write results in %d", result)
+	}
+	for i, want := range []byte{0, 0, 6, 7, 0} {
+		if data := jitterBufferTestRead(t, jb); data != want {
+			t.Fatalf("frame %d of %d, want %d", i, data, want)
+		}
+	}
+	if stat = jb.JitterBufferStatGet(); stat.LostFrames != 1 || stat.Underflows != 2 {
+		t.Fatalf("stat %+v", stat)
+	}
+}
+
+func TestJitterBufferConfigUpdate(t *testing.T) {
+	jb := jitterBufferTestCreate(t, jitterBufferTestConfig(JB_PARAM_MAX_PLAYOUT_DELAY, "50"))
+	for i := 0; i < 3; i++ {
+		jb.JitterBufferWrite(bytes.Repeat([]byte{byte(i + 1)}, 80), RTP_PT_PCMU, uint32(i*80), 0)
+	}
+	/* the buffer is resized, the frames buffered are kept */
+	if err := jb.JitterBufferConfigUpdate(jitterBufferTestConfig(JB_PARAM_MAX_PLAYOUT_DELAY, "200")); err != nil {
+		t.Fatal(err)
+	}
+	if jb.JitterBufferLengthGet() != 200 {
+		t.Fatalf("length %d", jb.JitterBufferLengthGet())
+	}
+	for i, want := range []byte{1, 2, 3} {
+		if data := jitterBufferTestRead(t, jb); data != want {
+			t.Fatalf("frame %d of %d, want %d", i, data, want)
+		}
+	}
+
+	/* the frames are discarded on restart */
+	jb.JitterBufferWrite(bytes.Repeat([]byte{4}, 80), RTP_PT_PCMU, 240, 0)
+	JitterBufferRestart(jb)
+	if data := jitterBufferTestRead(t, jb); data != 0 || jb.JitterBufferStatGet().Occupancy != 0 {
+		t.Fatalf("frame of %d is read after restart", data)
+	}
+}
+
+func TestJitterBufferTimeScale(t *testing.T) {
+	jb := jitterBufferTestCreate(t, jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "20", JB_PARAM_MAX_PLAYOUT_DELAY, "200"))
+	/* a frame is written and read every tick, the delay is changed in silence (comfort noise) only */
+	tick := 0
+	run := func(ticks int, payloadType RtpPayloadType) {
+		for end := tick + ticks; tick < end; tick++ {
+			payload := []byte{40}
+			if payloadType == RTP_PT_PCMU {
+				payload = make([]byte, 80)
+			}
+			jb.JitterBufferWrite(payload, payloadType, uint32(tick*80), 0)
+			frame := Frame{}
+			if err := jb.JitterBufferRead(&frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	run(10, RTP_PT_CN)
+	run(3, RTP_PT_PCMU)
+	jb.JitterBufferConfigUpdate(jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "50", JB_PARAM_MAX_PLAYOUT_DELAY, "200"))
+	run(10, RTP_PT_PCMU)
+	if jb.JitterBufferPlayOutDelayGet() != 20 || jb.JitterBufferTargetDelayGet() != 50 {
+		t.Fatalf("playout delay %d is changed in speech", jb.JitterBufferPlayOutDelayGet())
+	}
+	run(10, RTP_PT_CN)
+	if stat := jb.JitterBufferStatGet(); jb.JitterBufferPlayOutDelayGet() != 50 || stat.InsertedFrames != 3 {
+		t.Fatalf("playout delay %d, stat %+v", jb.JitterBufferPlayOutDelayGet(), stat)
+	}
+	jb.JitterBufferConfigUpdate(jitterBufferTestConfig(JB_PARAM_MAX_PLAYOUT_DELAY, "200"))
+	run(10, RTP_PT_CN)
+	stat := jb.JitterBufferStatGet()
+	if jb.JitterBufferPlayOutDelayGet() != 0 || stat.DeletedFrames != 5 || stat.LostFrames != 0 || stat.Underflows != 0 {
+		t.Fatalf("playout delay %d, stat %+v", jb.JitterBufferPlayOutDelayGet(), stat)
+	}
+}
+
+func TestJitterBufferAdaptive(t *testing.T) {
+	jb := jitterBufferTestCreate(t, jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "20", JB_PARAM_MAX_PLAYOUT_DELAY, "200", JB_PARAM_ADAPTIVE, "1"))
+	/* talkspurt of packets of 20 msec, every other packet is delayed by 40 msec, the silence follows */
+	talkspurt := func(ts uint32) {
+		const packets = 50
+		arrivals := map[int][]int{}
+		for i := 0; i < packets; i++ {
+			arrival := 2 * i
+			if i%2 == 1 {
+				arrival += 4
+			}
+			arrivals[arrival] = append(arrivals[arrival], i)
+		}
+		for tick := 0; tick < 2*packets+50; tick++ {
+			for _, i := range arrivals[tick] {
+				var marker byte
+				if i == 0 {
+					marker = 1
+				}
+				jb.JitterBufferWrite(make([]byte, 160), RTP_PT_PCMU, ts+uint32(i*160), marker)
+			}
+			frame := Frame{}
+			if err := jb.JitterBufferRead(&frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	talkspurt(0)
+	late := jb.JitterBufferStatGet().DiscardedLate
+	if late == 0 || jb.JitterBufferTargetDelayGet() < 40 || jb.JitterBufferTargetDelayGet() > 200 {
+		t.Fatalf("target delay %d, %d frames late", jb.JitterBufferTargetDelayGet(), late)
+	}
+	/* the delay grown is applied as the next talkspurt starts, no frames are late anymore */
+	talkspurt(100000)
+	if stat := jb.JitterBufferStatGet(); stat.DiscardedLate != late || jb.JitterBufferPlayOutDelayGet() < 40 {
+		t.Fatalf("playout delay %d, stat %+v", jb.JitterBufferPlayOutDelayGet(), stat)
+	}
+}
+
+func TestJitterBufferFrameDuration(t *testing.T) {
+	/* PCMU of 20 msec frames (160 bytes, 160 timestamp units) */
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1, FrameDuration: 20}
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jb := JitterBufferCreate(jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "60", JB_PARAM_MIN_PLAYOUT_DELAY, "40",
+		JB_PARAM_MAX_PLAYOUT_DELAY, "200"), descriptor, codec)
+	if jb == nil {
+		t.Fatal("failed to create jitter buffer")
+	}
+	if jb.targetDelayTs != 480 || jb.minPlayOutDelayTs != 320 || jb.maxPlayOutDelayTs != 1600 {
+		t.Fatalf("delays of %d, %d, %d timestamp units", jb.targetDelayTs, jb.minPlayOutDelayTs, jb.maxPlayOutDelayTs)
+	}
+	if jb.frameCount != 10 || jb.JitterBufferLengthGet() != 200 || jb.JitterBufferPlayOutDelayGet() != 60 || jb.JitterBufferTargetDelayGet() != 60 {
+		t.Fatalf("%d frames of %d msec, playout delay %d, target delay %d", jb.frameCount, jb.JitterBufferLengthGet(),
+			jb.JitterBufferPlayOutDelayGet(), jb.JitterBufferTargetDelayGet())
+	}
+
+	/* the first frame is played out after 60 msec, 3 frames of 20 msec */
+	jb.JitterBufferWrite(bytes.Repeat([]byte{1}, 160), RTP_PT_PCMU, 0, 1)
+	for i := 0; ; i++ {
+		frame := Frame{}
+		if err := jb.JitterBufferRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != MEDIA_FRAME_TYPE_NONE {
+			if data := codecFrameDataGet(&frame.CodecFrame); len(data) != 160 || data[0] != 1 || i != 3 {
+				t.Fatalf("frame of %d bytes is played out after %d frames", len(data), i)
+			}
+			break
+		}
+		if i > 10 {
+			t.Fatal("frame is not played out")
+		}
+	}
+
+	/* the config updated live is converted by the frame duration too */
+	if err := jb.JitterBufferConfigUpdate(jitterBufferTestConfig(JB_PARAM_PLAYOUT_DELAY, "100", JB_PARAM_MAX_PLAYOUT_DELAY, "400")); err != nil {
+		t.Fatal(err)
+	}
+	if jb.targetDelayTs != 800 || jb.maxPlayOutDelayTs != 3200 || jb.frameCount != 20 || jb.JitterBufferTargetDelayGet() != 100 {
+		t.Fatalf("target delay of %d timestamp units, max of %d, %d frames", jb.targetDelayTs, jb.maxPlayOutDelayTs, jb.frameCount)
+	}
+}
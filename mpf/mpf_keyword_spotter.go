@@ -0,0 +1,296 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Level of frames in dBFS speech segments are gated by */
+const KEYWORD_SPOTTER_GATE_LEVEL = -45
+
+/** Duration of silence in msec completing speech segment */
+const KEYWORD_SPOTTER_SEGMENT_GAP = 200
+
+/** Max distance (mean deviation of the energy envelopes in dB) the segment matches template within */
+const KEYWORD_SPOTTER_DISTANCE_THRESHOLD = 3.0
+
+/** Event id of termination event raised when keyword is spotted */
+const MPF_KEYWORD_SPOTTED_EVENT = 1
+
+/** Keyword spotted */
+type KeywordSpotterEvent struct {
+	/** Keyword (template or model specific name) */
+	Keyword string
+	/** Confidence of the detection (0 .. 1) */
+	Confidence float64
+}
+
+/**
+ * Model spotting keywords in linear audio, the built-in one matches energy templates,
+ * other models (e.g. neural wake word detectors) may be plugged in.
+ */
+type KeywordModel interface {
+	/** Process 10 msec frame of linear samples, returning the keyword spotted (if any) */
+	KeywordModelProcess(samples []int16) (*KeywordSpotterEvent, error)
+	/** Reset the state of the model */
+	KeywordModelReset()
+}
+
+/** Energy envelope template of keyword */
+type KeywordTemplate struct {
+	/** Keyword name */
+	Name string
+	/** Energy envelope (normalized log energies of 10 msec frames) */
+	envelope []float64
+}
+
+/** Keyword spotter matching speech segments gated by energy with templates by dynamic time warping */
+type KeywordTemplateSpotter struct {
+	templates []*KeywordTemplate
+	/** Max distance to match templates within */
+	threshold float64
+	/** Max length of segment in frames, longer ones are not matched */
+	maxFrames int
+
+	/** Energies of the current segment frames */
+	segment []float64
+	/** Number of consecutive silent frames in the segment */
+	silence int
+}
+
+/* Get log energy of the frame in dBFS */
+func keywordFrameEnergy(samples []int16) float64 {
+	var energy float64
+	for _, sample := range samples {
+		energy += float64(sample) * float64(sample)
+	}
+	if len(samples) == 0 || energy == 0 {
+		return -96
+	}
+	return 10 * math.Log10(energy/float64(len(samples))/(32768*32768))
+}
+
+/* Normalize envelope by its mean, so that matching is invariant to the gain */
+func keywordEnvelopeNormalize(envelope []float64) []float64 {
+	var mean float64
+	for _, e := range envelope {
+		mean += e
+	}
+	mean /= float64(len(envelope))
+	normalized := make([]float64, len(envelope))
+	for i, e := range envelope {
+		normalized[i] = e - mean
+	}
+	return normalized
+}
+
+/* Get distance of envelopes by dynamic time warping, normalized by the path length */
+func keywordEnvelopeDistance(a, b []float64) float64 {
+	inf := math.Inf(1)
+	cost := make([][]float64, len(a)+1)
+	steps := make([][]int, len(a)+1)
+	for i := range cost {
+		cost[i] = make([]float64, len(b)+1)
+		steps[i] = make([]int, len(b)+1)
+		for j := range cost[i] {
+			cost[i][j] = inf
+		}
+	}
+	cost[0][0] = 0
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			bi, bj := i-1, j-1
+			if cost[i-1][j] < cost[bi][bj] {
+				bj = j
+			}
+			if cost[i][j-1] < cost[bi][bj] {
+				bi, bj = i, j-1
+			}
+			cost[i][j] = cost[bi][bj] + math.Abs(a[i-1]-b[j-1])
+			steps[i][j] = steps[bi][bj] + 1
+		}
+	}
+	return cost[len(a)][len(b)] / float64(steps[len(a)][len(b)])
+}
+
+/**
+ * Create keyword template by sample utterance, leading and trailing silence is trimmed.
+ * @param name the keyword name
+ * @param samples the linear samples of the utterance
+ * @param descriptor the descriptor of the (linear, mono) audio
+ */
+func KeywordTemplateCreate(name string, samples []int16, descriptor *CodecDescriptor) (*KeywordTemplate, error) {
+	frameSamples := int(descriptor.CodecFrameSamplesCalculate())
+	if frameSamples == 0 {
+		return nil, fmt.Errorf("invalid descriptor")
+	}
+	var envelope []float64
+	for i := 0; i+frameSamples <= len(samples); i += frameSamples {
+		envelope = append(envelope, keywordFrameEnergy(samples[i:i+frameSamples]))
+	}
+	start, end := 0, len(envelope)
+	for start < end && envelope[start] < KEYWORD_SPOTTER_GATE_LEVEL {
+		start++
+	}
+	for end > start && envelope[end-1] < KEYWORD_SPOTTER_GATE_LEVEL {
+		end--
+	}
+	if start == end {
+		return nil, fmt.Errorf("no speech in keyword %s utterance", name)
+	}
+	return &KeywordTemplate{Name: name, envelope: keywordEnvelopeNormalize(envelope[start:end])}, nil
+}
+
+/**
+ * Create keyword spotter by templates.
+ * @param templates the keyword templates
+ * @param threshold the max distance to match templates within (KEYWORD_SPOTTER_DISTANCE_THRESHOLD by default)
+ */
+func KeywordTemplateSpotterCreate(templates []*KeywordTemplate, threshold float64) (*KeywordTemplateSpotter, error) {
+	if len(templates) == 0 {
+		return nil, fmt.Errorf("no keyword templates")
+	}
+	spotter := &KeywordTemplateSpotter{templates: templates, threshold: threshold}
+	for _, template := range templates {
+		if len(template.envelope)*2 > spotter.maxFrames {
+			spotter.maxFrames = len(template.envelope) * 2
+		}
+	}
+	return spotter, nil
+}
+
+/** Reset the segment being collected */
+func (s *KeywordTemplateSpotter) KeywordModelReset() {
+	s.segment = nil
+	s.silence = 0
+}
+
+/** Process frame, the segment is matched with templates as soon as it is completed by silence */
+func (s *KeywordTemplateSpotter) KeywordModelProcess(samples []int16) (*KeywordSpotterEvent, error) {
+	energy := keywordFrameEnergy(samples)
+	if energy < KEYWORD_SPOTTER_GATE_LEVEL {
+		if len(s.segment) == 0 {
+			return nil, nil
+		}
+		s.silence++
+		if s.silence*CODEC_FRAME_TIME_BASE < KEYWORD_SPOTTER_SEGMENT_GAP {
+			s.segment = append(s.segment, energy)
+			return nil, nil
+		}
+		segment := s.segment[:len(s.segment)-s.silence+1]
+		s.KeywordModelReset()
+		return s.match(segment), nil
+	}
+	s.silence = 0
+	s.segment = append(s.segment, energy)
+	if len(s.segment) > s.maxFrames {
+		/* too long to be a keyword, wait for the next segment */
+		s.segment = s.segment[1:]
+	}
+	return nil, nil
+}
+
+/* Match segment with templates, the best match within the threshold is spotted */
+func (s *KeywordTemplateSpotter) match(segment []float64) *KeywordSpotterEvent {
+	if len(segment) == 0 {
+		return nil
+	}
+	envelope := keywordEnvelopeNormalize(segment)
+	var best *KeywordSpotterEvent
+	bestDistance := s.threshold
+	for _, template := range s.templates {
+		if len(envelope)*2 < len(template.envelope) || len(envelope) > len(template.envelope)*2 {
+			continue
+		}
+		if distance := keywordEnvelopeDistance(envelope, template.envelope); distance <= bestDistance {
+			bestDistance = distance
+			best = &KeywordSpotterEvent{Keyword: template.Name, Confidence: 1 - distance/(2*s.threshold)}
+		}
+	}
+	return best
+}
+
+/* Stream spotting keywords in the audio written to the sink */
+type keywordSpotterStream struct {
+	base    *AudioStream
+	sink    *AudioStream
+	model   KeywordModel
+	handler func(event *KeywordSpotterEvent)
+	/** Is the audio gated until keyword is spotted */
+	gated bool
+	/** Is keyword spotted (the gate is open) */
+	spotted bool
+}
+
+/**
+ * Create stream spotting keywords in the audio written to the (linear) sink, e.g. of recognizer.
+ * When keyword is spotted, the handler is invoked and the termination event MPF_KEYWORD_SPOTTED_EVENT is raised.
+ * @param sink the sink of the audio
+ * @param model the keyword model
+ * @param gated the audio is passed to the sink only after keyword is spotted (hotword-gated recognition)
+ * @param handler the handler of spotted keywords (may be nil)
+ */
+func KeywordSpotterStreamCreate(sink *AudioStream, model KeywordModel, gated bool, handler func(event *KeywordSpotterEvent)) *AudioStream {
+	if sink == nil || model == nil {
+		return nil
+	}
+	stream := &keywordSpotterStream{sink: sink, model: model, handler: handler, gated: gated}
+	vtable := &AudioStreamVTable{
+		Destroy:    func(*AudioStream) error { return AudioStreamDestroy(sink) },
+		OpenTX:     func(_ *AudioStream, codec *Codec) error { return sink.AudioStreamTXOpen(codec) },
+		CloseTX:    func(*AudioStream) error { return sink.AudioStreamTXClose() },
+		WriteFrame: stream.frameWrite,
+	}
+	stream.base = AudioStreamCreate(stream, vtable, StreamCapabilitiesClone(sink.Capabilities))
+	if stream.base == nil {
+		return nil
+	}
+	stream.base.TXDescriptor = sink.TXDescriptor
+	stream.base.TXEventDescriptor = sink.TXEventDescriptor
+	stream.base.FrameWriteInPlace = sink.FrameWriteInPlace
+	return stream.base
+}
+
+func (stream *keywordSpotterStream) frameWrite(_ *AudioStream, frame *Frame) error {
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && !stream.spotted {
+		samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if err != nil {
+			return err
+		}
+		event, err := stream.model.KeywordModelProcess(samples)
+		if err != nil {
+			return err
+		}
+		if event != nil {
+			stream.spotted = true
+			if stream.handler != nil {
+				stream.handler(event)
+			}
+			if termination := stream.sink.termination; termination != nil && termination.EventHandler != nil {
+				if err := termination.EventHandler(termination, MPF_KEYWORD_SPOTTED_EVENT, event); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	if stream.gated && !stream.spotted {
+		return nil
+	}
+	return stream.sink.AudioStreamFrameWrite(frame)
+}
+
+/**
+ * Re-arm keyword spotter stream, e.g. when the recognition is complete.
+ * @param stream the keyword spotter stream
+ */
+func KeywordSpotterStreamReset(stream *AudioStream) error {
+	spotter, ok := stream.Obj.(*keywordSpotterStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not keyword spotter stream")
+	}
+	spotter.spotted = false
+	spotter.model.KeywordModelReset()
+	return nil
+}
@@ -0,0 +1,76 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Utterance of tone bursts of the amplitudes (100 msec each) between silence */
+func keywordTestUtterance(amplitudes ...float64) []int16 {
+	samples := make([]int16, 800)
+	for _, amp := range amplitudes {
+		samples = append(samples, loudnessTestSine(400, 8000, 100, amp)...)
+	}
+	return append(samples, make([]int16, 4000)...)
+}
+
+func TestKeywordSpotterStream(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	template, err := KeywordTemplateCreate("hello", keywordTestUtterance(8000, 800, 4000), descriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spotter, err := KeywordTemplateSpotterCreate([]*KeywordTemplate{template}, KEYWORD_SPOTTER_DISTANCE_THRESHOLD)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	written := 0
+	recognizer := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			written++
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	recognizer.TXDescriptor = descriptor
+	var events []*KeywordSpotterEvent
+	stream := KeywordSpotterStreamCreate(recognizer, spotter, true, func(event *KeywordSpotterEvent) {
+		events = append(events, event)
+	})
+
+	write := func(samples []int16) {
+		for i := 0; i+80 <= len(samples); i += 80 {
+			frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+			codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples[i:i+80]))
+			if err := stream.AudioStreamFrameWrite(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	/* other word is not spotted, the audio is gated */
+	write(keywordTestUtterance(800, 8000, 800, 8000))
+	if len(events) != 0 || written != 0 {
+		t.Fatalf("%d keywords spotted, %d frames passed", len(events), written)
+	}
+
+	/* the keyword spoken louder is spotted, the audio passes afterwards */
+	write(keywordTestUtterance(16000, 1600, 8000))
+	if len(events) != 1 || events[0].Keyword != "hello" || events[0].Confidence < 0.5 {
+		t.Fatalf("keyword is not spotted: %+v", events)
+	}
+	if written == 0 {
+		t.Fatalf("audio is gated after keyword is spotted")
+	}
+
+	/* re-armed spotter gates the audio again */
+	if err := KeywordSpotterStreamReset(stream); err != nil {
+		t.Fatal(err)
+	}
+	written = 0
+	write(make([]int16, 800))
+	if written != 0 {
+		t.Fatalf("%d frames passed after reset", written)
+	}
+}
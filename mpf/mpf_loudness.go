@@ -0,0 +1,233 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Normalization modes of captured audio */
+type NormalizationMode = int
+
+const (
+	MPF_NORMALIZATION_NONE     NormalizationMode = iota /**< no normalization */
+	MPF_NORMALIZATION_PEAK                              /**< peak normalization to target dBFS */
+	MPF_NORMALIZATION_LOUDNESS                          /**< loudness normalization (EBU R128) to target LUFS */
+)
+
+/** Default target level of peak normalization in dBFS */
+const MPF_NORMALIZATION_PEAK_TARGET = -1.0
+
+/** Default target level of loudness normalization in LUFS (EBU R128) */
+const MPF_NORMALIZATION_LOUDNESS_TARGET = -23.0
+
+/** Max true-peak level allowed after loudness normalization in dBFS */
+const MPF_NORMALIZATION_PEAK_LIMIT = -1.0
+
+/** Gating block duration in msec (ITU-R BS.1770) */
+const LOUDNESS_BLOCK_DURATION = 400
+
+/** Absolute gating threshold in LUFS */
+const LOUDNESS_ABSOLUTE_GATE = -70.0
+
+/** Relative gating threshold in LU */
+const LOUDNESS_RELATIVE_GATE = -10.0
+
+/** Biquad filter state */
+type biquadFilter struct {
+	b0, b1, b2, a1, a2 float64
+	z1, z2             float64
+}
+
+func (f *biquadFilter) process(x float64) float64 {
+	y := f.b0*x + f.z1
+	f.z1 = f.b1*x - f.a1*y + f.z2
+	f.z2 = f.b2*x - f.a2*y
+	return y
+}
+
+/* K-weighting filters (high shelf followed by high pass) for the sampling rate */
+func kWeightingFiltersCreate(samplingRate float64) (*biquadFilter, *biquadFilter) {
+	var (
+		f0 = 1681.974450955533
+		g  = 3.999843853973347
+		q  = 0.7071752369554196
+		k  = math.Tan(math.Pi * f0 / samplingRate)
+		vh = math.Pow(10, g/20)
+		vb = math.Pow(vh, 0.4996667741545416)
+		a0 = 1 + k/q + k*k
+	)
+	shelf := &biquadFilter{
+		b0: (vh + vb*k/q + k*k) / a0,
+		b1: 2 * (k*k - vh) / a0,
+		b2: (vh - vb*k/q + k*k) / a0,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+
+	f0 = 38.13547087602444
+	q = 0.5003270373238773
+	k = math.Tan(math.Pi * f0 / samplingRate)
+	a0 = 1 + k/q + k*k
+	highPass := &biquadFilter{
+		b0: 1,
+		b1: -2,
+		b2: 1,
+		a1: 2 * (k*k - 1) / a0,
+		a2: (1 - k/q + k*k) / a0,
+	}
+	return shelf, highPass
+}
+
+/**
+ * Measure integrated loudness of linear audio (ITU-R BS.1770 / EBU R128).
+ * @param samples the interleaved samples
+ * @param samplingRate the sampling rate
+ * @param channelCount the number of channels
+ * @return loudness in LUFS, -Inf for silence
+ */
+func LoudnessMeasure(samples []int16, samplingRate int, channelCount int) float64 {
+	if channelCount <= 0 {
+		channelCount = 1
+	}
+	var (
+		frames  = len(samples) / channelCount
+		filters = make([][2]*biquadFilter, channelCount)
+		/* squared K-weighted samples summed over channels */
+		power = make([]float64, frames)
+	)
+	for ch := range filters {
+		filters[ch][0], filters[ch][1] = kWeightingFiltersCreate(float64(samplingRate))
+	}
+	for i := 0; i < frames; i++ {
+		for ch := 0; ch < channelCount; ch++ {
+			x := float64(samples[i*channelCount+ch]) / 32768
+			y := filters[ch][1].process(filters[ch][0].process(x))
+			power[i] += y * y
+		}
+	}
+
+	/* gating blocks of 400 ms overlapping by 75% */
+	blockSize := samplingRate * LOUDNESS_BLOCK_DURATION / 1000
+	step := blockSize / 4
+	if blockSize <= 0 || frames < blockSize {
+		blockSize = frames
+		step = frames
+	}
+	if blockSize == 0 {
+		return math.Inf(-1)
+	}
+	var blocks []float64
+	for start := 0; start+blockSize <= frames; start += step {
+		var sum float64
+		for _, p := range power[start : start+blockSize] {
+			sum += p
+		}
+		blocks = append(blocks, sum/float64(blockSize))
+	}
+
+	loudness := func(meanSquare float64) float64 {
+		return -0.691 + 10*math.Log10(meanSquare)
+	}
+	gatedMean := func(threshold float64) float64 {
+		var (
+			sum   float64
+			count int
+		)
+		for _, z := range blocks {
+			if loudness(z) > threshold {
+				sum += z
+				count++
+			}
+		}
+		if count == 0 {
+			return 0
+		}
+		return sum / float64(count)
+	}
+
+	mean := gatedMean(LOUDNESS_ABSOLUTE_GATE)
+	if mean == 0 {
+		return math.Inf(-1)
+	}
+	mean = gatedMean(loudness(mean) + LOUDNESS_RELATIVE_GATE)
+	if mean == 0 {
+		return math.Inf(-1)
+	}
+	return loudness(mean)
+}
+
+/**
+ * Measure sample peak level of linear audio.
+ * @param samples the samples
+ * @return peak level in dBFS, -Inf for silence
+ */
+func PeakMeasure(samples []int16) float64 {
+	var peak float64
+	for _, sample := range samples {
+		if v := math.Abs(float64(sample)); v > peak {
+			peak = v
+		}
+	}
+	return 20 * math.Log10(peak/32768)
+}
+
+/* Apply gain in dB to samples with saturation */
+func gainApply(samples []int16, gain float64) {
+	factor := math.Pow(10, gain/20)
+	for i, sample := range samples {
+		v := math.Round(float64(sample) * factor)
+		if v > math.MaxInt16 {
+			v = math.MaxInt16
+		} else if v < math.MinInt16 {
+			v = math.MinInt16
+		}
+		samples[i] = int16(v)
+	}
+}
+
+/**
+ * Normalize captured linear audio.
+ * @param data the little-endian 16-bit linear audio, normalized in place
+ * @param descriptor the codec descriptor of the audio
+ * @param mode the normalization mode
+ * @param target the target level (dBFS for peak, LUFS for loudness normalization)
+ */
+func AudioNormalize(data []byte, descriptor *CodecDescriptor, mode NormalizationMode, target float64) error {
+	if mode == MPF_NORMALIZATION_NONE {
+		return nil
+	}
+	samples, err := binaryx.ByteSliceToInt16Slice(data)
+	if err != nil {
+		return err
+	}
+
+	peak := PeakMeasure(samples)
+	if math.IsInf(peak, -1) {
+		/* nothing to normalize in silence */
+		return nil
+	}
+
+	var gain float64
+	switch mode {
+	case MPF_NORMALIZATION_PEAK:
+		gain = target - peak
+	case MPF_NORMALIZATION_LOUDNESS:
+		loudness := LoudnessMeasure(samples, int(descriptor.SamplingRate), int(descriptor.ChannelCount))
+		if math.IsInf(loudness, -1) {
+			return nil
+		}
+		gain = target - loudness
+		/* do not let the peaks clip */
+		if peak+gain > MPF_NORMALIZATION_PEAK_LIMIT {
+			gain = MPF_NORMALIZATION_PEAK_LIMIT - peak
+		}
+	default:
+		return fmt.Errorf("unknown normalization mode %d", mode)
+	}
+
+	gainApply(samples, gain)
+	copy(data, binaryx.Int16SliceToByteSlice(samples))
+	return nil
+}
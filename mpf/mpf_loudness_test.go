@@ -0,0 +1,49 @@
+package mpf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func loudnessTestSine(freq float64, samplingRate int, ms int, amp float64) []int16 {
+	samples := make([]int16, samplingRate*ms/1000)
+	for i := range samples {
+		samples[i] = int16(amp * math.Sin(2*math.Pi*freq*float64(i)/float64(samplingRate)))
+	}
+	return samples
+}
+
+func TestLoudnessMeasure(t *testing.T) {
+	/* full scale 1 kHz sine reads about -3 LUFS */
+	loudness := LoudnessMeasure(loudnessTestSine(1000, 48000, 2000, 32767), 48000, 1)
+	if math.Abs(loudness-(-3.01)) > 0.5 {
+		t.Errorf("loudness %.2f LUFS, expected about -3 LUFS", loudness)
+	}
+	if loudness := LoudnessMeasure(make([]int16, 16000), 8000, 1); !math.IsInf(loudness, -1) {
+		t.Errorf("loudness of silence %.2f LUFS, expected -Inf", loudness)
+	}
+}
+
+func TestAudioNormalize(t *testing.T) {
+	descriptor := &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}
+
+	data := binaryx.Int16SliceToByteSlice(loudnessTestSine(440, 8000, 3000, 1000))
+	if err := AudioNormalize(data, descriptor, MPF_NORMALIZATION_LOUDNESS, MPF_NORMALIZATION_LOUDNESS_TARGET); err != nil {
+		t.Fatal(err)
+	}
+	samples, _ := binaryx.ByteSliceToInt16Slice(data)
+	if loudness := LoudnessMeasure(samples, 8000, 1); math.Abs(loudness-MPF_NORMALIZATION_LOUDNESS_TARGET) > 0.5 {
+		t.Errorf("loudness %.2f LUFS after normalization, expected %.2f", loudness, MPF_NORMALIZATION_LOUDNESS_TARGET)
+	}
+
+	data = binaryx.Int16SliceToByteSlice(loudnessTestSine(440, 8000, 1000, 1000))
+	if err := AudioNormalize(data, descriptor, MPF_NORMALIZATION_PEAK, -6); err != nil {
+		t.Fatal(err)
+	}
+	samples, _ = binaryx.ByteSliceToInt16Slice(data)
+	if peak := PeakMeasure(samples); math.Abs(peak-(-6)) > 0.1 {
+		t.Errorf("peak %.2f dBFS after normalization, expected -6", peak)
+	}
+}
@@ -1,5 +1,173 @@
 package mpf
 
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Release of the limiter protecting the mix from saturation in dB per second */
+const MIXER_LIMITER_RELEASE = 30.0
+
+/** MPF mixer derived from MPF object */
+type Mixer struct {
+	/** MPF mixer base */
+	base *Object
+	/** Audio stream sources (linear audio of the sink descriptor) */
+	sources []*AudioStream
+	/** Audio stream sink */
+	sink *AudioStream
+	/** Frames used to read data from sources */
+	frames []Frame
+	/** Frame of the mix written to sink */
+	frame Frame
+
+	mutex sync.Mutex
+	/** Weights (factors) of the sources */
+	weights []float64
+	limiter mixLimiter
+	/** Pacer of the frames of the sink, if the audio of any source is re-framed */
+	pacer *framePacer
+	/** Mix of the samples of the sources */
+	mix []float64
+}
+
+/** Process mixer: read frames from sources, mix them and write the mix to sink */
+func (mixer *Mixer) MixerProcess() error {
+	size := int(mixer.frame.CodecFrame.Size / BYTES_PER_SAMPLE)
+	if len(mixer.mix) != size {
+		mixer.mix = make([]float64, size)
+	}
+	for i := range mixer.mix {
+		mixer.mix[i] = 0
+	}
+
+	out := &mixer.frame
+	out.Type = MEDIA_FRAME_TYPE_NONE
+	out.Marker = MPF_MARKER_NONE
+	mixer.mutex.Lock()
+	weights := mixer.weights
+	mixer.mutex.Unlock()
+	for i, source := range mixer.sources {
+		frame := &mixer.frames[i]
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		frame.Marker = MPF_MARKER_NONE
+		frame.CodecFrame.Size = out.CodecFrame.Size
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			return err
+		}
+
+		if (frame.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT && (out.Type&MEDIA_FRAME_TYPE_EVENT) == 0 {
+			/* the event of the first source sending one is passed through */
+			out.Type |= MEDIA_FRAME_TYPE_EVENT
+			out.Marker = frame.Marker
+			out.EventFrame = frame.EventFrame
+		}
+		if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO || source.RXDescriptor == nil {
+			/* missing frame (and event-only source) is mixed as silence */
+			continue
+		}
+		samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if err != nil {
+			return err
+		}
+		out.Type |= MEDIA_FRAME_TYPE_AUDIO
+		weight := weights[i]
+		if len(samples) > size {
+			samples = samples[:size]
+		}
+		/* partially filled frame is padded by silence */
+		for n, sample := range samples {
+			mixer.mix[n] += weight * float64(sample)
+		}
+	}
+
+	if (out.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		out.CodecFrame.Buffer.Reset()
+		return mixer.sink.AudioStreamFrameWrite(out)
+	}
+	return mixer.sink.AudioStreamFrameWrite(mixer.limit(out))
+}
+
+/* Limit the mix to full scale and set it to the frame */
+func (mixer *Mixer) limit(frame *Frame) *Frame {
+	samples := mixer.limiter.process(mixer.mix, mixer.sink.TXDescriptor)
+	_ = codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+	return frame
+}
+
+/* Limiter protecting mix from saturation */
+type mixLimiter struct {
+	/** Gain of the limiter, 1 unless the mix saturates */
+	gain float64
+}
+
+/* Limit the mix of the (linear) descriptor to full scale */
+func (limiter *mixLimiter) process(mix []float64, descriptor *CodecDescriptor) []int16 {
+	var peak float64
+	for _, v := range mix {
+		if v = math.Abs(v); v > peak {
+			peak = v
+		}
+	}
+	/* attack at once, release smoothly */
+	if peak*limiter.gain <= math.MaxInt16 {
+		duration := float64(len(mix)) / float64(descriptor.ChannelCount) * 1000 / float64(descriptor.SamplingRate)
+		limiter.gain = math.Min(1, limiter.gain*math.Pow(10, MIXER_LIMITER_RELEASE*duration/1000/20))
+	}
+	if peak*limiter.gain > math.MaxInt16 {
+		limiter.gain = math.MaxInt16 / peak
+	}
+
+	samples := make([]int16, len(mix))
+	for i, v := range mix {
+		samples[i] = timeStretchSaturate(v * limiter.gain)
+	}
+	return samples
+}
+
+/**
+ * Set weights (factors) the audio of the sources is mixed by.
+ * @param weights the weights in the order of the sources, nil - unity
+ */
+func (mixer *Mixer) MixerWeightsSet(weights []float64) error {
+	if weights == nil {
+		weights = make([]float64, len(mixer.sources))
+		for i := range weights {
+			weights[i] = 1
+		}
+	}
+	if len(weights) != len(mixer.sources) {
+		return fmt.Errorf("%d weights of %d sources", len(weights), len(mixer.sources))
+	}
+	for _, weight := range weights {
+		if weight < 0 || math.IsNaN(weight) || math.IsInf(weight, 0) {
+			return fmt.Errorf("invalid weight %f", weight)
+		}
+	}
+	mixer.mutex.Lock()
+	defer mixer.mutex.Unlock()
+	mixer.weights = append([]float64(nil), weights...)
+	return nil
+}
+
+/** Destroy mixer: close sources and sink */
+func (mixer *Mixer) MixerDestroy() error {
+	var err error
+	for _, source := range mixer.sources {
+		if e := source.AudioStreamRXClose(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := mixer.sink.AudioStreamTXClose(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
 /**
  * Create audio stream mixer.
  * @param source_arr the array of audio sources
@@ -10,5 +178,107 @@ package mpf
  * @param pool the pool to allocate memory from
  */
 func MixerCreate(sourceArr []*AudioStream, sourceCount int64, sink *AudioStream, codecManager *CodecManager, name string) *Object {
-	return nil
+	if sourceCount < 0 || sourceCount > int64(len(sourceArr)) {
+		return nil
+	}
+	mixer, err := MixerWeightedCreate(sourceArr[:sourceCount], nil, sink, codecManager, name)
+	if err != nil {
+		return nil
+	}
+	return mixer.base
+}
+
+/**
+ * Create audio stream mixer summing the sources by weights, protected from saturation by limiter.
+ * The sources are decoded, resampled and converted to the channels of the sink as needed.
+ * @param sourceArr the audio sources
+ * @param weights the weights (factors) of the sources, nil - unity
+ * @param sink the audio sink
+ * @param codecManager the codec manager
+ * @param name the informative name used for debugging
+ */
+func MixerWeightedCreate(sourceArr []*AudioStream, weights []float64, sink *AudioStream, codecManager *CodecManager, name string) (*Mixer, error) {
+	if len(sourceArr) == 0 || sink == nil || sink.TXDescriptor == nil {
+		return nil, fmt.Errorf("no sources or sink")
+	}
+	var err error
+	if sink, err = linearSinkCreate(sink, codecManager); err != nil {
+		return nil, err
+	}
+
+	mixer := &Mixer{
+		base:    ObjectInit(name),
+		sink:    sink,
+		sources: make([]*AudioStream, len(sourceArr)),
+		frames:  make([]Frame, len(sourceArr)),
+		limiter: mixLimiter{gain: 1},
+	}
+	sheddable := true
+	for i, source := range sourceArr {
+		if source == nil || (source.RXDescriptor == nil && !eventOnlySourceCheck(source)) {
+			return nil, fmt.Errorf("source %d is nil", i)
+		}
+		sheddable = sheddable && AudioStreamSheddable(source)
+		if eventOnlySourceCheck(source) {
+			/* named events of event-only source are passed through, no audio is mixed */
+			mixer.sources[i] = source
+			mixer.frames[i].CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+			continue
+		}
+		if source, err = linearSourceCreate(source, sink.TXDescriptor, codecManager); err != nil {
+			return nil, err
+		}
+		if duration := sink.TXDescriptor.CodecFrameDurationGet(); source.RXDescriptor.CodecFrameDurationGet() != duration {
+			/* set re-framer before mixer, the frames of the sink are paced */
+			if source, err = ReFramerCreate(source, duration); err != nil {
+				return nil, err
+			}
+			mixer.pacer = &framePacer{duration: duration}
+		}
+		mixer.sources[i] = source
+		mixer.frames[i].CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	}
+	if err = mixer.MixerWeightsSet(weights); err != nil {
+		return nil, err
+	}
+	mixer.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	mixer.frame.CodecFrame.Size = sink.TXDescriptor.CodecLinearFrameSizeGet()
+
+	mixer.base.Sheddable = sheddable
+	mixer.base.Destroy = func(object *Object) error {
+		return mixer.MixerDestroy()
+	}
+	mixer.base.Process = func(object *Object) error {
+		if mixer.pacer == nil {
+			return mixer.MixerProcess()
+		}
+		/* frames of the sink are processed as they are due */
+		for count := mixer.pacer.tick(object.TickDuration); count > 0; count-- {
+			if err := mixer.MixerProcess(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i, source := range mixer.sources {
+		if err = source.AudioStreamRXOpen(nil); err != nil {
+			for _, opened := range mixer.sources[:i] {
+				_ = opened.AudioStreamRXClose()
+			}
+			return nil, err
+		}
+	}
+	if err = sink.AudioStreamTXOpen(nil); err != nil {
+		for _, source := range mixer.sources {
+			_ = source.AudioStreamRXClose()
+		}
+		return nil, err
+	}
+	return mixer, nil
+}
+
+/** Get MPF object of mixer */
+func (mixer *Mixer) MixerObjectGet() *Object {
+	return mixer.base
 }
@@ -0,0 +1,110 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Create source of constant samples, count of -1 - full frame, 0 - no audio */
+func mixerTestSource(value int16, count *int) *AudioStream {
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			n := int(frame.CodecFrame.Size / BYTES_PER_SAMPLE)
+			if *count == 0 {
+				return nil
+			}
+			if *count > 0 {
+				n = *count
+			}
+			samples := make([]int16, n)
+			for i := range samples {
+				samples[i] = value
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	return source
+}
+
+func TestMixer(t *testing.T) {
+	var written []int16
+	var writtenType FrameType
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			writtenType = frame.Type
+			written, _ = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+
+	full, partial, missing := -1, 40, 0
+	mixer, err := MixerWeightedCreate([]*AudioStream{
+		mixerTestSource(1000, &full),
+		mixerTestSource(3000, &partial),
+		mixerTestSource(5000, &missing),
+	}, []float64{1, 0.5, 1}, sink, CodecManagerDefaultCreate(), "mix")
+	if err != nil {
+		t.Fatal(err)
+	}
+	object := mixer.MixerObjectGet()
+	if err := object.ObjectProcess(); err != nil {
+		t.Fatal(err)
+	}
+	/* the partially filled frame is padded by silence, the missing one is skipped */
+	if len(written) != 80 || written[0] != 2500 || written[39] != 2500 || written[40] != 1000 || written[79] != 1000 {
+		t.Fatalf("mixed %d samples %v", len(written), written)
+	}
+
+	/* the sum saturating is limited, not wrapped */
+	_ = mixer.MixerWeightsSet([]float64{30, 1, 1})
+	missing = -1
+	if err := object.ObjectProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if written[0] <= 32000 || written[40] <= 20000 || written[40] >= written[0] {
+		t.Fatalf("limited to %d and %d", written[0], written[40])
+	}
+
+	/* the limiter releases once the sum fits */
+	_ = mixer.MixerWeightsSet(nil)
+	for i := 0; i < 100; i++ {
+		_ = object.ObjectProcess()
+	}
+	if written[0] != 9000 || written[79] != 6000 {
+		t.Fatalf("released to %d and %d", written[0], written[79])
+	}
+
+	full, partial, missing = 0, 0, 0
+	if err := object.ObjectProcess(); err != nil || writtenType != MEDIA_FRAME_TYPE_NONE || len(written) != 0 {
+		t.Fatalf("mix of no audio %d of %d samples: %v", writtenType, len(written), err)
+	}
+	if err := mixer.MixerWeightsSet([]float64{1}); err == nil {
+		t.Fatalf("weights of less sources are set")
+	}
+
+	/* context mixes the audio of two terminations to the third one */
+	one, two := -1, -1
+	context := ContextFactoryCreate().ContextCreate("mix", nil, 3)
+	var terminations []*Termination
+	for _, stream := range []*AudioStream{mixerTestSource(100, &one), mixerTestSource(200, &two), sink} {
+		termination := TerminationBaseCreate(nil, nil, nil, stream, nil)
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+		terminations = append(terminations, termination)
+	}
+	context.ContextAssociationAdd(terminations[0], terminations[2])
+	context.ContextAssociationAdd(terminations[1], terminations[2])
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if len(written) != 80 || written[0] != 300 {
+		t.Fatalf("context mixed %d samples %v", len(written), written)
+	}
+}
@@ -1,5 +1,180 @@
 package mpf
 
+import (
+	"bytes"
+	"fmt"
+)
+
+/** Stat of multiplier */
+type MultiplierStat struct {
+	/** Number of frames read from source */
+	Frames uint64
+	/** Number of frames written to sinks shared (without copy) */
+	SharedWrites uint64
+	/** Number of frames copied for sinks processing them in place */
+	Copies uint64
+}
+
+/* Sinks of the same (linear) format the audio of the source is converted to once */
+type multiplierGroup struct {
+	/** Conversion stages reading the frame of the source, nil if no conversion needed */
+	source *AudioStream
+	/** Whether the sinks get the named events of the source only (event-only source or sinks) */
+	events bool
+	/** Frame converted, shared read-only by the sinks */
+	frame Frame
+	sinks []*AudioStream
+	/** Frames the shared frame is copied to for the sinks processing frames in place */
+	copies []Frame
+}
+
+/** MPF multiplier derived from MPF object */
+type Multiplier struct {
+	/** MPF multiplier base */
+	base *Object
+	/** Audio stream source (linear) */
+	source *AudioStream
+	/** Media frame read from source */
+	frame Frame
+	/** Sinks grouped by format */
+	groups []*multiplierGroup
+	stat   MultiplierStat
+}
+
+/** Process multiplier: read frame from source and write it to sinks, copying it only for the sinks processing it in place */
+func (multiplier *Multiplier) MultiplierProcess() error {
+	multiplier.frame.Type = MEDIA_FRAME_TYPE_NONE
+	multiplier.frame.Marker = MPF_MARKER_NONE
+	if err := multiplier.source.AudioStreamFrameRead(&multiplier.frame); err != nil {
+		return err
+	}
+	if (multiplier.frame.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+		multiplier.frame.CodecFrame.Buffer.Reset()
+	}
+	multiplier.stat.Frames++
+
+	for _, group := range multiplier.groups {
+		frame := &multiplier.frame
+		if group.events {
+			frame = &group.frame
+			frame.Type = multiplier.frame.Type & MEDIA_FRAME_TYPE_EVENT
+			frame.Marker = multiplier.frame.Marker
+			frame.EventFrame = multiplier.frame.EventFrame
+			frame.CodecFrame.Buffer.Reset()
+		} else if group.source != nil {
+			frame = &group.frame
+			frame.Type = MEDIA_FRAME_TYPE_NONE
+			frame.Marker = MPF_MARKER_NONE
+			if err := group.source.AudioStreamFrameRead(frame); err != nil {
+				return err
+			}
+			if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+				frame.CodecFrame.Buffer.Reset()
+			}
+		}
+		if err := multiplier.groupWrite(group, frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Write frame to the sinks of group, the sinks processing frames in place get copy on write */
+func (multiplier *Multiplier) groupWrite(group *multiplierGroup, frame *Frame) error {
+	for k, sink := range group.sinks {
+		out := frame
+		if sink.FrameWriteInPlace {
+			out = &group.copies[k]
+			if err := FrameCopy(out, frame); err != nil {
+				return err
+			}
+			multiplier.stat.Copies++
+		} else {
+			multiplier.stat.SharedWrites++
+		}
+		if err := sink.AudioStreamFrameWrite(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/** Get stat of multiplier */
+func (multiplier *Multiplier) MultiplierStatGet() MultiplierStat {
+	return multiplier.stat
+}
+
+/** Get MPF object of multiplier */
+func (multiplier *Multiplier) MultiplierObjectGet() *Object {
+	return multiplier.base
+}
+
+/** Destroy multiplier: close source and sinks */
+func (multiplier *Multiplier) MultiplierDestroy() error {
+	err := multiplier.source.AudioStreamRXClose()
+	for _, group := range multiplier.groups {
+		for _, sink := range group.sinks {
+			if e := sink.AudioStreamTXClose(); e != nil && err == nil {
+				err = e
+			}
+		}
+	}
+	return err
+}
+
+/* Get group of the sinks of the linear descriptor, created if none */
+func (multiplier *Multiplier) groupGet(descriptor *CodecDescriptor) (*multiplierGroup, error) {
+	for _, group := range multiplier.groups {
+		if group.events {
+			continue
+		}
+		var groupDescriptor *CodecDescriptor
+		if group.source != nil {
+			groupDescriptor = group.source.RXDescriptor
+		} else {
+			groupDescriptor = multiplier.source.RXDescriptor
+		}
+		if groupDescriptor.SamplingRate == descriptor.SamplingRate && groupDescriptor.ChannelCount == descriptor.ChannelCount {
+			return group, nil
+		}
+	}
+
+	group := &multiplierGroup{}
+	group.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	source := multiplier.source
+	if source.RXDescriptor.SamplingRate != descriptor.SamplingRate || source.RXDescriptor.ChannelCount != descriptor.ChannelCount {
+		/* stages read the frame of the source as is */
+		feed := AudioStreamCreate(multiplier, &AudioStreamVTable{
+			ReadFrame: func(_ *AudioStream, frame *Frame) error {
+				return FrameCopy(frame, &multiplier.frame)
+			},
+		}, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+		feed.RXDescriptor = source.RXDescriptor
+		feed.RXEventDescriptor = source.RXEventDescriptor
+		converted, err := linearSourceCreate(feed, descriptor, nil)
+		if err != nil {
+			return nil, err
+		}
+		group.source = converted
+		group.frame.CodecFrame.Size = converted.RXDescriptor.CodecLinearFrameSizeGet()
+	}
+	multiplier.groups = append(multiplier.groups, group)
+	return group, nil
+}
+
+/* Get group of the sinks getting named events only, created if none */
+func (multiplier *Multiplier) eventGroupGet() *multiplierGroup {
+	for _, group := range multiplier.groups {
+		if group.events {
+			return group
+		}
+	}
+	group := &multiplierGroup{events: true}
+	group.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	multiplier.groups = append(multiplier.groups, group)
+	return group
+}
+
 /**
  * Create audio stream multiplier.
  * @param source the audio source
@@ -10,5 +185,93 @@ package mpf
  * @param pool the pool to allocate memory from
  */
 func MultiplierCreate(source *AudioStream, sinkArr []*AudioStream, sinkCount int64, codecManager *CodecManager, name string) *Object {
-	return nil
+	if sinkCount < 0 || sinkCount > int64(len(sinkArr)) {
+		return nil
+	}
+	multiplier, err := MultiplierSharedCreate(source, sinkArr[:sinkCount], codecManager, name)
+	if err != nil {
+		return nil
+	}
+	return multiplier.base
+}
+
+/**
+ * Create audio stream multiplier fanning out the audio of the source to the sinks without copying:
+ * the frame read (and converted once per format of the sinks) is shared read-only by the sinks,
+ * and copied only for the sinks processing frames in place (@see AudioStream.FrameWriteInPlace).
+ * @param source the audio source
+ * @param sinkArr the audio sinks
+ * @param codecManager the codec manager
+ * @param name the informative name used for debugging
+ */
+func MultiplierSharedCreate(source *AudioStream, sinkArr []*AudioStream, codecManager *CodecManager, name string) (*Multiplier, error) {
+	if source == nil || (source.RXDescriptor == nil && !eventOnlySourceCheck(source)) || len(sinkArr) == 0 {
+		return nil, fmt.Errorf("no source or sinks")
+	}
+	if source.RXDescriptor != nil && !CodecLPcmDescriptorMatch(source.RXDescriptor) {
+		codec, err := codecManager.CodecManagerCodecGet(source.RXDescriptor)
+		if err != nil {
+			return nil, err
+		}
+		/* set decoder before multiplier */
+		if source = DecoderCreate(source, codec); source == nil {
+			return nil, fmt.Errorf("failed to create decoder")
+		}
+	}
+
+	multiplier := &Multiplier{
+		base:   ObjectInit(name),
+		source: source,
+	}
+	multiplier.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	if source.RXDescriptor != nil {
+		multiplier.frame.CodecFrame.Size = source.RXDescriptor.CodecLinearFrameSizeGet()
+	}
+	var sinks []*AudioStream
+	for i, sink := range sinkArr {
+		if sink == nil || (sink.TXDescriptor == nil && !eventOnlySinkCheck(sink)) {
+			return nil, fmt.Errorf("sink %d is nil", i)
+		}
+		if eventOnlySourceCheck(source) || eventOnlySinkCheck(sink) {
+			/* no audio flows, the sink gets named events only */
+			group := multiplier.eventGroupGet()
+			group.sinks = append(group.sinks, sink)
+			group.copies = append(group.copies, Frame{})
+			sinks = append(sinks, sink)
+			continue
+		}
+		sink, err := linearSinkCreate(sink, codecManager)
+		if err != nil {
+			return nil, err
+		}
+		group, err := multiplier.groupGet(sink.TXDescriptor)
+		if err != nil {
+			return nil, err
+		}
+		group.sinks = append(group.sinks, sink)
+		group.copies = append(group.copies, Frame{})
+		sinks = append(sinks, sink)
+	}
+
+	multiplier.base.Sheddable = AudioStreamSheddable(source)
+	multiplier.base.Destroy = func(object *Object) error {
+		return multiplier.MultiplierDestroy()
+	}
+	multiplier.base.Process = func(object *Object) error {
+		return multiplier.MultiplierProcess()
+	}
+
+	if err := source.AudioStreamRXOpen(nil); err != nil {
+		return nil, err
+	}
+	for i, sink := range sinks {
+		if err := sink.AudioStreamTXOpen(nil); err != nil {
+			for _, opened := range sinks[:i] {
+				_ = opened.AudioStreamTXClose()
+			}
+			_ = source.AudioStreamRXClose()
+			return nil, err
+		}
+	}
+	return multiplier, nil
 }
@@ -0,0 +1,73 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Create sink keeping the samples of the last frame written */
+func multiplierTestSink(rate uint16, written *[]int16) *AudioStream {
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			var err error
+			*written, err = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			return err
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(rate, 1)
+	return sink
+}
+
+func TestMultiplier(t *testing.T) {
+	full := -1
+	var ducked, plain, other, wideband []int16
+	gain, _ := GainCreate(-6)
+	sinks := []*AudioStream{
+		FrameFilterStreamCreate(multiplierTestSink(8000, &ducked), gain),
+		multiplierTestSink(8000, &plain),
+		multiplierTestSink(8000, &other),
+		multiplierTestSink(16000, &wideband),
+	}
+	multiplier, err := MultiplierSharedCreate(mixerTestSource(1000, &full), sinks, CodecManagerDefaultCreate(), "fan-out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := multiplier.MultiplierObjectGet().ObjectProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* the frame processed in place by the filter is a copy, the sinks after it get the frame intact */
+	if len(ducked) != 80 || ducked[0] != 501 || len(plain) != 80 || plain[0] != 1000 || other[79] != 1000 {
+		t.Fatalf("written %v, %v and %v", ducked[:1], plain[:1], other[:1])
+	}
+	if len(wideband) != 160 || wideband[100] != 1000 {
+		t.Fatalf("%d samples resampled", len(wideband))
+	}
+	if stat := multiplier.MultiplierStatGet(); stat.Frames != 10 || stat.Copies != 10 || stat.SharedWrites != 30 {
+		t.Fatalf("stat %+v", stat)
+	}
+
+	/* context fans out the audio of the termination */
+	context := ContextFactoryCreate().ContextCreate("fan-out", nil, 3)
+	var terminations []*Termination
+	for _, stream := range []*AudioStream{mixerTestSource(300, &full), multiplierTestSink(8000, &plain), multiplierTestSink(8000, &other)} {
+		termination := TerminationBaseCreate(nil, nil, nil, stream, nil)
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+		terminations = append(terminations, termination)
+	}
+	context.ContextAssociationAdd(terminations[0], terminations[1])
+	context.ContextAssociationAdd(terminations[0], terminations[2])
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if plain[0] != 300 || other[0] != 300 {
+		t.Fatalf("context fanned out %v and %v", plain[:1], other[:1])
+	}
+}
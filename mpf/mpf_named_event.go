@@ -1,5 +1,23 @@
 package mpf
 
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+)
+
+/** Named event (telephone-event) codec name */
+const MPF_EVENT_CODEC_NAME = "telephone-event"
+
+/** Default payload type of named events */
+const MPF_EVENT_PAYLOAD_TYPE = 101
+
+/** Size of named event report in RTP payload (RFC4733 2.3) */
+const RTP_EVENT_PAYLOAD_SIZE = 4
+
+/** DTMF characters indexed by RFC4733 event identifiers */
+const dtmfEventIdMap = "0123456789*#ABCD"
+
 /** Named event (RFC4733/RFC2833, out-of-band DTMF) */
 type NamedEventFrame struct {
 
@@ -28,20 +46,95 @@ type NamedEventFrame struct {
 
 /** Create named event descriptor */
 func EventDescriptorCreate(samplingRate uint16) *CodecDescriptor {
-	return nil
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = MPF_EVENT_PAYLOAD_TYPE
+	descriptor.Name = MPF_EVENT_CODEC_NAME
+	descriptor.SamplingRate = samplingRate
+	descriptor.ChannelCount = 1
+	descriptor.Format = "0-15"
+	return descriptor
 }
 
 /** Check whether the specified descriptor is named event one */
 func EventDescriptorCheck(descriptor *CodecDescriptor) bool {
-	return false
+	if descriptor == nil {
+		return false
+	}
+	return strings.EqualFold(descriptor.Name, MPF_EVENT_CODEC_NAME)
+}
+
+/**
+ * Check whether named events received by the descriptor of source can be sent by the descriptor of sink:
+ * both must be named event descriptors of the same sampling rate, as event durations are in its units.
+ * @param source the event descriptor of the source
+ * @param sink the event descriptor of the sink
+ */
+func EventDescriptorsCompatible(source, sink *CodecDescriptor) bool {
+	if !EventDescriptorCheck(source) || !EventDescriptorCheck(sink) {
+		return false
+	}
+	return source.SamplingRate == sink.SamplingRate
+}
+
+/* Check whether the stream receives named events only (no audio) */
+func eventOnlySourceCheck(stream *AudioStream) bool {
+	return stream.RXDescriptor == nil && stream.RXEventDescriptor != nil
+}
+
+/* Check whether the stream sends named events only (no audio) */
+func eventOnlySinkCheck(stream *AudioStream) bool {
+	return stream.TXDescriptor == nil && stream.TXEventDescriptor != nil
 }
 
 /** Convert DTMF character to event identifier */
 func DtmfCharToEventId(dtmfChar byte) uint32 {
-	return 0
+	if dtmfChar >= 'a' && dtmfChar <= 'd' {
+		dtmfChar -= 'a' - 'A'
+	}
+	if i := strings.IndexByte(dtmfEventIdMap, dtmfChar); i >= 0 {
+		return uint32(i)
+	}
+	return DTMF_EVENT_ID_MAX + 1
 }
 
 /** Convert event identifier to DTMF character */
 func EventIdToDtmfChar(eventId uint32) byte {
-	return 0
+	if eventId > DTMF_EVENT_ID_MAX {
+		return 0
+	}
+	return dtmfEventIdMap[eventId]
+}
+
+/** Marshal named event to report of RTP payload (RFC4733 2.3) */
+func NamedEventMarshal(event *NamedEventFrame) []byte {
+	payload := make([]byte, RTP_EVENT_PAYLOAD_SIZE)
+	payload[0] = byte(event.EventId)
+	payload[1] = byte(event.Volume & 0x3F)
+	if event.Edge != 0 {
+		payload[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(payload[2:], uint16(event.Duration))
+	return payload
+}
+
+/**
+ * Parse named event reports of RTP payload, the payload may carry several events (RFC4733 2.5.1.5).
+ * @param payload the payload of RTP packet of telephone-event
+ */
+func NamedEventsParse(payload []byte) ([]NamedEventFrame, error) {
+	if len(payload) == 0 || len(payload)%RTP_EVENT_PAYLOAD_SIZE != 0 {
+		return nil, fmt.Errorf("named event payload of %d bytes", len(payload))
+	}
+	events := make([]NamedEventFrame, 0, len(payload)/RTP_EVENT_PAYLOAD_SIZE)
+	for offset := 0; offset < len(payload); offset += RTP_EVENT_PAYLOAD_SIZE {
+		report := payload[offset : offset+RTP_EVENT_PAYLOAD_SIZE]
+		events = append(events, NamedEventFrame{
+			EventId:  uint32(report[0]),
+			Volume:   uint32(report[1] & 0x3F),
+			Reserved: uint32(report[1]>>6) & 0x1,
+			Edge:     uint32(report[1] >> 7),
+			Duration: uint32(binary.BigEndian.Uint16(report[2:])),
+		})
+	}
+	return events, nil
 }
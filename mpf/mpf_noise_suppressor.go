@@ -0,0 +1,209 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"math/cmplx"
+	"sync"
+)
+
+/** Default max suppression of noise in dB (spectral floor) */
+const NS_SUPPRESSION = 20.0
+
+/** Over-subtraction factor of the noise estimated */
+const NS_OVER_SUBTRACTION = 2.0
+
+/** Block duration of spectral analysis in msec (rounded up to the power of 2 samples) */
+const NS_BLOCK_TIME = 32
+
+/** Smoothing of the power per bin over blocks, the minima of which are tracked */
+const NS_POWER_SMOOTHING = 0.8
+
+/** Rise of noise estimate per block, so that the noise tracks the (slowly) increasing level */
+const NS_NOISE_RISE = 1.005
+
+/** Compensation of the minima being below the mean power of noise */
+const NS_NOISE_BIAS = 2.5
+
+/**
+ * Noise suppressor by spectral subtraction.
+ * The noise spectrum is tracked by the minima of the power in each bin and subtracted from the spectrum
+ * of the audio analysed by blocks overlapped by half (square root Hann windows), down to the spectral floor.
+ * The output is delayed by a block.
+ */
+type NoiseSuppressor struct {
+	blockSize int
+	window    []float64
+	/** Gain of spectral floor */
+	floor float64
+
+	/** Input of the current block */
+	input []float64
+	/** Overlap of the previous output block */
+	overlap []float64
+	/** Output not consumed yet */
+	output []float64
+	/** Smoothed power per bin, nil until the first block */
+	power []float64
+	/** Noise power estimated per bin (minima of the smoothed power) */
+	noise []float64
+
+	mutex sync.Mutex
+}
+
+/**
+ * Create noise suppressor.
+ * @param descriptor the descriptor of the (linear, mono) audio
+ * @param suppression the max suppression in dB
+ */
+func NoiseSuppressorCreate(descriptor *CodecDescriptor, suppression float64) (*NoiseSuppressor, error) {
+	if descriptor == nil || descriptor.ChannelCount != 1 || descriptor.SamplingRate == 0 {
+		return nil, fmt.Errorf("noise suppression of the audio is not supported")
+	}
+	if suppression <= 0 {
+		return nil, fmt.Errorf("invalid noise suppression %.1f dB", suppression)
+	}
+	blockSize := 1
+	for blockSize < int(descriptor.SamplingRate)*NS_BLOCK_TIME/1000 {
+		blockSize <<= 1
+	}
+	ns := &NoiseSuppressor{
+		blockSize: blockSize,
+		window:    make([]float64, blockSize),
+		floor:     math.Pow(10, -suppression/20),
+	}
+	for i := range ns.window {
+		ns.window[i] = math.Sqrt(0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(blockSize)))
+	}
+	ns.NoiseSuppressorReset()
+	return ns, nil
+}
+
+/** Reset noise suppressor for the next audio, the noise is estimated again */
+func (ns *NoiseSuppressor) NoiseSuppressorReset() {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+	hop := ns.blockSize / 2
+	/* the input and output are primed by half a block each, so the delay is a block */
+	ns.input = make([]float64, hop, ns.blockSize)
+	ns.output = make([]float64, hop)
+	ns.overlap = make([]float64, hop)
+	ns.power = nil
+	ns.noise = nil
+}
+
+/**
+ * Process samples of the frame in place.
+ * @param samples the samples
+ */
+func (ns *NoiseSuppressor) NoiseSuppressorProcess(samples []int16) {
+	ns.mutex.Lock()
+	defer ns.mutex.Unlock()
+	for _, sample := range samples {
+		ns.input = append(ns.input, float64(sample))
+		if len(ns.input) == ns.blockSize {
+			ns.blockProcess()
+		}
+	}
+	for i := range samples {
+		v := math.Round(ns.output[i])
+		if v > math.MaxInt16 {
+			v = math.MaxInt16
+		} else if v < math.MinInt16 {
+			v = math.MinInt16
+		}
+		samples[i] = int16(v)
+	}
+	ns.output = append(ns.output[:0], ns.output[len(samples):]...)
+}
+
+/* Suppress noise of the input block and overlap-add the result to the output */
+func (ns *NoiseSuppressor) blockProcess() {
+	hop := ns.blockSize / 2
+	spectrum := make([]complex128, ns.blockSize)
+	for i, v := range ns.input {
+		spectrum[i] = complex(v*ns.window[i], 0)
+	}
+	fft(spectrum, false)
+
+	bins := hop + 1
+	if ns.power == nil {
+		ns.power = make([]float64, bins)
+		ns.noise = make([]float64, bins)
+		for k := range ns.power {
+			ns.power[k] = math.Pow(cmplx.Abs(spectrum[k]), 2)
+			ns.noise[k] = ns.power[k]
+		}
+	}
+	for k := 0; k < bins; k++ {
+		power := math.Pow(cmplx.Abs(spectrum[k]), 2)
+		ns.power[k] = NS_POWER_SMOOTHING*ns.power[k] + (1-NS_POWER_SMOOTHING)*power
+		if ns.power[k] < ns.noise[k] {
+			ns.noise[k] = ns.power[k]
+		} else {
+			ns.noise[k] *= NS_NOISE_RISE
+		}
+		gain := ns.floor
+		if power > 0 {
+			gain = math.Max(ns.floor, math.Sqrt(math.Max(0, 1-NS_OVER_SUBTRACTION*NS_NOISE_BIAS*ns.noise[k]/power)))
+		}
+		spectrum[k] *= complex(gain, 0)
+		if k > 0 && k < hop {
+			/* the spectrum of real signal is conjugate symmetric */
+			spectrum[ns.blockSize-k] = cmplx.Conj(spectrum[k])
+		}
+	}
+	fft(spectrum, true)
+
+	for i := 0; i < hop; i++ {
+		ns.output = append(ns.output, ns.overlap[i]+real(spectrum[i])*ns.window[i])
+		ns.overlap[i] = real(spectrum[hop+i]) * ns.window[hop+i]
+	}
+	ns.input = append(ns.input[:0], ns.input[hop:]...)
+}
+
+/** Process samples as frame filter */
+func (ns *NoiseSuppressor) FrameFilterProcess(samples []int16) error {
+	ns.NoiseSuppressorProcess(samples)
+	return nil
+}
+
+/** Reset as frame filter */
+func (ns *NoiseSuppressor) FrameFilterReset() {
+	ns.NoiseSuppressorReset()
+}
+
+/* In place radix-2 FFT (inverse scaled by 1/n), the length must be a power of 2 */
+func fft(x []complex128, inverse bool) {
+	n := len(x)
+	for i, j := 1, 0; i < n; i++ {
+		bit := n >> 1
+		for ; j&bit != 0; bit >>= 1 {
+			j ^= bit
+		}
+		j |= bit
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+	sign := -1.0
+	if inverse {
+		sign = 1.0
+	}
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, sign*2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				u, v := x[start+k], x[start+k+size/2]*w
+				x[start+k], x[start+k+size/2] = u+v, u-v
+				w *= step
+			}
+		}
+	}
+	if inverse {
+		for i := range x {
+			x[i] /= complex(float64(n), 0)
+		}
+	}
+}
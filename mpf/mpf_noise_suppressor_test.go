@@ -0,0 +1,127 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestNoiseSuppressor(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	ns, err := NoiseSuppressorCreate(descriptor, NS_SUPPRESSION)
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := rand.New(rand.NewSource(1))
+	var clean, written []int16
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			written = append(written, samples...)
+			return err
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = descriptor
+	stream := FrameFilterStreamCreate(sink, FrameFilterChainCreate(ns))
+
+	/* the energy in dB of input and output */
+	write := func(amplitude float64, frames int) (float64, float64) {
+		clean, written = clean[:0], written[:0]
+		var noisy float64
+		for i := 0; i < frames; i++ {
+			samples := make([]int16, 80)
+			for j := range samples {
+				tone := amplitude * math.Sin(2*math.Pi*440*float64(i*80+j)/8000)
+				clean = append(clean, int16(tone))
+				samples[j] = int16(tone + random.NormFloat64()*300)
+				noisy += float64(samples[j]) * float64(samples[j])
+			}
+			frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+			codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+			if err := stream.AudioStreamFrameWrite(frame); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var energy float64
+		for _, sample := range written {
+			energy += float64(sample) * float64(sample)
+		}
+		return 10 * math.Log10(noisy), 10 * math.Log10(energy)
+	}
+
+	/* stationary noise is suppressed once estimated */
+	write(0, 100)
+	if in, out := write(0, 200); in-out < 6 {
+		t.Fatalf("noise is suppressed by %.1f dB only", in-out)
+	}
+	/* the tone (speech) is preserved */
+	if in, out := write(3000, 200); math.Abs(in-out) > 1 {
+		t.Fatalf("tone is changed by %.1f dB", out-in)
+	}
+
+	if _, err := NoiseSuppressorCreate(CodecLPcmDescriptorCreate(8000, 2), NS_SUPPRESSION); err == nil {
+		t.Fatalf("noise suppressor of stereo audio is created")
+	}
+}
+
+func TestFrameFilterChain(t *testing.T) {
+	var order []string
+	filter := func(name string, failed bool) FrameFilter {
+		return FrameFilterFunc(func(samples []int16) error {
+			order = append(order, name)
+			if failed {
+				return fmt.Errorf("%s failed", name)
+			}
+			for i := range samples {
+				samples[i]++
+			}
+			return nil
+		})
+	}
+	var written []int16
+	var events int
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+				events++
+			}
+			samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+			written = samples
+			return err
+		},
+	}, SinkStreamCapabilitiesCreate())
+	chain := FrameFilterChainCreate(filter("ns", false), filter("agc", false))
+	stream := FrameFilterStreamCreate(sink, chain)
+
+	frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+	codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice([]int16{1, 2}))
+	if err := stream.AudioStreamFrameWrite(frame); err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(order) != "[ns agc]" || fmt.Sprint(written) != "[3 4]" {
+		t.Fatalf("filters %v applied, %v written", order, written)
+	}
+
+	/* events are passed through not filtered */
+	order = nil
+	if err := stream.AudioStreamFrameWrite(&Frame{Type: MEDIA_FRAME_TYPE_EVENT}); err != nil {
+		t.Fatal(err)
+	}
+	if events != 1 || len(order) != 0 {
+		t.Fatalf("event is filtered by %v", order)
+	}
+
+	/* filter inserted while streaming, the failure is reported */
+	chain.FrameFilterChainAdd(filter("external", true))
+	if chain.FrameFilterChainCount() != 3 {
+		t.Fatalf("%d filters in the chain", chain.FrameFilterChainCount())
+	}
+	frame = &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+	codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice([]int16{1, 2}))
+	if err := stream.AudioStreamFrameWrite(frame); err == nil {
+		t.Fatalf("failure of filter is not reported")
+	}
+}
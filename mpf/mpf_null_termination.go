@@ -0,0 +1,78 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+/* Get silence frame of the codec descriptor at its frame duration or ptime (CODEC_FRAME_TIME_BASE if not specified) */
+func silenceFrameCreate(descriptor *CodecDescriptor) ([]byte, error) {
+	duration := codecDescriptorFrameDurationGet(descriptor)
+	samples := int64(descriptor.SamplingRate) * int64(descriptor.ChannelCount) * duration / 1000
+	switch {
+	case CodecLPcmDescriptorMatch(descriptor) || strings.EqualFold(descriptor.Name, L16_CODEC_NAME):
+		return make([]byte, samples*BYTES_PER_SAMPLE), nil
+	case strings.EqualFold(descriptor.Name, G711U_CODEC_NAME):
+		return bytes.Repeat([]byte{0xff}, int(samples)), nil
+	case strings.EqualFold(descriptor.Name, G711A_CODEC_NAME):
+		return bytes.Repeat([]byte{0xd5}, int(samples)), nil
+	}
+	return nil, fmt.Errorf("silence of codec %s is not supported", descriptor.Name)
+}
+
+/**
+ * Create termination producing silence frames at the ptime of the descriptor, e.g. to keep topology valid
+ * while the other leg is inactive or to hold the peer without comfort noise.
+ * @param descriptor the codec descriptor of the silence (linear PCM 8 kHz mono if nil), LPCM, L16, PCMU or PCMA
+ */
+func SilenceTerminationCreate(descriptor *CodecDescriptor) (*Termination, error) {
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	silence, err := silenceFrameCreate(descriptor)
+	if err != nil {
+		return nil, err
+	}
+	duration := codecDescriptorFrameDurationGet(descriptor)
+	vtable := &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			if d := codecDescriptorFrameDurationGet(stream.RXDescriptor); d != duration {
+				/* frame duration is changed by the engine */
+				if silence, err = silenceFrameCreate(stream.RXDescriptor); err != nil {
+					return err
+				}
+				duration = d
+			}
+			frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, silence)
+		},
+	}
+	audioStream := AudioStreamCreate(nil, vtable, SourceStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = descriptor
+	return TerminationBaseCreate(nil, nil, nil, audioStream, nil), nil
+}
+
+/**
+ * Create termination discarding all the frames written, e.g. to keep topology valid while the other leg is inactive.
+ * @param descriptor the codec descriptor of the audio accepted (linear PCM 8 kHz mono if nil)
+ */
+func NullTerminationCreate(descriptor *CodecDescriptor) (*Termination, error) {
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	vtable := &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			return nil
+		},
+	}
+	audioStream := AudioStreamCreate(nil, vtable, SinkStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.TXDescriptor = descriptor
+	return TerminationBaseCreate(nil, nil, nil, audioStream, nil), nil
+}
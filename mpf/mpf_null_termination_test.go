@@ -0,0 +1,39 @@
+package mpf
+
+import "testing"
+
+func TestSilenceTermination(t *testing.T) {
+	for _, test := range []struct {
+		descriptor *CodecDescriptor
+		size       int64
+		sample     byte
+	}{
+		{CodecLPcmDescriptorCreate(8000, 1), 160, 0},
+		{&CodecDescriptor{Name: G711U_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1, Format: "ptime=20"}, 160, 0xff},
+		{&CodecDescriptor{Name: G711A_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1}, 80, 0xd5},
+	} {
+		termination, err := SilenceTerminationCreate(test.descriptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		frame := &Frame{}
+		if err := termination.TerminationAudioStreamGet().AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		data := codecFrameDataGet(&frame.CodecFrame)
+		if frame.Type != MEDIA_FRAME_TYPE_AUDIO || int64(len(data)) != test.size || data[0] != test.sample {
+			t.Fatalf("%s: frame of %d bytes", test.descriptor.Name, len(data))
+		}
+	}
+	if _, err := SilenceTerminationCreate(&CodecDescriptor{Name: "OPUS", SamplingRate: 48000, ChannelCount: 1}); err == nil {
+		t.Fatalf("silence of unsupported codec is created")
+	}
+
+	sink, err := NullTerminationCreate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.TerminationAudioStreamGet().AudioStreamFrameWrite(&Frame{Type: MEDIA_FRAME_TYPE_AUDIO}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -10,6 +10,12 @@ type Object struct {
 	Process func(object *Object) error
 	/** Virtual trace of media path */
 	Trace func(object *Object) error
+	/** [OPTIONAL] Virtual update of codecs renegotiated for the streams of the object */
+	UpdateCodec func(object *Object) error
+	/** Processing may be skipped under overload (object feeds non-live audio such as file or tone) */
+	Sheddable bool
+	/** Duration of the tick the object is processed every in msec (CODEC_FRAME_TIME_BASE if 0) */
+	TickDuration int64
 }
 
 /** Initialize object */
@@ -45,3 +51,11 @@ func (object *Object) ObjectTrace() error {
 	}
 	return nil
 }
+
+/** Update codecs of object to the renegotiated descriptors of its streams */
+func (object *Object) ObjectCodecUpdate() error {
+	if object.UpdateCodec != nil {
+		return object.UpdateCodec(object)
+	}
+	return nil
+}
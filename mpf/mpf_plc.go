@@ -0,0 +1,94 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Max number of consecutive frames concealed (60 msec), silence follows */
+const PLC_MAX_FRAMES = 6
+
+/** Attenuation of each next concealed frame */
+const PLC_ATTENUATION = 0.7
+
+/** Range of pitch periods searched in the previous frame in msec (2.5 .. 10 msec, 100 .. 400 Hz) */
+const (
+	PLC_PITCH_MIN = 2.5
+	PLC_PITCH_MAX = 10.0
+)
+
+/* Estimate pitch period (in samples) of the tail of the frame by autocorrelation */
+func plcPitchEstimate(samples []int16, samplingRate int) int {
+	minPeriod := int(PLC_PITCH_MIN * float64(samplingRate) / 1000)
+	maxPeriod := int(PLC_PITCH_MAX * float64(samplingRate) / 1000)
+	if maxPeriod > len(samples)/2 {
+		maxPeriod = len(samples) / 2
+	}
+	best, bestCorr := len(samples), 0.0
+	for period := minPeriod; period <= maxPeriod; period++ {
+		var corr, energy float64
+		for i := len(samples) - period; i < len(samples); i++ {
+			x, y := float64(samples[i]), float64(samples[i-period])
+			corr += x * y
+			energy += y * y
+		}
+		if energy > 0 {
+			corr /= math.Sqrt(energy)
+		}
+		if corr > bestCorr {
+			best, bestCorr = period, corr
+		}
+	}
+	return best
+}
+
+/**
+ * Conceal missing linear frame by the previous one: the last pitch period of the previous frame
+ * is repeated, fading by PLC_ATTENUATION over the frame. As the previous frame of consecutive
+ * losses is the concealed one, the audio fades out gradually.
+ * @param prev the previous (decoded or concealed) samples
+ * @param out the samples to conceal
+ * @param samplingRate the sampling rate
+ */
+func PLCLinearConceal(prev, out []int16, samplingRate int) {
+	if len(prev) == 0 {
+		for i := range out {
+			out[i] = 0
+		}
+		return
+	}
+	period := plcPitchEstimate(prev, samplingRate)
+	if period > len(prev) {
+		period = len(prev)
+	}
+	cycle := prev[len(prev)-period:]
+	for i := range out {
+		gain := 1 - (1-PLC_ATTENUATION)*float64(i+1)/float64(len(out))
+		out[i] = int16(float64(cycle[i%period]) * gain)
+	}
+}
+
+/** Conceal missing frame of PCM codec (decoded to linear) by waveform repeat and attenuation */
+func CodecLinearConceal(codec *Codec, prev, frameOut *CodecFrame) error {
+	samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(prev))
+	if err != nil {
+		return err
+	}
+	samplingRate := 8000
+	if descriptor := codec.CodecDescriptorGet(); descriptor != nil && descriptor.SamplingRate > 0 {
+		samplingRate = int(descriptor.SamplingRate)
+	}
+	out := make([]int16, len(samples))
+	PLCLinearConceal(samples, out, samplingRate)
+	return codecFrameDataSet(frameOut, binaryx.Int16SliceToByteSlice(out))
+}
+
+/** Conceal missing frame (decoded to linear) by the previous one */
+func (c *Codec) CodecConcealFrame(prev, frameOut *CodecFrame) error {
+	if c.VTable != nil && c.VTable.Conceal != nil {
+		return c.VTable.Conceal(c, prev, frameOut)
+	}
+	return fmt.Errorf("codec %s does not support packet loss concealment", c.Attribs.Name)
+}
@@ -0,0 +1,76 @@
+package mpf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestPLCLinearConceal(t *testing.T) {
+	/* 200 Hz tone, 5 msec pitch period */
+	prev := loudnessTestSine(200, 8000, 10, 10000)
+	out := make([]int16, len(prev))
+	PLCLinearConceal(prev, out, 8000)
+	if period := plcPitchEstimate(prev, 8000); period != 40 {
+		t.Fatalf("pitch period %d, want 40", period)
+	}
+	/* the waveform continues with the tone, fading */
+	for i := 0; i < 10; i++ {
+		if math.Abs(float64(out[i])-float64(prev[i])) > 1000 {
+			t.Fatalf("sample %d is %d, want about %d", i, out[i], prev[i])
+		}
+	}
+	if peak := PeakMeasure(out[len(out)-40:]); peak > PeakMeasure(prev)-1 {
+		t.Fatalf("concealed frame is not attenuated: %.1f dBFS", peak)
+	}
+}
+
+func TestDecoderConceal(t *testing.T) {
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(&CodecDescriptor{PayloadType: 96, Name: "L16", SamplingRate: 8000, ChannelCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tone := loudnessTestSine(200, 8000, 10, 10000)
+	/* L16 payload is big-endian */
+	payload := make([]byte, 160)
+	for i, sample := range tone {
+		payload[2*i], payload[2*i+1] = byte(uint16(sample)>>8), byte(sample)
+	}
+	reads := 0
+	source := AudioStreamCreate(nil, &AudioStreamVTable{ReadFrame: func(stream *AudioStream, frame *Frame) error {
+		reads++
+		if reads > 2 {
+			/* packets are lost */
+			return nil
+		}
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		return codecFrameDataSet(&frame.CodecFrame, payload)
+	}}, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	source.RXDescriptor = &CodecDescriptor{PayloadType: 96, Name: "L16", SamplingRate: 8000, ChannelCount: 1}
+	decoder := DecoderCreate(source, codec)
+
+	frame := &Frame{CodecFrame: CodecFrame{Buffer: new(bytes.Buffer), Size: 160}}
+	audio := 0
+	var last float64
+	for i := 0; i < 2+PLC_MAX_FRAMES+2; i++ {
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		if err := decoder.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+			continue
+		}
+		audio++
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		peak := PeakMeasure(samples)
+		if i > 2 && peak >= last {
+			t.Fatalf("concealed frame %d is not attenuated", i)
+		}
+		last = peak
+	}
+	if audio != 2+PLC_MAX_FRAMES {
+		t.Fatalf("%d audio frames, want %d", audio, 2+PLC_MAX_FRAMES)
+	}
+}
@@ -0,0 +1,163 @@
+package mpf
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+/** Number of frames queued for the consumer of pull sink by default (1 sec) */
+const PULL_SINK_QUEUE_SIZE = 100
+
+/** Frame received by pull sink */
+type PulledFrame struct {
+	/** Frame type (bitmask of MEDIA_FRAME_TYPE_...) */
+	Type FrameType
+	/** Audio data (of the codec of the descriptor), nil if no audio */
+	Data []byte
+	/** Named event, if the type is MEDIA_FRAME_TYPE_EVENT */
+	EventFrame NamedEventFrame
+	/** Timestamp of the frame by the media clock in msec, 0 for the first frame */
+	Timestamp int64
+	/** Time the frame is received at */
+	Time time.Time
+}
+
+/**
+ * Sink exposing the frames received to the consumer, e.g. recognizer engine written as plain goroutine,
+ * either on bounded channel or by callback, so that the consumer does not implement AudioStreamVTable.
+ * Only frames of audio or events are exposed, the timestamps tell the gaps.
+ */
+type PullSink struct {
+	descriptor *CodecDescriptor
+	/** Callback the frames are passed to, nil if the frames are queued on channel */
+	callback func(frame *PulledFrame)
+	frames   chan *PulledFrame
+
+	mutex sync.Mutex
+	/** Timestamp of the next frame in msec */
+	clock int64
+	/** Number of frames dropped since the consumer is late */
+	dropped uint64
+	/** The sink is destroyed, the channel is closed */
+	closed bool
+}
+
+/**
+ * Create pull sink queueing the frames on channel, the frames are dropped if the channel is full.
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ * @param queueSize the number of frames queued (PULL_SINK_QUEUE_SIZE if 0)
+ */
+func PullSinkCreate(descriptor *CodecDescriptor, queueSize int) (*PullSink, error) {
+	if queueSize < 0 {
+		return nil, fmt.Errorf("invalid queue size %d", queueSize)
+	}
+	if queueSize == 0 {
+		queueSize = PULL_SINK_QUEUE_SIZE
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	return &PullSink{descriptor: descriptor, frames: make(chan *PulledFrame, queueSize)}, nil
+}
+
+/**
+ * Create pull sink passing the frames to callback, called in the context of the media processing,
+ * so it must not block. The frame must not be retained, the data may be.
+ * @param descriptor the codec descriptor of the audio (linear PCM 8 kHz mono if nil)
+ * @param callback the callback
+ */
+func PullSinkCallbackCreate(descriptor *CodecDescriptor, callback func(frame *PulledFrame)) (*PullSink, error) {
+	if callback == nil {
+		return nil, fmt.Errorf("no callback")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	return &PullSink{descriptor: descriptor, callback: callback}, nil
+}
+
+/** Get channel the frames are queued on, closed as the termination is destroyed (nil for callback sink) */
+func (sink *PullSink) PullSinkFrames() <-chan *PulledFrame {
+	return sink.frames
+}
+
+/** Get number of frames dropped since the consumer is late */
+func (sink *PullSink) PullSinkDroppedGet() uint64 {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	return sink.dropped
+}
+
+/* Expose frame written, the callback is called without the mutex locked */
+func (sink *PullSink) frameWrite(as *AudioStream, frame *Frame) error {
+	sink.mutex.Lock()
+	timestamp := sink.clock
+	sink.clock += sink.descriptor.CodecFrameDurationGet()
+	closed := sink.closed
+	sink.mutex.Unlock()
+	if closed || (frame.Type&(MEDIA_FRAME_TYPE_AUDIO|MEDIA_FRAME_TYPE_EVENT)) == 0 {
+		return nil
+	}
+
+	pulled := &PulledFrame{
+		Type:      frame.Type,
+		Timestamp: timestamp,
+		Time:      time.Now(),
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		/* the frame is reused by the context, the data is copied */
+		pulled.Data = append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...)
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		pulled.EventFrame = frame.EventFrame
+	}
+	if sink.callback != nil {
+		sink.callback(pulled)
+		return nil
+	}
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if sink.closed {
+		return nil
+	}
+	select {
+	case sink.frames <- pulled:
+	default:
+		/* the consumer is late */
+		sink.dropped++
+	}
+	return nil
+}
+
+func (sink *PullSink) destroy(as *AudioStream) error {
+	sink.mutex.Lock()
+	defer sink.mutex.Unlock()
+	if !sink.closed && sink.frames != nil {
+		close(sink.frames)
+	}
+	sink.closed = true
+	return nil
+}
+
+/**
+ * Create termination the audio sink of which is pull sink.
+ * @param sink the pull sink
+ */
+func PullTerminationCreate(sink *PullSink) (*Termination, error) {
+	if sink == nil {
+		return nil, fmt.Errorf("no pull sink")
+	}
+	vtable := &AudioStreamVTable{
+		Destroy:    sink.destroy,
+		WriteFrame: sink.frameWrite,
+	}
+	audioStream := AudioStreamCreate(sink, vtable, SinkStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.TXDescriptor = sink.descriptor
+	/* out-of-band DTMF is exposed too */
+	audioStream.TXEventDescriptor = EventDescriptorCreate(sink.descriptor.SamplingRate)
+	return TerminationBaseCreate(nil, nil, nil, audioStream, nil), nil
+}
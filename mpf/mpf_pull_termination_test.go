@@ -0,0 +1,70 @@
+package mpf
+
+import (
+	"testing"
+)
+
+func TestPullTermination(t *testing.T) {
+	sink, err := PullSinkCreate(nil, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recognizer, err := PullTerminationCreate(sink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	rtp := TerminationBaseCreate(nil, nil, nil, mixerTestSource(100, &count), nil)
+	context := ContextFactoryCreate().ContextCreate("pull", nil, 2)
+	for _, termination := range []*Termination{rtp, recognizer} {
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+	}
+	context.ContextAssociationAdd(rtp, recognizer)
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	process := func() {
+		if err := context.ContextProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* no audio is not exposed, yet the clock runs */
+	process()
+	count = -1
+	process()
+	process()
+	process()
+	if dropped := sink.PullSinkDroppedGet(); dropped != 1 {
+		t.Fatalf("%d frames dropped", dropped)
+	}
+	for _, timestamp := range []int64{10, 20} {
+		frame := <-sink.PullSinkFrames()
+		if frame.Timestamp != timestamp || len(frame.Data) != 160 || frame.Data[0] != 100 || frame.Time.IsZero() {
+			t.Fatalf("frame at %d msec of %d bytes", frame.Timestamp, len(frame.Data))
+		}
+	}
+	if err := AudioStreamDestroy(recognizer.TerminationAudioStreamGet()); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-sink.PullSinkFrames(); ok {
+		t.Fatalf("channel is not closed")
+	}
+
+	/* callback gets the frames as written */
+	var timestamps []int64
+	sink, _ = PullSinkCallbackCreate(nil, func(frame *PulledFrame) {
+		timestamps = append(timestamps, frame.Timestamp)
+	})
+	recognizer, _ = PullTerminationCreate(sink)
+	stream := recognizer.TerminationAudioStreamGet()
+	for _, frameType := range []FrameType{MEDIA_FRAME_TYPE_AUDIO, MEDIA_FRAME_TYPE_NONE, MEDIA_FRAME_TYPE_EVENT} {
+		if err := stream.AudioStreamFrameWrite(&Frame{Type: frameType}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(timestamps) != 2 || timestamps[1] != 20 {
+		t.Fatalf("timestamps %v", timestamps)
+	}
+}
@@ -0,0 +1,156 @@
+package mpf
+
+import (
+	"fmt"
+	"sync"
+)
+
+/**
+ * Source of audio pushed asynchronously, e.g. by streaming TTS engine synthesizing SPEAK:
+ * the engine pushes chunks of linear PCM of any size as they are synthesized, and the termination
+ * paces them out frame by frame at real-time (the frame duration of the descriptor, ptime).
+ * As the end of the audio is pushed and all the audio is played, drain-complete is signaled.
+ */
+type PushSource struct {
+	descriptor *CodecDescriptor
+	frameSize  int64
+
+	mutex sync.Mutex
+	/** Audio pushed, not played yet */
+	pending []byte
+	/** Time played in msec */
+	played int64
+	/** The end of the audio is pushed */
+	ended bool
+	/** Closed as the audio is drained */
+	drained chan struct{}
+}
+
+/**
+ * Create push source.
+ * @param descriptor the codec descriptor of the linear audio pushed (linear PCM 8 kHz mono if nil)
+ */
+func PushSourceCreate(descriptor *CodecDescriptor) (*PushSource, error) {
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	if !CodecLPcmDescriptorMatch(descriptor) {
+		return nil, fmt.Errorf("audio of codec %s cannot be pushed, linear PCM only", descriptor.Name)
+	}
+	frameSize := descriptor.CodecLinearFrameSizeGet()
+	if frameSize <= 0 {
+		return nil, fmt.Errorf("invalid frame size of %s/%d", descriptor.Name, descriptor.SamplingRate)
+	}
+	return &PushSource{
+		descriptor: descriptor,
+		frameSize:  frameSize,
+		drained:    make(chan struct{}),
+	}, nil
+}
+
+/**
+ * Push chunk of synthesized audio, it is queued to be played after the audio pushed before.
+ * @param pcm the linear PCM of the descriptor, of any size
+ */
+func (source *PushSource) Push(pcm []byte) error {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	if source.ended {
+		return fmt.Errorf("end of audio is pushed")
+	}
+	source.pending = append(source.pending, pcm...)
+	return nil
+}
+
+/** Push the end of the audio, drain-complete is signaled as the audio pushed is played */
+func (source *PushSource) PushSourceEnd() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.ended = true
+}
+
+/** Discard the audio pushed and not played yet (e.g. on barge-in), the source may be pushed to further */
+func (source *PushSource) PushSourceFlush() {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	source.pending = source.pending[:0]
+}
+
+/** Get duration of the audio pushed and not played yet in msec */
+func (source *PushSource) PushSourceBufferedGet() int64 {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	return int64(len(source.pending)) * source.descriptor.CodecFrameDurationGet() / source.frameSize
+}
+
+/** Get duration of the audio played in msec */
+func (source *PushSource) PushSourcePlayedGet() int64 {
+	source.mutex.Lock()
+	defer source.mutex.Unlock()
+	return source.played
+}
+
+/** Get channel closed as the end of the audio is pushed and all the audio is played (drain-complete) */
+func (source *PushSource) PushSourceDrained() <-chan struct{} {
+	return source.drained
+}
+
+/* Read frame of the audio pushed, the last frame is padded by silence; no audio if the engine is late */
+func (source *PushSource) frameRead(as *AudioStream, frame *Frame) error {
+	source.mutex.Lock()
+	if len(source.pending) == 0 {
+		drain := source.ended && !source.drainedCheck()
+		played := source.played
+		source.mutex.Unlock()
+		if !drain {
+			/* nothing pushed (engine is late) or drained already */
+			return nil
+		}
+		close(source.drained)
+		completion := &AudioFileCompletion{Direction: FILE_READER, Cause: AUDIO_FILE_COMPLETION_EOF, Duration: played}
+		return AudioFileEventRaise(as, AUDIO_FILE_COMPLETE_EVENT, completion)
+	}
+	if int64(len(source.pending)) < source.frameSize && !source.ended {
+		/* wait for the rest of the frame */
+		source.mutex.Unlock()
+		return nil
+	}
+	data := make([]byte, source.frameSize)
+	n := copy(data, source.pending)
+	source.pending = append(source.pending[:0], source.pending[n:]...)
+	source.played += source.descriptor.CodecFrameDurationGet()
+	source.mutex.Unlock()
+
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	return codecFrameDataSet(&frame.CodecFrame, data)
+}
+
+/* Check whether drain-complete is signaled */
+func (source *PushSource) drainedCheck() bool {
+	select {
+	case <-source.drained:
+		return true
+	default:
+		return false
+	}
+}
+
+/**
+ * Create termination playing the audio pushed to the source, the audio drained
+ * (the end pushed and all the audio played) is raised as AUDIO_FILE_COMPLETE_EVENT.
+ * @param source the push source
+ */
+func PushTerminationCreate(source *PushSource) (*Termination, error) {
+	if source == nil {
+		return nil, fmt.Errorf("no push source")
+	}
+	vtable := &AudioStreamVTable{
+		ReadFrame: source.frameRead,
+	}
+	audioStream := AudioStreamCreate(source, vtable, SourceStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = source.descriptor
+	return TerminationBaseCreate(nil, nil, nil, audioStream, nil), nil
+}
@@ -0,0 +1,69 @@
+package mpf
+
+import (
+	"testing"
+)
+
+func TestPushTermination(t *testing.T) {
+	source, err := PushSourceCreate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	termination, err := PushTerminationCreate(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completions []*AudioFileCompletion
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		completions = append(completions, descriptor.(*AudioFileCompletion))
+		return nil
+	}
+	stream := termination.TerminationAudioStreamGet()
+	read := func() bool {
+		frame := Frame{}
+		frame.CodecFrame.Size = 160
+		if err := stream.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && len(codecFrameDataGet(&frame.CodecFrame)) != 160 {
+			t.Fatalf("frame of %d bytes", len(codecFrameDataGet(&frame.CodecFrame)))
+		}
+		return (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO
+	}
+
+	/* chunks of any size are paced out by frames */
+	for i := 0; i < 7; i++ {
+		if err := source.Push(make([]byte, 80)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if buffered := source.PushSourceBufferedGet(); buffered != 35 {
+		t.Fatalf("%d msec buffered", buffered)
+	}
+	for i := 0; i < 3; i++ {
+		if !read() {
+			t.Fatalf("frame %d is not played", i)
+		}
+	}
+	/* the rest of the frame is not pushed yet */
+	if read() || len(completions) != 0 {
+		t.Fatalf("partial frame is played")
+	}
+
+	/* the last frame is padded, drain-complete follows */
+	source.PushSourceEnd()
+	if err := source.Push(make([]byte, 80)); err == nil {
+		t.Fatalf("audio is pushed after the end")
+	}
+	if !read() || read() || read() {
+		t.Fatalf("the last frame is not played once")
+	}
+	select {
+	case <-source.PushSourceDrained():
+	default:
+		t.Fatalf("drain-complete is not signaled")
+	}
+	if len(completions) != 1 || completions[0].Duration != 40 || source.PushSourcePlayedGet() != 40 {
+		t.Fatalf("completions %v, %d msec played", completions, source.PushSourcePlayedGet())
+	}
+}
@@ -0,0 +1,257 @@
+package mpf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/** Suffix of the file being recorded, it is renamed as soon as finalized */
+const RECORDING_PARTIAL_SUFFIX = ".part"
+
+/** Cause the recorded file is finalized by */
+type RecordingFinalizeCause = int
+
+const (
+	RECORDING_FINALIZE_CLOSE    RecordingFinalizeCause = iota // recording is closed
+	RECORDING_FINALIZE_DURATION                               // max duration of file is reached
+	RECORDING_FINALIZE_SIZE                                   // max size of file is reached
+)
+
+/** Metadata of recorded file */
+type RecordingFile struct {
+	/** Path of the file */
+	Path string
+	/** Index of the file in the recording, starting at 1 */
+	Index int
+	/** Sampling rate */
+	SamplingRate uint16
+	/** Channel count */
+	ChannelCount uint8
+	/** Size of the audio data in bytes */
+	Size int64
+	/** Duration of the audio in msec */
+	Duration int64
+	/** Time the first frame is written at */
+	StartTime time.Time
+	/** Cause the file is finalized by */
+	Cause RecordingFinalizeCause
+}
+
+/** Callback invoked as soon as a recorded file is finalized */
+type RecordingFileProc func(file *RecordingFile)
+
+/** Recording config */
+type RecordingConfig struct {
+	/** Directory the files are written to */
+	Dir string
+	/** Prefix of file names, files are named "<prefix>-<index>.wav" (or ".pcm") */
+	Prefix string
+	/** Write WAV files, raw PCM otherwise */
+	Wav bool
+	/** Max duration of file in msec, 0 - unlimited */
+	MaxDuration int64
+	/** Max size of the audio data of file in bytes, 0 - unlimited */
+	MaxSize int64
+	/** [OPTIONAL] Callback invoked as files are finalized */
+	OnFile RecordingFileProc
+}
+
+/** Allocate recording config initialized with default values (a single WAV file in the current directory) */
+func RecordingConfigAlloc() *RecordingConfig {
+	return &RecordingConfig{
+		Dir:    ".",
+		Prefix: "recording",
+		Wav:    true,
+	}
+}
+
+/* Recording of the audio written to the sink, rotated by duration and size */
+type recordingWriter struct {
+	config     RecordingConfig
+	descriptor *CodecDescriptor
+
+	/** File being recorded, nil if none */
+	file *os.File
+	/** Metadata of the file being recorded */
+	current RecordingFile
+	/** Number of files recorded */
+	count  int
+	closed bool
+	mutex  sync.Mutex
+}
+
+/* Get duration in msec of the audio data size */
+func (w *recordingWriter) durationGet(size int64) int64 {
+	return size * 1000 / (int64(w.descriptor.SamplingRate) * int64(w.descriptor.ChannelCount) * BYTES_PER_SAMPLE)
+}
+
+/* Open the next file */
+func (w *recordingWriter) open() error {
+	extension := ".pcm"
+	if w.config.Wav {
+		extension = ".wav"
+	}
+	w.count++
+	path := filepath.Join(w.config.Dir, fmt.Sprintf("%s-%03d%s", w.config.Prefix, w.count, extension))
+	file, err := os.Create(path + RECORDING_PARTIAL_SUFFIX)
+	if err != nil {
+		return err
+	}
+	if w.config.Wav {
+		/* the header is completed as the file is finalized */
+		if err := WavHeaderWrite(file, w.descriptor.SamplingRate, w.descriptor.ChannelCount, 0); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	w.file = file
+	w.current = RecordingFile{
+		Path:         path,
+		Index:        w.count,
+		SamplingRate: w.descriptor.SamplingRate,
+		ChannelCount: w.descriptor.ChannelCount,
+		StartTime:    time.Now(),
+	}
+	return nil
+}
+
+/* Finalize the file being recorded: complete the header, flush and rename it atomically */
+func (w *recordingWriter) finalize(cause RecordingFinalizeCause) error {
+	if w.file == nil {
+		return nil
+	}
+	file := w.file
+	w.file = nil
+	err := func() error {
+		if w.config.Wav {
+			if _, err := file.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			if err := WavHeaderWrite(file, w.descriptor.SamplingRate, w.descriptor.ChannelCount, w.current.Size); err != nil {
+				return err
+			}
+		}
+		return file.Sync()
+	}()
+	if e := file.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(w.current.Path+RECORDING_PARTIAL_SUFFIX, w.current.Path); err != nil {
+		return err
+	}
+	w.current.Duration = w.durationGet(w.current.Size)
+	w.current.Cause = cause
+	if w.config.OnFile != nil {
+		file := w.current
+		w.config.OnFile(&file)
+	}
+	return nil
+}
+
+/* Write frame, rotating the file before the limits are exceeded */
+func (w *recordingWriter) frameWrite(as *AudioStream, frame *Frame) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	var data []byte
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		data = codecFrameDataGet(&frame.CodecFrame)
+	} else {
+		/* silence keeps the timing of the recording */
+		data = make([]byte, as.TXDescriptor.CodecLinearFrameSizeGet())
+	}
+	size := int64(len(data))
+	if w.file != nil && w.current.Size > 0 {
+		if w.config.MaxSize > 0 && w.current.Size+size > w.config.MaxSize {
+			if err := w.finalize(RECORDING_FINALIZE_SIZE); err != nil {
+				return err
+			}
+		} else if w.config.MaxDuration > 0 && w.durationGet(w.current.Size+size) > w.config.MaxDuration {
+			if err := w.finalize(RECORDING_FINALIZE_DURATION); err != nil {
+				return err
+			}
+		}
+	}
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return err
+		}
+	}
+	n, err := w.file.Write(data)
+	w.current.Size += int64(n)
+	return err
+}
+
+/* Close recording, finalizing the file being recorded */
+func (w *recordingWriter) close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.finalize(RECORDING_FINALIZE_CLOSE)
+}
+
+/**
+ * Create sink termination recording (linear) audio to PCM/WAV files, rotated by duration and size,
+ * e.g. for recorder resource engine. Each file is written under a partial name and renamed as soon as
+ * it is complete, so that only complete files are visible. The recording is closed as the termination
+ * is subtracted (@see RecordingTerminationClose()).
+ * @param config the recording config (default if nil)
+ * @param descriptor the codec descriptor of the audio accepted (linear PCM 8 kHz mono if nil)
+ */
+func RecordingTerminationCreate(config *RecordingConfig, descriptor *CodecDescriptor) (*Termination, error) {
+	if config == nil {
+		config = RecordingConfigAlloc()
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	if !CodecLPcmDescriptorMatch(descriptor) || descriptor.SamplingRate == 0 || descriptor.ChannelCount == 0 {
+		return nil, fmt.Errorf("recording of codec %s is not supported", descriptor.Name)
+	}
+	if config.Prefix == "" || config.MaxDuration < 0 || config.MaxSize < 0 {
+		return nil, fmt.Errorf("invalid recording prefix %q, max duration %d or max size %d",
+			config.Prefix, config.MaxDuration, config.MaxSize)
+	}
+	if info, err := os.Stat(config.Dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("no recording directory %s", config.Dir)
+	}
+	w := &recordingWriter{config: *config, descriptor: descriptor}
+	vtable := &AudioStreamVTable{
+		Destroy:    func(*AudioStream) error { return w.close() },
+		WriteFrame: w.frameWrite,
+	}
+	audioStream := AudioStreamCreate(w, vtable, SinkStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.TXDescriptor = descriptor
+	terminationVTable := &TerminationVTable{
+		Subtract: func(*Termination) error { return w.close() },
+	}
+	return TerminationBaseCreate(nil, w, terminationVTable, audioStream, nil), nil
+}
+
+/**
+ * Close recording of termination, finalizing the file being recorded.
+ * The frames written afterwards are discarded.
+ * @param termination the recording termination
+ */
+func RecordingTerminationClose(termination *Termination) error {
+	w, ok := termination.Obj.(*recordingWriter)
+	if !ok {
+		return fmt.Errorf("not a recording termination")
+	}
+	return w.close()
+}
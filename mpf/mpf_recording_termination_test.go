@@ -0,0 +1,94 @@
+package mpf
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecordingTermination(t *testing.T) {
+	dir := t.TempDir()
+	var files []RecordingFile
+	config := RecordingConfigAlloc()
+	config.Dir = dir
+	config.Prefix = "call"
+	config.MaxDuration = 100
+	config.OnFile = func(file *RecordingFile) {
+		files = append(files, *file)
+	}
+	termination, err := RecordingTerminationCreate(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := termination.TerminationAudioStreamGet()
+	for i := 0; i < 25; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		if i == 5 {
+			/* frame without audio is recorded as silence */
+			frame.Type = MEDIA_FRAME_TYPE_NONE
+		} else {
+			codecFrameDataSet(&frame.CodecFrame, make([]byte, 160))
+		}
+		if err := stream.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+		if i == 12 {
+			/* the file being recorded is not visible */
+			if _, err := os.Stat(filepath.Join(dir, "call-002.wav")); !os.IsNotExist(err) {
+				t.Fatalf("partial file is visible")
+			}
+		}
+	}
+	if err := termination.TerminationSubtract(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != 3 || files[0].Cause != RECORDING_FINALIZE_DURATION || files[2].Cause != RECORDING_FINALIZE_CLOSE {
+		t.Fatalf("unexpected files %+v", files)
+	}
+	for i, duration := range []int64{100, 100, 50} {
+		file := files[i]
+		if file.Index != i+1 || file.Duration != duration || file.Size != duration*16 || file.SamplingRate != 8000 {
+			t.Fatalf("unexpected file %+v", file)
+		}
+		f, err := os.Open(file.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		header, err := WavHeaderRead(f)
+		f.Close()
+		if err != nil || header.DataSize != file.Size {
+			t.Fatalf("invalid WAV header of %s: %v", file.Path, err)
+		}
+	}
+	if entries, _ := ioutil.ReadDir(dir); len(entries) != 3 {
+		t.Fatalf("%d files in the directory", len(entries))
+	}
+
+	/* raw PCM rotated by size */
+	config = RecordingConfigAlloc()
+	config.Dir = dir
+	config.Prefix = "raw"
+	config.Wav = false
+	config.MaxSize = 480
+	files = nil
+	config.OnFile = func(file *RecordingFile) {
+		files = append(files, *file)
+	}
+	termination, _ = RecordingTerminationCreate(config, nil)
+	for i := 0; i < 4; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, make([]byte, 160))
+		termination.TerminationAudioStreamGet().AudioStreamFrameWrite(frame)
+	}
+	if err := RecordingTerminationClose(termination); err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 || files[0].Cause != RECORDING_FINALIZE_SIZE || files[0].Size != 480 || files[1].Size != 160 {
+		t.Fatalf("unexpected files %+v", files)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "raw-001.pcm")); err != nil || info.Size() != 480 {
+		t.Fatalf("raw file is not recorded: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/** Re-framer derived from audio stream: reads (linear) audio of source in frames of other duration */
+type ReFramer struct {
+	/** Audio stream base */
+	base *AudioStream
+	/** Audio stream source */
+	source *AudioStream
+	/** Frame read from source */
+	frameIn Frame
+	/** Audio read from source, not output yet */
+	pending []byte
+	/** Whether audio (not silence of missing frames) is pending */
+	audio bool
+}
+
+/**
+ * Check whether re-framing of the audio is supported: the frame durations must be multiple of
+ * CODEC_FRAME_TIME_BASE, so that the frames fall on the ticks of the media clock.
+ * @param sourceDuration the frame duration of the source in msec
+ * @param sinkDuration the frame duration of the sink in msec
+ */
+func ReFramingSupported(sourceDuration, sinkDuration int64) bool {
+	return sourceDuration > 0 && sinkDuration > 0 && sourceDuration%CODEC_FRAME_TIME_BASE == 0 && sinkDuration%CODEC_FRAME_TIME_BASE == 0
+}
+
+/**
+ * Create stage re-framing (linear) audio of source, so that e.g. a 30ms-producing source feeds a 20ms-consuming sink.
+ * The source is read as many times as needed to fill the frame read, the rest is kept for the next frame.
+ * @param source the source stream
+ * @param frameDuration the frame duration to re-frame to in msec
+ */
+func ReFramerCreate(source *AudioStream, frameDuration int64) (*AudioStream, error) {
+	if source == nil || source.RXDescriptor == nil {
+		return nil, fmt.Errorf("source is nil")
+	}
+	if !CodecLPcmDescriptorMatch(source.RXDescriptor) {
+		return nil, fmt.Errorf("re-framing of codec %s is not supported", source.RXDescriptor.Name)
+	}
+	if !ReFramingSupported(source.RXDescriptor.CodecFrameDurationGet(), frameDuration) {
+		return nil, fmt.Errorf("re-framing %d ms to %d ms is not supported", source.RXDescriptor.CodecFrameDurationGet(), frameDuration)
+	}
+
+	reframer := &ReFramer{source: source}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			return reframer.frameRead(frame)
+		},
+	}
+	reframer.base = AudioStreamCreate(reframer, vtable, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	if reframer.base == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	reframer.base.RXDescriptor = CodecDescriptorClone(source.RXDescriptor)
+	reframer.base.RXDescriptor.FrameDuration = frameDuration
+	reframer.base.RXEventDescriptor = source.RXEventDescriptor
+	reframer.frameIn.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	return reframer.base, nil
+}
+
+/* Read frames of source till the frame is filled */
+func (reframer *ReFramer) frameRead(frame *Frame) error {
+	size := int(reframer.base.RXDescriptor.CodecLinearFrameSizeGet())
+	frame.Type = MEDIA_FRAME_TYPE_NONE
+	frame.Marker = MPF_MARKER_NONE
+	for len(reframer.pending) < size {
+		in := &reframer.frameIn
+		in.Type = MEDIA_FRAME_TYPE_NONE
+		in.Marker = MPF_MARKER_NONE
+		in.CodecFrame.Size = reframer.source.RXDescriptor.CodecLinearFrameSizeGet()
+		if err := reframer.source.AudioStreamFrameRead(in); err != nil {
+			return err
+		}
+		if (in.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT && (frame.Type&MEDIA_FRAME_TYPE_EVENT) == 0 {
+			frame.Type |= MEDIA_FRAME_TYPE_EVENT
+			frame.Marker = in.Marker
+			frame.EventFrame = in.EventFrame
+		}
+		if (in.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+			reframer.pending = append(reframer.pending, codecFrameDataGet(&in.CodecFrame)...)
+			reframer.audio = true
+		} else {
+			/* missing frame is re-framed as silence */
+			reframer.pending = append(reframer.pending, make([]byte, in.CodecFrame.Size)...)
+		}
+	}
+
+	if reframer.audio {
+		frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+		if err := codecFrameDataSet(&frame.CodecFrame, reframer.pending[:size]); err != nil {
+			return err
+		}
+	}
+	reframer.pending = append(reframer.pending[:0], reframer.pending[size:]...)
+	reframer.audio = reframer.audio && len(reframer.pending) > 0
+	return nil
+}
+
+/* Pacer of the frames of the duration processed every tick of the media clock */
+type framePacer struct {
+	/** Frame duration in msec */
+	duration int64
+	/** Time elapsed and time of the frames processed in msec */
+	clock, done int64
+}
+
+/* Advance pacer by tick (CODEC_FRAME_TIME_BASE if 0) and get number of frames due */
+func (pacer *framePacer) tick(tick int64) int {
+	if tick <= 0 {
+		tick = CODEC_FRAME_TIME_BASE
+	}
+	pacer.clock += tick
+	var count int
+	for ; pacer.done+pacer.duration <= pacer.clock; pacer.done += pacer.duration {
+		count++
+	}
+	return count
+}
@@ -0,0 +1,72 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestBridgeReFraming(t *testing.T) {
+	for _, durations := range [][2]int64{{30, 20}, {20, 30}, {10, 30}} {
+		var n int16
+		var reads int
+		source := AudioStreamCreate(nil, &AudioStreamVTable{
+			ReadFrame: func(stream *AudioStream, frame *Frame) error {
+				reads++
+				samples := make([]int16, frame.CodecFrame.Size/BYTES_PER_SAMPLE)
+				for i := range samples {
+					n++
+					samples[i] = n
+				}
+				frame.Type = MEDIA_FRAME_TYPE_AUDIO
+				return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+			},
+		}, SourceStreamCapabilitiesCreate())
+		source.RXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+		source.RXDescriptor.FrameDuration = durations[0]
+
+		var written []int16
+		var writes int
+		sink := AudioStreamCreate(nil, &AudioStreamVTable{
+			WriteFrame: func(stream *AudioStream, frame *Frame) error {
+				writes++
+				samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+				if len(samples) != int(durations[1])*8 {
+					t.Fatalf("%d ms to %d ms: frame of %d samples written", durations[0], durations[1], len(samples))
+				}
+				written = append(written, samples...)
+				return err
+			},
+		}, SinkStreamCapabilitiesCreate())
+		sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+		sink.TXDescriptor.FrameDuration = durations[1]
+
+		context := ContextFactoryCreate().ContextCreate("reframe", nil, 2)
+		var terminations []*Termination
+		for _, stream := range []*AudioStream{source, sink} {
+			termination := TerminationBaseCreate(nil, nil, nil, stream, nil)
+			termination.codecManager = CodecManagerDefaultCreate()
+			context.ContextTerminationAdd(termination)
+			terminations = append(terminations, termination)
+		}
+		context.ContextAssociationAdd(terminations[0], terminations[1])
+		if err := context.ContextTopologyApply(); err != nil {
+			t.Fatal(err)
+		}
+
+		/* 600 ms of media clock ticking every 10 ms */
+		for tick := 0; tick < 60; tick++ {
+			if err := context.ContextProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if writes != int(600/durations[1]) || reads > int(600/durations[0])+1 {
+			t.Fatalf("%d ms to %d ms: %d frames read, %d frames written", durations[0], durations[1], reads, writes)
+		}
+		for i, sample := range written {
+			if sample != int16(i+1) {
+				t.Fatalf("%d ms to %d ms: sample %d of %d", durations[0], durations[1], i, sample)
+			}
+		}
+	}
+}
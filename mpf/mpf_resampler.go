@@ -1,11 +1,263 @@
 package mpf
 
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Quality (filter) of resampling */
+type ReSamplerQuality = int32
+
+const (
+	RESAMPLER_QUALITY_LINEAR ReSamplerQuality = iota // linear interpolation, cheap but aliasing
+	RESAMPLER_QUALITY_SINC                           // windowed-sinc (Blackman) low-pass interpolation
+)
+
+/** Number of zero crossings of the sinc kernel at each side */
+const RESAMPLER_SINC_ZERO_CROSSINGS = 8
+
+/** Cutoff of the sinc kernel relative to the Nyquist frequency of the lower rate */
+const RESAMPLER_SINC_CUTOFF = 0.9
+
+/* Quality of the resamplers inserted by bridges */
+var resamplerQuality = RESAMPLER_QUALITY_SINC
+
+/** Set quality of the resamplers created afterwards by bridges (RESAMPLER_QUALITY_SINC by default) */
+func ReSamplerQualitySet(quality ReSamplerQuality) error {
+	if quality != RESAMPLER_QUALITY_LINEAR && quality != RESAMPLER_QUALITY_SINC {
+		return fmt.Errorf("invalid resampler quality %d", quality)
+	}
+	atomic.StoreInt32(&resamplerQuality, quality)
+	return nil
+}
+
+/** Get quality of the resamplers created by bridges */
+func ReSamplerQualityGet() ReSamplerQuality {
+	return atomic.LoadInt32(&resamplerQuality)
+}
+
+/** Resampler derived from audio stream */
+type ReSampler struct {
+	/** Audio stream base */
+	base *AudioStream
+	/** Audio stream source */
+	source *AudioStream
+	/** Frame read from source */
+	frameIn Frame
+
+	/** Output samples per phase: the input position of output k is k*step/phases */
+	phases, step int64
+	/** Half width of the kernel in input samples */
+	width int64
+	/** Kernel coefficients by phase, 2*width each */
+	kernels [][]float64
+
+	channels int
+	/** Input history per channel, the sample of index historyStart first */
+	history      [][]float64
+	historyStart int64
+	/** Number of input samples (per channel) read */
+	inputCount int64
+	/** Number of output samples (per channel) produced */
+	outputCount int64
+}
+
+/* Greatest common divisor */
+func gcd(a, b int64) int64 {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
+
+/* Create kernels of the polyphase filter */
+func (resampler *ReSampler) kernelsCreate(sourceRate, sinkRate int64, quality ReSamplerQuality) {
+	g := gcd(sourceRate, sinkRate)
+	resampler.phases, resampler.step = sinkRate/g, sourceRate/g
+
+	cutoff := 1.0
+	kernel := func(t float64) float64 {
+		/* triangle */
+		return math.Max(0, 1-math.Abs(t))
+	}
+	resampler.width = 1
+	if quality == RESAMPLER_QUALITY_SINC {
+		cutoff = RESAMPLER_SINC_CUTOFF * math.Min(1, float64(sinkRate)/float64(sourceRate))
+		resampler.width = int64(math.Ceil(RESAMPLER_SINC_ZERO_CROSSINGS / cutoff))
+		width := float64(resampler.width)
+		kernel = func(t float64) float64 {
+			if math.Abs(t) >= width {
+				return 0
+			}
+			x := math.Pi * cutoff * t
+			sinc := 1.0
+			if x != 0 {
+				sinc = math.Sin(x) / x
+			}
+			window := 0.42 + 0.5*math.Cos(math.Pi*t/width) + 0.08*math.Cos(2*math.Pi*t/width)
+			return sinc * window
+		}
+	}
+
+	resampler.kernels = make([][]float64, resampler.phases)
+	for phase := range resampler.kernels {
+		coefficients := make([]float64, 2*resampler.width)
+		var sum float64
+		for j := range coefficients {
+			/* distance of the output position to the input sample */
+			t := float64(phase)/float64(resampler.phases) + float64(resampler.width-1-int64(j))
+			coefficients[j] = kernel(t)
+			sum += coefficients[j]
+		}
+		for j := range coefficients {
+			/* unity gain at DC */
+			coefficients[j] /= sum
+		}
+		resampler.kernels[phase] = coefficients
+	}
+}
+
+/* Resample interleaved input samples, the output is delayed by the kernel width */
+func (resampler *ReSampler) process(samples []int16) []int16 {
+	channels := resampler.channels
+	count := int64(len(samples) / channels)
+	for c := 0; c < channels; c++ {
+		for i := int64(0); i < count; i++ {
+			resampler.history[c] = append(resampler.history[c], float64(samples[int(i)*channels+c]))
+		}
+	}
+	resampler.inputCount += count
+
+	var out []int16
+	for ; resampler.outputCount*resampler.step < resampler.inputCount*resampler.phases; resampler.outputCount++ {
+		position := resampler.outputCount * resampler.step
+		index, phase := position/resampler.phases, position%resampler.phases
+		/* the first input sample of the kernel */
+		first := index - 2*resampler.width + 1
+		kernel := resampler.kernels[phase]
+		for c := 0; c < channels; c++ {
+			var y float64
+			for j, h := range kernel {
+				if n := first + int64(j) - resampler.historyStart; n >= 0 {
+					y += h * resampler.history[c][n]
+				}
+			}
+			out = append(out, timeStretchSaturate(y))
+		}
+	}
+
+	/* keep the history the next kernels need */
+	next := resampler.outputCount*resampler.step/resampler.phases - 2*resampler.width + 1
+	if drop := next - resampler.historyStart; drop > 0 {
+		for c := 0; c < channels; c++ {
+			resampler.history[c] = append(resampler.history[c][:0], resampler.history[c][drop:]...)
+		}
+		resampler.historyStart = next
+	}
+	return out
+}
+
+/* Read frame of source and resample it */
+func (resampler *ReSampler) frameRead(frame *Frame) error {
+	in := &resampler.frameIn
+	in.Type = MEDIA_FRAME_TYPE_NONE
+	in.Marker = MPF_MARKER_NONE
+	in.AudioLevel = FrameAudioLevel{}
+	in.CodecFrame.Size = resampler.source.RXDescriptor.CodecLinearFrameSizeGet()
+	if err := resampler.source.AudioStreamFrameRead(in); err != nil {
+		return err
+	}
+
+	frame.Type = in.Type
+	frame.Marker = in.Marker
+	frame.PayloadType = in.PayloadType
+	frame.AudioLevel = in.AudioLevel
+	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		frame.EventFrame = in.EventFrame
+	}
+	var samples []int16
+	if (in.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		var err error
+		if samples, err = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&in.CodecFrame)); err != nil {
+			return err
+		}
+	} else {
+		/* silence keeps the filter in time */
+		samples = make([]int16, in.CodecFrame.Size/BYTES_PER_SAMPLE)
+	}
+	out := resampler.process(samples)
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+		return nil
+	}
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(out))
+}
+
 /**
  * Create audio stream resampler.
  * @param source the source stream to resample
  * @param sink the sink stream to resample to
- * @param pool the pool to allocate memory from
  */
 func ReSamplerCreate(source *AudioStream, sink *AudioStream) (*AudioStream, error) {
-	return nil, nil
+	if sink == nil || sink.TXDescriptor == nil {
+		return nil, fmt.Errorf("sink is nil")
+	}
+	return ReSamplerRateCreate(source, sink.TXDescriptor.SamplingRate, ReSamplerQualityGet())
+}
+
+/**
+ * Create stage resampling (linear) audio of source.
+ * @param source the source stream to resample
+ * @param samplingRate the sampling rate to resample to
+ * @param quality the quality of resampling
+ */
+func ReSamplerRateCreate(source *AudioStream, samplingRate uint16, quality ReSamplerQuality) (*AudioStream, error) {
+	if source == nil || source.RXDescriptor == nil {
+		return nil, fmt.Errorf("source is nil")
+	}
+	if !CodecLPcmDescriptorMatch(source.RXDescriptor) {
+		return nil, fmt.Errorf("resampling of codec %s is not supported", source.RXDescriptor.Name)
+	}
+	if !ReSamplerSupported(source.RXDescriptor.SamplingRate, samplingRate) {
+		return nil, fmt.Errorf("resampling %d Hz to %d Hz is not supported", source.RXDescriptor.SamplingRate, samplingRate)
+	}
+	if quality != RESAMPLER_QUALITY_LINEAR && quality != RESAMPLER_QUALITY_SINC {
+		return nil, fmt.Errorf("invalid resampler quality %d", quality)
+	}
+
+	resampler := &ReSampler{
+		source:   source,
+		channels: int(source.RXDescriptor.ChannelCount),
+	}
+	resampler.kernelsCreate(int64(source.RXDescriptor.SamplingRate), int64(samplingRate), quality)
+	resampler.history = make([][]float64, resampler.channels)
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(source) },
+		OpenRX:  func(_ *AudioStream, codec *Codec) error { return source.AudioStreamRXOpen(codec) },
+		CloseRX: func(*AudioStream) error { return source.AudioStreamRXClose() },
+		ReadFrame: func(_ *AudioStream, frame *Frame) error {
+			return resampler.frameRead(frame)
+		},
+	}
+	resampler.base = AudioStreamCreate(resampler, vtable, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	if resampler.base == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	resampler.base.RXDescriptor = CodecDescriptorClone(source.RXDescriptor)
+	resampler.base.RXDescriptor.SamplingRate = samplingRate
+	resampler.base.RXEventDescriptor = source.RXEventDescriptor
+	resampler.frameIn.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	return resampler.base, nil
+}
+
+/**
+ * Check whether resampling of the audio is supported.
+ * @param sourceRate the sampling rate of the source
+ * @param sinkRate the sampling rate of the sink
+ */
+func ReSamplerSupported(sourceRate, sinkRate uint16) bool {
+	return SamplingRateCheck(sourceRate, MPF_SAMPLE_RATE_SUPPORTED) && SamplingRateCheck(sinkRate, MPF_SAMPLE_RATE_SUPPORTED)
 }
@@ -0,0 +1,92 @@
+package mpf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Create source of sine at the rate */
+func resamplerTestSource(rate uint16, frequency float64) *AudioStream {
+	var n int
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			samples := make([]int16, frame.CodecFrame.Size/BYTES_PER_SAMPLE)
+			for i := range samples {
+				samples[i] = int16(10000 * math.Sin(2*math.Pi*frequency*float64(n)/float64(rate)))
+				n++
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecLPcmDescriptorCreate(rate, 1)
+	return source
+}
+
+/* Read frames of resampler and measure RMS level in dBFS, skipping the first frames the filter settles in */
+func resamplerTestLevel(t *testing.T, resampler *AudioStream, frameSize int) float64 {
+	var energy float64
+	var count int
+	for i := 0; i < 20; i++ {
+		frame := &Frame{}
+		if err := resampler.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if len(samples) != frameSize {
+			t.Fatalf("frame of %d samples, want %d", len(samples), frameSize)
+		}
+		if i >= 5 {
+			for _, sample := range samples {
+				energy += float64(sample) * float64(sample)
+				count++
+			}
+		}
+	}
+	return 10 * math.Log10(energy/float64(count)/(32768*32768))
+}
+
+func TestReSampler(t *testing.T) {
+	/* sine of 10000 amplitude */
+	reference := 20 * math.Log10(10000/math.Sqrt2/32768)
+	for _, quality := range []ReSamplerQuality{RESAMPLER_QUALITY_LINEAR, RESAMPLER_QUALITY_SINC} {
+		for _, rates := range [][2]uint16{{8000, 16000}, {16000, 8000}, {8000, 48000}, {48000, 32000}} {
+			resampler, err := ReSamplerRateCreate(resamplerTestSource(rates[0], 500), rates[1], quality)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if level := resamplerTestLevel(t, resampler, int(rates[1])/100); math.Abs(level-reference) > 0.5 {
+				t.Fatalf("quality %d, %d to %d Hz: level %.1f dBFS, want %.1f dBFS", quality, rates[0], rates[1], level, reference)
+			}
+		}
+	}
+
+	/* the audio above the Nyquist frequency of the sink is filtered out by sinc */
+	resampler, _ := ReSamplerRateCreate(resamplerTestSource(48000, 6000), 8000, RESAMPLER_QUALITY_SINC)
+	if level := resamplerTestLevel(t, resampler, 80); level > reference-40 {
+		t.Fatalf("alias level %.1f dBFS", level)
+	}
+
+	/* bridge of wideband source and narrowband sink resamples */
+	var written int
+	sink := AudioStreamCreate(nil, &AudioStreamVTable{
+		WriteFrame: func(stream *AudioStream, frame *Frame) error {
+			written = len(codecFrameDataGet(&frame.CodecFrame))
+			return nil
+		},
+	}, SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+	bridge, err := BridgeCreate(resamplerTestSource(16000, 500), sink, CodecManagerDefaultCreate(), "resample")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := bridge.Process(bridge); err != nil || written != 160 {
+		t.Fatalf("%d bytes written: %v", written, err)
+	}
+
+	if _, err := ReSamplerRateCreate(resamplerTestSource(8000, 500), 11025, RESAMPLER_QUALITY_SINC); err == nil {
+		t.Fatalf("resampler to 11025 Hz is created")
+	}
+}
@@ -0,0 +1,418 @@
+package mpf
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+/** Default interval of RTCP reports in msec */
+const RTCP_TX_INTERVAL_DEFAULT = 5000
+
+/** Offset of NTP time (since 1900) to Unix time in seconds */
+const RTCP_NTP_UNIX_OFFSET = 2208988800
+
+/** Event id of termination event raised when RTCP BYE is received from the remote */
+const MPF_RTCP_BYE_EVENT = 2
+
+/** RTCP BYE received, the remote left the session (e.g. the call is gone without signaling) */
+type RtcpByeEvent struct {
+	/** Sources leaving */
+	Ssrcs []uint32
+	/** Reason for leaving, empty if not given */
+	Reason string
+}
+
+/** Statistics of RTP session surfaced by RTCP reports */
+type RtpSessionStats struct {
+	/** Round-trip time measured by the reports of the remote on the SR sent, 0 if not measured yet */
+	RoundTripTime time.Duration
+	/** Fraction of the packets sent lost since the previous report of the remote (0..1) */
+	RemoteFractionLost float64
+	/** Cumulative number of the packets sent lost, as reported by the remote */
+	RemoteCumulativeLost int32
+	/** Interarrival jitter of the packets sent, as reported by the remote */
+	RemoteJitter time.Duration
+	/** Fraction of the packets received lost since the previous report sent (0..1) */
+	FractionLost float64
+	/** Interarrival jitter of the packets received */
+	Jitter time.Duration
+	/** VoIP metrics of the source received, sent in the last RTCP-XR (nil if not sent) */
+	VoipMetrics *RtcpXrVoipMetrics
+	/** VoIP metrics of the source sent, as reported by the remote in RTCP-XR (nil if not reported) */
+	RemoteVoipMetrics *RtcpXrVoipMetrics
+	/** Number of RTCP reports sent */
+	ReportsSent uint32
+	/** Number of RTCP reports received */
+	ReportsReceived uint32
+}
+
+/** RTCP session of RTP stream: the reports are sent periodically and received from the remote */
+type rtcpSession struct {
+	/** Socket RTCP is received on and sent from (the port of RTP + 1), nil if multiplexed with RTP */
+	socket *net.UDPConn
+	/** Channel closed to stop the reports */
+	stop chan struct{}
+	/** Source address of RTCP received the reports are sent back to (symmetric RTP), nil if not latched */
+	remoteAddr *net.UDPAddr
+
+	/** Middle 32 bits of NTP timestamp of the last SR received */
+	lsr uint32
+	/** Time the last SR is received at */
+	lsrTime time.Time
+	/** Statistics */
+	stats RtpSessionStats
+
+	mutex sync.Mutex
+}
+
+/* Get NTP timestamp (seconds, fractions) of time */
+func rtcpNtpTimeGet(t time.Time) (uint32, uint32) {
+	sec := uint32(t.Unix() + RTCP_NTP_UNIX_OFFSET)
+	frac := uint32((uint64(t.Nanosecond()) << 32) / uint64(time.Second))
+	return sec, frac
+}
+
+/* Convert duration in timestamp units of clock rate to time */
+func rtpTsDurationGet(ts uint32, clockRate uint16) time.Duration {
+	if clockRate == 0 {
+		return 0
+	}
+	return time.Duration(int64(ts) * int64(time.Second) / int64(clockRate))
+}
+
+/*
+ * Start RTCP session of RTP stream (the stream must be locked): the socket of RTCP is bound
+ * to the port next to the one of RTP, unless RTCP is multiplexed with RTP.
+ */
+func (rtpStream *RtpStream) rtcpStart() error {
+	if rtpStream.rtcp.stop != nil {
+		return nil
+	}
+	if !rtpStream.rtcpMux {
+		addr := rtpStream.socket.LocalAddr().(*net.UDPAddr)
+		socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: addr.IP, Port: addr.Port + 1})
+		if err != nil {
+			return fmt.Errorf("failed to bind rtcp socket: %v", err)
+		}
+		rtpStream.rtcp.socket = socket
+		go rtpStream.rtcpSocketRun(socket)
+	}
+	interval := time.Duration(rtpStream.settings.rtcpTXInterval) * time.Millisecond
+	if interval == 0 {
+		interval = RTCP_TX_INTERVAL_DEFAULT * time.Millisecond
+	}
+	rtpStream.rtcp.stop = make(chan struct{})
+	go rtpStream.rtcpRun(rtpStream.rtcp.stop, interval)
+	return nil
+}
+
+/* Stop RTCP session of RTP stream (the stream must be locked) */
+func (rtpStream *RtpStream) rtcpStop() {
+	if rtpStream.rtcp.stop == nil {
+		return
+	}
+	close(rtpStream.rtcp.stop)
+	rtpStream.rtcp.stop = nil
+	if rtpStream.rtcp.socket != nil {
+		_ = rtpStream.rtcp.socket.Close()
+		rtpStream.rtcp.socket = nil
+	}
+}
+
+/* Send reports periodically until stopped */
+func (rtpStream *RtpStream) rtcpRun(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_ = RtpStreamRtcpReportSend(rtpStream.base)
+		}
+	}
+}
+
+/* Receive RTCP packets on socket until it is closed */
+func (rtpStream *RtpStream) rtcpSocketRun(socket *net.UDPConn) {
+	buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+	for {
+		n, addr, err := socket.ReadFromUDP(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			return
+		}
+		if StunMessageCheck(buffer[:n]) {
+			rtpStream.iceCheckHandle(socket, buffer[:n], addr, ICE_COMPONENT_RTCP)
+			continue
+		}
+		if rtpStream.rtcpPacketReceive(buffer[:n], time.Now()) {
+			rtpStream.remoteLatch(addr, true)
+		}
+	}
+}
+
+/* Make report block of the source received, false if nothing is received yet */
+func (receiver *RtpReceiver) rtpRXReportMake(lsr uint32, lsrTime, now time.Time) (RtcpRRStat, bool) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.stat.ReceivedPackets == 0 {
+		return RtcpRRStat{}, false
+	}
+	/* RFC 3550 A.3 */
+	extendedMax := receiver.history.seqCycles + uint32(receiver.history.seqNumMax)
+	expected := extendedMax - uint32(receiver.history.seqNumBase) + 1
+	expectedInterval := expected - receiver.periodicHistory.expectedPrior
+	receivedInterval := receiver.stat.ReceivedPackets - receiver.periodicHistory.receivedPrior
+	receiver.periodicHistory.expectedPrior = expected
+	receiver.periodicHistory.receivedPrior = receiver.stat.ReceivedPackets
+	lostInterval := int64(expectedInterval) - int64(receivedInterval)
+	receiver.rrStat.fraction = 0
+	if expectedInterval != 0 && lostInterval > 0 {
+		receiver.rrStat.fraction = uint32((lostInterval << 8) / int64(expectedInterval))
+	}
+	receiver.rrStat.lost = int32(receiver.stat.LostPackets)
+	if receiver.rrStat.lost > 0x7FFFFF {
+		receiver.rrStat.lost = 0x7FFFFF
+	}
+	receiver.rrStat.lastSeq = extendedMax
+
+	rrStat := receiver.rrStat
+	/* the jitter is kept scaled by 16 */
+	rrStat.jitter = receiver.rrStat.jitter >> 4
+	rrStat.lsr = lsr
+	if lsr != 0 {
+		/* in units of 1/65536 sec */
+		rrStat.dlsr = uint32(now.Sub(lsrTime) * 65536 / time.Second)
+	}
+	return rrStat, true
+}
+
+/* Make compound RTCP report: SR if anything is sent, RR otherwise, followed by SDES CNAME (the stream must be locked) */
+func (rtpStream *RtpStream) rtcpReportMake(now time.Time) []byte {
+	rtpStream.rtcp.mutex.Lock()
+	lsr, lsrTime := rtpStream.rtcp.lsr, rtpStream.rtcp.lsrTime
+	rtpStream.rtcp.mutex.Unlock()
+
+	var rrStats []RtcpRRStat
+	if rrStat, ok := rtpStream.receiver.rtpRXReportMake(lsr, lsrTime, now); ok {
+		rrStats = append(rrStats, rrStat)
+		rtpStream.rtcp.mutex.Lock()
+		rtpStream.rtcp.stats.FractionLost = float64(rrStat.fraction) / 256
+		rtpStream.rtcp.mutex.Unlock()
+	}
+
+	rtpStream.transmitter.mutex.Lock()
+	srStat := rtpStream.transmitter.srStat
+	srStat.rtpTs = rtpStream.transmitter.timestamp
+	rtpStream.transmitter.mutex.Unlock()
+	var report []byte
+	if srStat.sentPackets > 0 {
+		srStat.ntpSec, srStat.ntpFrac = rtcpNtpTimeGet(now)
+		report = RtcpSRAppend(report, &srStat, rrStats)
+	} else {
+		report = RtcpRRAppend(report, srStat.ssrc, rrStats)
+	}
+	report = RtcpSdesAppend(report, srStat.ssrc, rtpStream.rtcpCnameGet())
+	if rtpStream.settings.rtcpXr && len(rrStats) > 0 {
+		rtpStream.rtcp.mutex.Lock()
+		roundTripDelay := rtcpXrDurationClamp(float64(rtpStream.rtcp.stats.RoundTripTime / time.Millisecond))
+		rtpStream.rtcp.mutex.Unlock()
+		rtpStream.receiver.mutex.Lock()
+		metrics := rtpStream.receiver.rtpRXVoipMetricsMake(roundTripDelay)
+		rtpStream.receiver.mutex.Unlock()
+		report = RtcpXrAppend(report, srStat.ssrc, []RtcpXrVoipMetrics{metrics})
+		rtpStream.rtcp.mutex.Lock()
+		rtpStream.rtcp.stats.VoipMetrics = &metrics
+		rtpStream.rtcp.mutex.Unlock()
+	}
+	return report
+}
+
+/* Get canonical name of the source sent (RFC 3550 6.5.1, the stream must be locked) */
+func (rtpStream *RtpStream) rtcpCnameGet() string {
+	if rtpStream.socket == nil {
+		return "mpf"
+	}
+	return "mpf@" + rtpStream.socket.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+/*
+ * Process compound RTCP packet received, the reports on the source sent are surfaced in statistics.
+ * Return true if the packet is valid.
+ */
+func (rtpStream *RtpStream) rtcpPacketReceive(data []byte, now time.Time) bool {
+	if srtpRX := rtpStream.srtpRXGet(); srtpRX != nil {
+		var err error
+		if data, err = srtpRX.SrtcpUnprotect(data); err != nil {
+			return false
+		}
+	}
+	rtpStream.transmitter.mutex.Lock()
+	ssrc := rtpStream.transmitter.srStat.ssrc
+	var clockRate uint16
+	if rtpStream.transmitter.descriptor != nil {
+		clockRate = rtpStream.transmitter.descriptor.CodecRtpClockRateGet()
+	}
+	rtpStream.transmitter.mutex.Unlock()
+	rtpStream.rtcp.mutex.Lock()
+	reported := false
+	var bye *RtcpByeEvent
+	err := RtcpCompoundParse(data, func(header *RtcpHeader, body []byte) error {
+		var blocks []byte
+		switch RtcpType(header.pt) {
+		case RTCP_BYE:
+			ssrcs, reason, err := rtcpByeParse(header, body)
+			if err != nil {
+				return err
+			}
+			bye = &RtcpByeEvent{Ssrcs: ssrcs, Reason: reason}
+			return nil
+		case RTCP_XR:
+			metrics, err := rtcpXrParse(body)
+			if err != nil {
+				return err
+			}
+			for i := range metrics {
+				if metrics[i].Ssrc == ssrc {
+					rtpStream.rtcp.stats.RemoteVoipMetrics = &metrics[i]
+				}
+			}
+			return nil
+		case RTCP_SR:
+			if len(body) < 4+RTCP_SR_INFO_SIZE {
+				return fmt.Errorf("rtcp sr is truncated")
+			}
+			srStat := rtcpSRStatParse(body)
+			rtpStream.rtcp.lsr = srStat.ntpSec<<16 | srStat.ntpFrac>>16
+			rtpStream.rtcp.lsrTime = now
+			blocks = body[4+RTCP_SR_INFO_SIZE:]
+		case RTCP_RR:
+			if len(body) < 4 {
+				return fmt.Errorf("rtcp rr is truncated")
+			}
+			blocks = body[4:]
+		default:
+			return nil
+		}
+		reported = true
+		for i := 0; i < int(header.count) && len(blocks) >= RTCP_REPORT_BLOCK_SIZE; i++ {
+			rrStat := rtcpRRStatParse(blocks)
+			blocks = blocks[RTCP_REPORT_BLOCK_SIZE:]
+			if rrStat.ssrc != ssrc {
+				continue
+			}
+			stats := &rtpStream.rtcp.stats
+			stats.RemoteFractionLost = float64(rrStat.fraction) / 256
+			stats.RemoteCumulativeLost = rrStat.lost
+			stats.RemoteJitter = rtpTsDurationGet(rrStat.jitter, clockRate)
+			if rrStat.lsr != 0 {
+				/* RFC 3550 6.4.1, in units of 1/65536 sec */
+				ntpSec, ntpFrac := rtcpNtpTimeGet(now)
+				if rtt := int32(ntpSec<<16 | ntpFrac>>16 - rrStat.lsr - rrStat.dlsr); rtt >= 0 {
+					stats.RoundTripTime = time.Duration(int64(rtt) * int64(time.Second) / 65536)
+				}
+			}
+		}
+		return nil
+	})
+	if err == nil && reported {
+		rtpStream.rtcp.stats.ReportsReceived++
+	}
+	rtpStream.rtcp.mutex.Unlock()
+
+	/* the owner of the termination tears the session down, it is not done here */
+	if err == nil && bye != nil {
+		if termination := rtpStream.base.termination; termination != nil && termination.EventHandler != nil {
+			_ = termination.EventHandler(termination, MPF_RTCP_BYE_EVENT, bye)
+		}
+	}
+	return err == nil
+}
+
+/**
+ * Send RTCP report of RTP stream to the remote immediately (the reports are sent periodically anyway).
+ * @param stream RTP stream to send report of
+ */
+func RtpStreamRtcpReportSend(stream *AudioStream) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.rtcpPacketSend(rtpStream.rtcpReportMake(time.Now()))
+}
+
+/*
+ * Send RTCP BYE along with the report (the stream must be locked), unless the session is not started.
+ * The source sent leaves the session, e.g. the termination is closed.
+ */
+func (rtpStream *RtpStream) rtcpByeSend(reason string) error {
+	if rtpStream.rtcp.stop == nil {
+		return nil
+	}
+	packet := rtpStream.rtcpReportMake(time.Now())
+	rtpStream.transmitter.mutex.Lock()
+	ssrc := rtpStream.transmitter.srStat.ssrc
+	rtpStream.transmitter.mutex.Unlock()
+	return rtpStream.rtcpPacketSend(RtcpByeAppend(packet, ssrc, reason))
+}
+
+/*
+ * Send RTCP packet to the remote, on the port of RTP + 1 (or the source of RTCP latched onto)
+ * or multiplexed with RTP (the stream must be locked)
+ */
+func (rtpStream *RtpStream) rtcpPacketSend(data []byte) error {
+	socket, remoteAddr := rtpStream.rtcp.socket, rtpStream.remoteAddr
+	if rtpStream.rtcpMux {
+		socket = rtpStream.socket
+	} else if rtpStream.rtcp.remoteAddr != nil {
+		remoteAddr = rtpStream.rtcp.remoteAddr
+	} else if remoteAddr != nil {
+		remoteAddr = &net.UDPAddr{IP: remoteAddr.IP, Port: remoteAddr.Port + 1}
+	}
+	if socket == nil || remoteAddr == nil {
+		return fmt.Errorf("no rtcp session to send report")
+	}
+	if rtpStream.srtpTX != nil {
+		var err error
+		if data, err = rtpStream.srtpTX.SrtcpProtect(data); err != nil {
+			return err
+		}
+	}
+	if _, err := socket.WriteToUDP(data, remoteAddr); err != nil {
+		return err
+	}
+	rtpStream.rtcp.mutex.Lock()
+	rtpStream.rtcp.stats.ReportsSent++
+	rtpStream.rtcp.mutex.Unlock()
+	return nil
+}
+
+/**
+ * Get statistics of RTP session surfaced by RTCP reports.
+ * @param stream RTP stream to get statistics of
+ */
+func RtpStreamSessionStatsGet(stream *AudioStream) (RtpSessionStats, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return RtpSessionStats{}, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.receiver.mutex.Lock()
+	var jitter time.Duration
+	if rtpStream.receiver.descriptor != nil {
+		jitter = rtpTsDurationGet(rtpStream.receiver.rrStat.jitter>>4, rtpStream.receiver.descriptor.CodecRtpClockRateGet())
+	}
+	rtpStream.receiver.mutex.Unlock()
+
+	rtpStream.rtcp.mutex.Lock()
+	defer rtpStream.rtcp.mutex.Unlock()
+	stats := rtpStream.rtcp.stats
+	stats.Jitter = jitter
+	return stats, nil
+}
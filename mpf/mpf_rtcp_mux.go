@@ -0,0 +1,58 @@
+package mpf
+
+import "fmt"
+
+/** Range of RTCP packet types, the second octet of RTCP packets multiplexed with RTP falls in (RFC 5761) */
+const (
+	RTCP_MUX_PT_MIN = 192
+	RTCP_MUX_PT_MAX = 223
+)
+
+/** Range of RTP payload types conflicting with RTCP packet types, not to be used with rtcp-mux */
+const (
+	RTCP_MUX_RTP_PT_CONFLICT_MIN RtpPayloadType = 64
+	RTCP_MUX_RTP_PT_CONFLICT_MAX RtpPayloadType = 95
+)
+
+/**
+ * Check whether packet received on port RTP and RTCP are multiplexed on is RTCP one.
+ * The packets are distinguished by the second octet (RTCP packet type vs RTP marker and payload type).
+ * @param data the packet received
+ */
+func RtcpMuxPacketCheck(data []byte) bool {
+	if len(data) < 2 || (data[0]>>6) != RTP_VERSION {
+		return false
+	}
+	return data[1] >= RTCP_MUX_PT_MIN && data[1] <= RTCP_MUX_PT_MAX
+}
+
+/**
+ * Check that codec list doesn't use payload types conflicting with RTCP packet types.
+ * @param codecList the codec list to check
+ */
+func RtcpMuxCodecListCheck(codecList *CodecList) error {
+	if codecList == nil || codecList.DescriptorArr == nil {
+		return nil
+	}
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if descriptor.PayloadType >= RTCP_MUX_RTP_PT_CONFLICT_MIN && descriptor.PayloadType <= RTCP_MUX_RTP_PT_CONFLICT_MAX {
+			return fmt.Errorf("payload type %d of %s conflicts with rtcp-mux", descriptor.PayloadType, descriptor.Name)
+		}
+	}
+	return nil
+}
+
+/**
+ * Negotiate multiplexing of RTP and RTCP: it is used if offered/accepted by the settings,
+ * requested by the remote media and none of the codecs conflicts with it.
+ * The result is set to the local media descriptor.
+ */
+func (d *RtpStreamDescriptor) RtpStreamDescriptorRtcpMuxNegotiate() error {
+	if d.local == nil || d.remote == nil || d.settings == nil {
+		return fmt.Errorf("local, remote media or settings of rtp stream is nil")
+	}
+	d.local.rtcpMux = d.settings.rtcpMux && d.remote.rtcpMux &&
+		RtcpMuxCodecListCheck(&d.remote.codecList) == nil && RtcpMuxCodecListCheck(&d.local.codecList) == nil
+	return nil
+}
@@ -0,0 +1,60 @@
+package mpf
+
+import "testing"
+
+func TestRtcpMuxPacketCheck(t *testing.T) {
+	for _, test := range []struct {
+		data []byte
+		rtcp bool
+	}{
+		{[]byte{0x80, 200}, true},         /* SR */
+		{[]byte{0x81, 201}, true},         /* RR */
+		{[]byte{0x80, 0x00}, false},       /* PCMU */
+		{[]byte{0x80, 0x80 | 101}, false}, /* telephone-event with marker */
+		{[]byte{0x40, 200}, false},        /* wrong version */
+		{[]byte{0x80}, false},
+	} {
+		if RtcpMuxPacketCheck(test.data) != test.rtcp {
+			t.Errorf("packet %x is not demultiplexed as rtcp %v", test.data, test.rtcp)
+		}
+	}
+}
+
+func TestRtcpMuxNegotiate(t *testing.T) {
+	if RtpAttribIdFind("RTCP-MUX") != RTP_ATTRIB_RTCP_MUX || RtpAttribStrGet(RTP_ATTRIB_RTCP_MUX) != "rtcp-mux" {
+		t.Fatal("rtcp-mux attribute is not found")
+	}
+
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	stream := termination.TerminationAudioStreamGet()
+
+	settings := RtpSettingsAlloc()
+	settings.RtpSettingsRtcpMuxSet(true)
+	remote := RtpMediaDescriptorAlloc()
+	remote.RtpMediaDescriptorRtcpMuxSet(true)
+	*remote.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, "PCMU telephone-event/101/8000")
+	streamDescriptor := &RtpStreamDescriptor{local: RtpMediaDescriptorAlloc(), remote: remote, settings: settings}
+	if err := streamDescriptor.RtpStreamDescriptorRtcpMuxNegotiate(); err != nil {
+		t.Fatal(err)
+	}
+	if !streamDescriptor.local.RtpMediaDescriptorRtcpMuxGet() {
+		t.Fatal("rtcp-mux is not negotiated")
+	}
+
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioLocalSet(streamDescriptor.local)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if !RtpStreamRtcpPacketCheck(stream, []byte{0x80, 200}) || RtpStreamRtcpPacketCheck(stream, []byte{0x80, 0}) {
+		t.Fatal("packets are not demultiplexed")
+	}
+
+	/* conflicting payload type disables rtcp-mux */
+	CodecListAdd(remote.RtpMediaDescriptorCodecListGet()).PayloadType = 72
+	streamDescriptor.RtpStreamDescriptorRtcpMuxNegotiate()
+	if streamDescriptor.local.RtpMediaDescriptorRtcpMuxGet() {
+		t.Fatal("rtcp-mux is negotiated with conflicting payload type")
+	}
+}
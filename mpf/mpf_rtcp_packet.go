@@ -1,6 +1,11 @@
 package mpf
 
-import "github.com/navi-tt/go-mrcp/utils/binaryx"
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
 
 /** RTCP payload (packet) types */
 type RtcpType = int
@@ -11,6 +16,7 @@ const (
 	RTCP_SDES RtcpType = 202
 	RTCP_BYE  RtcpType = 203
 	RTCP_APP  RtcpType = 204
+	RTCP_XR   RtcpType = 207
 )
 
 /** RTCP SDES types */
@@ -172,3 +178,193 @@ func RtcpRRNToH(rrStat *RtcpRRStat) {
 	//((rr_stat->lost << 16) & 0x00ff0000);
 	//#endif
 }
+
+/** Size of RTCP header */
+const RTCP_HEADER_SIZE = 4
+
+/** Size of sender info of SR (w/o SSRC of the sender) */
+const RTCP_SR_INFO_SIZE = 20
+
+/** Size of report block of SR/RR */
+const RTCP_REPORT_BLOCK_SIZE = 24
+
+/* Append RTCP header of packet of the length in bytes (including the header, multiple of 4) */
+func rtcpHeaderAppend(buf []byte, pt RtcpType, count int, length int) []byte {
+	buf = append(buf, byte(RTP_VERSION<<6|count&0x1F), byte(pt), 0, 0)
+	binary.BigEndian.PutUint16(buf[len(buf)-2:], uint16(length/4-1))
+	return buf
+}
+
+/* Append report block of SR/RR */
+func rtcpRRStatAppend(buf []byte, rrStat *RtcpRRStat) []byte {
+	var block [RTCP_REPORT_BLOCK_SIZE]byte
+	binary.BigEndian.PutUint32(block[0:], rrStat.ssrc)
+	binary.BigEndian.PutUint32(block[4:], rrStat.fraction<<24|uint32(rrStat.lost)&0xFFFFFF)
+	binary.BigEndian.PutUint32(block[8:], rrStat.lastSeq)
+	binary.BigEndian.PutUint32(block[12:], rrStat.jitter)
+	binary.BigEndian.PutUint32(block[16:], rrStat.lsr)
+	binary.BigEndian.PutUint32(block[20:], rrStat.dlsr)
+	return append(buf, block[:]...)
+}
+
+/* Parse report block of SR/RR */
+func rtcpRRStatParse(block []byte) RtcpRRStat {
+	word := binary.BigEndian.Uint32(block[4:])
+	return RtcpRRStat{
+		ssrc:     binary.BigEndian.Uint32(block[0:]),
+		fraction: word >> 24,
+		/* 24 bits signed */
+		lost:    int32(word<<8) >> 8,
+		lastSeq: binary.BigEndian.Uint32(block[8:]),
+		jitter:  binary.BigEndian.Uint32(block[12:]),
+		lsr:     binary.BigEndian.Uint32(block[16:]),
+		dlsr:    binary.BigEndian.Uint32(block[20:]),
+	}
+}
+
+/**
+ * Append sender report (SR) to compound RTCP packet.
+ * @param buf the compound packet to append to
+ * @param srStat the sender info
+ * @param rrStats the report blocks of the sources received
+ */
+func RtcpSRAppend(buf []byte, srStat *RtcpSRStat, rrStats []RtcpRRStat) []byte {
+	buf = rtcpHeaderAppend(buf, RTCP_SR, len(rrStats), RTCP_HEADER_SIZE+4+RTCP_SR_INFO_SIZE+len(rrStats)*RTCP_REPORT_BLOCK_SIZE)
+	var info [4 + RTCP_SR_INFO_SIZE]byte
+	binary.BigEndian.PutUint32(info[0:], srStat.ssrc)
+	binary.BigEndian.PutUint32(info[4:], srStat.ntpSec)
+	binary.BigEndian.PutUint32(info[8:], srStat.ntpFrac)
+	binary.BigEndian.PutUint32(info[12:], srStat.rtpTs)
+	binary.BigEndian.PutUint32(info[16:], srStat.sentPackets)
+	binary.BigEndian.PutUint32(info[20:], srStat.sentOctets)
+	buf = append(buf, info[:]...)
+	for i := range rrStats {
+		buf = rtcpRRStatAppend(buf, &rrStats[i])
+	}
+	return buf
+}
+
+/**
+ * Append receiver report (RR) to compound RTCP packet.
+ * @param buf the compound packet to append to
+ * @param ssrc the source generating the report
+ * @param rrStats the report blocks of the sources received
+ */
+func RtcpRRAppend(buf []byte, ssrc uint32, rrStats []RtcpRRStat) []byte {
+	buf = rtcpHeaderAppend(buf, RTCP_RR, len(rrStats), RTCP_HEADER_SIZE+4+len(rrStats)*RTCP_REPORT_BLOCK_SIZE)
+	buf = append(buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], ssrc)
+	for i := range rrStats {
+		buf = rtcpRRStatAppend(buf, &rrStats[i])
+	}
+	return buf
+}
+
+/**
+ * Append source description (SDES) of CNAME to compound RTCP packet.
+ * @param buf the compound packet to append to
+ * @param ssrc the source described
+ * @param cname the canonical name of the source
+ */
+func RtcpSdesAppend(buf []byte, ssrc uint32, cname string) []byte {
+	if len(cname) > 255 {
+		cname = cname[:255]
+	}
+	/* SSRC, CNAME item and END item padded to 32 bits */
+	length := RTCP_HEADER_SIZE + (4+2+len(cname)+1+3)/4*4
+	buf = rtcpHeaderAppend(buf, RTCP_SDES, 1, length)
+	chunk := make([]byte, length-RTCP_HEADER_SIZE)
+	binary.BigEndian.PutUint32(chunk[0:], ssrc)
+	chunk[4] = byte(RTCP_SDES_CNAME)
+	chunk[5] = byte(len(cname))
+	copy(chunk[6:], cname)
+	return append(buf, chunk...)
+}
+
+/**
+ * Append goodbye (BYE) to compound RTCP packet.
+ * @param buf the compound packet to append to
+ * @param ssrc the source leaving
+ * @param reason the reason for leaving (none if empty)
+ */
+func RtcpByeAppend(buf []byte, ssrc uint32, reason string) []byte {
+	if len(reason) > 255 {
+		reason = reason[:255]
+	}
+	length := RTCP_HEADER_SIZE + 4
+	if reason != "" {
+		length += (1 + len(reason) + 3) / 4 * 4
+	}
+	buf = rtcpHeaderAppend(buf, RTCP_BYE, 1, length)
+	body := make([]byte, length-RTCP_HEADER_SIZE)
+	binary.BigEndian.PutUint32(body[0:], ssrc)
+	if reason != "" {
+		body[4] = byte(len(reason))
+		copy(body[5:], reason)
+	}
+	return append(buf, body...)
+}
+
+/* Parse body of BYE: the sources leaving and the reason (if any) */
+func rtcpByeParse(header *RtcpHeader, body []byte) ([]uint32, string, error) {
+	count := int(header.count)
+	if len(body) < count*4 {
+		return nil, "", fmt.Errorf("rtcp bye is truncated")
+	}
+	ssrcs := make([]uint32, count)
+	for i := range ssrcs {
+		ssrcs[i] = binary.BigEndian.Uint32(body[i*4:])
+	}
+	reason := ""
+	if rest := body[count*4:]; len(rest) > 0 && int(rest[0]) < len(rest) {
+		reason = string(rest[1 : 1+int(rest[0])])
+	}
+	return ssrcs, reason, nil
+}
+
+/**
+ * Parse compound RTCP packet, the packets of it are passed to the handler one by one.
+ * @param data the compound packet received
+ * @param handler the handler of the header and the body (w/o header and padding) of the packets
+ */
+func RtcpCompoundParse(data []byte, handler func(header *RtcpHeader, body []byte) error) error {
+	for len(data) > 0 {
+		if len(data) < RTCP_HEADER_SIZE || (data[0]>>6) != RTP_VERSION {
+			return fmt.Errorf("invalid rtcp packet")
+		}
+		header := &RtcpHeader{
+			version: uint(data[0] >> 6),
+			padding: uint(data[0]>>5) & 0x01,
+			count:   uint(data[0]) & 0x1F,
+			pt:      uint(data[1]),
+			length:  binary.BigEndian.Uint16(data[2:]),
+		}
+		size := (int(header.length) + 1) * 4
+		if size > len(data) {
+			return fmt.Errorf("rtcp packet of %d bytes is truncated", len(data))
+		}
+		body := data[RTCP_HEADER_SIZE:size]
+		if header.padding == 1 && len(body) > 0 {
+			if padding := int(body[len(body)-1]); padding <= len(body) {
+				body = body[:len(body)-padding]
+			}
+		}
+		if err := handler(header, body); err != nil {
+			return err
+		}
+		data = data[size:]
+	}
+	return nil
+}
+
+/* Parse sender info of SR (including SSRC of the sender) */
+func rtcpSRStatParse(body []byte) RtcpSRStat {
+	return RtcpSRStat{
+		ssrc:        binary.BigEndian.Uint32(body[0:]),
+		ntpSec:      binary.BigEndian.Uint32(body[4:]),
+		ntpFrac:     binary.BigEndian.Uint32(body[8:]),
+		rtpTs:       binary.BigEndian.Uint32(body[12:]),
+		sentPackets: binary.BigEndian.Uint32(body[16:]),
+		sentOctets:  binary.BigEndian.Uint32(body[20:]),
+	}
+}
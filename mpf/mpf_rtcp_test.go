@@ -0,0 +1,244 @@
+package mpf
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+/* Create RTP termination of PCMU with RTCP enabled on the loopback, return the address bound */
+func rtcpTestTerminationCreate(t *testing.T, direction StreamDirection, byePolicy ByePolicy, handler TerminationEventHandler) (*Termination, *net.UDPAddr) {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	termination.EventHandler = handler
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	local.RtpMediaDescriptorDirectionSet(direction)
+	*local.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, "PCMU")
+	settings := RtpSettingsAlloc()
+	/* the reports are sent by the test */
+	settings.RtpSettingsRtcpSet(true, 60000)
+	settings.RtpSettingsRtcpByePolicySet(byePolicy)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioSettingsSet(settings)
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	ip, port := local.RtpMediaDescriptorAddressGet()
+	return termination, &net.UDPAddr{IP: net.ParseIP(ip), Port: int(port)}
+}
+
+/* Create RTP terminations sending to each other, the first one sends audio to the second one (handling the events by handler) */
+func rtcpTestPeersCreate(t *testing.T, byePolicy ByePolicy, handler TerminationEventHandler) (*Termination, *Termination) {
+	sender, senderAddr := rtcpTestTerminationCreate(t, STREAM_DIRECTION_SEND, byePolicy, nil)
+	receiver, receiverAddr := rtcpTestTerminationCreate(t, STREAM_DIRECTION_RECEIVE, byePolicy, handler)
+	for _, peer := range []struct {
+		termination *Termination
+		addr        *net.UDPAddr
+	}{{sender, receiverAddr}, {receiver, senderAddr}} {
+		descriptor := RtpTerminationDescriptorAlloc()
+		descriptor.RtpTerminationDescriptorAudioRemoteSet(rtpTestRemoteCreate(peer.addr, 10))
+		if err := peer.termination.TerminationModify(descriptor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return sender, receiver
+}
+
+/* Wait for RTCP report received by stream */
+func rtcpTestReportWait(t *testing.T, stream *AudioStream, reports uint32) RtpSessionStats {
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		stats, err := RtpStreamSessionStatsGet(stream)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.ReportsReceived == reports {
+			return stats
+		}
+	}
+	t.Fatalf("rtcp report %d is not received", reports)
+	return RtpSessionStats{}
+}
+
+func TestRtcpReports(t *testing.T) {
+	sender, receiver := rtcpTestPeersCreate(t, RTCP_BYE_DISABLE, nil)
+	defer TerminationDestroy(sender)
+	defer TerminationDestroy(receiver)
+
+	senderStream := sender.TerminationAudioStreamGet()
+	if err := senderStream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	receiverStream := receiver.TerminationAudioStreamGet()
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(receiverStream.RXDescriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := receiverStream.AudioStreamRXOpen(codec); err != nil {
+		t.Fatal(err)
+	}
+
+	/* 8 packets are sent, 3 of them are lost */
+	for i := 0; i < 8; i++ {
+		if i == 2 || i == 4 || i == 6 {
+			senderStream.Obj.(*RtpStream).transmitter.lastSeqNum++
+		}
+		frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{byte(i)}, 80))
+		if err := senderStream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ := RtpStreamRXStatGet(receiverStream); stat.ReceivedPackets == 8 {
+			break
+		}
+	}
+
+	/* SR of the sender, then RR of the receiver on the source sent */
+	if err := RtpStreamRtcpReportSend(senderStream); err != nil {
+		t.Fatal(err)
+	}
+	rtcpTestReportWait(t, receiverStream, 1)
+	if err := RtpStreamRtcpReportSend(receiverStream); err != nil {
+		t.Fatal(err)
+	}
+	stats := rtcpTestReportWait(t, senderStream, 1)
+	if stats.RemoteFractionLost != float64(3*256/11)/256 || stats.RemoteCumulativeLost != 3 || stats.ReportsSent != 1 {
+		t.Fatalf("sender stats %+v", stats)
+	}
+	/* the delay of the receiver since SR is excluded from round-trip time */
+	if stats.RoundTripTime < 0 || stats.RoundTripTime > 100*time.Millisecond {
+		t.Fatalf("round-trip time %v", stats.RoundTripTime)
+	}
+	if stats, _ = RtpStreamSessionStatsGet(receiverStream); stats.FractionLost != float64(3*256/11)/256 || stats.ReportsSent != 1 {
+		t.Fatalf("receiver stats %+v", stats)
+	}
+}
+
+func TestRtcpBye(t *testing.T) {
+	events := make(chan *RtcpByeEvent, 1)
+	sender, receiver := rtcpTestPeersCreate(t, RTCP_BYE_PER_SESSION, func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == MPF_RTCP_BYE_EVENT {
+			events <- descriptor.(*RtcpByeEvent)
+		}
+		return nil
+	})
+	defer TerminationDestroy(receiver)
+	ssrc := sender.TerminationAudioStreamGet().Obj.(*RtpStream).transmitter.srStat.ssrc
+
+	/* BYE is sent as the session of the sender ends */
+	if err := TerminationDestroy(sender); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case event := <-events:
+		if len(event.Ssrcs) != 1 || event.Ssrcs[0] != ssrc || event.Reason != "session ended" {
+			t.Fatalf("bye event %+v", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("bye event is not raised")
+	}
+}
+
+func TestRtcpXr(t *testing.T) {
+	/* lone losses within the gap, then a burst of losses close to each other */
+	history := rtcpXrBurstHistory{}
+	receive := func(count int) {
+		for i := 0; i < count; i++ {
+			history.rtcpXrPacketUpdate(0)
+		}
+	}
+	receive(20)
+	history.rtcpXrPacketUpdate(1)
+	receive(20)
+	history.rtcpXrPacketUpdate(1)
+	receive(20)
+	history.rtcpXrPacketUpdate(1)
+	receive(2)
+	history.rtcpXrPacketUpdate(1)
+	receive(2)
+	history.rtcpXrPacketUpdate(1)
+	receive(20)
+	burstDensity, gapDensity, burstDuration, gapDuration := history.rtcpXrBurstMetricsGet(20)
+	if burstDensity <= gapDensity || gapDensity == 0 || burstDuration == 0 || gapDuration <= burstDuration {
+		t.Fatalf("burst density %d gap density %d burst duration %d gap duration %d", burstDensity, gapDensity, burstDuration, gapDuration)
+	}
+
+	metrics := []RtcpXrVoipMetrics{{Ssrc: 7, LossRate: 12, BurstDensity: burstDensity, BurstDuration: burstDuration,
+		SignalLevel: -20, RFactor: 80, MosLq: 41, RxConfig: RTCP_XR_RX_CONFIG, JbAbsMax: 500}}
+	packet := RtcpXrAppend(nil, 1, metrics)
+	var parsed []RtcpXrVoipMetrics
+	err := RtcpCompoundParse(packet, func(header *RtcpHeader, body []byte) error {
+		if RtcpType(header.pt) != RTCP_XR {
+			t.Fatalf("rtcp packet type %d", header.pt)
+		}
+		var err error
+		parsed, err = rtcpXrParse(body)
+		return err
+	})
+	if err != nil || len(parsed) != 1 || parsed[0] != metrics[0] {
+		t.Fatalf("xr %+v is parsed as %+v: %v", metrics, parsed, err)
+	}
+
+	if r := EModelRFactorCalculate(0, 0, 0, 25.1); EModelMosCalculate(r) < 4.3 {
+		t.Fatalf("mos %f of r factor %f with no impairment", EModelMosCalculate(r), r)
+	}
+	if EModelRFactorCalculate(300, 0.05, 0, 25.1) >= EModelRFactorCalculate(0, 0.05, 0, 25.1) {
+		t.Fatal("r factor is not impaired by delay")
+	}
+}
+
+func TestRtcpXrReports(t *testing.T) {
+	sender, receiver := rtcpTestPeersCreate(t, RTCP_BYE_DISABLE, nil)
+	defer TerminationDestroy(sender)
+	defer TerminationDestroy(receiver)
+	settings := RtpSettingsAlloc()
+	settings.RtpSettingsRtcpSet(true, 60000)
+	settings.RtpSettingsRtcpXrSet(true)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioSettingsSet(settings)
+	if err := receiver.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	senderStream := sender.TerminationAudioStreamGet()
+	if err := senderStream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	receiverStream := receiver.TerminationAudioStreamGet()
+	if err := receiverStream.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	/* 4 packets are sent, 1 of them is lost */
+	for i := 0; i < 4; i++ {
+		if i == 2 {
+			senderStream.Obj.(*RtpStream).transmitter.lastSeqNum++
+		}
+		frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU}
+		codecFrameDataSet(&frame.CodecFrame, make([]byte, 80))
+		if err := senderStream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ := RtpStreamRXStatGet(receiverStream); stat.ReceivedPackets == 4 {
+			break
+		}
+	}
+
+	if err := RtpStreamRtcpReportSend(receiverStream); err != nil {
+		t.Fatal(err)
+	}
+	stats := rtcpTestReportWait(t, senderStream, 1)
+	remote := stats.RemoteVoipMetrics
+	if remote == nil || remote.LossRate != 256/5 || remote.Gmin != RTCP_XR_GMIN || remote.MosLq == 0 || remote.MosLq > 45 {
+		t.Fatalf("remote voip metrics %+v", remote)
+	}
+	if stats, _ = RtpStreamSessionStatsGet(receiverStream); stats.VoipMetrics == nil || *stats.VoipMetrics != *remote {
+		t.Fatalf("voip metrics %+v are reported as %+v", stats.VoipMetrics, remote)
+	}
+}
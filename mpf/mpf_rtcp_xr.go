@@ -0,0 +1,335 @@
+package mpf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+/** Block type of VoIP metrics report block of RTCP-XR (RFC 3611 4.7) */
+const RTCP_XR_VOIP_METRICS = 7
+
+/** Size of VoIP metrics report block (including the header of the block) */
+const RTCP_XR_VOIP_METRICS_SIZE = 36
+
+/** Gap threshold, min number of packets received between losses separating bursts (RFC 3611 4.7.2) */
+const RTCP_XR_GMIN = 16
+
+/** Value of metric unavailable */
+const RTCP_XR_UNAVAILABLE = 127
+
+/** Receiver configuration of VoIP metrics: standard packet loss concealment, non-adaptive jitter buffer */
+const RTCP_XR_RX_CONFIG = 0xC0 | 0x20
+
+/** Receiver configuration of VoIP metrics: standard packet loss concealment, adaptive jitter buffer */
+const RTCP_XR_RX_CONFIG_ADAPTIVE = 0xC0 | 0x30
+
+/** VoIP metrics report block of RTCP-XR (RFC 3611 4.7), the values are in the units of the block */
+type RtcpXrVoipMetrics struct {
+	/** Source the metrics are of */
+	Ssrc uint32
+	/** Fraction of packets lost (in 1/256) */
+	LossRate uint8
+	/** Fraction of packets discarded, e.g. too late to play (in 1/256) */
+	DiscardRate uint8
+	/** Fraction of packets lost/discarded within bursts (in 1/256) */
+	BurstDensity uint8
+	/** Fraction of packets lost/discarded within gaps between bursts (in 1/256) */
+	GapDensity uint8
+	/** Mean duration of bursts in msec */
+	BurstDuration uint16
+	/** Mean duration of gaps in msec */
+	GapDuration uint16
+	/** Round-trip delay in msec */
+	RoundTripDelay uint16
+	/** End system delay (buffering, packetization) in msec */
+	EndSystemDelay uint16
+	/** Signal level in dBm0 (RTCP_XR_UNAVAILABLE if not measured) */
+	SignalLevel int8
+	/** Noise level in dBm0 (RTCP_XR_UNAVAILABLE if not measured) */
+	NoiseLevel int8
+	/** Residual echo return loss in dB (RTCP_XR_UNAVAILABLE if not measured) */
+	Rerl uint8
+	/** Gap threshold */
+	Gmin uint8
+	/** R factor of the conversation (ITU-T G.107) */
+	RFactor uint8
+	/** R factor of external network segment (RTCP_XR_UNAVAILABLE if not measured) */
+	ExtRFactor uint8
+	/** Listening quality MOS scaled by 10 */
+	MosLq uint8
+	/** Conversational quality MOS scaled by 10 */
+	MosCq uint8
+	/** Receiver configuration (packet loss concealment and jitter buffer type) */
+	RxConfig uint8
+	/** Nominal delay of jitter buffer in msec */
+	JbNominal uint16
+	/** Max delay of jitter buffer in msec */
+	JbMaximum uint16
+	/** Absolute max delay of jitter buffer in msec */
+	JbAbsMax uint16
+}
+
+/** History of losses of RTP receiver, separating bursts and gaps (RFC 3611 Appendix A.2) */
+type rtcpXrBurstHistory struct {
+	/** Packets received since the last loss */
+	pkt uint32
+	/** Losses within current burst */
+	lost uint32
+	/** Transition counts of the Markov model */
+	c11, c13, c14, c22, c23, c33 uint32
+}
+
+/* Account packet received (or a number of packets lost before it) in burst history */
+func (h *rtcpXrBurstHistory) rtcpXrPacketUpdate(lost uint32) {
+	for i := uint32(0); i < lost; i++ {
+		if h.pkt >= RTCP_XR_GMIN {
+			/* the loss ends the gap */
+			if h.lost == 1 {
+				h.c14++
+			} else {
+				h.c13++
+			}
+			h.lost = 1
+			h.c11 += h.pkt
+		} else {
+			h.lost++
+			if h.pkt == 0 {
+				h.c33++
+			} else {
+				h.c23++
+				h.c22 += h.pkt - 1
+			}
+		}
+		h.pkt = 0
+	}
+	h.pkt++
+}
+
+/* Get burst and gap densities (in 1/256) and durations (in msec) of the packets of the duration */
+func (h *rtcpXrBurstHistory) rtcpXrBurstMetricsGet(packetDuration float64) (uint8, uint8, uint16, uint16) {
+	/* the packets received since the last loss are the gap */
+	c11 := float64(h.c11 + h.pkt)
+	c13, c14, c22, c23, c33 := float64(h.c13), float64(h.c14), float64(h.c22), float64(h.c23), float64(h.c33)
+	c31, c32 := c13, c23
+	total := c11 + c14 + c13 + c22 + c23 + c31 + c32 + c33
+	if c13 == 0 {
+		/* no burst, the losses (if any) are within the gap */
+		gapDensity := 0.0
+		if c11+c14 > 0 {
+			gapDensity = 256 * c14 / (c11 + c14)
+		}
+		return 0, rtcpXrRateClamp(gapDensity), 0, rtcpXrDurationClamp(total * packetDuration)
+	}
+	p32 := c32 / (c31 + c32 + c33)
+	p23 := 1.0
+	if c22+c23 >= 1 {
+		p23 = 1 - c22/(c22+c23)
+	}
+	burstDensity := 256 * p23 / (p23 + p32)
+	gapDensity := 0.0
+	if c11+c14 > 0 {
+		gapDensity = 256 * c14 / (c11 + c14)
+	}
+	gapDuration := (c11 + c14 + c13) * packetDuration / c13
+	burstDuration := total*packetDuration/c13 - gapDuration
+	return rtcpXrRateClamp(burstDensity), rtcpXrRateClamp(gapDensity), rtcpXrDurationClamp(burstDuration), rtcpXrDurationClamp(gapDuration)
+}
+
+/* Clamp rate in 1/256 to the field of the block */
+func rtcpXrRateClamp(rate float64) uint8 {
+	if rate >= 255 {
+		return 255
+	}
+	if rate <= 0 {
+		return 0
+	}
+	return uint8(rate)
+}
+
+/* Clamp duration in msec to the field of the block */
+func rtcpXrDurationClamp(duration float64) uint16 {
+	if duration >= math.MaxUint16 {
+		return math.MaxUint16
+	}
+	if duration <= 0 {
+		return 0
+	}
+	return uint16(duration)
+}
+
+/**
+ * Calculate R factor by the simplified E-model (ITU-T G.107), the losses are taken as random.
+ * @param delay the one-way delay in msec (0 for listening quality)
+ * @param loss the fraction of packets lost/discarded (0..1)
+ * @param ie the equipment impairment factor of the codec
+ * @param bpl the packet loss robustness factor of the codec
+ */
+func EModelRFactorCalculate(delay float64, loss float64, ie float64, bpl float64) float64 {
+	id := 0.024 * delay
+	if delay > 177.3 {
+		id += 0.11 * (delay - 177.3)
+	}
+	ppl := loss * 100
+	ieEff := ie + (95-ie)*ppl/(ppl+bpl)
+	r := 93.2 - id - ieEff
+	if r < 0 {
+		return 0
+	}
+	return r
+}
+
+/**
+ * Convert R factor to MOS (ITU-T G.107 Annex B).
+ * @param r the R factor
+ */
+func EModelMosCalculate(r float64) float64 {
+	switch {
+	case r <= 0:
+		return 1
+	case r >= 100:
+		return 4.5
+	}
+	return 1 + 0.035*r + r*(r-60)*(100-r)*7e-6
+}
+
+/* Get equipment impairment and packet loss robustness factors of codec (ITU-T G.113), G.711 with PLC by default */
+func eModelCodecFactorsGet(descriptor *CodecDescriptor) (float64, float64) {
+	if descriptor != nil {
+		switch descriptor.Name {
+		case "G729", "G.729":
+			return 11, 19
+		case "AMR", "AMR-WB":
+			return 5, 10
+		}
+	}
+	return 0, 25.1
+}
+
+/* Make VoIP metrics of the source received (the receiver must be locked) */
+func (receiver *RtpReceiver) rtpRXVoipMetricsMake(roundTripDelay uint16) RtcpXrVoipMetrics {
+	metrics := RtcpXrVoipMetrics{
+		Ssrc:           receiver.rrStat.ssrc,
+		RoundTripDelay: roundTripDelay,
+		SignalLevel:    RTCP_XR_UNAVAILABLE,
+		NoiseLevel:     RTCP_XR_UNAVAILABLE,
+		Rerl:           RTCP_XR_UNAVAILABLE,
+		Gmin:           RTCP_XR_GMIN,
+		ExtRFactor:     RTCP_XR_UNAVAILABLE,
+		RxConfig:       RTCP_XR_RX_CONFIG,
+	}
+	expected := float64(receiver.history.seqCycles + uint32(receiver.history.seqNumMax) - uint32(receiver.history.seqNumBase) + 1)
+	lossRate := float64(receiver.stat.LostPackets) / expected
+	discardRate := float64(receiver.stat.DiscardedPackets) / expected
+	metrics.LossRate = rtcpXrRateClamp(256 * lossRate)
+	metrics.DiscardRate = rtcpXrRateClamp(256 * discardRate)
+
+	frameDuration := float64(CODEC_FRAME_TIME_BASE)
+	if receiver.lastDescriptor != nil {
+		frameDuration = float64(receiver.lastDescriptor.CodecFrameDurationGet())
+	}
+	packetDuration := frameDuration * float64(receiver.packetFrames)
+	metrics.BurstDensity, metrics.GapDensity, metrics.BurstDuration, metrics.GapDuration =
+		receiver.burstHistory.rtcpXrBurstMetricsGet(packetDuration)
+
+	/* the frames are played out after the delay, up to the length of the jitter buffer */
+	if receiver.jb != nil {
+		metrics.JbNominal = rtcpXrDurationClamp(float64(receiver.jb.JitterBufferPlayOutDelayGet()))
+		metrics.JbMaximum = rtcpXrDurationClamp(float64(receiver.jb.JitterBufferConfigGet().maxPlayOutDelay))
+		metrics.JbAbsMax = rtcpXrDurationClamp(float64(receiver.jb.JitterBufferLengthGet()))
+		if receiver.jb.JitterBufferConfigGet().JbConfigAdaptiveGet() {
+			metrics.RxConfig = RTCP_XR_RX_CONFIG_ADAPTIVE
+		}
+	}
+	if metrics.JbMaximum < metrics.JbNominal {
+		metrics.JbMaximum = metrics.JbNominal
+	}
+	metrics.EndSystemDelay = rtcpXrDurationClamp(float64(metrics.JbNominal) + packetDuration)
+
+	ie, bpl := eModelCodecFactorsGet(receiver.lastDescriptor)
+	loss := lossRate + discardRate
+	delay := float64(metrics.EndSystemDelay) + float64(roundTripDelay)/2
+	rFactor := EModelRFactorCalculate(delay, loss, ie, bpl)
+	metrics.RFactor = uint8(math.Round(rFactor))
+	metrics.MosLq = uint8(math.Round(10 * EModelMosCalculate(EModelRFactorCalculate(0, loss, ie, bpl))))
+	metrics.MosCq = uint8(math.Round(10 * EModelMosCalculate(rFactor)))
+	return metrics
+}
+
+/**
+ * Append extended report (XR) of VoIP metrics to compound RTCP packet.
+ * @param buf the compound packet to append to
+ * @param ssrc the source generating the report
+ * @param metrics the metrics of the sources received
+ */
+func RtcpXrAppend(buf []byte, ssrc uint32, metrics []RtcpXrVoipMetrics) []byte {
+	buf = rtcpHeaderAppend(buf, RTCP_XR, 0, RTCP_HEADER_SIZE+4+len(metrics)*RTCP_XR_VOIP_METRICS_SIZE)
+	buf = append(buf, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(buf[len(buf)-4:], ssrc)
+	for i := range metrics {
+		m := &metrics[i]
+		var block [RTCP_XR_VOIP_METRICS_SIZE]byte
+		block[0] = RTCP_XR_VOIP_METRICS
+		binary.BigEndian.PutUint16(block[2:], RTCP_XR_VOIP_METRICS_SIZE/4-1)
+		binary.BigEndian.PutUint32(block[4:], m.Ssrc)
+		block[8], block[9], block[10], block[11] = m.LossRate, m.DiscardRate, m.BurstDensity, m.GapDensity
+		binary.BigEndian.PutUint16(block[12:], m.BurstDuration)
+		binary.BigEndian.PutUint16(block[14:], m.GapDuration)
+		binary.BigEndian.PutUint16(block[16:], m.RoundTripDelay)
+		binary.BigEndian.PutUint16(block[18:], m.EndSystemDelay)
+		block[20], block[21], block[22], block[23] = byte(m.SignalLevel), byte(m.NoiseLevel), m.Rerl, m.Gmin
+		block[24], block[25], block[26], block[27] = m.RFactor, m.ExtRFactor, m.MosLq, m.MosCq
+		block[28] = m.RxConfig
+		binary.BigEndian.PutUint16(block[30:], m.JbNominal)
+		binary.BigEndian.PutUint16(block[32:], m.JbMaximum)
+		binary.BigEndian.PutUint16(block[34:], m.JbAbsMax)
+		buf = append(buf, block[:]...)
+	}
+	return buf
+}
+
+/* Parse body of XR: the VoIP metrics blocks, the other blocks are skipped */
+func rtcpXrParse(body []byte) ([]RtcpXrVoipMetrics, error) {
+	if len(body) < 4 {
+		return nil, fmt.Errorf("rtcp xr is truncated")
+	}
+	var metrics []RtcpXrVoipMetrics
+	for blocks := body[4:]; len(blocks) > 0; {
+		if len(blocks) < 4 {
+			return nil, fmt.Errorf("rtcp xr block is truncated")
+		}
+		size := (int(binary.BigEndian.Uint16(blocks[2:])) + 1) * 4
+		if size > len(blocks) {
+			return nil, fmt.Errorf("rtcp xr block of %d bytes is truncated", len(blocks))
+		}
+		block := blocks[:size]
+		blocks = blocks[size:]
+		if block[0] != RTCP_XR_VOIP_METRICS || size < RTCP_XR_VOIP_METRICS_SIZE {
+			continue
+		}
+		metrics = append(metrics, RtcpXrVoipMetrics{
+			Ssrc:           binary.BigEndian.Uint32(block[4:]),
+			LossRate:       block[8],
+			DiscardRate:    block[9],
+			BurstDensity:   block[10],
+			GapDensity:     block[11],
+			BurstDuration:  binary.BigEndian.Uint16(block[12:]),
+			GapDuration:    binary.BigEndian.Uint16(block[14:]),
+			RoundTripDelay: binary.BigEndian.Uint16(block[16:]),
+			EndSystemDelay: binary.BigEndian.Uint16(block[18:]),
+			SignalLevel:    int8(block[20]),
+			NoiseLevel:     int8(block[21]),
+			Rerl:           block[22],
+			Gmin:           block[23],
+			RFactor:        block[24],
+			ExtRFactor:     block[25],
+			MosLq:          block[26],
+			MosCq:          block[27],
+			RxConfig:       block[28],
+			JbNominal:      binary.BigEndian.Uint16(block[30:]),
+			JbMaximum:      binary.BigEndian.Uint16(block[32:]),
+			JbAbsMax:       binary.BigEndian.Uint16(block[34:]),
+		})
+	}
+	return metrics, nil
+}
@@ -1,5 +1,7 @@
 package mpf
 
+import "strings"
+
 /** RTP attributes */
 type RtpAttrib = int
 
@@ -10,22 +12,62 @@ const (
 	RTP_ATTRIB_SENDRECV
 	RTP_ATTRIB_MID
 	RTP_ATTRIB_PTIME
+	RTP_ATTRIB_RTCP_MUX
+	RTP_ATTRIB_CRYPTO
+	RTP_ATTRIB_ICE_UFRAG
+	RTP_ATTRIB_ICE_PWD
+	RTP_ATTRIB_ICE_LITE
+	RTP_ATTRIB_CANDIDATE
+	RTP_ATTRIB_EXTMAP
 
 	RTP_ATTRIB_COUNT
 	RTP_ATTRIB_UNKNOWN = RTP_ATTRIB_COUNT
 )
 
+/** Names of RTP attributes */
+var rtpAttribNames = [RTP_ATTRIB_COUNT]string{
+	RTP_ATTRIB_RTPMAP:    "rtpmap",
+	RTP_ATTRIB_SENDONLY:  "sendonly",
+	RTP_ATTRIB_RECVONLY:  "recvonly",
+	RTP_ATTRIB_SENDRECV:  "sendrecv",
+	RTP_ATTRIB_MID:       "mid",
+	RTP_ATTRIB_PTIME:     "ptime",
+	RTP_ATTRIB_RTCP_MUX:  "rtcp-mux",
+	RTP_ATTRIB_CRYPTO:    "crypto",
+	RTP_ATTRIB_ICE_UFRAG: "ice-ufrag",
+	RTP_ATTRIB_ICE_PWD:   "ice-pwd",
+	RTP_ATTRIB_ICE_LITE:  "ice-lite",
+	RTP_ATTRIB_CANDIDATE: "candidate",
+	RTP_ATTRIB_EXTMAP:    "extmap",
+}
+
 /** Get audio media attribute name by attribute identifier */
 func RtpAttribStrGet(attribId RtpAttrib) string {
-	return ""
+	if attribId < 0 || attribId >= RTP_ATTRIB_COUNT {
+		return ""
+	}
+	return rtpAttribNames[attribId]
 }
 
 /** Find audio media attribute identifier by attribute name */
 func RtpAttribIdFind(attrib string) RtpAttrib {
-	return 0
+	for attribId, name := range rtpAttribNames {
+		if strings.EqualFold(name, attrib) {
+			return attribId
+		}
+	}
+	return RTP_ATTRIB_UNKNOWN
 }
 
 /** Get string by RTP direction (send/receive) */
 func RtpDirectionStrGet(direction StreamDirection) string {
-	return ""
+	switch direction {
+	case STREAM_DIRECTION_SEND:
+		return rtpAttribNames[RTP_ATTRIB_SENDONLY]
+	case STREAM_DIRECTION_RECEIVE:
+		return rtpAttribNames[RTP_ATTRIB_RECVONLY]
+	case STREAM_DIRECTION_DUPLEX:
+		return rtpAttribNames[RTP_ATTRIB_SENDRECV]
+	}
+	return "inactive"
 }
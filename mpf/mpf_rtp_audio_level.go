@@ -0,0 +1,154 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+/** URI of the client-to-mixer audio level header extension (RFC 6464) */
+const RTP_AUDIO_LEVEL_URI = "urn:ietf:params:rtp-hdrext:ssrc-audio-level"
+
+/** Audio level in -dBov of silence (the lowest level signalled) */
+const RTP_AUDIO_LEVEL_SILENCE = 127
+
+/** Header extension map of SDP media (a=extmap, RFC 8285) */
+type RtpExtmap struct {
+	/** Id of the elements of the extension */
+	Id byte
+	/** Direction of the extension (sendonly, recvonly, ...), empty if not given */
+	Direction string
+	/** URI of the extension */
+	Uri string
+	/** Attributes of the extension, e.g. "vad=on", empty if none */
+	Attributes string
+}
+
+/**
+ * Parse value of extmap attribute, e.g. "1 urn:ietf:params:rtp-hdrext:ssrc-audio-level vad=on".
+ * @param value the value of the attribute
+ */
+func RtpExtmapParse(value string) (*RtpExtmap, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 2 {
+		return nil, fmt.Errorf("invalid extmap attribute [%s]", value)
+	}
+	extmap := &RtpExtmap{Uri: fields[1], Attributes: strings.Join(fields[2:], " ")}
+	idField := fields[0]
+	if i := strings.IndexByte(idField, '/'); i >= 0 {
+		extmap.Direction = strings.ToLower(idField[i+1:])
+		idField = idField[:i]
+	}
+	/* ids of two-byte elements (up to 255) are accepted as well */
+	id, err := strconv.ParseUint(idField, 10, 8)
+	if err != nil || id == 0 {
+		return nil, fmt.Errorf("invalid id of extmap attribute [%s]", value)
+	}
+	extmap.Id = byte(id)
+	return extmap, nil
+}
+
+/** Get value of extmap attribute */
+func (e *RtpExtmap) RtpExtmapStrGet() string {
+	value := strconv.Itoa(int(e.Id))
+	if e.Direction != "" {
+		value += "/" + e.Direction
+	}
+	value += " " + e.Uri
+	if e.Attributes != "" {
+		value += " " + e.Attributes
+	}
+	return value
+}
+
+/**
+ * Set id of the audio level header extension of RTP media descriptor (a=extmap), the extension is not used if 0.
+ * The id of local media is the one of the elements sent and received, as negotiated.
+ */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorAudioLevelSet(id byte) {
+	media.audioLevelId = id
+}
+
+/** Get id of the audio level header extension of RTP media descriptor, 0 if not used */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorAudioLevelGet() byte {
+	return media.audioLevelId
+}
+
+/**
+ * Negotiate audio level header extension: if accepted by the settings and offered by the remote media
+ * (by the id fitting one-byte elements), the local media answers with the same id. It is not used otherwise.
+ */
+func (d *RtpStreamDescriptor) RtpStreamDescriptorAudioLevelNegotiate() error {
+	if d.local == nil || d.remote == nil || d.settings == nil {
+		return fmt.Errorf("local, remote media or settings of rtp stream is nil")
+	}
+	d.local.audioLevelId = 0
+	if d.settings.audioLevel && d.remote.audioLevelId >= RTP_EXTENSION_ONE_BYTE_ID_MIN &&
+		d.remote.audioLevelId <= RTP_EXTENSION_ONE_BYTE_ID_MAX {
+		d.local.audioLevelId = d.remote.audioLevelId
+	}
+	return nil
+}
+
+/**
+ * Calculate audio level in -dBov of linear samples (RFC 6464 3), the level of the RMS relative to the overload.
+ * @param samples the samples to calculate level of
+ */
+func RtpAudioLevelCalculate(samples []int16) byte {
+	if len(samples) == 0 {
+		return RTP_AUDIO_LEVEL_SILENCE
+	}
+	var power float64
+	for _, sample := range samples {
+		power += float64(sample) * float64(sample)
+	}
+	return rtpAudioLevelOfPower(power / float64(len(samples)) / (32768 * 32768))
+}
+
+/* Get audio level in -dBov of mean power relative to the overload one */
+func rtpAudioLevelOfPower(power float64) byte {
+	if power <= 0 {
+		return RTP_AUDIO_LEVEL_SILENCE
+	}
+	level := math.Round(-10 * math.Log10(power))
+	switch {
+	case level < 0:
+		return 0
+	case level > RTP_AUDIO_LEVEL_SILENCE:
+		return RTP_AUDIO_LEVEL_SILENCE
+	}
+	return byte(level)
+}
+
+/* Get mean power relative to the overload one of audio level in -dBov */
+func rtpAudioLevelPowerGet(level byte) float64 {
+	if level >= RTP_AUDIO_LEVEL_SILENCE {
+		return 0
+	}
+	return math.Pow(10, -float64(level)/10)
+}
+
+/**
+ * Parse data of audio level header extension element: the voice activity flag and the level in -dBov.
+ * @param data the data of the element
+ * @return the level, not present if the data is empty
+ */
+func RtpAudioLevelParse(data []byte) FrameAudioLevel {
+	if len(data) == 0 {
+		return FrameAudioLevel{}
+	}
+	return FrameAudioLevel{Present: true, Level: data[0] & 0x7F, Voice: (data[0] & 0x80) != 0}
+}
+
+/**
+ * Marshal audio level to data of audio level header extension element.
+ * @param level the level to marshal
+ */
+func RtpAudioLevelMarshal(level FrameAudioLevel) []byte {
+	data := level.Level & 0x7F
+	if level.Voice {
+		data |= 0x80
+	}
+	return []byte{data}
+}
@@ -0,0 +1,205 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestRtpHeaderExtension(t *testing.T) {
+	header := &RtpHeader{Version: RTP_VERSION, Type: uint32(RTP_PT_PCMU), sequence: 1, timestamp: 160, ssrc: 0x1234}
+	if err := header.RtpHeaderExtensionElementAdd(1, []byte{0x9E}); err != nil {
+		t.Fatal(err)
+	}
+	if err := header.RtpHeaderExtensionElementAdd(5, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := header.RtpHeaderExtensionElementAdd(15, []byte{1}); err == nil {
+		t.Fatal("element of reserved id is added")
+	}
+	packet := header.RtpHeaderMarshal(bytes.Repeat([]byte{0xFF}, 80))
+	/* the elements of 6 bytes are padded to 2 words */
+	if len(packet) != RTP_HEADER_SIZE+4+8+80 || (packet[0]&0x10) == 0 {
+		t.Fatalf("packet of %d bytes", len(packet))
+	}
+	parsed, payload, err := RtpHeaderParse(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(payload) != 80 || payload[0] != 0xFF || parsed.ssrc != 0x1234 {
+		t.Fatalf("payload of %d bytes", len(payload))
+	}
+	if data := parsed.RtpHeaderExtensionElementGet(5); !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Fatalf("element 5 of %v", data)
+	}
+	if data := parsed.RtpHeaderExtensionElementGet(1); !bytes.Equal(data, []byte{0x9E}) {
+		t.Fatalf("element 1 of %v", data)
+	}
+	if data := parsed.RtpHeaderExtensionElementGet(2); data != nil {
+		t.Fatalf("missing element of %v", data)
+	}
+	if length, err := rtpHeaderLengthGet(packet); err != nil || length != RTP_HEADER_SIZE+12 {
+		t.Fatalf("header of %d bytes: %v", length, err)
+	}
+
+	/* two-byte elements, padded between */
+	packet = rtpTestPacketCreate(0x1234, 2, 320, false, []byte{0xFF})
+	packet[0] |= 0x10
+	extension := []byte{0x10, 0x00, 0x00, 0x02, 0x07, 0x01, 0x55, 0x00, 0x01, 0x02, 0xAA, 0xBB}
+	packet = append(packet[:RTP_HEADER_SIZE], append(extension, packet[RTP_HEADER_SIZE:]...)...)
+	if parsed, payload, err = RtpHeaderParse(packet); err != nil || len(payload) != 1 {
+		t.Fatalf("payload of %d bytes: %v", len(payload), err)
+	}
+	if data := parsed.RtpHeaderExtensionElementGet(7); !bytes.Equal(data, []byte{0x55}) {
+		t.Fatalf("element 7 of %v", data)
+	}
+	if data := parsed.RtpHeaderExtensionElementGet(1); !bytes.Equal(data, []byte{0xAA, 0xBB}) {
+		t.Fatalf("element 1 of %v", data)
+	}
+	/* the extension of other profile is not of elements */
+	packet[RTP_HEADER_SIZE] = 0x12
+	if parsed, _, _ = RtpHeaderParse(packet); parsed.RtpHeaderExtensionElementGet(7) != nil {
+		t.Fatal("element of other profile is got")
+	}
+}
+
+func TestRtpAudioLevel(t *testing.T) {
+	for _, test := range []struct {
+		value int16
+		level byte
+	}{
+		{0, RTP_AUDIO_LEVEL_SILENCE},
+		{32767, 0},
+		{1000, 30},
+		{100, 50},
+		{1, 90},
+	} {
+		samples := make([]int16, 160)
+		for i := range samples {
+			/* square wave, the RMS is the amplitude */
+			samples[i] = test.value
+			if i%2 == 1 {
+				samples[i] = -test.value
+			}
+		}
+		if level := RtpAudioLevelCalculate(samples); level != test.level {
+			t.Errorf("level of %d is %d, want %d", test.value, level, test.level)
+		}
+	}
+	level := FrameAudioLevel{Present: true, Level: 42, Voice: true}
+	if data := RtpAudioLevelMarshal(level); !bytes.Equal(data, []byte{0x80 | 42}) || RtpAudioLevelParse(data) != level {
+		t.Fatalf("level is marshaled to %v", data)
+	}
+	if RtpAudioLevelParse(nil).Present {
+		t.Fatal("level of no data is present")
+	}
+
+	extmap, err := RtpExtmapParse("3/recvonly " + RTP_AUDIO_LEVEL_URI + " vad=on")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if extmap.Id != 3 || extmap.Direction != "recvonly" || extmap.Uri != RTP_AUDIO_LEVEL_URI || extmap.Attributes != "vad=on" {
+		t.Fatalf("extmap attribute %+v", extmap)
+	}
+	if value := extmap.RtpExtmapStrGet(); value != "3/recvonly "+RTP_AUDIO_LEVEL_URI+" vad=on" {
+		t.Fatalf("extmap attribute is formatted as [%s]", value)
+	}
+	for _, value := range []string{"0 " + RTP_AUDIO_LEVEL_URI, "x " + RTP_AUDIO_LEVEL_URI, "1"} {
+		if _, err := RtpExtmapParse(value); err == nil {
+			t.Errorf("invalid extmap attribute [%s] is parsed", value)
+		}
+	}
+	if RtpAttribIdFind("extmap") != RTP_ATTRIB_EXTMAP {
+		t.Fatal("extmap attribute is not found")
+	}
+
+	descriptor := RtpStreamDescriptor{local: RtpMediaDescriptorAlloc(), remote: RtpMediaDescriptorAlloc(), settings: RtpSettingsAlloc()}
+	descriptor.remote.RtpMediaDescriptorAudioLevelSet(3)
+	if err := descriptor.RtpStreamDescriptorAudioLevelNegotiate(); err != nil || descriptor.local.RtpMediaDescriptorAudioLevelGet() != 0 {
+		t.Fatalf("audio level is negotiated while not accepted: %v", err)
+	}
+	descriptor.settings.RtpSettingsAudioLevelSet(true)
+	if descriptor.RtpStreamDescriptorAudioLevelNegotiate(); descriptor.local.RtpMediaDescriptorAudioLevelGet() != 3 {
+		t.Fatalf("audio level of id %d is negotiated", descriptor.local.RtpMediaDescriptorAudioLevelGet())
+	}
+	/* the id of two-byte elements is not sent */
+	descriptor.remote.RtpMediaDescriptorAudioLevelSet(100)
+	if descriptor.RtpStreamDescriptorAudioLevelNegotiate(); descriptor.local.RtpMediaDescriptorAudioLevelGet() != 0 {
+		t.Fatalf("audio level of id %d is negotiated", descriptor.local.RtpMediaDescriptorAudioLevelGet())
+	}
+}
+
+func TestRtpAudioLevelTermination(t *testing.T) {
+	receiver, addr := rtpTestReceiverCreate(t, "PCMU")
+	defer TerminationDestroy(receiver)
+	sender := rtpTestTransmitterCreate(t, addr, 10, "PCMU")
+	defer TerminationDestroy(sender)
+
+	/* the id negotiated is applied by the local media of both */
+	for _, test := range []struct {
+		termination *Termination
+		direction   StreamDirection
+	}{
+		{sender, STREAM_DIRECTION_SEND},
+		{receiver, STREAM_DIRECTION_RECEIVE},
+	} {
+		local := RtpMediaDescriptorAlloc()
+		local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+		local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+		local.RtpMediaDescriptorDirectionSet(test.direction)
+		local.RtpMediaDescriptorAudioLevelSet(2)
+		descriptor := RtpTerminationDescriptorAlloc()
+		descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+		if err := test.termination.TerminationModify(descriptor); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	senderStream := sender.TerminationAudioStreamGet()
+	if err := senderStream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	receiverStream := receiver.TerminationAudioStreamGet()
+	if err := receiverStream.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	levels := []FrameAudioLevel{
+		{Present: true, Level: 20, Voice: true},
+		{Present: true, Level: 64},
+		/* the level of the frame is unknown, no extension is sent */
+		{},
+	}
+	for i, level := range levels {
+		frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU, AudioLevel: level}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{byte(i + 1)}, 80))
+		if err := senderStream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stat RtpRXStat
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ = RtpStreamRXStatGet(receiverStream); stat.ReceivedPackets == 3 {
+			break
+		}
+	}
+	if stat.ReceivedPackets != 3 {
+		t.Fatalf("stat %+v", stat)
+	}
+	/* the octets of the payload are counted only */
+	if txStat, _ := RtpStreamTXStatGet(senderStream); txStat.SentOctets != 3*80 {
+		t.Fatalf("%d octets sent", txStat.SentOctets)
+	}
+	for i, want := range levels {
+		frame := Frame{}
+		if err := receiverStream.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		if data := codecFrameDataGet(&frame.CodecFrame); len(data) != 80 || data[0] != byte(i+1) {
+			t.Fatalf("frame %d of %d bytes", i, len(data))
+		}
+		if frame.AudioLevel != want {
+			t.Fatalf("level of frame %d is %+v, want %+v", i, frame.AudioLevel, want)
+		}
+	}
+}
@@ -1,5 +1,7 @@
 package mpf
 
+import "sync"
+
 /** Used to calculate actual number of received packets (32bit) in
  * case seq number (16bit) wrapped around */
 const RTP_SEQ_MOD = (1 << 16)
@@ -20,6 +22,9 @@ const DEVIATION_THRESHOLD = 4000
 /** This threshold is used to detect a new talkspurt */
 const INTER_TALKSPURT_GAP = 1000 /* msec */
 
+/** Number of packets of new SSRC received in sequence to switch to it */
+const MAX_SSRC_PROBATION = 3
+
 /** History of RTP receiver */
 type RtpRXHistory struct {
 
@@ -60,18 +65,18 @@ type RtpRXPeriodicHistory struct {
 
 /** Reset RTP receiver history */
 func RtpRXHistoryReset(rxHistory *RtpRXHistory) {
-	rxHistory = new(RtpRXHistory)
+	*rxHistory = RtpRXHistory{}
 }
 
 /** Reset RTP receiver periodic history */
 func RtpRXPeriodicHistoryReset(rxPeriodicHistory *RtpRXPeriodicHistory) {
-	rxPeriodicHistory = new(RtpRXPeriodicHistory)
+	*rxPeriodicHistory = RtpRXPeriodicHistory{}
 }
 
 /** RTP receiver */
 type RtpReceiver struct {
 
-	/** Jitter buffer */
+	/** Jitter buffer the frames received are played out by, nil if the receiver is not open */
 	jb *JitterBuffer
 
 	/** RTCP statistics used in RR */
@@ -82,6 +87,24 @@ type RtpReceiver struct {
 	history RtpRXHistory
 	/** RTP periodic history */
 	periodicHistory RtpRXPeriodicHistory
+
+	/** Codec descriptor of the audio received, nil if the receiver is not open */
+	descriptor *CodecDescriptor
+	/** Codec descriptor of the audio received last, kept as the receiver is closed (for the statistics) */
+	lastDescriptor *CodecDescriptor
+	/** Descriptor of the named events received, nil if not negotiated */
+	eventDescriptor *CodecDescriptor
+	/** Frame size in bytes of the audio received (0 if variable) */
+	frameSize int64
+	/** Number of frames in the last packet of audio received */
+	packetFrames int
+	/** History of losses reported in RTCP-XR */
+	burstHistory rtcpXrBurstHistory
+	/** Id of the audio level header extension element received, 0 if not negotiated */
+	audioLevelId byte
+
+	/** Guard of the receiver, packets are received by the goroutine reading the socket */
+	mutex sync.Mutex
 }
 
 /** RTP transmitter */
@@ -112,6 +135,27 @@ type RtpTransmitter struct {
 
 	/** RTCP statistics used in SR */
 	srStat RtcpSRStat
+
+	/** Codec descriptor of the audio sent, nil if the transmitter is not open */
+	descriptor *CodecDescriptor
+	/** Descriptor of the named events sent, nil if not negotiated */
+	eventDescriptor *CodecDescriptor
+	/** Named event is being sent (till the end of it is retransmitted), the audio is not sent meanwhile */
+	eventActive bool
+	/** End of the named event being sent is reported */
+	eventEnded bool
+	/** Statistics of the packets sent */
+	stat RtpTXStat
+	/** Id of the audio level header extension element sent, 0 if not negotiated */
+	audioLevelId byte
+	/** Sum of the power of the levels of the frames packed, and the number of such frames */
+	levelPower  float64
+	levelFrames int
+	/** Any of the frames packed contains voice */
+	levelVoice bool
+
+	/** Guard of the transmitter, packets are sent by the goroutine of the pacer, if any */
+	mutex sync.Mutex
 }
 
 /** Initialize RTP receiver */
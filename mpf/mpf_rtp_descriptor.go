@@ -1,6 +1,12 @@
 package mpf
 
-import "strings"
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
 
 /** MPF media state */
 type MediaState = int
@@ -31,6 +37,18 @@ type RtpMediaDescriptor struct {
 	mid int64
 	/** Position, order in SDP message (0,1,...) */
 	id int64
+	/** RTP and RTCP are multiplexed on the same port (a=rtcp-mux, RFC 5761) */
+	rtcpMux bool
+	/** Crypto attribute (a=crypto, RFC 4568), the key the media is sent with, nil if SRTP is not used */
+	crypto *SrtpCryptoAttrib
+	/** ICE username fragment (a=ice-ufrag, RFC 8839), empty if ICE is not used */
+	iceUfrag string
+	/** ICE password (a=ice-pwd) */
+	icePwd string
+	/** ICE candidates (a=candidate) */
+	candidates []*IceCandidate
+	/** Id of the audio level header extension (a=extmap, RFC 6464), 0 if not used */
+	audioLevelId byte
 }
 
 /** RTP stream descriptor */
@@ -44,6 +62,8 @@ type RtpStreamDescriptor struct {
 	remote *RtpMediaDescriptor
 	/** Settings loaded from config */
 	settings *RtpSettings
+	/** Jitter buffer config to tune the stream with live, the other settings are kept [OPTIONAL] */
+	jbConfig *JbConfig
 }
 
 /** RTP termination descriptor */
@@ -91,6 +111,12 @@ type RtpConfig struct {
 	rtpPortMax uint16
 	/** Current RTP port */
 	rtpPortCur uint16
+	/** Transmit pacer shared by the streams (nil - packets are sent at the tick) */
+	pacer *RtpPacer
+	/** Owners (terminations) of the RTP ports allocated, the RTCP port next to each one is reserved along */
+	rtpPorts map[uint16]*Termination
+	/** Guard of the ports allocated by the sessions running concurrently */
+	mutex sync.Mutex
 }
 
 /** RTP settings */
@@ -104,8 +130,20 @@ type RtpSettings struct {
 	ownPreference bool
 	/** Enable/disable RTCP support */
 	rtcp bool
+	/** Offer/accept multiplexing of RTP and RTCP on the same port (RFC 5761) */
+	rtcpMux bool
+	/** Offer/accept SRTP with the keys exchanged by SDP (RFC 4568) */
+	srtp bool
+	/** Latch onto the source address of the media received (symmetric RTP), e.g. the remote is behind NAT */
+	symmetricRtp bool
+	/** Accept ICE offered by the remote, the server is ICE-lite agent (RFC 8445) */
+	ice bool
+	/** Accept audio level header extension offered by the remote (RFC 6464) */
+	audioLevel bool
 	/** RTCP BYE policy */
 	rtcpByePolicy ByePolicy
+	/** Send RTCP-XR VoIP metrics (RFC 3611) along with the reports */
+	rtcpXr bool
 	/** RTCP report transmission interval */
 	rtcpTXInterval uint16
 	/** RTCP rx resolution (timeout to check for a new RTCP message) */
@@ -125,6 +163,11 @@ func RtpMediaDescriptorInit(media *RtpMediaDescriptor) {
 	CodecListReset(&media.codecList)
 	media.mid = 0
 	media.id = 0
+	media.rtcpMux = false
+	media.iceUfrag = ""
+	media.icePwd = ""
+	media.candidates = nil
+	media.audioLevelId = 0
 }
 
 /** Initialize RTP stream descriptor */
@@ -133,6 +176,7 @@ func RtpStreamDescriptorInit(descriptor *RtpStreamDescriptor) {
 	descriptor.local = nil
 	descriptor.remote = nil
 	descriptor.settings = nil
+	descriptor.jbConfig = nil
 }
 
 /** Initialize RTP termination descriptor */
@@ -150,6 +194,218 @@ func JbConfigInit(jbConfig *JbConfig) {
 	jbConfig.timeSkewDetection = 1
 }
 
+/** Names of jitter buffer params */
+const (
+	JB_PARAM_PLAYOUT_DELAY       = "playout-delay"       /**< initial playout delay in msec */
+	JB_PARAM_MIN_PLAYOUT_DELAY   = "min-playout-delay"   /**< min playout delay in msec */
+	JB_PARAM_MAX_PLAYOUT_DELAY   = "max-playout-delay"   /**< max playout delay in msec */
+	JB_PARAM_ADAPTIVE            = "adaptive"            /**< mode of operation: static - 0, adaptive - 1 */
+	JB_PARAM_TIME_SKEW_DETECTION = "time-skew-detection" /**< enable/disable time skew detection */
+)
+
+/** Allocate JB config */
+func JbConfigAlloc() *JbConfig {
+	jbConfig := &JbConfig{}
+	JbConfigInit(jbConfig)
+	return jbConfig
+}
+
+/** Set JB config param by name */
+func (c *JbConfig) JbConfigParamSet(name, value string) error {
+	value = strings.TrimSpace(value)
+	switch strings.ToLower(name) {
+	case JB_PARAM_PLAYOUT_DELAY, JB_PARAM_MIN_PLAYOUT_DELAY, JB_PARAM_MAX_PLAYOUT_DELAY:
+		delay, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return fmt.Errorf("invalid jitter buffer %s [%s]", name, value)
+		}
+		switch strings.ToLower(name) {
+		case JB_PARAM_PLAYOUT_DELAY:
+			c.initialPlayOutDelay = uint32(delay)
+		case JB_PARAM_MIN_PLAYOUT_DELAY:
+			c.minPlayOutDelay = uint32(delay)
+		default:
+			c.maxPlayOutDelay = uint32(delay)
+		}
+	case JB_PARAM_ADAPTIVE, JB_PARAM_TIME_SKEW_DETECTION:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid jitter buffer %s [%s]", name, value)
+		}
+		var flag byte
+		if enabled {
+			flag = 1
+		}
+		if strings.EqualFold(name, JB_PARAM_ADAPTIVE) {
+			c.adaptive = flag
+		} else {
+			c.timeSkewDetection = flag
+		}
+	default:
+		return fmt.Errorf("unknown jitter buffer param [%s]", name)
+	}
+	return nil
+}
+
+/** Validate JB config, keeping playout delays consistent (min <= initial <= max) */
+func (c *JbConfig) JbConfigValidate() error {
+	if c.maxPlayOutDelay > 0 && c.minPlayOutDelay > c.maxPlayOutDelay {
+		return fmt.Errorf("jitter buffer min playout delay %d exceeds max %d", c.minPlayOutDelay, c.maxPlayOutDelay)
+	}
+	if c.initialPlayOutDelay < c.minPlayOutDelay {
+		c.initialPlayOutDelay = c.minPlayOutDelay
+	}
+	if c.maxPlayOutDelay > 0 && c.initialPlayOutDelay > c.maxPlayOutDelay {
+		c.initialPlayOutDelay = c.maxPlayOutDelay
+	}
+	return nil
+}
+
+/** Get initial, min and max playout delays in msec */
+func (c *JbConfig) JbConfigPlayOutDelayGet() (initial, min, max uint32) {
+	return c.initialPlayOutDelay, c.minPlayOutDelay, c.maxPlayOutDelay
+}
+
+/** Is jitter buffer adaptive */
+func (c *JbConfig) JbConfigAdaptiveGet() bool {
+	return c.adaptive != 0
+}
+
+/** Set JB config of RTP termination descriptor (audio stream settings) to modify termination with */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorJbConfigSet(jbConfig *JbConfig) {
+	config := *jbConfig
+	d.audio.jbConfig = &config
+}
+
+/** Set remote media of RTP termination descriptor (audio stream, e.g. learned from SDP) to modify termination with */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorAudioRemoteSet(media *RtpMediaDescriptor) {
+	d.audio.remote = media
+}
+
+/** Get remote media of RTP termination descriptor (audio stream), nil if not set */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorAudioRemoteGet() *RtpMediaDescriptor {
+	return d.audio.remote
+}
+
+/** Set state of RTP media descriptor, the media of enabled local descriptor is bound to */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorStateSet(state MediaState) {
+	media.state = state
+}
+
+/** Set IP address and port of RTP media descriptor (port 0 - chosen by the system for local media) */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorAddressSet(ip string, port uint16) {
+	media.ip = ip
+	media.port = port
+}
+
+/** Get IP address and port of RTP media descriptor */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorAddressGet() (string, uint16) {
+	return media.ip, media.port
+}
+
+/** Get external (NAT) IP address of RTP media descriptor, the address to advertise in SDP if set */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorExtIpGet() string {
+	return media.extIp
+}
+
+/** Set direction (send/receive) of RTP media descriptor */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorDirectionSet(direction StreamDirection) {
+	media.direction = direction
+}
+
+/** Set packetization time in msec of RTP media descriptor (a=ptime) */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorPtimeSet(ptime uint16) {
+	media.ptime = ptime
+}
+
+/** Get codec list of RTP media descriptor */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorCodecListGet() *CodecList {
+	return &media.codecList
+}
+
+/** Set whether RTP and RTCP of the media are multiplexed (a=rtcp-mux) */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorRtcpMuxSet(rtcpMux bool) {
+	media.rtcpMux = rtcpMux
+}
+
+/** Get whether RTP and RTCP of the media are multiplexed (a=rtcp-mux) */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorRtcpMuxGet() bool {
+	return media.rtcpMux
+}
+
+/** Set crypto attribute of the media (a=crypto), nil if SRTP is not used */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorCryptoSet(crypto *SrtpCryptoAttrib) {
+	media.crypto = crypto
+}
+
+/** Get crypto attribute of the media (a=crypto), nil if SRTP is not used */
+func (media *RtpMediaDescriptor) RtpMediaDescriptorCryptoGet() *SrtpCryptoAttrib {
+	return media.crypto
+}
+
+/**
+ * Set whether RTCP is enabled and the interval of the reports.
+ * @param rtcp whether RTCP is enabled
+ * @param txInterval the interval of the reports in msec (RTCP_TX_INTERVAL_DEFAULT if 0)
+ */
+func (s *RtpSettings) RtpSettingsRtcpSet(rtcp bool, txInterval uint16) {
+	s.rtcp = rtcp
+	s.rtcpTXInterval = txInterval
+}
+
+/** Set RTCP BYE policy, BYE is sent to the remote as the session (or talkspurt received) ends */
+func (s *RtpSettings) RtpSettingsRtcpByePolicySet(policy ByePolicy) {
+	s.rtcpByePolicy = policy
+}
+
+/** Set whether RTCP-XR VoIP metrics are sent along with the reports */
+func (s *RtpSettings) RtpSettingsRtcpXrSet(rtcpXr bool) {
+	s.rtcpXr = rtcpXr
+}
+
+/** Set whether multiplexing of RTP and RTCP is offered/accepted */
+func (s *RtpSettings) RtpSettingsRtcpMuxSet(rtcpMux bool) {
+	s.rtcpMux = rtcpMux
+}
+
+/** Set whether SRTP is offered/accepted */
+func (s *RtpSettings) RtpSettingsSrtpSet(srtp bool) {
+	s.srtp = srtp
+}
+
+/**
+ * Set whether symmetric RTP is used: the media is sent back to the source address of the first valid
+ * packet received instead of the address advertised by the remote, which is private behind NAT.
+ */
+func (s *RtpSettings) RtpSettingsSymmetricRtpSet(symmetricRtp bool) {
+	s.symmetricRtp = symmetricRtp
+}
+
+/** Set whether ICE offered by the remote is accepted, the connectivity checks are responded as ICE-lite agent */
+func (s *RtpSettings) RtpSettingsIceSet(ice bool) {
+	s.ice = ice
+}
+
+/** Set whether audio level header extension offered by the remote is accepted, the levels are sent and received along with the audio */
+func (s *RtpSettings) RtpSettingsAudioLevelSet(audioLevel bool) {
+	s.audioLevel = audioLevel
+}
+
+/** Set settings of RTP termination descriptor (audio stream, e.g. loaded from config) to add/modify termination with */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorAudioSettingsSet(settings *RtpSettings) {
+	d.audio.settings = settings
+}
+
+/** Set local media of RTP termination descriptor (audio stream, e.g. of the SDP answer) to modify termination with */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorAudioLocalSet(media *RtpMediaDescriptor) {
+	d.audio.local = media
+}
+
+/** Get local media of RTP termination descriptor (audio stream), nil if not set */
+func (d *RtpTerminationDescriptor) RtpTerminationDescriptorAudioLocalGet() *RtpMediaDescriptor {
+	return d.audio.local
+}
+
 /** Allocate RTP config */
 func RtpConfigAlloc() *RtpConfig {
 	rtpConfig := RtpConfig{
@@ -158,10 +414,65 @@ func RtpConfigAlloc() *RtpConfig {
 		rtpPortMin: 0,
 		rtpPortMax: 0,
 		rtpPortCur: 0,
+		rtpPorts:   make(map[uint16]*Termination),
 	}
 	return &rtpConfig
 }
 
+/** Set transmit pacer of RTP config, shared by the streams created by the factory */
+func (c *RtpConfig) RtpConfigPacerSet(pacer *RtpPacer) {
+	c.pacer = pacer
+}
+
+/** Get transmit pacer of RTP config */
+func (c *RtpConfig) RtpConfigPacerGet() *RtpPacer {
+	return c.pacer
+}
+
+/**
+ * Set IP addresses of RTP config.
+ * @param ip the local IP address the streams are bound to (all the interfaces if empty)
+ * @param extIp the external (NAT) IP address advertised instead of the local one (none if empty)
+ */
+func (c *RtpConfig) RtpConfigIpSet(ip string, extIp string) error {
+	if ip != "" && net.ParseIP(ip) == nil {
+		return fmt.Errorf("invalid ip address [%s]", ip)
+	}
+	if extIp != "" && net.ParseIP(extIp) == nil {
+		return fmt.Errorf("invalid external ip address [%s]", extIp)
+	}
+	c.ip = ip
+	c.extIp = extIp
+	return nil
+}
+
+/**
+ * Set RTP port range of RTP config, the streams are bound to the even ports of the range (RTCP to the next odd one).
+ * @param min the min RTP port (even)
+ * @param max the max RTP port (exclusive), both 0 - ports are chosen by the system
+ */
+func (c *RtpConfig) RtpConfigPortRangeSet(min, max uint16) error {
+	if min%2 != 0 {
+		return fmt.Errorf("min rtp port %d is not even", min)
+	}
+	if (min != 0 || max != 0) && (min == 0 || int(max) < int(min)+2) {
+		return fmt.Errorf("invalid rtp port range [%d-%d]", min, max)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.rtpPortMin = min
+	c.rtpPortMax = max
+	c.rtpPortCur = min
+	return nil
+}
+
+/** Get RTP port range of RTP config */
+func (c *RtpConfig) RtpConfigPortRangeGet() (uint16, uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.rtpPortMin, c.rtpPortMax
+}
+
 /** Allocate RTP settings */
 func RtpSettingsAlloc() *RtpSettings {
 	rtpSettings := RtpSettings{}
@@ -202,6 +513,12 @@ func RtpMediaDescriptorCopy(srcMedia *RtpMediaDescriptor) *RtpMediaDescriptor {
 		media.codecList = srcMedia.codecList
 		media.mid = srcMedia.mid
 		media.id = srcMedia.id
+		media.rtcpMux = srcMedia.rtcpMux
+		media.crypto = srcMedia.crypto
+		media.iceUfrag = srcMedia.iceUfrag
+		media.icePwd = srcMedia.icePwd
+		media.candidates = append([]*IceCandidate(nil), srcMedia.candidates...)
+		media.audioLevelId = srcMedia.audioLevelId
 	}
 	return media
 }
@@ -236,6 +553,23 @@ func RtpMediaDescriptorsCompare(media1, media2 *RtpMediaDescriptor) bool {
 		return false
 	}
 
+	if media1.rtcpMux != media2.rtcpMux {
+		return false
+	}
+
+	if (media1.crypto == nil) != (media2.crypto == nil) ||
+		(media1.crypto != nil && media1.crypto.SrtpCryptoAttribStrGet() != media2.crypto.SrtpCryptoAttribStrGet()) {
+		return false
+	}
+
+	if media1.iceUfrag != media2.iceUfrag || media1.icePwd != media2.icePwd {
+		return false
+	}
+
+	if media1.audioLevelId != media2.audioLevelId {
+		return false
+	}
+
 	if !CodecListsCompare(&media1.codecList, &media2.codecList) {
 		return false
 	}
@@ -1,5 +1,10 @@
 package mpf
 
+import (
+	"encoding/binary"
+	"fmt"
+)
+
 const RTP_VERSION = 2
 
 type RtpHeader struct {
@@ -37,6 +42,11 @@ type RtpHeader struct {
 	timestamp uint32
 	/** synchronization source */
 	ssrc uint32
+
+	/** header extension, if the extension flag is set */
+	extHeader RtpExtensionHeader
+	/** data of the header extension (elements of RFC 8285, if of its profiles), the padding is kept */
+	extData []byte
 }
 
 /** RTP extension header */
@@ -46,3 +56,172 @@ type RtpExtensionHeader struct {
 	/** length */
 	length uint16
 }
+
+/** Size of RTP header without CSRC list and extension */
+const RTP_HEADER_SIZE = 12
+
+/** Profiles of RTP header extension of one-byte and two-byte elements (RFC 8285) */
+const (
+	RTP_EXTENSION_ONE_BYTE_PROFILE      = 0xBEDE
+	RTP_EXTENSION_TWO_BYTE_PROFILE      = 0x1000
+	RTP_EXTENSION_TWO_BYTE_PROFILE_MASK = 0xFFF0
+)
+
+/** Range of ids and max length of data of one-byte header extension element */
+const (
+	RTP_EXTENSION_ONE_BYTE_ID_MIN  = 1
+	RTP_EXTENSION_ONE_BYTE_ID_MAX  = 14
+	RTP_EXTENSION_ONE_BYTE_LEN_MAX = 16
+)
+
+/**
+ * Parse RTP header of packet.
+ * @param data the packet received
+ * @return the header and the payload, the CSRC list, header extension and padding are skipped
+ * (the elements of the extension are got by RtpHeaderExtensionElementGet())
+ */
+func RtpHeaderParse(data []byte) (*RtpHeader, []byte, error) {
+	if len(data) < RTP_HEADER_SIZE {
+		return nil, nil, fmt.Errorf("rtp packet of %d bytes is too short", len(data))
+	}
+	header := &RtpHeader{
+		Version:   uint32(data[0] >> 6),
+		Padding:   uint32(data[0]>>5) & 0x01,
+		Extension: uint32(data[0]>>4) & 0x01,
+		count:     uint32(data[0]) & 0x0F,
+		Marker:    uint32(data[1] >> 7),
+		Type:      uint32(data[1]) & 0x7F,
+		sequence:  uint32(binary.BigEndian.Uint16(data[2:])),
+		timestamp: binary.BigEndian.Uint32(data[4:]),
+		ssrc:      binary.BigEndian.Uint32(data[8:]),
+	}
+	if header.Version != RTP_VERSION {
+		return nil, nil, fmt.Errorf("invalid rtp version %d", header.Version)
+	}
+	offset := RTP_HEADER_SIZE + 4*int(header.count)
+	if header.Extension == 1 {
+		if len(data) < offset+4 {
+			return nil, nil, fmt.Errorf("rtp header extension is truncated")
+		}
+		header.extHeader.profile = binary.BigEndian.Uint16(data[offset:])
+		header.extHeader.length = binary.BigEndian.Uint16(data[offset+2:])
+		offset += 4
+		if len(data) >= offset+4*int(header.extHeader.length) {
+			header.extData = data[offset : offset+4*int(header.extHeader.length)]
+		}
+		offset += 4 * int(header.extHeader.length)
+	}
+	end := len(data)
+	if header.Padding == 1 && end > 0 {
+		end -= int(data[end-1])
+	}
+	if offset > end {
+		return nil, nil, fmt.Errorf("rtp packet of %d bytes is truncated", len(data))
+	}
+	return header, data[offset:end], nil
+}
+
+/**
+ * Get element of RTP header extension of one-byte or two-byte elements (RFC 8285).
+ * @param id the id of the element (negotiated by a=extmap)
+ * @return the data of the element, nil if none
+ */
+func (header *RtpHeader) RtpHeaderExtensionElementGet(id byte) []byte {
+	if header.Extension != 1 || id == 0 {
+		return nil
+	}
+	twoByte := false
+	switch {
+	case header.extHeader.profile == RTP_EXTENSION_ONE_BYTE_PROFILE:
+	case header.extHeader.profile&RTP_EXTENSION_TWO_BYTE_PROFILE_MASK == RTP_EXTENSION_TWO_BYTE_PROFILE:
+		twoByte = true
+	default:
+		/* the extension is of other profile */
+		return nil
+	}
+	data := header.extData
+	for len(data) > 0 {
+		var elementId byte
+		var length, offset int
+		if twoByte {
+			if data[0] == 0 {
+				/* padding */
+				data = data[1:]
+				continue
+			}
+			if len(data) < 2 {
+				return nil
+			}
+			elementId, length, offset = data[0], int(data[1]), 2
+		} else {
+			elementId = data[0] >> 4
+			if elementId == 0 {
+				/* padding */
+				data = data[1:]
+				continue
+			}
+			if elementId == 15 {
+				/* reserved, the rest of the extension is not processed */
+				return nil
+			}
+			length, offset = int(data[0]&0x0F)+1, 1
+		}
+		if len(data) < offset+length {
+			return nil
+		}
+		if elementId == id {
+			return data[offset : offset+length]
+		}
+		data = data[offset+length:]
+	}
+	return nil
+}
+
+/**
+ * Add element to RTP header extension of one-byte elements (RFC 8285), the extension flag is set.
+ * @param id the id of the element (negotiated by a=extmap)
+ * @param data the data of the element
+ */
+func (header *RtpHeader) RtpHeaderExtensionElementAdd(id byte, data []byte) error {
+	if id < RTP_EXTENSION_ONE_BYTE_ID_MIN || id > RTP_EXTENSION_ONE_BYTE_ID_MAX {
+		return fmt.Errorf("invalid id %d of one-byte header extension element", id)
+	}
+	if len(data) == 0 || len(data) > RTP_EXTENSION_ONE_BYTE_LEN_MAX {
+		return fmt.Errorf("invalid length %d of one-byte header extension element", len(data))
+	}
+	if header.Extension == 1 && header.extHeader.profile != RTP_EXTENSION_ONE_BYTE_PROFILE {
+		return fmt.Errorf("rtp header extension of profile 0x%04x", header.extHeader.profile)
+	}
+	header.Extension = 1
+	header.extHeader.profile = RTP_EXTENSION_ONE_BYTE_PROFILE
+	header.extData = append(header.extData, id<<4|byte(len(data)-1))
+	header.extData = append(header.extData, data...)
+	header.extHeader.length = uint16((len(header.extData) + 3) / 4)
+	return nil
+}
+
+/**
+ * Marshal RTP header (no CSRC list) followed by payload to packet.
+ * The header extension is marshaled if the extension flag is set, its data is padded to 32-bit words.
+ * @param payload the payload of the packet
+ */
+func (header *RtpHeader) RtpHeaderMarshal(payload []byte) []byte {
+	size := RTP_HEADER_SIZE
+	if header.Extension == 1 {
+		size += 4 + 4*int(header.extHeader.length)
+	}
+	packet := make([]byte, size, size+len(payload))
+	packet[0] = byte(RTP_VERSION << 6)
+	packet[1] = byte(header.Marker<<7) | byte(header.Type&0x7F)
+	binary.BigEndian.PutUint16(packet[2:], uint16(header.sequence))
+	binary.BigEndian.PutUint32(packet[4:], header.timestamp)
+	binary.BigEndian.PutUint32(packet[8:], header.ssrc)
+	if header.Extension == 1 {
+		packet[0] |= 0x10
+		binary.BigEndian.PutUint16(packet[RTP_HEADER_SIZE:], header.extHeader.profile)
+		binary.BigEndian.PutUint16(packet[RTP_HEADER_SIZE+2:], header.extHeader.length)
+		/* the rest is zero padding */
+		copy(packet[RTP_HEADER_SIZE+4:], header.extData)
+	}
+	return append(packet, payload...)
+}
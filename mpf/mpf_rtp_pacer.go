@@ -0,0 +1,210 @@
+package mpf
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+/** Prototype of the function sending RTP packet of paced stream */
+type RtpPacerSendFunc func(data []byte) error
+
+/**
+ * Transmit pacer spacing RTP packets of the streams evenly within the frame interval.
+ * Instead of sending the packets of all the streams at the tick boundary in a burst,
+ * each stream is assigned a slot (offset) within the interval its packets are sent at.
+ * Random jitter can be injected to the send time for testing of the peers.
+ */
+type RtpPacer struct {
+	/** Frame (packetization) interval */
+	interval time.Duration
+	/** Max jitter injected (0 - disabled) */
+	jitterMax time.Duration
+	/** Random source of the jitter */
+	rand *rand.Rand
+
+	/** Paced streams, the slot of the stream is its index */
+	streams []*RtpPacerStream
+	/** Packets scheduled, sorted by the due time */
+	queue []*rtpPacerPacket
+
+	mutex sync.Mutex
+}
+
+/** Stream paced by RTP pacer */
+type RtpPacerStream struct {
+	pacer *RtpPacer
+	/** Index of the slot within the interval */
+	slot int
+	send RtpPacerSendFunc
+}
+
+/* Packet scheduled to be sent */
+type rtpPacerPacket struct {
+	due    time.Time
+	stream *RtpPacerStream
+	data   []byte
+}
+
+/**
+ * Create RTP pacer.
+ * @param interval the frame interval of the streams (e.g. ptime)
+ */
+func RtpPacerCreate(interval time.Duration) (*RtpPacer, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid pacer interval %v", interval)
+	}
+	return &RtpPacer{interval: interval}, nil
+}
+
+/**
+ * Set jitter injected to the send time of the packets (for testing).
+ * The packets are never moved out of their frame interval, so that the order is kept.
+ * @param max the max deviation from the slot of the stream, 0 to disable
+ * @param seed the seed of the random source
+ */
+func (p *RtpPacer) RtpPacerJitterSet(max time.Duration, seed int64) error {
+	if max < 0 || max >= p.interval {
+		return fmt.Errorf("invalid pacer jitter %v, interval is %v", max, p.interval)
+	}
+	p.mutex.Lock()
+	p.jitterMax = max
+	p.rand = rand.New(rand.NewSource(seed))
+	p.mutex.Unlock()
+	return nil
+}
+
+/**
+ * Add stream to RTP pacer, the slots of the streams are re-spread over the interval.
+ * @param send the function sending packets of the stream
+ */
+func (p *RtpPacer) RtpPacerStreamAdd(send RtpPacerSendFunc) *RtpPacerStream {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	stream := &RtpPacerStream{pacer: p, slot: len(p.streams), send: send}
+	p.streams = append(p.streams, stream)
+	return stream
+}
+
+/** Remove stream from RTP pacer, the packets scheduled are dropped */
+func (s *RtpPacerStream) RtpPacerStreamRemove() {
+	p := s.pacer
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if s.slot < 0 {
+		return
+	}
+	p.streams = append(p.streams[:s.slot], p.streams[s.slot+1:]...)
+	for i := s.slot; i < len(p.streams); i++ {
+		p.streams[i].slot = i
+	}
+	s.slot = -1
+	queue := p.queue[:0]
+	for _, packet := range p.queue {
+		if packet.stream != s {
+			queue = append(queue, packet)
+		}
+	}
+	p.queue = queue
+}
+
+/* Get offset of the slot within the interval */
+func (p *RtpPacer) slotOffset(slot int) time.Duration {
+	offset := p.interval * time.Duration(slot) / time.Duration(len(p.streams))
+	if p.jitterMax > 0 {
+		offset += time.Duration(p.rand.Int63n(int64(2*p.jitterMax)+1)) - p.jitterMax
+		if offset < 0 {
+			offset = 0
+		}
+		if offset >= p.interval {
+			offset = p.interval - 1
+		}
+	}
+	return offset
+}
+
+/**
+ * Schedule packet of the stream produced at the tick to be sent at the slot of the stream.
+ * @param data the packet to send
+ * @param tick the time of the tick (the start of the frame interval) the packet is produced at
+ */
+func (s *RtpPacerStream) RtpPacerPacketSchedule(data []byte, tick time.Time) error {
+	p := s.pacer
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if s.slot < 0 {
+		return fmt.Errorf("stream is removed from pacer")
+	}
+	packet := &rtpPacerPacket{
+		due:    tick.Add(p.slotOffset(s.slot)),
+		stream: s,
+		data:   data,
+	}
+	/* keep the order of the packets with the same due time */
+	i := sort.Search(len(p.queue), func(i int) bool { return p.queue[i].due.After(packet.due) })
+	p.queue = append(p.queue, nil)
+	copy(p.queue[i+1:], p.queue[i:])
+	p.queue[i] = packet
+	return nil
+}
+
+/**
+ * Send the packets due by now.
+ * @param now the current time
+ * @return the due time of the next packet scheduled (zero if none) and the first error of sending
+ */
+func (p *RtpPacer) RtpPacerProcess(now time.Time) (time.Time, error) {
+	p.mutex.Lock()
+	var due []*rtpPacerPacket
+	for len(p.queue) > 0 && !p.queue[0].due.After(now) {
+		due = append(due, p.queue[0])
+		p.queue = p.queue[1:]
+	}
+	var next time.Time
+	if len(p.queue) > 0 {
+		next = p.queue[0].due
+	}
+	p.mutex.Unlock()
+
+	/* send out of the lock, the send function may take time */
+	var err error
+	for _, packet := range due {
+		if sendErr := packet.stream.send(packet.data); sendErr != nil && err == nil {
+			err = sendErr
+		}
+	}
+	return next, err
+}
+
+/**
+ * Run RTP pacer sending the packets at their due time till stopped.
+ * @param stop the channel closed to stop the pacer
+ * @param poll the max time to wait for newly scheduled packets
+ */
+func (p *RtpPacer) RtpPacerRun(stop <-chan struct{}, poll time.Duration) {
+	timer := time.NewTimer(poll)
+	defer timer.Stop()
+	for {
+		next, _ := p.RtpPacerProcess(time.Now())
+		wait := poll
+		if !next.IsZero() {
+			if until := time.Until(next); until < wait {
+				wait = until
+			}
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-stop:
+			return
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package mpf
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRtpPacer(t *testing.T) {
+	config := RtpConfigAlloc()
+	pacer, err := RtpPacerCreate(20 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config.RtpConfigPacerSet(pacer)
+	factory := RtpTerminationFactoryCreate(config)
+
+	var sent []int
+	var streams []*AudioStream
+	for i := 0; i < 4; i++ {
+		i := i
+		stream := factory.TerminationCreate(nil).TerminationAudioStreamGet()
+		if err := RtpStreamTXPacerAttach(stream, func(data []byte) error {
+			sent = append(sent, i)
+			return nil
+		}); err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, stream)
+	}
+
+	/* all the streams produce packets at the tick, they are sent 5 msec apart */
+	tick := time.Unix(0, 0)
+	for i := len(streams) - 1; i >= 0; i-- {
+		if err := RtpStreamTXPacketSend(streams[i], []byte{byte(i)}, tick, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 4; i++ {
+		next, err := pacer.RtpPacerProcess(tick.Add(time.Duration(i) * 5 * time.Millisecond))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != i+1 || sent[i] != i {
+			t.Fatalf("sent %v at %d msec", sent, i*5)
+		}
+		if i < 3 && next != tick.Add(time.Duration(i+1)*5*time.Millisecond) {
+			t.Fatalf("next packet is due at %v", next.Sub(tick))
+		}
+	}
+
+	/* slots are re-spread when stream is removed */
+	if err := RtpStreamRemove(streams[1]); err != nil {
+		t.Fatal(err)
+	}
+	sent = nil
+	for _, stream := range []*AudioStream{streams[0], streams[2], streams[3]} {
+		RtpStreamTXPacketSend(stream, nil, tick, nil)
+	}
+	if next, _ := pacer.RtpPacerProcess(tick); next.Sub(tick) != 20*time.Millisecond/3 {
+		t.Fatalf("next packet is due at %v", next.Sub(tick))
+	}
+	pacer.RtpPacerProcess(tick.Add(20 * time.Millisecond))
+	if len(sent) != 3 || sent[1] != 2 || sent[2] != 3 {
+		t.Fatalf("sent %v", sent)
+	}
+}
+
+func TestRtpPacerJitter(t *testing.T) {
+	pacer, _ := RtpPacerCreate(20 * time.Millisecond)
+	if err := pacer.RtpPacerJitterSet(20*time.Millisecond, 1); err == nil {
+		t.Fatalf("jitter of the whole interval is accepted")
+	}
+	if err := pacer.RtpPacerJitterSet(4*time.Millisecond, 1); err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	stream := pacer.RtpPacerStreamAdd(func(data []byte) error {
+		count++
+		return nil
+	})
+	pacer.RtpPacerStreamAdd(func(data []byte) error { return nil })
+
+	/* the first slot is at 0 msec, jittered within 4 msec (clamped to the interval) */
+	for i := 0; i < 100; i++ {
+		tick := time.Unix(0, 0).Add(time.Duration(i) * 20 * time.Millisecond)
+		stream.RtpPacerPacketSchedule(nil, tick)
+		next, _ := pacer.RtpPacerProcess(tick.Add(-time.Millisecond))
+		if offset := next.Sub(tick); offset < 0 || offset > 4*time.Millisecond {
+			t.Fatalf("packet is due at %v of the interval", offset)
+		}
+		pacer.RtpPacerProcess(tick.Add(20 * time.Millisecond))
+	}
+	if count != 100 {
+		t.Fatalf("%d packets sent", count)
+	}
+}
@@ -6,6 +6,7 @@ type RtpPayloadType = uint8
 const (
 	RTP_PT_PCMU RtpPayloadType = 0 /**< PCMU           Audio 8kHz 1 */
 	RTP_PT_PCMA RtpPayloadType = 8 /**< PCMA           Audio 8kHz 1 */
+	RTP_PT_G722 RtpPayloadType = 9 /**< G722           Audio 8kHz 1 (16kHz actual) */
 
 	RTP_PT_CN RtpPayloadType = 13 /**< Comfort Noise Audio 8kHz 1 */
 
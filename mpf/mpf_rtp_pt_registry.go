@@ -0,0 +1,139 @@
+package mpf
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+/**
+ * Registry of RTP payload types of a session.
+ * Dynamic payload types (96-127) are mapped to the codec descriptors learned from SDP,
+ * static ones are resolved by the static descriptors of the codec manager.
+ */
+type RtpPayloadTypeRegistry struct {
+	/** Codec manager to resolve static payload types by */
+	codecManager *CodecManager
+	/** Descriptors of dynamic payload types */
+	descriptors [RTP_PT_DYNAMIC_MAX - RTP_PT_DYNAMIC + 1]*CodecDescriptor
+
+	mutex sync.RWMutex
+}
+
+/**
+ * Create payload type registry.
+ * @param codecManager the codec manager to resolve static payload types by (may be nil)
+ */
+func RtpPayloadTypeRegistryCreate(codecManager *CodecManager) *RtpPayloadTypeRegistry {
+	return &RtpPayloadTypeRegistry{codecManager: codecManager}
+}
+
+/* Check whether payload type is dynamic */
+func rtpPayloadTypeDynamicCheck(payloadType RtpPayloadType) bool {
+	return payloadType >= RTP_PT_DYNAMIC && payloadType <= RTP_PT_DYNAMIC_MAX
+}
+
+/**
+ * Register descriptor of dynamic payload type.
+ * The payload type must not be remapped to another codec within the session (RFC 3264).
+ * @param descriptor the descriptor to register
+ */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeRegister(descriptor *CodecDescriptor) error {
+	if descriptor == nil {
+		return fmt.Errorf("codec descriptor is nil")
+	}
+	if !rtpPayloadTypeDynamicCheck(descriptor.PayloadType) {
+		return fmt.Errorf("payload type %d is not dynamic", descriptor.PayloadType)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	index := descriptor.PayloadType - RTP_PT_DYNAMIC
+	if registered := r.descriptors[index]; registered != nil {
+		if !strings.EqualFold(registered.Name, descriptor.Name) ||
+			registered.SamplingRate != descriptor.SamplingRate ||
+			registered.ChannelCount != descriptor.ChannelCount {
+			return fmt.Errorf("payload type %d is mapped to %s/%d already", descriptor.PayloadType, registered.Name, registered.SamplingRate)
+		}
+	}
+	r.descriptors[index] = CodecDescriptorClone(descriptor)
+	return nil
+}
+
+/**
+ * Register dynamic payload types of codec list, static ones are skipped.
+ * @param codecList the codec list (e.g. of the remote SDP) to register
+ */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeRegistryCodecListLoad(codecList *CodecList) error {
+	if codecList == nil || codecList.DescriptorArr == nil {
+		return nil
+	}
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if !rtpPayloadTypeDynamicCheck(descriptor.PayloadType) {
+			continue
+		}
+		if err := r.RtpPayloadTypeRegister(descriptor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/** Unregister dynamic payload type */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeUnregister(payloadType RtpPayloadType) {
+	if !rtpPayloadTypeDynamicCheck(payloadType) {
+		return
+	}
+	r.mutex.Lock()
+	r.descriptors[payloadType-RTP_PT_DYNAMIC] = nil
+	r.mutex.Unlock()
+}
+
+/**
+ * Resolve payload type to codec descriptor.
+ * @param payloadType the payload type of RTP packet
+ * @return the descriptor, nil if the payload type is unknown
+ */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeResolve(payloadType RtpPayloadType) *CodecDescriptor {
+	if rtpPayloadTypeDynamicCheck(payloadType) {
+		r.mutex.RLock()
+		defer r.mutex.RUnlock()
+		return r.descriptors[payloadType-RTP_PT_DYNAMIC]
+	}
+	if payloadType >= RTP_PT_DYNAMIC || r.codecManager == nil {
+		return nil
+	}
+	if codec := r.codecManager.CodecManagerCodecPayloadTypeFind(payloadType); codec != nil {
+		return codec.StaticDescriptor
+	}
+	return nil
+}
+
+/**
+ * Find payload type of codec (matched by name, sampling rate and channel count), used to send.
+ * @param descriptor the descriptor of the codec
+ * @return the payload type, RTP_PT_UNKNOWN if the codec is not registered
+ */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeFind(descriptor *CodecDescriptor) RtpPayloadType {
+	if descriptor == nil {
+		return RTP_PT_UNKNOWN
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	for i, registered := range r.descriptors {
+		if registered != nil && CodecDescriptorsMatch(registered, descriptor) {
+			return RTP_PT_DYNAMIC + RtpPayloadType(i)
+		}
+	}
+	if descriptor.PayloadType < RTP_PT_DYNAMIC && r.codecManager != nil {
+		if codec := r.codecManager.CodecManagerCodecPayloadTypeFind(descriptor.PayloadType); codec != nil {
+			return descriptor.PayloadType
+		}
+	}
+	return RTP_PT_UNKNOWN
+}
+
+/** Check whether payload type is mapped to named events (telephone-event) */
+func (r *RtpPayloadTypeRegistry) RtpPayloadTypeEventCheck(payloadType RtpPayloadType) bool {
+	return EventDescriptorCheck(r.RtpPayloadTypeResolve(payloadType))
+}
@@ -0,0 +1,97 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRtpPayloadTypeRegistry(t *testing.T) {
+	registry := RtpPayloadTypeRegistryCreate(CodecManagerDefaultGet())
+	if err := registry.RtpPayloadTypeRegistryCodecListLoad(codecListCreate(t, "PCMU L16/97/16000 telephone-event/101/8000")); err != nil {
+		t.Fatal(err)
+	}
+	if descriptor := registry.RtpPayloadTypeResolve(97); descriptor == nil || descriptor.Name != "L16" || descriptor.SamplingRate != 16000 {
+		t.Fatalf("unexpected descriptor %+v of 97", descriptor)
+	}
+	if descriptor := registry.RtpPayloadTypeResolve(RTP_PT_PCMU); descriptor == nil || descriptor.Name != "PCMU" {
+		t.Fatalf("unexpected descriptor %+v of PCMU", descriptor)
+	}
+	if !registry.RtpPayloadTypeEventCheck(101) || registry.RtpPayloadTypeEventCheck(97) {
+		t.Fatal("telephone-event is not resolved")
+	}
+	if registry.RtpPayloadTypeResolve(98) != nil {
+		t.Fatal("unknown payload type is resolved")
+	}
+	if pt := registry.RtpPayloadTypeFind(&CodecDescriptor{PayloadType: RTP_PT_DYNAMIC, Name: "l16", SamplingRate: 16000, ChannelCount: 1}); pt != 97 {
+		t.Fatalf("payload type %d of L16/16000, want 97", pt)
+	}
+
+	/* remapping within session is rejected, the same mapping is accepted */
+	if err := registry.RtpPayloadTypeRegister(&CodecDescriptor{PayloadType: 97, Name: "PCMA", SamplingRate: 8000, ChannelCount: 1}); err == nil {
+		t.Fatal("payload type is remapped")
+	}
+	if err := registry.RtpPayloadTypeRegister(&CodecDescriptor{PayloadType: 97, Name: "L16", SamplingRate: 16000, ChannelCount: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.RtpPayloadTypeRegister(&CodecDescriptor{PayloadType: RTP_PT_PCMA, Name: "PCMA"}); err == nil {
+		t.Fatal("static payload type is registered")
+	}
+}
+
+func TestRtpStreamPayloadTypeRegistry(t *testing.T) {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	stream := termination.TerminationAudioStreamGet()
+	registry := RtpPayloadTypeRegistryCreate(CodecManagerDefaultGet())
+	if err := RtpStreamPayloadTypeRegistrySet(stream, registry); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := RtpMediaDescriptorAlloc()
+	*remote.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, "L16/100/8000 telephone-event/99/8000")
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(remote)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if RtpStreamPayloadTypeRegistryGet(stream).RtpPayloadTypeResolve(100) == nil {
+		t.Fatal("remote payload type is not learned")
+	}
+}
+
+func TestDecoderPayloadTypeRegistry(t *testing.T) {
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(&CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payloadType := RTP_PT_PCMU
+	source := AudioStreamCreate(nil, &AudioStreamVTable{ReadFrame: func(stream *AudioStream, frame *Frame) error {
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		frame.PayloadType = payloadType
+		return codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{0xFF}, 80))
+	}}, StreamCapabilitiesCreate(STREAM_DIRECTION_RECEIVE))
+	source.RXDescriptor = codec.StaticDescriptor
+	decoder := DecoderCreate(source, codec)
+	registry := RtpPayloadTypeRegistryCreate(CodecManagerDefaultGet())
+	registry.RtpPayloadTypeRegister(&CodecDescriptor{PayloadType: 101, Name: MPF_EVENT_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1})
+	if err := DecoderPayloadTypeRegistrySet(decoder, registry); err != nil {
+		t.Fatal(err)
+	}
+
+	frame := &Frame{CodecFrame: CodecFrame{Buffer: new(bytes.Buffer), Size: 160}}
+	if err := decoder.AudioStreamFrameRead(frame); err != nil {
+		t.Fatal(err)
+	}
+	if frame.Type&MEDIA_FRAME_TYPE_AUDIO == 0 {
+		t.Fatal("PCMU frame is not decoded")
+	}
+	for _, payloadType = range []RtpPayloadType{101, 120, RTP_PT_PCMA} {
+		frame.Type = MEDIA_FRAME_TYPE_NONE
+		if err := decoder.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type&MEDIA_FRAME_TYPE_AUDIO != 0 {
+			t.Fatalf("frame of payload type %d is decoded", payloadType)
+		}
+	}
+}
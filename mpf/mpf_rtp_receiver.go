@@ -0,0 +1,298 @@
+package mpf
+
+import (
+	"fmt"
+	"time"
+)
+
+/**
+ * Open RTP receiver, the packets of the audio (and of the named events) are accepted since then.
+ * @param descriptor the descriptor of the audio received
+ * @param eventDescriptor the descriptor of the named events received (nil if not negotiated)
+ * @param codec the codec of the audio received, packets are dissected to frames of its size (nil for linear audio)
+ * @param jbConfig the config of the jitter buffer the frames are played out by (default if nil)
+ */
+func (receiver *RtpReceiver) rtpRXOpen(descriptor, eventDescriptor *CodecDescriptor, codec *Codec, jbConfig *JbConfig) error {
+	jb := JitterBufferCreate(jbConfig, descriptor, codec)
+	if jb == nil {
+		return fmt.Errorf("failed to create jitter buffer")
+	}
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.descriptor = descriptor
+	receiver.lastDescriptor = descriptor
+	receiver.eventDescriptor = eventDescriptor
+	receiver.frameSize = jb.frameSize
+	receiver.jb = jb
+	return nil
+}
+
+/** Close RTP receiver, the packets are ignored since then */
+func (receiver *RtpReceiver) rtpRXClose() {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.descriptor = nil
+	receiver.eventDescriptor = nil
+	JitterBufferDestroy(receiver.jb)
+	receiver.jb = nil
+}
+
+/**
+ * Receive RTP packet: the source (SSRC), sequence number and timing are tracked,
+ * the frames of the payload are written to the jitter buffer to be played out.
+ * @param data the packet received
+ * @param now the time the packet is received at
+ * @return true if the packet is valid (of the source received, if open)
+ */
+func (receiver *RtpReceiver) rtpRXPacketReceive(data []byte, now time.Time) bool {
+	header, payload, err := RtpHeaderParse(data)
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if err != nil {
+		receiver.stat.InvalidPackets++
+		return false
+	}
+	if receiver.descriptor == nil {
+		/* the packet is valid, though the receiver is not open */
+		receiver.stat.IgnoredPackets++
+		return true
+	}
+	if !receiver.rtpRXSsrcCheck(header) {
+		receiver.stat.IgnoredPackets++
+		return false
+	}
+	receiver.rtpRXSeqUpdate(header)
+	receiver.stat.ReceivedPackets++
+
+	payloadType := RtpPayloadType(header.Type)
+	if receiver.eventDescriptor != nil && payloadType == receiver.eventDescriptor.PayloadType {
+		/* the timestamp is the start of the event, not the time of the packet, the timing is not tracked */
+		if !receiver.rtpRXEventsWrite(header, payload) {
+			receiver.stat.DiscardedPackets++
+		}
+		return true
+	}
+	if payloadType != receiver.descriptor.PayloadType && payloadType != RTP_PT_CN {
+		receiver.stat.IgnoredPackets++
+		return true
+	}
+	talkspurt := receiver.rtpRXTimeUpdate(header, now)
+	var level FrameAudioLevel
+	if receiver.audioLevelId != 0 {
+		level = RtpAudioLevelParse(header.RtpHeaderExtensionElementGet(receiver.audioLevelId))
+	}
+	if !receiver.rtpRXFramesWrite(header.timestamp, payloadType, payload, talkspurt, level) {
+		receiver.stat.DiscardedPackets++
+	}
+	return true
+}
+
+/* Set id of the audio level header extension element received, the levels are not surfaced if 0 */
+func (receiver *RtpReceiver) rtpRXAudioLevelIdSet(id byte) {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.audioLevelId = id
+}
+
+/* Account packet received as invalid, e.g. failed to be unprotected by SRTP */
+func (receiver *RtpReceiver) rtpRXPacketInvalidate() {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	receiver.stat.InvalidPackets++
+}
+
+/* Check SSRC of packet, the receiver is restarted by the first packet and by the new source after probation */
+func (receiver *RtpReceiver) rtpRXSsrcCheck(header *RtpHeader) bool {
+	if receiver.stat.ReceivedPackets == 0 {
+		receiver.rtpRXRestart(header)
+		return true
+	}
+	if header.ssrc == receiver.rrStat.ssrc {
+		receiver.history.ssrcProbation = 0
+		return true
+	}
+	if header.ssrc != receiver.history.ssrcNew {
+		receiver.history.ssrcNew = header.ssrc
+		receiver.history.ssrcProbation = 0
+	}
+	receiver.history.ssrcProbation++
+	if receiver.history.ssrcProbation < MAX_SSRC_PROBATION {
+		return false
+	}
+	/* the source is switched, e.g. the call is transferred */
+	receiver.rtpRXRestart(header)
+	return true
+}
+
+/* Restart receiver by the packet of the source, the frames buffered are discarded */
+func (receiver *RtpReceiver) rtpRXRestart(header *RtpHeader) {
+	if receiver.stat.ReceivedPackets > 0 {
+		receiver.stat.Restarts++
+	}
+	RtcpRRStatReset(&receiver.rrStat)
+	RtpRXHistoryReset(&receiver.history)
+	RtpRXPeriodicHistoryReset(&receiver.periodicHistory)
+	receiver.rrStat.ssrc = header.ssrc
+	/* the packets received before are of the previous source */
+	receiver.periodicHistory.receivedPrior = receiver.stat.ReceivedPackets
+	receiver.history.seqNumBase = uint16(header.sequence)
+	receiver.history.seqNumMax = uint16(header.sequence)
+	receiver.burstHistory = rtcpXrBurstHistory{}
+	receiver.burstHistory.rtcpXrPacketUpdate(0)
+	JitterBufferRestart(receiver.jb)
+}
+
+/* Update sequence number history by packet (RFC 3550 A.1), the receiver is restarted on a very large jump */
+func (receiver *RtpReceiver) rtpRXSeqUpdate(header *RtpHeader) {
+	seq := uint16(header.sequence)
+	seqDelta := seq - receiver.history.seqNumMax
+	switch {
+	case seqDelta == 0:
+		/* the first packet of the source or duplicate */
+	case seqDelta < MAX_DROPOUT:
+		if seq < receiver.history.seqNumMax {
+			/* sequence number wrapped around */
+			receiver.history.seqCycles += RTP_SEQ_MOD
+		}
+		receiver.stat.LostPackets += uint32(seqDelta) - 1
+		if uint32(seqDelta)-1 > receiver.stat.MaxBurstLost {
+			receiver.stat.MaxBurstLost = uint32(seqDelta) - 1
+		}
+		receiver.history.seqNumMax = seq
+		receiver.burstHistory.rtcpXrPacketUpdate(uint32(seqDelta) - 1)
+	case seqDelta <= RTP_SEQ_MOD-MAX_MISORDER:
+		/* the source is restarted without SSRC change */
+		receiver.rtpRXRestart(header)
+	default:
+		/* misordered packet, counted as lost before */
+		receiver.stat.ReorderedPackets++
+		if receiver.stat.LostPackets > 0 {
+			receiver.stat.LostPackets--
+		}
+	}
+}
+
+/* Update timing history (interarrival jitter) by packet, return true if the packet starts new talkspurt */
+func (receiver *RtpReceiver) rtpRXTimeUpdate(header *RtpHeader, now time.Time) bool {
+	timeNow := now.UnixNano()
+	talkspurt := header.Marker == 1 || receiver.history.timeLast == 0 ||
+		timeNow-receiver.history.timeLast > INTER_TALKSPURT_GAP*int64(time.Millisecond)
+	if !talkspurt {
+		/* RFC 3550 A.8, the jitter is kept scaled by 16 */
+		timeDiff := (timeNow - receiver.history.timeLast) * int64(receiver.descriptor.CodecRtpClockRateGet()) / int64(time.Second)
+		deviation := timeDiff - int64(int32(header.timestamp-receiver.history.tsLast))
+		if deviation < 0 {
+			deviation = -deviation
+		}
+		if deviation > DEVIATION_THRESHOLD {
+			/* timestamps of the source drifted, playout is synchronized again */
+			talkspurt = true
+			JitterBufferRestart(receiver.jb)
+		} else {
+			receiver.rrStat.jitter += uint32(deviation) - ((receiver.rrStat.jitter + 8) >> 4)
+		}
+	}
+	receiver.history.tsLast = header.timestamp
+	receiver.history.timeLast = timeNow
+	return talkspurt
+}
+
+/* Write frames of payload (of the audio level signalled) to the jitter buffer, return false if any frame is discarded */
+func (receiver *RtpReceiver) rtpRXFramesWrite(ts uint32, payloadType RtpPayloadType, payload []byte, talkspurt bool, level FrameAudioLevel) bool {
+	if payloadType == receiver.descriptor.PayloadType && receiver.frameSize > 0 {
+		receiver.packetFrames = len(payload) / int(receiver.frameSize)
+	}
+	var marker byte
+	if talkspurt {
+		/* playout is synchronized by the first frame of talkspurt, the silence before is skipped */
+		marker = 1
+	}
+	return receiver.jb.JitterBufferLevelWrite(payload, payloadType, ts, marker, level) == JB_OK
+}
+
+/* Write named events of payload to the jitter buffer, return false if the payload is invalid or any event is discarded */
+func (receiver *RtpReceiver) rtpRXEventsWrite(header *RtpHeader, payload []byte) bool {
+	events, err := NamedEventsParse(payload)
+	if err != nil {
+		return false
+	}
+	written := true
+	ts := header.timestamp
+	for i := range events {
+		/* the events packed follow each other, the timestamp of the packet is the start of the first one */
+		if receiver.jb.JitterBufferEventWrite(&events[i], ts, byte(header.Marker)) != JB_OK {
+			written = false
+		}
+		ts += events[i].Duration
+	}
+	return written
+}
+
+/**
+ * Read frame due from the jitter buffer, the frame has no audio (type MEDIA_FRAME_TYPE_NONE) if it is missing.
+ * @param frame the frame to read
+ */
+func (receiver *RtpReceiver) rtpRXFrameRead(frame *Frame) error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.descriptor == nil {
+		return nil
+	}
+	return receiver.jb.JitterBufferRead(frame)
+}
+
+/* Update config of the jitter buffer live */
+func (receiver *RtpReceiver) rtpRXJbConfigUpdate(jbConfig *JbConfig) error {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.jb == nil {
+		return nil
+	}
+	return receiver.jb.JitterBufferConfigUpdate(jbConfig)
+}
+
+/* Get statistics of the jitter buffer, zero if the receiver is not open */
+func (receiver *RtpReceiver) rtpRXJbStatGet() JitterBufferStat {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	if receiver.jb == nil {
+		return JitterBufferStat{}
+	}
+	return receiver.jb.JitterBufferStatGet()
+}
+
+/* Make statistics of RTP stream of the source received, the quality is estimated by the round-trip time */
+func (receiver *RtpReceiver) rtpRXStreamStatMake(roundTripTime time.Duration) RtpStreamStat {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	stat := RtpStreamStat{
+		ReceivedPackets:  receiver.stat.ReceivedPackets,
+		LostPackets:      receiver.stat.LostPackets,
+		DiscardedPackets: receiver.stat.DiscardedPackets,
+		ReorderedPackets: receiver.stat.ReorderedPackets,
+		MaxBurstLost:     receiver.stat.MaxBurstLost,
+		RoundTripTime:    roundTripTime,
+	}
+	if receiver.stat.ReceivedPackets == 0 {
+		return stat
+	}
+	if receiver.lastDescriptor != nil {
+		/* the jitter is kept scaled by 16 */
+		stat.Jitter = rtpTsDurationGet(receiver.rrStat.jitter>>4, receiver.lastDescriptor.CodecRtpClockRateGet())
+	}
+	/* the quality is estimated the same way as the one reported by RTCP-XR */
+	metrics := receiver.rtpRXVoipMetricsMake(rtcpXrDurationClamp(float64(roundTripTime / time.Millisecond)))
+	stat.BurstDensity = float64(metrics.BurstDensity) / 256
+	stat.BurstDuration = time.Duration(metrics.BurstDuration) * time.Millisecond
+	stat.RFactor = float64(metrics.RFactor)
+	stat.MosLq = float64(metrics.MosLq) / 10
+	stat.MosCq = float64(metrics.MosCq) / 10
+	return stat
+}
+
+/* Get statistics of receiver */
+func (receiver *RtpReceiver) rtpRXStatGet() RtpRXStat {
+	receiver.mutex.Lock()
+	defer receiver.mutex.Unlock()
+	return receiver.stat
+}
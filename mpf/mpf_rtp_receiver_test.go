@@ -0,0 +1,235 @@
+package mpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+)
+
+/* Create RTP packet of PCMU */
+func rtpTestPacketCreate(ssrc uint32, seq uint16, ts uint32, marker bool, payload []byte) []byte {
+	packet := make([]byte, RTP_HEADER_SIZE, RTP_HEADER_SIZE+len(payload))
+	packet[0] = RTP_VERSION << 6
+	packet[1] = RTP_PT_PCMU
+	if marker {
+		packet[1] |= 0x80
+	}
+	binary.BigEndian.PutUint16(packet[2:], seq)
+	binary.BigEndian.PutUint32(packet[4:], ts)
+	binary.BigEndian.PutUint32(packet[8:], ssrc)
+	return append(packet, payload...)
+}
+
+/* Create RTP termination receiving the codecs (PCMU) on the loopback, return the address bound */
+func rtpTestReceiverCreate(t *testing.T, codecs string) (*Termination, *net.UDPAddr) {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	local.RtpMediaDescriptorDirectionSet(STREAM_DIRECTION_RECEIVE)
+	*local.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, codecs)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	ip, port := local.RtpMediaDescriptorAddressGet()
+	if port == 0 {
+		t.Fatal("port of local media is not set")
+	}
+	return termination, &net.UDPAddr{IP: net.ParseIP(ip), Port: int(port)}
+}
+
+func TestRtpReceiver(t *testing.T) {
+	termination, addr := rtpTestReceiverCreate(t, "PCMU")
+	defer TerminationDestroy(termination)
+	stream := termination.TerminationAudioStreamGet()
+	if stream.AudioStreamDirectionGet() != STREAM_DIRECTION_RECEIVE || stream.RXDescriptor == nil || stream.RXDescriptor.Name != "PCMU" {
+		t.Fatalf("stream is not configured by local media")
+	}
+	codec, err := CodecManagerDefaultGet().CodecManagerCodecGet(stream.RXDescriptor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.AudioStreamRXOpen(codec); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	packets := [][]byte{
+		rtpTestPacketCreate(1, 100, 0, true, bytes.Repeat([]byte{1}, 160)),
+		/* reordered, the second packet carries two frames */
+		rtpTestPacketCreate(1, 102, 320, false, bytes.Repeat([]byte{3}, 160)),
+		rtpTestPacketCreate(1, 101, 160, false, bytes.Repeat([]byte{2}, 160)),
+		/* duplicate */
+		rtpTestPacketCreate(1, 101, 160, false, bytes.Repeat([]byte{2}, 160)),
+		/* other source, in probation */
+		rtpTestPacketCreate(2, 7, 8000, false, bytes.Repeat([]byte{9}, 160)),
+		/* invalid */
+		{0x40, 0x00},
+	}
+	for _, packet := range packets {
+		if _, err := conn.Write(packet); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stat RtpRXStat
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ = RtpStreamRXStatGet(stream); stat.ReceivedPackets+stat.IgnoredPackets+stat.InvalidPackets == 6 {
+			break
+		}
+	}
+	if stat.ReceivedPackets != 4 || stat.IgnoredPackets != 1 || stat.InvalidPackets != 1 || stat.DiscardedPackets != 1 || stat.LostPackets != 0 {
+		t.Fatalf("stat %+v", stat)
+	}
+
+	/* the frames are read in order, half of the second packet is split to frames */
+	for i, want := range []byte{1, 1, 2, 2, 3, 3} {
+		frame := Frame{}
+		if err := stream.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		data := codecFrameDataGet(&frame.CodecFrame)
+		if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == 0 || len(data) != 80 || data[0] != want || frame.PayloadType != RTP_PT_PCMU {
+			t.Fatalf("frame %d of type %d and %d bytes", i, frame.Type, len(data))
+		}
+	}
+	frame := Frame{}
+	if err := stream.AudioStreamFrameRead(&frame); err != nil || frame.Type != MEDIA_FRAME_TYPE_NONE {
+		t.Fatalf("frame of type %d is read on underrun: %v", frame.Type, err)
+	}
+}
+
+func TestRtpReceiverSsrcSwitch(t *testing.T) {
+	receiver := &RtpReceiver{}
+	RtpReceiverInit(receiver)
+	receiver.rtpRXOpen(&CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}, nil, nil, nil)
+	now := time.Now()
+	receiver.rtpRXPacketReceive(rtpTestPacketCreate(1, 10, 0, true, make([]byte, 80)), now)
+	/* the new source is switched to after probation */
+	for i := 0; i < MAX_SSRC_PROBATION; i++ {
+		receiver.rtpRXPacketReceive(rtpTestPacketCreate(2, uint16(500+i), uint32(i*80), i == 0, make([]byte, 80)), now)
+	}
+	/* lost packet is counted by the gap of sequence numbers */
+	receiver.rtpRXPacketReceive(rtpTestPacketCreate(2, 500+MAX_SSRC_PROBATION+1, 80*(MAX_SSRC_PROBATION+1), false, make([]byte, 80)), now.Add(20*time.Millisecond))
+	stat := receiver.rtpRXStatGet()
+	if stat.Restarts != 1 || stat.ReceivedPackets != 3 || stat.IgnoredPackets != MAX_SSRC_PROBATION-1 || stat.LostPackets != 1 {
+		t.Fatalf("stat %+v", stat)
+	}
+}
+
+/* Create RTP packet of named event */
+func rtpTestEventPacketCreate(seq uint16, ts uint32, marker bool, event NamedEventFrame) []byte {
+	header := &RtpHeader{Version: RTP_VERSION, Type: MPF_EVENT_PAYLOAD_TYPE, sequence: uint32(seq), timestamp: ts, ssrc: 1}
+	if marker {
+		header.Marker = 1
+	}
+	return header.RtpHeaderMarshal(NamedEventMarshal(&event))
+}
+
+func TestRtpReceiverEvents(t *testing.T) {
+	receiver := &RtpReceiver{}
+	RtpReceiverInit(receiver)
+	descriptor := &CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}
+	if err := receiver.rtpRXOpen(descriptor, EventDescriptorCreate(8000), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	digit := NamedEventFrame{EventId: 5, Volume: DTMF_EVENT_VOLUME}
+	receiver.rtpRXPacketReceive(rtpTestPacketCreate(1, 1, 0, true, make([]byte, 80)), now)
+	for i, duration := range []uint32{80, 160, 160, 240, 240, 240} {
+		event := digit
+		event.Duration = duration
+		if i >= 3 {
+			event.Edge = 1
+		}
+		/* the update is duplicated, the end is retransmitted */
+		receiver.rtpRXPacketReceive(rtpTestEventPacketCreate(uint16(2+i), 80, i == 0, event), now)
+	}
+	/* the start of short event is lost */
+	short := NamedEventFrame{EventId: 11, Volume: DTMF_EVENT_VOLUME, Edge: 1, Duration: 160}
+	receiver.rtpRXPacketReceive(rtpTestEventPacketCreate(8, 400, false, short), now)
+
+	for i, want := range []struct {
+		frameType FrameType
+		marker    FrameMarker
+		eventId   uint32
+		duration  uint32
+	}{
+		{MEDIA_FRAME_TYPE_AUDIO, MPF_MARKER_NONE, 0, 0},
+		{MEDIA_FRAME_TYPE_EVENT, MPF_MARKER_START_OF_EVENT, 5, 80},
+		{MEDIA_FRAME_TYPE_EVENT, MPF_MARKER_NONE, 5, 160},
+		{MEDIA_FRAME_TYPE_EVENT, MPF_MARKER_END_OF_EVENT, 5, 240},
+		{MEDIA_FRAME_TYPE_NONE, MPF_MARKER_NONE, 0, 0},
+		{MEDIA_FRAME_TYPE_EVENT, MPF_MARKER_START_OF_EVENT, 11, 160},
+		{MEDIA_FRAME_TYPE_EVENT, MPF_MARKER_END_OF_EVENT, 11, 160},
+	} {
+		frame := Frame{}
+		if err := receiver.rtpRXFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != want.frameType || frame.Marker != want.marker || frame.EventFrame.EventId != want.eventId || frame.EventFrame.Duration != want.duration {
+			t.Fatalf("frame %d of type %d, marker %d, event %+v", i, frame.Type, frame.Marker, frame.EventFrame)
+		}
+	}
+	if stat := receiver.rtpRXStatGet(); stat.ReceivedPackets != 8 || stat.DiscardedPackets != 0 {
+		t.Fatalf("stat %+v", stat)
+	}
+}
+
+func TestRtpEventsDtmf(t *testing.T) {
+	receiver, addr := rtpTestReceiverCreate(t, "PCMU telephone-event/101/8000")
+	defer TerminationDestroy(receiver)
+	sender := rtpTestTransmitterCreate(t, addr, 20, "PCMU telephone-event/101/8000")
+	defer TerminationDestroy(sender)
+	senderStream := sender.TerminationAudioStreamGet()
+	receiverStream := receiver.TerminationAudioStreamGet()
+	if senderStream.TXEventDescriptor == nil || receiverStream.RXEventDescriptor == nil {
+		t.Fatal("named events are not negotiated")
+	}
+	if err := senderStream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := receiverStream.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	/* the digit is generated out-of-band, sent and detected by the events received */
+	generator := DtmfGeneratorCreateEx(&AudioStream{RXDescriptor: senderStream.TXDescriptor, RXEventDescriptor: senderStream.TXEventDescriptor},
+		MPF_DTMF_GENERATOR_OUTBAND, 70, 50)
+	if err := generator.DtmfGeneratorEnqueue("7"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		frame := Frame{}
+		generator.DtmfGeneratorPutFrame(&frame)
+		if err := senderStream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sent, _ := RtpStreamTXStatGet(senderStream)
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ := RtpStreamRXStatGet(receiverStream); stat.ReceivedPackets == sent.SentPackets {
+			break
+		}
+	}
+	detector := DtmfDetectorCreateEx(&AudioStream{TXDescriptor: receiverStream.RXDescriptor, TXEventDescriptor: receiverStream.RXEventDescriptor},
+		MPF_DTMF_DETECTOR_OUTBAND, nil)
+	for i := 0; i < 20; i++ {
+		frame := Frame{}
+		if err := receiverStream.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		detector.DtmfDetectorGetFrame(&frame)
+	}
+	if digit := detector.DtmfDetectorDigitGet(); digit != '7' {
+		t.Fatalf("digit %q is detected (%d packets sent)", digit, sent.SentPackets)
+	}
+}
@@ -1,23 +1,29 @@
 package mpf
 
+import "time"
+
 /** RTP receiver statistics */
 type RtpRXStat struct {
 
 	/** number of valid RTP packets received */
-	receivedPackets uint32
+	ReceivedPackets uint32
 	/** number of invalid RTP packets received */
-	invalidPackets uint32
+	InvalidPackets uint32
 
 	/** number of discarded in jitter buffer packets */
-	discardedPackets uint32
+	DiscardedPackets uint32
 	/** number of ignored packets */
-	ignoredPackets uint32
+	IgnoredPackets uint32
 
 	/** number of lost in network packets */
-	lostPackets uint32
+	LostPackets uint32
+	/** number of packets received out of order */
+	ReorderedPackets uint32
+	/** max number of packets lost in a row */
+	MaxBurstLost uint32
 
 	/** number of restarts */
-	restarts byte
+	Restarts byte
 }
 
 /** RTCP statistics used in Sender Report (SR)  */
@@ -57,15 +63,62 @@ type RtcpRRStat struct {
 
 /** Reset RTCP SR statistics */
 func RtcpSRStatReset(srStat *RtcpSRStat) {
-	srStat = new(RtcpSRStat)
+	*srStat = RtcpSRStat{}
 }
 
 /** Reset RTCP RR statistics */
 func RtcpRRStatReset(rrStat *RtcpRRStat) {
-	rrStat = new(RtcpRRStat)
+	*rrStat = RtcpRRStat{}
 }
 
 /** Reset RTP receiver statistics */
 func RtpRXStatReset(rxStat *RtpRXStat) {
-	rxStat = new(RtpRXStat)
+	*rxStat = RtpRXStat{}
+}
+
+/** RTP transmitter statistics */
+type RtpTXStat struct {
+	/** number of RTP packets sent */
+	SentPackets uint32
+	/** number of payload octets (bytes) sent */
+	SentOctets uint32
+	/** number of RTP packets failed to send */
+	FailedPackets uint32
+	/** number of talkspurts started (packets sent with marker bit) */
+	Talkspurts uint32
+}
+
+/** Event id of termination event raised as RTP stream is removed, the final statistics (*RtpStreamStat) are surfaced */
+const MPF_RTP_STAT_EVENT = 3
+
+/** Cumulative statistics of RTP stream along with the quality estimated by the E-model (ITU-T G.107) */
+type RtpStreamStat struct {
+	/** Number of RTP packets sent */
+	SentPackets uint32
+	/** Number of payload octets (bytes) sent */
+	SentOctets uint32
+	/** Number of valid RTP packets received */
+	ReceivedPackets uint32
+	/** Number of packets lost in network */
+	LostPackets uint32
+	/** Number of packets discarded by the jitter buffer (too late, too early, duplicate) */
+	DiscardedPackets uint32
+	/** Number of packets received out of order */
+	ReorderedPackets uint32
+	/** Interarrival jitter of the packets received */
+	Jitter time.Duration
+	/** Max number of packets lost in a row */
+	MaxBurstLost uint32
+	/** Fraction of packets lost/discarded within bursts (0..1) */
+	BurstDensity float64
+	/** Mean duration of bursts */
+	BurstDuration time.Duration
+	/** Round-trip time measured by RTCP, 0 if not measured */
+	RoundTripTime time.Duration
+	/** R factor of the conversation, 0 if nothing is received */
+	RFactor float64
+	/** Listening quality MOS (1..4.5), 0 if nothing is received */
+	MosLq float64
+	/** Conversational quality MOS (1..4.5), the delay is taken into account, 0 if nothing is received */
+	MosCq float64
 }
@@ -1,5 +1,70 @@
 package mpf
 
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+/** Max size of RTP packet received */
+const RTP_PACKET_SIZE_MAX = 4096
+
+/** RTP stream */
+type RtpStream struct {
+	/** Audio stream base */
+	base *AudioStream
+	/** Config of RTP factory */
+	config *RtpConfig
+	/** Settings of the stream */
+	settings *RtpSettings
+	/** Payload type registry of the session */
+	registry *RtpPayloadTypeRegistry
+	/** RTP and RTCP are multiplexed on the RTP port (negotiated rtcp-mux) */
+	rtcpMux bool
+	/** Stream of the transmit pacer (nil if not paced) */
+	pacer *RtpPacerStream
+	/** RTP receiver */
+	receiver RtpReceiver
+	/** RTP transmitter */
+	transmitter RtpTransmitter
+	/** Socket bound to the local media address, nil if not bound yet */
+	socket *net.UDPConn
+	/** RTP port of the socket allocated from the range of the config, 0 if chosen otherwise */
+	rtpPort uint16
+	/** Local media descriptor applied */
+	local *RtpMediaDescriptor
+	/** Remote media descriptor applied */
+	remote *RtpMediaDescriptor
+	/** Address of the remote media the packets are sent to, nil if none */
+	remoteAddr *net.UDPAddr
+	/** The address of the remote media is latched onto the source of the packets received (symmetric RTP) */
+	latched bool
+	/** SRTP context protecting the packets sent by the key of local media, nil if SRTP is not used */
+	srtpTX *SrtpContext
+	/** SRTP context unprotecting the packets received by the key of remote media, nil if SRTP is not used */
+	srtpRX *SrtpContext
+	/** RTCP session, started along with the socket if RTCP is enabled by the settings */
+	rtcp rtcpSession
+	/** Statistics snapshotted as the stream is removed, nil if not removed yet */
+	statFinal *RtpStreamStat
+
+	/** Guard of settings modified while the stream is running */
+	mutex sync.Mutex
+}
+
+var rtpStreamVTable = AudioStreamVTable{
+	Destroy:   rtpStreamDestroy,
+	OpenRX:    rtpStreamRXOpen,
+	CloseRX:   rtpStreamRXClose,
+	ReadFrame: rtpStreamFrameRead,
+
+	OpenTX:     rtpStreamTXOpen,
+	CloseTX:    rtpStreamTXClose,
+	WriteFrame: rtpStreamFrameWrite,
+}
+
 /**
  * Create RTP stream.
  * @param termination the back pointer to hold
@@ -8,7 +73,23 @@ package mpf
  * @param pool the pool to allocate memory from
  */
 func RtpStreamCreate(termination *Termination, config *RtpConfig, settings *RtpSettings) *AudioStream {
-	return nil
+	if settings == nil {
+		settings = RtpSettingsAlloc()
+	}
+	rtpStream := &RtpStream{
+		config:   config,
+		settings: settings,
+	}
+	RtpReceiverInit(&rtpStream.receiver)
+	RtpTransmitterInit(&rtpStream.transmitter)
+	rtpStream.transmitter.rtpTXSourceInit()
+	capabilities := StreamCapabilitiesCreate(STREAM_DIRECTION_DUPLEX)
+	rtpStream.base = AudioStreamCreate(rtpStream, &rtpStreamVTable, capabilities)
+	if rtpStream.base == nil {
+		return nil
+	}
+	rtpStream.base.termination = termination
+	return rtpStream.base
 }
 
 /**
@@ -24,14 +105,658 @@ func RtpStreamAdd(stream *AudioStream) error {
  * @param stream RTP stream to subtract
  */
 func RtpStreamRemove(stream *AudioStream) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	if rtpStream.pacer != nil {
+		rtpStream.pacer.RtpPacerStreamRemove()
+		rtpStream.pacer = nil
+	}
+	var stat *RtpStreamStat
+	if rtpStream.socket != nil {
+		/* the statistics of the session are kept as the stream is gone */
+		statFinal := rtpStream.rtpStreamStatMake()
+		stat = &statFinal
+		rtpStream.statFinal = stat
+	}
+	err := rtpStream.socketClose()
+	rtpStream.mutex.Unlock()
+
+	if stat != nil {
+		if termination := stream.termination; termination != nil && termination.EventHandler != nil {
+			_ = termination.EventHandler(termination, MPF_RTP_STAT_EVENT, stat)
+		}
+	}
+	return err
+}
+
+/* Destroy RTP stream, the socket is closed */
+func rtpStreamDestroy(stream *AudioStream) error {
+	return RtpStreamRemove(stream)
+}
+
+/* Open receiver of RTP stream with the codec of the RX descriptor negotiated */
+func rtpStreamRXOpen(stream *AudioStream, codec *Codec) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	if stream.RXDescriptor == nil {
+		return fmt.Errorf("no codec negotiated to receive")
+	}
+	rtpStream.mutex.Lock()
+	jbConfig := rtpStream.settings.jbConfig
+	rtpStream.mutex.Unlock()
+	return rtpStream.receiver.rtpRXOpen(stream.RXDescriptor, stream.RXEventDescriptor, codec, &jbConfig)
+}
+
+/* Close receiver of RTP stream */
+func rtpStreamRXClose(stream *AudioStream) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	rtpStream.receiver.rtpRXClose()
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if rtpStream.settings.rtcpByePolicy == RTCP_BYE_PER_TALKSPURT {
+		/* the input is over, the remote may end the session */
+		return rtpStream.rtcpByeSend("talkspurt ended")
+	}
+	return nil
+}
+
+/* Read frame received by RTP stream */
+func rtpStreamFrameRead(stream *AudioStream, frame *Frame) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	return rtpStream.receiver.rtpRXFrameRead(frame)
+}
+
+/* Open transmitter of RTP stream with the codec of the TX descriptor negotiated, packed up to the ptime requested by the remote */
+func rtpStreamTXOpen(stream *AudioStream, codec *Codec) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	if stream.TXDescriptor == nil {
+		return fmt.Errorf("no codec negotiated to send")
+	}
+	rtpStream.mutex.Lock()
+	ptime := rtpStream.settings.ptime
+	if rtpStream.remote != nil && rtpStream.remote.ptime != 0 {
+		ptime = rtpStream.remote.ptime
+	}
+	rtpStream.mutex.Unlock()
+	rtpStream.transmitter.rtpTXOpen(stream.TXDescriptor, stream.TXEventDescriptor, ptime)
 	return nil
 }
 
+/* Close transmitter of RTP stream */
+func rtpStreamTXClose(stream *AudioStream) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	rtpStream.transmitter.rtpTXClose()
+	return nil
+}
+
+/* Write frame to RTP stream, the packets made are sent to the remote media (paced, if attached to pacer) */
+func rtpStreamFrameWrite(stream *AudioStream, frame *Frame) error {
+	rtpStream := stream.Obj.(*RtpStream)
+	packets := rtpStream.transmitter.rtpTXFrameWrite(frame, rtpStream.cnFrameCheck(frame))
+	if len(packets) == 0 {
+		return nil
+	}
+	tick := time.Now()
+	for _, packet := range packets {
+		if err := RtpStreamTXPacketSend(stream, packet, tick, rtpStream.packetSend); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Check whether frame to send is comfort noise (of other payload type than the audio, resolved by the registry if set) */
+func (rtpStream *RtpStream) cnFrameCheck(frame *Frame) bool {
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO || rtpStream.base.TXDescriptor == nil ||
+		frame.PayloadType == rtpStream.base.TXDescriptor.PayloadType {
+		return false
+	}
+	if frame.PayloadType == RTP_PT_CN {
+		return true
+	}
+	rtpStream.mutex.Lock()
+	registry := rtpStream.registry
+	rtpStream.mutex.Unlock()
+	if registry == nil {
+		return false
+	}
+	descriptor := registry.RtpPayloadTypeResolve(frame.PayloadType)
+	return descriptor != nil && strings.EqualFold(descriptor.Name, CN_CODEC_NAME)
+}
+
+/* Send packet to the remote media, dropped if there is no remote media (yet) */
+func (rtpStream *RtpStream) packetSend(data []byte) error {
+	rtpStream.mutex.Lock()
+	socket, remoteAddr, srtpTX := rtpStream.socket, rtpStream.remoteAddr, rtpStream.srtpTX
+	rtpStream.mutex.Unlock()
+	if socket == nil || remoteAddr == nil {
+		return nil
+	}
+	packet := data
+	if srtpTX != nil {
+		var err error
+		if packet, err = srtpTX.SrtpProtect(data); err != nil {
+			rtpStream.transmitter.rtpTXPacketSent(data, err)
+			return err
+		}
+	}
+	_, err := socket.WriteToUDP(packet, remoteAddr)
+	rtpStream.transmitter.rtpTXPacketSent(data, err)
+	return err
+}
+
+/* Apply remote media: the packets are sent to its address since then (e.g. updated by re-INVITE) */
+func (rtpStream *RtpStream) remoteMediaApply(remote *RtpMediaDescriptor) error {
+	var remoteAddr *net.UDPAddr
+	if remote.ip != "" && remote.port != 0 {
+		ip := net.ParseIP(remote.ip)
+		if ip == nil {
+			return fmt.Errorf("invalid remote ip address [%s]", remote.ip)
+		}
+		if !ip.IsUnspecified() {
+			/* c=0.0.0.0 puts the media on hold (RFC 3264), nothing is sent */
+			remoteAddr = &net.UDPAddr{IP: ip, Port: int(remote.port)}
+		}
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	srtpRX, err := srtpContextUpdate(rtpStream.srtpRX, rtpStream.remote, remote)
+	if err != nil {
+		return err
+	}
+	rtpStream.srtpRX = srtpRX
+	rtpStream.remote = remote
+	rtpStream.remoteAddr = remoteAddr
+	/* the remote media is latched onto again, e.g. the call is transferred */
+	rtpStream.latched = false
+	rtpStream.rtcp.remoteAddr = nil
+	return nil
+}
+
+/*
+ * Latch the remote media onto the source address of the valid packet received (symmetric RTP),
+ * the packets are sent back to the address since then. Nothing is latched before the remote media
+ * is applied, nor while it is on hold.
+ */
+func (rtpStream *RtpStream) remoteLatch(addr *net.UDPAddr, rtcp bool) {
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if !rtpStream.settings.symmetricRtp || rtpStream.remoteAddr == nil {
+		return
+	}
+	if rtcp {
+		if rtpStream.rtcp.remoteAddr == nil {
+			rtpStream.rtcp.remoteAddr = addr
+		}
+		return
+	}
+	if !rtpStream.latched {
+		rtpStream.remoteAddr = addr
+		rtpStream.latched = true
+	}
+}
+
+/**
+ * Get address of the remote media RTP is sent to, the source address latched onto if symmetric RTP is used.
+ * @param stream RTP stream to get address of
+ * @return the address, nil if there is no remote media
+ */
+func RtpStreamRemoteAddrGet(stream *AudioStream) (*net.UDPAddr, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return nil, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.remoteAddr, nil
+}
+
+/* Apply local media: the socket is (re)bound to the address, the codec negotiated and the direction are set */
+func (rtpStream *RtpStream) localMediaApply(local *RtpMediaDescriptor) error {
+	ip := local.ip
+	if ip == "" && rtpStream.config != nil {
+		ip = rtpStream.config.ip
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	srtpTX, err := srtpContextUpdate(rtpStream.srtpTX, rtpStream.local, local)
+	if err != nil {
+		return err
+	}
+	rtpStream.srtpTX = srtpTX
+	if rtpStream.socket != nil {
+		addr := rtpStream.socket.LocalAddr().(*net.UDPAddr)
+		if (local.port != 0 && int(local.port) != addr.Port) || (ip != "" && !addr.IP.Equal(net.ParseIP(ip))) {
+			if err := rtpStream.socketClose(); err != nil {
+				return err
+			}
+		}
+	}
+	if rtpStream.socket == nil {
+		if err := rtpStream.socketBind(ip, local.port); err != nil {
+			return err
+		}
+	}
+	/* the port allocated (or chosen by the system) is advertised */
+	local.port = uint16(rtpStream.socket.LocalAddr().(*net.UDPAddr).Port)
+	if local.ip == "" {
+		local.ip = ip
+	}
+	if local.extIp == "" && rtpStream.config != nil {
+		local.extIp = rtpStream.config.extIp
+	}
+	rtpStream.iceCandidatesGather(local, local.ip, local.port)
+	rtpStream.local = local
+	/* the audio levels are sent and received by the id of the header extension negotiated */
+	rtpStream.receiver.rtpRXAudioLevelIdSet(local.audioLevelId)
+	rtpStream.transmitter.rtpTXAudioLevelIdSet(local.audioLevelId)
+	if rtpStream.settings.rtcp {
+		if err := rtpStream.rtcpStart(); err != nil {
+			return err
+		}
+	}
+
+	if local.codecList.DescriptorArr != nil {
+		if descriptor := rtpCodecListPrimaryGet(&local.codecList); descriptor != nil {
+			rtpStream.base.RXDescriptor = descriptor
+			rtpStream.base.TXDescriptor = descriptor
+		}
+		/* named events (out-of-band DTMF) are received and sent if negotiated */
+		eventDescriptor := rtpCodecListEventGet(&local.codecList)
+		rtpStream.base.RXEventDescriptor = eventDescriptor
+		rtpStream.base.TXEventDescriptor = eventDescriptor
+	}
+	return rtpStream.base.AudioStreamDirectionSet(local.direction)
+}
+
+/* Update SRTP context by the crypto attribute of the media applied, the context is kept while the key is not changed */
+func srtpContextUpdate(context *SrtpContext, prev *RtpMediaDescriptor, media *RtpMediaDescriptor) (*SrtpContext, error) {
+	if media.crypto == nil {
+		return nil, nil
+	}
+	if context != nil && prev != nil && prev.crypto != nil &&
+		prev.crypto.SrtpCryptoAttribStrGet() == media.crypto.SrtpCryptoAttribStrGet() {
+		return context, nil
+	}
+	return SrtpContextCreate(media.crypto)
+}
+
+/* Get primary (audio) descriptor of codec list, the first enabled non event one if not set */
+func rtpCodecListPrimaryGet(codecList *CodecList) *CodecDescriptor {
+	if codecList.PrimaryDescriptor != nil {
+		return codecList.PrimaryDescriptor
+	}
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if descriptor.Enabled && !EventDescriptorCheck(descriptor) {
+			return descriptor
+		}
+	}
+	return nil
+}
+
+/* Get named event descriptor of codec list, the first enabled one if not set */
+func rtpCodecListEventGet(codecList *CodecList) *CodecDescriptor {
+	if codecList.EventDescriptor != nil {
+		return codecList.EventDescriptor
+	}
+	for i := 0; i < codecList.DescriptorArr.Stack.Size(); i++ {
+		descriptor := codecList.CodecListDescriptorGet(i)
+		if descriptor.Enabled && EventDescriptorCheck(descriptor) {
+			return descriptor
+		}
+	}
+	return nil
+}
+
+/* Bind UDP socket to local address, the port is chosen by the system if 0 */
+func rtpSocketBind(ip string, port uint16) (*net.UDPConn, error) {
+	addr := &net.UDPAddr{Port: int(port)}
+	if ip != "" {
+		if addr.IP = net.ParseIP(ip); addr.IP == nil {
+			return nil, fmt.Errorf("invalid ip address [%s]", ip)
+		}
+	}
+	return net.ListenUDP("udp", addr)
+}
+
+/* Bind socket of RTP stream (the stream must be locked), the port is allocated from the range of the config if not set */
+func (rtpStream *RtpStream) socketBind(ip string, port uint16) error {
+	var socket *net.UDPConn
+	var err error
+	if port == 0 && rtpStream.config != nil && rtpStream.config.rtpPortRangeCheck() {
+		socket, err = rtpStream.config.rtpPortBind(ip, rtpStream.base.termination)
+		if err == nil {
+			rtpStream.rtpPort = uint16(socket.LocalAddr().(*net.UDPAddr).Port)
+		}
+	} else {
+		socket, err = rtpSocketBind(ip, port)
+	}
+	if err != nil {
+		return err
+	}
+	rtpStream.socket = socket
+	go rtpStream.socketRun(socket)
+	return nil
+}
+
+/* Receive packets on socket until it is closed */
+func (rtpStream *RtpStream) socketRun(socket *net.UDPConn) {
+	buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+	for {
+		n, addr, err := socket.ReadFromUDP(buffer)
+		if err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Temporary() {
+				continue
+			}
+			/* the socket is closed */
+			return
+		}
+		if StunMessageCheck(buffer[:n]) {
+			rtpStream.iceCheckHandle(socket, buffer[:n], addr, ICE_COMPONENT_RTP)
+			continue
+		}
+		if RtpStreamRtcpPacketCheck(rtpStream.base, buffer[:n]) {
+			rtpStream.rtcpPacketReceive(buffer[:n], time.Now())
+			continue
+		}
+		packet := buffer[:n]
+		if srtpRX := rtpStream.srtpRXGet(); srtpRX != nil {
+			if packet, err = srtpRX.SrtpUnprotect(packet); err != nil {
+				rtpStream.receiver.rtpRXPacketInvalidate()
+				continue
+			}
+		}
+		if rtpStream.receiver.rtpRXPacketReceive(packet, time.Now()) {
+			rtpStream.remoteLatch(addr, false)
+		}
+	}
+}
+
+/* Get SRTP context of the packets received, nil if SRTP is not used */
+func (rtpStream *RtpStream) srtpRXGet() *SrtpContext {
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.srtpRX
+}
+
+/* Close socket of RTP stream, if bound (the stream must be locked) */
+func (rtpStream *RtpStream) socketClose() error {
+	if rtpStream.socket == nil {
+		return nil
+	}
+	if rtpStream.settings.rtcpByePolicy != RTCP_BYE_DISABLE {
+		_ = rtpStream.rtcpByeSend("session ended")
+	}
+	rtpStream.rtcpStop()
+	err := rtpStream.socket.Close()
+	rtpStream.socket = nil
+	if rtpStream.rtpPort != 0 {
+		/* the port is released after the socket is closed, ready to be bound by another stream */
+		rtpStream.config.rtpPortRelease(rtpStream.rtpPort)
+		rtpStream.rtpPort = 0
+	}
+	return err
+}
+
 /**
  * Modify RTP stream.
  * @param stream RTP stream to modify
  * @param descriptor the descriptor to modify stream according
  */
 func RtpStreamModify(stream *AudioStream, descriptor *RtpStreamDescriptor) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	if descriptor.settings != nil {
+		/* RTCP settings are applied as the socket is bound */
+		rtpStream.mutex.Lock()
+		rtpStream.settings.rtcp = descriptor.settings.rtcp
+		rtpStream.settings.rtcpTXInterval = descriptor.settings.rtcpTXInterval
+		rtpStream.settings.rtcpByePolicy = descriptor.settings.rtcpByePolicy
+		rtpStream.settings.rtcpXr = descriptor.settings.rtcpXr
+		rtpStream.settings.symmetricRtp = descriptor.settings.symmetricRtp
+		rtpStream.mutex.Unlock()
+	}
+	if descriptor.local != nil {
+		rtpStream.mutex.Lock()
+		rtpStream.rtcpMux = descriptor.local.rtcpMux
+		rtpStream.mutex.Unlock()
+		if descriptor.local.state == MPF_MEDIA_ENABLED {
+			if err := rtpStream.localMediaApply(descriptor.local); err != nil {
+				return err
+			}
+		}
+	}
+	if descriptor.remote != nil {
+		if err := rtpStream.remoteMediaApply(descriptor.remote); err != nil {
+			return err
+		}
+		/* dynamic payload types of the remote SDP are learned per session */
+		rtpStream.mutex.Lock()
+		registry := rtpStream.registry
+		rtpStream.mutex.Unlock()
+		if registry != nil {
+			if err := registry.RtpPayloadTypeRegistryCodecListLoad(&descriptor.remote.codecList); err != nil {
+				return err
+			}
+		}
+	}
+	if descriptor.settings != nil {
+		if err := rtpStream.jbConfigApply(descriptor.settings.jbConfig); err != nil {
+			return err
+		}
+	}
+	if descriptor.jbConfig != nil {
+		/* tuned apart from the settings, RTCP and latching of the stream are kept */
+		return rtpStream.jbConfigApply(*descriptor.jbConfig)
+	}
+	return nil
+}
+
+/* Apply jitter buffer config, the jitter buffer is tuned live, without restarting the stream */
+func (rtpStream *RtpStream) jbConfigApply(jbConfig JbConfig) error {
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if err := jbConfig.JbConfigValidate(); err != nil {
+		return err
+	}
+	if err := rtpStream.receiver.rtpRXJbConfigUpdate(&jbConfig); err != nil {
+		return err
+	}
+	rtpStream.settings.jbConfig = jbConfig
+	return nil
+}
+
+/**
+ * Get jitter buffer config of RTP stream.
+ * @param stream RTP stream to get config of
+ */
+func RtpStreamJbConfigGet(stream *AudioStream) *JbConfig {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return nil
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	jbConfig := rtpStream.settings.jbConfig
+	return &jbConfig
+}
+
+/**
+ * Set payload type registry of the session to RTP stream.
+ * @param stream RTP stream to set registry to
+ * @param registry the registry, dynamic payload types of the remote media are registered to
+ */
+func RtpStreamPayloadTypeRegistrySet(stream *AudioStream, registry *RtpPayloadTypeRegistry) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	rtpStream.registry = registry
+	rtpStream.mutex.Unlock()
 	return nil
 }
+
+/**
+ * Get payload type registry of RTP stream.
+ * @param stream RTP stream to get registry of
+ */
+func RtpStreamPayloadTypeRegistryGet(stream *AudioStream) *RtpPayloadTypeRegistry {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return nil
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.registry
+}
+
+/**
+ * Get whether RTP and RTCP of RTP stream are multiplexed on the RTP port.
+ * @param stream RTP stream to check
+ */
+func RtpStreamRtcpMuxGet(stream *AudioStream) bool {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return false
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.rtcpMux
+}
+
+/**
+ * Demultiplex packet received on the RTP port of RTP stream.
+ * @param stream RTP stream the packet is received by
+ * @param data the packet received
+ * @return true if the packet is RTCP one (possible with rtcp-mux only), false if RTP one
+ */
+func RtpStreamRtcpPacketCheck(stream *AudioStream, data []byte) bool {
+	return RtpStreamRtcpMuxGet(stream) && RtcpMuxPacketCheck(data)
+}
+
+/**
+ * Attach transmitter of RTP stream to the pacer of the factory config.
+ * @param stream RTP stream to attach
+ * @param send the function sending RTP packets of the stream
+ */
+func RtpStreamTXPacerAttach(stream *AudioStream, send RtpPacerSendFunc) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	if send == nil {
+		return fmt.Errorf("send function is nil")
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if rtpStream.pacer != nil {
+		return fmt.Errorf("RTP stream is attached to pacer already")
+	}
+	if rtpStream.config == nil || rtpStream.config.pacer == nil {
+		return fmt.Errorf("no pacer configured")
+	}
+	rtpStream.pacer = rtpStream.config.pacer.RtpPacerStreamAdd(send)
+	return nil
+}
+
+/**
+ * Send RTP packet of the stream produced at the tick, paced if the stream is attached to pacer.
+ * @param stream RTP stream to send packet of
+ * @param data the packet to send
+ * @param tick the time of the tick the packet is produced at
+ * @param send the function sending the packet immediately (not paced)
+ */
+func RtpStreamTXPacketSend(stream *AudioStream, data []byte, tick time.Time, send RtpPacerSendFunc) error {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	pacer := rtpStream.pacer
+	rtpStream.mutex.Unlock()
+	if pacer != nil {
+		return pacer.RtpPacerPacketSchedule(data, tick)
+	}
+	return send(data)
+}
+
+/**
+ * Get statistics of the receiver of RTP stream.
+ * @param stream RTP stream to get statistics of
+ */
+func RtpStreamRXStatGet(stream *AudioStream) (RtpRXStat, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return RtpRXStat{}, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	return rtpStream.receiver.rtpRXStatGet(), nil
+}
+
+/**
+ * Get statistics of the jitter buffer of RTP stream, zero if the receiver is not open.
+ * @param stream RTP stream to get statistics of
+ */
+func RtpStreamJbStatGet(stream *AudioStream) (JitterBufferStat, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return JitterBufferStat{}, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	return rtpStream.receiver.rtpRXJbStatGet(), nil
+}
+
+/* Make cumulative statistics of RTP stream */
+func (rtpStream *RtpStream) rtpStreamStatMake() RtpStreamStat {
+	rtpStream.rtcp.mutex.Lock()
+	roundTripTime := rtpStream.rtcp.stats.RoundTripTime
+	rtpStream.rtcp.mutex.Unlock()
+	stat := rtpStream.receiver.rtpRXStreamStatMake(roundTripTime)
+	txStat := rtpStream.transmitter.rtpTXStatGet()
+	stat.SentPackets = txStat.SentPackets
+	stat.SentOctets = txStat.SentOctets
+	return stat
+}
+
+/**
+ * Get cumulative statistics of RTP stream, live while the stream is running.
+ * @param stream RTP stream to get statistics of
+ */
+func RtpStreamStatGet(stream *AudioStream) (RtpStreamStat, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return RtpStreamStat{}, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	return rtpStream.rtpStreamStatMake(), nil
+}
+
+/**
+ * Get statistics of RTP stream snapshotted as the stream (termination) is removed.
+ * @param stream RTP stream to get statistics of
+ * @return the statistics, nil if the stream is not removed yet
+ */
+func RtpStreamFinalStatGet(stream *AudioStream) (*RtpStreamStat, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return nil, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	return rtpStream.statFinal, nil
+}
+
+/**
+ * Get statistics of the transmitter of RTP stream.
+ * @param stream RTP stream to get statistics of
+ */
+func RtpStreamTXStatGet(stream *AudioStream) (RtpTXStat, error) {
+	rtpStream, ok := stream.Obj.(*RtpStream)
+	if !ok {
+		return RtpTXStat{}, fmt.Errorf("AudioStream.Obj is not *RtpStream")
+	}
+	return rtpStream.transmitter.rtpTXStatGet(), nil
+}
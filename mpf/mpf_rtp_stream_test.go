@@ -0,0 +1,334 @@
+package mpf
+
+import (
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRtpTerminationJbModify(t *testing.T) {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	stream := termination.TerminationAudioStreamGet()
+
+	jbConfig := RtpStreamJbConfigGet(stream)
+	for name, value := range map[string]string{
+		JB_PARAM_PLAYOUT_DELAY:     "60",
+		JB_PARAM_MAX_PLAYOUT_DELAY: "200",
+		JB_PARAM_ADAPTIVE:          "true",
+	} {
+		if err := jbConfig.JbConfigParamSet(name, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := jbConfig.JbConfigParamSet("unknown", "1"); err == nil {
+		t.Fatalf("unknown param is accepted")
+	}
+
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorJbConfigSet(jbConfig)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	initial, _, max := RtpStreamJbConfigGet(stream).JbConfigPlayOutDelayGet()
+	if initial != 60 || max != 200 || !RtpStreamJbConfigGet(stream).JbConfigAdaptiveGet() {
+		t.Fatalf("jitter buffer is not tuned: initial %d max %d", initial, max)
+	}
+
+	/* initial delay is clamped by max one */
+	jbConfig.JbConfigParamSet(JB_PARAM_PLAYOUT_DELAY, "500")
+	descriptor.RtpTerminationDescriptorJbConfigSet(jbConfig)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if initial, _, _ = RtpStreamJbConfigGet(stream).JbConfigPlayOutDelayGet(); initial != 200 {
+		t.Fatalf("initial delay %d, want 200", initial)
+	}
+}
+
+func TestRtpTerminationJbModifyKeepsRtcp(t *testing.T) {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	stream := termination.TerminationAudioStreamGet()
+	settings := RtpSettingsAlloc()
+	settings.RtpSettingsRtcpSet(true, 5000)
+	settings.RtpSettingsRtcpByePolicySet(RTCP_BYE_PER_SESSION)
+	settings.RtpSettingsRtcpXrSet(true)
+	settings.RtpSettingsSymmetricRtpSet(true)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioSettingsSet(settings)
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	/* jitter buffer is tuned by SET-PARAMS on the live stream */
+	jbConfig := RtpStreamJbConfigGet(stream)
+	jbConfig.JbConfigParamSet(JB_PARAM_PLAYOUT_DELAY, "80")
+	descriptor = RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorJbConfigSet(jbConfig)
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	if initial, _, _ := RtpStreamJbConfigGet(stream).JbConfigPlayOutDelayGet(); initial != 80 {
+		t.Fatalf("initial delay %d, want 80", initial)
+	}
+	rtpStream := stream.Obj.(*RtpStream)
+	rtpStream.mutex.Lock()
+	defer rtpStream.mutex.Unlock()
+	if !rtpStream.settings.rtcp || rtpStream.settings.rtcpTXInterval != 5000 || rtpStream.settings.rtcpByePolicy != RTCP_BYE_PER_SESSION ||
+		!rtpStream.settings.rtcpXr || !rtpStream.settings.symmetricRtp {
+		t.Fatalf("RTCP and latching settings are changed by jitter buffer tuning: %+v", rtpStream.settings)
+	}
+}
+
+/* Add RTP termination created by factory with local media of the port 0, return the port allocated */
+func rtpTestPortAllocate(factory *TerminationFactory) (*Termination, uint16, error) {
+	termination := factory.TerminationCreate(nil)
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		return termination, 0, err
+	}
+	_, port := local.RtpMediaDescriptorAddressGet()
+	return termination, port, nil
+}
+
+func TestRtpTerminationPortRange(t *testing.T) {
+	config := RtpConfigAlloc()
+	if err := config.RtpConfigPortRangeSet(47001, 47010); err == nil {
+		t.Fatal("odd min port is accepted")
+	}
+	if err := config.RtpConfigIpSet("127.0.0.1", "203.0.113.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.RtpConfigPortRangeSet(47000, 47006); err != nil {
+		t.Fatal(err)
+	}
+	factory := RtpTerminationFactoryCreate(config)
+
+	/* the port in use by another process is skipped */
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 47000})
+	if err != nil {
+		t.Skip(err)
+	}
+	var terminations []*Termination
+	for _, want := range []uint16{47002, 47004} {
+		termination, port, err := rtpTestPortAllocate(factory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if port != want || config.RtpConfigPortOwnerGet(port) != termination {
+			t.Fatalf("port %d is allocated, want %d", port, want)
+		}
+		terminations = append(terminations, termination)
+	}
+	if ip := terminations[0].TerminationAudioStreamGet().Obj.(*RtpStream).local.RtpMediaDescriptorExtIpGet(); ip != "203.0.113.1" {
+		t.Fatalf("external ip [%s] is advertised", ip)
+	}
+	if termination, _, err := rtpTestPortAllocate(factory); err == nil {
+		TerminationDestroy(termination)
+		t.Fatal("port is allocated out of the range exhausted")
+	}
+	conn.Close()
+
+	/* the ports are released as the terminations are destroyed */
+	for _, termination := range terminations {
+		if err := TerminationDestroy(termination); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if config.RtpConfigPortsAllocatedGet() != 0 || config.RtpConfigPortOwnerGet(47002) != nil {
+		t.Fatalf("%d ports are allocated", config.RtpConfigPortsAllocatedGet())
+	}
+	termination, port, err := rtpTestPortAllocate(factory)
+	if err != nil || port != 47000 {
+		t.Fatalf("port %d is allocated: %v", port, err)
+	}
+	TerminationDestroy(termination)
+}
+
+func TestRtpTerminationPortRangeConcurrent(t *testing.T) {
+	config := RtpConfigAlloc()
+	if err := config.RtpConfigPortRangeSet(47100, 47140); err != nil {
+		t.Fatal(err)
+	}
+	factory := RtpTerminationFactoryCreate(config)
+	/* the terminations are kept until all of them are added, the ports allocated are distinct */
+	terminations := make(chan *Termination, 16)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			termination, _, err := rtpTestPortAllocate(factory)
+			if err != nil {
+				t.Error(err)
+			}
+			terminations <- termination
+		}()
+	}
+	wg.Wait()
+	close(terminations)
+	allocated := map[uint16]bool{}
+	for termination := range terminations {
+		_, port := termination.TerminationAudioStreamGet().Obj.(*RtpStream).local.RtpMediaDescriptorAddressGet()
+		if port%2 != 0 || port < 47100 || port >= 47140 || allocated[port] || config.RtpConfigPortOwnerGet(port) != termination {
+			t.Errorf("port %d is allocated", port)
+		}
+		allocated[port] = true
+		TerminationDestroy(termination)
+	}
+	if config.RtpConfigPortsAllocatedGet() != 0 {
+		t.Fatalf("%d ports are not released", config.RtpConfigPortsAllocatedGet())
+	}
+}
+
+func TestRtpSymmetric(t *testing.T) {
+	/* the remote is behind NAT: the address advertised is not the one the media comes from */
+	advertised := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}
+	peers := make([]*net.UDPConn, 2)
+	for i := range peers {
+		socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer socket.Close()
+		peers[i] = socket
+	}
+	rtpPeer, rtcpPeer := peers[0], peers[1]
+
+	termination := RtpTerminationFactoryCreate(RtpConfigAlloc()).TerminationCreate(nil)
+	defer TerminationDestroy(termination)
+	settings := RtpSettingsAlloc()
+	settings.RtpSettingsRtcpSet(true, 60000)
+	settings.RtpSettingsSymmetricRtpSet(true)
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	local.RtpMediaDescriptorDirectionSet(STREAM_DIRECTION_SEND)
+	*local.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, "PCMU")
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioSettingsSet(settings)
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(rtpTestRemoteCreate(advertised, 10))
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	_, port := local.RtpMediaDescriptorAddressGet()
+	stream := termination.TerminationAudioStreamGet()
+	if err := stream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	remoteWait := func(want *net.UDPAddr) {
+		var addr *net.UDPAddr
+		for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+			if addr, _ = RtpStreamRemoteAddrGet(stream); addr.String() == want.String() {
+				return
+			}
+		}
+		t.Fatalf("remote address %v, want %v", addr, want)
+	}
+
+	/* the invalid packet is not latched onto, the valid one is */
+	localAddr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: int(port)}
+	if _, err := rtpPeer.WriteToUDP([]byte{0, 1, 2}, localAddr); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	remoteWait(advertised)
+	if _, err := rtpPeer.WriteToUDP(rtpTestPacketCreate(1, 1, 0, true, make([]byte, 80)), localAddr); err != nil {
+		t.Fatal(err)
+	}
+	remoteWait(rtpPeer.LocalAddr().(*net.UDPAddr))
+	frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU}
+	codecFrameDataSet(&frame.CodecFrame, make([]byte, 80))
+	if err := stream.AudioStreamFrameWrite(&frame); err != nil {
+		t.Fatal(err)
+	}
+	if header, _ := rtpTestPacketReceive(t, rtpPeer); RtpPayloadType(header.Type) != RTP_PT_PCMU {
+		t.Fatalf("payload type %d", header.Type)
+	}
+
+	/* RTCP is latched onto the source of the reports, not the port next to RTP */
+	if _, err := rtcpPeer.WriteToUDP(RtcpRRAppend(nil, 1, nil), &net.UDPAddr{IP: localAddr.IP, Port: localAddr.Port + 1}); err != nil {
+		t.Fatal(err)
+	}
+	rtcpTestReportWait(t, stream, 1)
+	if err := RtpStreamRtcpReportSend(stream); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+	rtcpPeer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := rtcpPeer.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := RtcpCompoundParse(buffer[:n], func(header *RtcpHeader, body []byte) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	/* the remote media applied again (e.g. re-INVITE) is latched onto again */
+	descriptor = RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(rtpTestRemoteCreate(advertised, 10))
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	remoteWait(advertised)
+}
+
+func TestRtpStreamStat(t *testing.T) {
+	termination, addr := rtpTestReceiverCreate(t, "PCMU")
+	stream := termination.TerminationAudioStreamGet()
+	if err := stream.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	if stat, _ := RtpStreamStatGet(stream); stat.ReceivedPackets != 0 || stat.MosLq != 0 {
+		t.Fatalf("stat %+v before anything is received", stat)
+	}
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	/* 4 and 9..11 are lost, 5 is reordered */
+	for _, seq := range []uint16{1, 2, 3, 6, 5, 7, 8, 12} {
+		if _, err := conn.Write(rtpTestPacketCreate(1, seq, uint32(seq)*80, seq == 1, make([]byte, 80))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var stat RtpStreamStat
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ = RtpStreamStatGet(stream); stat.ReceivedPackets == 8 {
+			break
+		}
+	}
+	if stat.ReceivedPackets != 8 || stat.LostPackets != 4 || stat.ReorderedPackets != 1 || stat.MaxBurstLost != 3 || stat.SentPackets != 0 {
+		t.Fatalf("stat %+v", stat)
+	}
+	if stat.MosLq < 1 || stat.MosLq >= 4 || stat.MosCq > stat.MosLq || stat.RFactor == 0 {
+		t.Fatalf("quality of stat %+v", stat)
+	}
+
+	/* the statistics are snapshotted as the termination is destroyed */
+	if final, _ := RtpStreamFinalStatGet(stream); final != nil {
+		t.Fatal("final stat of the stream running")
+	}
+	var event *RtpStreamStat
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == MPF_RTP_STAT_EVENT {
+			event = descriptor.(*RtpStreamStat)
+		}
+		return nil
+	}
+	if err := TerminationDestroy(termination); err != nil {
+		t.Fatal(err)
+	}
+	final, _ := RtpStreamFinalStatGet(stream)
+	if final == nil || event != final || final.ReceivedPackets != 8 || final.MosLq != stat.MosLq {
+		t.Fatalf("final stat %+v, stat %+v", final, stat)
+	}
+}
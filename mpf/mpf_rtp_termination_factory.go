@@ -1,8 +1,124 @@
 package mpf
 
+import (
+	"fmt"
+	"net"
+)
+
+var rtpTerminationVTable = TerminationVTable{
+	Destroy:  nil,
+	Add:      RtpTerminationAdd,
+	Modify:   RtpTerminationModify,
+	Subtract: RtpTerminationSubtract,
+}
+
+/** Add RTP termination */
+func RtpTerminationAdd(termination *Termination, descriptor interface{}) error {
+	if termination.audioStream == nil {
+		return nil
+	}
+	if rtpDescriptor, ok := descriptor.(*RtpTerminationDescriptor); ok && rtpDescriptor != nil {
+		if err := RtpStreamModify(termination.audioStream, &rtpDescriptor.audio); err != nil {
+			return err
+		}
+	}
+	return RtpStreamAdd(termination.audioStream)
+}
+
+/** Modify RTP termination */
+func RtpTerminationModify(termination *Termination, descriptor interface{}) error {
+	rtpDescriptor, ok := descriptor.(*RtpTerminationDescriptor)
+	if !ok || rtpDescriptor == nil {
+		return fmt.Errorf("descriptor is not *RtpTerminationDescriptor")
+	}
+	if termination.audioStream == nil {
+		return nil
+	}
+	return RtpStreamModify(termination.audioStream, &rtpDescriptor.audio)
+}
+
+/** Subtract RTP termination */
+func RtpTerminationSubtract(termination *Termination) error {
+	if termination.audioStream == nil {
+		return nil
+	}
+	return RtpStreamRemove(termination.audioStream)
+}
+
 /**
  * Create RTP termination factory.
+ * @param rtpConfig the config of the streams created: the IP address to bind to and
+ *                  the port range the ports are allocated from (chosen by the system if not set)
  */
 func RtpTerminationFactoryCreate(rtpConfig *RtpConfig) *TerminationFactory {
-	return nil
+	if rtpConfig == nil {
+		return nil
+	}
+	return &TerminationFactory{
+		CreateTermination: func(factory *TerminationFactory, obj interface{}) *Termination {
+			termination := TerminationBaseCreate(factory, obj, &rtpTerminationVTable, nil, nil)
+			termination.audioStream = RtpStreamCreate(termination, rtpConfig, nil)
+			return termination
+		},
+		AssignEngine: nil,
+	}
+}
+
+/* Bind socket of RTP port allocated from the range of config to the termination, the ports in use are skipped */
+func (c *RtpConfig) rtpPortBind(ip string, owner *Termination) (*net.UDPConn, error) {
+	if ip != "" && net.ParseIP(ip) == nil {
+		return nil, fmt.Errorf("invalid ip address [%s]", ip)
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i := 0; i < int(c.rtpPortMax-c.rtpPortMin)/2; i++ {
+		port := c.rtpPortCur
+		c.rtpPortCur += 2
+		if int(c.rtpPortCur)+2 > int(c.rtpPortMax) || c.rtpPortCur < c.rtpPortMin {
+			c.rtpPortCur = c.rtpPortMin
+		}
+		if _, ok := c.rtpPorts[port]; ok {
+			continue
+		}
+		/* the port may be in use by another process as well */
+		socket, err := rtpSocketBind(ip, port)
+		if err != nil {
+			continue
+		}
+		c.rtpPorts[port] = owner
+		return socket, nil
+	}
+	return nil, fmt.Errorf("no rtp port available in range [%d-%d]", c.rtpPortMin, c.rtpPortMax)
+}
+
+/* Release RTP port allocated from the range of config */
+func (c *RtpConfig) rtpPortRelease(port uint16) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.rtpPorts, port)
+}
+
+/* Check whether the ports of RTP config are allocated from the range */
+func (c *RtpConfig) rtpPortRangeCheck() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.rtpPortMax > c.rtpPortMin
+}
+
+/**
+ * Get termination owning RTP port allocated from the range of RTP config.
+ * @param port the RTP port to get owner of
+ * @return the termination, nil if the port is not allocated
+ */
+func (c *RtpConfig) RtpConfigPortOwnerGet(port uint16) *Termination {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.rtpPorts[port]
+}
+
+/** Get number of RTP ports (pairs of RTP/RTCP ports) allocated from the range of RTP config */
+func (c *RtpConfig) RtpConfigPortsAllocatedGet() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.rtpPorts)
 }
@@ -0,0 +1,230 @@
+package mpf
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+/* Initialize source of transmitter: SSRC, the initial sequence number and timestamp are random (RFC 3550) */
+func (transmitter *RtpTransmitter) rtpTXSourceInit() {
+	var random [10]byte
+	if _, err := rand.Read(random[:]); err != nil {
+		return
+	}
+	transmitter.srStat.ssrc = binary.BigEndian.Uint32(random[0:])
+	transmitter.timestamp = binary.BigEndian.Uint32(random[4:])
+	transmitter.lastSeqNum = binary.BigEndian.Uint16(random[8:])
+}
+
+/**
+ * Open RTP transmitter, the sequence numbers and timestamps continue those of the previous opening.
+ * @param descriptor the descriptor of the audio sent, the payload type of which is sent
+ * @param eventDescriptor the descriptor of the named events sent (nil if not negotiated, the events are not sent)
+ * @param ptime the packetization time in msec, frames are packed up to it (a frame per packet if 0)
+ */
+func (transmitter *RtpTransmitter) rtpTXOpen(descriptor, eventDescriptor *CodecDescriptor, ptime uint16) {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	transmitter.descriptor = descriptor
+	transmitter.eventDescriptor = eventDescriptor
+	transmitter.eventActive = false
+	transmitter.ptime = ptime
+	transmitter.packetFrames = 1
+	if frameDuration := descriptor.CodecFrameDurationGet(); int64(ptime) > frameDuration {
+		transmitter.packetFrames = uint16(int64(ptime) / frameDuration)
+	}
+	transmitter.currentFrames = 0
+	transmitter.samplesPerFrame = uint32(descriptor.CodecFrameTimestampCalculate())
+	transmitter.inactivity = 1
+	transmitter.packetData = transmitter.packetData[:0]
+	transmitter.packetSize = 0
+	transmitter.rtpTXLevelReset()
+}
+
+/** Close RTP transmitter, the frames packed and not sent yet are dropped */
+func (transmitter *RtpTransmitter) rtpTXClose() {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	transmitter.descriptor = nil
+	transmitter.eventDescriptor = nil
+	transmitter.currentFrames = 0
+	transmitter.packetData = nil
+	transmitter.packetSize = 0
+	transmitter.rtpTXLevelReset()
+}
+
+/* Set id of the audio level header extension element sent, the levels are not sent if 0 */
+func (transmitter *RtpTransmitter) rtpTXAudioLevelIdSet(id byte) {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	transmitter.audioLevelId = id
+}
+
+/* Reset the levels of the frames packed */
+func (transmitter *RtpTransmitter) rtpTXLevelReset() {
+	transmitter.levelPower = 0
+	transmitter.levelFrames = 0
+	transmitter.levelVoice = false
+}
+
+/* Account the level of the frame packed, the level of the packet is the one of the mean power of the frames */
+func (transmitter *RtpTransmitter) rtpTXLevelAdd(level FrameAudioLevel) {
+	if !level.Present {
+		return
+	}
+	transmitter.levelPower += rtpAudioLevelPowerGet(level.Level)
+	transmitter.levelFrames++
+	transmitter.levelVoice = transmitter.levelVoice || level.Voice
+}
+
+/**
+ * Write frame to RTP transmitter: frames of audio are packed up to the packetization time,
+ * the packet starting talkspurt (after frames of no audio) is marked. Named events take precedence
+ * over audio, the audio is not sent while the event is sent (@see rtpTXEventWrite()).
+ * @param frame the frame to write
+ * @param cn the frame is comfort noise (RFC 3389) of the payload type of the frame, sent in the packet of its own
+ * @return the packets to send, if any
+ */
+func (transmitter *RtpTransmitter) rtpTXFrameWrite(frame *Frame, cn bool) [][]byte {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	if transmitter.descriptor == nil {
+		return nil
+	}
+	if (frame.Type&MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT && transmitter.eventDescriptor != nil {
+		return transmitter.rtpTXEventWrite(frame)
+	}
+	if transmitter.eventActive {
+		if !transmitter.eventEnded {
+			/* the event is still being sent, the audio is dropped */
+			transmitter.timestamp += transmitter.samplesPerFrame
+			return nil
+		}
+		/* the audio after the event starts talkspurt */
+		transmitter.eventActive = false
+		transmitter.inactivity = 1
+	}
+	var packets [][]byte
+	if (frame.Type&MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO && !cn {
+		transmitter.packetData = append(transmitter.packetData, codecFrameDataGet(&frame.CodecFrame)...)
+		transmitter.packetSize = int64(len(transmitter.packetData))
+		transmitter.rtpTXLevelAdd(frame.AudioLevel)
+		transmitter.currentFrames++
+		transmitter.timestamp += transmitter.samplesPerFrame
+		if transmitter.currentFrames >= transmitter.packetFrames {
+			packets = append(packets, transmitter.rtpTXPacketMake(transmitter.descriptor.PayloadType))
+		}
+		return packets
+	}
+
+	/* silence, the frames packed are sent and the next packet of audio starts talkspurt */
+	if transmitter.currentFrames > 0 {
+		packets = append(packets, transmitter.rtpTXPacketMake(transmitter.descriptor.PayloadType))
+	}
+	if cn {
+		/* comfort noise is not talkspurt, it is not marked */
+		transmitter.inactivity = 0
+		transmitter.packetData = append(transmitter.packetData, codecFrameDataGet(&frame.CodecFrame)...)
+		transmitter.currentFrames = 1
+		transmitter.timestamp += transmitter.samplesPerFrame
+		packets = append(packets, transmitter.rtpTXPacketMake(frame.PayloadType))
+	} else {
+		transmitter.timestamp += transmitter.samplesPerFrame
+	}
+	transmitter.inactivity = 1
+	return packets
+}
+
+/*
+ * Write frame of named event (RFC4733): the packets of the event carry the timestamp of its start and the duration
+ * accumulated, the first one is marked, the end is retransmitted as written (the duration is not advanced).
+ * The audio packed is sent before the event.
+ */
+func (transmitter *RtpTransmitter) rtpTXEventWrite(frame *Frame) [][]byte {
+	var packets [][]byte
+	if transmitter.currentFrames > 0 {
+		packets = append(packets, transmitter.rtpTXPacketMake(transmitter.descriptor.PayloadType))
+	}
+	var marker uint32
+	if !transmitter.eventActive || frame.Marker == MPF_MARKER_START_OF_EVENT || frame.Marker == MPF_MARKER_NEW_SEGMENT {
+		transmitter.timestampBase = transmitter.timestamp
+		transmitter.eventActive = true
+		if frame.Marker != MPF_MARKER_NEW_SEGMENT {
+			/* the next segment of long-lasting event is not marked (RFC4733 2.5.1.3) */
+			marker = 1
+		}
+	}
+	transmitter.eventEnded = frame.EventFrame.Edge != 0
+	transmitter.lastSeqNum++
+	header := &RtpHeader{
+		Version:   RTP_VERSION,
+		Marker:    marker,
+		Type:      uint32(transmitter.eventDescriptor.PayloadType),
+		sequence:  uint32(transmitter.lastSeqNum),
+		timestamp: transmitter.timestampBase,
+		ssrc:      transmitter.srStat.ssrc,
+	}
+	packets = append(packets, header.RtpHeaderMarshal(NamedEventMarshal(&frame.EventFrame)))
+	transmitter.timestamp += transmitter.samplesPerFrame
+	return packets
+}
+
+/*
+ * Make packet of the frames packed, the timestamp of the packet is the one of the first frame.
+ * The audio level of the frames is sent in the header extension, if negotiated and known.
+ */
+func (transmitter *RtpTransmitter) rtpTXPacketMake(payloadType RtpPayloadType) []byte {
+	transmitter.lastSeqNum++
+	header := &RtpHeader{
+		Version:   RTP_VERSION,
+		Marker:    uint32(transmitter.inactivity),
+		Type:      uint32(payloadType),
+		sequence:  uint32(transmitter.lastSeqNum),
+		timestamp: transmitter.timestamp - uint32(transmitter.currentFrames)*transmitter.samplesPerFrame,
+		ssrc:      transmitter.srStat.ssrc,
+	}
+	if transmitter.audioLevelId != 0 && transmitter.levelFrames > 0 {
+		level := FrameAudioLevel{
+			Present: true,
+			Level:   rtpAudioLevelOfPower(transmitter.levelPower / float64(transmitter.levelFrames)),
+			Voice:   transmitter.levelVoice,
+		}
+		_ = header.RtpHeaderExtensionElementAdd(transmitter.audioLevelId, RtpAudioLevelMarshal(level))
+	}
+	transmitter.rtpTXLevelReset()
+	packet := header.RtpHeaderMarshal(transmitter.packetData)
+	transmitter.inactivity = 0
+	transmitter.currentFrames = 0
+	transmitter.packetData = transmitter.packetData[:0]
+	transmitter.packetSize = 0
+	return packet
+}
+
+/* Account packet sent (or failed to send) in statistics of transmitter */
+func (transmitter *RtpTransmitter) rtpTXPacketSent(packet []byte, err error) {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	if err != nil {
+		transmitter.stat.FailedPackets++
+		return
+	}
+	transmitter.stat.SentPackets++
+	/* the octets of the payload only, the header extension is not counted */
+	headerLength, err := rtpHeaderLengthGet(packet)
+	if err != nil {
+		headerLength = RTP_HEADER_SIZE
+	}
+	transmitter.stat.SentOctets += uint32(len(packet) - headerLength)
+	if (packet[1] & 0x80) != 0 {
+		transmitter.stat.Talkspurts++
+	}
+	transmitter.srStat.sentPackets = transmitter.stat.SentPackets
+	transmitter.srStat.sentOctets = transmitter.stat.SentOctets
+}
+
+/* Get statistics of transmitter */
+func (transmitter *RtpTransmitter) rtpTXStatGet() RtpTXStat {
+	transmitter.mutex.Lock()
+	defer transmitter.mutex.Unlock()
+	return transmitter.stat
+}
@@ -0,0 +1,161 @@
+package mpf
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+/* Create RTP termination sending the codecs (PCMU) to the remote address, packed to ptime */
+func rtpTestTransmitterCreate(t *testing.T, remoteAddr *net.UDPAddr, ptime uint16, codecs string) *Termination {
+	factory := RtpTerminationFactoryCreate(RtpConfigAlloc())
+	termination := factory.TerminationCreate(nil)
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	local.RtpMediaDescriptorDirectionSet(STREAM_DIRECTION_SEND)
+	*local.RtpMediaDescriptorCodecListGet() = *codecListCreate(t, codecs)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(rtpTestRemoteCreate(remoteAddr, ptime))
+	if err := termination.TerminationAdd(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	return termination
+}
+
+/* Create remote media of the address */
+func rtpTestRemoteCreate(addr *net.UDPAddr, ptime uint16) *RtpMediaDescriptor {
+	remote := RtpMediaDescriptorAlloc()
+	remote.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	remote.RtpMediaDescriptorAddressSet(addr.IP.String(), uint16(addr.Port))
+	remote.RtpMediaDescriptorPtimeSet(ptime)
+	return remote
+}
+
+/* Receive RTP packet on socket */
+func rtpTestPacketReceive(t *testing.T, socket *net.UDPConn) (*RtpHeader, []byte) {
+	buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+	socket.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := socket.ReadFromUDP(buffer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, payload, err := RtpHeaderParse(buffer[:n])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return header, payload
+}
+
+func TestRtpTransmitter(t *testing.T) {
+	peers := make([]*net.UDPConn, 2)
+	for i := range peers {
+		socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer socket.Close()
+		peers[i] = socket
+	}
+	termination := rtpTestTransmitterCreate(t, peers[0].LocalAddr().(*net.UDPAddr), 20, "PCMU")
+	defer TerminationDestroy(termination)
+	stream := termination.TerminationAudioStreamGet()
+	if err := stream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	write := func(frameType FrameType, value byte) {
+		frame := Frame{Type: frameType, PayloadType: RTP_PT_PCMU}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{value}, 80))
+		if err := stream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	/* frames of 10 msec are packed to ptime of 20 msec */
+	write(MEDIA_FRAME_TYPE_AUDIO, 1)
+	write(MEDIA_FRAME_TYPE_AUDIO, 2)
+	write(MEDIA_FRAME_TYPE_AUDIO, 3)
+	write(MEDIA_FRAME_TYPE_AUDIO, 4)
+	first, payload := rtpTestPacketReceive(t, peers[0])
+	if first.Marker != 1 || first.Type != uint32(RTP_PT_PCMU) || len(payload) != 160 || payload[0] != 1 || payload[80] != 2 {
+		t.Fatalf("first packet %+v of %d bytes", first, len(payload))
+	}
+	second, _ := rtpTestPacketReceive(t, peers[0])
+	if second.Marker != 0 || second.sequence != first.sequence+1 || second.timestamp != first.timestamp+160 || second.ssrc != first.ssrc {
+		t.Fatalf("second packet %+v follows %+v", second, first)
+	}
+
+	/* the destination is updated (re-INVITE), talkspurt after silence is marked */
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(rtpTestRemoteCreate(peers[1].LocalAddr().(*net.UDPAddr), 20))
+	if err := termination.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	write(MEDIA_FRAME_TYPE_NONE, 0)
+	write(MEDIA_FRAME_TYPE_AUDIO, 5)
+	write(MEDIA_FRAME_TYPE_AUDIO, 6)
+	third, payload := rtpTestPacketReceive(t, peers[1])
+	if third.Marker != 1 || third.sequence != first.sequence+2 || third.timestamp != first.timestamp+400 || payload[0] != 5 {
+		t.Fatalf("third packet %+v follows %+v", third, first)
+	}
+
+	stat, _ := RtpStreamTXStatGet(stream)
+	if stat.SentPackets != 3 || stat.SentOctets != 480 || stat.Talkspurts != 2 || stat.FailedPackets != 0 {
+		t.Fatalf("stat %+v", stat)
+	}
+}
+
+func TestRtpTransmitterEvents(t *testing.T) {
+	transmitter := &RtpTransmitter{}
+	RtpTransmitterInit(transmitter)
+	transmitter.rtpTXOpen(&CodecDescriptor{PayloadType: RTP_PT_PCMU, Name: "PCMU", SamplingRate: 8000, ChannelCount: 1}, EventDescriptorCreate(8000), 20)
+	write := func(frame Frame) [][]byte {
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+			codecFrameDataSet(&frame.CodecFrame, make([]byte, 80))
+		}
+		return transmitter.rtpTXFrameWrite(&frame, false)
+	}
+	event := func(marker FrameMarker, edge, duration uint32) Frame {
+		return Frame{Type: MEDIA_FRAME_TYPE_EVENT, Marker: marker,
+			EventFrame: NamedEventFrame{EventId: 5, Volume: DTMF_EVENT_VOLUME, Edge: edge, Duration: duration}}
+	}
+
+	/* the audio packed is sent before the start of the event */
+	write(Frame{Type: MEDIA_FRAME_TYPE_AUDIO})
+	packets := write(event(MPF_MARKER_START_OF_EVENT, 0, 80))
+	if len(packets) != 2 {
+		t.Fatalf("%d packets sent at the start of event", len(packets))
+	}
+	audio, _, _ := RtpHeaderParse(packets[0])
+	start, payload, _ := RtpHeaderParse(packets[1])
+	if start.Marker != 1 || start.Type != MPF_EVENT_PAYLOAD_TYPE || start.timestamp != audio.timestamp+80 || start.sequence != audio.sequence+1 {
+		t.Fatalf("start of event %+v after audio %+v", start, audio)
+	}
+	if events, err := NamedEventsParse(payload); err != nil || len(events) != 1 || events[0].EventId != 5 || events[0].Duration != 80 {
+		t.Fatalf("event %+v: %v", events, err)
+	}
+	/* the audio is not sent while the event is sent */
+	if packets := write(Frame{Type: MEDIA_FRAME_TYPE_AUDIO}); len(packets) != 0 {
+		t.Fatal("audio is sent along with the event")
+	}
+	update, _, _ := RtpHeaderParse(write(event(MPF_MARKER_NONE, 0, 240))[0])
+	if update.Marker != 0 || update.timestamp != start.timestamp {
+		t.Fatalf("update of event %+v", update)
+	}
+	/* the end is retransmitted with the same duration */
+	for i := 0; i < 3; i++ {
+		end, payload, _ := RtpHeaderParse(write(event(MPF_MARKER_END_OF_EVENT, 1, 320))[0])
+		events, _ := NamedEventsParse(payload)
+		if end.Marker != 0 || end.timestamp != start.timestamp || end.sequence != update.sequence+uint32(i)+1 || events[0].Edge != 1 || events[0].Duration != 320 {
+			t.Fatalf("end of event %+v %+v", end, events)
+		}
+	}
+	/* the audio after the event starts talkspurt, the time of the event is accounted */
+	write(Frame{Type: MEDIA_FRAME_TYPE_AUDIO})
+	packets = write(Frame{Type: MEDIA_FRAME_TYPE_AUDIO})
+	if header, _, _ := RtpHeaderParse(packets[0]); header.Marker != 1 || header.Type != uint32(RTP_PT_PCMU) || header.timestamp != start.timestamp+6*80 {
+		t.Fatalf("audio %+v after event %+v", header, start)
+	}
+}
@@ -1,5 +1,11 @@
 package mpf
 
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
 /** Prototype of scheduler callback */
 type SchedulerProc func(scheduler *Scheduler, obj interface{})
 
@@ -15,39 +21,133 @@ type Scheduler struct {
 	timerProc        SchedulerProc
 	timerObj         interface{}
 	timerId          uint
+
+	/* elapsed time of media clock */
+	mediaElapsedTime uint64
+	/* n times faster than real-time */
+	rate uint64
+
+	mutex sync.Mutex
+	stop  chan struct{}
+	done  chan struct{}
 }
 
 /** Create scheduler */
 func SchedulerCreate() *Scheduler {
-	return nil
+	return &Scheduler{
+		resolution:      CODEC_FRAME_TIME_BASE,
+		mediaResolution: CODEC_FRAME_TIME_BASE,
+		rate:            1,
+	}
 }
 
 /** Destroy scheduler */
 func SchedulerDestroy(scheduler *Scheduler) error {
-	return nil
+	return scheduler.SchedulerStop()
 }
 
 /** Set media processing clock */
 func (s *Scheduler) SchedulerMediaClockSet(resolution uint64, proc SchedulerProc, obj interface{}) error {
+	if resolution == 0 || resolution%s.resolution != 0 {
+		return fmt.Errorf("media clock resolution %d msec is not a multiple of scheduler resolution %d msec", resolution, s.resolution)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mediaResolution = resolution
+	s.mediaElapsedTime = 0
+	s.mediaProc = proc
+	s.mediaObj = obj
 	return nil
 }
 
 /** Set timer clock */
 func (s *Scheduler) SchedulerTimerClockSet(resolution uint64, proc SchedulerProc, obj interface{}) error {
+	if resolution == 0 || resolution%s.resolution != 0 {
+		return fmt.Errorf("timer clock resolution %d msec is not a multiple of scheduler resolution %d msec", resolution, s.resolution)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.timerResolution = resolution
+	s.timerElapsedTime = 0
+	s.timerProc = proc
+	s.timerObj = obj
 	return nil
 }
 
 /** Set scheduler rate (n times faster than real-time) */
 func (s *Scheduler) SchedulerRateSet(rate uint64) error {
+	if rate == 0 {
+		return fmt.Errorf("invalid scheduler rate %d", rate)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stop != nil {
+		return fmt.Errorf("scheduler rate must be set before start")
+	}
+	s.rate = rate
 	return nil
 }
 
 /** Start scheduler */
 func (s *Scheduler) SchedulerStart() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.stop != nil {
+		return fmt.Errorf("scheduler is already started")
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run(time.Duration(s.resolution)*time.Millisecond/time.Duration(s.rate), s.stop, s.done)
 	return nil
 }
 
 /** Stop scheduler */
 func (s *Scheduler) SchedulerStop() error {
+	s.mutex.Lock()
+	stop, done := s.stop, s.done
+	s.stop, s.done = nil, nil
+	s.mutex.Unlock()
+	if stop == nil {
+		return nil
+	}
+	close(stop)
+	<-done
 	return nil
 }
+
+/* Tick every resolution, media and timer procs are called as soon as their clocks elapse */
+func (s *Scheduler) run(interval time.Duration, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		s.mutex.Lock()
+		var mediaProc, timerProc SchedulerProc
+		s.mediaElapsedTime += s.resolution
+		if s.mediaElapsedTime >= s.mediaResolution {
+			s.mediaElapsedTime = 0
+			mediaProc = s.mediaProc
+		}
+		if s.timerResolution > 0 {
+			s.timerElapsedTime += s.resolution
+			if s.timerElapsedTime >= s.timerResolution {
+				s.timerElapsedTime = 0
+				timerProc = s.timerProc
+			}
+		}
+		mediaObj, timerObj := s.mediaObj, s.timerObj
+		s.mutex.Unlock()
+
+		if mediaProc != nil {
+			mediaProc(s, mediaObj)
+		}
+		if timerProc != nil {
+			timerProc(s, timerObj)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package mpf
+
+import "fmt"
+
+/** Default level threshold of silence (mean absolute sample value of linear audio) */
+const SILENCE_GATE_LEVEL_THRESHOLD = 64
+
+/** Default duration of silence kept before the first speech and after the last one in msec */
+const SILENCE_GATE_KEEP = 100
+
+/** Max duration of silence trimmed (or held) at once in msec, longer silence is passed through */
+const SILENCE_GATE_MAX = 5000
+
+/** Silence gate states */
+type SilenceGateState = int
+
+const (
+	SILENCE_GATE_STATE_LEADING SilenceGateState = iota /**< leading silence is trimmed */
+	SILENCE_GATE_STATE_OPEN                            /**< speech is passed through */
+)
+
+/**
+ * Output gate trimming long leading and trailing silence (including low-level noise)
+ * of synthesized audio. Leading silence is dropped except the last kept period before speech,
+ * silence following speech is passed through up to the kept period and held beyond it,
+ * so that it is dropped if no speech follows till the end of the audio.
+ */
+type SilenceGate struct {
+	/** Level detector of frames */
+	detector *ActivityDetector
+	/** Number of silent frames kept at edges */
+	keepFrames int
+	/** Max number of silent frames trimmed or held at once */
+	maxFrames int
+
+	state SilenceGateState
+	/** Silent frames: the pre-roll in leading state, the held ones in open state */
+	silence [][]byte
+	/** Number of consecutive silent frames */
+	silenceCount int
+	/** Frames ready to be read */
+	output [][]byte
+}
+
+/**
+ * Create silence gate.
+ * @param levelThreshold the level threshold of silence (mean absolute sample value)
+ * @param keep the duration of silence kept at edges in msec
+ * @param max the max duration of silence trimmed at once in msec
+ */
+func SilenceGateCreate(levelThreshold int64, keep, max int64) (*SilenceGate, error) {
+	if levelThreshold < 0 || keep < 0 || max < keep {
+		return nil, fmt.Errorf("invalid silence gate level %d, keep %d or max %d", levelThreshold, keep, max)
+	}
+	detector := ActivityDetectorCreate()
+	detector.ActivityDetectorLevelSet(levelThreshold)
+	return &SilenceGate{
+		detector:   detector,
+		keepFrames: int(keep / CODEC_FRAME_TIME_BASE),
+		maxFrames:  int(max / CODEC_FRAME_TIME_BASE),
+		state:      SILENCE_GATE_STATE_LEADING,
+	}, nil
+}
+
+/** Reset silence gate for the next audio (e.g. the next SPEAK request) */
+func (g *SilenceGate) SilenceGateReset() {
+	g.state = SILENCE_GATE_STATE_LEADING
+	g.silence = nil
+	g.silenceCount = 0
+	g.output = nil
+}
+
+/* Process source frame data, queueing the frames to output as soon as they are known not to be trimmed */
+func (g *SilenceGate) process(data []byte, silent bool) {
+	switch g.state {
+	case SILENCE_GATE_STATE_LEADING:
+		if !silent || g.silenceCount >= g.maxFrames {
+			/* speech starts (or the silence is too long to trim), the pre-roll precedes it */
+			g.output = append(g.output, g.silence...)
+			g.output = append(g.output, data)
+			g.silence = nil
+			g.silenceCount = 0
+			g.state = SILENCE_GATE_STATE_OPEN
+			return
+		}
+		g.silenceCount++
+		g.silence = append(g.silence, data)
+		if len(g.silence) > g.keepFrames {
+			g.silence = g.silence[1:]
+		}
+	case SILENCE_GATE_STATE_OPEN:
+		if !silent {
+			/* a pause within speech, not trailing silence */
+			g.output = append(g.output, g.silence...)
+			g.output = append(g.output, data)
+			g.silence = nil
+			g.silenceCount = 0
+			return
+		}
+		g.silenceCount++
+		if g.silenceCount <= g.keepFrames {
+			g.output = append(g.output, data)
+			return
+		}
+		g.silence = append(g.silence, data)
+		if len(g.silence) >= g.maxFrames {
+			g.output = append(g.output, g.silence...)
+			g.silence = nil
+		}
+	}
+}
+
+/**
+ * Read gated audio frame, pulling as many source frames as needed.
+ * When the source has no more audio, the held silence is dropped and the gate is reset.
+ * @param read the function reading the source (linear) frame
+ * @param frame the frame to read to
+ */
+func (g *SilenceGate) SilenceGateFrameRead(read func(frame *Frame) error, frame *Frame) error {
+	for len(g.output) == 0 {
+		if err := read(frame); err != nil {
+			return err
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+			/* no audio in the source (e.g. speech is over), trailing silence is trimmed */
+			g.SilenceGateReset()
+			return nil
+		}
+		level, err := g.detector.ActivityDetectorLevelCalculate(frame)
+		if err != nil {
+			return err
+		}
+		data := append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...)
+		g.process(data, level < g.detector.LevelThreshold)
+	}
+	data := g.output[0]
+	g.output = g.output[1:]
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	return codecFrameDataSet(&frame.CodecFrame, data)
+}
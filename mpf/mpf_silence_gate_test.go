@@ -0,0 +1,80 @@
+package mpf
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestSilenceGate(t *testing.T) {
+	gate, err := SilenceGateCreate(SILENCE_GATE_LEVEL_THRESHOLD, SILENCE_GATE_KEEP, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	/* 0.8 s of leading noise, 0.5 s of speech, 0.4 s pause, 0.5 s of speech, 0.9 s of trailing noise */
+	noise := make([]int16, 80)
+	for i := range noise {
+		noise[i] = int16(i%5 - 2)
+	}
+	speech := loudnessTestSine(400, 8000, 10, 8000)
+	var source [][]int16
+	for _, part := range []struct {
+		samples []int16
+		frames  int
+	}{{noise, 80}, {speech, 50}, {noise, 40}, {speech, 50}, {noise, 90}} {
+		for i := 0; i < part.frames; i++ {
+			source = append(source, part.samples)
+		}
+	}
+
+	sourceFrames := 0
+	read := func(frame *Frame) error {
+		if sourceFrames >= len(source) {
+			frame.Type = MEDIA_FRAME_TYPE_NONE
+			return nil
+		}
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		sourceFrames++
+		return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(source[sourceFrames-1]))
+	}
+
+	frame := &Frame{CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(nil), Size: 160}}
+	var frames []int16
+	for {
+		if err := gate.SilenceGateFrameRead(read, frame); err != nil {
+			t.Fatal(err)
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+			break
+		}
+		samples, _ := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		frames = append(frames, samples[5])
+	}
+	/* kept 10 + 50 + pause 40 + 50 + kept 10 */
+	if len(frames) != 160 {
+		t.Fatalf("%d frames read, want 160", len(frames))
+	}
+	if frames[9] != noise[5] || frames[10] != speech[5] || frames[len(frames)-11] != speech[5] {
+		t.Fatal("speech is not framed by the kept silence")
+	}
+
+	/* the gate is reset for the next audio, too long leading silence is passed through from the max on */
+	sourceFrames = 0
+	source = make([][]int16, 150)
+	for i := range source {
+		source[i] = noise
+	}
+	count := 0
+	for {
+		gate.SilenceGateFrameRead(read, frame)
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+			break
+		}
+		count++
+	}
+	/* kept 10 + the frame at the max, then kept 10 of the rest */
+	if count != 21 {
+		t.Fatalf("%d frames read, want 21", count)
+	}
+}
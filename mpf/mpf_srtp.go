@@ -0,0 +1,501 @@
+package mpf
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/** SRTP crypto suites (RFC 4568) */
+const (
+	SRTP_AES_CM_128_HMAC_SHA1_80 = "AES_CM_128_HMAC_SHA1_80"
+	SRTP_AES_CM_128_HMAC_SHA1_32 = "AES_CM_128_HMAC_SHA1_32"
+)
+
+/** Sizes of SRTP master key and master salt of AES-CM-128 */
+const (
+	SRTP_MASTER_KEY_SIZE  = 16
+	SRTP_MASTER_SALT_SIZE = 14
+)
+
+/** Size of SRTP authentication key of HMAC-SHA1 */
+const SRTP_AUTH_KEY_SIZE = 20
+
+/** Size of SRTCP authentication tag, 80 bits for both suites */
+const SRTCP_AUTH_TAG_SIZE = 10
+
+/** Size of SRTCP index (with E flag) */
+const SRTCP_INDEX_SIZE = 4
+
+/** Size of replay window in packets */
+const SRTP_REPLAY_WINDOW_SIZE = 64
+
+/** Key derivation labels (RFC 3711 4.3.2) */
+const (
+	srtpLabelRtpEncryption  = 0x00
+	srtpLabelRtpAuth        = 0x01
+	srtpLabelRtpSalt        = 0x02
+	srtpLabelRtcpEncryption = 0x03
+	srtpLabelRtcpAuth       = 0x04
+	srtpLabelRtcpSalt       = 0x05
+)
+
+/* Get size of authentication tag of SRTP by crypto suite, 0 if the suite is not supported */
+func srtpAuthTagSizeGet(suite string) int {
+	switch strings.ToUpper(suite) {
+	case SRTP_AES_CM_128_HMAC_SHA1_80:
+		return 10
+	case SRTP_AES_CM_128_HMAC_SHA1_32:
+		return 4
+	}
+	return 0
+}
+
+/** Crypto attribute of SDP media (a=crypto, RFC 4568) */
+type SrtpCryptoAttrib struct {
+	/** Tag of the attribute, the answer refers to the offer by */
+	Tag int
+	/** Crypto suite */
+	Suite string
+	/** Master key */
+	Key []byte
+	/** Master salt */
+	Salt []byte
+}
+
+/**
+ * Generate crypto attribute of random master key and salt.
+ * @param tag the tag of the attribute
+ * @param suite the crypto suite
+ */
+func SrtpCryptoAttribGenerate(tag int, suite string) (*SrtpCryptoAttrib, error) {
+	if srtpAuthTagSizeGet(suite) == 0 {
+		return nil, fmt.Errorf("unsupported crypto suite [%s]", suite)
+	}
+	keySalt := make([]byte, SRTP_MASTER_KEY_SIZE+SRTP_MASTER_SALT_SIZE)
+	if _, err := rand.Read(keySalt); err != nil {
+		return nil, err
+	}
+	return &SrtpCryptoAttrib{
+		Tag:   tag,
+		Suite: strings.ToUpper(suite),
+		Key:   keySalt[:SRTP_MASTER_KEY_SIZE],
+		Salt:  keySalt[SRTP_MASTER_KEY_SIZE:],
+	}, nil
+}
+
+/**
+ * Parse value of crypto attribute, e.g. "1 AES_CM_128_HMAC_SHA1_80 inline:<key||salt>|2^20|1:4".
+ * The lifetime and MKI of the key are ignored, the session parameters are not supported.
+ * @param value the value of the attribute
+ */
+func SrtpCryptoAttribParse(value string) (*SrtpCryptoAttrib, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid crypto attribute [%s]", value)
+	}
+	tag, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid tag of crypto attribute [%s]", value)
+	}
+	if srtpAuthTagSizeGet(fields[1]) == 0 {
+		return nil, fmt.Errorf("unsupported crypto suite [%s]", fields[1])
+	}
+	if !strings.HasPrefix(strings.ToLower(fields[2]), "inline:") {
+		return nil, fmt.Errorf("unsupported key method of crypto attribute [%s]", value)
+	}
+	inline := fields[2][len("inline:"):]
+	if i := strings.IndexByte(inline, '|'); i >= 0 {
+		inline = inline[:i]
+	}
+	keySalt, err := base64.StdEncoding.DecodeString(inline)
+	if err != nil {
+		/* some implementations omit the padding */
+		if keySalt, err = base64.RawStdEncoding.DecodeString(inline); err != nil {
+			return nil, fmt.Errorf("invalid key of crypto attribute: %v", err)
+		}
+	}
+	if len(keySalt) != SRTP_MASTER_KEY_SIZE+SRTP_MASTER_SALT_SIZE {
+		return nil, fmt.Errorf("invalid key length %d of crypto attribute", len(keySalt))
+	}
+	return &SrtpCryptoAttrib{
+		Tag:   tag,
+		Suite: strings.ToUpper(fields[1]),
+		Key:   keySalt[:SRTP_MASTER_KEY_SIZE],
+		Salt:  keySalt[SRTP_MASTER_KEY_SIZE:],
+	}, nil
+}
+
+/** Get value of crypto attribute */
+func (a *SrtpCryptoAttrib) SrtpCryptoAttribStrGet() string {
+	keySalt := append(append([]byte(nil), a.Key...), a.Salt...)
+	return fmt.Sprintf("%d %s inline:%s", a.Tag, a.Suite, base64.StdEncoding.EncodeToString(keySalt))
+}
+
+/** Session keys derived from master key and salt for RTP or RTCP */
+type srtpSessionKeys struct {
+	block   cipher.Block
+	salt    []byte
+	authKey []byte
+}
+
+/* Derive session key of the label by AES-CM PRF, the key derivation rate is 0 (RFC 3711 4.3) */
+func srtpKeyDerive(master cipher.Block, masterSalt []byte, label byte, size int) []byte {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, masterSalt)
+	iv[7] ^= label
+	key := make([]byte, size)
+	cipher.NewCTR(master, iv).XORKeyStream(key, key)
+	return key
+}
+
+/* Derive session keys of the labels (encryption, authentication, salt) */
+func srtpSessionKeysDerive(master cipher.Block, masterSalt []byte, labels [3]byte) (*srtpSessionKeys, error) {
+	block, err := aes.NewCipher(srtpKeyDerive(master, masterSalt, labels[0], SRTP_MASTER_KEY_SIZE))
+	if err != nil {
+		return nil, err
+	}
+	return &srtpSessionKeys{
+		block:   block,
+		authKey: srtpKeyDerive(master, masterSalt, labels[1], SRTP_AUTH_KEY_SIZE),
+		salt:    srtpKeyDerive(master, masterSalt, labels[2], SRTP_MASTER_SALT_SIZE),
+	}, nil
+}
+
+/* Encrypt/decrypt data of the source and packet index in place by AES-CM (RFC 3711 4.1.1) */
+func (keys *srtpSessionKeys) srtpXor(data []byte, ssrc uint32, index uint64) {
+	iv := make([]byte, aes.BlockSize)
+	copy(iv, keys.salt)
+	var ssrcBytes [4]byte
+	binary.BigEndian.PutUint32(ssrcBytes[:], ssrc)
+	for i := 0; i < 4; i++ {
+		iv[4+i] ^= ssrcBytes[i]
+	}
+	for i := 0; i < 6; i++ {
+		iv[8+i] ^= byte(index >> uint(8*(5-i)))
+	}
+	cipher.NewCTR(keys.block, iv).XORKeyStream(data, data)
+}
+
+/* Calculate authentication tag of the portion of packet (followed by ROC for RTP) */
+func (keys *srtpSessionKeys) srtpAuthTagCalculate(data []byte, roc []byte, size int) []byte {
+	mac := hmac.New(sha1.New, keys.authKey)
+	mac.Write(data)
+	if roc != nil {
+		mac.Write(roc)
+	}
+	return mac.Sum(nil)[:size]
+}
+
+/** Replay list of the packets received, the window slides by the highest index */
+type srtpReplayList struct {
+	/** Any packet is received */
+	init bool
+	/** Highest index received */
+	index uint64
+	/** Bitmask of the packets received below the highest index */
+	bitmask uint64
+}
+
+/* Check whether packet of index is not replayed */
+func (r *srtpReplayList) srtpReplayCheck(index uint64) bool {
+	if !r.init || index > r.index {
+		return true
+	}
+	delta := r.index - index
+	if delta >= SRTP_REPLAY_WINDOW_SIZE {
+		return false
+	}
+	return r.bitmask&(1<<delta) == 0
+}
+
+/* Add packet of index (authenticated) to replay list */
+func (r *srtpReplayList) srtpReplayAdd(index uint64) {
+	if !r.init {
+		r.init = true
+		r.index = index
+		r.bitmask = 1
+		return
+	}
+	if index > r.index {
+		delta := index - r.index
+		if delta >= SRTP_REPLAY_WINDOW_SIZE {
+			r.bitmask = 1
+		} else {
+			r.bitmask = r.bitmask<<delta | 1
+		}
+		r.index = index
+		return
+	}
+	r.bitmask |= 1 << (r.index - index)
+}
+
+/** State of SRTP source (SSRC) */
+type srtpSource struct {
+	/** Rollover counter */
+	roc uint32
+	/** Highest sequence number (s_l) */
+	seq uint16
+	/** Any packet is sent/received */
+	init bool
+	/** Replay list of RTP */
+	replay srtpReplayList
+	/** Index of the last SRTCP packet sent */
+	rtcpIndex uint32
+	/** Replay list of RTCP */
+	rtcpReplay srtpReplayList
+}
+
+/** SRTP context of a direction, protecting the packets sent or unprotecting the packets received */
+type SrtpContext struct {
+	/** Size of authentication tag of SRTP */
+	authTagSize int
+	/** Session keys of RTP */
+	rtp *srtpSessionKeys
+	/** Session keys of RTCP */
+	rtcp *srtpSessionKeys
+	/** States of the sources */
+	sources map[uint32]*srtpSource
+
+	mutex sync.Mutex
+}
+
+/**
+ * Create SRTP context by crypto attribute.
+ * @param attrib the crypto attribute of the master key and salt
+ */
+func SrtpContextCreate(attrib *SrtpCryptoAttrib) (*SrtpContext, error) {
+	authTagSize := srtpAuthTagSizeGet(attrib.Suite)
+	if authTagSize == 0 {
+		return nil, fmt.Errorf("unsupported crypto suite [%s]", attrib.Suite)
+	}
+	if len(attrib.Key) != SRTP_MASTER_KEY_SIZE || len(attrib.Salt) != SRTP_MASTER_SALT_SIZE {
+		return nil, fmt.Errorf("invalid master key or salt length")
+	}
+	master, err := aes.NewCipher(attrib.Key)
+	if err != nil {
+		return nil, err
+	}
+	c := &SrtpContext{
+		authTagSize: authTagSize,
+		sources:     make(map[uint32]*srtpSource),
+	}
+	if c.rtp, err = srtpSessionKeysDerive(master, attrib.Salt, [3]byte{srtpLabelRtpEncryption, srtpLabelRtpAuth, srtpLabelRtpSalt}); err != nil {
+		return nil, err
+	}
+	if c.rtcp, err = srtpSessionKeysDerive(master, attrib.Salt, [3]byte{srtpLabelRtcpEncryption, srtpLabelRtcpAuth, srtpLabelRtcpSalt}); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+/* Get state of source, created on the first packet */
+func (c *SrtpContext) srtpSourceGet(ssrc uint32) *srtpSource {
+	source, ok := c.sources[ssrc]
+	if !ok {
+		source = &srtpSource{}
+		c.sources[ssrc] = source
+	}
+	return source
+}
+
+/* Get length of RTP header (with CSRCs and extension) of packet */
+func rtpHeaderLengthGet(data []byte) (int, error) {
+	if len(data) < RTP_HEADER_SIZE || (data[0]>>6) != RTP_VERSION {
+		return 0, fmt.Errorf("invalid rtp packet")
+	}
+	length := RTP_HEADER_SIZE + 4*int(data[0]&0x0F)
+	if (data[0] & 0x10) != 0 {
+		if len(data) < length+4 {
+			return 0, fmt.Errorf("rtp header extension is truncated")
+		}
+		length += 4 + 4*int(binary.BigEndian.Uint16(data[length+2:]))
+	}
+	if length > len(data) {
+		return 0, fmt.Errorf("rtp header is truncated")
+	}
+	return length, nil
+}
+
+/**
+ * Protect RTP packet: the payload is encrypted and the authentication tag is appended.
+ * @param packet the RTP packet to protect (left intact)
+ * @return the SRTP packet
+ */
+func (c *SrtpContext) SrtpProtect(packet []byte) ([]byte, error) {
+	headerLength, err := rtpHeaderLengthGet(packet)
+	if err != nil {
+		return nil, err
+	}
+	seq := binary.BigEndian.Uint16(packet[2:])
+	ssrc := binary.BigEndian.Uint32(packet[8:])
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	source := c.srtpSourceGet(ssrc)
+	switch {
+	case !source.init:
+		source.init = true
+		source.seq = seq
+	case seq < source.seq && source.seq-seq > 0x8000:
+		/* sequence number wrapped around */
+		source.roc++
+		source.seq = seq
+	case seq > source.seq:
+		source.seq = seq
+	}
+	index := uint64(source.roc)<<16 | uint64(seq)
+
+	out := make([]byte, len(packet), len(packet)+c.authTagSize)
+	copy(out, packet)
+	c.rtp.srtpXor(out[headerLength:], ssrc, index)
+	var roc [4]byte
+	binary.BigEndian.PutUint32(roc[:], source.roc)
+	return append(out, c.rtp.srtpAuthTagCalculate(out, roc[:], c.authTagSize)...), nil
+}
+
+/**
+ * Unprotect SRTP packet: the authentication tag is verified, replayed packets are rejected, the payload is decrypted.
+ * @param packet the SRTP packet received (left intact)
+ * @return the RTP packet
+ */
+func (c *SrtpContext) SrtpUnprotect(packet []byte) ([]byte, error) {
+	if len(packet) < RTP_HEADER_SIZE+c.authTagSize {
+		return nil, fmt.Errorf("srtp packet is truncated")
+	}
+	authenticated := packet[:len(packet)-c.authTagSize]
+	headerLength, err := rtpHeaderLengthGet(authenticated)
+	if err != nil {
+		return nil, err
+	}
+	seq := binary.BigEndian.Uint16(packet[2:])
+	ssrc := binary.BigEndian.Uint32(packet[8:])
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	source := c.srtpSourceGet(ssrc)
+	if !source.init {
+		source.seq = seq
+	}
+	/* estimate rollover counter of the packet (RFC 3711 3.3.1) */
+	roc := source.roc
+	if source.seq < 0x8000 {
+		if seq > source.seq && seq-source.seq > 0x8000 && roc > 0 {
+			roc--
+		}
+	} else if source.seq-0x8000 > seq {
+		roc++
+	}
+	index := uint64(roc)<<16 | uint64(seq)
+	if !source.replay.srtpReplayCheck(index) {
+		return nil, fmt.Errorf("srtp packet %d is replayed", index)
+	}
+	var rocBytes [4]byte
+	binary.BigEndian.PutUint32(rocBytes[:], roc)
+	tag := c.rtp.srtpAuthTagCalculate(authenticated, rocBytes[:], c.authTagSize)
+	if subtle.ConstantTimeCompare(tag, packet[len(authenticated):]) != 1 {
+		return nil, fmt.Errorf("srtp packet fails authentication")
+	}
+
+	source.init = true
+	source.replay.srtpReplayAdd(index)
+	if roc > source.roc {
+		source.roc = roc
+		source.seq = seq
+	} else if roc == source.roc && seq > source.seq {
+		source.seq = seq
+	}
+	out := append([]byte(nil), authenticated...)
+	c.rtp.srtpXor(out[headerLength:], ssrc, index)
+	return out, nil
+}
+
+/**
+ * Protect RTCP packet: the reports after the first header are encrypted, SRTCP index and authentication tag are appended.
+ * @param packet the compound RTCP packet to protect (left intact)
+ * @return the SRTCP packet
+ */
+func (c *SrtpContext) SrtcpProtect(packet []byte) ([]byte, error) {
+	if len(packet) < RTCP_HEADER_SIZE+4 {
+		return nil, fmt.Errorf("rtcp packet is truncated")
+	}
+	ssrc := binary.BigEndian.Uint32(packet[4:])
+
+	c.mutex.Lock()
+	source := c.srtpSourceGet(ssrc)
+	index := source.rtcpIndex & 0x7FFFFFFF
+	source.rtcpIndex++
+	c.mutex.Unlock()
+
+	out := make([]byte, len(packet), len(packet)+SRTCP_INDEX_SIZE+SRTCP_AUTH_TAG_SIZE)
+	copy(out, packet)
+	c.rtcp.srtpXor(out[RTCP_HEADER_SIZE+4:], ssrc, uint64(index))
+	out = append(out, 0, 0, 0, 0)
+	/* E flag, the packet is encrypted */
+	binary.BigEndian.PutUint32(out[len(out)-SRTCP_INDEX_SIZE:], 0x80000000|index)
+	return append(out, c.rtcp.srtpAuthTagCalculate(out, nil, SRTCP_AUTH_TAG_SIZE)...), nil
+}
+
+/**
+ * Unprotect SRTCP packet: the authentication tag is verified, replayed packets are rejected, the reports are decrypted.
+ * @param packet the SRTCP packet received (left intact)
+ * @return the compound RTCP packet
+ */
+func (c *SrtpContext) SrtcpUnprotect(packet []byte) ([]byte, error) {
+	if len(packet) < RTCP_HEADER_SIZE+4+SRTCP_INDEX_SIZE+SRTCP_AUTH_TAG_SIZE {
+		return nil, fmt.Errorf("srtcp packet is truncated")
+	}
+	authenticated := packet[:len(packet)-SRTCP_AUTH_TAG_SIZE]
+	tag := c.rtcp.srtpAuthTagCalculate(authenticated, nil, SRTCP_AUTH_TAG_SIZE)
+	if subtle.ConstantTimeCompare(tag, packet[len(authenticated):]) != 1 {
+		return nil, fmt.Errorf("srtcp packet fails authentication")
+	}
+	word := binary.BigEndian.Uint32(authenticated[len(authenticated)-SRTCP_INDEX_SIZE:])
+	encrypted, index := (word&0x80000000) != 0, uint64(word&0x7FFFFFFF)
+	ssrc := binary.BigEndian.Uint32(packet[4:])
+
+	c.mutex.Lock()
+	source := c.srtpSourceGet(ssrc)
+	if !source.rtcpReplay.srtpReplayCheck(index) {
+		c.mutex.Unlock()
+		return nil, fmt.Errorf("srtcp packet %d is replayed", index)
+	}
+	source.rtcpReplay.srtpReplayAdd(index)
+	c.mutex.Unlock()
+
+	out := append([]byte(nil), authenticated[:len(authenticated)-SRTCP_INDEX_SIZE]...)
+	if encrypted {
+		c.rtcp.srtpXor(out[RTCP_HEADER_SIZE+4:], ssrc, index)
+	}
+	return out, nil
+}
+
+/**
+ * Negotiate SRTP: if offered/accepted by the settings and the remote media offers supported crypto suite,
+ * the crypto attribute of the local media (of the same tag and suite) is generated.
+ * SRTP is not used otherwise.
+ */
+func (d *RtpStreamDescriptor) RtpStreamDescriptorSrtpNegotiate() error {
+	if d.local == nil || d.remote == nil || d.settings == nil {
+		return fmt.Errorf("local, remote media or settings of rtp stream is nil")
+	}
+	d.local.crypto = nil
+	if !d.settings.srtp || d.remote.crypto == nil {
+		return nil
+	}
+	crypto, err := SrtpCryptoAttribGenerate(d.remote.crypto.Tag, d.remote.crypto.Suite)
+	if err != nil {
+		return err
+	}
+	d.local.crypto = crypto
+	return nil
+}
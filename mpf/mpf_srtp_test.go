@@ -0,0 +1,201 @@
+package mpf
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSrtpKeyDerive(t *testing.T) {
+	/* RFC 3711 B.3 */
+	masterKey, _ := hex.DecodeString("E1F97A0D3E018BE0D64FA32C06DE4139")
+	masterSalt, _ := hex.DecodeString("0EC675AD498AFEEBB6960B3AABE6")
+	master, err := aes.NewCipher(masterKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		label byte
+		size  int
+		key   string
+	}{
+		{srtpLabelRtpEncryption, SRTP_MASTER_KEY_SIZE, "C61E7A93744F39EE10734AFE3FF7A087"},
+		{srtpLabelRtpSalt, SRTP_MASTER_SALT_SIZE, "30CBBC08863D8C85D49DB34A9AE1"},
+		{srtpLabelRtpAuth, SRTP_AUTH_KEY_SIZE, "CEBE321F6FF7716B6FD4AB49AF256A156D38BAA4"},
+	} {
+		if key := strings.ToUpper(hex.EncodeToString(srtpKeyDerive(master, masterSalt, test.label, test.size))); key != test.key {
+			t.Errorf("key of label %d is %s", test.label, key)
+		}
+	}
+}
+
+func TestSrtpCryptoAttrib(t *testing.T) {
+	attrib, err := SrtpCryptoAttribParse("1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR|2^20|1:4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attrib.Tag != 1 || attrib.Suite != SRTP_AES_CM_128_HMAC_SHA1_80 || len(attrib.Key) != SRTP_MASTER_KEY_SIZE || len(attrib.Salt) != SRTP_MASTER_SALT_SIZE {
+		t.Fatalf("crypto attribute %+v", attrib)
+	}
+	if value := attrib.SrtpCryptoAttribStrGet(); value != "1 AES_CM_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR" {
+		t.Fatalf("crypto attribute is formatted as [%s]", value)
+	}
+	for _, value := range []string{"1 F8_128_HMAC_SHA1_80 inline:PS1uQCVeeCFCanVmcjkpPywjNWhcYD0mXXtxaVBR", "1 AES_CM_128_HMAC_SHA1_32 inline:PS1u", "x AES_CM_128_HMAC_SHA1_32"} {
+		if _, err := SrtpCryptoAttribParse(value); err == nil {
+			t.Errorf("invalid crypto attribute [%s] is parsed", value)
+		}
+	}
+	if RtpAttribIdFind("crypto") != RTP_ATTRIB_CRYPTO {
+		t.Fatal("crypto attribute is not found")
+	}
+}
+
+func TestSrtpProtect(t *testing.T) {
+	for _, suite := range []string{SRTP_AES_CM_128_HMAC_SHA1_80, SRTP_AES_CM_128_HMAC_SHA1_32} {
+		attrib, err := SrtpCryptoAttribGenerate(1, suite)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sender, _ := SrtpContextCreate(attrib)
+		receiver, _ := SrtpContextCreate(attrib)
+
+		/* the sequence numbers wrap around, the rollover counter is tracked */
+		for _, seq := range []uint16{65534, 65535, 0, 1} {
+			packet := rtpTestPacketCreate(0x1234, seq, uint32(seq)*160, false, bytes.Repeat([]byte{byte(seq)}, 160))
+			protected, err := sender.SrtpProtect(packet)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(protected) != len(packet)+srtpAuthTagSizeGet(suite) || bytes.Equal(protected[RTP_HEADER_SIZE:len(packet)], packet[RTP_HEADER_SIZE:]) {
+				t.Fatalf("packet %d is not protected", seq)
+			}
+			unprotected, err := receiver.SrtpUnprotect(protected)
+			if err != nil || !bytes.Equal(unprotected, packet) {
+				t.Fatalf("packet %d is not unprotected: %v", seq, err)
+			}
+			/* replayed */
+			if _, err := receiver.SrtpUnprotect(protected); err == nil {
+				t.Fatalf("replayed packet %d is unprotected", seq)
+			}
+			/* tampered */
+			protected[RTP_HEADER_SIZE] ^= 1
+			protected[3]++
+			if _, err := receiver.SrtpUnprotect(protected); err == nil {
+				t.Fatalf("tampered packet %d is unprotected", seq)
+			}
+		}
+		if source := receiver.sources[0x1234]; source.roc != 1 || source.seq != 1 {
+			t.Fatalf("rollover counter %d, sequence number %d", source.roc, source.seq)
+		}
+
+		report := RtcpSdesAppend(RtcpRRAppend(nil, 0x1234, nil), 0x1234, "mpf@127.0.0.1")
+		protected, err := sender.SrtcpProtect(report)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(protected) != len(report)+SRTCP_INDEX_SIZE+SRTCP_AUTH_TAG_SIZE {
+			t.Fatalf("srtcp packet of %d bytes", len(protected))
+		}
+		if unprotected, err := receiver.SrtcpUnprotect(protected); err != nil || !bytes.Equal(unprotected, report) {
+			t.Fatalf("srtcp packet is not unprotected: %v", err)
+		}
+		if _, err := receiver.SrtcpUnprotect(protected); err == nil {
+			t.Fatal("replayed srtcp packet is unprotected")
+		}
+	}
+}
+
+func TestSrtpNegotiate(t *testing.T) {
+	offer, _ := SrtpCryptoAttribGenerate(2, SRTP_AES_CM_128_HMAC_SHA1_32)
+	descriptor := RtpStreamDescriptor{local: RtpMediaDescriptorAlloc(), remote: RtpMediaDescriptorAlloc(), settings: RtpSettingsAlloc()}
+	descriptor.remote.RtpMediaDescriptorCryptoSet(offer)
+	if err := descriptor.RtpStreamDescriptorSrtpNegotiate(); err != nil || descriptor.local.crypto != nil {
+		t.Fatalf("srtp is negotiated while not accepted: %v", err)
+	}
+	descriptor.settings.RtpSettingsSrtpSet(true)
+	if err := descriptor.RtpStreamDescriptorSrtpNegotiate(); err != nil {
+		t.Fatal(err)
+	}
+	answer := descriptor.local.RtpMediaDescriptorCryptoGet()
+	if answer == nil || answer.Tag != 2 || answer.Suite != SRTP_AES_CM_128_HMAC_SHA1_32 || bytes.Equal(answer.Key, offer.Key) {
+		t.Fatalf("answer %+v to offer %+v", answer, offer)
+	}
+}
+
+func TestSrtpTermination(t *testing.T) {
+	receiver, addr := rtpTestReceiverCreate(t, "PCMU")
+	defer TerminationDestroy(receiver)
+	sender := rtpTestTransmitterCreate(t, addr, 10, "PCMU")
+	defer TerminationDestroy(sender)
+
+	/* the key of local media of the sender is the key of remote media of the receiver */
+	crypto, err := SrtpCryptoAttribGenerate(1, SRTP_AES_CM_128_HMAC_SHA1_80)
+	if err != nil {
+		t.Fatal(err)
+	}
+	local := RtpMediaDescriptorAlloc()
+	local.RtpMediaDescriptorStateSet(MPF_MEDIA_ENABLED)
+	local.RtpMediaDescriptorAddressSet("127.0.0.1", 0)
+	local.RtpMediaDescriptorDirectionSet(STREAM_DIRECTION_SEND)
+	local.RtpMediaDescriptorCryptoSet(crypto)
+	descriptor := RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioLocalSet(local)
+	if err := sender.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+	remote := rtpTestRemoteCreate(&net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}, 10)
+	remote.RtpMediaDescriptorCryptoSet(crypto)
+	descriptor = RtpTerminationDescriptorAlloc()
+	descriptor.RtpTerminationDescriptorAudioRemoteSet(remote)
+	if err := receiver.TerminationModify(descriptor); err != nil {
+		t.Fatal(err)
+	}
+
+	senderStream := sender.TerminationAudioStreamGet()
+	if err := senderStream.AudioStreamTXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	receiverStream := receiver.TerminationAudioStreamGet()
+	if err := receiverStream.AudioStreamRXOpen(nil); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		frame := Frame{Type: MEDIA_FRAME_TYPE_AUDIO, PayloadType: RTP_PT_PCMU}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{byte(i + 1)}, 80))
+		if err := senderStream.AudioStreamFrameWrite(&frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	/* packet of plain RTP is rejected */
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write(rtpTestPacketCreate(1, 1, 0, true, make([]byte, 80))); err != nil {
+		t.Fatal(err)
+	}
+
+	var stat RtpRXStat
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if stat, _ = RtpStreamRXStatGet(receiverStream); stat.ReceivedPackets == 3 && stat.InvalidPackets == 1 {
+			break
+		}
+	}
+	if stat.ReceivedPackets != 3 || stat.InvalidPackets != 1 {
+		t.Fatalf("stat %+v", stat)
+	}
+	for i := 0; i < 3; i++ {
+		frame := Frame{}
+		if err := receiverStream.AudioStreamFrameRead(&frame); err != nil {
+			t.Fatal(err)
+		}
+		if data := codecFrameDataGet(&frame.CodecFrame); len(data) != 80 || data[0] != byte(i+1) {
+			t.Fatalf("frame %d is not decrypted", i)
+		}
+	}
+}
@@ -1,6 +1,11 @@
 package mpf
 
-import "github.com/navi-tt/go-mrcp/toolkit"
+import (
+	"fmt"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
 
 /** Table of audio stream virtual methods */
 //todo(可以改成interface接口)
@@ -25,6 +30,9 @@ type AudioStreamVTable struct {
 
 	/** Virtual trace method */
 	Trace func(stream *AudioStream, direction StreamDirection, output *toolkit.AptTextStream)
+
+	/** [OPTIONAL] Virtual codec update method, RX/TX are re-opened with the codec if not set */
+	UpdateCodec func(stream *AudioStream, codec *Codec) error
 }
 
 /** Audio stream */
@@ -51,20 +59,53 @@ type AudioStream struct {
 	TXDescriptor *CodecDescriptor
 	/** Tx event descriptor */
 	TXEventDescriptor *CodecDescriptor
-}
 
-/** Video stream */
-type VideoStream struct {
+	/** Frames written are processed in place (e.g. filtered), so that frame shared by sinks is copied for the stream */
+	FrameWriteInPlace bool
 
-	/** Back pointer */
-	termination *Termination
-	/** Stream direction send/receive (bitmask of mpf_stream_direction_e) */
-	direction StreamDirection
+	/** Meters of the frames read and written */
+	rxMeter, txMeter streamMeter
+	/** Tracer of the frames read and written */
+	tracer streamTracer
 }
 
 /** Create audio stream */
 func AudioStreamCreate(obj interface{}, vtable *AudioStreamVTable, capabilities *StreamCapabilities) *AudioStream {
-	return nil
+	if vtable == nil || capabilities == nil {
+		return nil
+	}
+	return &AudioStream{
+		Obj:          obj,
+		VTable:       vtable,
+		Capabilities: capabilities,
+		direction:    capabilities.direction,
+	}
+}
+
+/**
+ * Check whether audio stream accepts encoded frames of the codec natively (without transcoding).
+ * @param descriptor the codec descriptor to check
+ */
+func (stream *AudioStream) AudioStreamCodecAccept(descriptor *CodecDescriptor) bool {
+	if stream.Capabilities == nil || descriptor == nil {
+		return false
+	}
+	return stream.Capabilities.codecs.CodecCapabilitiesNativeFind(descriptor) != nil
+}
+
+/**
+ * Check whether frames of audio stream may be dropped under overload.
+ * Non-live sources (file, tone) are shed before live RTP/engine streams.
+ * @param stream the source audio stream
+ */
+func AudioStreamSheddable(stream *AudioStream) bool {
+	switch obj := stream.Obj.(type) {
+	case *AudioFileStream:
+		return true
+	case *Decoder:
+		return AudioStreamSheddable(obj.Source)
+	}
+	return false
 }
 
 /** Validate audio stream receiver */
@@ -103,8 +144,19 @@ func (stream *AudioStream) AudioStreamRXClose() error {
 
 /** Read frame */
 func (stream *AudioStream) AudioStreamFrameRead(frame *Frame) error {
+	var start time.Time
+	traced := stream.tracer.procGet() != nil
+	if traced {
+		start = time.Now()
+	}
 	if stream.VTable != nil && stream.VTable.ReadFrame != nil {
-		return stream.VTable.ReadFrame(stream, frame)
+		if err := stream.VTable.ReadFrame(stream, frame); err != nil {
+			return err
+		}
+	}
+	stream.rxMeter.update(frame, stream.RXDescriptor)
+	if traced {
+		stream.tracer.trace(stream, STREAM_DIRECTION_RECEIVE, frame, start)
 	}
 	return nil
 }
@@ -127,12 +179,70 @@ func (stream *AudioStream) AudioStreamTXClose() error {
 
 /** Write frame */
 func (stream *AudioStream) AudioStreamFrameWrite(frame *Frame) error {
+	/* metered before written, as the frame may be processed in place by the stream */
+	stream.txMeter.update(frame, stream.TXDescriptor)
+	if stream.tracer.procGet() != nil {
+		return stream.tracedFrameWrite(frame)
+	}
 	if stream.VTable != nil && stream.VTable.WriteFrame != nil {
 		return stream.VTable.WriteFrame(stream, frame)
 	}
 	return nil
 }
 
+/**
+ * Update codec of open audio stream (e.g. renegotiated by re-INVITE) without re-creating it:
+ * the open receiver/transmitter is closed and re-opened with the new codec.
+ * @param codec the new codec
+ */
+func (stream *AudioStream) AudioStreamCodecUpdate(codec *Codec) error {
+	if stream.VTable != nil && stream.VTable.UpdateCodec != nil {
+		return stream.VTable.UpdateCodec(stream, codec)
+	}
+	direction := STREAM_DIRECTION_DUPLEX
+	if stream.Capabilities != nil {
+		direction = stream.Capabilities.StreamCapabilitiesDirectionGet()
+	}
+	if (direction & STREAM_DIRECTION_RECEIVE) == STREAM_DIRECTION_RECEIVE {
+		if err := stream.AudioStreamRXClose(); err != nil {
+			return err
+		}
+		if err := stream.AudioStreamRXOpen(codec); err != nil {
+			return err
+		}
+	}
+	if (direction & STREAM_DIRECTION_SEND) == STREAM_DIRECTION_SEND {
+		if err := stream.AudioStreamTXClose(); err != nil {
+			return err
+		}
+		if err := stream.AudioStreamTXOpen(codec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Change direction of audio stream at runtime, e.g. on hold/unhold (the context of the termination
+ * must be updated then, @see ContextTerminationDirectionSet()).
+ * @param direction the direction (STREAM_DIRECTION_NONE - inactive), supported by the capabilities of the stream
+ */
+func (stream *AudioStream) AudioStreamDirectionSet(direction StreamDirection) error {
+	if stream.Capabilities != nil && (direction&stream.Capabilities.direction) != direction {
+		return fmt.Errorf("direction %d is not supported by the stream", direction)
+	}
+	stream.direction = direction
+	return nil
+}
+
+/** Get current direction of audio stream */
+func (stream *AudioStream) AudioStreamDirectionGet() StreamDirection {
+	return stream.direction
+}
+
 /** Trace media path */
 func (stream *AudioStream) AudioStreamTrace(direction StreamDirection, output *toolkit.AptTextStream) {
+	if stream.VTable != nil && stream.VTable.Trace != nil {
+		stream.VTable.Trace(stream, direction, output)
+	}
 }
@@ -1,5 +1,7 @@
 package mpf
 
+import "fmt"
+
 /** Stream directions (none, send, receive, duplex) */
 type StreamDirection = int
 
@@ -21,7 +23,11 @@ type StreamCapabilities struct {
 
 /** Create stream capabilities */
 func StreamCapabilitiesCreate(directions StreamDirection) *StreamCapabilities {
-	return nil
+	capabilities := &StreamCapabilities{
+		direction: directions,
+	}
+	capabilities.codecs.CodecCapabilitiesInit(1)
+	return capabilities
 }
 
 /** Create source stream capabilities */
@@ -36,14 +42,36 @@ func SinkStreamCapabilitiesCreate() *StreamCapabilities {
 
 /** Clone stream capabilities */
 func StreamCapabilitiesClone(srcCapabilities *StreamCapabilities) *StreamCapabilities {
-	return nil
+	if srcCapabilities == nil {
+		return nil
+	}
+	capabilities := &StreamCapabilities{
+		direction: srcCapabilities.direction,
+	}
+	capabilities.codecs.CodecCapabilitiesClone(&srcCapabilities.codecs)
+	return capabilities
 }
 
 /** Merge stream capabilities */
 func StreamCapabilitiesMerge(capabilities, srcCapabilities *StreamCapabilities) error {
+	if capabilities == nil || srcCapabilities == nil {
+		return fmt.Errorf("capabilities is nil")
+	}
+	capabilities.direction |= srcCapabilities.direction
+	capabilities.codecs.CodecCapabilitiesMerge(&srcCapabilities.codecs)
 	return nil
 }
 
+/** Get codec capabilities (codecs the stream accepts natively) */
+func (c *StreamCapabilities) StreamCapabilitiesCodecsGet() *CodecCapabilities {
+	return &c.codecs
+}
+
+/** Get supported directions */
+func (c *StreamCapabilities) StreamCapabilitiesDirectionGet() StreamDirection {
+	return c.direction
+}
+
 /** Get reverse direction */
 func StreamReverseDirectionGet(direction StreamDirection) StreamDirection {
 	revDirection := direction
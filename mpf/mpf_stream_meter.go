@@ -0,0 +1,141 @@
+package mpf
+
+import (
+	"math"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Level reported for silence (no energy) in dBFS */
+const METER_LEVEL_SILENCE = -96.0
+
+/** Time constant of the running RMS level in msec */
+const METER_RMS_TIME = 300
+
+/** Decay of the peak level held in dB per second */
+const METER_PEAK_DECAY = 20.0
+
+/** Stat of audio stream meter */
+type StreamMeterStat struct {
+	/** Total number of frames read/written */
+	Frames uint64
+	/** Number of frames of audio */
+	AudioFrames uint64
+	/** Number of frames of (named) events */
+	EventFrames uint64
+	/** Number of frames since the last frame of audio (all frames if none) */
+	IdleFrames uint64
+	/** Running RMS level in dBFS (linear audio only, METER_LEVEL_SILENCE otherwise) */
+	Rms float64
+	/** Peak level held (decaying by METER_PEAK_DECAY) in dBFS (linear audio only) */
+	Peak float64
+}
+
+/* Meter of the frames of audio stream in one direction */
+type streamMeter struct {
+	mutex sync.Mutex
+	stat  StreamMeterStat
+	/** Running mean power and peak held, relative to full scale */
+	power, peak float64
+}
+
+/* Convert relative level to dBFS */
+func meterLevelGet(level float64) float64 {
+	if level <= 0 {
+		return METER_LEVEL_SILENCE
+	}
+	return math.Max(METER_LEVEL_SILENCE, 10*math.Log10(level))
+}
+
+/* Update meter by the frame passed */
+func (meter *streamMeter) update(frame *Frame, descriptor *CodecDescriptor) {
+	var samples []int16
+	audio := (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO
+	linear := descriptor != nil && descriptor.SamplingRate > 0 && CodecLPcmDescriptorMatch(descriptor)
+	if audio && linear {
+		samples, _ = binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+	}
+
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+	meter.stat.Frames++
+	if (frame.Type & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT {
+		meter.stat.EventFrames++
+	}
+	if audio {
+		meter.stat.AudioFrames++
+		meter.stat.IdleFrames = 0
+	} else {
+		meter.stat.IdleFrames++
+	}
+	if !linear {
+		return
+	}
+
+	/* the frame is silent unless audio */
+	duration := float64(CODEC_FRAME_TIME_BASE)
+	var energy, max float64
+	if len(samples) > 0 {
+		for _, sample := range samples {
+			v := float64(sample)
+			energy += v * v
+			if v = math.Abs(v); v > max {
+				max = v
+			}
+		}
+		energy /= float64(len(samples)) * 32768 * 32768
+		max = max * max / (32768 * 32768)
+		channels := math.Max(1, float64(descriptor.ChannelCount))
+		duration = float64(len(samples)) / channels * 1000 / float64(descriptor.SamplingRate)
+	}
+	alpha := math.Min(1, duration/METER_RMS_TIME)
+	meter.power += alpha * (energy - meter.power)
+	meter.peak = math.Max(max, meter.peak*math.Pow(10, -METER_PEAK_DECAY*duration/1000/10))
+	meter.stat.Rms = meterLevelGet(meter.power)
+	meter.stat.Peak = meterLevelGet(meter.peak)
+}
+
+/* Get stat of meter */
+func (meter *streamMeter) statGet() StreamMeterStat {
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+	stat := meter.stat
+	if stat.Frames == 0 {
+		stat.Rms, stat.Peak = METER_LEVEL_SILENCE, METER_LEVEL_SILENCE
+	}
+	return stat
+}
+
+/* Reset meter */
+func (meter *streamMeter) reset() {
+	meter.mutex.Lock()
+	defer meter.mutex.Unlock()
+	meter.stat = StreamMeterStat{}
+	meter.power, meter.peak = 0, 0
+}
+
+/**
+ * Get stat of the frames read from (receive) or written to (send) audio stream, e.g. to raise
+ * "no audio received" alarm by IdleFrames or to trace the levels.
+ * @param direction the direction (STREAM_DIRECTION_RECEIVE or STREAM_DIRECTION_SEND)
+ */
+func (stream *AudioStream) AudioStreamMeterGet(direction StreamDirection) StreamMeterStat {
+	if direction == STREAM_DIRECTION_SEND {
+		return stream.txMeter.statGet()
+	}
+	return stream.rxMeter.statGet()
+}
+
+/**
+ * Reset meters of audio stream.
+ * @param direction the direction(s) to reset meters of
+ */
+func (stream *AudioStream) AudioStreamMeterReset(direction StreamDirection) {
+	if (direction & STREAM_DIRECTION_RECEIVE) == STREAM_DIRECTION_RECEIVE {
+		stream.rxMeter.reset()
+	}
+	if (direction & STREAM_DIRECTION_SEND) == STREAM_DIRECTION_SEND {
+		stream.txMeter.reset()
+	}
+}
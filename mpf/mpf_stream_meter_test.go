@@ -0,0 +1,66 @@
+package mpf
+
+import (
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestAudioStreamMeter(t *testing.T) {
+	var talking bool
+	source := AudioStreamCreate(nil, &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			if !talking {
+				return nil
+			}
+			samples := make([]int16, 80)
+			for i := range samples {
+				samples[i] = int16(10000 * math.Sin(2*math.Pi*float64(i)/8))
+			}
+			frame.Type = MEDIA_FRAME_TYPE_AUDIO
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}, SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = CodecLPcmDescriptorCreate(8000, 1)
+
+	if stat := source.AudioStreamMeterGet(STREAM_DIRECTION_RECEIVE); stat.Frames != 0 || stat.Rms != METER_LEVEL_SILENCE {
+		t.Fatalf("meter of new stream %+v", stat)
+	}
+
+	/* no audio received */
+	for i := 0; i < 50; i++ {
+		_ = source.AudioStreamFrameRead(&Frame{})
+	}
+	if stat := source.AudioStreamMeterGet(STREAM_DIRECTION_RECEIVE); stat.Frames != 50 || stat.IdleFrames != 50 || stat.AudioFrames != 0 {
+		t.Fatalf("meter of idle stream %+v", stat)
+	}
+
+	talking = true
+	for i := 0; i < 200; i++ {
+		_ = source.AudioStreamFrameRead(&Frame{})
+	}
+	stat := source.AudioStreamMeterGet(STREAM_DIRECTION_RECEIVE)
+	rms := 20 * math.Log10(10000/math.Sqrt2/32768)
+	if stat.AudioFrames != 200 || stat.IdleFrames != 0 || math.Abs(stat.Rms-rms) > 0.5 || math.Abs(stat.Peak-20*math.Log10(10000.0/32768)) > 0.1 {
+		t.Fatalf("meter of talking stream %+v", stat)
+	}
+
+	/* the peak is held and decays, the RMS falls faster */
+	talking = false
+	for i := 0; i < 10; i++ {
+		_ = source.AudioStreamFrameRead(&Frame{})
+	}
+	held := source.AudioStreamMeterGet(STREAM_DIRECTION_RECEIVE)
+	if held.IdleFrames != 10 || held.Peak >= stat.Peak || held.Peak < stat.Peak-3 || held.Rms > stat.Rms-1 {
+		t.Fatalf("meter after talk %+v", held)
+	}
+	if stat := source.AudioStreamMeterGet(STREAM_DIRECTION_SEND); stat.Frames != 0 {
+		t.Fatalf("meter of frames written %+v", stat)
+	}
+
+	source.AudioStreamMeterReset(STREAM_DIRECTION_DUPLEX)
+	if stat := source.AudioStreamMeterGet(STREAM_DIRECTION_RECEIVE); stat.Frames != 0 || stat.Peak != METER_LEVEL_SILENCE {
+		t.Fatalf("meter after reset %+v", stat)
+	}
+}
@@ -0,0 +1,215 @@
+package mpf
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+/** Frame traced, as read from or written to audio stream */
+type StreamTraceRecord struct {
+	/** STREAM_DIRECTION_RECEIVE for frame read, STREAM_DIRECTION_SEND for frame written */
+	Direction StreamDirection
+	/** Frame type (bitmask of MEDIA_FRAME_TYPE_...) and marker */
+	Type   FrameType
+	Marker FrameMarker
+	/** Size of the frame expected and size of the data of the frame in bytes */
+	Size, DataSize int64
+	/** Descriptor of the audio of the direction, nil if none */
+	Descriptor *CodecDescriptor
+	/** Time the frame is read/written at */
+	Time time.Time
+	/** Time spent by the stream reading/writing the frame */
+	Elapsed time.Duration
+	/** Time since the previous frame of the direction, 0 for the first frame */
+	Interval time.Duration
+	/** Frame, the tracer must neither modify nor retain it */
+	Frame *Frame
+}
+
+/**
+ * Procedure the frames of audio stream are traced to (@see AudioStreamTracerSet()).
+ * It is called in the context of the media processing, so it should not block.
+ * @param stream the audio stream
+ * @param record the frame traced
+ */
+type StreamTraceProc func(stream *AudioStream, record *StreamTraceRecord)
+
+/* Tracer of audio stream, set and replaced at runtime */
+type streamTracer struct {
+	mutex sync.Mutex
+	proc  StreamTraceProc
+	/** Time of the previous frame read and written */
+	lastRX, lastTX time.Time
+	/** Copy of the frame written, traced as written to the stream processing frames in place */
+	frame Frame
+}
+
+/**
+ * Set tracer of audio stream at runtime, every frame read from or written to the stream is traced to it.
+ * @param proc the procedure frames are traced to, nil to disable
+ */
+func (stream *AudioStream) AudioStreamTracerSet(proc StreamTraceProc) {
+	stream.tracer.mutex.Lock()
+	defer stream.tracer.mutex.Unlock()
+	stream.tracer.proc = proc
+	stream.tracer.lastRX = time.Time{}
+	stream.tracer.lastTX = time.Time{}
+}
+
+/* Get tracer procedure, nil if not traced */
+func (tracer *streamTracer) procGet() StreamTraceProc {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	return tracer.proc
+}
+
+/* Trace frame read/written started at start */
+func (tracer *streamTracer) trace(stream *AudioStream, direction StreamDirection, frame *Frame, start time.Time) {
+	now := time.Now()
+	record := &StreamTraceRecord{
+		Direction: direction,
+		Type:      frame.Type,
+		Marker:    frame.Marker,
+		Size:      frame.CodecFrame.Size,
+		Time:      now,
+		Elapsed:   now.Sub(start),
+		Frame:     frame,
+	}
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		record.DataSize = int64(len(codecFrameDataGet(&frame.CodecFrame)))
+	}
+
+	tracer.mutex.Lock()
+	proc := tracer.proc
+	last := &tracer.lastTX
+	record.Descriptor = stream.TXDescriptor
+	if direction == STREAM_DIRECTION_RECEIVE {
+		last = &tracer.lastRX
+		record.Descriptor = stream.RXDescriptor
+	}
+	if !last.IsZero() {
+		record.Interval = start.Sub(*last)
+	}
+	*last = start
+	tracer.mutex.Unlock()
+
+	if proc != nil {
+		proc(stream, record)
+	}
+}
+
+/* Write frame to traced stream, the frame processed in place by the stream is traced as written to it */
+func (stream *AudioStream) tracedFrameWrite(frame *Frame) error {
+	traced := frame
+	if stream.FrameWriteInPlace {
+		traced = &stream.tracer.frame
+		if err := FrameCopy(traced, frame); err != nil {
+			return err
+		}
+	}
+	start := time.Now()
+	var err error
+	if stream.VTable != nil && stream.VTable.WriteFrame != nil {
+		err = stream.VTable.WriteFrame(stream, frame)
+	}
+	stream.tracer.trace(stream, STREAM_DIRECTION_SEND, traced, start)
+	return err
+}
+
+/* Get name of frame type */
+func frameTypeNameGet(frameType FrameType) string {
+	switch {
+	case (frameType & (MEDIA_FRAME_TYPE_AUDIO | MEDIA_FRAME_TYPE_EVENT)) == MEDIA_FRAME_TYPE_AUDIO|MEDIA_FRAME_TYPE_EVENT:
+		return "audio+event"
+	case (frameType & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO:
+		return "audio"
+	case (frameType & MEDIA_FRAME_TYPE_EVENT) == MEDIA_FRAME_TYPE_EVENT:
+		return "event"
+	}
+	return "none"
+}
+
+/**
+ * Create tracer logging a line per frame: the direction, frame type, sizes and timing.
+ * @param logger the logger, nil - standard error
+ * @param name the informative name of the stream logged
+ */
+func LogStreamTracerCreate(logger *log.Logger, name string) StreamTraceProc {
+	if logger == nil {
+		logger = log.New(os.Stderr, "", log.LstdFlags|log.Lmicroseconds)
+	}
+	return func(stream *AudioStream, record *StreamTraceRecord) {
+		direction := "tx"
+		if record.Direction == STREAM_DIRECTION_RECEIVE {
+			direction = "rx"
+		}
+		codec := "-"
+		if record.Descriptor != nil {
+			codec = fmt.Sprintf("%s/%d/%d", record.Descriptor.Name, record.Descriptor.SamplingRate, record.Descriptor.ChannelCount)
+		}
+		logger.Printf("stream %s %s %s %s %d/%d bytes marker %d elapsed %v interval %v",
+			name, direction, codec, frameTypeNameGet(record.Type), record.DataSize, record.Size, record.Marker, record.Elapsed, record.Interval)
+	}
+}
+
+/**
+ * Tracer capturing the (linear) audio of one direction of stream to WAV file.
+ * Frames without audio are written as silence, so that the timing is kept. Other than linear audio is skipped.
+ */
+type WavStreamTracer struct {
+	path      string
+	direction StreamDirection
+	mutex     sync.Mutex
+	file      *wavTapFile
+}
+
+/**
+ * Create WAV tracer, the file is created as the first frame of linear audio is traced.
+ * @param path the path of the file, the directory of which must exist
+ * @param direction the direction to capture (STREAM_DIRECTION_RECEIVE or STREAM_DIRECTION_SEND)
+ */
+func WavStreamTracerCreate(path string, direction StreamDirection) (*WavStreamTracer, error) {
+	if direction != STREAM_DIRECTION_RECEIVE && direction != STREAM_DIRECTION_SEND {
+		return nil, fmt.Errorf("one direction is captured")
+	}
+	info, err := os.Stat(filepath.Dir(path))
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", filepath.Dir(path))
+	}
+	return &WavStreamTracer{path: path, direction: direction}, nil
+}
+
+/** Write frame of the direction to the file, as StreamTraceProc */
+func (tracer *WavStreamTracer) WavStreamTraceProc(stream *AudioStream, record *StreamTraceRecord) {
+	if record.Direction != tracer.direction || record.Descriptor == nil || !CodecLPcmDescriptorMatch(record.Descriptor) {
+		return
+	}
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	if tracer.file == nil {
+		var err error
+		if tracer.file, err = wavTapFileCreate(tracer.path, record.Descriptor); err != nil {
+			return
+		}
+	}
+	tracer.file.write(record.Frame)
+}
+
+/** Close WAV tracer, the header of the file written is completed */
+func (tracer *WavStreamTracer) WavStreamTracerClose() error {
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	if tracer.file == nil {
+		return nil
+	}
+	err := tracer.file.close()
+	tracer.file = nil
+	return err
+}
@@ -0,0 +1,62 @@
+package mpf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStreamTracer(t *testing.T) {
+	full := -1
+	source := mixerTestSource(1000, &full)
+	var written []int16
+	gain, _ := GainCreate(-6)
+	sink := FrameFilterStreamCreate(multiplierTestSink(8000, &written), gain)
+
+	output := &bytes.Buffer{}
+	source.AudioStreamTracerSet(LogStreamTracerCreate(log.New(output, "", 0), "rtp"))
+	path := filepath.Join(t.TempDir(), "recognizer.wav")
+	wav, err := WavStreamTracerCreate(path, STREAM_DIRECTION_SEND)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sink.AudioStreamTracerSet(wav.WavStreamTraceProc)
+
+	bridge, err := BridgeCreate(source, sink, CodecManagerDefaultCreate(), "trace")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := bridge.ObjectProcess(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	/* the tracer is replaced at runtime */
+	source.AudioStreamTracerSet(nil)
+	if err := bridge.ObjectProcess(); err != nil {
+		t.Fatal(err)
+	}
+	if err := wav.WavStreamTracerClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "stream rtp rx LPCM/8000/1 audio 160/160 bytes") {
+		t.Fatalf("logged %q", output.String())
+	}
+	/* the audio written is captured as is, not as processed in place by the filter */
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, err := WavHeaderRead(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if header.DataSize != 4*160 || data[header.DataOffset] != 0xe8 || data[header.DataOffset+1] != 0x03 || written[0] != 501 {
+		t.Fatalf("captured %d bytes %x, written %v", header.DataSize, data[header.DataOffset:header.DataOffset+2], written[:1])
+	}
+}
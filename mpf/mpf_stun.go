@@ -0,0 +1,384 @@
+package mpf
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"net"
+	"time"
+)
+
+/** Size of STUN message header (RFC 5389) */
+const STUN_HEADER_SIZE = 20
+
+/** Magic cookie of STUN message, distinguishes STUN from the classic one (RFC 3489) and from RTP */
+const STUN_MAGIC_COOKIE = 0x2112A442
+
+/** XOR-ed into CRC-32 of FINGERPRINT attribute */
+const STUN_FINGERPRINT_XOR = 0x5354554E
+
+/** STUN message types (of Binding method) */
+const (
+	STUN_BINDING_REQUEST  = 0x0001
+	STUN_BINDING_SUCCESS  = 0x0101
+	STUN_BINDING_ERROR    = 0x0111
+	STUN_BINDING_INDICATE = 0x0011
+)
+
+/** STUN attribute types (RFC 5389, RFC 8445) */
+const (
+	STUN_ATTR_MAPPED_ADDRESS     = 0x0001
+	STUN_ATTR_USERNAME           = 0x0006
+	STUN_ATTR_MESSAGE_INTEGRITY  = 0x0008
+	STUN_ATTR_ERROR_CODE         = 0x0009
+	STUN_ATTR_XOR_MAPPED_ADDRESS = 0x0020
+	STUN_ATTR_PRIORITY           = 0x0024
+	STUN_ATTR_USE_CANDIDATE      = 0x0025
+	STUN_ATTR_FINGERPRINT        = 0x8028
+	STUN_ATTR_ICE_CONTROLLED     = 0x8029
+	STUN_ATTR_ICE_CONTROLLING    = 0x802A
+)
+
+/** STUN error codes */
+const (
+	STUN_ERROR_BAD_REQUEST   = 400
+	STUN_ERROR_UNAUTHORIZED  = 401
+	STUN_ERROR_ROLE_CONFLICT = 487
+)
+
+/** Size of MESSAGE-INTEGRITY attribute (HMAC-SHA1) along with its header */
+const stunIntegritySize = 4 + sha1.Size
+
+/** Size of FINGERPRINT attribute along with its header */
+const stunFingerprintSize = 4 + 4
+
+/** STUN attribute */
+type StunAttrib struct {
+	/** Attribute type */
+	Type uint16
+	/** Attribute value (not padded) */
+	Value []byte
+}
+
+/** STUN message */
+type StunMessage struct {
+	/** Message type (method and class) */
+	Type uint16
+	/** Transaction ID */
+	TransactionId [12]byte
+	/** Attributes, MESSAGE-INTEGRITY and FINGERPRINT are added as the message is marshaled */
+	Attribs []StunAttrib
+
+	/* Message parsed, the integrity and fingerprint are checked against */
+	raw []byte
+}
+
+/**
+ * Check whether packet received on RTP (or RTCP) port is STUN message (RFC 7983):
+ * the first two bits are zero and the magic cookie is set.
+ * @param data the packet received
+ */
+func StunMessageCheck(data []byte) bool {
+	return len(data) >= STUN_HEADER_SIZE && data[0]&0xC0 == 0 &&
+		binary.BigEndian.Uint32(data[4:]) == STUN_MAGIC_COOKIE
+}
+
+/**
+ * Create STUN message of random transaction ID.
+ * @param msgType the message type
+ */
+func StunMessageCreate(msgType uint16) (*StunMessage, error) {
+	message := &StunMessage{Type: msgType}
+	if _, err := rand.Read(message.TransactionId[:]); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+/** Add attribute to STUN message */
+func (m *StunMessage) StunMessageAttribAdd(attribType uint16, value []byte) {
+	m.Attribs = append(m.Attribs, StunAttrib{Type: attribType, Value: value})
+}
+
+/** Get value of the first attribute of type, nil if none */
+func (m *StunMessage) StunMessageAttribGet(attribType uint16) []byte {
+	for i := range m.Attribs {
+		if m.Attribs[i].Type == attribType {
+			return m.Attribs[i].Value
+		}
+	}
+	return nil
+}
+
+/** Check whether STUN message has attribute of type */
+func (m *StunMessage) StunMessageAttribCheck(attribType uint16) bool {
+	for i := range m.Attribs {
+		if m.Attribs[i].Type == attribType {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Marshal STUN message.
+ * @param key the key of MESSAGE-INTEGRITY (the password of short-term credentials), not added if nil
+ * @param fingerprint whether FINGERPRINT is added
+ */
+func (m *StunMessage) StunMessageMarshal(key []byte, fingerprint bool) []byte {
+	data := make([]byte, STUN_HEADER_SIZE, 128)
+	binary.BigEndian.PutUint16(data[0:], m.Type)
+	binary.BigEndian.PutUint32(data[4:], STUN_MAGIC_COOKIE)
+	copy(data[8:], m.TransactionId[:])
+	for _, attrib := range m.Attribs {
+		data = stunAttribAppend(data, attrib.Type, attrib.Value)
+	}
+	if key != nil {
+		/* the length covers the integrity itself, not the fingerprint following */
+		binary.BigEndian.PutUint16(data[2:], uint16(len(data)-STUN_HEADER_SIZE+stunIntegritySize))
+		mac := hmac.New(sha1.New, key)
+		mac.Write(data)
+		data = stunAttribAppend(data, STUN_ATTR_MESSAGE_INTEGRITY, mac.Sum(nil))
+	}
+	if fingerprint {
+		binary.BigEndian.PutUint16(data[2:], uint16(len(data)-STUN_HEADER_SIZE+stunFingerprintSize))
+		crc := make([]byte, 4)
+		binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(data)^STUN_FINGERPRINT_XOR)
+		data = stunAttribAppend(data, STUN_ATTR_FINGERPRINT, crc)
+	}
+	binary.BigEndian.PutUint16(data[2:], uint16(len(data)-STUN_HEADER_SIZE))
+	return data
+}
+
+/* Append attribute padded to 4 bytes */
+func stunAttribAppend(data []byte, attribType uint16, value []byte) []byte {
+	var header [4]byte
+	binary.BigEndian.PutUint16(header[0:], attribType)
+	binary.BigEndian.PutUint16(header[2:], uint16(len(value)))
+	data = append(append(data, header[:]...), value...)
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+/**
+ * Parse STUN message, the attributes following MESSAGE-INTEGRITY other than FINGERPRINT are ignored.
+ * @param data the packet received
+ */
+func StunMessageParse(data []byte) (*StunMessage, error) {
+	if !StunMessageCheck(data) {
+		return nil, fmt.Errorf("not stun message")
+	}
+	length := int(binary.BigEndian.Uint16(data[2:]))
+	if length%4 != 0 || STUN_HEADER_SIZE+length > len(data) {
+		return nil, fmt.Errorf("invalid length %d of stun message", length)
+	}
+	message := &StunMessage{Type: binary.BigEndian.Uint16(data[0:]), raw: data[:STUN_HEADER_SIZE+length]}
+	copy(message.TransactionId[:], data[8:STUN_HEADER_SIZE])
+	integrity := false
+	for body := data[STUN_HEADER_SIZE : STUN_HEADER_SIZE+length]; len(body) > 0; {
+		if len(body) < 4 {
+			return nil, fmt.Errorf("stun attribute is truncated")
+		}
+		attribType := binary.BigEndian.Uint16(body[0:])
+		size := int(binary.BigEndian.Uint16(body[2:]))
+		padded := (size + 3) &^ 3
+		if 4+padded > len(body) {
+			return nil, fmt.Errorf("stun attribute 0x%04x is truncated", attribType)
+		}
+		if !integrity || attribType == STUN_ATTR_FINGERPRINT {
+			message.Attribs = append(message.Attribs, StunAttrib{Type: attribType, Value: body[4 : 4+size]})
+		}
+		if attribType == STUN_ATTR_MESSAGE_INTEGRITY {
+			integrity = true
+		}
+		body = body[4+padded:]
+	}
+	return message, nil
+}
+
+/* Get offset of attribute of type in the message parsed, -1 if none */
+func (m *StunMessage) stunAttribOffsetGet(attribType uint16) int {
+	for offset := STUN_HEADER_SIZE; offset+4 <= len(m.raw); {
+		if binary.BigEndian.Uint16(m.raw[offset:]) == attribType {
+			return offset
+		}
+		offset += 4 + (int(binary.BigEndian.Uint16(m.raw[offset+2:]))+3)&^3
+	}
+	return -1
+}
+
+/**
+ * Check MESSAGE-INTEGRITY of STUN message parsed.
+ * @param key the key (the password of short-term credentials)
+ */
+func (m *StunMessage) StunMessageIntegrityCheck(key []byte) bool {
+	offset := m.stunAttribOffsetGet(STUN_ATTR_MESSAGE_INTEGRITY)
+	if offset < 0 || offset+stunIntegritySize > len(m.raw) {
+		return false
+	}
+	data := append([]byte(nil), m.raw[:offset]...)
+	binary.BigEndian.PutUint16(data[2:], uint16(offset-STUN_HEADER_SIZE+stunIntegritySize))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), m.raw[offset+4:offset+stunIntegritySize])
+}
+
+/** Check FINGERPRINT of STUN message parsed, true if there is no fingerprint */
+func (m *StunMessage) StunMessageFingerprintCheck() bool {
+	offset := m.stunAttribOffsetGet(STUN_ATTR_FINGERPRINT)
+	if offset < 0 {
+		return true
+	}
+	if offset+stunFingerprintSize > len(m.raw) {
+		return false
+	}
+	return crc32.ChecksumIEEE(m.raw[:offset])^STUN_FINGERPRINT_XOR == binary.BigEndian.Uint32(m.raw[offset+4:])
+}
+
+/**
+ * Make value of XOR-MAPPED-ADDRESS attribute.
+ * @param addr the address mapped
+ * @param transactionId the transaction ID of the message, IPv6 address is XOR-ed with
+ */
+func StunXorAddressMake(addr *net.UDPAddr, transactionId [12]byte) []byte {
+	var cookie [16]byte
+	binary.BigEndian.PutUint32(cookie[0:], STUN_MAGIC_COOKIE)
+	copy(cookie[4:], transactionId[:])
+	ip := addr.IP.To4()
+	family := byte(0x01)
+	if ip == nil {
+		ip = addr.IP.To16()
+		family = 0x02
+	}
+	value := make([]byte, 4+len(ip))
+	value[1] = family
+	binary.BigEndian.PutUint16(value[2:], uint16(addr.Port)^uint16(STUN_MAGIC_COOKIE>>16))
+	for i := range ip {
+		value[4+i] = ip[i] ^ cookie[i]
+	}
+	return value
+}
+
+/**
+ * Parse value of (XOR-)MAPPED-ADDRESS attribute.
+ * @param value the value of the attribute
+ * @param xor whether the address is XOR-ed (XOR-MAPPED-ADDRESS)
+ * @param transactionId the transaction ID of the message
+ */
+func StunAddressParse(value []byte, xor bool, transactionId [12]byte) (*net.UDPAddr, error) {
+	if len(value) < 4 {
+		return nil, fmt.Errorf("stun address is truncated")
+	}
+	size := 0
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil, fmt.Errorf("unknown stun address family %d", value[1])
+	}
+	if len(value) < 4+size {
+		return nil, fmt.Errorf("stun address is truncated")
+	}
+	addr := &net.UDPAddr{IP: append(net.IP(nil), value[4:4+size]...), Port: int(binary.BigEndian.Uint16(value[2:]))}
+	if xor {
+		var cookie [16]byte
+		binary.BigEndian.PutUint32(cookie[0:], STUN_MAGIC_COOKIE)
+		copy(cookie[4:], transactionId[:])
+		addr.Port ^= STUN_MAGIC_COOKIE >> 16
+		for i := range addr.IP {
+			addr.IP[i] ^= cookie[i]
+		}
+	}
+	return addr, nil
+}
+
+/** Make value of ERROR-CODE attribute */
+func StunErrorCodeMake(code int, reason string) []byte {
+	value := []byte{0, 0, byte(code / 100), byte(code % 100)}
+	return append(value, reason...)
+}
+
+/**
+ * Send Binding request to STUN server and wait for the response, return the address mapped,
+ * i.e. the address of the socket as seen by the server (behind NAT).
+ * @param socket the socket to send from, not read by anyone else meanwhile
+ * @param server the address of the STUN server
+ * @param timeout the time to wait for the response, the request is retransmitted meanwhile
+ */
+func StunBindingRequest(socket *net.UDPConn, server *net.UDPAddr, timeout time.Duration) (*net.UDPAddr, error) {
+	request, err := StunMessageCreate(STUN_BINDING_REQUEST)
+	if err != nil {
+		return nil, err
+	}
+	data := request.StunMessageMarshal(nil, true)
+	buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+	deadline := time.Now().Add(timeout)
+	/* RFC 5389 7.2.1, the request is retransmitted at the doubling interval */
+	for rto := 100 * time.Millisecond; time.Now().Before(deadline); rto *= 2 {
+		if _, err := socket.WriteToUDP(data, server); err != nil {
+			return nil, err
+		}
+		wait := time.Now().Add(rto)
+		if wait.After(deadline) {
+			wait = deadline
+		}
+		if err := socket.SetReadDeadline(wait); err != nil {
+			return nil, err
+		}
+		for {
+			n, _, err := socket.ReadFromUDP(buffer)
+			if err != nil {
+				break
+			}
+			response, err := StunMessageParse(buffer[:n])
+			if err != nil || response.TransactionId != request.TransactionId {
+				continue
+			}
+			_ = socket.SetReadDeadline(time.Time{})
+			if response.Type != STUN_BINDING_SUCCESS {
+				return nil, fmt.Errorf("stun binding request is rejected")
+			}
+			if value := response.StunMessageAttribGet(STUN_ATTR_XOR_MAPPED_ADDRESS); value != nil {
+				return StunAddressParse(value, true, response.TransactionId)
+			}
+			if value := response.StunMessageAttribGet(STUN_ATTR_MAPPED_ADDRESS); value != nil {
+				return StunAddressParse(value, false, response.TransactionId)
+			}
+			return nil, fmt.Errorf("no mapped address in stun binding response")
+		}
+	}
+	_ = socket.SetReadDeadline(time.Time{})
+	return nil, fmt.Errorf("no response of stun server %v", server)
+}
+
+/**
+ * Discover external (NAT) IP address of RTP config by STUN server, the address is advertised in SDP since then.
+ * The address discovered is reachable for 1:1 NAT (e.g. the public IP address of Kubernetes node),
+ * the port mapped is not kept for the streams.
+ * @param server the address (host:port) of the STUN server
+ * @param timeout the time to wait for the response
+ */
+func (c *RtpConfig) RtpConfigExtIpDiscover(server string, timeout time.Duration) error {
+	serverAddr, err := net.ResolveUDPAddr("udp", server)
+	if err != nil {
+		return err
+	}
+	socket, err := rtpSocketBind(c.ip, 0)
+	if err != nil {
+		return err
+	}
+	defer socket.Close()
+	addr, err := StunBindingRequest(socket, serverAddr, timeout)
+	if err != nil {
+		return err
+	}
+	c.extIp = addr.IP.String()
+	return nil
+}
@@ -0,0 +1,97 @@
+package mpf
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStunMessage(t *testing.T) {
+	request, err := StunMessageCreate(STUN_BINDING_REQUEST)
+	if err != nil {
+		t.Fatal(err)
+	}
+	request.StunMessageAttribAdd(STUN_ATTR_USERNAME, []byte("local:remote"))
+	request.StunMessageAttribAdd(STUN_ATTR_USE_CANDIDATE, nil)
+	data := request.StunMessageMarshal([]byte("password"), true)
+	if !StunMessageCheck(data) || RtcpMuxPacketCheck(data) || len(data)%4 != 0 {
+		t.Fatal("stun message is not distinguished")
+	}
+	parsed, err := StunMessageParse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.Type != STUN_BINDING_REQUEST || parsed.TransactionId != request.TransactionId ||
+		string(parsed.StunMessageAttribGet(STUN_ATTR_USERNAME)) != "local:remote" || !parsed.StunMessageAttribCheck(STUN_ATTR_USE_CANDIDATE) {
+		t.Fatalf("stun message %+v is parsed as %+v", request, parsed)
+	}
+	if !parsed.StunMessageIntegrityCheck([]byte("password")) || parsed.StunMessageIntegrityCheck([]byte("wrong")) {
+		t.Fatal("message integrity is not checked")
+	}
+	if !parsed.StunMessageFingerprintCheck() {
+		t.Fatal("fingerprint is not checked")
+	}
+	/* tampered */
+	data[STUN_HEADER_SIZE+4] ^= 1
+	if parsed, _ = StunMessageParse(data); parsed.StunMessageIntegrityCheck([]byte("password")) || parsed.StunMessageFingerprintCheck() {
+		t.Fatal("tampered message is accepted")
+	}
+
+	for _, addr := range []*net.UDPAddr{{IP: net.IPv4(192, 0, 2, 1), Port: 32853}, {IP: net.ParseIP("2001:db8::1"), Port: 5000}} {
+		parsed, err := StunAddressParse(StunXorAddressMake(addr, request.TransactionId), true, request.TransactionId)
+		if err != nil || !parsed.IP.Equal(addr.IP) || parsed.Port != addr.Port {
+			t.Fatalf("address %v is parsed as %v: %v", addr, parsed, err)
+		}
+	}
+}
+
+func TestStunBindingRequest(t *testing.T) {
+	/* the server maps the address to the external one, the first request is lost */
+	server, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	external := &net.UDPAddr{IP: net.IPv4(203, 0, 113, 7), Port: 40000}
+	go func() {
+		buffer := make([]byte, RTP_PACKET_SIZE_MAX)
+		for i := 0; ; i++ {
+			n, addr, err := server.ReadFromUDP(buffer)
+			if err != nil {
+				return
+			}
+			request, err := StunMessageParse(buffer[:n])
+			if err != nil || i == 0 {
+				continue
+			}
+			response := &StunMessage{Type: STUN_BINDING_SUCCESS, TransactionId: request.TransactionId}
+			response.StunMessageAttribAdd(STUN_ATTR_XOR_MAPPED_ADDRESS, StunXorAddressMake(external, request.TransactionId))
+			server.WriteToUDP(response.StunMessageMarshal(nil, true), addr)
+		}
+	}()
+
+	config := RtpConfigAlloc()
+	if err := config.RtpConfigIpSet("127.0.0.1", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.RtpConfigExtIpDiscover(server.LocalAddr().String(), 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if config.extIp != "203.0.113.7" {
+		t.Fatalf("external ip [%s] is discovered", config.extIp)
+	}
+
+	/* no response */
+	socket, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer socket.Close()
+	if _, err := StunBindingRequest(socket, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9}, 300*time.Millisecond); err == nil {
+		t.Fatal("binding request is responded")
+	}
+	if _, err := StunAddressParse(bytes.Repeat([]byte{3}, 8), false, [12]byte{}); err == nil {
+		t.Fatal("address of unknown family is parsed")
+	}
+}
@@ -0,0 +1,163 @@
+package mpf
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/**
+ * Procedure the frames tapped are forked to, it must neither modify nor retain the frame.
+ * @param context the context of the termination
+ * @param termination the termination the frame is written to
+ * @param descriptor the descriptor of the audio written
+ * @param frame the frame
+ */
+type FrameTapProc func(context *Context, termination *Termination, descriptor *CodecDescriptor, frame *Frame)
+
+/**
+ * Create stream forking every frame written to the sink to the procedure, the frame is passed on as is.
+ * @param sink the sink
+ * @param proc the procedure
+ */
+func TapStreamCreate(sink *AudioStream, proc func(frame *Frame)) *AudioStream {
+	if sink == nil || proc == nil {
+		return nil
+	}
+	vtable := &AudioStreamVTable{
+		Destroy: func(*AudioStream) error { return AudioStreamDestroy(sink) },
+		OpenTX:  func(_ *AudioStream, codec *Codec) error { return sink.AudioStreamTXOpen(codec) },
+		CloseTX: func(*AudioStream) error { return sink.AudioStreamTXClose() },
+		WriteFrame: func(_ *AudioStream, frame *Frame) error {
+			proc(frame)
+			return sink.AudioStreamFrameWrite(frame)
+		},
+	}
+	stream := AudioStreamCreate(sink, vtable, StreamCapabilitiesClone(sink.Capabilities))
+	if stream == nil {
+		return nil
+	}
+	stream.TXDescriptor = sink.TXDescriptor
+	stream.TXEventDescriptor = sink.TXEventDescriptor
+	stream.FrameWriteInPlace = sink.FrameWriteInPlace
+	return stream
+}
+
+/**
+ * Tap writing the (linear) audio written to terminations to WAV files, one per termination,
+ * named by the context and the termination (e.g. "call-1-recognizer.wav").
+ * Frames without audio are written as silence, so that the timing is kept. Other than linear audio is skipped.
+ */
+type WavTap struct {
+	dir   string
+	files map[*Termination]*wavTapFile
+	mutex sync.Mutex
+}
+
+/* WAV file of termination tapped */
+type wavTapFile struct {
+	file       *os.File
+	descriptor *CodecDescriptor
+	size       int64
+}
+
+/**
+ * Create WAV tap.
+ * @param dir the existing directory to write files to
+ */
+func WavTapCreate(dir string) (*WavTap, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+	return &WavTap{dir: dir, files: make(map[*Termination]*wavTapFile)}, nil
+}
+
+/* Get file name of termination tapped */
+func wavTapFileNameGet(context *Context, termination *Termination) string {
+	name := termination.Name
+	if name == "" {
+		name = strconv.FormatInt(termination.slot, 10)
+	}
+	if context != nil && context.Name != "" {
+		name = context.Name + "-" + name
+	}
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(name) + ".wav"
+}
+
+/** Write frame to the file of termination, as FrameTapProc */
+func (tap *WavTap) WavTapProc(context *Context, termination *Termination, descriptor *CodecDescriptor, frame *Frame) {
+	if descriptor == nil || !CodecLPcmDescriptorMatch(descriptor) {
+		return
+	}
+	tap.mutex.Lock()
+	defer tap.mutex.Unlock()
+	f := tap.files[termination]
+	if f == nil {
+		var err error
+		if f, err = wavTapFileCreate(filepath.Join(tap.dir, wavTapFileNameGet(context, termination)), descriptor); err != nil {
+			return
+		}
+		tap.files[termination] = f
+	}
+	f.write(frame)
+}
+
+/* Create WAV file of the linear audio, the header is completed as the file is closed */
+func wavTapFileCreate(path string, descriptor *CodecDescriptor) (*wavTapFile, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := WavHeaderWrite(file, descriptor.SamplingRate, descriptor.ChannelCount, 0); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &wavTapFile{file: file, descriptor: descriptor}, nil
+}
+
+/* Write audio of frame to file, frame without audio is written as silence */
+func (f *wavTapFile) write(frame *Frame) {
+	var data []byte
+	if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+		data = codecFrameDataGet(&frame.CodecFrame)
+	} else {
+		data = make([]byte, f.descriptor.CodecLinearFrameSizeGet())
+	}
+	if n, err := f.file.Write(data); err == nil {
+		f.size += int64(n)
+	}
+}
+
+/* Complete header and close file */
+func (f *wavTapFile) close() error {
+	var err error
+	if _, err = f.file.Seek(0, io.SeekStart); err == nil {
+		err = WavHeaderWrite(f.file, f.descriptor.SamplingRate, f.descriptor.ChannelCount, f.size)
+	}
+	if e := f.file.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
+}
+
+/** Close WAV tap, the headers of the files written are completed */
+func (tap *WavTap) WavTapClose() error {
+	tap.mutex.Lock()
+	defer tap.mutex.Unlock()
+	var err error
+	for termination, f := range tap.files {
+		if e := f.close(); e != nil && err == nil {
+			err = e
+		}
+		delete(tap.files, termination)
+	}
+	return err
+}
@@ -0,0 +1,109 @@
+package mpf
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestContextTap(t *testing.T) {
+	descriptor := CodecLPcmDescriptorCreate(8000, 1)
+	factory := ContextFactoryCreate()
+	tap, err := WavTapCreate(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory.ContextFactoryTapSet("call-1", tap.WavTapProc)
+
+	contextCreate := func(name string) (*Context, *bytes.Buffer) {
+		var tick int16
+		source := AudioStreamCreate(nil, &AudioStreamVTable{
+			ReadFrame: func(stream *AudioStream, frame *Frame) error {
+				tick++
+				if tick%2 == 0 {
+					/* no audio */
+					return nil
+				}
+				samples := make([]int16, 80)
+				for i := range samples {
+					samples[i] = tick*100 + int16(i)
+				}
+				frame.Type = MEDIA_FRAME_TYPE_AUDIO
+				return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+			},
+		}, SourceStreamCapabilitiesCreate())
+		source.RXDescriptor = descriptor
+		received := &bytes.Buffer{}
+		sink := AudioStreamCreate(nil, &AudioStreamVTable{
+			WriteFrame: func(stream *AudioStream, frame *Frame) error {
+				if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+					received.Write(codecFrameDataGet(&frame.CodecFrame))
+				} else {
+					received.Write(make([]byte, 160))
+				}
+				return nil
+			},
+		}, SinkStreamCapabilitiesCreate())
+		sink.TXDescriptor = descriptor
+
+		context := factory.ContextCreate(name, nil, 2)
+		rtp := TerminationBaseCreate(nil, nil, nil, source, nil)
+		rtp.Name = "rtp"
+		recognizer := TerminationBaseCreate(nil, nil, nil, sink, nil)
+		recognizer.Name = "recognizer"
+		context.ContextTerminationAdd(rtp)
+		context.ContextTerminationAdd(recognizer)
+		context.ContextAssociationAdd(rtp, recognizer)
+		if err := context.ContextTopologyApply(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < 10; i++ {
+			if err := context.ContextProcess(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return context, received
+	}
+
+	_, received := contextCreate("call-1")
+	contextCreate("call-2")
+	if err := tap.WavTapClose(); err != nil {
+		t.Fatal(err)
+	}
+
+	/* exactly what the recognizer received is captured, the context of other name is not tapped */
+	file, err := os.Open(filepath.Join(tap.dir, "call-1-recognizer.wav"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	header, err := WavHeaderRead(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadAll(file)
+	if header.SamplingRate != 8000 || header.DataSize != 1600 || !bytes.Equal(data, received.Bytes()) {
+		t.Fatalf("%d bytes at %d Hz are captured, want %d", header.DataSize, header.SamplingRate, received.Len())
+	}
+	if files, _ := ioutil.ReadDir(tap.dir); len(files) != 1 {
+		t.Fatalf("%d files are captured", len(files))
+	}
+
+	/* the tap of context has precedence */
+	var frames int
+	context, _ := contextCreate("call-3")
+	context.ContextTapSet(func(context *Context, termination *Termination, descriptor *CodecDescriptor, frame *Frame) {
+		frames++
+	})
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	context.ContextProcess()
+	if frames != 1 {
+		t.Fatalf("%d frames are tapped", frames)
+	}
+}
@@ -58,7 +58,21 @@ type Termination struct {
  */
 func TerminationBaseCreate(terminationFactory *TerminationFactory, obj interface{},
 vtable *TerminationVTable, audioStream *AudioStream, videoStream *VideoStream) *Termination {
-	return nil
+	termination := &Termination{
+		Obj:                obj,
+		terminationFactory: terminationFactory,
+		vtable:             vtable,
+		slot:               0,
+		audioStream:        audioStream,
+		videoStream:        videoStream,
+	}
+	if audioStream != nil {
+		audioStream.termination = termination
+	}
+	if videoStream != nil {
+		videoStream.termination = termination
+	}
+	return termination
 }
 
 /**
@@ -67,6 +81,9 @@ vtable *TerminationVTable, audioStream *AudioStream, videoStream *VideoStream) *
  * @param descriptor the termination specific descriptor
  */
 func (t *Termination) TerminationAdd(descriptor interface{}) error {
+	if t.vtable != nil && t.vtable.Add != nil {
+		return t.vtable.Add(t, descriptor)
+	}
 	return nil
 }
 
@@ -76,6 +93,9 @@ func (t *Termination) TerminationAdd(descriptor interface{}) error {
  * @param descriptor the termination specific descriptor
  */
 func (t *Termination) TerminationModify(descriptor interface{}) error {
+	if t.vtable != nil && t.vtable.Modify != nil {
+		return t.vtable.Modify(t, descriptor)
+	}
 	return nil
 }
 
@@ -84,5 +104,8 @@ func (t *Termination) TerminationModify(descriptor interface{}) error {
  * @param termination the termination to subtract
  */
 func (t *Termination) TerminationSubtract() error {
+	if t.vtable != nil && t.vtable.Subtract != nil {
+		return t.vtable.Subtract(t)
+	}
 	return nil
 }
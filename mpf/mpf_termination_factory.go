@@ -1,5 +1,7 @@
 package mpf
 
+import "fmt"
+
 /** MPF termination factory */
 type TerminationFactory struct {
 	/** Virtual create */
@@ -14,6 +16,9 @@ type TerminationFactory struct {
  * @param media_engine the media engine to assign
  */
 func TerminationFactoryEngineAssign(terminationFactory *TerminationFactory, mediaEngine *Engine) error {
+	if terminationFactory.AssignEngine != nil {
+		return terminationFactory.AssignEngine(terminationFactory, mediaEngine)
+	}
 	return nil
 }
 
@@ -24,6 +29,9 @@ func TerminationFactoryEngineAssign(terminationFactory *TerminationFactory, medi
  * @param pool the pool to allocate memory from
  */
 func (tf *TerminationFactory) TerminationCreate(obj interface{}) *Termination {
+	if tf.CreateTermination != nil {
+		return tf.CreateTermination(tf, obj)
+	}
 	return nil
 }
 
@@ -35,7 +43,7 @@ func (tf *TerminationFactory) TerminationCreate(obj interface{}) *Termination {
  * @param pool the pool to allocate memory from
  */
 func RawTerminationCreate(obj interface{}, audioStream *AudioStream, videoStream *VideoStream) *Termination {
-	return nil
+	return TerminationBaseCreate(nil, obj, nil, audioStream, videoStream)
 }
 
 /**
@@ -43,6 +51,19 @@ func RawTerminationCreate(obj interface{}, audioStream *AudioStream, videoStream
  * @param termination the termination to destroy
  */
 func TerminationDestroy(termination *Termination) error {
+	if termination.vtable != nil && termination.vtable.Destroy != nil {
+		if err := termination.vtable.Destroy(termination); err != nil {
+			return err
+		}
+	}
+	if termination.audioStream != nil {
+		if err := AudioStreamDestroy(termination.audioStream); err != nil {
+			return err
+		}
+	}
+	if termination.videoStream != nil {
+		return VideoStreamDestroy(termination.videoStream)
+	}
 	return nil
 }
 
@@ -51,7 +72,10 @@ func TerminationDestroy(termination *Termination) error {
  * @param termination the termination to get name of
  */
 func TerminationNameGet(termination *Termination) string {
-	return ""
+	if termination == nil {
+		return ""
+	}
+	return termination.Name
 }
 
 /**
@@ -59,7 +83,7 @@ func TerminationNameGet(termination *Termination) string {
  * @param termination the termination to get object from
  */
 func (t *Termination) TerminationObjectGet() interface{} {
-	return nil
+	return t.Obj
 }
 
 /**
@@ -70,6 +94,18 @@ func (t *Termination) TerminationAudioStreamGet() *AudioStream {
 	return t.audioStream
 }
 
+/**
+ * Change direction of the audio stream of termination, e.g. to "sendonly" on hold.
+ * The context of the termination must be updated then, @see ContextTerminationDirectionSet().
+ * @param direction the direction (STREAM_DIRECTION_NONE - inactive)
+ */
+func (t *Termination) TerminationDirectionSet(direction StreamDirection) error {
+	if t.audioStream == nil {
+		return fmt.Errorf("termination has no audio stream")
+	}
+	return t.audioStream.AudioStreamDirectionSet(direction)
+}
+
 /**
  * Get video stream.
  * @param termination the termination to get video stream from
@@ -0,0 +1,227 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Window duration of time-stretch segments in msec (overlapped by half) */
+const TIME_STRETCH_WINDOW = 30
+
+/** Max deviation of the analysis position searched for the best overlap in msec */
+const TIME_STRETCH_TOLERANCE = 8
+
+/** Supported range of playback rate */
+const (
+	TIME_STRETCH_RATE_MIN = 0.5
+	TIME_STRETCH_RATE_MAX = 2.0
+)
+
+/**
+ * WSOLA (waveform similarity overlap-add) time-stretch filter changing playback rate of speech
+ * while keeping its pitch. Segments are picked around the nominal analysis position at the place
+ * the waveform continues the previous segment the most similarly, and overlap-added by Hann windows.
+ */
+type TimeStretch struct {
+	rate      float64   // Playback rate (>1 - faster, <1 - slower)
+	window    int       // Window length in samples
+	hop       int       // Synthesis hop (half of the window) in samples
+	tolerance int       // Max deviation of analysis position in samples
+	hann      []float64 // Window function
+
+	input    []float64 // Buffered input samples
+	inOffset int64     // Absolute position of the first buffered input sample
+	analysis float64   // Absolute nominal analysis position of the next segment
+	prevPos  int64     // Absolute position of the previous segment (-1 if none)
+	tail     []float64 // Second half of the previous windowed segment to overlap with
+
+	output []int16 // Output samples not read yet (framed reading)
+}
+
+/**
+ * Create time-stretch filter.
+ * @param descriptor the descriptor of the (linear, mono) audio to stretch
+ */
+func TimeStretchCreate(descriptor *CodecDescriptor) (*TimeStretch, error) {
+	if descriptor.ChannelCount != 1 {
+		return nil, fmt.Errorf("time-stretch of %d channels is not supported", descriptor.ChannelCount)
+	}
+	if descriptor.SamplingRate == 0 {
+		return nil, fmt.Errorf("invalid sampling rate")
+	}
+	window := int(descriptor.SamplingRate) * TIME_STRETCH_WINDOW / 1000
+	window -= window % 2
+	ts := &TimeStretch{
+		rate:      1.0,
+		window:    window,
+		hop:       window / 2,
+		tolerance: int(descriptor.SamplingRate) * TIME_STRETCH_TOLERANCE / 1000,
+		hann:      make([]float64, window),
+		prevPos:   -1,
+		tail:      make([]float64, window/2),
+	}
+	/* periodic Hann window, halves overlapped by the hop sum to 1 */
+	for i := range ts.hann {
+		ts.hann[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(window))
+	}
+	return ts, nil
+}
+
+/** Set playback rate, applied from the next segment on */
+func (ts *TimeStretch) TimeStretchRateSet(rate float64) error {
+	if math.IsNaN(rate) || rate < TIME_STRETCH_RATE_MIN || rate > TIME_STRETCH_RATE_MAX {
+		return fmt.Errorf("time-stretch rate %.2f is out of range [%.1f, %.1f]", rate, TIME_STRETCH_RATE_MIN, TIME_STRETCH_RATE_MAX)
+	}
+	ts.rate = rate
+	return nil
+}
+
+/** Get playback rate */
+func (ts *TimeStretch) TimeStretchRateGet() float64 {
+	return ts.rate
+}
+
+/* Get buffered input sample by absolute position */
+func (ts *TimeStretch) sample(pos int64) float64 {
+	return ts.input[pos-ts.inOffset]
+}
+
+/* Find position around the nominal one, the segment at which matches the natural continuation of the previous one best */
+func (ts *TimeStretch) segmentSeek(nominal int64) int64 {
+	if ts.prevPos < 0 {
+		return nominal
+	}
+	natural := ts.prevPos + int64(ts.hop)
+	best, bestCorr := nominal, math.Inf(-1)
+	for pos := nominal - int64(ts.tolerance); pos <= nominal+int64(ts.tolerance); pos++ {
+		if pos < ts.inOffset {
+			continue
+		}
+		var corr, energy float64
+		for i := int64(0); i < int64(ts.hop); i++ {
+			x := ts.sample(pos + i)
+			corr += x * ts.sample(natural+i)
+			energy += x * x
+		}
+		if energy > 0 {
+			corr /= math.Sqrt(energy)
+		}
+		if corr > bestCorr {
+			best, bestCorr = pos, corr
+		}
+	}
+	return best
+}
+
+/**
+ * Process input samples, returning the stretched samples available so far.
+ * @param samples the input samples
+ */
+func (ts *TimeStretch) TimeStretchProcess(samples []int16) []int16 {
+	for _, sample := range samples {
+		ts.input = append(ts.input, float64(sample))
+	}
+	var out []int16
+	for {
+		nominal := int64(ts.analysis)
+		/* the search and the natural continuation of the previous segment must be buffered */
+		end := nominal + int64(ts.tolerance+ts.window)
+		if ts.prevPos >= 0 && ts.prevPos+int64(ts.window) > end {
+			end = ts.prevPos + int64(ts.window)
+		}
+		if end > ts.inOffset+int64(len(ts.input)) {
+			break
+		}
+		pos := ts.segmentSeek(nominal)
+		for i := 0; i < ts.window; i++ {
+			x := ts.sample(pos+int64(i)) * ts.hann[i]
+			if i < ts.hop {
+				out = append(out, timeStretchSaturate(ts.tail[i]+x))
+			} else {
+				ts.tail[i-ts.hop] = x
+			}
+		}
+		ts.prevPos = pos
+		ts.analysis += float64(ts.hop) * ts.rate
+
+		/* discard input not needed anymore */
+		keep := int64(ts.analysis) - int64(ts.tolerance)
+		if next := ts.prevPos + int64(ts.hop); next < keep {
+			keep = next
+		}
+		if drop := keep - ts.inOffset; drop > 0 {
+			ts.input = append(ts.input[:0], ts.input[drop:]...)
+			ts.inOffset = keep
+		}
+	}
+	return out
+}
+
+/** Flush the tail of the last segment and reset the filter, keeping the rate */
+func (ts *TimeStretch) TimeStretchFlush() []int16 {
+	var out []int16
+	if ts.prevPos >= 0 {
+		out = make([]int16, ts.hop)
+		for i := range out {
+			out[i] = timeStretchSaturate(ts.tail[i])
+		}
+	}
+	ts.input = ts.input[:0]
+	ts.inOffset = 0
+	ts.analysis = 0
+	ts.prevPos = -1
+	for i := range ts.tail {
+		ts.tail[i] = 0
+	}
+	return out
+}
+
+func timeStretchSaturate(x float64) int16 {
+	if x > math.MaxInt16 {
+		return math.MaxInt16
+	}
+	if x < math.MinInt16 {
+		return math.MinInt16
+	}
+	return int16(math.Round(x))
+}
+
+/**
+ * Read stretched audio frame, pulling as many source frames as needed.
+ * The frame is passed through as is if the rate is 1.
+ * @param read the function reading the source (linear) frame
+ * @param frame the frame to read to, its codec frame size specifies the number of bytes to read
+ */
+func (ts *TimeStretch) TimeStretchFrameRead(read func(frame *Frame) error, frame *Frame) error {
+	if ts.rate == 1.0 && len(ts.output) == 0 && ts.prevPos < 0 {
+		return read(frame)
+	}
+	frameSamples := int(frame.CodecFrame.Size) / BYTES_PER_SAMPLE
+	for len(ts.output) < frameSamples {
+		if err := read(frame); err != nil {
+			return err
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) != MEDIA_FRAME_TYPE_AUDIO {
+			/* no audio in the source (e.g. speech is over), flush the rest */
+			tail := ts.TimeStretchFlush()
+			ts.output = append(ts.output, tail...)
+			break
+		}
+		samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if err != nil {
+			return err
+		}
+		ts.output = append(ts.output, ts.TimeStretchProcess(samples)...)
+	}
+	if len(ts.output) == 0 {
+		frame.Type &^= MEDIA_FRAME_TYPE_AUDIO
+		return nil
+	}
+	out := make([]int16, frameSamples)
+	n := copy(out, ts.output)
+	ts.output = append(ts.output[:0], ts.output[n:]...)
+	frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+	return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(out))
+}
@@ -0,0 +1,100 @@
+package mpf
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/* Count zero crossings per second (twice the frequency of a tone) */
+func timeStretchTestCrossings(samples []int16, samplingRate int) float64 {
+	var crossings int
+	for i := 1; i < len(samples); i++ {
+		if (samples[i-1] < 0) != (samples[i] < 0) {
+			crossings++
+		}
+	}
+	return float64(crossings) * float64(samplingRate) / float64(len(samples))
+}
+
+func TestTimeStretch(t *testing.T) {
+	descriptor := &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}
+	input := loudnessTestSine(200, 8000, 2000, 8000)
+	for _, rate := range []float64{0.5, 0.8, 1.25, 2.0} {
+		ts, err := TimeStretchCreate(descriptor)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ts.TimeStretchRateSet(rate); err != nil {
+			t.Fatal(err)
+		}
+		var output []int16
+		for i := 0; i < len(input); i += 80 {
+			output = append(output, ts.TimeStretchProcess(input[i:i+80])...)
+		}
+		output = append(output, ts.TimeStretchFlush()...)
+
+		/* duration is scaled by the rate (within the latency of a window) */
+		want := float64(len(input)) / rate
+		if math.Abs(float64(len(output))-want) > 2*float64(ts.window) {
+			t.Errorf("rate %.2f: %d samples, want about %.0f", rate, len(output), want)
+		}
+		/* pitch is kept */
+		if crossings := timeStretchTestCrossings(output[ts.window:len(output)-ts.window], 8000); math.Abs(crossings-400) > 20 {
+			t.Errorf("rate %.2f: %.0f zero crossings per second, want 400", rate, crossings)
+		}
+	}
+
+	ts, _ := TimeStretchCreate(descriptor)
+	if err := ts.TimeStretchRateSet(3); err == nil {
+		t.Error("rate out of range is accepted")
+	}
+	if _, err := TimeStretchCreate(&CodecDescriptor{SamplingRate: 8000, ChannelCount: 2}); err == nil {
+		t.Error("stereo is accepted")
+	}
+}
+
+func TestTimeStretchFrameRead(t *testing.T) {
+	descriptor := &CodecDescriptor{SamplingRate: 8000, ChannelCount: 1}
+	ts, _ := TimeStretchCreate(descriptor)
+	input := loudnessTestSine(200, 8000, 1000, 8000)
+	sourceFrames := 0
+	read := func(frame *Frame) error {
+		if sourceFrames*80 >= len(input) {
+			frame.Type = MEDIA_FRAME_TYPE_NONE
+			return nil
+		}
+		frame.Type = MEDIA_FRAME_TYPE_AUDIO
+		data := binaryx.Int16SliceToByteSlice(input[sourceFrames*80 : sourceFrames*80+80])
+		sourceFrames++
+		return codecFrameDataSet(&frame.CodecFrame, data)
+	}
+
+	frame := &Frame{CodecFrame: CodecFrame{Buffer: bytes.NewBuffer(nil), Size: 160}}
+	/* rate 1 is passed through */
+	if err := ts.TimeStretchFrameRead(read, frame); err != nil || sourceFrames != 1 {
+		t.Fatalf("pass through read %d source frames, err %v", sourceFrames, err)
+	}
+
+	ts.TimeStretchRateSet(2)
+	frames := 0
+	for {
+		frame.CodecFrame.Size = 160
+		if err := ts.TimeStretchFrameRead(read, frame); err != nil {
+			t.Fatal(err)
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == 0 {
+			break
+		}
+		if frame.CodecFrame.Size != 160 {
+			t.Fatalf("frame size %d, want 160", frame.CodecFrame.Size)
+		}
+		frames++
+	}
+	/* 99 source frames played twice faster */
+	if frames < 45 || frames > 55 {
+		t.Errorf("%d frames read, want about 50", frames)
+	}
+}
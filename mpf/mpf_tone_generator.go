@@ -0,0 +1,248 @@
+package mpf
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+/** Peak amplitude of each frequency of tone (about -12 dBFS for dual tone) */
+const TONE_SINE_AMPLITUDE = 4096
+
+/** Tone cadence is repeated till the generator is reset */
+const TONE_REPEAT_INFINITE = -1
+
+/** Standard tones */
+type TonePattern = int
+
+const (
+	TONE_PATTERN_BEEP       TonePattern = iota /**< recorder start beep: 1000 Hz, 250 msec */
+	TONE_PATTERN_DIAL                          /**< dial tone: 350+440 Hz, continuous */
+	TONE_PATTERN_RINGBACK                      /**< ringback: 440+480 Hz, 2 sec on, 4 sec off */
+	TONE_PATTERN_BUSY                          /**< busy: 480+620 Hz, 0.5 sec on, 0.5 sec off */
+	TONE_PATTERN_CONGESTION                    /**< congestion (reorder): 480+620 Hz, 0.25 sec on, 0.25 sec off */
+)
+
+/** Segment of tone cadence */
+type ToneSegment struct {
+	Frequencies []float64 // Frequencies in Hz mixed, silence if none
+	Duration    int64     // Duration in msec
+}
+
+/** Tone: cadence of segments played the number of times */
+type Tone struct {
+	Segments  []ToneSegment // Cadence
+	Repeat    int           // Number of times the cadence is played, TONE_REPEAT_INFINITE to repeat it till reset
+	Amplitude float64       // Peak amplitude of each frequency, TONE_SINE_AMPLITUDE if 0
+}
+
+/**
+ * Create standard tone.
+ * @param pattern the tone pattern
+ */
+func ToneCreate(pattern TonePattern) (*Tone, error) {
+	switch pattern {
+	case TONE_PATTERN_BEEP:
+		return ToneParse("1000/250", 1)
+	case TONE_PATTERN_DIAL:
+		return ToneParse("350+440/1000", TONE_REPEAT_INFINITE)
+	case TONE_PATTERN_RINGBACK:
+		return ToneParse("440+480/2000,0/4000", TONE_REPEAT_INFINITE)
+	case TONE_PATTERN_BUSY:
+		return ToneParse("480+620/500,0/500", TONE_REPEAT_INFINITE)
+	case TONE_PATTERN_CONGESTION:
+		return ToneParse("480+620/250,0/250", TONE_REPEAT_INFINITE)
+	}
+	return nil, fmt.Errorf("unknown tone pattern %d", pattern)
+}
+
+/**
+ * Parse custom tone, e.g. "440+480/2000,0/4000" (ringback).
+ * @param spec the segments separated by comma, each one is frequencies in Hz joined by '+' (0 for silence)
+ * followed by '/' and duration in msec
+ * @param repeat the number of times the cadence is played, TONE_REPEAT_INFINITE to repeat it till reset
+ */
+func ToneParse(spec string, repeat int) (*Tone, error) {
+	tone := &Tone{Repeat: repeat}
+	for _, item := range strings.Split(spec, ",") {
+		parts := strings.Split(strings.TrimSpace(item), "/")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid tone segment %q", item)
+		}
+		duration, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("invalid duration of tone segment %q", item)
+		}
+		segment := ToneSegment{Duration: duration}
+		for _, f := range strings.Split(parts[0], "+") {
+			frequency, err := strconv.ParseFloat(f, 64)
+			if err != nil || frequency < 0 {
+				return nil, fmt.Errorf("invalid frequency of tone segment %q", item)
+			}
+			if frequency > 0 {
+				segment.Frequencies = append(segment.Frequencies, frequency)
+			}
+		}
+		tone.Segments = append(tone.Segments, segment)
+	}
+	if repeat == 0 || repeat < TONE_REPEAT_INFINITE {
+		return nil, fmt.Errorf("invalid repeat count %d", repeat)
+	}
+	return tone, nil
+}
+
+/** Generator of tone (linear audio) */
+type ToneGenerator struct {
+	tone         *Tone
+	samplingRate uint16
+	channelCount uint8
+
+	/** Sines of the current segment */
+	sines []SineState
+	/** Index of the current segment */
+	segment int
+	/** Samples left of the current segment */
+	left int64
+	/** Number of times the cadence is played */
+	played int
+	/** Tone is complete */
+	complete bool
+}
+
+/**
+ * Create tone generator.
+ * @param tone the tone to generate
+ * @param descriptor the codec descriptor of the linear audio to generate
+ */
+func ToneGeneratorCreate(tone *Tone, descriptor *CodecDescriptor) (*ToneGenerator, error) {
+	if tone == nil || len(tone.Segments) == 0 {
+		return nil, fmt.Errorf("no tone segments")
+	}
+	if descriptor == nil || descriptor.SamplingRate == 0 || descriptor.ChannelCount == 0 {
+		return nil, fmt.Errorf("invalid descriptor of tone")
+	}
+	g := &ToneGenerator{tone: tone, samplingRate: descriptor.SamplingRate, channelCount: descriptor.ChannelCount}
+	g.ToneGeneratorReset()
+	return g, nil
+}
+
+/** Restart tone from the beginning */
+func (g *ToneGenerator) ToneGeneratorReset() {
+	g.played = 0
+	g.complete = false
+	g.segmentStart(0)
+}
+
+/* Start segment of cadence */
+func (g *ToneGenerator) segmentStart(index int) {
+	segment := g.tone.Segments[index]
+	amplitude := g.tone.Amplitude
+	if amplitude == 0 {
+		amplitude = TONE_SINE_AMPLITUDE
+	}
+	g.segment = index
+	g.left = segment.Duration * int64(g.samplingRate) / 1000
+	g.sines = g.sines[:0]
+	for _, frequency := range segment.Frequencies {
+		omega := 2 * math.Pi * frequency / float64(g.samplingRate)
+		g.sines = append(g.sines, SineState{Coef: 2 * math.Cos(omega), S1: 0, S2: amplitude * math.Sin(omega)})
+	}
+}
+
+/* Advance to the next segment as soon as the current one is played */
+func (g *ToneGenerator) segmentNext() {
+	next := g.segment + 1
+	if next == len(g.tone.Segments) {
+		next = 0
+		g.played++
+		if g.tone.Repeat != TONE_REPEAT_INFINITE && g.played >= g.tone.Repeat {
+			g.complete = true
+			return
+		}
+	}
+	g.segmentStart(next)
+}
+
+/** Check whether tone is complete */
+func (g *ToneGenerator) ToneGeneratorComplete() bool {
+	return g.complete
+}
+
+/**
+ * Generate the next samples of tone (interleaved if multichannel), silence after the tone is complete.
+ * @param count the number of samples per channel
+ */
+func (g *ToneGenerator) ToneGeneratorSamplesGet(count int) []int16 {
+	channels := int(g.channelCount)
+	samples := make([]int16, count*channels)
+	for i := 0; i < count && !g.complete; i++ {
+		var value float64
+		for j := range g.sines {
+			sine := &g.sines[j]
+			value += sine.S2
+			s := sine.S1
+			sine.S1 = sine.S2
+			sine.S2 = sine.Coef*sine.S1 - s
+		}
+		if value > math.MaxInt16 {
+			value = math.MaxInt16
+		} else if value < math.MinInt16 {
+			value = math.MinInt16
+		}
+		for c := 0; c < channels; c++ {
+			samples[i*channels+c] = int16(value)
+		}
+		g.left--
+		if g.left <= 0 {
+			g.segmentNext()
+		}
+	}
+	return samples
+}
+
+/**
+ * Create termination playing tone, e.g. recorder start beep, ringback or diagnostic tone.
+ * The completion of the tone of finite repeat count is raised as AUDIO_FILE_COMPLETE_EVENT.
+ * @param tone the tone to play
+ * @param descriptor the codec descriptor of the linear audio (linear PCM 8 kHz mono if nil)
+ */
+func ToneTerminationCreate(tone *Tone, descriptor *CodecDescriptor) (*Termination, error) {
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	if !CodecLPcmDescriptorMatch(descriptor) {
+		return nil, fmt.Errorf("tone of codec %s is not supported", descriptor.Name)
+	}
+	g, err := ToneGeneratorCreate(tone, descriptor)
+	if err != nil {
+		return nil, err
+	}
+	var duration int64
+	completed := false
+	vtable := &AudioStreamVTable{
+		ReadFrame: func(stream *AudioStream, frame *Frame) error {
+			if completed {
+				return nil
+			}
+			if g.ToneGeneratorComplete() {
+				completed = true
+				completion := &AudioFileCompletion{Direction: FILE_READER, Cause: AUDIO_FILE_COMPLETION_EOF, Duration: duration}
+				return AudioFileEventRaise(stream, AUDIO_FILE_COMPLETE_EVENT, completion)
+			}
+			frameDuration := stream.RXDescriptor.CodecFrameDurationGet()
+			frame.Type |= MEDIA_FRAME_TYPE_AUDIO
+			duration += frameDuration
+			samples := g.ToneGeneratorSamplesGet(int(int64(g.samplingRate) * frameDuration / 1000))
+			return codecFrameDataSet(&frame.CodecFrame, binaryx.Int16SliceToByteSlice(samples))
+		},
+	}
+	audioStream := AudioStreamCreate(g, vtable, SourceStreamCapabilitiesCreate())
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = descriptor
+	return TerminationBaseCreate(nil, g, nil, audioStream, nil), nil
+}
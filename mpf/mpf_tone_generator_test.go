@@ -0,0 +1,104 @@
+package mpf
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/binaryx"
+)
+
+func TestToneTermination(t *testing.T) {
+	tone, err := ToneCreate(TONE_PATTERN_BEEP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	termination, err := ToneTerminationCreate(tone, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var completions []*AudioFileCompletion
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == AUDIO_FILE_COMPLETE_EVENT {
+			completions = append(completions, descriptor.(*AudioFileCompletion))
+		}
+		return nil
+	}
+	source := termination.TerminationAudioStreamGet()
+	frames, crossings := 0, 0
+	var last int16
+	for i := 0; i < 40; i++ {
+		frame := &Frame{}
+		if err := source.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if frame.Type != MEDIA_FRAME_TYPE_AUDIO {
+			continue
+		}
+		frames++
+		samples, err := binaryx.ByteSliceToInt16Slice(codecFrameDataGet(&frame.CodecFrame))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, sample := range samples {
+			if (last < 0) != (sample < 0) {
+				crossings++
+			}
+			last = sample
+		}
+	}
+	if frames != 25 {
+		t.Fatalf("%d frames of 250 msec beep", frames)
+	}
+	/* 1000 Hz: 2 zero crossings per msec */
+	if crossings < 490 || crossings > 510 {
+		t.Fatalf("%d zero crossings of 250 msec beep", crossings)
+	}
+	if len(completions) != 1 || completions[0].Cause != AUDIO_FILE_COMPLETION_EOF || completions[0].Duration != 250 {
+		t.Fatalf("completions %+v", completions)
+	}
+}
+
+func TestToneCadence(t *testing.T) {
+	tone, err := ToneParse("440+480/20,0/30", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := ToneGeneratorCreate(tone, CodecLPcmDescriptorCreate(8000, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	samples := g.ToneGeneratorSamplesGet(8 * 100)
+	if !g.ToneGeneratorComplete() || len(samples) != 2*8*100 {
+		t.Fatalf("tone of 100 msec is not complete")
+	}
+	for _, test := range []struct {
+		from, to int
+		silent   bool
+	}{
+		{0, 160, false},
+		{160, 400, true},
+		{400, 560, false},
+		{560, 800, true},
+	} {
+		silent := true
+		for i := test.from; i < test.to; i++ {
+			if samples[2*i] != samples[2*i+1] {
+				t.Fatalf("channels differ at %d", i)
+			}
+			if samples[2*i] != 0 {
+				silent = false
+			}
+		}
+		if silent != test.silent {
+			t.Fatalf("samples %d-%d: silent %v", test.from, test.to, silent)
+		}
+	}
+
+	for _, spec := range []string{"", "440", "440/0", "abc/100", "440+-1/100"} {
+		if _, err := ToneParse(spec, 1); err == nil {
+			t.Fatalf("invalid tone %q is parsed", spec)
+		}
+	}
+	if _, err := ToneTerminationCreate(tone, &CodecDescriptor{Name: G711U_CODEC_NAME, SamplingRate: 8000, ChannelCount: 1}); err == nil {
+		t.Fatalf("tone of PCMU is created")
+	}
+}
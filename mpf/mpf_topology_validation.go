@@ -0,0 +1,112 @@
+package mpf
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/** Mismatch of descriptors of associated terminations no conversion stage is available for */
+type StreamMismatch struct {
+	/** Name of the source termination */
+	Source string
+	/** Name of the sink termination */
+	Sink string
+	/** Attributes mismatched, e.g. "sampling rate 8000 Hz != 16000 Hz (no resampler)" */
+	Reasons []string
+}
+
+/** Error of topology the audio of which would be corrupted */
+type TopologyMismatchError struct {
+	/** Name of the context */
+	Context    string
+	Mismatches []*StreamMismatch
+}
+
+func (e *TopologyMismatchError) Error() string {
+	items := make([]string, 0, len(e.Mismatches))
+	for _, mismatch := range e.Mismatches {
+		items = append(items, fmt.Sprintf("%s -> %s: %s", mismatch.Source, mismatch.Sink, strings.Join(mismatch.Reasons, ", ")))
+	}
+	return fmt.Sprintf("context %s topology mismatch: %s", e.Context, strings.Join(items, "; "))
+}
+
+/* Get frame duration of the codec descriptor in msec (frame duration set or ptime format param) */
+func codecDescriptorFrameDurationGet(descriptor *CodecDescriptor) int64 {
+	if descriptor.FrameDuration > 0 {
+		return descriptor.FrameDuration
+	}
+	if ptime, err := strconv.ParseInt(descriptor.CodecFormatParamsGet()["ptime"], 10, 64); err == nil && ptime > 0 {
+		return ptime
+	}
+	return CODEC_FRAME_TIME_BASE
+}
+
+/**
+ * Validate descriptors of audio streams to bridge: the audio must either be relayed as is,
+ * or be converted by the stages available (decoder, encoder, resampler, channel converter, re-framer).
+ * @param source the source audio stream
+ * @param sink the sink audio stream
+ * @return the attributes mismatched, nil if the streams may be bridged
+ */
+func StreamDescriptorsValidate(source, sink *AudioStream) []string {
+	if eventOnlySourceCheck(source) || eventOnlySinkCheck(sink) {
+		/* no audio flows, named events only */
+		if !EventDescriptorsCompatible(source.RXEventDescriptor, sink.TXEventDescriptor) {
+			return []string{"named events not compatible"}
+		}
+		return nil
+	}
+	rx, tx := source.RXDescriptor, sink.TXDescriptor
+	if rx == nil || tx == nil {
+		return []string{"no codec descriptor"}
+	}
+	if CodecDescriptorsMatch(rx, tx) ||
+		(!CodecLPcmDescriptorMatch(rx) && sink.AudioStreamCodecAccept(rx)) ||
+		(!CodecLPcmDescriptorMatch(tx) && source.AudioStreamCodecAccept(tx)) {
+		/* relayed as is */
+		return nil
+	}
+	var reasons []string
+	if rx.SamplingRate != tx.SamplingRate && !ReSamplerSupported(rx.SamplingRate, tx.SamplingRate) {
+		reasons = append(reasons, fmt.Sprintf("sampling rate %d Hz != %d Hz (no resampler)", rx.SamplingRate, tx.SamplingRate))
+	}
+	if !ChannelConversionSupported(rx.ChannelCount, tx.ChannelCount) {
+		reasons = append(reasons, fmt.Sprintf("channel count %d != %d (no channel conversion)", rx.ChannelCount, tx.ChannelCount))
+	}
+	if rxDuration, txDuration := codecDescriptorFrameDurationGet(rx), codecDescriptorFrameDurationGet(tx); rxDuration != txDuration && !ReFramingSupported(rxDuration, txDuration) {
+		reasons = append(reasons, fmt.Sprintf("frame duration %d ms != %d ms (no re-framing)", rxDuration, txDuration))
+	}
+	return reasons
+}
+
+/**
+ * Validate associations of the context, so that the topology is not applied if audio would be corrupted.
+ * @param context the context to validate
+ */
+func (context *Context) ContextTopologyValidate() error {
+	var mismatches []*StreamMismatch
+	for i := int64(0); i < context.Capacity; i++ {
+		source := context.header[i].termination
+		if source == nil || source.audioStream == nil || context.header[i].TXCount <= 0 {
+			continue
+		}
+		for j := int64(0); j < context.Capacity; j++ {
+			sink := context.header[j].termination
+			if sink == nil || sink.audioStream == nil || context.matrix[i][j].On <= 0 {
+				continue
+			}
+			if reasons := StreamDescriptorsValidate(source.audioStream, sink.audioStream); len(reasons) > 0 {
+				mismatches = append(mismatches, &StreamMismatch{
+					Source:  TerminationNameGet(source),
+					Sink:    TerminationNameGet(sink),
+					Reasons: reasons,
+				})
+			}
+		}
+	}
+	if len(mismatches) > 0 {
+		return &TopologyMismatchError{Context: context.Name, Mismatches: mismatches}
+	}
+	return nil
+}
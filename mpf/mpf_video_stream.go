@@ -0,0 +1,232 @@
+package mpf
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/** RTP clock rate of video in Hz, implied by video codec descriptors (the sampling rate of which is 0) */
+const VIDEO_CLOCK_RATE = 90000
+
+/** Table of video stream virtual methods */
+type VideoStreamVTable struct {
+
+	/** Virtual destroy method */
+	Destroy func(stream *VideoStream) error
+
+	/** Virtual open receiver method */
+	OpenRX func(stream *VideoStream) error
+	/** Virtual close receiver method */
+	CloseRX func(stream *VideoStream) error
+	/** Virtual read frame method */
+	ReadFrame func(stream *VideoStream, frame *Frame) error
+
+	/** Virtual open transmitter method */
+	OpenTX func(stream *VideoStream) error
+	/** Virtual close transmitter method */
+	CloseTX func(stream *VideoStream) error
+	/** Virtual write frame method */
+	WriteFrame func(stream *VideoStream, frame *Frame) error
+}
+
+/**
+ * Video stream: frames of video (MEDIA_FRAME_TYPE_VIDEO) are passed through as encoded,
+ * no transcoding is supported, so that MRCP sessions negotiated along with video are not broken.
+ */
+type VideoStream struct {
+
+	/** External object */
+	Obj interface{}
+	/** Table of virtual methods */
+	VTable *VideoStreamVTable
+	/** Back pointer */
+	termination *Termination
+
+	/** Stream capabilities */
+	Capabilities *StreamCapabilities
+
+	/** Stream direction send/receive (bitmask of mpf_stream_direction_e) */
+	direction StreamDirection
+
+	/** Rx codec descriptor */
+	RXDescriptor *CodecDescriptor
+	/** Tx codec descriptor */
+	TXDescriptor *CodecDescriptor
+}
+
+/** Create video stream */
+func VideoStreamCreate(obj interface{}, vtable *VideoStreamVTable, capabilities *StreamCapabilities) *VideoStream {
+	if vtable == nil || capabilities == nil {
+		return nil
+	}
+	return &VideoStream{
+		Obj:          obj,
+		VTable:       vtable,
+		Capabilities: capabilities,
+		direction:    capabilities.direction,
+	}
+}
+
+/** Destroy video stream */
+func VideoStreamDestroy(stream *VideoStream) error {
+	if stream.VTable != nil && stream.VTable.Destroy != nil {
+		return stream.VTable.Destroy(stream)
+	}
+	return nil
+}
+
+/** Open video stream receiver */
+func (stream *VideoStream) VideoStreamRXOpen() error {
+	if stream.VTable != nil && stream.VTable.OpenRX != nil {
+		return stream.VTable.OpenRX(stream)
+	}
+	return nil
+}
+
+/** Close video stream receiver */
+func (stream *VideoStream) VideoStreamRXClose() error {
+	if stream.VTable != nil && stream.VTable.CloseRX != nil {
+		return stream.VTable.CloseRX(stream)
+	}
+	return nil
+}
+
+/** Read frame */
+func (stream *VideoStream) VideoStreamFrameRead(frame *Frame) error {
+	if stream.VTable != nil && stream.VTable.ReadFrame != nil {
+		return stream.VTable.ReadFrame(stream, frame)
+	}
+	return nil
+}
+
+/** Open video stream transmitter */
+func (stream *VideoStream) VideoStreamTXOpen() error {
+	if stream.VTable != nil && stream.VTable.OpenTX != nil {
+		return stream.VTable.OpenTX(stream)
+	}
+	return nil
+}
+
+/** Close video stream transmitter */
+func (stream *VideoStream) VideoStreamTXClose() error {
+	if stream.VTable != nil && stream.VTable.CloseTX != nil {
+		return stream.VTable.CloseTX(stream)
+	}
+	return nil
+}
+
+/** Write frame */
+func (stream *VideoStream) VideoStreamFrameWrite(frame *Frame) error {
+	if stream.VTable != nil && stream.VTable.WriteFrame != nil {
+		return stream.VTable.WriteFrame(stream, frame)
+	}
+	return nil
+}
+
+/** Get current direction of video stream */
+func (stream *VideoStream) VideoStreamDirectionGet() StreamDirection {
+	return stream.direction
+}
+
+/**
+ * Check whether video of source can be passed through to sink: the directions must be compatible
+ * and the codecs must match, as video is not transcoded.
+ * @param source the source video stream
+ * @param sink the sink video stream
+ */
+func VideoStreamPassthroughCheck(source, sink *VideoStream) bool {
+	if source == nil || (source.direction&STREAM_DIRECTION_RECEIVE) != STREAM_DIRECTION_RECEIVE ||
+		sink == nil || (sink.direction&STREAM_DIRECTION_SEND) != STREAM_DIRECTION_SEND {
+		return false
+	}
+	if source.RXDescriptor == nil || sink.TXDescriptor == nil {
+		return false
+	}
+	return CodecDescriptorsMatch(source.RXDescriptor, sink.TXDescriptor)
+}
+
+/** MPF video bridge derived from MPF object: passes video of source through to sinks as is */
+type VideoBridge struct {
+	/** MPF video bridge base */
+	base *Object
+	/** Video stream source */
+	source *VideoStream
+	/** Video stream sinks */
+	sinks []*VideoStream
+	/** Frame read from source and written to sinks */
+	frame Frame
+}
+
+/**
+ * Create bridge passing video of source through to sinks (no transcoding).
+ * @param source the source video stream
+ * @param sinks the sink video streams, the codecs of which match the one of source
+ * @param name the informative name used for debugging
+ */
+func VideoBridgeCreate(source *VideoStream, sinks []*VideoStream, name string) (*Object, error) {
+	if source == nil || len(sinks) == 0 {
+		return nil, fmt.Errorf("no source or sinks")
+	}
+	for _, sink := range sinks {
+		if !VideoStreamPassthroughCheck(source, sink) {
+			return nil, fmt.Errorf("passthrough of video is not possible")
+		}
+	}
+	bridge := &VideoBridge{
+		base:   ObjectInit(name),
+		source: source,
+		sinks:  sinks,
+	}
+	bridge.frame.CodecFrame.Buffer = bytes.NewBuffer(make([]byte, 0))
+	bridge.base.Process = func(object *Object) error {
+		return bridge.VideoBridgeProcess()
+	}
+	bridge.base.Destroy = func(object *Object) error {
+		return bridge.VideoBridgeDestroy()
+	}
+
+	if err := source.VideoStreamRXOpen(); err != nil {
+		return nil, err
+	}
+	for i, sink := range sinks {
+		if err := sink.VideoStreamTXOpen(); err != nil {
+			for _, opened := range sinks[:i] {
+				_ = opened.VideoStreamTXClose()
+			}
+			_ = source.VideoStreamRXClose()
+			return nil, err
+		}
+	}
+	return bridge.base, nil
+}
+
+/** Process video bridge: read frame from source and write it to sinks, if any */
+func (bridge *VideoBridge) VideoBridgeProcess() error {
+	bridge.frame.Type = MEDIA_FRAME_TYPE_NONE
+	bridge.frame.Marker = MPF_MARKER_NONE
+	bridge.frame.CodecFrame.Buffer.Reset()
+	if err := bridge.source.VideoStreamFrameRead(&bridge.frame); err != nil {
+		return err
+	}
+	if (bridge.frame.Type & MEDIA_FRAME_TYPE_VIDEO) != MEDIA_FRAME_TYPE_VIDEO {
+		/* video is not clocked by the media clock, nothing to pass this time */
+		return nil
+	}
+	for _, sink := range bridge.sinks {
+		if err := sink.VideoStreamFrameWrite(&bridge.frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/** Destroy video bridge: close source and sinks */
+func (bridge *VideoBridge) VideoBridgeDestroy() error {
+	err := bridge.source.VideoStreamRXClose()
+	for _, sink := range bridge.sinks {
+		if e := sink.VideoStreamTXClose(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
+}
@@ -0,0 +1,65 @@
+package mpf
+
+import (
+	"testing"
+)
+
+/* Create video descriptor */
+func videoTestDescriptorCreate(name string) *CodecDescriptor {
+	descriptor := CodecDescriptorCreate()
+	descriptor.PayloadType = 96
+	descriptor.Name = name
+	descriptor.ChannelCount = 1
+	return descriptor
+}
+
+func TestVideoPassthrough(t *testing.T) {
+	var received [][]byte
+	camera := VideoStreamCreate(nil, &VideoStreamVTable{
+		ReadFrame: func(stream *VideoStream, frame *Frame) error {
+			frame.Type = MEDIA_FRAME_TYPE_VIDEO
+			return codecFrameDataSet(&frame.CodecFrame, []byte{0x65, 0x88})
+		},
+	}, SourceStreamCapabilitiesCreate())
+	camera.RXDescriptor = videoTestDescriptorCreate("H264")
+	screen := func(name string) *VideoStream {
+		stream := VideoStreamCreate(nil, &VideoStreamVTable{
+			WriteFrame: func(stream *VideoStream, frame *Frame) error {
+				received = append(received, append([]byte(nil), codecFrameDataGet(&frame.CodecFrame)...))
+				return nil
+			},
+		}, SinkStreamCapabilitiesCreate())
+		stream.TXDescriptor = videoTestDescriptorCreate(name)
+		return stream
+	}
+
+	var written []int16
+	count := -1
+	context := ContextFactoryCreate().ContextCreate("video", nil, 3)
+	terminations := []*Termination{
+		RawTerminationCreate(nil, mixerTestSource(100, &count), camera),
+		RawTerminationCreate(nil, multiplierTestSink(8000, &written), screen("H264")),
+		/* video only, of other codec */
+		RawTerminationCreate(nil, nil, screen("VP8")),
+	}
+	for _, termination := range terminations {
+		termination.codecManager = CodecManagerDefaultCreate()
+		context.ContextTerminationAdd(termination)
+	}
+	context.ContextAssociationAdd(terminations[0], terminations[1])
+	context.ContextAssociationAdd(terminations[0], terminations[2])
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextProcess(); err != nil {
+		t.Fatal(err)
+	}
+
+	/* video of the matching codec is passed through, the audio flows regardless of video */
+	if len(received) != 1 || received[0][0] != 0x65 || len(written) != 80 || written[0] != 100 {
+		t.Fatalf("received %d video frames, %d samples of audio", len(received), len(written))
+	}
+	if _, err := VideoBridgeCreate(camera, []*VideoStream{screen("VP8")}, "video"); err == nil {
+		t.Fatalf("video is bridged to other codec")
+	}
+}
@@ -0,0 +1,121 @@
+package mpf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+/** Size of canonical WAV header (RIFF, fmt and data chunk headers) */
+const WAV_HEADER_SIZE = 44
+
+/** WAVE format tag of PCM */
+const WAV_FORMAT_PCM = 1
+
+/** WAV header of PCM audio */
+type WavHeader struct {
+	/** Sampling rate */
+	SamplingRate uint16
+	/** Channel count */
+	ChannelCount uint8
+	/** Bits per sample */
+	BitsPerSample uint16
+	/** Offset of the audio data in the file */
+	DataOffset int64
+	/** Size of the audio data in bytes */
+	DataSize int64
+}
+
+/** Check whether data starts with WAV (RIFF/WAVE) header */
+func WavHeaderCheck(data []byte) bool {
+	return len(data) >= 12 && bytes.Equal(data[0:4], []byte("RIFF")) && bytes.Equal(data[8:12], []byte("WAVE"))
+}
+
+/**
+ * Read WAV header, the reader is positioned at the start of the audio data.
+ * Only PCM 16-bit mono audio at 8 kHz or 16 kHz is supported.
+ * @param r the reader positioned at the start of the file
+ */
+func WavHeaderRead(r io.ReadSeeker) (*WavHeader, error) {
+	riff := make([]byte, 12)
+	if _, err := io.ReadFull(r, riff); err != nil {
+		return nil, fmt.Errorf("failed to read WAV header: %v", err)
+	}
+	if !WavHeaderCheck(riff) {
+		return nil, fmt.Errorf("not a WAV file")
+	}
+	header := &WavHeader{}
+	offset := int64(12)
+	formatFound := false
+	for {
+		chunk := make([]byte, 8)
+		if _, err := io.ReadFull(r, chunk); err != nil {
+			return nil, fmt.Errorf("no data chunk in WAV file")
+		}
+		offset += 8
+		id, size := string(chunk[0:4]), int64(binary.LittleEndian.Uint32(chunk[4:8]))
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, fmt.Errorf("invalid WAV fmt chunk size %d", size)
+			}
+			format := make([]byte, size)
+			if _, err := io.ReadFull(r, format); err != nil {
+				return nil, err
+			}
+			if tag := binary.LittleEndian.Uint16(format[0:2]); tag != WAV_FORMAT_PCM {
+				return nil, fmt.Errorf("WAV format %d is not supported, PCM is expected", tag)
+			}
+			channels := binary.LittleEndian.Uint16(format[2:4])
+			samplingRate := binary.LittleEndian.Uint32(format[4:8])
+			header.BitsPerSample = binary.LittleEndian.Uint16(format[14:16])
+			if channels != 1 || header.BitsPerSample != BITS_PER_SAMPLE || (samplingRate != 8000 && samplingRate != 16000) {
+				return nil, fmt.Errorf("WAV of %d channels, %d bits, %d Hz is not supported", channels, header.BitsPerSample, samplingRate)
+			}
+			header.ChannelCount = uint8(channels)
+			header.SamplingRate = uint16(samplingRate)
+			formatFound = true
+		case "data":
+			if !formatFound {
+				return nil, fmt.Errorf("no fmt chunk before data chunk in WAV file")
+			}
+			header.DataOffset = offset
+			header.DataSize = size
+			return header, nil
+		default:
+			/* skip unknown chunk (e.g. LIST), chunks are padded to even size */
+			if _, err := r.Seek(size+size%2, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+		}
+		offset += size + size%2
+	}
+}
+
+/**
+ * Write canonical WAV header of PCM 16-bit audio.
+ * @param w the writer
+ * @param samplingRate the sampling rate
+ * @param channelCount the channel count
+ * @param dataSize the size of the audio data in bytes
+ */
+func WavHeaderWrite(w io.Writer, samplingRate uint16, channelCount uint8, dataSize int64) error {
+	header := make([]byte, WAV_HEADER_SIZE)
+	blockAlign := uint16(channelCount) * BYTES_PER_SAMPLE
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(WAV_HEADER_SIZE-8+dataSize))
+	copy(header[8:12], "WAVE")
+	copy(header[12:16], "fmt ")
+	binary.LittleEndian.PutUint32(header[16:20], 16)
+	binary.LittleEndian.PutUint16(header[20:22], WAV_FORMAT_PCM)
+	binary.LittleEndian.PutUint16(header[22:24], uint16(channelCount))
+	binary.LittleEndian.PutUint32(header[24:28], uint32(samplingRate))
+	binary.LittleEndian.PutUint32(header[28:32], uint32(samplingRate)*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(header[32:34], blockAlign)
+	binary.LittleEndian.PutUint16(header[34:36], BITS_PER_SAMPLE)
+	copy(header[36:40], "data")
+	binary.LittleEndian.PutUint32(header[40:44], uint32(dataSize))
+	_, err := w.Write(header)
+	return err
+}
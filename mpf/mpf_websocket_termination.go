@@ -0,0 +1,53 @@
+package mpf
+
+import (
+	"fmt"
+
+	"github.com/navi-tt/go-mrcp/utils/websocketx"
+)
+
+/**
+ * Create termination streaming audio over WebSocket in both directions, e.g. to attach browser-based
+ * or cloud-relay audio to MRCP engine channel.
+ *
+ * Framing: each binary message carries audio of the codec of the descriptor. Linear PCM may be sent
+ * in messages of any size and is re-chunked to frames, audio of other codecs (e.g. Opus) is sent
+ * as one frame per message. Each frame written to the termination is sent as one binary message.
+ * Text messages are reserved for control and are ignored. Close of the connection by the peer
+ * ends the audio, raised as AUDIO_FILE_COMPLETE_EVENT. The connection is closed on destroy.
+ * @param conn the WebSocket connection
+ * @param descriptor the codec descriptor of the audio in both directions (linear PCM 8 kHz mono if nil)
+ */
+func WebSocketTerminationCreate(conn *websocketx.Conn, descriptor *CodecDescriptor) (*Termination, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("no WebSocket connection")
+	}
+	if descriptor == nil {
+		descriptor = CodecLPcmDescriptorCreate(8000, 1)
+	}
+	next := ioMessageFramesRead(descriptor, func() ([]byte, error) {
+		for {
+			opcode, payload, err := conn.ReadMessage()
+			if err != nil {
+				/* io.EOF if closed by the peer */
+				return nil, err
+			}
+			if opcode == websocketx.OP_BINARY {
+				return payload, nil
+			}
+		}
+	})
+	reader := ioReaderStreamCreate(next, conn)
+	writer := ioWriterStreamCreate(func(data []byte) error {
+		return conn.WriteMessage(websocketx.OP_BINARY, data)
+	})
+	audioStream := ioDuplexAudioStreamCreate(reader, writer, nil)
+	if audioStream == nil {
+		return nil, fmt.Errorf("failed to create stream")
+	}
+	audioStream.RXDescriptor = descriptor
+	audioStream.TXDescriptor = descriptor
+	go reader.run()
+	go writer.run()
+	return TerminationBaseCreate(nil, conn, nil, audioStream, nil), nil
+}
@@ -0,0 +1,123 @@
+package mpf
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/utils/websocketx"
+)
+
+func TestWebSocketTermination(t *testing.T) {
+	terminations := make(chan *Termination, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		termination, err := WebSocketTerminationCreate(conn, nil)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		terminations <- termination
+	}))
+	defer server.Close()
+
+	client, err := websocketx.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	termination := <-terminations
+	completed := false
+	termination.EventHandler = func(termination *Termination, eventId int, descriptor interface{}) error {
+		if eventId == AUDIO_FILE_COMPLETE_EVENT {
+			completed = true
+		}
+		return nil
+	}
+	stream := termination.TerminationAudioStreamGet()
+
+	/* messages of any size are re-chunked to frames of 160 bytes, text messages are ignored */
+	audio := bytes.Repeat([]byte{1, 2, 3, 4, 5}, 96)
+	for _, message := range [][]byte{audio[:100], audio[100:350], audio[350:]} {
+		if err := client.WriteMessage(websocketx.OP_BINARY, message); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.WriteMessage(websocketx.OP_TEXT, []byte("{}")); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var received []byte
+	for !completed {
+		frame := &Frame{}
+		if err := stream.AudioStreamFrameRead(frame); err != nil {
+			t.Fatal(err)
+		}
+		if (frame.Type & MEDIA_FRAME_TYPE_AUDIO) == MEDIA_FRAME_TYPE_AUDIO {
+			if frame.CodecFrame.Size != 160 {
+				t.Fatalf("frame of %d bytes received", frame.CodecFrame.Size)
+			}
+			received = append(received, codecFrameDataGet(&frame.CodecFrame)...)
+		}
+		runtime.Gosched()
+	}
+	if !bytes.Equal(received, audio) || IOStreamErrorGet(stream) != nil {
+		t.Fatalf("%d bytes received, want %d: %v", len(received), len(audio), IOStreamErrorGet(stream))
+	}
+	AudioStreamDestroy(stream)
+}
+
+func TestWebSocketTerminationWrite(t *testing.T) {
+	terminations := make(chan *Termination, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocketx.Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		termination, err := WebSocketTerminationCreate(conn, nil)
+		if err != nil {
+			conn.Close()
+			return
+		}
+		terminations <- termination
+	}))
+	defer server.Close()
+
+	client, err := websocketx.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	stream := (<-terminations).TerminationAudioStreamGet()
+
+	/* each frame written is sent as one binary message */
+	for i := 0; i < 3; i++ {
+		frame := &Frame{Type: MEDIA_FRAME_TYPE_AUDIO}
+		codecFrameDataSet(&frame.CodecFrame, bytes.Repeat([]byte{byte(i)}, 160))
+		if err := stream.AudioStreamFrameWrite(frame); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := AudioStreamDestroy(stream); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		opcode, payload, err := client.ReadMessage()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if opcode != websocketx.OP_BINARY || !bytes.Equal(payload, bytes.Repeat([]byte{byte(i)}, 160)) {
+			t.Fatalf("message %d: opcode %d, %d bytes", i, opcode, len(payload))
+		}
+	}
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatalf("connection is not closed on destroy")
+	}
+}
@@ -0,0 +1,151 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+)
+
+/** Name of the recognizer method which defines grammars */
+const MRCP_DEFINE_GRAMMAR_METHOD_NAME = "DEFINE-GRAMMAR"
+
+/** Grammar already defined on the server side */
+type MRCPClientGrammar struct {
+	ContentId   string // Content-Id the grammar is defined under
+	ContentType string // Content-Type of the grammar
+	Hash        string // Hash of the grammar content
+}
+
+/**
+ * Client side cache of grammars defined per MRCP channel.
+ * Used to skip redundant DEFINE-GRAMMAR round trips when the same grammar
+ * (by content hash) has already been defined on the same channel.
+ */
+type MRCPClientGrammarCache struct {
+	mutex    sync.Mutex
+	channels map[header.MRCPChannelId]map[string]*MRCPClientGrammar // channel-identifier -> Content-Id -> grammar
+}
+
+/** Create client grammar cache */
+func MRCPClientGrammarCacheCreate() *MRCPClientGrammarCache {
+	return &MRCPClientGrammarCache{
+		channels: make(map[header.MRCPChannelId]map[string]*MRCPClientGrammar),
+	}
+}
+
+/**
+ * Calculate hash of grammar content.
+ * @param contentType the content type of the grammar
+ * @param body the grammar content
+ */
+func MRCPGrammarHash(contentType, body string) string {
+	sum := sha256.Sum256([]byte(contentType + "\r\n" + body))
+	return hex.EncodeToString(sum[:])
+}
+
+/* Get grammar definition carried by DEFINE-GRAMMAR request, if any */
+func mrcpClientGrammarGet(msg *message.MRCPMessage) *MRCPClientGrammar {
+	if msg == nil || msg.StartLine == nil || msg.StartLine.MethodName != MRCP_DEFINE_GRAMMAR_METHOD_NAME {
+		return nil
+	}
+	genericHeader, ok := msg.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	if !ok || genericHeader == nil || len(genericHeader.ContentId) == 0 || len(msg.Body) == 0 {
+		return nil
+	}
+	return &MRCPClientGrammar{
+		ContentId:   genericHeader.ContentId,
+		ContentType: genericHeader.ContentType,
+		Hash:        MRCPGrammarHash(genericHeader.ContentType, msg.Body),
+	}
+}
+
+/**
+ * Check whether DEFINE-GRAMMAR request is redundant and may be skipped.
+ * @param request the request to be sent
+ * @return the locally generated response to use instead of the round trip,
+ * or nil if the request must be sent to the server
+ */
+func (c *MRCPClientGrammarCache) GrammarCacheCheck(request *message.MRCPMessage) *message.MRCPMessage {
+	grammar := mrcpClientGrammarGet(request)
+	if grammar == nil {
+		return nil
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	defined, ok := c.channels[request.ChannelId][grammar.ContentId]
+	if !ok || defined.Hash != grammar.Hash {
+		return nil
+	}
+	return message.MRCPResponseCreate(request)
+}
+
+/**
+ * Update cache on response to DEFINE-GRAMMAR request.
+ * @param request the request sent to the server
+ * @param response the response received from the server
+ */
+func (c *MRCPClientGrammarCache) GrammarCacheUpdate(request, response *message.MRCPMessage) {
+	grammar := mrcpClientGrammarGet(request)
+	if grammar == nil || response == nil || response.StartLine == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	grammars := c.channels[request.ChannelId]
+	switch response.StartLine.StatusCode {
+	case message.MRCP_STATUS_CODE_SUCCESS, message.MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE:
+		if grammars == nil {
+			grammars = make(map[string]*MRCPClientGrammar)
+			c.channels[request.ChannelId] = grammars
+		}
+		grammars[grammar.ContentId] = grammar
+	default:
+		/* failed definition invalidates previously defined grammar with the same Content-Id */
+		delete(grammars, grammar.ContentId)
+	}
+}
+
+/**
+ * Find grammar defined on the channel by content hash.
+ * @param channelId the channel-identifier
+ * @param hash the content hash of the grammar
+ */
+func (c *MRCPClientGrammarCache) GrammarCacheFind(channelId header.MRCPChannelId, hash string) *MRCPClientGrammar {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for _, grammar := range c.channels[channelId] {
+		if grammar.Hash == hash {
+			return grammar
+		}
+	}
+	return nil
+}
+
+/**
+ * Remove grammars defined on the channel (channel is destroyed).
+ * @param channelId the channel-identifier
+ */
+func (c *MRCPClientGrammarCache) GrammarCacheChannelRemove(channelId header.MRCPChannelId) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.channels, channelId)
+}
+
+/**
+ * Remove grammars defined on all the channels of the session (session is terminated).
+ * @param sessionId the session identifier
+ */
+func (c *MRCPClientGrammarCache) GrammarCacheSessionRemove(sessionId string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for channelId := range c.channels {
+		if channelId.SessionId == sessionId {
+			delete(c.channels, channelId)
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+)
+
+const grammarCacheTestContent = `<grammar root="yesno"><rule id="yesno"><one-of><item>yes</item><item>no</item></one-of></rule></grammar>`
+
+var grammarCacheTestRequestId mrcp.MRCPRequestId
+
+func grammarCacheTestRequestCreate(sessionId, contentId, body string) *message.MRCPMessage {
+	grammarCacheTestRequestId++
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{
+		MessageType: message.MRCP_MESSAGE_TYPE_REQUEST,
+		Version:     mrcp.MRCP_VERSION_2,
+		MethodName:  MRCP_DEFINE_GRAMMAR_METHOD_NAME,
+		RequestId:   grammarCacheTestRequestId,
+	}
+	request.ChannelId = header.MRCPChannelId{SessionId: sessionId, ResourceName: "speechrecog"}
+	request.Header.GenericHeaderAccessor.Data = &header.MRCPGenericHeader{ContentId: contentId, ContentType: "application/srgs+xml"}
+	request.Body = body
+	return request
+}
+
+/* Define grammar by the request responded with the status code */
+func grammarCacheTestDefine(c *MRCPClientGrammarCache, request *message.MRCPMessage, status message.MRCPStatusCode) {
+	response := message.MRCPResponseCreate(request)
+	response.StartLine.StatusCode = status
+	c.GrammarCacheUpdate(request, response)
+}
+
+func TestGrammarCacheHit(t *testing.T) {
+	c := MRCPClientGrammarCacheCreate()
+	request := grammarCacheTestRequestCreate("session-1", "yesno", grammarCacheTestContent)
+	if c.GrammarCacheCheck(request) != nil {
+		t.Fatal("grammar not defined is hit")
+	}
+	grammarCacheTestDefine(c, request, message.MRCP_STATUS_CODE_SUCCESS)
+
+	/* the same grammar is defined once per channel */
+	again := grammarCacheTestRequestCreate("session-1", "yesno", grammarCacheTestContent)
+	response := c.GrammarCacheCheck(again)
+	if response == nil || response.StartLine.MessageType != message.MRCP_MESSAGE_TYPE_RESPONSE ||
+		response.StartLine.RequestId != again.StartLine.RequestId || response.StartLine.StatusCode != message.MRCP_STATUS_CODE_SUCCESS {
+		t.Fatalf("response to redundant definition %+v", response)
+	}
+	hash := MRCPGrammarHash("application/srgs+xml", grammarCacheTestContent)
+	if grammar := c.GrammarCacheFind(request.ChannelId, hash); grammar == nil || grammar.ContentId != "yesno" {
+		t.Fatalf("grammar found %+v", grammar)
+	}
+
+	for _, miss := range []*message.MRCPMessage{
+		/* another channel */
+		grammarCacheTestRequestCreate("session-2", "yesno", grammarCacheTestContent),
+		/* another Content-Id */
+		grammarCacheTestRequestCreate("session-1", "digits", grammarCacheTestContent),
+		/* changed content */
+		grammarCacheTestRequestCreate("session-1", "yesno", `<grammar root="no"/>`),
+		/* not a definition */
+		grammarCacheTestRequestCreate("session-1", "", grammarCacheTestContent),
+	} {
+		if c.GrammarCacheCheck(miss) != nil {
+			t.Fatalf("request %+v of body %s is hit", miss.MRCPGenericHeaderGet(), miss.Body)
+		}
+	}
+
+	/* success with ignore defines the grammar too */
+	digits := grammarCacheTestRequestCreate("session-1", "digits", `<grammar root="digits"/>`)
+	grammarCacheTestDefine(c, digits, message.MRCP_STATUS_CODE_SUCCESS_WITH_IGNORE)
+	if c.GrammarCacheCheck(digits) == nil {
+		t.Fatal("grammar defined with ignore is not hit")
+	}
+}
+
+func TestGrammarCacheInvalidation(t *testing.T) {
+	c := MRCPClientGrammarCacheCreate()
+	request := grammarCacheTestRequestCreate("session-1", "yesno", grammarCacheTestContent)
+	grammarCacheTestDefine(c, request, message.MRCP_STATUS_CODE_SUCCESS)
+
+	/* redefinition replaces the grammar of the Content-Id */
+	changed := grammarCacheTestRequestCreate("session-1", "yesno", `<grammar root="no"/>`)
+	grammarCacheTestDefine(c, changed, message.MRCP_STATUS_CODE_SUCCESS)
+	if c.GrammarCacheCheck(request) != nil || c.GrammarCacheCheck(changed) == nil {
+		t.Fatal("replaced grammar is hit")
+	}
+
+	/* failed redefinition invalidates the grammar */
+	grammarCacheTestDefine(c, request, message.MRCP_STATUS_CODE_METHOD_FAILED)
+	if c.GrammarCacheCheck(request) != nil || c.GrammarCacheCheck(changed) != nil {
+		t.Fatal("invalidated grammar is hit")
+	}
+	if c.GrammarCacheFind(request.ChannelId, MRCPGrammarHash("application/srgs+xml", `<grammar root="no"/>`)) != nil {
+		t.Fatal("invalidated grammar is found")
+	}
+
+	/* failed definition of the grammar never defined */
+	failed := grammarCacheTestRequestCreate("session-2", "yesno", grammarCacheTestContent)
+	grammarCacheTestDefine(c, failed, message.MRCP_STATUS_CODE_ILLEGAL_PARAM_VALUE)
+	if c.GrammarCacheCheck(failed) != nil {
+		t.Fatal("failed grammar is hit")
+	}
+	c.GrammarCacheUpdate(failed, nil)
+}
+
+func TestGrammarCacheEviction(t *testing.T) {
+	c := MRCPClientGrammarCacheCreate()
+	requests := []*message.MRCPMessage{
+		grammarCacheTestRequestCreate("session-1", "yesno", grammarCacheTestContent),
+		grammarCacheTestRequestCreate("session-1", "yesno", grammarCacheTestContent),
+		grammarCacheTestRequestCreate("session-2", "yesno", grammarCacheTestContent),
+	}
+	/* the channels of the same session */
+	requests[1].ChannelId.ResourceName = "speakverify"
+	for _, request := range requests {
+		grammarCacheTestDefine(c, request, message.MRCP_STATUS_CODE_SUCCESS)
+	}
+
+	/* the grammars are evicted with the channel */
+	c.GrammarCacheChannelRemove(requests[0].ChannelId)
+	if c.GrammarCacheCheck(requests[0]) != nil || c.GrammarCacheCheck(requests[1]) == nil || c.GrammarCacheCheck(requests[2]) == nil {
+		t.Fatal("grammars of other channels are evicted")
+	}
+
+	/* and with all the channels of the session */
+	c.GrammarCacheSessionRemove("session-1")
+	if c.GrammarCacheCheck(requests[1]) != nil || c.GrammarCacheCheck(requests[2]) == nil {
+		t.Fatal("grammars of other sessions are evicted")
+	}
+	c.GrammarCacheSessionRemove("session-2")
+	if c.GrammarCacheCheck(requests[2]) != nil {
+		t.Fatal("grammar of terminated session is hit")
+	}
+}
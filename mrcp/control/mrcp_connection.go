@@ -0,0 +1,294 @@
+package control
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/** Reason of control connection disconnect */
+type MRCPDisconnectReason = int
+
+const (
+	MRCP_DISCONNECT_LOCAL       MRCPDisconnectReason = iota /**< closed locally */
+	MRCP_DISCONNECT_HALF_CLOSED                             /**< half-closed by the peer (FIN), closed as soon as pending messages are sent */
+	MRCP_DISCONNECT_ABRUPT                                  /**< failed (e.g. reset by the peer, EOF within message, write error) */
+)
+
+/** Get string of disconnect reason */
+func MRCPDisconnectReasonStr(reason MRCPDisconnectReason) string {
+	switch reason {
+	case MRCP_DISCONNECT_LOCAL:
+		return "closed locally"
+	case MRCP_DISCONNECT_HALF_CLOSED:
+		return "half-closed by peer"
+	case MRCP_DISCONNECT_ABRUPT:
+		return "connection failed"
+	}
+	return "unknown"
+}
+
+/** Max size of MRCPv2 message received */
+const MRCP_CONNECTION_MAX_MESSAGE_SIZE = 1 << 20
+
+/** Default time the requests in progress are still served for after the peer half-closes the connection */
+const MRCP_CONNECTION_DEFAULT_LINGER = 5 * time.Second
+
+/* States of control connection */
+const (
+	mrcpConnectionOpen = iota
+	mrcpConnectionHalfClosed
+	mrcpConnectionClosed
+)
+
+/**
+ * MRCPv2 control connection (TCP/TLS).
+ * Messages received are framed by the message-length of the start-line, messages sent are queued
+ * and written in order in the background. The peer half-closing the connection (FIN at message boundary)
+ * is not treated as failure: the pending messages are sent, and the responses and events of the requests
+ * received and not yet completed are still sent for up to Linger, then the connection is closed with
+ * MRCP_DISCONNECT_HALF_CLOSED reason.
+ */
+type MRCPConnection struct {
+	Id string // Connection identifier
+
+	/** Called for each message received (raw MRCPv2 message) */
+	OnMessage func(c *MRCPConnection, data []byte) error
+	/** Called for each message written to the connection (raw MRCPv2 message) [OPTIONAL] */
+	OnSend func(c *MRCPConnection, data []byte)
+	/** Called once as soon as the connection is closed [OPTIONAL] */
+	OnDisconnect func(c *MRCPConnection, reason MRCPDisconnectReason, err error)
+	/** Max time the requests in progress are still served for after the peer half-closes the connection */
+	Linger time.Duration
+
+	conn   net.Conn
+	reader *bufio.Reader
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	queue    [][]byte
+	inflight map[string]struct{}
+	state    int
+	lingers  bool
+	reason   MRCPDisconnectReason
+	err      error
+	done     chan struct{}
+}
+
+/**
+ * Create control connection, Start is to be called as soon as the handlers are set.
+ * @param id the connection identifier
+ * @param conn the connection
+ */
+func MRCPConnectionCreate(id string, conn net.Conn) *MRCPConnection {
+	c := &MRCPConnection{
+		Id:       id,
+		Linger:   MRCP_CONNECTION_DEFAULT_LINGER,
+		conn:     conn,
+		reader:   bufio.NewReader(conn),
+		inflight: make(map[string]struct{}),
+		done:     make(chan struct{}),
+	}
+	c.cond = sync.NewCond(&c.mutex)
+	return c
+}
+
+/** Start reading and writing messages */
+func (c *MRCPConnection) MRCPConnectionStart() {
+	go c.readRun()
+	go c.writeRun()
+}
+
+/**
+ * Send (queue) message.
+ * @param data the raw MRCPv2 message
+ */
+func (c *MRCPConnection) MRCPConnectionSend(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state == mrcpConnectionClosed || (c.state == mrcpConnectionHalfClosed && !c.served() && len(c.queue) == 0) {
+		return fmt.Errorf("connection %s is closed", c.Id)
+	}
+	c.queue = append(c.queue, data)
+	c.cond.Broadcast()
+	return nil
+}
+
+/** Close connection, the pending messages are discarded */
+func (c *MRCPConnection) MRCPConnectionClose() error {
+	c.disconnect(MRCP_DISCONNECT_LOCAL, nil)
+	<-c.done
+	return nil
+}
+
+/** Wait for connection to be closed, return the disconnect reason */
+func (c *MRCPConnection) MRCPConnectionWait() (MRCPDisconnectReason, error) {
+	<-c.done
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.reason, c.err
+}
+
+/* Check whether requests in progress are still served after half-close, called under the mutex */
+func (c *MRCPConnection) served() bool {
+	return c.lingers && len(c.inflight) > 0
+}
+
+/*
+ * Track the requests in progress by the start-line of message: request received is in progress
+ * ("MRCP/2.0 <length> <method> <request-id>") until response or event of COMPLETE request-state is sent
+ * ("MRCP/2.0 <length> <request-id> <status-code> <request-state>",
+ * "MRCP/2.0 <length> <event> <request-id> <request-state>").
+ */
+func (c *MRCPConnection) inflightTrack(data []byte, received bool) {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	fields := strings.Fields(string(line))
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if received {
+		if len(fields) == 4 {
+			c.inflight[fields[3]] = struct{}{}
+		}
+		return
+	}
+	if len(fields) == 5 && fields[4] == "COMPLETE" {
+		if _, err := strconv.Atoi(fields[2]); err == nil {
+			delete(c.inflight, fields[2])
+		} else {
+			delete(c.inflight, fields[3])
+		}
+		c.cond.Broadcast()
+	}
+}
+
+/* Read message framed by the message-length of the start-line ("MRCP/2.0 <message-length> ...") */
+func (c *MRCPConnection) messageRead() ([]byte, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	if len(fields) < 2 || !strings.HasPrefix(fields[0], "MRCP/") {
+		return nil, fmt.Errorf("invalid start-line %q", strings.TrimSpace(line))
+	}
+	length, err := strconv.Atoi(fields[1])
+	if err != nil || length < len(line) || length > MRCP_CONNECTION_MAX_MESSAGE_SIZE {
+		return nil, fmt.Errorf("invalid message-length %q", fields[1])
+	}
+	data := make([]byte, length)
+	copy(data, line)
+	if _, err := io.ReadFull(c.reader, data[len(line):]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+func (c *MRCPConnection) readRun() {
+	for {
+		data, err := c.messageRead()
+		if err == io.EOF {
+			c.halfClose()
+			return
+		}
+		if err != nil {
+			c.disconnect(MRCP_DISCONNECT_ABRUPT, err)
+			return
+		}
+		c.inflightTrack(data, true)
+		if c.OnMessage != nil {
+			if err := c.OnMessage(c, data); err != nil {
+				c.disconnect(MRCP_DISCONNECT_ABRUPT, err)
+				return
+			}
+		}
+	}
+}
+
+/* Peer half-closed the connection, the pending messages are sent before close */
+func (c *MRCPConnection) halfClose() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.state != mrcpConnectionOpen {
+		return
+	}
+	c.state = mrcpConnectionHalfClosed
+	if c.Linger > 0 {
+		c.lingers = true
+		time.AfterFunc(c.Linger, func() {
+			c.mutex.Lock()
+			c.lingers = false
+			c.cond.Broadcast()
+			c.mutex.Unlock()
+		})
+	}
+	c.cond.Broadcast()
+}
+
+func (c *MRCPConnection) writeRun() {
+	for {
+		c.mutex.Lock()
+		for len(c.queue) == 0 && (c.state == mrcpConnectionOpen || (c.state == mrcpConnectionHalfClosed && c.served())) {
+			c.cond.Wait()
+		}
+		if c.state == mrcpConnectionClosed {
+			c.mutex.Unlock()
+			return
+		}
+		if len(c.queue) == 0 {
+			/* half-closed, drained and no request is served */
+			c.mutex.Unlock()
+			if closer, ok := c.conn.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+			c.disconnect(MRCP_DISCONNECT_HALF_CLOSED, nil)
+			return
+		}
+		data := c.queue[0]
+		c.queue = c.queue[1:]
+		c.mutex.Unlock()
+		if _, err := c.conn.Write(data); err != nil {
+			c.disconnect(MRCP_DISCONNECT_ABRUPT, err)
+			return
+		}
+		c.inflightTrack(data, false)
+		if c.OnSend != nil {
+			c.OnSend(c, data)
+		}
+	}
+}
+
+func (c *MRCPConnection) disconnect(reason MRCPDisconnectReason, err error) {
+	c.mutex.Lock()
+	if c.state == mrcpConnectionClosed {
+		c.mutex.Unlock()
+		return
+	}
+	c.state = mrcpConnectionClosed
+	c.reason = reason
+	c.err = err
+	c.queue = nil
+	c.cond.Broadcast()
+	c.mutex.Unlock()
+
+	c.conn.Close()
+	/* done is closed first, so that the handler may close or wait for the connection */
+	close(c.done)
+	if c.OnDisconnect != nil {
+		c.OnDisconnect(c, reason, err)
+	}
+}
@@ -0,0 +1,176 @@
+package control
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* Create raw MRCPv2 message of the start-line following the message-length */
+func connectionTestMessageCreate(startLine string) []byte {
+	text := " " + startLine + "\r\nChannel-Identifier: connection@speechsynth\r\n\r\n"
+	length := len("MRCP/2.0 ") + len(text)
+	length += len(strconv.Itoa(length))
+	return []byte("MRCP/2.0 " + strconv.Itoa(length) + text)
+}
+
+/* Create connection of TCP loopback, return the connection and the peer */
+func connectionTestCreate(t *testing.T) (*MRCPConnection, *net.TCPConn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	peer, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := listener.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return MRCPConnectionCreate("connection", conn), peer.(*net.TCPConn)
+}
+
+/* Read start-lines of messages until the connection is closed */
+func connectionTestStartLinesRead(peer net.Conn) []string {
+	var lines []string
+	reader := bufio.NewReader(peer)
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return lines
+		}
+		if strings.HasPrefix(line, "MRCP/") {
+			fields := strings.Fields(line)
+			lines = append(lines, strings.Join(fields[2:], " "))
+		}
+	}
+}
+
+func connectionTestWait(t *testing.T, c *MRCPConnection) (MRCPDisconnectReason, error) {
+	waited := make(chan struct{})
+	var (
+		reason MRCPDisconnectReason
+		err    error
+	)
+	go func() {
+		reason, err = c.MRCPConnectionWait()
+		close(waited)
+	}()
+	select {
+	case <-waited:
+	case <-time.After(5 * time.Second):
+		t.Fatal("connection is not closed")
+	}
+	return reason, err
+}
+
+func TestConnectionHalfCloseInFlight(t *testing.T) {
+	c, peer := connectionTestCreate(t)
+	defer peer.Close()
+	/* the request is completed after the peer half-closes the connection */
+	c.OnMessage = func(c *MRCPConnection, data []byte) error {
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			c.MRCPConnectionSend(connectionTestMessageCreate("1 200 IN-PROGRESS"))
+			time.Sleep(50 * time.Millisecond)
+			c.MRCPConnectionSend(connectionTestMessageCreate("SPEAK-COMPLETE 1 COMPLETE"))
+		}()
+		return nil
+	}
+	c.MRCPConnectionStart()
+
+	if _, err := peer.Write(connectionTestMessageCreate("SPEAK 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	lines := connectionTestStartLinesRead(peer)
+	if strings.Join(lines, "|") != "1 200 IN-PROGRESS|SPEAK-COMPLETE 1 COMPLETE" {
+		t.Fatalf("messages received %q", lines)
+	}
+	if reason, err := connectionTestWait(t, c); reason != MRCP_DISCONNECT_HALF_CLOSED || err != nil {
+		t.Fatalf("disconnected: %s, %v", MRCPDisconnectReasonStr(reason), err)
+	}
+	if err := c.MRCPConnectionSend(connectionTestMessageCreate("2 200 COMPLETE")); err == nil {
+		t.Fatal("message is sent on closed connection")
+	}
+}
+
+func TestConnectionHalfCloseLinger(t *testing.T) {
+	c, peer := connectionTestCreate(t)
+	defer peer.Close()
+	c.Linger = 100 * time.Millisecond
+	c.OnMessage = func(c *MRCPConnection, data []byte) error {
+		return c.MRCPConnectionSend(connectionTestMessageCreate("1 200 IN-PROGRESS"))
+	}
+	c.MRCPConnectionStart()
+
+	/* the request never completed is served for the linger only */
+	started := time.Now()
+	if _, err := peer.Write(connectionTestMessageCreate("SPEAK 1")); err != nil {
+		t.Fatal(err)
+	}
+	if err := peer.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if reason, err := connectionTestWait(t, c); reason != MRCP_DISCONNECT_HALF_CLOSED || err != nil {
+		t.Fatalf("disconnected: %s, %v", MRCPDisconnectReasonStr(reason), err)
+	}
+	if elapsed := time.Since(started); elapsed < c.Linger {
+		t.Fatalf("closed in %v before linger", elapsed)
+	}
+	if lines := connectionTestStartLinesRead(peer); len(lines) != 1 || lines[0] != "1 200 IN-PROGRESS" {
+		t.Fatalf("messages received %q", lines)
+	}
+
+	/* no request in progress, closed as soon as half-closed */
+	c, peer = connectionTestCreate(t)
+	defer peer.Close()
+	c.MRCPConnectionStart()
+	started = time.Now()
+	if err := peer.CloseWrite(); err != nil {
+		t.Fatal(err)
+	}
+	if reason, _ := connectionTestWait(t, c); reason != MRCP_DISCONNECT_HALF_CLOSED {
+		t.Fatalf("disconnected: %s", MRCPDisconnectReasonStr(reason))
+	}
+	if elapsed := time.Since(started); elapsed >= c.Linger {
+		t.Fatalf("closed in %v, no request is in progress", elapsed)
+	}
+}
+
+func TestConnectionErrorDisconnect(t *testing.T) {
+	local, peer := net.Pipe()
+	defer peer.Close()
+	c := MRCPConnectionCreate("connection", local)
+	disconnected := make(chan MRCPDisconnectReason, 1)
+	/* the handler closing the connection does not deadlock */
+	c.OnDisconnect = func(c *MRCPConnection, reason MRCPDisconnectReason, err error) {
+		c.MRCPConnectionClose()
+		disconnected <- reason
+	}
+	c.MRCPConnectionStart()
+
+	go peer.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+	select {
+	case reason := <-disconnected:
+		if reason != MRCP_DISCONNECT_ABRUPT {
+			t.Fatalf("disconnected: %s", MRCPDisconnectReasonStr(reason))
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler is not called")
+	}
+	if _, err := c.MRCPConnectionWait(); err == nil || !strings.Contains(err.Error(), "invalid start-line") {
+		t.Fatalf("error %v", err)
+	}
+	/* closed once */
+	if err := c.MRCPConnectionClose(); err != nil {
+		t.Fatal(err)
+	}
+}
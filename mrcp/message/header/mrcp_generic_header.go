@@ -1,6 +1,8 @@
 package header
 
 import (
+	"fmt"
+
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/toolkit"
 )
@@ -80,12 +82,34 @@ func MRCPGetGenericHeaderVTableGet(version mrcp.Version) *MRCPHeaderVTable {
 	return nil
 }
 
+/** Get number of request identifiers in the list */
+func (l *MRCPRequestIdList) MRCPRequestIdListCount() int {
+	return int(l.count)
+}
+
+/** Get request identifier by index */
+func (l *MRCPRequestIdList) MRCPRequestIdListGet(index int) mrcp.MRCPRequestId {
+	return l.ids[index]
+}
+
 /** Append active request id list */
 func (h *MRCPGenericHeader) ActiveRequestIdListAppend(requestId mrcp.MRCPRequestId) error {
+	list := &h.ActiveRequestIdList
+	if list.count >= __MAX_ACTIVE_REQUEST_ID_COUNT {
+		return fmt.Errorf("active request id list is full, request id %d is not appended", requestId)
+	}
+	list.ids[list.count] = requestId
+	list.count++
 	return nil
 }
 
 /** Find request id in active request id list */
 func (h *MRCPGenericHeader) ActiveRequestIdListFind(requestId mrcp.MRCPRequestId) error {
-	return nil
+	list := &h.ActiveRequestIdList
+	for i := int64(0); i < list.count; i++ {
+		if list.ids[i] == requestId {
+			return nil
+		}
+	}
+	return fmt.Errorf("request id %d is not found in active request id list", requestId)
 }
@@ -41,8 +41,30 @@ func (header *MRCPMessageHeader) MRCPMessageHeaderDestroy() {
 	MRCPHeaderDestroy(&header.ResourceHeaderAccessor)
 }
 
-/** Add MRCP header field */
+/** Add MRCP header field, fields unknown (e.g. extension or vendor headers) are kept byte-exact in the order added */
 func (header *MRCPMessageHeader) MRCPHeaderFieldAdd(field *toolkit.AptHeaderField) error {
+	return header.HeaderSection.AptHeaderSectionFieldAdd(field)
+}
+
+/** Get MRCP header fields unknown in the order received */
+func (header *MRCPMessageHeader) MRCPUnknownHeaderFieldsGet() []*toolkit.AptHeaderField {
+	return header.HeaderSection.AptHeaderSectionUnknownFieldsGet()
+}
+
+/**
+ * Add (copy) MRCP header fields unknown of the source header, e.g. to echo them in response or to forward them.
+ * @param srcHeader the header to copy fields from
+ * @param filter the function selecting fields by name (all fields are copied if nil)
+ */
+func (header *MRCPMessageHeader) MRCPUnknownHeaderFieldsInherit(srcHeader *MRCPMessageHeader, filter func(name string) bool) error {
+	for _, field := range srcHeader.MRCPUnknownHeaderFieldsGet() {
+		if filter != nil && !filter(field.Name) {
+			continue
+		}
+		if err := header.MRCPHeaderFieldAdd(toolkit.AptHeaderFieldCopy(field)); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -0,0 +1,271 @@
+package header
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/mrcp"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/**
+ * Table of typed header fields: the names of fields by numeric identifier, and the check and parse
+ * of the field values of typed header data (e.g. *MRCPGenericHeader), so that the fields set by name
+ * are seen by the readers of typed header.
+ */
+type MRCPHeaderFieldTable struct {
+	/** Names of fields indexed by numeric identifier */
+	Names []string
+	/** Allocate header data */
+	Allocate func() interface{}
+	/** Check whether field value is set (not default) in header data */
+	Check func(data interface{}, id int64) bool
+	/** Parse field value to header data */
+	Parse func(data interface{}, id int64, value string) error
+}
+
+/**
+ * Find numeric identifier of field by name (case insensitive).
+ * @param name the name of field
+ * @return the numeric identifier, -1 if not found
+ */
+func (t *MRCPHeaderFieldTable) MRCPHeaderFieldIdFind(name string) int64 {
+	for id, fieldName := range t.Names {
+		if strings.EqualFold(fieldName, name) {
+			return int64(id)
+		}
+	}
+	return -1
+}
+
+/** Parse integer value of header field */
+func MRCPHeaderIntParse(value string) (int64, error) {
+	return strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+}
+
+/** Parse float value of header field */
+func MRCPHeaderFloatParse(value string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(value), 64)
+}
+
+/** Parse boolean value of header field (true/false) */
+func MRCPHeaderBoolParse(value string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value [%s]", value)
+}
+
+/** Names of MRCP generic header fields */
+var mrcpGenericHeaderFieldNames = [GENERIC_HEADER_COUNT]string{
+	GENERIC_HEADER_ACTIVE_REQUEST_ID_LIST: "Active-Request-Id-List",
+	GENERIC_HEADER_PROXY_SYNC_ID:          "Proxy-Sync-Id",
+	GENERIC_HEADER_ACCEPT_CHARSET:         "Accept-Charset",
+	GENERIC_HEADER_CONTENT_TYPE:           "Content-Type",
+	GENERIC_HEADER_CONTENT_ID:             "Content-Id",
+	GENERIC_HEADER_CONTENT_BASE:           "Content-Base",
+	GENERIC_HEADER_CONTENT_ENCODING:       "Content-Encoding",
+	GENERIC_HEADER_CONTENT_LOCATION:       "Content-Location",
+	GENERIC_HEADER_CONTENT_LENGTH:         "Content-Length",
+	GENERIC_HEADER_CACHE_CONTROL:          "Cache-Control",
+	GENERIC_HEADER_LOGGING_TAG:            "Logging-Tag",
+	GENERIC_HEADER_VENDOR_SPECIFIC_PARAMS: "Vendor-Specific-Parameters",
+	GENERIC_HEADER_ACCEPT:                 "Accept",
+	GENERIC_HEADER_FETCH_TIMEOUT:          "Fetch-Timeout",
+	GENERIC_HEADER_SET_COOKIE:             "Set-Cookie",
+	GENERIC_HEADER_SET_COOKIE2:            "Set-Cookie2",
+}
+
+var mrcpGenericHeaderFieldTable = &MRCPHeaderFieldTable{
+	Names:    mrcpGenericHeaderFieldNames[:],
+	Allocate: func() interface{} { return &MRCPGenericHeader{} },
+	Check:    mrcpGenericHeaderFieldCheck,
+	Parse:    mrcpGenericHeaderFieldParse,
+}
+
+/** Get table of MRCP generic header fields */
+func MRCPGenericHeaderFieldTableGet() *MRCPHeaderFieldTable {
+	return mrcpGenericHeaderFieldTable
+}
+
+/* Check whether generic header field is set */
+func mrcpGenericHeaderFieldCheck(data interface{}, id int64) bool {
+	h, ok := data.(*MRCPGenericHeader)
+	if !ok || h == nil {
+		return false
+	}
+	switch MRCPGenericHeaderId(id) {
+	case GENERIC_HEADER_ACTIVE_REQUEST_ID_LIST:
+		return h.ActiveRequestIdList.count > 0
+	case GENERIC_HEADER_PROXY_SYNC_ID:
+		return h.ProxySyncId != ""
+	case GENERIC_HEADER_ACCEPT_CHARSET:
+		return h.AcceptCharset != ""
+	case GENERIC_HEADER_CONTENT_TYPE:
+		return h.ContentType != ""
+	case GENERIC_HEADER_CONTENT_ID:
+		return h.ContentId != ""
+	case GENERIC_HEADER_CONTENT_BASE:
+		return h.contentBase != ""
+	case GENERIC_HEADER_CONTENT_ENCODING:
+		return h.ContentEncoding != ""
+	case GENERIC_HEADER_CONTENT_LOCATION:
+		return h.ContentLocation != ""
+	case GENERIC_HEADER_CONTENT_LENGTH:
+		return h.ContentLength != 0
+	case GENERIC_HEADER_CACHE_CONTROL:
+		return h.CacheControl != ""
+	case GENERIC_HEADER_LOGGING_TAG:
+		return h.LoggingTag != ""
+	case GENERIC_HEADER_VENDOR_SPECIFIC_PARAMS:
+		return h.VendorSpecificParams != nil && toolkit.AptPairArraySize(h.VendorSpecificParams) > 0
+	case GENERIC_HEADER_ACCEPT:
+		return h.accept != ""
+	case GENERIC_HEADER_FETCH_TIMEOUT:
+		return h.FetchTimeout != 0
+	case GENERIC_HEADER_SET_COOKIE:
+		return h.SetCookie != ""
+	case GENERIC_HEADER_SET_COOKIE2:
+		return h.SetCookie2 != ""
+	}
+	return false
+}
+
+/* Parse generic header field value */
+func mrcpGenericHeaderFieldParse(data interface{}, id int64, value string) error {
+	h, ok := data.(*MRCPGenericHeader)
+	if !ok || h == nil {
+		return fmt.Errorf("header data %T is not generic header", data)
+	}
+	var err error
+	switch MRCPGenericHeaderId(id) {
+	case GENERIC_HEADER_ACTIVE_REQUEST_ID_LIST:
+		list := MRCPRequestIdList{}
+		for _, item := range strings.Split(value, ",") {
+			requestId, err := strconv.ParseUint(strings.TrimSpace(item), 10, 32)
+			if err != nil {
+				return fmt.Errorf("invalid request id [%s] of active request id list", item)
+			}
+			if list.count >= __MAX_ACTIVE_REQUEST_ID_COUNT {
+				return fmt.Errorf("too many request ids of active request id list [%s]", value)
+			}
+			list.ids[list.count] = mrcp.MRCPRequestId(requestId)
+			list.count++
+		}
+		h.ActiveRequestIdList = list
+	case GENERIC_HEADER_PROXY_SYNC_ID:
+		h.ProxySyncId = value
+	case GENERIC_HEADER_ACCEPT_CHARSET:
+		h.AcceptCharset = value
+	case GENERIC_HEADER_CONTENT_TYPE:
+		h.ContentType = value
+	case GENERIC_HEADER_CONTENT_ID:
+		h.ContentId = value
+	case GENERIC_HEADER_CONTENT_BASE:
+		h.contentBase = value
+	case GENERIC_HEADER_CONTENT_ENCODING:
+		h.ContentEncoding = value
+	case GENERIC_HEADER_CONTENT_LOCATION:
+		h.ContentLocation = value
+	case GENERIC_HEADER_CONTENT_LENGTH:
+		h.ContentLength, err = MRCPHeaderIntParse(value)
+	case GENERIC_HEADER_CACHE_CONTROL:
+		h.CacheControl = value
+	case GENERIC_HEADER_LOGGING_TAG:
+		h.LoggingTag = value
+	case GENERIC_HEADER_VENDOR_SPECIFIC_PARAMS:
+		/* name=value pairs separated by semicolon */
+		items := strings.Split(value, ";")
+		params := toolkit.AptPairArrayCreate(len(items))
+		for _, item := range items {
+			if item = strings.TrimSpace(item); item == "" {
+				continue
+			}
+			name, paramValue := item, ""
+			if i := strings.IndexByte(item, '='); i >= 0 {
+				name, paramValue = strings.TrimSpace(item[:i]), strings.TrimSpace(item[i+1:])
+			}
+			toolkit.AptPairArrayAppend(params, name, paramValue)
+		}
+		h.VendorSpecificParams = params
+	case GENERIC_HEADER_ACCEPT:
+		h.accept = value
+	case GENERIC_HEADER_FETCH_TIMEOUT:
+		h.FetchTimeout, err = MRCPHeaderIntParse(value)
+	case GENERIC_HEADER_SET_COOKIE:
+		h.SetCookie = value
+	case GENERIC_HEADER_SET_COOKIE2:
+		h.SetCookie2 = value
+	default:
+		return fmt.Errorf("unknown generic header field %d", id)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value [%s] of %s", value, mrcpGenericHeaderFieldNames[id])
+	}
+	return nil
+}
+
+/**
+ * Check whether field value of typed header is set (not zero).
+ * @param value the pointer to the field value (*string, *int64, *int, *float64, *bool or *byte)
+ */
+func MRCPHeaderFieldValueCheck(value interface{}) bool {
+	switch v := value.(type) {
+	case *string:
+		return *v != ""
+	case *int64:
+		return *v != 0
+	case *int:
+		return *v != 0
+	case *float64:
+		return *v != 0
+	case *bool:
+		return *v
+	case *byte:
+		return *v != 0
+	}
+	return false
+}
+
+/**
+ * Parse field value of typed header.
+ * @param value the pointer to the field value (*string, *int64, *int, *float64, *bool or *byte)
+ * @param text the text of the value, of a code (*int) the text following the code is ignored, e.g. "001 no-match"
+ */
+func MRCPHeaderFieldValueParse(value interface{}, text string) error {
+	var err error
+	switch v := value.(type) {
+	case *string:
+		*v = text
+	case *int64:
+		*v, err = MRCPHeaderIntParse(text)
+	case *int:
+		var code int64
+		if fields := strings.Fields(text); len(fields) > 0 {
+			code, err = MRCPHeaderIntParse(fields[0])
+		} else {
+			err = fmt.Errorf("empty code")
+		}
+		*v = int(code)
+	case *float64:
+		*v, err = MRCPHeaderFloatParse(text)
+	case *bool:
+		*v, err = MRCPHeaderBoolParse(text)
+	case *byte:
+		if text = strings.TrimSpace(text); len(text) != 1 {
+			err = fmt.Errorf("not a single character")
+		} else {
+			*v = text[0]
+		}
+	default:
+		return fmt.Errorf("field value %T is not supported", value)
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value [%s]: %v", text, err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package message
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+/**
+ * Default header fields of resource, e.g. Confidence-Threshold of speechrecog or Voice-Name of speechsynth,
+ * added to outgoing requests (client) or assumed for the fields missing in requests received (server).
+ */
+type MRCPHeaderProfile struct {
+	ResourceName string              // MRCP resource name
+	Methods      []string            // Names of methods the profile applies to (all requests if empty)
+	Fields       *toolkit.AptPairArr // Default header fields (name-value pairs) in the order added
+}
+
+/**
+ * Create header profile.
+ * @param resourceName the MRCP resource name
+ * @param fields the default header fields, added in the order of names
+ * @param methods the names of methods the profile applies to (all requests if none)
+ */
+func MRCPHeaderProfileCreate(resourceName string, fields map[string]string, methods ...string) *MRCPHeaderProfile {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	profile := &MRCPHeaderProfile{
+		ResourceName: resourceName,
+		Methods:      methods,
+		Fields:       toolkit.AptPairArrayCreate(len(names)),
+	}
+	for _, name := range names {
+		toolkit.AptPairArrayAppend(profile.Fields, name, fields[name])
+	}
+	return profile
+}
+
+/* Check whether profile applies to the method */
+func (profile *MRCPHeaderProfile) methodMatch(methodName string) bool {
+	if len(profile.Methods) == 0 {
+		return true
+	}
+	for _, name := range profile.Methods {
+		if strings.EqualFold(name, methodName) {
+			return true
+		}
+	}
+	return false
+}
+
+/**
+ * Header profiles of resources. The default fields are resolved by name to the generic header fields
+ * or to the fields of resource header table set, so that they are seen by the readers of typed headers.
+ */
+type MRCPHeaderProfiles struct {
+	mutex    sync.RWMutex
+	profiles map[string][]*MRCPHeaderProfile
+	tables   map[string]*header.MRCPHeaderFieldTable
+}
+
+/** Create header profiles */
+func MRCPHeaderProfilesCreate() *MRCPHeaderProfiles {
+	return &MRCPHeaderProfiles{
+		profiles: make(map[string][]*MRCPHeaderProfile),
+		tables:   make(map[string]*header.MRCPHeaderFieldTable),
+	}
+}
+
+/**
+ * Set table of resource header fields, e.g. resources.MRCPRecognizerHeaderFieldTableGet() of speechrecog.
+ * The fields of profiles not found in the generic and resource tables are added as unknown fields.
+ * @param resourceName the MRCP resource name
+ * @param table the table of resource header fields
+ */
+func (p *MRCPHeaderProfiles) MRCPHeaderFieldTableSet(resourceName string, table *header.MRCPHeaderFieldTable) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.tables[strings.ToLower(resourceName)] = table
+}
+
+/* Header field of profile resolved */
+type mrcpProfileField struct {
+	table    *header.MRCPHeaderFieldTable // Table of the field, nil if unknown
+	id       int64                        // Numeric identifier in the table
+	resource bool                         // Whether field is of resource header (generic header otherwise)
+}
+
+/* Resolve header field of profile by name */
+func (p *MRCPHeaderProfiles) fieldResolve(resourceName, name string) mrcpProfileField {
+	generic := header.MRCPGenericHeaderFieldTableGet()
+	if id := generic.MRCPHeaderFieldIdFind(name); id >= 0 {
+		return mrcpProfileField{table: generic, id: id}
+	}
+	p.mutex.RLock()
+	table := p.tables[strings.ToLower(resourceName)]
+	p.mutex.RUnlock()
+	if table != nil {
+		if id := table.MRCPHeaderFieldIdFind(name); id >= 0 {
+			return mrcpProfileField{table: table, id: id, resource: true}
+		}
+	}
+	return mrcpProfileField{id: toolkit.UNKNOWN_HEADER_FIELD_ID}
+}
+
+/**
+ * Add header profile, the profiles of resource are applied in the order added (the first default wins).
+ * @param profile the profile to add
+ */
+func (p *MRCPHeaderProfiles) MRCPHeaderProfileAdd(profile *MRCPHeaderProfile) error {
+	if profile.ResourceName == "" {
+		return fmt.Errorf("no resource name of header profile")
+	}
+	for i := 0; i < toolkit.AptPairArraySize(profile.Fields); i++ {
+		if pair := toolkit.AptPairArrayGet(profile.Fields, i); pair == nil || pair.Name == "" || strings.ContainsAny(pair.Name, ": \t\r\n") {
+			return fmt.Errorf("invalid header field of %s profile", profile.ResourceName)
+		}
+	}
+	/* the values of the fields known are checked beforehand, not to fail the requests applied to */
+	for i := 0; i < toolkit.AptPairArraySize(profile.Fields); i++ {
+		pair := toolkit.AptPairArrayGet(profile.Fields, i)
+		if field := p.fieldResolve(profile.ResourceName, pair.Name); field.table != nil {
+			if err := field.table.Parse(field.table.Allocate(), field.id, pair.Value); err != nil {
+				return fmt.Errorf("invalid header field of %s profile: %v", profile.ResourceName, err)
+			}
+		}
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	key := strings.ToLower(profile.ResourceName)
+	p.profiles[key] = append(p.profiles[key], profile)
+	return nil
+}
+
+/**
+ * Remove header profiles of resource.
+ * @param resourceName the MRCP resource name
+ */
+func (p *MRCPHeaderProfiles) MRCPHeaderProfilesRemove(resourceName string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.profiles, strings.ToLower(resourceName))
+}
+
+/* Check whether header field is set in message by name (case insensitive) */
+func (m *MRCPMessage) headerFieldNameCheck(name string) bool {
+	for field := m.MRCPMessageNextHeaderFieldGet(nil); field != nil; field = m.MRCPMessageNextHeaderFieldGet(field) {
+		if strings.EqualFold(field.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+/* Check whether header field resolved is set in message, either in header section or in typed header */
+func (m *MRCPMessage) profileFieldCheck(field mrcpProfileField, name string) bool {
+	if m.headerFieldNameCheck(name) {
+		return true
+	}
+	switch {
+	case field.table == nil:
+		return false
+	case field.resource:
+		return m.MRCPResourceHeaderPropertyCheck(field.id) || field.table.Check(m.MRCPResourceHeaderGet(), field.id)
+	}
+	return m.MRCPGenericHeaderPropertyCheck(field.id) || field.table.Check(m.MRCPGenericHeaderGet(), field.id)
+}
+
+/* Set header field resolved in message, both in header section and in typed header */
+func (m *MRCPMessage) profileFieldSet(field mrcpProfileField, name, value string) error {
+	if field.table == nil {
+		return m.MRCPMessageHeaderFieldAdd(toolkit.AptHeaderFieldCreate(name, value, toolkit.UNKNOWN_HEADER_FIELD_ID))
+	}
+	id := field.id
+	var data interface{}
+	if field.resource {
+		if data = m.MRCPResourceHeaderPrepare(); data == nil {
+			/* no accessor vtable is set, allocate resource header of the table */
+			data = field.table.Allocate()
+			m.Header.ResourceHeaderAccessor.Data = data
+		}
+		id += int64(header.GENERIC_HEADER_COUNT)
+	} else {
+		data = m.MRCPGenericHeaderPrepare()
+	}
+	if err := field.table.Parse(data, field.id, value); err != nil {
+		return err
+	}
+	return m.MRCPMessageHeaderFieldAdd(toolkit.AptHeaderFieldCreate(field.table.Names[field.id], value, id))
+}
+
+/**
+ * Apply header profiles of the resource of request, the default header fields missing in request are added.
+ * A field is missing if it is neither in the header section nor set in the typed generic or resource header,
+ * and it is added to both, so that the engines reading typed headers see the defaults.
+ * Should be called for outgoing requests (client) or for requests received (server), other messages are left as is.
+ * @param message the request to apply profiles to
+ * @return the number of header fields added
+ */
+func (p *MRCPHeaderProfiles) MRCPHeaderProfilesApply(message *MRCPMessage) (int, error) {
+	if message.StartLine == nil || message.StartLine.MessageType != MRCP_MESSAGE_TYPE_REQUEST {
+		return 0, nil
+	}
+	resourceName := message.ChannelId.ResourceName
+	if resourceName == "" {
+		resourceName = message.Resource.Name
+	}
+	p.mutex.RLock()
+	profiles := p.profiles[strings.ToLower(resourceName)]
+	p.mutex.RUnlock()
+
+	added := 0
+	for _, profile := range profiles {
+		if !profile.methodMatch(message.StartLine.MethodName) {
+			continue
+		}
+		for i := 0; i < toolkit.AptPairArraySize(profile.Fields); i++ {
+			pair := toolkit.AptPairArrayGet(profile.Fields, i)
+			field := p.fieldResolve(resourceName, pair.Name)
+			if message.profileFieldCheck(field, pair.Name) {
+				continue
+			}
+			if err := message.profileFieldSet(field, pair.Name, pair.Value); err != nil {
+				return added, err
+			}
+			added++
+		}
+	}
+	return added, nil
+}
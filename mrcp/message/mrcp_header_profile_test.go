@@ -0,0 +1,136 @@
+package message
+
+import (
+	"testing"
+
+	"github.com/navi-tt/go-mrcp/mrcp/message/header"
+	"github.com/navi-tt/go-mrcp/mrcp/resources"
+	"github.com/navi-tt/go-mrcp/toolkit"
+)
+
+func profileTestRequestCreate(methodName string) *MRCPMessage {
+	request := MRCPMessageCreate()
+	request.StartLine = &MRCPStartLine{MessageType: MRCP_MESSAGE_TYPE_REQUEST, MethodName: methodName}
+	request.ChannelId.ResourceName = "speechrecog"
+	return request
+}
+
+func profileTestProfilesCreate(t *testing.T) *MRCPHeaderProfiles {
+	profiles := MRCPHeaderProfilesCreate()
+	profiles.MRCPHeaderFieldTableSet("speechrecog", resources.MRCPRecognizerHeaderFieldTableGet())
+	profile := MRCPHeaderProfileCreate("speechrecog", map[string]string{
+		"Confidence-Threshold": "0.5",
+		"logging-tag":          "default",
+		"X-Vendor-Mode":        "fast",
+	}, "RECOGNIZE")
+	if err := profiles.MRCPHeaderProfileAdd(profile); err != nil {
+		t.Fatal(err)
+	}
+	return profiles
+}
+
+func TestHeaderProfileDefaultApplied(t *testing.T) {
+	profiles := profileTestProfilesCreate(t)
+	request := profileTestRequestCreate("RECOGNIZE")
+	added, err := profiles.MRCPHeaderProfilesApply(request)
+	if err != nil || added != 3 {
+		t.Fatalf("%d fields added: %v", added, err)
+	}
+	recogHeader, ok := request.MRCPResourceHeaderGet().(*resources.MRCPRecognizerHeader)
+	if !ok || recogHeader.ConfidenceThreshold != 0.5 {
+		t.Fatalf("resource header %+v", request.MRCPResourceHeaderGet())
+	}
+	if genericHeader := request.MRCPGenericHeaderGet(); genericHeader == nil || genericHeader.LoggingTag != "default" {
+		t.Fatalf("generic header %+v", genericHeader)
+	}
+	/* the fields known are added by id with the names of the tables */
+	if !request.MRCPResourceHeaderPropertyCheck(int64(resources.RECOGNIZER_HEADER_CONFIDENCE_THRESHOLD)) ||
+		!request.MRCPGenericHeaderPropertyCheck(int64(header.GENERIC_HEADER_LOGGING_TAG)) {
+		t.Fatal("fields are not added by id")
+	}
+	unknown := request.MRCPUnknownHeaderFieldsGet()
+	if len(unknown) != 1 || unknown[0].Name != "X-Vendor-Mode" || unknown[0].Value != "fast" {
+		t.Fatalf("unknown fields %+v", unknown)
+	}
+	want := "Confidence-Threshold: 0.5\r\nX-Vendor-Mode: fast\r\nLogging-Tag: default\r\n\r\n"
+	if text := request.MRCPMessageHeaderGenerate(); text != want {
+		t.Fatalf("header generated [%q]", text)
+	}
+
+	/* no field is added twice */
+	if added, err := profiles.MRCPHeaderProfilesApply(request); err != nil || added != 0 {
+		t.Fatalf("%d fields added again: %v", added, err)
+	}
+	/* the profile of other methods and messages other than requests are left as is */
+	if added, _ := profiles.MRCPHeaderProfilesApply(profileTestRequestCreate("SET-PARAMS")); added != 0 {
+		t.Fatalf("%d fields added to other method", added)
+	}
+	if added, _ := profiles.MRCPHeaderProfilesApply(MRCPResponseCreate(profileTestRequestCreate("RECOGNIZE"))); added != 0 {
+		t.Fatalf("%d fields added to response", added)
+	}
+}
+
+func TestHeaderProfileExplicitValue(t *testing.T) {
+	profiles := profileTestProfilesCreate(t)
+
+	/* the value set in typed headers only */
+	request := profileTestRequestCreate("RECOGNIZE")
+	request.Header.ResourceHeaderAccessor.Data = &resources.MRCPRecognizerHeader{ConfidenceThreshold: 0.8}
+	request.MRCPGenericHeaderPrepare().LoggingTag = "call-1"
+	added, err := profiles.MRCPHeaderProfilesApply(request)
+	if err != nil || added != 1 {
+		t.Fatalf("%d fields added: %v", added, err)
+	}
+	if recogHeader := request.MRCPResourceHeaderGet().(*resources.MRCPRecognizerHeader); recogHeader.ConfidenceThreshold != 0.8 {
+		t.Fatalf("confidence threshold %f", recogHeader.ConfidenceThreshold)
+	}
+	if request.MRCPGenericHeaderGet().LoggingTag != "call-1" {
+		t.Fatalf("logging tag %s", request.MRCPGenericHeaderGet().LoggingTag)
+	}
+	if request.MRCPResourceHeaderPropertyCheck(int64(resources.RECOGNIZER_HEADER_CONFIDENCE_THRESHOLD)) {
+		t.Fatal("default field is added to header section")
+	}
+
+	/* the value of the fields received, unknown or resolved by id */
+	request = profileTestRequestCreate("RECOGNIZE")
+	table := resources.MRCPRecognizerHeaderFieldTableGet()
+	err = request.MRCPMessageHeaderParse("confidence-threshold: 0.7\r\nX-Vendor-Mode: accurate\r\n", func(name string) int64 {
+		if id := table.MRCPHeaderFieldIdFind(name); id >= 0 {
+			return id + int64(header.GENERIC_HEADER_COUNT)
+		}
+		return toolkit.UNKNOWN_HEADER_FIELD_ID
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added, err = profiles.MRCPHeaderProfilesApply(request); err != nil || added != 1 {
+		t.Fatalf("%d fields added: %v", added, err)
+	}
+	if field := request.Header.HeaderSection.AptHeaderSectionFieldGet(int64(header.GENERIC_HEADER_LOGGING_TAG)); field == nil || field.Value != "default" {
+		t.Fatalf("logging tag field %+v", field)
+	}
+}
+
+func TestHeaderProfileInvalidValue(t *testing.T) {
+	profiles := MRCPHeaderProfilesCreate()
+	profiles.MRCPHeaderFieldTableSet("speechsynth", resources.MRCPSynthHeaderFieldTableGet())
+	if err := profiles.MRCPHeaderProfileAdd(MRCPHeaderProfileCreate("speechsynth", map[string]string{"Kill-On-Barge-In": "maybe"})); err == nil {
+		t.Fatal("profile of invalid value is added")
+	}
+	if err := profiles.MRCPHeaderProfileAdd(MRCPHeaderProfileCreate("speechsynth", map[string]string{"Fetch-Timeout": "soon"})); err == nil {
+		t.Fatal("profile of invalid generic value is added")
+	}
+	if err := profiles.MRCPHeaderProfileAdd(MRCPHeaderProfileCreate("speechsynth", map[string]string{"Voice-Name": "Anna", "Voice-Gender": "female"})); err != nil {
+		t.Fatal(err)
+	}
+	request := MRCPMessageCreate()
+	request.StartLine = &MRCPStartLine{MessageType: MRCP_MESSAGE_TYPE_REQUEST, MethodName: "SPEAK"}
+	request.ChannelId.ResourceName = "speechsynth"
+	if added, err := profiles.MRCPHeaderProfilesApply(request); err != nil || added != 2 {
+		t.Fatalf("%d fields added: %v", added, err)
+	}
+	voice := request.MRCPResourceHeaderGet().(*resources.MRCPSynthHeader).MRCPVoiceParamGet()
+	if voice.Name != "Anna" || voice.Gender != resources.VOICE_GENDER_FEMALE {
+		t.Fatalf("voice %+v", voice)
+	}
+}
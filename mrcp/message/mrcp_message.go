@@ -43,7 +43,21 @@ func MRCPRequestCreate(res *resource.MRCPResource, v mrcp.Version, mid mrcp.MRCP
  * @param pool the pool to allocate memory from
  */
 func MRCPResponseCreate(reqMessage *MRCPMessage) *MRCPMessage {
-	return &MRCPMessage{}
+	respMessage := MRCPMessageCreate()
+	if reqMessage.StartLine != nil {
+		respMessage.StartLine = &MRCPStartLine{
+			MessageType:  MRCP_MESSAGE_TYPE_RESPONSE,
+			Version:      reqMessage.StartLine.Version,
+			RequestId:    reqMessage.StartLine.RequestId,
+			MethodName:   reqMessage.StartLine.MethodName,
+			MethodId:     reqMessage.StartLine.MethodId,
+			StatusCode:   MRCP_STATUS_CODE_SUCCESS,
+			RequestState: MRCP_REQUEST_STATE_COMPLETE,
+		}
+	}
+	respMessage.ChannelId = reqMessage.ChannelId
+	respMessage.Resource = reqMessage.Resource
+	return respMessage
 }
 
 /**
@@ -53,7 +67,19 @@ func MRCPResponseCreate(reqMessage *MRCPMessage) *MRCPMessage {
  * @param pool the pool to allocate memory from
  */
 func MRCPEventCreate(reqMessage *MRCPMessage, evevtId mrcp.MRCPMethodId) *MRCPMessage {
-	return &MRCPMessage{}
+	eventMessage := MRCPMessageCreate()
+	if reqMessage.StartLine != nil {
+		eventMessage.StartLine = &MRCPStartLine{
+			MessageType:  MRCP_MESSAGE_TYPE_EVENT,
+			Version:      reqMessage.StartLine.Version,
+			RequestId:    reqMessage.StartLine.RequestId,
+			MethodId:     evevtId,
+			RequestState: MRCP_REQUEST_STATE_INPROGRESS,
+		}
+	}
+	eventMessage.ChannelId = reqMessage.ChannelId
+	eventMessage.Resource = reqMessage.Resource
+	return eventMessage
 }
 
 /**
@@ -86,7 +112,8 @@ func (m *MRCPMessage) MRCPMessageDestroy() error {
  * @param message the message to get generic header from
  */
 func (m *MRCPMessage) MRCPGenericHeaderGet() *header.MRCPGenericHeader {
-	return m.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	genericHeader, _ := m.Header.GenericHeaderAccessor.Data.(*header.MRCPGenericHeader)
+	return genericHeader
 }
 
 /**
@@ -94,7 +121,13 @@ func (m *MRCPMessage) MRCPGenericHeaderGet() *header.MRCPGenericHeader {
  * @param message the message to prepare generic header for
  */
 func (m *MRCPMessage) MRCPGenericHeaderPrepare() *header.MRCPGenericHeader {
-	return header.MRCPHeaderAllocate(&m.Header.GenericHeaderAccessor).(*header.MRCPGenericHeader)
+	if genericHeader, ok := header.MRCPHeaderAllocate(&m.Header.GenericHeaderAccessor).(*header.MRCPGenericHeader); ok && genericHeader != nil {
+		return genericHeader
+	}
+	/* no accessor vtable is set, allocate plain generic header */
+	genericHeader := &header.MRCPGenericHeader{}
+	m.Header.GenericHeaderAccessor.Data = genericHeader
+	return genericHeader
 }
 
 /**
@@ -215,5 +248,42 @@ func (m *MRCPMessage) MRCPMessageHeaderFieldAdd(headerField *toolkit.AptHeaderFi
  *  }
  */
 func (m *MRCPMessage) MRCPMessageNextHeaderFieldGet(headerField *toolkit.AptHeaderField) *toolkit.AptHeaderField {
-	return nil
+	return m.Header.HeaderSection.AptHeaderSectionNextFieldGet(headerField)
+}
+
+/**
+ * Get MRCP header fields unknown (extension or vendor headers) in the order received.
+ * @param message the message to use
+ * @remark The fields are kept byte-exact, so that they are generated as received unless changed
+ */
+func (m *MRCPMessage) MRCPUnknownHeaderFieldsGet() []*toolkit.AptHeaderField {
+	return m.Header.MRCPUnknownHeaderFieldsGet()
+}
+
+/**
+ * Echo (copy) MRCP header fields unknown of the request, e.g. to include them in response or to forward the request.
+ * @param message the message to add header fields for
+ * @param request the request to copy header fields from
+ * @param filter the function selecting fields by name (all fields are copied if nil)
+ */
+func (m *MRCPMessage) MRCPUnknownHeaderFieldsEcho(request *MRCPMessage, filter func(name string) bool) error {
+	return m.Header.MRCPUnknownHeaderFieldsInherit(&request.Header, filter)
+}
+
+/**
+ * Parse MRCP header section, the fields are added in the order received.
+ * @param message the message to parse header fields to
+ * @param text the header section, the fields terminated by CRLF
+ * @param resolve the function resolving numeric identifier of the field by name (may be nil, all fields are unknown then)
+ */
+func (m *MRCPMessage) MRCPMessageHeaderParse(text string, resolve func(name string) int64) error {
+	return m.Header.HeaderSection.AptHeaderSectionParse(text, resolve)
+}
+
+/**
+ * Generate MRCP header section, the fields in the order added followed by the empty line.
+ * @param message the message to generate header section of
+ */
+func (m *MRCPMessage) MRCPMessageHeaderGenerate() string {
+	return m.Header.HeaderSection.AptHeaderSectionGenerate()
 }
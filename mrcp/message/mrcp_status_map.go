@@ -0,0 +1,224 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+/** Internal failures translated to status codes of MRCP responses and SIP/RTSP final responses */
+type MRCPFailure = int
+
+const (
+	MRCP_FAILURE_NONE               MRCPFailure = iota /**< no failure */
+	MRCP_FAILURE_ENGINE_UNAVAILABLE                    /**< no engine to serve the resource, or the engine failed to open */
+	MRCP_FAILURE_CODEC_MISMATCH                        /**< no offered codec is supported */
+	MRCP_FAILURE_QUOTA_EXCEEDED                        /**< max number of sessions/channels is reached */
+	MRCP_FAILURE_RESOURCE_NOT_FOUND                    /**< requested resource is not supported */
+	MRCP_FAILURE_PARAM_VALUE                           /**< unsupported header field value */
+	MRCP_FAILURE_MEDIA                                 /**< media processing failed */
+	MRCP_FAILURE_TIMEOUT                               /**< engine did not respond in time */
+	MRCP_FAILURE_INTERNAL                              /**< any other failure */
+
+	MRCP_FAILURE_COUNT /**< number of failures */
+)
+
+/** Names of the failures used to configure the mapping */
+var mrcpFailureNames = [MRCP_FAILURE_COUNT]string{
+	"none",
+	"engine-unavailable",
+	"codec-mismatch",
+	"quota-exceeded",
+	"resource-not-found",
+	"param-value",
+	"media",
+	"timeout",
+	"internal",
+}
+
+/** Get failure by name */
+func MRCPFailureFind(name string) (MRCPFailure, bool) {
+	for failure, failureName := range mrcpFailureNames {
+		if strings.EqualFold(failureName, name) {
+			return failure, true
+		}
+	}
+	return MRCP_FAILURE_NONE, false
+}
+
+/** Get name of failure */
+func MRCPFailureNameGet(failure MRCPFailure) string {
+	if failure < 0 || failure >= MRCP_FAILURE_COUNT {
+		return ""
+	}
+	return mrcpFailureNames[failure]
+}
+
+/** Status codes the failure is translated to (0 if the signaling response is not affected) */
+type MRCPStatusMapping struct {
+	StatusCode     MRCPStatusCode // Status code of MRCP response
+	SipStatusCode  int            // Status code of SIP final response
+	RtspStatusCode int            // Status code of RTSP response
+}
+
+/** Centralized mapping of internal failures to status codes */
+type MRCPStatusMap struct {
+	mutex    sync.RWMutex
+	mappings [MRCP_FAILURE_COUNT]MRCPStatusMapping
+}
+
+/** Default mapping of failures */
+var mrcpDefaultStatusMappings = [MRCP_FAILURE_COUNT]MRCPStatusMapping{
+	MRCP_FAILURE_NONE:               {MRCP_STATUS_CODE_SUCCESS, 200, 200},
+	MRCP_FAILURE_ENGINE_UNAVAILABLE: {MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE, 503, 503},
+	MRCP_FAILURE_CODEC_MISMATCH:     {MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE, 488, 415},
+	MRCP_FAILURE_QUOTA_EXCEEDED:     {MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE, 486, 503},
+	MRCP_FAILURE_RESOURCE_NOT_FOUND: {MRCP_STATUS_CODE_NOT_FOUND, 404, 404},
+	MRCP_FAILURE_PARAM_VALUE:        {MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE, 0, 0},
+	MRCP_FAILURE_MEDIA:              {MRCP_STATUS_CODE_METHOD_FAILED, 0, 0},
+	MRCP_FAILURE_TIMEOUT:            {MRCP_STATUS_CODE_METHOD_FAILED, 408, 408},
+	MRCP_FAILURE_INTERNAL:           {MRCP_STATUS_CODE_METHOD_FAILED, 500, 500},
+}
+
+var (
+	defaultStatusMap     *MRCPStatusMap
+	defaultStatusMapOnce sync.Once
+)
+
+/** Create status map with default mapping */
+func MRCPStatusMapCreate() *MRCPStatusMap {
+	return &MRCPStatusMap{
+		mappings: mrcpDefaultStatusMappings,
+	}
+}
+
+/** Get default status map used by the server session logic */
+func MRCPStatusMapDefaultGet() *MRCPStatusMap {
+	defaultStatusMapOnce.Do(func() {
+		defaultStatusMap = MRCPStatusMapCreate()
+	})
+	return defaultStatusMap
+}
+
+/** Get status codes the failure is translated to */
+func (m *MRCPStatusMap) MRCPStatusMappingGet(failure MRCPFailure) MRCPStatusMapping {
+	if failure < 0 || failure >= MRCP_FAILURE_COUNT {
+		failure = MRCP_FAILURE_INTERNAL
+	}
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.mappings[failure]
+}
+
+/** Set status codes the failure is translated to */
+func (m *MRCPStatusMap) MRCPStatusMappingSet(failure MRCPFailure, mapping MRCPStatusMapping) error {
+	if failure <= MRCP_FAILURE_NONE || failure >= MRCP_FAILURE_COUNT {
+		return fmt.Errorf("invalid failure %d", failure)
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.mappings[failure] = mapping
+	return nil
+}
+
+/**
+ * Load mapping from config params.
+ * @param params the table of <failure name> -> "<mrcp>[/<sip>[/<rtsp>]]" status codes,
+ *               e.g. "quota-exceeded" -> "421/503/503"
+ */
+func (m *MRCPStatusMap) MRCPStatusMapLoad(params map[string]string) error {
+	for name, value := range params {
+		failure, ok := MRCPFailureFind(name)
+		if !ok {
+			return fmt.Errorf("unknown failure [%s]", name)
+		}
+		var (
+			codes   [3]int
+			mapping = m.MRCPStatusMappingGet(failure)
+		)
+		codes[0], codes[1], codes[2] = int(mapping.StatusCode), mapping.SipStatusCode, mapping.RtspStatusCode
+		for i, field := range strings.Split(value, "/") {
+			if i >= len(codes) {
+				return fmt.Errorf("invalid status codes [%s] of failure %s", value, name)
+			}
+			code, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return fmt.Errorf("invalid status codes [%s] of failure %s", value, name)
+			}
+			codes[i] = code
+		}
+		mapping = MRCPStatusMapping{
+			StatusCode:     MRCPStatusCode(codes[0]),
+			SipStatusCode:  codes[1],
+			RtspStatusCode: codes[2],
+		}
+		if err := m.MRCPStatusMappingSet(failure, mapping); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Set status of the response according to the failure.
+ * @param response the response to set status of
+ * @param failure the internal failure
+ */
+func (m *MRCPStatusMap) MRCPResponseFailureSet(response *MRCPMessage, failure MRCPFailure) {
+	if response.StartLine == nil {
+		return
+	}
+	response.StartLine.StatusCode = m.MRCPStatusMappingGet(failure).StatusCode
+	response.StartLine.RequestState = MRCP_REQUEST_STATE_COMPLETE
+}
+
+/**
+ * Create response to the request failed by the error.
+ * @param request the request failed
+ * @param err the error the request failed by, translated by the failure it carries
+ */
+func (m *MRCPStatusMap) MRCPFailureResponseCreate(request *MRCPMessage, err error) *MRCPMessage {
+	response := MRCPResponseCreate(request)
+	failure := MRCPFailureGet(err)
+	if failure == MRCP_FAILURE_NONE {
+		failure = MRCP_FAILURE_INTERNAL
+	}
+	m.MRCPResponseFailureSet(response, failure)
+	return response
+}
+
+/** Error carrying the internal failure it is to be translated by */
+type MRCPFailureError struct {
+	Failure MRCPFailure
+	Err     error
+}
+
+/** Create error of the failure */
+func MRCPFailureErrorCreate(failure MRCPFailure, err error) error {
+	return &MRCPFailureError{Failure: failure, Err: err}
+}
+
+func (e *MRCPFailureError) Error() string {
+	if e.Err == nil {
+		return MRCPFailureNameGet(e.Failure)
+	}
+	return fmt.Sprintf("%s: %v", MRCPFailureNameGet(e.Failure), e.Err)
+}
+
+func (e *MRCPFailureError) Unwrap() error {
+	return e.Err
+}
+
+/** Get failure of the error (internal failure if the error does not carry any) */
+func MRCPFailureGet(err error) MRCPFailure {
+	if err == nil {
+		return MRCP_FAILURE_NONE
+	}
+	var failureErr *MRCPFailureError
+	if errors.As(err, &failureErr) {
+		return failureErr.Failure
+	}
+	return MRCP_FAILURE_INTERNAL
+}
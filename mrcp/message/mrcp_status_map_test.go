@@ -0,0 +1,94 @@
+package message
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestStatusMapDefault(t *testing.T) {
+	m := MRCPStatusMapCreate()
+	for _, test := range []struct {
+		failure MRCPFailure
+		mapping MRCPStatusMapping
+	}{
+		{MRCP_FAILURE_NONE, MRCPStatusMapping{MRCP_STATUS_CODE_SUCCESS, 200, 200}},
+		{MRCP_FAILURE_ENGINE_UNAVAILABLE, MRCPStatusMapping{MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE, 503, 503}},
+		{MRCP_FAILURE_CODEC_MISMATCH, MRCPStatusMapping{MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE, 488, 415}},
+		{MRCP_FAILURE_QUOTA_EXCEEDED, MRCPStatusMapping{MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE, 486, 503}},
+		{MRCP_FAILURE_RESOURCE_NOT_FOUND, MRCPStatusMapping{MRCP_STATUS_CODE_NOT_FOUND, 404, 404}},
+		{MRCP_FAILURE_PARAM_VALUE, MRCPStatusMapping{MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE, 0, 0}},
+		{MRCP_FAILURE_MEDIA, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 0, 0}},
+		{MRCP_FAILURE_TIMEOUT, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 408, 408}},
+		{MRCP_FAILURE_INTERNAL, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 500, 500}},
+		/* unknown failures are internal */
+		{MRCP_FAILURE_COUNT, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 500, 500}},
+		{-1, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 500, 500}},
+	} {
+		if mapping := m.MRCPStatusMappingGet(test.failure); mapping != test.mapping {
+			t.Errorf("failure %d mapped to %+v, want %+v", test.failure, mapping, test.mapping)
+		}
+	}
+}
+
+func TestStatusMapLoad(t *testing.T) {
+	for _, test := range []struct {
+		params  map[string]string
+		failure MRCPFailure
+		mapping MRCPStatusMapping
+		valid   bool
+	}{
+		{map[string]string{"quota-exceeded": "407/503/503"}, MRCP_FAILURE_QUOTA_EXCEEDED, MRCPStatusMapping{MRCP_STATUS_CODE_METHOD_FAILED, 503, 503}, true},
+		{map[string]string{"Codec-Mismatch": " 409 / 415 "}, MRCP_FAILURE_CODEC_MISMATCH, MRCPStatusMapping{MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE, 415, 415}, true},
+		{map[string]string{"media": "421"}, MRCP_FAILURE_MEDIA, MRCPStatusMapping{MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE, 0, 0}, true},
+		{map[string]string{"unknown": "421"}, MRCP_FAILURE_NONE, MRCPStatusMapping{}, false},
+		{map[string]string{"none": "421"}, MRCP_FAILURE_NONE, MRCPStatusMapping{}, false},
+		{map[string]string{"timeout": "407/408/408/408"}, MRCP_FAILURE_TIMEOUT, MRCPStatusMapping{}, false},
+		{map[string]string{"timeout": "407/busy"}, MRCP_FAILURE_TIMEOUT, MRCPStatusMapping{}, false},
+	} {
+		m := MRCPStatusMapCreate()
+		err := m.MRCPStatusMapLoad(test.params)
+		if !test.valid {
+			if err == nil {
+				t.Errorf("invalid params %v are loaded", test.params)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("params %v: %v", test.params, err)
+		} else if mapping := m.MRCPStatusMappingGet(test.failure); mapping != test.mapping {
+			t.Errorf("params %v mapped to %+v, want %+v", test.params, mapping, test.mapping)
+		}
+	}
+
+	/* the default map is not affected */
+	if mapping := MRCPStatusMapDefaultGet().MRCPStatusMappingGet(MRCP_FAILURE_QUOTA_EXCEEDED); mapping.StatusCode != MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE {
+		t.Fatalf("default mapping %+v", mapping)
+	}
+}
+
+func TestStatusMapFailureResponse(t *testing.T) {
+	request := MRCPMessageCreate()
+	request.StartLine = &MRCPStartLine{MessageType: MRCP_MESSAGE_TYPE_REQUEST, MethodName: "RECOGNIZE", RequestId: 7}
+	m := MRCPStatusMapCreate()
+	for _, test := range []struct {
+		err        error
+		failure    MRCPFailure
+		statusCode MRCPStatusCode
+	}{
+		{MRCPFailureErrorCreate(MRCP_FAILURE_CODEC_MISMATCH, errors.New("no codec")), MRCP_FAILURE_CODEC_MISMATCH, MRCP_STATUS_CODE_UNSUPPORTED_PARAM_VALUE},
+		{fmt.Errorf("open: %w", MRCPFailureErrorCreate(MRCP_FAILURE_ENGINE_UNAVAILABLE, nil)), MRCP_FAILURE_ENGINE_UNAVAILABLE, MRCP_STATUS_CODE_RESOURCE_SPECIFIC_FAILURE},
+		{errors.New("unexpected"), MRCP_FAILURE_INTERNAL, MRCP_STATUS_CODE_METHOD_FAILED},
+		/* the request is failed anyway */
+		{nil, MRCP_FAILURE_NONE, MRCP_STATUS_CODE_METHOD_FAILED},
+	} {
+		if failure := MRCPFailureGet(test.err); failure != test.failure {
+			t.Errorf("error %v of failure %d, want %d", test.err, failure, test.failure)
+		}
+		response := m.MRCPFailureResponseCreate(request, test.err)
+		if response.StartLine.MessageType != MRCP_MESSAGE_TYPE_RESPONSE || response.StartLine.RequestId != 7 ||
+			response.StartLine.StatusCode != test.statusCode || response.StartLine.RequestState != MRCP_REQUEST_STATE_COMPLETE {
+			t.Errorf("response to error %v %+v", test.err, response.StartLine)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package resources
 
 import (
+	"fmt"
+
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/mrcp/control/resource"
 	"github.com/navi-tt/go-mrcp/mrcp/message/header"
@@ -288,3 +290,176 @@ func MRCPRecognizerCompletionCauseGet(cause MRCPRecognizerCompletionCause, v mrc
 func MRCPRecognizerResourceCreate() *resource.MRCPResource {
 	return nil
 }
+
+/** Names of MRCP recognizer header fields */
+var mrcpRecognizerHeaderFieldNames = [RECOGNIZER_HEADER_COUNT]string{
+	RECOGNIZER_HEADER_CONFIDENCE_THRESHOLD:              "Confidence-Threshold",
+	RECOGNIZER_HEADER_SENSITIVITY_LEVEL:                 "Sensitivity-Level",
+	RECOGNIZER_HEADER_SPEED_VS_ACCURACY:                 "Speed-Vs-Accuracy",
+	RECOGNIZER_HEADER_N_BEST_LIST_LENGTH:                "N-Best-List-Length",
+	RECOGNIZER_HEADER_NO_INPUT_TIMEOUT:                  "No-Input-Timeout",
+	RECOGNIZER_HEADER_RECOGNITION_TIMEOUT:               "Recognition-Timeout",
+	RECOGNIZER_HEADER_WAVEFORM_URI:                      "Waveform-Uri",
+	RECOGNIZER_HEADER_COMPLETION_CAUSE:                  "Completion-Cause",
+	RECOGNIZER_HEADER_RECOGNIZER_CONTEXT_BLOCK:          "Recognizer-Context-Block",
+	RECOGNIZER_HEADER_START_INPUT_TIMERS:                "Start-Input-Timers",
+	RECOGNIZER_HEADER_SPEECH_COMPLETE_TIMEOUT:           "Speech-Complete-Timeout",
+	RECOGNIZER_HEADER_SPEECH_INCOMPLETE_TIMEOUT:         "Speech-Incomplete-Timeout",
+	RECOGNIZER_HEADER_DTMF_INTERDIGIT_TIMEOUT:           "DTMF-Interdigit-Timeout",
+	RECOGNIZER_HEADER_DTMF_TERM_TIMEOUT:                 "DTMF-Term-Timeout",
+	RECOGNIZER_HEADER_DTMF_TERM_CHAR:                    "DTMF-Term-Char",
+	RECOGNIZER_HEADER_FAILED_URI:                        "Failed-Uri",
+	RECOGNIZER_HEADER_FAILED_URI_CAUSE:                  "Failed-Uri-Cause",
+	RECOGNIZER_HEADER_SAVE_WAVEFORM:                     "Save-Waveform",
+	RECOGNIZER_HEADER_NEW_AUDIO_CHANNEL:                 "New-Audio-Channel",
+	RECOGNIZER_HEADER_SPEECH_LANGUAGE:                   "Speech-Language",
+	RECOGNIZER_HEADER_INPUT_TYPE:                        "Input-Type",
+	RECOGNIZER_HEADER_INPUT_WAVEFORM_URI:                "Input-Waveform-Uri",
+	RECOGNIZER_HEADER_COMPLETION_REASON:                 "Completion-Reason",
+	RECOGNIZER_HEADER_MEDIA_TYPE:                        "Media-Type",
+	RECOGNIZER_HEADER_VER_BUFFER_UTTERANCE:              "Ver-Buffer-Utterance",
+	RECOGNIZER_HEADER_RECOGNITION_MODE:                  "Recognition-Mode",
+	RECOGNIZER_HEADER_CANCEL_IF_QUEUE:                   "Cancel-If-Queue",
+	RECOGNIZER_HEADER_HOTWORD_MAX_DURATION:              "Hotword-Max-Duration",
+	RECOGNIZER_HEADER_HOTWORD_MIN_DURATION:              "Hotword-Min-Duration",
+	RECOGNIZER_HEADER_INTERPRET_TEXT:                    "Interpret-Text",
+	RECOGNIZER_HEADER_DTMF_BUFFER_TIME:                  "DTMF-Buffer-Time",
+	RECOGNIZER_HEADER_CLEAR_DTMF_BUFFER:                 "Clear-DTMF-Buffer",
+	RECOGNIZER_HEADER_EARLY_NO_MATCH:                    "Early-No-Match",
+	RECOGNIZER_HEADER_NUM_MIN_CONSISTENT_PRONUNCIATIONS: "Num-Min-Consistent-Pronunciations",
+	RECOGNIZER_HEADER_CONSISTENCY_THRESHOLD:             "Consistency-Threshold",
+	RECOGNIZER_HEADER_CLASH_THRESHOLD:                   "Clash-Threshold",
+	RECOGNIZER_HEADER_PERSONAL_GRAMMAR_URI:              "Personal-Grammar-Uri",
+	RECOGNIZER_HEADER_ENROLL_UTTERANCE:                  "Enroll-Utterance",
+	RECOGNIZER_HEADER_PHRASE_ID:                         "Phrase-Id",
+	RECOGNIZER_HEADER_PHRASE_NL:                         "Phrase-NL",
+	RECOGNIZER_HEADER_WEIGHT:                            "Weight",
+	RECOGNIZER_HEADER_SAVE_BEST_WAVEFORM:                "Save-Best-Waveform",
+	RECOGNIZER_HEADER_NEW_PHRASE_ID:                     "New-Phrase-Id",
+	RECOGNIZER_HEADER_CONFUSABLE_PHRASES_URI:            "Confusable-Phrases-URI",
+	RECOGNIZER_HEADER_ABORT_PHRASE_ENROLLMENT:           "Abort-Phrase-Enrollment",
+}
+
+var mrcpRecognizerHeaderFieldTable = &header.MRCPHeaderFieldTable{
+	Names:    mrcpRecognizerHeaderFieldNames[:],
+	Allocate: func() interface{} { return &MRCPRecognizerHeader{} },
+	Check: func(data interface{}, id int64) bool {
+		return header.MRCPHeaderFieldValueCheck(mrcpRecognizerHeaderFieldValueGet(data, id))
+	},
+	Parse: func(data interface{}, id int64, value string) error {
+		fieldValue := mrcpRecognizerHeaderFieldValueGet(data, id)
+		if fieldValue == nil {
+			return fmt.Errorf("unknown recognizer header field %d of %T", id, data)
+		}
+		if err := header.MRCPHeaderFieldValueParse(fieldValue, value); err != nil {
+			return fmt.Errorf("%s: %v", mrcpRecognizerHeaderFieldNames[id], err)
+		}
+		return nil
+	},
+}
+
+/** Get table of MRCP recognizer header fields */
+func MRCPRecognizerHeaderFieldTableGet() *header.MRCPHeaderFieldTable {
+	return mrcpRecognizerHeaderFieldTable
+}
+
+/* Get pointer to the field value of recognizer header, nil if unknown */
+func mrcpRecognizerHeaderFieldValueGet(data interface{}, id int64) interface{} {
+	h, ok := data.(*MRCPRecognizerHeader)
+	if !ok || h == nil {
+		return nil
+	}
+	switch MRCPRecognizerHeaderId(id) {
+	case RECOGNIZER_HEADER_CONFIDENCE_THRESHOLD:
+		return &h.ConfidenceThreshold
+	case RECOGNIZER_HEADER_SENSITIVITY_LEVEL:
+		return &h.SensitivityLevel
+	case RECOGNIZER_HEADER_SPEED_VS_ACCURACY:
+		return &h.SpeedVsAccuracy
+	case RECOGNIZER_HEADER_N_BEST_LIST_LENGTH:
+		return &h.NBestListLength
+	case RECOGNIZER_HEADER_NO_INPUT_TIMEOUT:
+		return &h.NoInputTimeout
+	case RECOGNIZER_HEADER_RECOGNITION_TIMEOUT:
+		return &h.RecognitionTimeout
+	case RECOGNIZER_HEADER_WAVEFORM_URI:
+		return &h.WaveformUri
+	case RECOGNIZER_HEADER_COMPLETION_CAUSE:
+		return &h.CompletionCause
+	case RECOGNIZER_HEADER_RECOGNIZER_CONTEXT_BLOCK:
+		return &h.RecognizerContextBlock
+	case RECOGNIZER_HEADER_START_INPUT_TIMERS:
+		return &h.StartInputTimers
+	case RECOGNIZER_HEADER_SPEECH_COMPLETE_TIMEOUT:
+		return &h.SpeechCompleteTimeout
+	case RECOGNIZER_HEADER_SPEECH_INCOMPLETE_TIMEOUT:
+		return &h.SpeechIncompleteTimeout
+	case RECOGNIZER_HEADER_DTMF_INTERDIGIT_TIMEOUT:
+		return &h.DtmfInterdigitTimeout
+	case RECOGNIZER_HEADER_DTMF_TERM_TIMEOUT:
+		return &h.DtmfTermTimeout
+	case RECOGNIZER_HEADER_DTMF_TERM_CHAR:
+		return &h.DtmfTermChar
+	case RECOGNIZER_HEADER_FAILED_URI:
+		return &h.FailedUri
+	case RECOGNIZER_HEADER_FAILED_URI_CAUSE:
+		return &h.FailedUriCause
+	case RECOGNIZER_HEADER_SAVE_WAVEFORM:
+		return &h.SaveWaveform
+	case RECOGNIZER_HEADER_NEW_AUDIO_CHANNEL:
+		return &h.NewAudioChannel
+	case RECOGNIZER_HEADER_SPEECH_LANGUAGE:
+		return &h.SpeechLanguage
+	case RECOGNIZER_HEADER_INPUT_TYPE:
+		return &h.InputType
+	case RECOGNIZER_HEADER_INPUT_WAVEFORM_URI:
+		return &h.InputWaveformUri
+	case RECOGNIZER_HEADER_COMPLETION_REASON:
+		return &h.CompletionReason
+	case RECOGNIZER_HEADER_MEDIA_TYPE:
+		return &h.MediaType
+	case RECOGNIZER_HEADER_VER_BUFFER_UTTERANCE:
+		return &h.VerBufferUtterance
+	case RECOGNIZER_HEADER_RECOGNITION_MODE:
+		return &h.RecognitionMode
+	case RECOGNIZER_HEADER_CANCEL_IF_QUEUE:
+		return &h.CancelIfQueue
+	case RECOGNIZER_HEADER_HOTWORD_MAX_DURATION:
+		return &h.HotWordMaxDuration
+	case RECOGNIZER_HEADER_HOTWORD_MIN_DURATION:
+		return &h.HotWordMinDuration
+	case RECOGNIZER_HEADER_INTERPRET_TEXT:
+		return &h.InterpretText
+	case RECOGNIZER_HEADER_DTMF_BUFFER_TIME:
+		return &h.DtmfBufferTime
+	case RECOGNIZER_HEADER_CLEAR_DTMF_BUFFER:
+		return &h.ClearDtmfBuffer
+	case RECOGNIZER_HEADER_EARLY_NO_MATCH:
+		return &h.EarlyNoMatch
+	case RECOGNIZER_HEADER_NUM_MIN_CONSISTENT_PRONUNCIATIONS:
+		return &h.NumMinConsistentPronunciations
+	case RECOGNIZER_HEADER_CONSISTENCY_THRESHOLD:
+		return &h.ConsistencyThreshold
+	case RECOGNIZER_HEADER_CLASH_THRESHOLD:
+		return &h.ClashThreshold
+	case RECOGNIZER_HEADER_PERSONAL_GRAMMAR_URI:
+		return &h.PersonalGrammarUri
+	case RECOGNIZER_HEADER_ENROLL_UTTERANCE:
+		return &h.EnrollUtterance
+	case RECOGNIZER_HEADER_PHRASE_ID:
+		return &h.PhraseId
+	case RECOGNIZER_HEADER_PHRASE_NL:
+		return &h.PhraseNl
+	case RECOGNIZER_HEADER_WEIGHT:
+		return &h.Weight
+	case RECOGNIZER_HEADER_SAVE_BEST_WAVEFORM:
+		return &h.SaveBestWaveform
+	case RECOGNIZER_HEADER_NEW_PHRASE_ID:
+		return &h.NewPhraseId
+	case RECOGNIZER_HEADER_CONFUSABLE_PHRASES_URI:
+		return &h.ConfusablePhrasesUri
+	case RECOGNIZER_HEADER_ABORT_PHRASE_ENROLLMENT:
+		return &h.AbortPhraseEnrollment
+	}
+	return nil
+}
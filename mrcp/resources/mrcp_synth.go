@@ -1,6 +1,9 @@
 package resources
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/navi-tt/go-mrcp/mrcp"
 	"github.com/navi-tt/go-mrcp/mrcp/control/resource"
 	"github.com/navi-tt/go-mrcp/mrcp/message/header"
@@ -283,6 +286,61 @@ type MRCPSynthHeader struct {
 	LexiconSearchOrder string
 }
 
+/**
+ * Get numeric speech-length in msec.
+ * @remark Negative value stands for backward direction (rewind)
+ */
+func (v *MRCPSpeechLengthValue) MRCPSpeechLengthMsecGet() (int64, error) {
+	if v.Type != SPEECH_LENGTH_TYPE_NUMERIC_POSITIVE && v.Type != SPEECH_LENGTH_TYPE_NUMERIC_NEGATIVE {
+		return 0, fmt.Errorf("speech-length is not numeric")
+	}
+	if v.Value.Numeric.Unit != SPEECH_UNIT_SECOND {
+		return 0, fmt.Errorf("speech-length unit %d is not convertible to time", v.Value.Numeric.Unit)
+	}
+	msec := v.Value.Numeric.Length * 1000
+	if v.Type == SPEECH_LENGTH_TYPE_NUMERIC_NEGATIVE {
+		msec = -msec
+	}
+	return msec, nil
+}
+
+/** Get prosody-rate of synthesizer header */
+func (h *MRCPSynthHeader) MRCPProsodyRateGet() *MRCPProsodyRate {
+	return &h.prosody_param.rate
+}
+
+/** Set prosody-rate of synthesizer header */
+func (h *MRCPSynthHeader) MRCPProsodyRateSet(rate MRCPProsodyRate) {
+	h.prosody_param.rate = rate
+}
+
+/** Playback rate factors of prosody-rate labels */
+var mrcpProsodyRateFactors = [PROSODY_RATE_COUNT]float64{
+	PROSODY_RATE_XSLOW:   0.5,
+	PROSODY_RATE_SLOW:    0.75,
+	PROSODY_RATE_MEDIUM:  1.0,
+	PROSODY_RATE_FAST:    1.5,
+	PROSODY_RATE_XFAST:   2.0,
+	PROSODY_RATE_DEFAULT: 1.0,
+}
+
+/** Get playback rate factor of prosody-rate (1 - default rate, 2 - twice faster) */
+func (r *MRCPProsodyRate) MRCPProsodyRateFactorGet() (float64, error) {
+	switch r.Type {
+	case PROSODY_RATE_TYPE_LABEL:
+		if r.Value.Label < 0 || r.Value.Label >= PROSODY_RATE_COUNT {
+			return 0, fmt.Errorf("unknown prosody-rate label %d", r.Value.Label)
+		}
+		return mrcpProsodyRateFactors[r.Value.Label], nil
+	case PROSODY_RATE_TYPE_RELATIVE_CHANGE:
+		if r.Value.Relative <= 0 {
+			return 0, fmt.Errorf("invalid prosody-rate relative change %f", r.Value.Relative)
+		}
+		return r.Value.Relative, nil
+	}
+	return 0, fmt.Errorf("unknown prosody-rate type %d", r.Type)
+}
+
 /** Get synthesizer header vtable */
 func MRCPSynthHeaderVTableGet(v mrcp.Version) *header.MRCPHeaderVTable {
 	return nil
@@ -297,3 +355,119 @@ func MRCPSynthCompletionCauseGet(cause MRCPSynthCompletionCause, v mrcp.Version)
 func MRCPSynthResourceCreate() *resource.MRCPResource {
 	return nil
 }
+
+/** Names of MRCP synthesizer header fields */
+var mrcpSynthHeaderFieldNames = [SYNTHESIZER_HEADER_COUNT]string{
+	SYNTHESIZER_HEADER_JUMP_SIZE:            "Jump-Size",
+	SYNTHESIZER_HEADER_KILL_ON_BARGE_IN:     "Kill-On-Barge-In",
+	SYNTHESIZER_HEADER_SPEAKER_PROFILE:      "Speaker-Profile",
+	SYNTHESIZER_HEADER_COMPLETION_CAUSE:     "Completion-Cause",
+	SYNTHESIZER_HEADER_COMPLETION_REASON:    "Completion-Reason",
+	SYNTHESIZER_HEADER_VOICE_GENDER:         "Voice-Gender",
+	SYNTHESIZER_HEADER_VOICE_AGE:            "Voice-Age",
+	SYNTHESIZER_HEADER_VOICE_VARIANT:        "Voice-Variant",
+	SYNTHESIZER_HEADER_VOICE_NAME:           "Voice-Name",
+	SYNTHESIZER_HEADER_PROSODY_VOLUME:       "Prosody-Volume",
+	SYNTHESIZER_HEADER_PROSODY_RATE:         "Prosody-Rate",
+	SYNTHESIZER_HEADER_SPEECH_MARKER:        "Speech-Marker",
+	SYNTHESIZER_HEADER_SPEECH_LANGUAGE:      "Speech-Language",
+	SYNTHESIZER_HEADER_FETCH_HINT:           "Fetch-Hint",
+	SYNTHESIZER_HEADER_AUDIO_FETCH_HINT:     "Audio-Fetch-Hint",
+	SYNTHESIZER_HEADER_FAILED_URI:           "Failed-Uri",
+	SYNTHESIZER_HEADER_FAILED_URI_CAUSE:     "Failed-Uri-Cause",
+	SYNTHESIZER_HEADER_SPEAK_RESTART:        "Speak-Restart",
+	SYNTHESIZER_HEADER_SPEAK_LENGTH:         "Speak-Length",
+	SYNTHESIZER_HEADER_LOAD_LEXICON:         "Load-Lexicon",
+	SYNTHESIZER_HEADER_LEXICON_SEARCH_ORDER: "Lexicon-Search-Order",
+}
+
+/** Names of voice genders */
+var mrcpVoiceGenderNames = [VOICE_GENDER_COUNT]string{
+	VOICE_GENDER_MALE:    "male",
+	VOICE_GENDER_FEMALE:  "female",
+	VOICE_GENDER_NEUTRAL: "neutral",
+}
+
+var mrcpSynthHeaderFieldTable = &header.MRCPHeaderFieldTable{
+	Names:    mrcpSynthHeaderFieldNames[:],
+	Allocate: func() interface{} { return &MRCPSynthHeader{} },
+	/* the voice gender of zero value is male, it is seen as set by the header field only */
+	Check: func(data interface{}, id int64) bool {
+		return header.MRCPHeaderFieldValueCheck(mrcpSynthHeaderFieldValueGet(data, id))
+	},
+	Parse: func(data interface{}, id int64, value string) error {
+		if h, ok := data.(*MRCPSynthHeader); ok && h != nil && id == int64(SYNTHESIZER_HEADER_VOICE_GENDER) {
+			for gender, name := range mrcpVoiceGenderNames {
+				if strings.EqualFold(strings.TrimSpace(value), name) {
+					h.voice_param.Gender = gender
+					return nil
+				}
+			}
+			return fmt.Errorf("Voice-Gender: invalid value [%s]", value)
+		}
+		fieldValue := mrcpSynthHeaderFieldValueGet(data, id)
+		if fieldValue == nil {
+			if id >= 0 && id < int64(SYNTHESIZER_HEADER_COUNT) {
+				return fmt.Errorf("value of %s is not supported", mrcpSynthHeaderFieldNames[id])
+			}
+			return fmt.Errorf("unknown synthesizer header field %d of %T", id, data)
+		}
+		if err := header.MRCPHeaderFieldValueParse(fieldValue, value); err != nil {
+			return fmt.Errorf("%s: %v", mrcpSynthHeaderFieldNames[id], err)
+		}
+		return nil
+	},
+}
+
+/** Get table of MRCP synthesizer header fields */
+func MRCPSynthHeaderFieldTableGet() *header.MRCPHeaderFieldTable {
+	return mrcpSynthHeaderFieldTable
+}
+
+/** Get voice-param of synthesizer header */
+func (h *MRCPSynthHeader) MRCPVoiceParamGet() *MRCPVoiceParam {
+	return &h.voice_param
+}
+
+/* Get pointer to the field value of synthesizer header, nil if unknown or not of plain value */
+func mrcpSynthHeaderFieldValueGet(data interface{}, id int64) interface{} {
+	h, ok := data.(*MRCPSynthHeader)
+	if !ok || h == nil {
+		return nil
+	}
+	switch MRCPSynthesizerHeaderId(id) {
+	case SYNTHESIZER_HEADER_KILL_ON_BARGE_IN:
+		return &h.KillOnBargeIn
+	case SYNTHESIZER_HEADER_SPEAKER_PROFILE:
+		return &h.SpeakerProfile
+	case SYNTHESIZER_HEADER_COMPLETION_CAUSE:
+		return &h.CompletionCause
+	case SYNTHESIZER_HEADER_COMPLETION_REASON:
+		return &h.CompletionReason
+	case SYNTHESIZER_HEADER_VOICE_AGE:
+		return &h.voice_param.Age
+	case SYNTHESIZER_HEADER_VOICE_VARIANT:
+		return &h.voice_param.Variant
+	case SYNTHESIZER_HEADER_VOICE_NAME:
+		return &h.voice_param.Name
+	case SYNTHESIZER_HEADER_SPEECH_MARKER:
+		return &h.SpeechMarker
+	case SYNTHESIZER_HEADER_SPEECH_LANGUAGE:
+		return &h.SpeechLanguage
+	case SYNTHESIZER_HEADER_FETCH_HINT:
+		return &h.FetchHint
+	case SYNTHESIZER_HEADER_AUDIO_FETCH_HINT:
+		return &h.AudioFetchHint
+	case SYNTHESIZER_HEADER_FAILED_URI:
+		return &h.FailedUri
+	case SYNTHESIZER_HEADER_FAILED_URI_CAUSE:
+		return &h.FailedUriCause
+	case SYNTHESIZER_HEADER_SPEAK_RESTART:
+		return &h.SpeakRestart
+	case SYNTHESIZER_HEADER_LOAD_LEXICON:
+		return &h.LoadLexicon
+	case SYNTHESIZER_HEADER_LEXICON_SEARCH_ORDER:
+		return &h.LexiconSearchOrder
+	}
+	return nil
+}
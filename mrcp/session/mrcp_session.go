@@ -0,0 +1,120 @@
+package session
+
+import (
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/control"
+)
+
+/** MRCP session */
+type MRCPSession struct {
+	Id   string      // Session identifier
+	Name string      // Human readable name used in traces
+	Obj  interface{} // External object associated with session
+
+	StartTime time.Time // Time the session is created at
+
+	Audit *MRCPSessionAuditTrail // Audit trail of the session
+
+	PayloadTypes *mpf.RtpPayloadTypeRegistry // RTP payload types learned from SDP of the session
+}
+
+/**
+ * Create MRCP session.
+ * The session audit trail is created and registered, so that it is retrievable via admin API.
+ * The payload type registry is to be set to RTP streams and decoders of the session.
+ * @param id the session identifier
+ * @param obj the external object associated with session
+ */
+func MRCPSessionCreate(id string, obj interface{}) *MRCPSession {
+	return mrcpSessionCreate("", id, obj)
+}
+
+/* Create MRCP session served by the node, empty for the local one */
+func mrcpSessionCreate(node, id string, obj interface{}) *MRCPSession {
+	session := &MRCPSession{
+		Id:   id,
+		Name: id,
+		Obj:  obj,
+
+		StartTime: time.Now(),
+		Audit:     MRCPSessionAuditTrailCreate(id, MRCP_SESSION_AUDIT_MAX_RECORDS),
+
+		PayloadTypes: mpf.RtpPayloadTypeRegistryCreate(mpf.CodecManagerDefaultGet()),
+	}
+	session.Audit.Node = node
+	MRCPAuditRegistryDefaultGet().MRCPAuditRegistryAdd(session.Audit)
+	session.Audit.MRCPAuditSignalingRecord("session created")
+	return session
+}
+
+/**
+ * Terminate MRCP session.
+ * The audit trail is dumped if the session is terminated abnormally, then unregistered.
+ * The CDR is exported if the registry storage is set.
+ * @param reason the reason of termination
+ * @param abnormal whether the session is terminated abnormally
+ */
+func (s *MRCPSession) MRCPSessionTerminate(reason string, abnormal bool) error {
+	s.Audit.MRCPAuditSignalingRecord("session terminated: " + reason)
+	registry := MRCPAuditRegistryDefaultGet()
+	defer registry.MRCPAuditRegistryRemove(s.Audit.Node, s.Id)
+	var err error
+	if abnormal {
+		err = registry.MRCPAuditRegistryDump(s.Audit)
+	}
+	if registry.Storage != nil {
+		if _, cdrErr := MRCPSessionCDRExport(registry.Storage, s.MRCPSessionCDRCreate(reason, abnormal)); err == nil {
+			err = cdrErr
+		}
+	}
+	return err
+}
+
+/**
+ * Terminate MRCP session as its control connection is closed, only failures are abnormal terminations:
+ * the peer half-closing the connection as soon as it is done is normal.
+ * @param reason the disconnect reason
+ * @param err the error the connection failed with (if any)
+ */
+func (s *MRCPSession) MRCPSessionControlDisconnected(reason control.MRCPDisconnectReason, err error) error {
+	description := "control connection " + control.MRCPDisconnectReasonStr(reason)
+	if err != nil {
+		description += ": " + err.Error()
+	}
+	return s.MRCPSessionTerminate(description, reason == control.MRCP_DISCONNECT_ABRUPT)
+}
+
+/**
+ * Audit the messages of control connection of the session, as sent and received (e.g. by the client).
+ * The handlers of the connection are wrapped, so it is to be called as soon as they are set, before start.
+ * @param c the control connection
+ */
+func (s *MRCPSession) MRCPSessionConnectionAudit(c *control.MRCPConnection) {
+	onMessage := c.OnMessage
+	c.OnMessage = func(c *control.MRCPConnection, data []byte) error {
+		s.Audit.MRCPAuditRawMessageRecord(MRCP_AUDIT_DIRECTION_INBOUND, data)
+		if onMessage != nil {
+			return onMessage(c, data)
+		}
+		return nil
+	}
+	onSend := c.OnSend
+	c.OnSend = func(c *control.MRCPConnection, data []byte) {
+		s.Audit.MRCPAuditRawMessageRecord(MRCP_AUDIT_DIRECTION_OUTBOUND, data)
+		if onSend != nil {
+			onSend(c, data)
+		}
+	}
+}
+
+/**
+ * Audit the media topology of context of the session, each time it is applied.
+ * @param context the media context
+ */
+func (s *MRCPSession) MRCPSessionContextAudit(context *mpf.Context) {
+	context.ContextTopologyProcSet(func(context *mpf.Context, description string, err error) {
+		s.Audit.MRCPAuditTopologyRecord(description)
+	})
+}
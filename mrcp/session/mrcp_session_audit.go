@@ -0,0 +1,361 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
+)
+
+/** Default max number of records kept in session audit trail (the oldest ones are discarded) */
+const MRCP_SESSION_AUDIT_MAX_RECORDS = 4096
+
+/** Audit record categories */
+type MRCPAuditCategory = string
+
+const (
+	MRCP_AUDIT_SIGNALING MRCPAuditCategory = "signaling" /**< signaling milestones (offer/answer, terminate) */
+	MRCP_AUDIT_MESSAGE   MRCPAuditCategory = "message"   /**< MRCP requests, responses and events */
+	MRCP_AUDIT_TOPOLOGY  MRCPAuditCategory = "topology"  /**< media topology changes */
+)
+
+/** Direction of audited MRCP message */
+type MRCPAuditDirection = string
+
+const (
+	MRCP_AUDIT_DIRECTION_NONE     MRCPAuditDirection = ""
+	MRCP_AUDIT_DIRECTION_INBOUND  MRCPAuditDirection = "in"  /**< received from the peer (client or server) */
+	MRCP_AUDIT_DIRECTION_OUTBOUND MRCPAuditDirection = "out" /**< sent to the peer (client or server) */
+)
+
+/** Audit record */
+type MRCPAuditRecord struct {
+	Seq       uint64             `json:"seq"`                 // Sequence number, ordering records of the session
+	Time      time.Time          `json:"time"`                // Timestamp
+	Category  MRCPAuditCategory  `json:"category"`            // Record category
+	Direction MRCPAuditDirection `json:"direction,omitempty"` // Direction of MRCP message
+
+	Description  string `json:"description,omitempty"`   // Milestone or topology change description
+	ChannelId    string `json:"channel_id,omitempty"`    // MRCP channel identifier
+	MessageType  string `json:"message_type,omitempty"`  // MRCP message type
+	MethodName   string `json:"method_name,omitempty"`   // MRCP method (event) name
+	MethodId     int64  `json:"method_id,omitempty"`     // MRCP method (event) id
+	RequestId    uint32 `json:"request_id,omitempty"`    // MRCP request identifier
+	StatusCode   int    `json:"status_code,omitempty"`   // MRCP status code of response
+	RequestState string `json:"request_state,omitempty"` // MRCP request state of response/event
+}
+
+/** Ordered audit trail of session */
+type MRCPSessionAuditTrail struct {
+	SessionId string // Session identifier
+	Node      string // Node (server instance) serving the session, empty for the local one
+
+	mutex      sync.Mutex
+	seq        uint64
+	maxRecords int
+	records    []MRCPAuditRecord // Records, ring of maxRecords once full (if limited)
+	head       int               // Index of the oldest record in the ring
+	dropped    uint64            // Number of discarded records
+}
+
+var mrcpAuditMessageTypes = map[message.MRCPMessageType]string{
+	message.MRCP_MESSAGE_TYPE_REQUEST:  "request",
+	message.MRCP_MESSAGE_TYPE_RESPONSE: "response",
+	message.MRCP_MESSAGE_TYPE_EVENT:    "event",
+}
+
+var mrcpAuditRequestStates = [message.MRCP_REQUEST_STATE_COUNT]string{
+	"COMPLETE",
+	"IN-PROGRESS",
+	"PENDING",
+}
+
+/**
+ * Create session audit trail.
+ * @param sessionId the session identifier
+ * @param maxRecords the max number of records to keep (0 - unlimited)
+ */
+func MRCPSessionAuditTrailCreate(sessionId string, maxRecords int) *MRCPSessionAuditTrail {
+	return &MRCPSessionAuditTrail{
+		SessionId:  sessionId,
+		maxRecords: maxRecords,
+	}
+}
+
+func (a *MRCPSessionAuditTrail) record(record MRCPAuditRecord) {
+	if a == nil {
+		return
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.seq++
+	record.Seq = a.seq
+	record.Time = time.Now()
+	if a.maxRecords > 0 && len(a.records) >= a.maxRecords {
+		/* the ring is full, the oldest record is overwritten */
+		a.records[a.head] = record
+		a.head = (a.head + 1) % len(a.records)
+		a.dropped++
+		return
+	}
+	a.records = append(a.records, record)
+}
+
+/* Get copy of the records in order, must be called with the mutex held */
+func (a *MRCPSessionAuditTrail) recordsCopy() []MRCPAuditRecord {
+	records := make([]MRCPAuditRecord, 0, len(a.records))
+	records = append(records, a.records[a.head:]...)
+	return append(records, a.records[:a.head]...)
+}
+
+/** Record signaling milestone */
+func (a *MRCPSessionAuditTrail) MRCPAuditSignalingRecord(description string) {
+	a.record(MRCPAuditRecord{Category: MRCP_AUDIT_SIGNALING, Description: description})
+}
+
+/** Record media topology change */
+func (a *MRCPSessionAuditTrail) MRCPAuditTopologyRecord(description string) {
+	a.record(MRCPAuditRecord{Category: MRCP_AUDIT_TOPOLOGY, Description: description})
+}
+
+/**
+ * Record MRCP message.
+ * @param direction the direction of the message
+ * @param msg the MRCP request, response or event
+ */
+func (a *MRCPSessionAuditTrail) MRCPAuditMessageRecord(direction MRCPAuditDirection, msg *message.MRCPMessage) {
+	if a == nil || msg == nil {
+		return
+	}
+	record := MRCPAuditRecord{
+		Category:  MRCP_AUDIT_MESSAGE,
+		Direction: direction,
+	}
+	if msg.ChannelId.SessionId != "" || msg.ChannelId.ResourceName != "" {
+		record.ChannelId = msg.ChannelId.SessionId + "@" + msg.ChannelId.ResourceName
+	}
+	if startLine := msg.StartLine; startLine != nil {
+		record.MessageType = mrcpAuditMessageTypes[startLine.MessageType]
+		record.MethodName = startLine.MethodName
+		record.MethodId = startLine.MethodId
+		record.RequestId = startLine.RequestId
+		if startLine.MessageType != message.MRCP_MESSAGE_TYPE_REQUEST {
+			if startLine.RequestState >= 0 && startLine.RequestState < message.MRCP_REQUEST_STATE_COUNT {
+				record.RequestState = mrcpAuditRequestStates[startLine.RequestState]
+			}
+		}
+		if startLine.MessageType == message.MRCP_MESSAGE_TYPE_RESPONSE {
+			record.StatusCode = int(startLine.StatusCode)
+		}
+	}
+	a.record(record)
+}
+
+/**
+ * Record raw MRCPv2 message, as sent or received by control connection.
+ * The start-line ("MRCP/2.0 <length> <method-name> <request-id>" of request,
+ * "MRCP/2.0 <length> <request-id> <status-code> <request-state>" of response,
+ * "MRCP/2.0 <length> <event-name> <request-id> <request-state>" of event) and Channel-Identifier are recorded.
+ * @param direction the direction of the message
+ * @param data the raw MRCPv2 message
+ */
+func (a *MRCPSessionAuditTrail) MRCPAuditRawMessageRecord(direction MRCPAuditDirection, data []byte) {
+	if a == nil {
+		return
+	}
+	record := MRCPAuditRecord{
+		Category:  MRCP_AUDIT_MESSAGE,
+		Direction: direction,
+	}
+	text := string(data)
+	if i := strings.Index(text, "\r\n\r\n"); i >= 0 {
+		text = text[:i]
+	}
+	lines := strings.Split(text, "\r\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) >= 4 {
+		if requestId, err := strconv.ParseUint(fields[2], 10, 32); err == nil {
+			record.MessageType = mrcpAuditMessageTypes[message.MRCP_MESSAGE_TYPE_RESPONSE]
+			record.RequestId = uint32(requestId)
+			record.StatusCode, _ = strconv.Atoi(fields[3])
+			if len(fields) > 4 {
+				record.RequestState = fields[4]
+			}
+		} else {
+			record.MethodName = fields[2]
+			requestId, _ := strconv.ParseUint(fields[3], 10, 32)
+			record.RequestId = uint32(requestId)
+			record.MessageType = mrcpAuditMessageTypes[message.MRCP_MESSAGE_TYPE_REQUEST]
+			if len(fields) > 4 {
+				record.MessageType = mrcpAuditMessageTypes[message.MRCP_MESSAGE_TYPE_EVENT]
+				record.RequestState = fields[4]
+			}
+		}
+	}
+	for _, line := range lines[1:] {
+		if i := strings.IndexByte(line, ':'); i > 0 && strings.EqualFold(strings.TrimSpace(line[:i]), "Channel-Identifier") {
+			record.ChannelId = strings.TrimSpace(line[i+1:])
+			break
+		}
+	}
+	a.record(record)
+}
+
+/** Get copy of the records in order */
+func (a *MRCPSessionAuditTrail) MRCPAuditRecordsGet() []MRCPAuditRecord {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.recordsCopy()
+}
+
+/** Export audit trail as JSON document, the records are copied so that the trail is not locked while writing */
+func (a *MRCPSessionAuditTrail) MRCPAuditExport(w io.Writer) error {
+	a.mutex.Lock()
+	document := struct {
+		SessionId string            `json:"session_id"`
+		Node      string            `json:"node,omitempty"`
+		Dropped   uint64            `json:"dropped"`
+		Records   []MRCPAuditRecord `json:"records"`
+	}{
+		SessionId: a.SessionId,
+		Node:      a.Node,
+		Dropped:   a.dropped,
+		Records:   a.recordsCopy(),
+	}
+	a.mutex.Unlock()
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&document)
+}
+
+/**
+ * Registry of audit trails of active sessions, keyed by node and session identifier,
+ * as the session migrated is served by two nodes under the same identifier during handoff.
+ */
+type MRCPAuditRegistry struct {
+	mutex  sync.RWMutex
+	trails map[mrcpAuditRegistryKey]*MRCPSessionAuditTrail
+
+	/** Directory to dump audit trails of abnormally terminated sessions to (os.TempDir() if empty) */
+	DumpDir string
+	/** Storage to dump audit trails and export CDRs to, overrides DumpDir (CDRs are not exported if nil) */
+	Storage storage.MRCPStorage
+}
+
+/* Key of audit trail in the registry */
+type mrcpAuditRegistryKey struct {
+	node      string
+	sessionId string
+}
+
+var (
+	defaultAuditRegistry     *MRCPAuditRegistry
+	defaultAuditRegistryOnce sync.Once
+)
+
+/** Create audit registry */
+func MRCPAuditRegistryCreate() *MRCPAuditRegistry {
+	return &MRCPAuditRegistry{trails: make(map[mrcpAuditRegistryKey]*MRCPSessionAuditTrail)}
+}
+
+/** Get default audit registry */
+func MRCPAuditRegistryDefaultGet() *MRCPAuditRegistry {
+	defaultAuditRegistryOnce.Do(func() {
+		defaultAuditRegistry = MRCPAuditRegistryCreate()
+	})
+	return defaultAuditRegistry
+}
+
+/** Register audit trail */
+func (r *MRCPAuditRegistry) MRCPAuditRegistryAdd(trail *MRCPSessionAuditTrail) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.trails[mrcpAuditRegistryKey{trail.Node, trail.SessionId}] = trail
+}
+
+/**
+ * Unregister audit trail.
+ * @param node the node serving the session, empty for the local one
+ * @param sessionId the session identifier
+ */
+func (r *MRCPAuditRegistry) MRCPAuditRegistryRemove(node, sessionId string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.trails, mrcpAuditRegistryKey{node, sessionId})
+}
+
+/**
+ * Get audit trail of session.
+ * @param node the node serving the session, empty for the local one
+ * @param sessionId the session identifier
+ */
+func (r *MRCPAuditRegistry) MRCPAuditRegistryGet(node, sessionId string) *MRCPSessionAuditTrail {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.trails[mrcpAuditRegistryKey{node, sessionId}]
+}
+
+/** Dump audit trail to <dump dir>/mrcp-audit-<session id>.json, or to audit/mrcp-audit-<session id>.json of the storage */
+func (r *MRCPAuditRegistry) MRCPAuditRegistryDump(trail *MRCPSessionAuditTrail) error {
+	name := fmt.Sprintf("mrcp-audit-%s.json", filepath.Base(trail.SessionId))
+	if trail.Node != "" {
+		name = fmt.Sprintf("mrcp-audit-%s-%s.json", filepath.Base(trail.Node), filepath.Base(trail.SessionId))
+	}
+	if r.Storage != nil {
+		var buffer bytes.Buffer
+		if err := trail.MRCPAuditExport(&buffer); err != nil {
+			return err
+		}
+		metadata := &storage.MRCPStorageMetadata{
+			ContentType: "application/json",
+			Attributes:  map[string]string{"session": trail.SessionId},
+		}
+		_, err := r.Storage.Put("audit/"+name, &buffer, metadata)
+		return err
+	}
+
+	dir := r.DumpDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	file, err := os.Create(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	if err := trail.MRCPAuditExport(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+/**
+ * Admin API handler exporting audit trail of the session specified by "session" query param,
+ * and by "node" query param for the session served by other than the local node,
+ * e.g. GET /audit?session=<session id>, GET /audit?node=<node>&session=<session id>
+ */
+func (r *MRCPAuditRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	sessionId := req.URL.Query().Get("session")
+	if sessionId == "" {
+		http.Error(w, "session is not specified", http.StatusBadRequest)
+		return
+	}
+	trail := r.MRCPAuditRegistryGet(req.URL.Query().Get("node"), sessionId)
+	if trail == nil {
+		http.Error(w, "no such session", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	trail.MRCPAuditExport(w)
+}
@@ -0,0 +1,228 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mpf"
+	"github.com/navi-tt/go-mrcp/mrcp/control"
+	"github.com/navi-tt/go-mrcp/mrcp/message"
+)
+
+func TestAuditTrailRing(t *testing.T) {
+	trail := MRCPSessionAuditTrailCreate("ring", 3)
+	for _, description := range []string{"a", "b", "c", "d", "e"} {
+		trail.MRCPAuditSignalingRecord(description)
+	}
+	records := trail.MRCPAuditRecordsGet()
+	if len(records) != 3 {
+		t.Fatalf("%d records", len(records))
+	}
+	for i, want := range []string{"c", "d", "e"} {
+		if records[i].Description != want || records[i].Seq != uint64(i+3) {
+			t.Fatalf("record %d %+v", i, records[i])
+		}
+	}
+
+	var document struct {
+		SessionId string            `json:"session_id"`
+		Dropped   uint64            `json:"dropped"`
+		Records   []MRCPAuditRecord `json:"records"`
+	}
+	var buffer bytes.Buffer
+	if err := trail.MRCPAuditExport(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buffer.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.SessionId != "ring" || document.Dropped != 2 || len(document.Records) != 3 || document.Records[0].Description != "c" {
+		t.Fatalf("document %+v", document)
+	}
+
+	/* unlimited trail keeps all the records */
+	trail = MRCPSessionAuditTrailCreate("unlimited", 0)
+	for i := 0; i < 10; i++ {
+		trail.MRCPAuditTopologyRecord("topology")
+	}
+	if records := trail.MRCPAuditRecordsGet(); len(records) != 10 || records[9].Seq != 10 {
+		t.Fatalf("%d records", len(records))
+	}
+}
+
+/* Writer recording to the trail while being written to */
+type auditTestWriter struct {
+	trail *MRCPSessionAuditTrail
+	bytes.Buffer
+}
+
+func (w *auditTestWriter) Write(p []byte) (int, error) {
+	w.trail.MRCPAuditSignalingRecord("written")
+	return w.Buffer.Write(p)
+}
+
+func TestAuditExportUnlocked(t *testing.T) {
+	trail := MRCPSessionAuditTrailCreate("export", 0)
+	trail.MRCPAuditSignalingRecord("created")
+	done := make(chan error, 1)
+	writer := &auditTestWriter{trail: trail}
+	go func() {
+		done <- trail.MRCPAuditExport(writer)
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("trail is locked while exported")
+	}
+	if !strings.Contains(writer.String(), `"created"`) || strings.Contains(writer.String(), `"written"`) {
+		t.Fatalf("export %s", writer.String())
+	}
+
+	/* admin API */
+	registry := MRCPAuditRegistryCreate()
+	registry.MRCPAuditRegistryAdd(trail)
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/audit?session=export", nil))
+	if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"session_id": "export"`) {
+		t.Fatalf("status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	recorder = httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/audit?session=none", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("status %d of unknown session", recorder.Code)
+	}
+}
+
+func TestAuditMessageRecord(t *testing.T) {
+	trail := MRCPSessionAuditTrailCreate("message", 0)
+	request := message.MRCPMessageCreate()
+	request.StartLine = &message.MRCPStartLine{MessageType: message.MRCP_MESSAGE_TYPE_REQUEST, MethodName: "SPEAK", RequestId: 7}
+	request.ChannelId.SessionId = "32AECB23433801"
+	request.ChannelId.ResourceName = "speechsynth"
+	trail.MRCPAuditMessageRecord(MRCP_AUDIT_DIRECTION_INBOUND, request)
+	response := message.MRCPResponseCreate(request)
+	response.StartLine.RequestState = message.MRCP_REQUEST_STATE_INPROGRESS
+	trail.MRCPAuditMessageRecord(MRCP_AUDIT_DIRECTION_OUTBOUND, response)
+
+	trail.MRCPAuditRawMessageRecord(MRCP_AUDIT_DIRECTION_OUTBOUND, []byte("MRCP/2.0 83 RECOGNIZE 8\r\nChannel-Identifier: 32AECB23433802@speechrecog\r\n\r\n"))
+	trail.MRCPAuditRawMessageRecord(MRCP_AUDIT_DIRECTION_INBOUND, []byte("MRCP/2.0 79 8 200 IN-PROGRESS\r\nChannel-Identifier: 32AECB23433802@speechrecog\r\n\r\n"))
+	trail.MRCPAuditRawMessageRecord(MRCP_AUDIT_DIRECTION_INBOUND, []byte("MRCP/2.0 99 RECOGNITION-COMPLETE 8 COMPLETE\r\nchannel-identifier: 32AECB23433802@speechrecog\r\n\r\n<result/>"))
+
+	want := []MRCPAuditRecord{
+		{Direction: "in", ChannelId: "32AECB23433801@speechsynth", MessageType: "request", MethodName: "SPEAK", RequestId: 7},
+		{Direction: "out", ChannelId: "32AECB23433801@speechsynth", MessageType: "response", MethodName: "SPEAK", RequestId: 7, StatusCode: 200, RequestState: "IN-PROGRESS"},
+		{Direction: "out", ChannelId: "32AECB23433802@speechrecog", MessageType: "request", MethodName: "RECOGNIZE", RequestId: 8},
+		{Direction: "in", ChannelId: "32AECB23433802@speechrecog", MessageType: "response", RequestId: 8, StatusCode: 200, RequestState: "IN-PROGRESS"},
+		{Direction: "in", ChannelId: "32AECB23433802@speechrecog", MessageType: "event", MethodName: "RECOGNITION-COMPLETE", RequestId: 8, RequestState: "COMPLETE"},
+	}
+	records := trail.MRCPAuditRecordsGet()
+	if len(records) != len(want) {
+		t.Fatalf("%d records", len(records))
+	}
+	for i, record := range records {
+		record.Seq, record.Time = 0, time.Time{}
+		want[i].Category = MRCP_AUDIT_MESSAGE
+		if record != want[i] {
+			t.Errorf("record %d %+v, want %+v", i, record, want[i])
+		}
+	}
+}
+
+/* Create raw MRCPv2 message of the start-line following the message-length */
+func auditTestMessageCreate(startLine string) []byte {
+	text := " " + startLine + "\r\nChannel-Identifier: connection@speechsynth\r\n\r\n"
+	length := len("MRCP/2.0 ") + len(text)
+	length += len(strconv.Itoa(length))
+	return []byte("MRCP/2.0 " + strconv.Itoa(length) + text)
+}
+
+func TestSessionConnectionAudit(t *testing.T) {
+	s := MRCPSessionCreate("connection", nil)
+	defer MRCPAuditRegistryDefaultGet().MRCPAuditRegistryRemove("", s.Id)
+	local, peer := net.Pipe()
+	defer peer.Close()
+	received := make(chan []byte, 1)
+	c := control.MRCPConnectionCreate("connection", local)
+	c.OnMessage = func(c *control.MRCPConnection, data []byte) error {
+		received <- data
+		return nil
+	}
+	s.MRCPSessionConnectionAudit(c)
+	c.MRCPConnectionStart()
+	defer c.MRCPConnectionClose()
+
+	go peer.Write(auditTestMessageCreate("SPEAK 1"))
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("message is not received")
+	}
+	if err := c.MRCPConnectionSend(auditTestMessageCreate("1 200 COMPLETE")); err != nil {
+		t.Fatal(err)
+	}
+	buffer := make([]byte, 256)
+	if _, err := peer.Read(buffer); err != nil {
+		t.Fatal(err)
+	}
+
+	var records []MRCPAuditRecord
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if records = s.Audit.MRCPAuditRecordsGet(); len(records) == 3 {
+			break
+		}
+	}
+	if len(records) != 3 {
+		t.Fatalf("records %+v", records)
+	}
+	if r := records[1]; r.Direction != MRCP_AUDIT_DIRECTION_INBOUND || r.MethodName != "SPEAK" || r.ChannelId != "connection@speechsynth" {
+		t.Fatalf("record of request %+v", r)
+	}
+	if r := records[2]; r.Direction != MRCP_AUDIT_DIRECTION_OUTBOUND || r.StatusCode != 200 || r.RequestState != "COMPLETE" {
+		t.Fatalf("record of response %+v", r)
+	}
+}
+
+func TestSessionContextAudit(t *testing.T) {
+	s := MRCPSessionCreate("context", nil)
+	defer MRCPAuditRegistryDefaultGet().MRCPAuditRegistryRemove("", s.Id)
+	context := mpf.ContextFactoryCreate().ContextCreate("context", nil, 2)
+	s.MRCPSessionContextAudit(context)
+
+	source := mpf.AudioStreamCreate(nil, &mpf.AudioStreamVTable{}, mpf.SourceStreamCapabilitiesCreate())
+	source.RXDescriptor = mpf.CodecLPcmDescriptorCreate(8000, 3)
+	sink := mpf.AudioStreamCreate(nil, &mpf.AudioStreamVTable{}, mpf.SinkStreamCapabilitiesCreate())
+	sink.TXDescriptor = mpf.CodecLPcmDescriptorCreate(11025, 1)
+	sink.TXDescriptor.Format = "ptime=25"
+	rtp := mpf.TerminationBaseCreate(nil, nil, nil, source, nil)
+	recognizer := mpf.TerminationBaseCreate(nil, nil, nil, sink, nil)
+	context.ContextTerminationAdd(rtp)
+	context.ContextTerminationAdd(recognizer)
+	if err := context.ContextAssociationAdd(rtp, recognizer); err != nil {
+		t.Fatal(err)
+	}
+	if err := context.ContextTopologyApply(); err == nil {
+		t.Fatal("topology of mismatching descriptors is applied")
+	}
+	sink.TXDescriptor = mpf.CodecLPcmDescriptorCreate(8000, 3)
+	if err := context.ContextTopologyApply(); err != nil {
+		t.Fatal(err)
+	}
+
+	records := s.Audit.MRCPAuditRecordsGet()
+	if len(records) != 3 || records[1].Category != MRCP_AUDIT_TOPOLOGY || records[2].Category != MRCP_AUDIT_TOPOLOGY {
+		t.Fatalf("records %+v", records)
+	}
+	if !strings.Contains(records[1].Description, "failed") || !strings.Contains(records[2].Description, "applied with 1 objects") {
+		t.Fatalf("records %+v", records)
+	}
+}
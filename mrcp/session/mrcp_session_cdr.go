@@ -0,0 +1,65 @@
+package session
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/navi-tt/go-mrcp/mrcp/storage"
+)
+
+/** Call detail record of terminated session */
+type MRCPSessionCDR struct {
+	SessionId    string    `json:"session_id"`    // Session identifier
+	Name         string    `json:"name"`          // Session name
+	StartTime    time.Time `json:"start_time"`    // Time the session is created at
+	EndTime      time.Time `json:"end_time"`      // Time the session is terminated at
+	Duration     int64     `json:"duration"`      // Duration of the session in msec
+	Reason       string    `json:"reason"`        // Reason of termination
+	Abnormal     bool      `json:"abnormal"`      // Whether the session is terminated abnormally
+	MessageCount int       `json:"message_count"` // Number of MRCP messages exchanged (as kept in the audit trail)
+}
+
+/**
+ * Create CDR of the session.
+ * @param reason the reason of termination
+ * @param abnormal whether the session is terminated abnormally
+ */
+func (s *MRCPSession) MRCPSessionCDRCreate(reason string, abnormal bool) *MRCPSessionCDR {
+	cdr := &MRCPSessionCDR{
+		SessionId: s.Id,
+		Name:      s.Name,
+		StartTime: s.StartTime,
+		EndTime:   time.Now(),
+		Reason:    reason,
+		Abnormal:  abnormal,
+	}
+	cdr.Duration = int64(cdr.EndTime.Sub(cdr.StartTime) / time.Millisecond)
+	for _, record := range s.Audit.MRCPAuditRecordsGet() {
+		if record.Category == MRCP_AUDIT_MESSAGE {
+			cdr.MessageCount++
+		}
+	}
+	return cdr
+}
+
+/**
+ * Export CDR to cdr/<yyyy-mm-dd>/<session id>.json of the storage.
+ * @param store the storage to export to
+ * @param cdr the CDR to export
+ * @return the URI of the exported CDR
+ */
+func MRCPSessionCDRExport(store storage.MRCPStorage, cdr *MRCPSessionCDR) (string, error) {
+	data, err := json.Marshal(cdr)
+	if err != nil {
+		return "", err
+	}
+	key := fmt.Sprintf("cdr/%s/%s.json", cdr.EndTime.UTC().Format("2006-01-02"), filepath.Base(cdr.SessionId))
+	metadata := &storage.MRCPStorageMetadata{
+		ContentType: "application/json",
+		Attributes:  map[string]string{"session": cdr.SessionId},
+	}
+	return store.Put(key, bytes.NewReader(data), metadata)
+}
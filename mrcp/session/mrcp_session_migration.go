@@ -0,0 +1,354 @@
+package session
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+/**
+ * Session migration between server instances (experimental).
+ *
+ * The instance going down offers the signaling state and the channels of an active session to a peer instance,
+ * which restores the session under the same identifiers and returns its control and media addresses.
+ * The client is then re-INVITEd to the addresses of the peer and the session is terminated locally,
+ * or the handoff is aborted if the re-INVITE fails, so that the session goes on at the original instance.
+ */
+
+/** Version of session handoff document */
+const MRCP_SESSION_HANDOFF_VERSION = 1
+
+/** Default time the session handed off is kept for until taken over, the handoff expires then */
+const MRCP_SESSION_HANDOFF_DEFAULT_EXPIRY = 30 * time.Second
+
+/** State of MRCP channel handed off */
+type MRCPChannelHandoff struct {
+	Id           string          `json:"id"`              // Channel identifier (Channel-Identifier header), kept by the peer
+	ResourceName string          `json:"resource_name"`   // Resource name, e.g. speechrecog
+	State        json.RawMessage `json:"state,omitempty"` // Resource (engine) specific state, e.g. the dictation in progress
+}
+
+/** Signaling state and channels of the session handed off */
+type MRCPSessionHandoff struct {
+	Version   int                   `json:"version"`    // Version of the document (MRCP_SESSION_HANDOFF_VERSION)
+	SessionId string                `json:"session_id"` // Session identifier, kept by the peer
+	Name      string                `json:"name"`       // Session name
+	StartTime time.Time             `json:"start_time"` // Time the session is created at
+	Signaling string                `json:"signaling"`  // Signaling state (e.g. SIP dialog and SDP), opaque for the session layer
+	Channels  []*MRCPChannelHandoff `json:"channels"`   // Channels of the session
+}
+
+/** Handoff accepted by the peer: the addresses the client is to be re-INVITEd to */
+type MRCPSessionHandoffAccept struct {
+	SessionId      string `json:"session_id"`      // Session identifier
+	ControlAddress string `json:"control_address"` // MRCP control (TCP/TLS) address of the peer
+	MediaAddress   string `json:"media_address"`   // RTP address of the peer
+}
+
+/** Peer instance sessions are handed off to */
+type MRCPSessionMigrationPeer interface {
+	/** Offer session handoff, the peer restores the session and returns its addresses */
+	HandoffOffer(handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error)
+	/** Abort handoff accepted, the session restored by the peer is terminated */
+	HandoffAbort(sessionId string) error
+}
+
+/**
+ * Create handoff of the session.
+ * @param signaling the signaling state to hand off
+ * @param channels the channels of the session
+ */
+func (s *MRCPSession) MRCPSessionHandoffCreate(signaling string, channels []*MRCPChannelHandoff) *MRCPSessionHandoff {
+	return &MRCPSessionHandoff{
+		Version:   MRCP_SESSION_HANDOFF_VERSION,
+		SessionId: s.Id,
+		Name:      s.Name,
+		StartTime: s.StartTime,
+		Signaling: signaling,
+		Channels:  channels,
+	}
+}
+
+/**
+ * Migrate the session to the peer instance.
+ * The session is terminated (normally) as soon as the client is re-INVITEd to the peer,
+ * the handoff is aborted if the re-INVITE fails.
+ * @param peer the peer instance
+ * @param handoff the handoff of the session
+ * @param reinvite the function re-INVITing the client to the addresses of the peer
+ */
+func (s *MRCPSession) MRCPSessionMigrate(peer MRCPSessionMigrationPeer, handoff *MRCPSessionHandoff,
+	reinvite func(accept *MRCPSessionHandoffAccept) error) error {
+	s.Audit.MRCPAuditSignalingRecord("session handoff offered")
+	accept, err := peer.HandoffOffer(handoff)
+	if err != nil {
+		s.Audit.MRCPAuditSignalingRecord("session handoff rejected: " + err.Error())
+		return err
+	}
+	if err := reinvite(accept); err != nil {
+		s.Audit.MRCPAuditSignalingRecord("session re-INVITE failed: " + err.Error())
+		if abortErr := peer.HandoffAbort(handoff.SessionId); abortErr != nil {
+			return fmt.Errorf("failed to re-INVITE client: %v, failed to abort handoff: %v", err, abortErr)
+		}
+		return err
+	}
+	return s.MRCPSessionTerminate(fmt.Sprintf("migrated to %s", accept.ControlAddress), false)
+}
+
+/**
+ * Restore session handed off by the peer instance.
+ * @param node the node restoring the session, its audit trail is registered by (empty for the local one)
+ * @param handoff the handoff of the session
+ * @param obj the external object associated with session
+ */
+func MRCPSessionImport(node string, handoff *MRCPSessionHandoff, obj interface{}) (*MRCPSession, error) {
+	if handoff.Version != MRCP_SESSION_HANDOFF_VERSION {
+		return nil, fmt.Errorf("session handoff version %d is not supported", handoff.Version)
+	}
+	if handoff.SessionId == "" {
+		return nil, fmt.Errorf("no session identifier in handoff")
+	}
+	s := mrcpSessionCreate(node, handoff.SessionId, obj)
+	s.Name = handoff.Name
+	if !handoff.StartTime.IsZero() {
+		s.StartTime = handoff.StartTime
+	}
+	s.Audit.MRCPAuditSignalingRecord(fmt.Sprintf("session handed off with %d channels", len(handoff.Channels)))
+	return s, nil
+}
+
+/**
+ * Acceptor of sessions handed off to this instance, the local peer and the admin API handler
+ * of the migration (POST /handoff with the handoff document, DELETE /handoff?session=<session id> to abort).
+ * The admin API requests are authenticated by the bearer token ("Authorization: Bearer <token>").
+ * The session handed off and not taken over within Expiry is terminated.
+ */
+type MRCPSessionMigrationAcceptor struct {
+	/** Restore channels and signaling of the session imported, returning the addresses of this instance */
+	Accept func(session *MRCPSession, handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error)
+	/** Time the session handed off is kept for until taken over */
+	Expiry time.Duration
+
+	node  string
+	token string
+
+	mutex    sync.Mutex
+	sessions map[string]*mrcpSessionHandoffPending
+}
+
+/* Session handed off, not taken over yet */
+type mrcpSessionHandoffPending struct {
+	session *MRCPSession // Session, nil while it is being restored
+	timer   *time.Timer  // Expiry timer
+}
+
+/**
+ * Create migration acceptor.
+ * @param node the node of this instance, the audit trails of the sessions imported are registered by
+ * (empty if it is the only node of the process)
+ * @param token the bearer token authenticating the admin API requests, all of them are rejected if empty
+ * @param accept the function restoring channels and signaling of the session imported
+ */
+func MRCPSessionMigrationAcceptorCreate(node, token string,
+	accept func(session *MRCPSession, handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error)) *MRCPSessionMigrationAcceptor {
+	return &MRCPSessionMigrationAcceptor{
+		Accept:   accept,
+		Expiry:   MRCP_SESSION_HANDOFF_DEFAULT_EXPIRY,
+		node:     node,
+		token:    token,
+		sessions: make(map[string]*mrcpSessionHandoffPending),
+	}
+}
+
+/** Import session handed off and restore it */
+func (a *MRCPSessionMigrationAcceptor) HandoffOffer(handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error) {
+	/* reserve the session identifier until the session is restored, so that concurrent offers of it are rejected */
+	pending := &mrcpSessionHandoffPending{}
+	a.mutex.Lock()
+	if _, exists := a.sessions[handoff.SessionId]; exists {
+		a.mutex.Unlock()
+		return nil, fmt.Errorf("session %s is already handed off", handoff.SessionId)
+	}
+	a.sessions[handoff.SessionId] = pending
+	a.mutex.Unlock()
+
+	s, err := MRCPSessionImport(a.node, handoff, nil)
+	if err != nil {
+		a.pendingTake(handoff.SessionId, pending)
+		return nil, err
+	}
+	accept, err := a.Accept(s, handoff)
+	if err == nil && accept == nil {
+		err = fmt.Errorf("no addresses to take session %s over at", s.Id)
+	}
+	if err != nil {
+		a.pendingTake(s.Id, pending)
+		s.MRCPSessionTerminate("handoff rejected: "+err.Error(), true)
+		return nil, err
+	}
+	accept.SessionId = s.Id
+	a.mutex.Lock()
+	pending.session = s
+	if a.Expiry > 0 {
+		pending.timer = time.AfterFunc(a.Expiry, func() {
+			if a.pendingTake(s.Id, pending) != nil {
+				s.MRCPSessionTerminate("handoff expired, not taken over", true)
+			}
+		})
+	}
+	a.mutex.Unlock()
+	return accept, nil
+}
+
+/*
+ * Remove session handed off (only the pending one specified if not nil) and stop its expiry, return the one removed.
+ * The session being restored is removed by its offer only.
+ */
+func (a *MRCPSessionMigrationAcceptor) pendingTake(sessionId string, pending *mrcpSessionHandoffPending) *mrcpSessionHandoffPending {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	current := a.sessions[sessionId]
+	if current == nil || (pending != nil && current != pending) || (pending == nil && current.session == nil) {
+		return nil
+	}
+	delete(a.sessions, sessionId)
+	if current.timer != nil {
+		current.timer.Stop()
+	}
+	return current
+}
+
+/** Terminate session of aborted handoff */
+func (a *MRCPSessionMigrationAcceptor) HandoffAbort(sessionId string) error {
+	pending := a.pendingTake(sessionId, nil)
+	if pending == nil {
+		return fmt.Errorf("no session %s handed off", sessionId)
+	}
+	return pending.session.MRCPSessionTerminate("handoff aborted", false)
+}
+
+/**
+ * Take session handed off over, it is not abortable and does not expire then.
+ * @param sessionId the session identifier
+ * @return the session, nil if it is not handed off (or it expired)
+ */
+func (a *MRCPSessionMigrationAcceptor) MRCPSessionTakeOver(sessionId string) *MRCPSession {
+	pending := a.pendingTake(sessionId, nil)
+	if pending == nil {
+		return nil
+	}
+	return pending.session
+}
+
+/* Check the bearer token of the admin API request */
+func (a *MRCPSessionMigrationAcceptor) authorized(req *http.Request) bool {
+	authorization := req.Header.Get("Authorization")
+	if a.token == "" || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	token := authorization[len("Bearer "):]
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.token)) == 1
+}
+
+/** Admin API handler of session handoff */
+func (a *MRCPSessionMigrationAcceptor) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if !a.authorized(req) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch req.Method {
+	case http.MethodPost:
+		handoff := &MRCPSessionHandoff{}
+		if err := json.NewDecoder(req.Body).Decode(handoff); err != nil {
+			http.Error(w, "invalid handoff: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		accept, err := a.HandoffOffer(handoff)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(accept)
+	case http.MethodDelete:
+		if err := a.HandoffAbort(req.URL.Query().Get("session")); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		}
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+/* Peer instance reached via the admin API */
+type mrcpSessionMigrationHTTPPeer struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+/**
+ * Create peer instance reached via the admin API of its migration acceptor.
+ * @param url the URL of the handoff handler of the peer, e.g. http://10.0.0.2:8080/handoff
+ * @param token the bearer token of the admin API of the peer
+ * @param client the HTTP client (http.DefaultClient if nil)
+ */
+func MRCPSessionMigrationHTTPPeerCreate(url, token string, client *http.Client) MRCPSessionMigrationPeer {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &mrcpSessionMigrationHTTPPeer{url: url, token: token, client: client}
+}
+
+/* Send admin API request authenticated by the bearer token */
+func (p *mrcpSessionMigrationHTTPPeer) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	return p.client.Do(req)
+}
+
+func (p *mrcpSessionMigrationHTTPPeer) HandoffOffer(handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error) {
+	data, err := json.Marshal(handoff)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, p.url, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("session handoff is rejected by peer: %s", resp.Status)
+	}
+	accept := &MRCPSessionHandoffAccept{}
+	if err := json.NewDecoder(resp.Body).Decode(accept); err != nil {
+		return nil, err
+	}
+	return accept, nil
+}
+
+func (p *mrcpSessionMigrationHTTPPeer) HandoffAbort(sessionId string) error {
+	req, err := http.NewRequest(http.MethodDelete, p.url+"?session="+url.QueryEscape(sessionId), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := p.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("session handoff abort is rejected by peer: %s", resp.Status)
+	}
+	return nil
+}
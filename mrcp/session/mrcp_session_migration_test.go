@@ -0,0 +1,217 @@
+package session
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+/* Create acceptor of node b served by the admin API */
+func migrationTestAcceptorCreate(t *testing.T) (*MRCPSessionMigrationAcceptor, *httptest.Server) {
+	acceptor := MRCPSessionMigrationAcceptorCreate("b", "secret", func(session *MRCPSession, handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error) {
+		if len(handoff.Channels) != 1 || handoff.Channels[0].ResourceName != "speechrecog" {
+			return nil, fmt.Errorf("unexpected channels")
+		}
+		return &MRCPSessionHandoffAccept{ControlAddress: "10.0.0.2:1544", MediaAddress: "10.0.0.2:5000"}, nil
+	})
+	return acceptor, httptest.NewServer(acceptor)
+}
+
+func migrationTestHandoffCreate(s *MRCPSession) *MRCPSessionHandoff {
+	return s.MRCPSessionHandoffCreate("dialog", []*MRCPChannelHandoff{{Id: s.Id + "@speechrecog", ResourceName: "speechrecog"}})
+}
+
+func TestSessionMigrationHTTP(t *testing.T) {
+	acceptor, server := migrationTestAcceptorCreate(t)
+	defer server.Close()
+	registry := MRCPAuditRegistryDefaultGet()
+	peer := MRCPSessionMigrationHTTPPeerCreate(server.URL, "secret", nil)
+
+	s := MRCPSessionCreate("migrated", nil)
+	err := s.MRCPSessionMigrate(peer, migrationTestHandoffCreate(s), func(accept *MRCPSessionHandoffAccept) error {
+		if accept.SessionId != s.Id || accept.ControlAddress != "10.0.0.2:1544" {
+			return fmt.Errorf("accept %+v", accept)
+		}
+		/* the session is served by both nodes during handoff */
+		if local, remote := registry.MRCPAuditRegistryGet("", s.Id), registry.MRCPAuditRegistryGet("b", s.Id); local != s.Audit || remote == nil || remote == local {
+			return fmt.Errorf("audit trails %p, %p", local, remote)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if registry.MRCPAuditRegistryGet("", s.Id) != nil {
+		t.Fatal("audit trail of the session migrated is registered")
+	}
+	taken := acceptor.MRCPSessionTakeOver(s.Id)
+	if taken == nil || taken.Name != s.Name || !taken.StartTime.Equal(s.StartTime) {
+		t.Fatalf("session taken over %+v", taken)
+	}
+	defer taken.MRCPSessionTerminate("done", false)
+	if registry.MRCPAuditRegistryGet("b", s.Id) != taken.Audit {
+		t.Fatal("audit trail of the session taken over is not registered")
+	}
+	if acceptor.MRCPSessionTakeOver(s.Id) != nil || acceptor.HandoffAbort(s.Id) == nil {
+		t.Fatal("session is taken over twice")
+	}
+}
+
+func TestSessionMigrationAbort(t *testing.T) {
+	acceptor, server := migrationTestAcceptorCreate(t)
+	defer server.Close()
+	registry := MRCPAuditRegistryDefaultGet()
+
+	s := MRCPSessionCreate("aborted", nil)
+	defer s.MRCPSessionTerminate("done", false)
+	err := s.MRCPSessionMigrate(MRCPSessionMigrationHTTPPeerCreate(server.URL, "secret", nil), migrationTestHandoffCreate(s),
+		func(accept *MRCPSessionHandoffAccept) error {
+			return fmt.Errorf("re-INVITE timed out")
+		})
+	if err == nil || !strings.Contains(err.Error(), "re-INVITE timed out") {
+		t.Fatalf("error %v", err)
+	}
+	/* the session goes on at the original node */
+	if registry.MRCPAuditRegistryGet("", s.Id) != s.Audit || registry.MRCPAuditRegistryGet("b", s.Id) != nil {
+		t.Fatal("audit trails of the handoff aborted")
+	}
+	if acceptor.MRCPSessionTakeOver(s.Id) != nil {
+		t.Fatal("session of the handoff aborted is taken over")
+	}
+}
+
+func TestSessionMigrationUnauthorized(t *testing.T) {
+	acceptor, server := migrationTestAcceptorCreate(t)
+	defer server.Close()
+
+	s := MRCPSessionCreate("unauthorized", nil)
+	defer s.MRCPSessionTerminate("done", false)
+	_, err := MRCPSessionMigrationHTTPPeerCreate(server.URL, "guess", nil).HandoffOffer(migrationTestHandoffCreate(s))
+	if err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("error %v", err)
+	}
+	resp, err := http.Post(server.URL, "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("status %d of request of no token", resp.StatusCode)
+	}
+	if acceptor.MRCPSessionTakeOver(s.Id) != nil {
+		t.Fatal("session of unauthorized handoff is taken over")
+	}
+
+	/* the token is to be of the bearer scheme */
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodDelete, "/handoff?session=x", nil)
+	request.Header.Set("Authorization", "secret")
+	acceptor.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d of request of no bearer scheme", recorder.Code)
+	}
+
+	/* the admin API of no token is disabled */
+	recorder = httptest.NewRecorder()
+	request = httptest.NewRequest(http.MethodDelete, "/handoff?session=x", nil)
+	request.Header.Set("Authorization", "Bearer ")
+	MRCPSessionMigrationAcceptorCreate("", "", nil).ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("status %d of acceptor of no token", recorder.Code)
+	}
+}
+
+func TestSessionMigrationExpiry(t *testing.T) {
+	acceptor, server := migrationTestAcceptorCreate(t)
+	server.Close()
+	acceptor.Expiry = 20 * time.Millisecond
+	registry := MRCPAuditRegistryDefaultGet()
+	dumpDir := registry.DumpDir
+	registry.DumpDir = t.TempDir()
+	defer func() { registry.DumpDir = dumpDir }()
+
+	s := MRCPSessionCreate("expired", nil)
+	defer s.MRCPSessionTerminate("done", false)
+	if _, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s)); err == nil {
+		t.Fatal("session is handed off twice")
+	}
+	for deadline := time.Now().Add(2 * time.Second); registry.MRCPAuditRegistryGet("b", s.Id) != nil && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+	}
+	if registry.MRCPAuditRegistryGet("b", s.Id) != nil {
+		t.Fatal("session of the handoff expired is registered")
+	}
+	if acceptor.MRCPSessionTakeOver(s.Id) != nil {
+		t.Fatal("session of the handoff expired is taken over")
+	}
+	if registry.MRCPAuditRegistryGet("", s.Id) != s.Audit {
+		t.Fatal("audit trail of the original session is unregistered")
+	}
+}
+
+func TestSessionMigrationConcurrentOffer(t *testing.T) {
+	restoring, restored := make(chan struct{}), make(chan struct{})
+	acceptor := MRCPSessionMigrationAcceptorCreate("b", "secret", func(session *MRCPSession, handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error) {
+		close(restoring)
+		<-restored
+		return &MRCPSessionHandoffAccept{ControlAddress: "10.0.0.2:1544", MediaAddress: "10.0.0.2:5000"}, nil
+	})
+	registry := MRCPAuditRegistryDefaultGet()
+
+	s := MRCPSessionCreate("concurrent", nil)
+	defer s.MRCPSessionTerminate("done", false)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s))
+		errs <- err
+	}()
+	<-restoring
+
+	/* the session being restored is reserved: offered again, aborted and taken over by nobody else */
+	if _, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s)); err == nil {
+		t.Fatal("session being restored is handed off twice")
+	}
+	if acceptor.HandoffAbort(s.Id) == nil || acceptor.MRCPSessionTakeOver(s.Id) != nil {
+		t.Fatal("session being restored is taken")
+	}
+	close(restored)
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	taken := acceptor.MRCPSessionTakeOver(s.Id)
+	if taken == nil || registry.MRCPAuditRegistryGet("b", s.Id) != taken.Audit {
+		t.Fatalf("session taken over %+v", taken)
+	}
+	taken.MRCPSessionTerminate("done", false)
+}
+
+func TestSessionMigrationNoAccept(t *testing.T) {
+	var accept *MRCPSessionHandoffAccept
+	acceptor := MRCPSessionMigrationAcceptorCreate("b", "secret", func(session *MRCPSession, handoff *MRCPSessionHandoff) (*MRCPSessionHandoffAccept, error) {
+		return accept, nil
+	})
+	registry := MRCPAuditRegistryDefaultGet()
+
+	s := MRCPSessionCreate("no accept", nil)
+	defer s.MRCPSessionTerminate("done", false)
+	if _, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s)); err == nil {
+		t.Fatal("session is handed off of no addresses")
+	}
+	if registry.MRCPAuditRegistryGet("b", s.Id) != nil {
+		t.Fatal("session of the handoff rejected is registered")
+	}
+
+	/* the session identifier is released */
+	accept = &MRCPSessionHandoffAccept{ControlAddress: "10.0.0.2:1544", MediaAddress: "10.0.0.2:5000"}
+	if _, err := acceptor.HandoffOffer(migrationTestHandoffCreate(s)); err != nil {
+		t.Fatal(err)
+	}
+	if acceptor.HandoffAbort(s.Id) != nil {
+		t.Fatal("handoff is not aborted")
+	}
+}
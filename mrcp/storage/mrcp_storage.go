@@ -0,0 +1,95 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/** Error of object not found in storage */
+var ErrMRCPStorageNotFound = errors.New("object not found")
+
+/** Metadata of stored object */
+type MRCPStorageMetadata struct {
+	ContentType string            `json:"content_type,omitempty"` // MIME type of the object
+	Size        int64             `json:"size"`                   // Size of the object in bytes (set by the storage)
+	Attributes  map[string]string `json:"attributes,omitempty"`   // User defined attributes (session id, channel id, etc.)
+}
+
+/**
+ * Storage backend for artifacts produced by sessions (waveforms, recordings, CDRs),
+ * so deployments can keep them off local disks.
+ * Keys are slash separated paths, e.g. "recordings/<session id>/<channel id>-1.pcm".
+ */
+type MRCPStorage interface {
+	/**
+	 * Put object to storage, replacing the existing one.
+	 * @param key the object key
+	 * @param r the object data
+	 * @param metadata the object metadata (may be nil)
+	 * @return the URI the object is accessible by (e.g. Waveform-URI/Record-URI)
+	 */
+	Put(key string, r io.Reader, metadata *MRCPStorageMetadata) (string, error)
+
+	/**
+	 * Get object from storage, the caller must close the returned reader.
+	 * @param key the object key
+	 */
+	Get(key string) (io.ReadCloser, *MRCPStorageMetadata, error)
+
+	/**
+	 * Delete object from storage.
+	 * @param key the object key
+	 */
+	Delete(key string) error
+}
+
+/* Check object key: non empty relative slash separated path not escaping the storage root */
+func mrcpStorageKeyCheck(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return fmt.Errorf("invalid storage key [%s]", key)
+	}
+	for _, segment := range strings.Split(key, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return fmt.Errorf("invalid storage key [%s]", key)
+		}
+	}
+	return nil
+}
+
+/** Storage params used to create storage by config */
+const (
+	MRCP_STORAGE_PARAM_TYPE       = "storage"            /**< storage type: local (default) or s3 */
+	MRCP_STORAGE_PARAM_DIR        = "storage-dir"        /**< root dir of local storage */
+	MRCP_STORAGE_PARAM_ENDPOINT   = "storage-endpoint"   /**< endpoint of S3-compatible storage, e.g. https://s3.example.com */
+	MRCP_STORAGE_PARAM_REGION     = "storage-region"     /**< region of S3-compatible storage */
+	MRCP_STORAGE_PARAM_BUCKET     = "storage-bucket"     /**< bucket of S3-compatible storage */
+	MRCP_STORAGE_PARAM_ACCESS_KEY = "storage-access-key" /**< access key of S3-compatible storage */
+	MRCP_STORAGE_PARAM_SECRET_KEY = "storage-secret-key" /**< secret key of S3-compatible storage */
+)
+
+/**
+ * Create storage by config params.
+ * @param params the table of name/value params (see MRCP_STORAGE_PARAM_*)
+ * @param defaultDir the root dir of local storage if not configured
+ */
+func MRCPStorageCreate(params map[string]string, defaultDir string) (MRCPStorage, error) {
+	switch strings.ToLower(params[MRCP_STORAGE_PARAM_TYPE]) {
+	case "", "local":
+		dir := params[MRCP_STORAGE_PARAM_DIR]
+		if dir == "" {
+			dir = defaultDir
+		}
+		return MRCPLocalStorageCreate(dir)
+	case "s3":
+		return MRCPS3StorageCreate(&MRCPS3StorageConfig{
+			Endpoint:  params[MRCP_STORAGE_PARAM_ENDPOINT],
+			Region:    params[MRCP_STORAGE_PARAM_REGION],
+			Bucket:    params[MRCP_STORAGE_PARAM_BUCKET],
+			AccessKey: params[MRCP_STORAGE_PARAM_ACCESS_KEY],
+			SecretKey: params[MRCP_STORAGE_PARAM_SECRET_KEY],
+		})
+	}
+	return nil, fmt.Errorf("unknown storage type %s", params[MRCP_STORAGE_PARAM_TYPE])
+}
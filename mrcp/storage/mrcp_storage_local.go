@@ -0,0 +1,125 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+/** Suffix of the file the metadata of the object is kept in */
+const MRCP_LOCAL_STORAGE_META_SUFFIX = ".meta.json"
+
+/** Storage keeping objects in local file system, under the root dir */
+type MRCPLocalStorage struct {
+	Root string // Root dir of the storage
+}
+
+/**
+ * Create local storage.
+ * @param root the root dir of the storage, created if not exists
+ */
+func MRCPLocalStorageCreate(root string) (*MRCPLocalStorage, error) {
+	if root == "" {
+		return nil, fmt.Errorf("root dir of local storage is not specified")
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, err
+	}
+	return &MRCPLocalStorage{Root: root}, nil
+}
+
+/* Get path to the file of the object */
+func (s *MRCPLocalStorage) path(key string) (string, error) {
+	if err := mrcpStorageKeyCheck(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(key)), nil
+}
+
+/** Put object to file, written to a temp file first and renamed, so readers never see partial objects */
+func (s *MRCPLocalStorage) Put(key string, r io.Reader, metadata *MRCPStorageMetadata) (string, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".put-")
+	if err != nil {
+		return "", err
+	}
+	size, err := io.Copy(file, r)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+
+	meta := MRCPStorageMetadata{Size: size}
+	if metadata != nil {
+		meta.ContentType = metadata.ContentType
+		meta.Attributes = metadata.Attributes
+	}
+	data, err := json.Marshal(&meta)
+	if err == nil {
+		err = ioutil.WriteFile(path+MRCP_LOCAL_STORAGE_META_SUFFIX, data, 0644)
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), path)
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+/** Get object from file */
+func (s *MRCPLocalStorage) Get(key string) (io.ReadCloser, *MRCPStorageMetadata, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, ErrMRCPStorageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &MRCPStorageMetadata{}
+	if data, err := ioutil.ReadFile(path + MRCP_LOCAL_STORAGE_META_SUFFIX); err == nil {
+		if err := json.Unmarshal(data, meta); err != nil {
+			file.Close()
+			return nil, nil, fmt.Errorf("invalid metadata of %s: %v", key, err)
+		}
+	}
+	if info, err := file.Stat(); err == nil {
+		meta.Size = info.Size()
+	}
+	return file, meta, nil
+}
+
+/** Delete object file and its metadata */
+func (s *MRCPLocalStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	os.Remove(path + MRCP_LOCAL_STORAGE_META_SUFFIX)
+	if os.IsNotExist(err) {
+		return ErrMRCPStorageNotFound
+	}
+	return err
+}
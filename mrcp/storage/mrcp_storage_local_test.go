@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func localTestCreate(t *testing.T) (*MRCPLocalStorage, func()) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := MRCPLocalStorageCreate(filepath.Join(dir, "root"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestLocalStoragePutGet(t *testing.T) {
+	s, cleanup := localTestCreate(t)
+	defer cleanup()
+
+	key := "recordings/session-1/channel-1-1.pcm"
+	uri, err := s.Put(key, strings.NewReader("first"), &MRCPStorageMetadata{
+		ContentType: "audio/L16",
+		Attributes:  map[string]string{"session-id": "session-1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri != "file://"+filepath.ToSlash(filepath.Join(s.Root, "recordings", "session-1", "channel-1-1.pcm")) {
+		t.Fatalf("uri %s", uri)
+	}
+
+	/* the object is replaced as a whole, the temp files are not left */
+	if _, err := s.Put(key, bytes.NewReader([]byte("replaced")), &MRCPStorageMetadata{ContentType: "audio/L16"}); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := ioutil.ReadDir(filepath.Join(s.Root, "recordings", "session-1"))
+	if len(files) != 2 {
+		t.Fatalf("%d files of the object", len(files))
+	}
+
+	r, meta, err := s.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadAll(r)
+	r.Close()
+	if err != nil || string(data) != "replaced" {
+		t.Fatalf("object [%s]: %v", data, err)
+	}
+	if meta.ContentType != "audio/L16" || meta.Size != int64(len("replaced")) || meta.Attributes != nil {
+		t.Fatalf("metadata %+v", meta)
+	}
+
+	if err := s.Delete(key); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get(key); err != ErrMRCPStorageNotFound {
+		t.Fatalf("deleted object got: %v", err)
+	}
+	if err := s.Delete(key); err != ErrMRCPStorageNotFound {
+		t.Fatalf("deleted object deleted: %v", err)
+	}
+	files, _ = ioutil.ReadDir(filepath.Join(s.Root, "recordings", "session-1"))
+	if len(files) != 0 {
+		t.Fatalf("%d files left", len(files))
+	}
+}
+
+func TestLocalStorageNoMetadata(t *testing.T) {
+	s, cleanup := localTestCreate(t)
+	defer cleanup()
+
+	/* the object written apart from the storage is got with its size */
+	if err := ioutil.WriteFile(filepath.Join(s.Root, "cdr.json"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r, meta, err := s.Get("cdr.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Close()
+	if meta.Size != 2 || meta.ContentType != "" {
+		t.Fatalf("metadata %+v", meta)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(s.Root, "cdr.json"+MRCP_LOCAL_STORAGE_META_SUFFIX), []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := s.Get("cdr.json"); err == nil {
+		t.Fatal("object of invalid metadata got")
+	}
+}
+
+func TestLocalStorageInvalidKey(t *testing.T) {
+	s, cleanup := localTestCreate(t)
+	defer cleanup()
+	for _, key := range []string{"", "/etc/passwd", "../escape", "a/../../escape", "a//b", "a/./b", "a/"} {
+		if _, err := s.Put(key, strings.NewReader("data"), nil); err == nil {
+			t.Errorf("object put by key [%s]", key)
+		}
+		if _, _, err := s.Get(key); err == nil || err == ErrMRCPStorageNotFound {
+			t.Errorf("object got by key [%s]: %v", key, err)
+		}
+		if err := s.Delete(key); err == nil || err == ErrMRCPStorageNotFound {
+			t.Errorf("object deleted by key [%s]: %v", key, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(s.Root), "escape")); !os.IsNotExist(err) {
+		t.Fatal("object put out of the root")
+	}
+}
+
+func TestStorageCreate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s, err := MRCPStorageCreate(map[string]string{}, dir)
+	if local, ok := s.(*MRCPLocalStorage); err != nil || !ok || local.Root != dir {
+		t.Fatalf("default storage %+v: %v", s, err)
+	}
+	s, err = MRCPStorageCreate(map[string]string{MRCP_STORAGE_PARAM_TYPE: "LOCAL", MRCP_STORAGE_PARAM_DIR: filepath.Join(dir, "configured")}, dir)
+	if local, ok := s.(*MRCPLocalStorage); err != nil || !ok || local.Root != filepath.Join(dir, "configured") {
+		t.Fatalf("local storage %+v: %v", s, err)
+	}
+	s, err = MRCPStorageCreate(map[string]string{
+		MRCP_STORAGE_PARAM_TYPE:     "s3",
+		MRCP_STORAGE_PARAM_ENDPOINT: "http://minio:9000",
+		MRCP_STORAGE_PARAM_BUCKET:   "artifacts",
+	}, dir)
+	if s3, ok := s.(*MRCPS3Storage); err != nil || !ok || s3.config.Region != "us-east-1" {
+		t.Fatalf("s3 storage %+v: %v", s, err)
+	}
+	if _, err := MRCPStorageCreate(map[string]string{MRCP_STORAGE_PARAM_TYPE: "s3"}, dir); err == nil {
+		t.Fatal("s3 storage created without endpoint")
+	}
+	if _, err := MRCPStorageCreate(map[string]string{MRCP_STORAGE_PARAM_TYPE: "ftp"}, dir); err == nil {
+		t.Fatal("storage of unknown type created")
+	}
+}
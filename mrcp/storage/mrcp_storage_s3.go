@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/** Prefix of S3 headers carrying user defined metadata */
+const mrcpS3MetaHeaderPrefix = "X-Amz-Meta-"
+
+/** Config of S3-compatible storage */
+type MRCPS3StorageConfig struct {
+	Endpoint  string // Endpoint URL, e.g. https://s3.eu-west-1.amazonaws.com or http://minio:9000
+	Region    string // Region the requests are signed for (us-east-1 if empty)
+	Bucket    string // Bucket to keep objects in
+	AccessKey string // Access key id
+	SecretKey string // Secret access key
+
+	Client *http.Client // HTTP client (http.DefaultClient if nil)
+}
+
+/** Storage keeping objects in S3-compatible object storage, addressed path-style (<endpoint>/<bucket>/<key>) */
+type MRCPS3Storage struct {
+	config   MRCPS3StorageConfig
+	endpoint *url.URL
+
+	/** Time source of request signing */
+	now func() time.Time
+}
+
+/**
+ * Create S3-compatible storage.
+ * @param config the storage config
+ */
+func MRCPS3StorageCreate(config *MRCPS3StorageConfig) (*MRCPS3Storage, error) {
+	if config.Endpoint == "" || config.Bucket == "" {
+		return nil, fmt.Errorf("endpoint or bucket of s3 storage is not specified")
+	}
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 storage endpoint [%s]", config.Endpoint)
+	}
+	s := &MRCPS3Storage{
+		config:   *config,
+		endpoint: endpoint,
+		now:      time.Now,
+	}
+	if s.config.Region == "" {
+		s.config.Region = "us-east-1"
+	}
+	if s.config.Client == nil {
+		s.config.Client = http.DefaultClient
+	}
+	return s, nil
+}
+
+/* URI encode per AWS signature version 4, keeping slashes of the path */
+func mrcpS3UriEncode(value string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', path && c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func mrcpS3Hmac(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func mrcpS3Sha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+/* Create request signed by AWS signature version 4 */
+func (s *MRCPS3Storage) requestCreate(method, key string, body []byte) (*http.Request, error) {
+	if err := mrcpStorageKeyCheck(key); err != nil {
+		return nil, err
+	}
+	path := s.endpoint.Path + "/" + s.config.Bucket + "/" + key
+	requestUrl := *s.endpoint
+	requestUrl.Path = path
+	requestUrl.RawPath = mrcpS3UriEncode(path, true)
+	req, err := http.NewRequest(method, requestUrl.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := mrcpS3Sha256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		method,
+		requestUrl.RawPath,
+		"",
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + mrcpS3Sha256([]byte(canonicalRequest))
+
+	signingKey := mrcpS3Hmac([]byte("AWS4"+s.config.SecretKey), date)
+	signingKey = mrcpS3Hmac(signingKey, s.config.Region)
+	signingKey = mrcpS3Hmac(signingKey, "s3")
+	signingKey = mrcpS3Hmac(signingKey, "aws4_request")
+	signature := hex.EncodeToString(mrcpS3Hmac(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+	return req, nil
+}
+
+/* Perform request, failing on non 2xx status */
+func (s *MRCPS3Storage) requestDo(req *http.Request) (*http.Response, error) {
+	resp, err := s.config.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 == 2 {
+		return resp, nil
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrMRCPStorageNotFound
+	}
+	return nil, fmt.Errorf("s3 %s %s failed: %s", req.Method, req.URL.Path, resp.Status)
+}
+
+/** Put object to the bucket, metadata attributes are sent as x-amz-meta-* headers */
+func (s *MRCPS3Storage) Put(key string, r io.Reader, metadata *MRCPStorageMetadata) (string, error) {
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	req, err := s.requestCreate(http.MethodPut, key, body)
+	if err != nil {
+		return "", err
+	}
+	req.ContentLength = int64(len(body))
+	if metadata != nil {
+		if metadata.ContentType != "" {
+			req.Header.Set("Content-Type", metadata.ContentType)
+		}
+		for name, value := range metadata.Attributes {
+			req.Header.Set(mrcpS3MetaHeaderPrefix+name, value)
+		}
+	}
+	resp, err := s.requestDo(req)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return req.URL.String(), nil
+}
+
+/** Get object from the bucket */
+func (s *MRCPS3Storage) Get(key string) (io.ReadCloser, *MRCPStorageMetadata, error) {
+	req, err := s.requestCreate(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.requestDo(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	meta := &MRCPStorageMetadata{
+		ContentType: resp.Header.Get("Content-Type"),
+		Size:        resp.ContentLength,
+	}
+	if size, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64); err == nil {
+		meta.Size = size
+	}
+	for name, values := range resp.Header {
+		if strings.HasPrefix(name, mrcpS3MetaHeaderPrefix) && len(values) > 0 {
+			if meta.Attributes == nil {
+				meta.Attributes = make(map[string]string)
+			}
+			meta.Attributes[strings.ToLower(name[len(mrcpS3MetaHeaderPrefix):])] = values[0]
+		}
+	}
+	return resp.Body, meta, nil
+}
+
+/** Delete object from the bucket */
+func (s *MRCPS3Storage) Delete(key string) error {
+	req, err := s.requestCreate(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := s.requestDo(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -2,13 +2,81 @@ package toolkit
 
 import (
 	"container/list"
+	"fmt"
+	"strings"
 )
 
+/** Numeric identifier of header field unknown (e.g. extension or vendor header), not indexed in header section */
+const UNKNOWN_HEADER_FIELD_ID int64 = -1
+
 type AptHeaderField struct {
-	Link  *list.List // Ring entry
-	Name  string     // Name of the header field
-	Value string     // Value of the header field
-	Id    int64      // Numeric identifier associated with name
+	Name  string // Name of the header field
+	Value string // Value of the header field
+	Id    int64  // Numeric identifier associated with name
+	/** Header field as received (without the terminating CRLF), generated as is while name and value are unchanged */
+	Raw string
+
+	ring    *list.List    // Ring of the header section the field is added to
+	element *list.Element // Ring entry of the field
+}
+
+/**
+ * Create header field.
+ * @param name the name of the header field
+ * @param value the value of the header field
+ * @param id the numeric identifier associated with name (UNKNOWN_HEADER_FIELD_ID if not known)
+ */
+func AptHeaderFieldCreate(name, value string, id int64) *AptHeaderField {
+	return &AptHeaderField{Name: name, Value: value, Id: id}
+}
+
+/**
+ * Parse header field, the name and the raw field are kept byte-exact.
+ * @param line the header field (without the terminating CRLF), folded lines are joined by CRLF
+ */
+func AptHeaderFieldParse(line string) (*AptHeaderField, error) {
+	sep := strings.IndexByte(line, ':')
+	if sep <= 0 {
+		return nil, fmt.Errorf("invalid header field %q", line)
+	}
+	name := line[:sep]
+	if strings.TrimSpace(name) != name {
+		return nil, fmt.Errorf("invalid header field name %q", name)
+	}
+	return &AptHeaderField{
+		Name:  name,
+		Value: aptHeaderFieldValueUnfold(line[sep+1:]),
+		Id:    UNKNOWN_HEADER_FIELD_ID,
+		Raw:   line,
+	}, nil
+}
+
+/* Unfold value of header field: leading and trailing whitespace is removed, folded lines are joined by space */
+func aptHeaderFieldValueUnfold(value string) string {
+	lines := strings.Split(value, "\r\n")
+	for i := range lines {
+		lines[i] = strings.Trim(lines[i], " \t")
+	}
+	return strings.Join(lines, " ")
+}
+
+/**
+ * Generate header field terminated by CRLF.
+ * @param field the header field to generate
+ * @remark The field received is generated byte-exact unless its name or value is changed
+ */
+func AptHeaderFieldGenerate(field *AptHeaderField) string {
+	if field.Raw != "" {
+		if parsed, err := AptHeaderFieldParse(field.Raw); err == nil && parsed.Name == field.Name && parsed.Value == field.Value {
+			return field.Raw + "\r\n"
+		}
+	}
+	return field.Name + ": " + field.Value + "\r\n"
+}
+
+/** Copy header field */
+func AptHeaderFieldCopy(field *AptHeaderField) *AptHeaderField {
+	return &AptHeaderField{Name: field.Name, Value: field.Value, Id: field.Id, Raw: field.Raw}
 }
 
 /** Initialize header section (collection of header fields) */
@@ -17,13 +85,41 @@ func AptHeaderSectionInit(header *AptHeaderSection) {
 	header.Arr = make([]*list.Element, 0)
 }
 
+/**
+ * Add header field to header section, the fields are kept in the order added.
+ * @param header the header section to add field to
+ * @param headerField the header field to add, indexed by its numeric identifier unless unknown
+ * @remark The field is added to one header section at a time, AptHeaderFieldCopy it to add to another one
+ */
+func (header *AptHeaderSection) AptHeaderSectionFieldAdd(headerField *AptHeaderField) error {
+	if header.Ring == nil {
+		AptHeaderSectionInit(header)
+	}
+	if headerField.ring != nil {
+		return fmt.Errorf("header field %s is already added", headerField.Name)
+	}
+	if headerField.Id < 0 {
+		headerField.ring, headerField.element = header.Ring, header.Ring.PushBack(headerField)
+		return nil
+	}
+	if header.AptHeaderSectionFieldCheck(headerField.Id) {
+		return fmt.Errorf("header field %s is already set", headerField.Name)
+	}
+	for int64(len(header.Arr)) <= headerField.Id {
+		header.Arr = append(header.Arr, nil)
+	}
+	headerField.ring, headerField.element = header.Ring, header.Ring.PushBack(headerField)
+	header.Arr[headerField.Id] = headerField.element
+	return nil
+}
+
 /**
  * Check whether specified header field is set.
  * @param header the header section to use
  * @param id the identifier associated with the header_field to check
  */
 func (header *AptHeaderSection) AptHeaderSectionFieldCheck(id int64) bool {
-	if id < int64(len(header.Arr)) {
+	if id >= 0 && id < int64(len(header.Arr)) {
 		return header.Arr[id] != nil
 	}
 	return false
@@ -35,23 +131,119 @@ func (header *AptHeaderSection) AptHeaderSectionFieldCheck(id int64) bool {
  * @param id the identifier associated with the header_field
  */
 func (header *AptHeaderSection) AptHeaderSectionFieldGet(id int64) *AptHeaderField {
-	if id < int64(len(header.Arr)) {
+	if header.AptHeaderSectionFieldCheck(id) {
 		return header.Arr[id].Value.(*AptHeaderField)
 	}
 	return nil
 }
 
+/* Get element of header field in the ring, nil if the field is not added to the header section */
+func (header *AptHeaderSection) aptHeaderSectionElementGet(headerField *AptHeaderField) *list.Element {
+	if header.Ring == nil || headerField.ring != header.Ring {
+		return nil
+	}
+	return headerField.element
+}
+
 /** Remove header field from header section */
 func (header *AptHeaderSection) AptHeaderSectionFieldRemove(headerField *AptHeaderField) error {
-	var (
-		e *list.Element
-	)
-	if headerField.Id < int64(len(header.Arr)) {
-		e = header.Arr[headerField.Id]
+	e := header.aptHeaderSectionElementGet(headerField)
+	if e == nil {
+		return fmt.Errorf("header field %s is not found", headerField.Name)
+	}
+	if header.AptHeaderSectionFieldCheck(headerField.Id) && header.Arr[headerField.Id] == e {
 		header.Arr[headerField.Id] = nil
-		//todo( 要确认下切片元素只需要设nil, 还是还需要删除元素???)
-		//header.Arr = append(header.Arr[:headerField.Id], header.Arr[headerField.Id+1:]...)
 	}
 	header.Ring.Remove(e)
+	headerField.ring, headerField.element = nil, nil
+	return nil
+}
+
+/**
+ * Get the next header field in the order added.
+ * @param header the header section to use
+ * @param headerField the current header field, nil to get the first one
+ */
+func (header *AptHeaderSection) AptHeaderSectionNextFieldGet(headerField *AptHeaderField) *AptHeaderField {
+	if header.Ring == nil {
+		return nil
+	}
+	e := header.Ring.Front()
+	if headerField != nil {
+		if e = header.aptHeaderSectionElementGet(headerField); e != nil {
+			e = e.Next()
+		}
+	}
+	if e == nil {
+		return nil
+	}
+	return e.Value.(*AptHeaderField)
+}
+
+/**
+ * Get header fields unknown (not indexed) in the order added.
+ * @param header the header section to use
+ */
+func (header *AptHeaderSection) AptHeaderSectionUnknownFieldsGet() []*AptHeaderField {
+	var fields []*AptHeaderField
+	if header.Ring == nil {
+		return nil
+	}
+	for e := header.Ring.Front(); e != nil; e = e.Next() {
+		if field := e.Value.(*AptHeaderField); field.Id < 0 {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+/**
+ * Parse header section, the fields are added in the order received.
+ * @param header the header section to parse fields to
+ * @param text the header section, the fields terminated by CRLF (the empty line terminating the section is optional)
+ * @param resolve the function resolving numeric identifier of the field by name (may be nil, all fields are unknown then)
+ */
+func (header *AptHeaderSection) AptHeaderSectionParse(text string, resolve func(name string) int64) error {
+	text = strings.TrimSuffix(text, "\r\n\r\n")
+	text = strings.TrimSuffix(text, "\r\n")
+	if text == "" {
+		return nil
+	}
+	var lines []string
+	for _, line := range strings.Split(text, "\r\n") {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			/* folded line continues the field */
+			lines[len(lines)-1] += "\r\n" + line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	for _, line := range lines {
+		field, err := AptHeaderFieldParse(line)
+		if err != nil {
+			return err
+		}
+		if resolve != nil {
+			field.Id = resolve(field.Name)
+		}
+		if err := header.AptHeaderSectionFieldAdd(field); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+/**
+ * Generate header section, the fields in the order added followed by the empty line.
+ * @param header the header section to generate
+ */
+func (header *AptHeaderSection) AptHeaderSectionGenerate() string {
+	var b strings.Builder
+	if header.Ring != nil {
+		for e := header.Ring.Front(); e != nil; e = e.Next() {
+			b.WriteString(AptHeaderFieldGenerate(e.Value.(*AptHeaderField)))
+		}
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
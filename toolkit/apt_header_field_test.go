@@ -0,0 +1,112 @@
+package toolkit
+
+import (
+	"strings"
+	"testing"
+)
+
+func headerTestResolve(name string) int64 {
+	switch strings.ToLower(name) {
+	case "channel-identifier":
+		return 0
+	case "content-type":
+		return 1
+	}
+	return UNKNOWN_HEADER_FIELD_ID
+}
+
+func TestHeaderSectionFolded(t *testing.T) {
+	var header AptHeaderSection
+	text := "Channel-Identifier: 32AECB23433801@speechsynth\r\nX-Vendor-Hint:  first\r\n\tsecond \r\n  third\r\nContent-Type: text/plain\r\n\r\n"
+	if err := header.AptHeaderSectionParse(text, headerTestResolve); err != nil {
+		t.Fatal(err)
+	}
+	unknown := header.AptHeaderSectionUnknownFieldsGet()
+	if len(unknown) != 1 || unknown[0].Name != "X-Vendor-Hint" || unknown[0].Value != "first second third" {
+		t.Fatalf("unknown fields %+v", unknown)
+	}
+	if field := header.AptHeaderSectionFieldGet(1); field == nil || field.Value != "text/plain" {
+		t.Fatalf("content type %+v", field)
+	}
+	/* folded field received is generated as is */
+	if generated := header.AptHeaderSectionGenerate(); generated != text {
+		t.Fatalf("generated %q", generated)
+	}
+
+	if err := header.AptHeaderSectionParse("Invalid\r\n", nil); err == nil {
+		t.Fatal("field of no colon is parsed")
+	}
+	if err := header.AptHeaderSectionParse(" Name : value\r\n", nil); err == nil {
+		t.Fatal("field of whitespace in name is parsed")
+	}
+}
+
+func TestHeaderSectionUnknownEcho(t *testing.T) {
+	var received AptHeaderSection
+	text := "x-trace-ID:abc;  q=1\r\nChannel-Identifier: 32AECB23433801@speechrecog\r\nX-Empty:\r\nx-trace-ID: second\r\n"
+	if err := received.AptHeaderSectionParse(text, headerTestResolve); err != nil {
+		t.Fatal(err)
+	}
+	unknown := received.AptHeaderSectionUnknownFieldsGet()
+	if len(unknown) != 3 || unknown[0].Value != "abc;  q=1" || unknown[1].Value != "" || unknown[2].Value != "second" {
+		t.Fatalf("unknown fields %+v", unknown)
+	}
+
+	/* the unknown fields echoed in the order received, byte-exact */
+	var echoed AptHeaderSection
+	for _, field := range unknown {
+		if err := echoed.AptHeaderSectionFieldAdd(AptHeaderFieldCopy(field)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if generated := echoed.AptHeaderSectionGenerate(); generated != "x-trace-ID:abc;  q=1\r\nX-Empty:\r\nx-trace-ID: second\r\n\r\n" {
+		t.Fatalf("echoed %q", generated)
+	}
+	/* the field changed is generated anew */
+	echoed.AptHeaderSectionNextFieldGet(nil).Value = "changed"
+	if generated := echoed.AptHeaderSectionGenerate(); !strings.HasPrefix(generated, "x-trace-ID: changed\r\n") {
+		t.Fatalf("generated %q", generated)
+	}
+	/* the field added to a header section is copied to be added to another one */
+	if err := echoed.AptHeaderSectionFieldAdd(unknown[0]); err == nil {
+		t.Fatal("field of other header section is added")
+	}
+}
+
+func TestHeaderSectionIteration(t *testing.T) {
+	var header AptHeaderSection
+	fields := []*AptHeaderField{
+		AptHeaderFieldCreate("X-A", "1", UNKNOWN_HEADER_FIELD_ID),
+		AptHeaderFieldCreate("Content-Type", "text/plain", 1),
+		AptHeaderFieldCreate("X-B", "2", UNKNOWN_HEADER_FIELD_ID),
+		AptHeaderFieldCreate("X-C", "3", UNKNOWN_HEADER_FIELD_ID),
+	}
+	for _, field := range fields {
+		if err := header.AptHeaderSectionFieldAdd(field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := header.AptHeaderSectionFieldAdd(AptHeaderFieldCreate("Content-Type", "text/xml", 1)); err == nil {
+		t.Fatal("field of the id set is added twice")
+	}
+	if err := header.AptHeaderSectionFieldRemove(fields[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := header.AptHeaderSectionFieldRemove(fields[2]); err == nil {
+		t.Fatal("field removed is removed again")
+	}
+	var names []string
+	for field := header.AptHeaderSectionNextFieldGet(nil); field != nil; field = header.AptHeaderSectionNextFieldGet(field) {
+		names = append(names, field.Name)
+	}
+	if strings.Join(names, ",") != "X-A,Content-Type,X-C" {
+		t.Fatalf("fields %v", names)
+	}
+	/* the field not added has no next field */
+	if field := header.AptHeaderSectionNextFieldGet(fields[2]); field != nil {
+		t.Fatalf("next field of removed field %+v", field)
+	}
+	if err := header.AptHeaderSectionFieldRemove(fields[1]); err != nil || header.AptHeaderSectionFieldCheck(1) {
+		t.Fatalf("indexed field is not removed: %v", err)
+	}
+}
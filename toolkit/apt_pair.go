@@ -1,6 +1,53 @@
 package toolkit
 
-import "github.com/navi-tt/go-mrcp/apr"
+import (
+	"strings"
+
+	"github.com/navi-tt/go-mrcp/apr"
+)
+
+/** Name-value pair */
+type AptPair struct {
+	Name  string // The name
+	Value string // The value
+}
 
 /** Dynamic array of name-value pairs */
 type AptPairArr = apr.ArrayHeader
+
+/** Create array of name-value pairs */
+func AptPairArrayCreate(initialCount int) *AptPairArr {
+	return apr.NewArrayHeader(initialCount)
+}
+
+/** Append name-value pair */
+func AptPairArrayAppend(arr *AptPairArr, name, value string) {
+	arr.Stack.Push(&AptPair{Name: name, Value: value})
+}
+
+/** Get number of name-value pairs */
+func AptPairArraySize(arr *AptPairArr) int {
+	if arr == nil {
+		return 0
+	}
+	return arr.Stack.Size()
+}
+
+/** Get name-value pair by index */
+func AptPairArrayGet(arr *AptPairArr, id int) *AptPair {
+	if id < 0 || id >= AptPairArraySize(arr) {
+		return nil
+	}
+	pair, _ := arr.ArrayHeaderIndex(id).(*AptPair)
+	return pair
+}
+
+/** Find name-value pair by name (case insensitive) */
+func AptPairArrayFind(arr *AptPairArr, name string) *AptPair {
+	for i := 0; i < AptPairArraySize(arr); i++ {
+		if pair := AptPairArrayGet(arr, i); pair != nil && strings.EqualFold(pair.Name, name) {
+			return pair
+		}
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package websocketx
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+/* Minimal WebSocket (RFC 6455) connection: handshake, framing, fragmentation, ping/pong and close */
+
+/** Message (frame) opcodes */
+const (
+	OP_CONTINUATION = 0x0
+	OP_TEXT         = 0x1
+	OP_BINARY       = 0x2
+	OP_CLOSE        = 0x8
+	OP_PING         = 0x9
+	OP_PONG         = 0xa
+)
+
+/** Max size of message read */
+const MAX_MESSAGE_SIZE = 1 << 20
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+/** WebSocket connection */
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	/** Frames sent by client are masked */
+	client bool
+
+	writeMutex sync.Mutex
+	closeOnce  sync.Once
+}
+
+func acceptKeyGet(key string) string {
+	hash := sha1.Sum([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash[:])
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header[http.CanonicalHeaderKey(name)] {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+/**
+ * Upgrade HTTP request to WebSocket connection (server side).
+ * @param w the response writer, which must support hijacking
+ * @param r the request
+ */
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") || key == "" {
+		http.Error(w, "WebSocket upgrade is expected", http.StatusBadRequest)
+		return nil, fmt.Errorf("not a WebSocket upgrade request")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported WebSocket version", http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("unsupported WebSocket version %s", r.Header.Get("Sec-WebSocket-Version"))
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket is not supported", http.StatusInternalServerError)
+		return nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	response := "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKeyGet(key) + "\r\n\r\n"
+	if _, err := conn.Write([]byte(response)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &Conn{conn: conn, reader: rw.Reader}, nil
+}
+
+/**
+ * Dial WebSocket server (client side).
+ * @param rawurl the URL of the server (ws:// or wss://)
+ * @param header the additional headers of the handshake request (may be nil)
+ */
+func Dial(rawurl string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	host := u.Host
+	var conn net.Conn
+	switch u.Scheme {
+	case "ws":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+		conn, err = net.Dial("tcp", host)
+	case "wss":
+		if u.Port() == "" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		}
+		conn, err = tls.Dial("tcp", host, &tls.Config{ServerName: u.Hostname()})
+	default:
+		return nil, fmt.Errorf("unsupported scheme %s", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(nonce)
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Accept") != acceptKeyGet(key) {
+		conn.Close()
+		return nil, fmt.Errorf("WebSocket handshake failed: %s", resp.Status)
+	}
+	return &Conn{conn: conn, reader: reader, client: true}, nil
+}
+
+/* Read frame: fin flag, opcode and payload */
+func (c *Conn) frameRead() (bool, int, []byte, error) {
+	head := make([]byte, 2)
+	if _, err := io.ReadFull(c.reader, head); err != nil {
+		return false, 0, nil, err
+	}
+	fin, opcode := head[0]&0x80 != 0, int(head[0]&0x0f)
+	masked := head[1]&0x80 != 0
+	size := uint64(head[1] & 0x7f)
+	switch size {
+	case 126:
+		ext := make([]byte, 2)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = uint64(binary.BigEndian.Uint16(ext))
+	case 127:
+		ext := make([]byte, 8)
+		if _, err := io.ReadFull(c.reader, ext); err != nil {
+			return false, 0, nil, err
+		}
+		size = binary.BigEndian.Uint64(ext)
+	}
+	if size > MAX_MESSAGE_SIZE {
+		return false, 0, nil, fmt.Errorf("WebSocket frame of %d bytes is too large", size)
+	}
+	var mask []byte
+	if masked {
+		mask = make([]byte, 4)
+		if _, err := io.ReadFull(c.reader, mask); err != nil {
+			return false, 0, nil, err
+		}
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, 0, nil, err
+	}
+	for i := range payload {
+		if masked {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return fin, opcode, payload, nil
+}
+
+/* Write frame, masked if written by client */
+func (c *Conn) frameWrite(opcode int, payload []byte) error {
+	frame := make([]byte, 0, len(payload)+14)
+	frame = append(frame, 0x80|byte(opcode))
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, maskBit|byte(len(payload)))
+	case len(payload) <= 0xffff:
+		frame = append(frame, maskBit|126, byte(len(payload)>>8), byte(len(payload)))
+	default:
+		ext := make([]byte, 8)
+		binary.BigEndian.PutUint64(ext, uint64(len(payload)))
+		frame = append(append(frame, maskBit|127), ext...)
+	}
+	if c.client {
+		mask := make([]byte, 4)
+		if _, err := rand.Read(mask); err != nil {
+			return err
+		}
+		frame = append(frame, mask...)
+		for i, b := range payload {
+			frame = append(frame, b^mask[i%4])
+		}
+	} else {
+		frame = append(frame, payload...)
+	}
+	c.writeMutex.Lock()
+	defer c.writeMutex.Unlock()
+	_, err := c.conn.Write(frame)
+	return err
+}
+
+/**
+ * Read message, control frames are handled (ping answered by pong) on the way.
+ * @return the opcode (OP_TEXT or OP_BINARY) and payload of the message, io.EOF if the connection is closed by peer
+ */
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var (
+		message []byte
+		opcode  int
+	)
+	for {
+		fin, op, payload, err := c.frameRead()
+		if err != nil {
+			return 0, nil, err
+		}
+		switch op {
+		case OP_PING:
+			if err := c.frameWrite(OP_PONG, payload); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OP_PONG:
+			continue
+		case OP_CLOSE:
+			c.frameWrite(OP_CLOSE, payload)
+			return 0, nil, io.EOF
+		case OP_CONTINUATION:
+			if opcode == 0 {
+				return 0, nil, fmt.Errorf("unexpected WebSocket continuation frame")
+			}
+		default:
+			opcode = op
+		}
+		if len(message)+len(payload) > MAX_MESSAGE_SIZE {
+			return 0, nil, fmt.Errorf("WebSocket message is too large")
+		}
+		message = append(message, payload...)
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+/**
+ * Write message.
+ * @param opcode the opcode of the message (OP_TEXT or OP_BINARY)
+ * @param payload the payload of the message
+ */
+func (c *Conn) WriteMessage(opcode int, payload []byte) error {
+	return c.frameWrite(opcode, payload)
+}
+
+/** Close connection, the close frame is sent to the peer */
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.frameWrite(OP_CLOSE, []byte{0x03, 0xe8}) /* 1000 normal closure */
+		err = c.conn.Close()
+	})
+	return err
+}